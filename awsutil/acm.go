@@ -1,20 +1,36 @@
 package awsutil
 
 import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/acm"
 	"github.com/aws/aws-sdk-go/service/acm/acmiface"
+	"github.com/karlseguin/ccache"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // ACM is our extension to AWS's ACM.acm
 type ACM struct {
-	Svc acmiface.ACMAPI
+	Svc   acmiface.ACMAPI
+	cache APICache
+	// Requests requests DNS validated certificates, which Svc can't
+	Requests *ACMRequests
 }
 
 // NewACM returns an ACM based off of the provided AWS session
 func NewACM(awsSession *session.Session) *ACM {
+	a := NewACMWithClient(acm.New(awsSession))
+	a.Requests = NewACMRequests(awsSession)
+	return a
+}
+
+// NewACMWithClient returns an ACM making its calls through svc.
+func NewACMWithClient(svc acmiface.ACMAPI) *ACM {
 	elbClient := ACM{
-		acm.New(awsSession),
+		Svc:   svc,
+		cache: APICache{ccache.New(ccache.Configure())},
 	}
 	return &elbClient
 }
@@ -26,3 +42,158 @@ func (a *ACM) CertExists(arn *string) bool {
 	}
 	return true
 }
+
+// DescribeCertificate looks up the details of an ACM certificate by its ARN.
+func (a *ACM) DescribeCertificate(arn *string) (*acm.CertificateDetail, error) {
+	o, err := a.Svc.DescribeCertificate(&acm.DescribeCertificateInput{CertificateArn: arn})
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "ACM", "request": "DescribeCertificate", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+	return o.Certificate, nil
+}
+
+// LookupCertificate returns the details of an ACM certificate like DescribeCertificate, caching
+// them for a while. It's meant for matching the domain names of certificates, which don't change
+// unless a certificate is reimported.
+func (a *ACM) LookupCertificate(arn *string) (*acm.CertificateDetail, error) {
+	key := "certificate" + *arn
+	if item := a.cache.Get(key); item != nil {
+		AWSCache.With(prometheus.Labels{"cache": "certificate", "action": "hit"}).Add(float64(1))
+		return item.Value().(*acm.CertificateDetail), nil
+	}
+	AWSCache.With(prometheus.Labels{"cache": "certificate", "action": "miss"}).Add(float64(1))
+
+	certificate, err := a.DescribeCertificate(arn)
+	if err != nil {
+		return nil, err
+	}
+	a.cache.Set(key, certificate, time.Minute*30)
+	return certificate, nil
+}
+
+// DescribeIssuedCertificates returns the details of every issued ACM certificate in the region.
+// The list of certificates is cached for a few minutes, their details like LookupCertificate.
+func (a *ACM) DescribeIssuedCertificates() ([]*acm.CertificateDetail, error) {
+	var arns []*string
+	if item := a.cache.Get("issued"); item != nil {
+		AWSCache.With(prometheus.Labels{"cache": "certificate", "action": "hit"}).Add(float64(1))
+		arns = item.Value().([]*string)
+	} else {
+		AWSCache.With(prometheus.Labels{"cache": "certificate", "action": "miss"}).Add(float64(1))
+		in := &acm.ListCertificatesInput{CertificateStatuses: []*string{aws.String(acm.CertificateStatusIssued)}}
+		err := a.Svc.ListCertificatesPages(in, func(page *acm.ListCertificatesOutput, lastPage bool) bool {
+			for _, summary := range page.CertificateSummaryList {
+				arns = append(arns, summary.CertificateArn)
+			}
+			return true
+		})
+		if err != nil {
+			AWSErrorCount.With(
+				prometheus.Labels{"service": "ACM", "request": "ListCertificates", "code": ErrorCode(err)}).Add(float64(1))
+			return nil, err
+		}
+		a.cache.Set("issued", arns, time.Minute*5)
+	}
+
+	var certificates []*acm.CertificateDetail
+	for _, arn := range arns {
+		certificate, err := a.LookupCertificate(arn)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+	return certificates, nil
+}
+
+// ImportCertificate imports a certificate into ACM, returning its ARN. When in.CertificateArn is
+// set, the certificate with that ARN is replaced in place and keeps its ARN.
+func (a *ACM) ImportCertificate(in acm.ImportCertificateInput) (*string, error) {
+	o, err := a.Svc.ImportCertificate(&in)
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "ACM", "request": "ImportCertificate", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+	return o.CertificateArn, nil
+}
+
+// AddTagsToCertificate sets tags on an ACM certificate. Existing tags with the same keys are
+// overwritten.
+func (a *ACM) AddTagsToCertificate(arn *string, tags map[string]string) error {
+	in := &acm.AddTagsToCertificateInput{CertificateArn: arn}
+	for k, v := range tags {
+		in.Tags = append(in.Tags, &acm.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	if _, err := a.Svc.AddTagsToCertificate(in); err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "ACM", "request": "AddTagsToCertificate", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+	a.cache.Delete("tags" + *arn)
+	return nil
+}
+
+// FindCertificate returns the ARN and tags of an ACM certificate carrying all of tags, or a nil
+// ARN when there is none. The certificates of the region are listed and their tags looked up, so it
+// should only be used to look up certificates whose ARN isn't known yet. The tags of each
+// certificate are cached, so certificates that don't carry them aren't looked up again on every
+// call.
+func (a *ACM) FindCertificate(tags map[string]string) (*string, map[string]string, error) {
+	var arns []*string
+	err := a.Svc.ListCertificatesPages(&acm.ListCertificatesInput{}, func(page *acm.ListCertificatesOutput, lastPage bool) bool {
+		for _, summary := range page.CertificateSummaryList {
+			arns = append(arns, summary.CertificateArn)
+		}
+		return true
+	})
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "ACM", "request": "ListCertificates", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, nil, err
+	}
+
+	for _, arn := range arns {
+		current, err := a.certificateTags(arn)
+		if err != nil {
+			return nil, nil, err
+		}
+		matches := true
+		for k, v := range tags {
+			if current[k] != v {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return arn, current, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// certificateTags returns the tags of the ACM certificate arn, caching them for a while. Tags set
+// through AddTagsToCertificate replace the cached ones.
+func (a *ACM) certificateTags(arn *string) (map[string]string, error) {
+	key := "tags" + *arn
+	if item := a.cache.Get(key); item != nil {
+		AWSCache.With(prometheus.Labels{"cache": "certificate-tags", "action": "hit"}).Add(float64(1))
+		return item.Value().(map[string]string), nil
+	}
+	AWSCache.With(prometheus.Labels{"cache": "certificate-tags", "action": "miss"}).Add(float64(1))
+
+	o, err := a.Svc.ListTagsForCertificate(&acm.ListTagsForCertificateInput{CertificateArn: arn})
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "ACM", "request": "ListTagsForCertificate", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+	tags := make(map[string]string)
+	for _, tag := range o.Tags {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	a.cache.Set(key, tags, time.Hour)
+	return tags, nil
+}
@@ -0,0 +1,74 @@
+package awsutil
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"github.com/aws/aws-sdk-go/service/acm/acmiface"
+)
+
+// taggedACM is an acmiface.ACMAPI holding tagged certificates, counting the ListTagsForCertificate
+// calls.
+type taggedACM struct {
+	acmiface.ACMAPI
+
+	tags      map[string]map[string]string
+	tagsCalls int
+}
+
+func (a *taggedACM) ListCertificatesPages(in *acm.ListCertificatesInput, fn func(*acm.ListCertificatesOutput, bool) bool) error {
+	page := &acm.ListCertificatesOutput{}
+	for _, arn := range []string{"arn-1", "arn-2", "arn-3"} {
+		page.CertificateSummaryList = append(page.CertificateSummaryList, &acm.CertificateSummary{CertificateArn: aws.String(arn)})
+	}
+	fn(page, true)
+	return nil
+}
+
+func (a *taggedACM) ListTagsForCertificate(in *acm.ListTagsForCertificateInput) (*acm.ListTagsForCertificateOutput, error) {
+	a.tagsCalls++
+	out := &acm.ListTagsForCertificateOutput{}
+	for k, v := range a.tags[*in.CertificateArn] {
+		out.Tags = append(out.Tags, &acm.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return out, nil
+}
+
+func (a *taggedACM) AddTagsToCertificate(in *acm.AddTagsToCertificateInput) (*acm.AddTagsToCertificateOutput, error) {
+	if a.tags[*in.CertificateArn] == nil {
+		a.tags[*in.CertificateArn] = make(map[string]string)
+	}
+	for _, tag := range in.Tags {
+		a.tags[*in.CertificateArn][*tag.Key] = *tag.Value
+	}
+	return &acm.AddTagsToCertificateOutput{}, nil
+}
+
+func TestFindCertificate(t *testing.T) {
+	svc := &taggedACM{tags: map[string]map[string]string{
+		"arn-1": {"Team": "a"},
+		"arn-3": {"Secret": "default/web"},
+	}}
+	a := NewACMWithClient(svc)
+
+	arn, tags, err := a.FindCertificate(map[string]string{"Secret": "default/web"})
+	if err != nil || aws.StringValue(arn) != "arn-3" || tags["Secret"] != "default/web" {
+		t.Fatalf("FindCertificate() = %v, %v, %v, expected arn-3", aws.StringValue(arn), tags, err)
+	}
+	if svc.tagsCalls != 3 {
+		t.Errorf("FindCertificate(): expected the tags of 3 certificates to be listed, actual %d", svc.tagsCalls)
+	}
+
+	// The tags are cached, except for the ones the controller sets.
+	if err := a.AddTagsToCertificate(aws.String("arn-2"), map[string]string{"Secret": "default/api"}); err != nil {
+		t.Fatalf("AddTagsToCertificate() returned error %v", err)
+	}
+	arn, _, err = a.FindCertificate(map[string]string{"Secret": "default/api"})
+	if err != nil || aws.StringValue(arn) != "arn-2" {
+		t.Fatalf("FindCertificate() = %v, %v, expected arn-2", aws.StringValue(arn), err)
+	}
+	if svc.tagsCalls != 4 {
+		t.Errorf("FindCertificate(): expected only the tags of arn-2 to be listed again, actual %d calls", svc.tagsCalls)
+	}
+}
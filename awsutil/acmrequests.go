@@ -0,0 +1,173 @@
+package awsutil
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/jsonrpc"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ValidationMethodDNS is the validation method of the certificates requested through ACMRequests.
+const ValidationMethodDNS = "DNS"
+
+// ACMRequests is a client of the ACM operations requesting DNS validated certificates. The vendored
+// aws-sdk-go predates DNS validation: its RequestCertificateInput has no ValidationMethod, and the
+// domain validations it describes carry no ResourceRecord.
+type ACMRequests struct {
+	*client.Client
+}
+
+// NewACMRequests returns an ACMRequests client based off of the provided AWS session.
+func NewACMRequests(awsSession *session.Session) *ACMRequests {
+	c := awsSession.ClientConfig("acm")
+	a := &ACMRequests{
+		Client: client.New(
+			*c.Config,
+			metadata.ClientInfo{
+				ServiceName:   "acm",
+				SigningName:   c.SigningName,
+				SigningRegion: c.SigningRegion,
+				Endpoint:      c.Endpoint,
+				APIVersion:    "2015-12-08",
+				JSONVersion:   "1.1",
+				TargetPrefix:  "CertificateManager",
+			},
+			c.Handlers,
+		),
+	}
+	a.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	a.Handlers.Build.PushBackNamed(jsonrpc.BuildHandler)
+	a.Handlers.Unmarshal.PushBackNamed(jsonrpc.UnmarshalHandler)
+	a.Handlers.UnmarshalMeta.PushBackNamed(jsonrpc.UnmarshalMetaHandler)
+	a.Handlers.UnmarshalError.PushBackNamed(jsonrpc.UnmarshalErrorHandler)
+	return a
+}
+
+// NewACMRequestsWithClient returns an ACMRequests making its calls through c, e.g. one served by an
+// in-memory fake from the awsutil/fake package.
+func NewACMRequestsWithClient(c *client.Client) *ACMRequests {
+	return &ACMRequests{Client: c}
+}
+
+type acmTag struct {
+	_ struct{} `type:"structure"`
+
+	Key   *string `type:"string"`
+	Value *string `type:"string"`
+}
+
+type requestCertificateInput struct {
+	_ struct{} `type:"structure"`
+
+	DomainName              *string   `type:"string"`
+	IdempotencyToken        *string   `type:"string"`
+	SubjectAlternativeNames []*string `type:"list"`
+	Tags                    []*acmTag `type:"list"`
+	ValidationMethod        *string   `type:"string"`
+}
+
+type requestCertificateOutput struct {
+	_ struct{} `type:"structure"`
+
+	CertificateArn *string `type:"string"`
+}
+
+type describeCertificateValidationInput struct {
+	_ struct{} `type:"structure"`
+
+	CertificateArn *string `type:"string"`
+}
+
+type describeCertificateValidationOutput struct {
+	_ struct{} `type:"structure"`
+
+	Certificate *certificateValidationDetail `type:"structure"`
+}
+
+type certificateValidationDetail struct {
+	_ struct{} `type:"structure"`
+
+	DomainValidationOptions []*domainValidation `type:"list"`
+	Status                  *string             `type:"string"`
+}
+
+type domainValidation struct {
+	_ struct{} `type:"structure"`
+
+	DomainName       *string         `type:"string"`
+	ResourceRecord   *ResourceRecord `type:"structure"`
+	ValidationMethod *string         `type:"string"`
+	ValidationStatus *string         `type:"string"`
+}
+
+// ResourceRecord is the DNS record ACM looks up to validate a domain name of a certificate.
+type ResourceRecord struct {
+	_ struct{} `type:"structure"`
+
+	Name  *string `type:"string"`
+	Type  *string `type:"string"`
+	Value *string `type:"string"`
+}
+
+// CertificateValidation is the status of a certificate requested with DNS validation, one of the
+// acm.CertificateStatus values, and the records validating its domain names. Records ACM hasn't
+// generated yet are missing.
+type CertificateValidation struct {
+	Status  string
+	Records []*ResourceRecord
+}
+
+// RequestCertificate requests a certificate for domainName and subjectAlternativeNames, validated
+// through DNS, and tagged with tags. Requests with the same idempotencyToken within an hour return
+// the certificate of the first one.
+func (a *ACMRequests) RequestCertificate(domainName string, subjectAlternativeNames []string, idempotencyToken string, tags map[string]string) (*string, error) {
+	in := &requestCertificateInput{
+		DomainName:       aws.String(domainName),
+		IdempotencyToken: aws.String(idempotencyToken),
+		ValidationMethod: aws.String(ValidationMethodDNS),
+	}
+	if len(subjectAlternativeNames) > 0 {
+		in.SubjectAlternativeNames = aws.StringSlice(append([]string{domainName}, subjectAlternativeNames...))
+	}
+	for k, v := range tags {
+		in.Tags = append(in.Tags, &acmTag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	out := &requestCertificateOutput{}
+	op := &request.Operation{Name: "RequestCertificate", HTTPMethod: "POST", HTTPPath: "/"}
+	if err := a.NewRequest(op, in, out).Send(); err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "ACM", "request": "RequestCertificate", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+	return out.CertificateArn, nil
+}
+
+// DescribeCertificateValidation returns the status of the certificate arn and the records
+// validating its domain names through DNS. Domain names sharing a record are only listed once.
+func (a *ACMRequests) DescribeCertificateValidation(arn *string) (*CertificateValidation, error) {
+	in := &describeCertificateValidationInput{CertificateArn: arn}
+	out := &describeCertificateValidationOutput{}
+	op := &request.Operation{Name: "DescribeCertificate", HTTPMethod: "POST", HTTPPath: "/"}
+	if err := a.NewRequest(op, in, out).Send(); err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "ACM", "request": "DescribeCertificate", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+	validation := &CertificateValidation{}
+	if out.Certificate == nil {
+		return validation, nil
+	}
+	validation.Status = aws.StringValue(out.Certificate.Status)
+	seen := make(map[string]bool)
+	for _, option := range out.Certificate.DomainValidationOptions {
+		record := option.ResourceRecord
+		if record == nil || seen[aws.StringValue(record.Name)] {
+			continue
+		}
+		seen[aws.StringValue(record.Name)] = true
+		validation.Records = append(validation.Records, record)
+	}
+	return validation, nil
+}
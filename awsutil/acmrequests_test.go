@@ -0,0 +1,80 @@
+package awsutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestACMRequests(t *testing.T) {
+	var targets []string
+	var requests []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		targets = append(targets, r.Header.Get("X-Amz-Target"))
+		b, _ := ioutil.ReadAll(r.Body)
+		var body map[string]interface{}
+		json.Unmarshal(b, &body)
+		requests = append(requests, body)
+		if r.Header.Get("X-Amz-Target") == "CertificateManager.RequestCertificate" {
+			fmt.Fprint(w, `{"CertificateArn":"arn-1"}`)
+			return
+		}
+		// The wildcard shares the record of the domain it's a wildcard of, and the record of the
+		// last domain isn't generated yet.
+		fmt.Fprint(w, `{"Certificate":{"Status":"PENDING_VALIDATION","DomainValidationOptions":[
+			{"DomainName":"example.com","ResourceRecord":{"Name":"_a.example.com.","Type":"CNAME","Value":"_b.acm-validations.aws."}},
+			{"DomainName":"*.example.com","ResourceRecord":{"Name":"_a.example.com.","Type":"CNAME","Value":"_b.acm-validations.aws."}},
+			{"DomainName":"example.org"}]}}`)
+	}))
+	defer server.Close()
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+	requester := NewACMRequests(sess)
+	arn, err := requester.RequestCertificate("example.com", []string{"*.example.com", "example.org"}, "token", map[string]string{"ClusterName": "cluster"})
+	if err != nil || aws.StringValue(arn) != "arn-1" {
+		t.Fatalf("RequestCertificate(): returned %v, error %v", aws.StringValue(arn), err)
+	}
+	validation, err := requester.DescribeCertificateValidation(arn)
+	if err != nil {
+		t.Fatalf("DescribeCertificateValidation(): returned error %v", err)
+	}
+	expectedValidation := &CertificateValidation{
+		Status: "PENDING_VALIDATION",
+		Records: []*ResourceRecord{
+			{Name: aws.String("_a.example.com."), Type: aws.String("CNAME"), Value: aws.String("_b.acm-validations.aws.")},
+		},
+	}
+	if !reflect.DeepEqual(validation, expectedValidation) {
+		t.Errorf("DescribeCertificateValidation(): expected %v, actual %v", expectedValidation, validation)
+	}
+
+	expectedTargets := []string{"CertificateManager.RequestCertificate", "CertificateManager.DescribeCertificate"}
+	if !reflect.DeepEqual(targets, expectedTargets) {
+		t.Errorf("ACMRequests: expected targets %v, actual %v", expectedTargets, targets)
+	}
+	expected := []map[string]interface{}{
+		{
+			"DomainName":              "example.com",
+			"IdempotencyToken":        "token",
+			"SubjectAlternativeNames": []interface{}{"example.com", "*.example.com", "example.org"},
+			"Tags":                    []interface{}{map[string]interface{}{"Key": "ClusterName", "Value": "cluster"}},
+			"ValidationMethod":        "DNS",
+		},
+		{"CertificateArn": "arn-1"},
+	}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf("ACMRequests: expected requests %v, actual %v", expected, requests)
+	}
+}
@@ -0,0 +1,84 @@
+package awsutil
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/query"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LifecycleActionResultContinue lets Auto Scaling go ahead with the instance its lifecycle action
+// is for, e.g. terminate it.
+const LifecycleActionResultContinue = "CONTINUE"
+
+// AutoScaling is a client of the Auto Scaling operations the controller calls, which the vendored
+// aws-sdk-go has no client for.
+type AutoScaling struct {
+	*client.Client
+}
+
+// NewAutoScaling returns an AutoScaling client based off of the provided AWS session.
+func NewAutoScaling(awsSession *session.Session) *AutoScaling {
+	c := awsSession.ClientConfig("autoscaling")
+	a := &AutoScaling{
+		Client: client.New(
+			*c.Config,
+			metadata.ClientInfo{
+				ServiceName:   "autoscaling",
+				SigningName:   c.SigningName,
+				SigningRegion: c.SigningRegion,
+				Endpoint:      c.Endpoint,
+				APIVersion:    "2011-01-01",
+			},
+			c.Handlers,
+		),
+	}
+	a.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	a.Handlers.Build.PushBackNamed(query.BuildHandler)
+	a.Handlers.Unmarshal.PushBackNamed(query.UnmarshalHandler)
+	a.Handlers.UnmarshalMeta.PushBackNamed(query.UnmarshalMetaHandler)
+	a.Handlers.UnmarshalError.PushBackNamed(query.UnmarshalErrorHandler)
+	return a
+}
+
+// NewAutoScalingWithClient returns an AutoScaling making its calls through c, e.g. one served by an
+// in-memory fake from the awsutil/fake package.
+func NewAutoScalingWithClient(c *client.Client) *AutoScaling {
+	return &AutoScaling{Client: c}
+}
+
+type completeLifecycleActionInput struct {
+	_ struct{} `type:"structure"`
+
+	AutoScalingGroupName  *string `type:"string"`
+	InstanceId            *string `type:"string"`
+	LifecycleActionResult *string `type:"string"`
+	LifecycleActionToken  *string `type:"string"`
+	LifecycleHookName     *string `type:"string"`
+}
+
+type completeLifecycleActionOutput struct {
+	_ struct{} `type:"structure"`
+}
+
+// CompleteLifecycleAction completes the lifecycle action, with result, e.g.
+// LifecycleActionResultContinue.
+func (a *AutoScaling) CompleteLifecycleAction(action *LifecycleAction, result string) error {
+	in := &completeLifecycleActionInput{
+		AutoScalingGroupName:  aws.String(action.GroupName),
+		InstanceId:            aws.String(action.InstanceID),
+		LifecycleActionResult: aws.String(result),
+		LifecycleActionToken:  aws.String(action.Token),
+		LifecycleHookName:     aws.String(action.HookName),
+	}
+	op := &request.Operation{Name: "CompleteLifecycleAction", HTTPMethod: "POST", HTTPPath: "/"}
+	if err := a.NewRequest(op, in, &completeLifecycleActionOutput{}).Send(); err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "AutoScaling", "request": "CompleteLifecycleAction", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package awsutil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// ErrCodeCircuitOpen is the error code of calls rejected while a service's circuit is open.
+	ErrCodeCircuitOpen = "CircuitOpen"
+
+	// Default number of consecutive failures to a service before its circuit opens
+	defaultCircuitThreshold int = 5
+	// Default amount of time a circuit stays open before a probe call is let through
+	defaultCircuitCooldown = 30 * time.Second
+)
+
+// Breaker is the circuit breaker guarding the AWS services. It's nil when circuit breaking is
+// disabled.
+var Breaker *CircuitBreaker
+
+// CircuitBreaker pauses mutating calls to an AWS service after a number of consecutive failures,
+// so an outage doesn't turn every reconcile into a hot loop of failing changes. Once the cooldown
+// elapsed, a single mutating call is let through as a probe. Its success closes the circuit, its
+// failure keeps the circuit open for another cooldown. Read calls are never paused.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	failures int
+	open     bool
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreaker returns a CircuitBreaker opening a service's circuit after threshold
+// consecutive failures and probing the service every cooldown while open. Values equal to zero
+// leave the respective default in place. A negative threshold disables circuit breaking, in which
+// case nil is returned.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold < 0 {
+		return nil
+	}
+	if threshold == 0 {
+		threshold = defaultCircuitThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = defaultCircuitCooldown
+	}
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		circuits:  make(map[string]*circuit),
+	}
+}
+
+// Attach installs the circuit breaker on the AWS session. It must be called before the service
+// clients are created from the session, as clients copy the session handlers.
+func (cb *CircuitBreaker) Attach(s *session.Session) {
+	s.Handlers.Validate.PushFront(func(r *request.Request) {
+		if !cb.allow(r.ClientInfo.ServiceName, isMutating(r.Operation.Name)) {
+			r.Error = awserr.New(ErrCodeCircuitOpen,
+				fmt.Sprintf("%s calls are paused after %d consecutive failures", r.ClientInfo.ServiceName, cb.threshold), nil)
+		}
+	})
+	s.Handlers.Complete.PushBack(func(r *request.Request) {
+		if ErrorCode(r.Error) == ErrCodeCircuitOpen {
+			return
+		}
+		cb.record(r.ClientInfo.ServiceName, isMutating(r.Operation.Name), isServiceFailure(r))
+	})
+}
+
+// OpenCircuits returns the names of the services whose circuit is currently open.
+func (cb *CircuitBreaker) OpenCircuits() []string {
+	if cb == nil {
+		return nil
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	var services []string
+	for service, c := range cb.circuits {
+		if c.open {
+			services = append(services, service)
+		}
+	}
+	sort.Strings(services)
+	return services
+}
+
+// allow reports whether a call to service may be made. While the circuit is open only reads are
+// allowed, plus one mutating probe once the cooldown elapsed.
+func (cb *CircuitBreaker) allow(service string, mutating bool) bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c := cb.circuit(service)
+	if !c.open || !mutating {
+		return true
+	}
+	if c.probing || cb.now().Sub(c.openedAt) < cb.cooldown {
+		return false
+	}
+	c.probing = true
+	return true
+}
+
+// record updates the circuit of service with the outcome of a call.
+func (cb *CircuitBreaker) record(service string, mutating, failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c := cb.circuit(service)
+	if c.open {
+		// Only the probe decides whether the circuit closes again.
+		if !mutating || !c.probing {
+			return
+		}
+		c.probing = false
+		if failed {
+			c.openedAt = cb.now()
+			glog.Errorf("Probe of %s failed, keeping its circuit open for %s.", service, cb.cooldown)
+			return
+		}
+		c.open = false
+		c.failures = 0
+		AWSCircuitOpen.With(prometheus.Labels{"service": service}).Set(0)
+		glog.Infof("Probe of %s succeeded, resuming calls.", service)
+		return
+	}
+
+	if !failed {
+		c.failures = 0
+		return
+	}
+	c.failures++
+	if c.failures >= cb.threshold {
+		c.open = true
+		c.openedAt = cb.now()
+		AWSCircuitOpen.With(prometheus.Labels{"service": service}).Set(1)
+		glog.Errorf("%d consecutive %s failures, pausing mutating calls for %s.", c.failures, service, cb.cooldown)
+	}
+}
+
+func (cb *CircuitBreaker) circuit(service string) *circuit {
+	c, ok := cb.circuits[service]
+	if !ok {
+		c = &circuit{}
+		cb.circuits[service] = c
+	}
+	return c
+}
+
+// isMutating reports whether an AWS operation changes state, as opposed to reading it.
+func isMutating(operation string) bool {
+	for _, prefix := range []string{"Describe", "List", "Get"} {
+		if strings.HasPrefix(operation, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// isServiceFailure reports whether a request failed because of the service rather than because of
+// the request itself. Throttling, server errors and unreachable endpoints count, invalid requests
+// and missing permissions don't.
+func isServiceFailure(r *request.Request) bool {
+	if r.Error == nil {
+		return false
+	}
+	if r.IsErrorThrottle() || r.IsErrorRetryable() {
+		return true
+	}
+	return r.HTTPResponse != nil && r.HTTPResponse.StatusCode >= 500
+}
@@ -0,0 +1,76 @@
+package awsutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	cb := NewCircuitBreaker(2, time.Minute)
+	cb.now = func() time.Time { return now }
+
+	cb.record("elasticloadbalancing", true, true)
+	if !cb.allow("elasticloadbalancing", true) {
+		t.Fatalf("circuit opened before reaching the threshold")
+	}
+	cb.record("elasticloadbalancing", false, true)
+	if cb.allow("elasticloadbalancing", true) {
+		t.Fatalf("mutating call allowed after %d consecutive failures", cb.threshold)
+	}
+	if !cb.allow("elasticloadbalancing", false) {
+		t.Errorf("read call paused while the circuit is open")
+	}
+	if !cb.allow("ec2", true) {
+		t.Errorf("circuit of an unrelated service opened")
+	}
+	if open := cb.OpenCircuits(); len(open) != 1 || open[0] != "elasticloadbalancing" {
+		t.Errorf("OpenCircuits returned %v, expected [elasticloadbalancing]", open)
+	}
+
+	// Once the cooldown elapsed, a single probe is let through. Its failure keeps the circuit open.
+	now = now.Add(time.Minute)
+	if !cb.allow("elasticloadbalancing", true) {
+		t.Fatalf("probe not allowed after the cooldown")
+	}
+	if cb.allow("elasticloadbalancing", true) {
+		t.Errorf("second mutating call allowed while probing")
+	}
+	cb.record("elasticloadbalancing", true, true)
+	if cb.allow("elasticloadbalancing", true) {
+		t.Fatalf("mutating call allowed after a failed probe")
+	}
+
+	// A successful probe closes the circuit.
+	now = now.Add(time.Minute)
+	if !cb.allow("elasticloadbalancing", true) {
+		t.Fatalf("probe not allowed after the cooldown")
+	}
+	cb.record("elasticloadbalancing", true, false)
+	if !cb.allow("elasticloadbalancing", true) {
+		t.Errorf("mutating call paused after a successful probe")
+	}
+	if open := cb.OpenCircuits(); len(open) != 0 {
+		t.Errorf("OpenCircuits returned %v, expected none", open)
+	}
+}
+
+func TestIsMutating(t *testing.T) {
+	var tests = []struct {
+		operation string
+		mutating  bool
+	}{
+		{"DescribeLoadBalancers", false},
+		{"ListResourceRecordSets", false},
+		{"GetChange", false},
+		{"CreateRule", true},
+		{"ChangeResourceRecordSets", true},
+		{"RegisterTargets", true},
+	}
+
+	for _, tt := range tests {
+		if mutating := isMutating(tt.operation); mutating != tt.mutating {
+			t.Errorf("isMutating(%s) returned %t, expected %t", tt.operation, mutating, tt.mutating)
+		}
+	}
+}
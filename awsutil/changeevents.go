@@ -0,0 +1,68 @@
+package awsutil
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// ChangeEvent is an AWS API call recorded by CloudTrail, as EventBridge delivers it.
+type ChangeEvent struct {
+	// EventName is the API operation, e.g. ModifyListener
+	EventName string
+	// UserAgent is the User-Agent of the call
+	UserAgent string
+	// Resources are the ARNs and security group IDs found in the parameters of the call, e.g. the
+	// ARN of the listener ModifyListener modified
+	Resources []string
+}
+
+// changeEventEnvelope is the part of an EventBridge event of the "AWS API Call via CloudTrail" type
+// a ChangeEvent is parsed from.
+type changeEventEnvelope struct {
+	Detail struct {
+		EventName         string      `json:"eventName"`
+		UserAgent         string      `json:"userAgent"`
+		ErrorCode         string      `json:"errorCode"`
+		RequestParameters interface{} `json:"requestParameters"`
+	} `json:"detail"`
+}
+
+// ParseChangeEvent parses the EventBridge event body. Calls that failed, which changed nothing, are
+// returned as nil.
+func ParseChangeEvent(body string) (*ChangeEvent, error) {
+	var envelope changeEventEnvelope
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil {
+		return nil, err
+	}
+	if envelope.Detail.ErrorCode != "" {
+		return nil, nil
+	}
+	resources := make(map[string]bool)
+	collectResources(envelope.Detail.RequestParameters, resources)
+
+	event := &ChangeEvent{EventName: envelope.Detail.EventName, UserAgent: envelope.Detail.UserAgent}
+	for id := range resources {
+		event.Resources = append(event.Resources, id)
+	}
+	sort.Strings(event.Resources)
+	return event, nil
+}
+
+// collectResources adds the ARNs and security group IDs found in the values of v to resources.
+func collectResources(v interface{}, resources map[string]bool) {
+	switch v := v.(type) {
+	case string:
+		if strings.HasPrefix(v, "arn:") || strings.HasPrefix(v, "sg-") {
+			resources[v] = true
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectResources(item, resources)
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			collectResources(item, resources)
+		}
+	}
+}
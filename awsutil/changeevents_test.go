@@ -0,0 +1,105 @@
+package awsutil
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestParseChangeEvent(t *testing.T) {
+	listenerArn := "arn:aws:elasticloadbalancing:us-east-1:123456789012:listener/app/web/50dc6c495c0c9188/f2f7dc8efc522ab2"
+	targetGroupArn := "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/web/73e2d6bc24d8a067"
+	var tests = []struct {
+		body     string
+		expected *ChangeEvent
+		pass     bool
+	}{
+		{
+			fmt.Sprintf(`{"detail-type": "AWS API Call via CloudTrail", "detail": {"eventName": "ModifyListener",
+  "userAgent": "console.amazonaws.com", "requestParameters": {"listenerArn": "%s", "port": 8443,
+  "defaultActions": [{"type": "forward", "targetGroupArn": "%s"}]}}}`, listenerArn, targetGroupArn),
+			&ChangeEvent{"ModifyListener", "console.amazonaws.com", []string{listenerArn, targetGroupArn}},
+			true,
+		},
+		{
+			`{"detail": {"eventName": "RevokeSecurityGroupIngress", "requestParameters": {"groupId": "sg-0123456789abcdef0",
+  "ipPermissions": {"items": [{"ipProtocol": "tcp", "fromPort": 443}]}}}}`,
+			&ChangeEvent{EventName: "RevokeSecurityGroupIngress", Resources: []string{"sg-0123456789abcdef0"}},
+			true,
+		},
+		{
+			fmt.Sprintf(`{"detail": {"eventName": "DeleteListener", "errorCode": "AccessDenied",
+  "requestParameters": {"listenerArn": "%s"}}}`, listenerArn),
+			nil,
+			true,
+		},
+		{`{"detail": `, nil, false},
+	}
+
+	for _, tt := range tests {
+		event, err := ParseChangeEvent(tt.body)
+		if err != nil && tt.pass {
+			t.Errorf("ParseChangeEvent(%s): returned error %v", tt.body, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("ParseChangeEvent(%s): expected an error", tt.body)
+		}
+		if !reflect.DeepEqual(event, tt.expected) {
+			t.Errorf("ParseChangeEvent(%s): expected %+v, actual %+v", tt.body, tt.expected, event)
+		}
+	}
+}
+
+func TestReceiveMessages(t *testing.T) {
+	var actions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		actions = append(actions, r.PostForm.Get("Action"))
+		if r.PostForm.Get("QueueUrl") != "https://sqs.us-east-1.amazonaws.com/123456789012/alb-changes" {
+			t.Errorf("%s: unexpected queue %s", r.PostForm.Get("Action"), r.PostForm.Get("QueueUrl"))
+		}
+		if r.PostForm.Get("Action") == "DeleteMessage" {
+			if r.PostForm.Get("ReceiptHandle") != "handle-1" {
+				t.Errorf("DeleteMessage(): unexpected receipt handle %s", r.PostForm.Get("ReceiptHandle"))
+			}
+			fmt.Fprint(w, `<DeleteMessageResponse><ResponseMetadata><RequestId>2</RequestId></ResponseMetadata></DeleteMessageResponse>`)
+			return
+		}
+		if r.PostForm.Get("WaitTimeSeconds") != "20" {
+			t.Errorf("ReceiveMessages(): unexpected wait %s", r.PostForm.Get("WaitTimeSeconds"))
+		}
+		fmt.Fprint(w, `<ReceiveMessageResponse><ReceiveMessageResult>
+  <Message><MessageId>1</MessageId><ReceiptHandle>handle-1</ReceiptHandle><Body>{"detail": {}}</Body></Message>
+  <Message><MessageId>2</MessageId><ReceiptHandle>handle-2</ReceiptHandle><Body>{}</Body></Message>
+</ReceiveMessageResult><ResponseMetadata><RequestId>1</RequestId></ResponseMetadata></ReceiveMessageResponse>`)
+	}))
+	defer server.Close()
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+	svc := NewSQS(sess)
+	queueURL := "https://sqs.us-east-1.amazonaws.com/123456789012/alb-changes"
+	messages, err := svc.ReceiveMessages(queueURL)
+	if err != nil {
+		t.Fatalf("ReceiveMessages(): returned error %v", err)
+	}
+	if len(messages) != 2 || aws.StringValue(messages[0].Body) != `{"detail": {}}` ||
+		aws.StringValue(messages[1].ReceiptHandle) != "handle-2" {
+		t.Fatalf("ReceiveMessages(): unexpected messages %v", messages)
+	}
+	if err := svc.DeleteMessage(queueURL, messages[0].ReceiptHandle); err != nil {
+		t.Fatalf("DeleteMessage(): returned error %v", err)
+	}
+	if !reflect.DeepEqual(actions, []string{"ReceiveMessage", "DeleteMessage"}) {
+		t.Errorf("unexpected actions %v", actions)
+	}
+}
@@ -0,0 +1,198 @@
+package awsutil
+
+import (
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/query"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CloudWatch is a client of the CloudWatch operations the controller calls, which the vendored
+// aws-sdk-go has no client for.
+type CloudWatch struct {
+	*client.Client
+}
+
+// MetricAlarm is a CloudWatch alarm, on a metric or on an expression of the metrics of Metrics.
+type MetricAlarm struct {
+	_ struct{} `type:"structure"`
+
+	ActionsEnabled     *bool              `type:"boolean"`
+	AlarmActions       []*string          `type:"list"`
+	AlarmDescription   *string            `type:"string"`
+	AlarmName          *string            `type:"string"`
+	ComparisonOperator *string            `type:"string"`
+	Dimensions         []*MetricDimension `type:"list"`
+	EvaluationPeriods  *int64             `type:"integer"`
+	MetricName         *string            `type:"string"`
+	Metrics            []*MetricDataQuery `type:"list"`
+	Namespace          *string            `type:"string"`
+	OKActions          []*string          `type:"list"`
+	Period             *int64             `type:"integer"`
+	Statistic          *string            `type:"string"`
+	Threshold          *float64           `type:"double"`
+	TreatMissingData   *string            `type:"string"`
+}
+
+// MetricDimension is a dimension of a CloudWatch metric, e.g. the target group it's reported for.
+type MetricDimension struct {
+	_ struct{} `type:"structure"`
+
+	Name  *string `type:"string"`
+	Value *string `type:"string"`
+}
+
+// MetricDataQuery is a metric, or an expression of other queries, an alarm evaluates. The queries
+// referenced by Expression are identified by their Id.
+type MetricDataQuery struct {
+	_ struct{} `type:"structure"`
+
+	Expression *string     `type:"string"`
+	Id         *string     `type:"string"`
+	Label      *string     `type:"string"`
+	MetricStat *MetricStat `type:"structure"`
+	ReturnData *bool       `type:"boolean"`
+}
+
+// MetricStat is the statistic of a metric over a period.
+type MetricStat struct {
+	_ struct{} `type:"structure"`
+
+	Metric *Metric `type:"structure"`
+	Period *int64  `type:"integer"`
+	Stat   *string `type:"string"`
+}
+
+// Metric is a CloudWatch metric.
+type Metric struct {
+	_ struct{} `type:"structure"`
+
+	Dimensions []*MetricDimension `type:"list"`
+	MetricName *string            `type:"string"`
+	Namespace  *string            `type:"string"`
+}
+
+// MetricDatum is a value of a custom metric, published with PutMetricData.
+type MetricDatum struct {
+	_ struct{} `type:"structure"`
+
+	Dimensions []*MetricDimension `type:"list"`
+	MetricName *string            `type:"string"`
+	Unit       *string            `type:"string"`
+	Value      *float64           `type:"double"`
+}
+
+// NewCloudWatch returns a CloudWatch client based off of the provided AWS session.
+func NewCloudWatch(awsSession *session.Session) *CloudWatch {
+	c := awsSession.ClientConfig("monitoring")
+	cw := &CloudWatch{
+		Client: client.New(
+			*c.Config,
+			metadata.ClientInfo{
+				ServiceName:   "monitoring",
+				SigningName:   c.SigningName,
+				SigningRegion: c.SigningRegion,
+				Endpoint:      c.Endpoint,
+				APIVersion:    "2010-08-01",
+			},
+			c.Handlers,
+		),
+	}
+	cw.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	cw.Handlers.Build.PushBackNamed(query.BuildHandler)
+	cw.Handlers.Unmarshal.PushBackNamed(query.UnmarshalHandler)
+	cw.Handlers.UnmarshalMeta.PushBackNamed(query.UnmarshalMetaHandler)
+	cw.Handlers.UnmarshalError.PushBackNamed(query.UnmarshalErrorHandler)
+	return cw
+}
+
+// NewCloudWatchWithClient returns a CloudWatch making its calls through c, e.g. one served by an
+// in-memory fake from the awsutil/fake package.
+func NewCloudWatchWithClient(c *client.Client) *CloudWatch {
+	return &CloudWatch{Client: c}
+}
+
+type putMetricAlarmOutput struct {
+	_ struct{} `type:"structure"`
+}
+
+type describeAlarmsInput struct {
+	_ struct{} `type:"structure"`
+
+	AlarmNames []*string `type:"list"`
+}
+
+type describeAlarmsOutput struct {
+	_ struct{} `type:"structure"`
+
+	MetricAlarms []*MetricAlarm `type:"list"`
+}
+
+type deleteAlarmsInput struct {
+	_ struct{} `type:"structure"`
+
+	AlarmNames []*string `type:"list"`
+}
+
+type deleteAlarmsOutput struct {
+	_ struct{} `type:"structure"`
+}
+
+type putMetricDataInput struct {
+	_ struct{} `type:"structure"`
+
+	MetricData []*MetricDatum `type:"list"`
+	Namespace  *string        `type:"string"`
+}
+
+type putMetricDataOutput struct {
+	_ struct{} `type:"structure"`
+}
+
+// PutMetricAlarm creates the alarm, or replaces the alarm of the same name.
+func (cw *CloudWatch) PutMetricAlarm(alarm *MetricAlarm) error {
+	op := &request.Operation{Name: "PutMetricAlarm", HTTPMethod: "POST", HTTPPath: "/"}
+	if err := cw.NewRequest(op, alarm, &putMetricAlarmOutput{}).Send(); err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "CloudWatch", "request": "PutMetricAlarm", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+	return nil
+}
+
+// DescribeAlarms looks up the alarms names, up to 100 of them. Alarms that don't exist are left
+// out.
+func (cw *CloudWatch) DescribeAlarms(names []*string) ([]*MetricAlarm, error) {
+	out := &describeAlarmsOutput{}
+	op := &request.Operation{Name: "DescribeAlarms", HTTPMethod: "POST", HTTPPath: "/"}
+	if err := cw.NewRequest(op, &describeAlarmsInput{AlarmNames: names}, out).Send(); err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "CloudWatch", "request": "DescribeAlarms", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+	return out.MetricAlarms, nil
+}
+
+// DeleteAlarms deletes the alarms names, up to 100 of them. Nothing is deleted when any of them
+// doesn't exist.
+func (cw *CloudWatch) DeleteAlarms(names []*string) error {
+	op := &request.Operation{Name: "DeleteAlarms", HTTPMethod: "POST", HTTPPath: "/"}
+	if err := cw.NewRequest(op, &deleteAlarmsInput{AlarmNames: names}, &deleteAlarmsOutput{}).Send(); err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "CloudWatch", "request": "DeleteAlarms", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+	return nil
+}
+
+// PutMetricData publishes the values of data, up to 20 of them, under namespace. The values are
+// timestamped with the time CloudWatch receives them.
+func (cw *CloudWatch) PutMetricData(namespace string, data []*MetricDatum) error {
+	op := &request.Operation{Name: "PutMetricData", HTTPMethod: "POST", HTTPPath: "/"}
+	in := &putMetricDataInput{Namespace: &namespace, MetricData: data}
+	if err := cw.NewRequest(op, in, &putMetricDataOutput{}).Send(); err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "CloudWatch", "request": "PutMetricData", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package awsutil
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestCloudWatch(t *testing.T) {
+	var forms []map[string][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		forms = append(forms, r.PostForm)
+		switch r.PostForm.Get("Action") {
+		case "DescribeAlarms":
+			fmt.Fprint(w, `<DescribeAlarmsResponse><DescribeAlarmsResult><MetricAlarms>
+<member><AlarmName>app-5xx-rate</AlarmName><AlarmDescription>hash</AlarmDescription><Threshold>5.0</Threshold></member>
+</MetricAlarms></DescribeAlarmsResult></DescribeAlarmsResponse>`)
+		default:
+			fmt.Fprintf(w, `<%sResponse><ResponseMetadata><RequestId>1</RequestId></ResponseMetadata></%sResponse>`,
+				r.PostForm.Get("Action"), r.PostForm.Get("Action"))
+		}
+	}))
+	defer server.Close()
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+	cw := NewCloudWatch(sess)
+	alarm := &MetricAlarm{
+		AlarmName:          aws.String("app-5xx-rate"),
+		AlarmActions:       aws.StringSlice([]string{"arn:aws:sns:us-east-1:123456789012:alerts"}),
+		ComparisonOperator: aws.String("GreaterThanThreshold"),
+		EvaluationPeriods:  aws.Int64(3),
+		Threshold:          aws.Float64(5),
+		Metrics: []*MetricDataQuery{
+			{Id: aws.String("rate"), Expression: aws.String("100 * errors / requests"), ReturnData: aws.Bool(true)},
+			{Id: aws.String("errors"), ReturnData: aws.Bool(false), MetricStat: &MetricStat{
+				Metric: &Metric{
+					Namespace:  aws.String("AWS/ApplicationELB"),
+					MetricName: aws.String("HTTPCode_Target_5XX_Count"),
+					Dimensions: []*MetricDimension{{Name: aws.String("TargetGroup"), Value: aws.String("targetgroup/app/1")}},
+				},
+				Period: aws.Int64(60),
+				Stat:   aws.String("Sum"),
+			}},
+		},
+	}
+	if err := cw.PutMetricAlarm(alarm); err != nil {
+		t.Fatalf("PutMetricAlarm(): returned error %v", err)
+	}
+	alarms, err := cw.DescribeAlarms(aws.StringSlice([]string{"app-5xx-rate", "app-response-time"}))
+	if err != nil {
+		t.Fatalf("DescribeAlarms(): returned error %v", err)
+	}
+	if len(alarms) != 1 || *alarms[0].AlarmName != "app-5xx-rate" || *alarms[0].AlarmDescription != "hash" || *alarms[0].Threshold != 5 {
+		t.Errorf("DescribeAlarms(): expected app-5xx-rate, actual %v", alarms)
+	}
+	if err := cw.DeleteAlarms(aws.StringSlice([]string{"app-5xx-rate"})); err != nil {
+		t.Fatalf("DeleteAlarms(): returned error %v", err)
+	}
+	datum := &MetricDatum{
+		MetricName: aws.String("ManagedIngresses"),
+		Dimensions: []*MetricDimension{{Name: aws.String("ClusterName"), Value: aws.String("prod")}},
+		Unit:       aws.String("Count"),
+		Value:      aws.Float64(3),
+	}
+	if err := cw.PutMetricData("ALBIngressController", []*MetricDatum{datum}); err != nil {
+		t.Fatalf("PutMetricData(): returned error %v", err)
+	}
+
+	expected := []map[string][]string{
+		{
+			"Action":                      {"PutMetricAlarm"},
+			"Version":                     {"2010-08-01"},
+			"AlarmName":                   {"app-5xx-rate"},
+			"AlarmActions.member.1":       {"arn:aws:sns:us-east-1:123456789012:alerts"},
+			"ComparisonOperator":          {"GreaterThanThreshold"},
+			"EvaluationPeriods":           {"3"},
+			"Threshold":                   {"5"},
+			"Metrics.member.1.Id":         {"rate"},
+			"Metrics.member.1.Expression": {"100 * errors / requests"},
+			"Metrics.member.1.ReturnData": {"true"},
+			"Metrics.member.2.Id":         {"errors"},
+			"Metrics.member.2.ReturnData": {"false"},
+			"Metrics.member.2.MetricStat.Metric.Namespace":                 {"AWS/ApplicationELB"},
+			"Metrics.member.2.MetricStat.Metric.MetricName":                {"HTTPCode_Target_5XX_Count"},
+			"Metrics.member.2.MetricStat.Metric.Dimensions.member.1.Name":  {"TargetGroup"},
+			"Metrics.member.2.MetricStat.Metric.Dimensions.member.1.Value": {"targetgroup/app/1"},
+			"Metrics.member.2.MetricStat.Period":                           {"60"},
+			"Metrics.member.2.MetricStat.Stat":                             {"Sum"},
+		},
+		{
+			"Action":              {"DescribeAlarms"},
+			"Version":             {"2010-08-01"},
+			"AlarmNames.member.1": {"app-5xx-rate"},
+			"AlarmNames.member.2": {"app-response-time"},
+		},
+		{
+			"Action":              {"DeleteAlarms"},
+			"Version":             {"2010-08-01"},
+			"AlarmNames.member.1": {"app-5xx-rate"},
+		},
+		{
+			"Action":                         {"PutMetricData"},
+			"Version":                        {"2010-08-01"},
+			"Namespace":                      {"ALBIngressController"},
+			"MetricData.member.1.MetricName": {"ManagedIngresses"},
+			"MetricData.member.1.Dimensions.member.1.Name":  {"ClusterName"},
+			"MetricData.member.1.Dimensions.member.1.Value": {"prod"},
+			"MetricData.member.1.Unit":                      {"Count"},
+			"MetricData.member.1.Value":                     {"3"},
+		},
+	}
+	if !reflect.DeepEqual(forms, expected) {
+		t.Errorf("CloudWatch: expected requests %v, actual %v", expected, forms)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
@@ -11,6 +13,13 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+const (
+	// Amount of time between each deletion attempt (or reattempt) for a security group
+	deleteSecurityGroupReattemptSleep int = 10
+	// Maximum attempts should be made to delete a security group
+	deleteSecurityGroupReattemptMax int = 10
+)
+
 // EC2 is our extension to AWS's ec2.EC2
 type EC2 struct {
 	Svc   ec2iface.EC2API
@@ -19,8 +28,13 @@ type EC2 struct {
 
 // NewEC2 returns an awsutil EC2 service
 func NewEC2(awsSession *session.Session) *EC2 {
+	return NewEC2WithClient(ec2.New(awsSession))
+}
+
+// NewEC2WithClient returns an awsutil EC2 service making its calls through svc.
+func NewEC2WithClient(svc ec2iface.EC2API) *EC2 {
 	elbClient := EC2{
-		ec2.New(awsSession),
+		svc,
 		APICache{ccache.New(ccache.Configure()), },
 	}
 	return &elbClient
@@ -31,20 +45,32 @@ func (e *EC2) DescribeSubnets(in ec2.DescribeSubnetsInput) ([]*ec2.Subnet, error
 	o, err := e.Svc.DescribeSubnets(&in)
 	if err != nil {
 		AWSErrorCount.With(
-			prometheus.Labels{"service": "EC2", "request": "DescribeSubnets"}).Add(float64(1))
+			prometheus.Labels{"service": "EC2", "request": "DescribeSubnets", "code": ErrorCode(err)}).Add(float64(1))
 		return nil, err
 	}
 
 	return o.Subnets, nil
 }
 
+// DescribeAccountAttributes describes the attributes of the AWS account. It's a cheap call the
+// controller's heartbeat makes to check it can still talk to AWS.
+func (e *EC2) DescribeAccountAttributes() error {
+	_, err := e.Svc.DescribeAccountAttributes(&ec2.DescribeAccountAttributesInput{})
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "EC2", "request": "DescribeAccountAttributes", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+	return nil
+}
+
 // DescribeSecurityGroups looks up Security Groups based on input and returns a list of Security
 // Groups.
 func (e *EC2) DescribeSecurityGroups(in ec2.DescribeSecurityGroupsInput) ([]*ec2.SecurityGroup, error) {
 	o, err := e.Svc.DescribeSecurityGroups(&in)
 	if err != nil {
 		AWSErrorCount.With(
-			prometheus.Labels{"service": "EC2", "request": "DescribeSecurityGroups"}).Add(float64(1))
+			prometheus.Labels{"service": "EC2", "request": "DescribeSecurityGroups", "code": ErrorCode(err)}).Add(float64(1))
 		return nil, err
 	}
 
@@ -67,7 +93,7 @@ func (e *EC2) GetVPCID(subnets []*string) (*string, error) {
 			SubnetIds: subnets,
 		})
 		if err != nil {
-			AWSErrorCount.With(prometheus.Labels{"service": "EC2", "request": "DescribeSubnets"}).Add(float64(1))
+			AWSErrorCount.With(prometheus.Labels{"service": "EC2", "request": "DescribeSubnets", "code": ErrorCode(err)}).Add(float64(1))
 			return nil, err
 		}
 
@@ -86,3 +112,154 @@ func (e *EC2) GetVPCID(subnets []*string) (*string, error) {
 
 	return vpc, nil
 }
+
+// CreateSecurityGroup makes a new security group in AWS. The ID of the new security group is returned
+// on success and an error is returned on failure.
+func (e *EC2) CreateSecurityGroup(in ec2.CreateSecurityGroupInput) (*string, error) {
+	o, err := e.Svc.CreateSecurityGroup(&in)
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "EC2", "request": "CreateSecurityGroup", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+
+	return o.GroupId, nil
+}
+
+// AuthorizeSecurityGroupIngress adds inbound rules to a security group. When descriptions is not
+// nil, descriptions[i] is set on every source of in.IpPermissions[i].
+func (e *EC2) AuthorizeSecurityGroupIngress(in ec2.AuthorizeSecurityGroupIngressInput, descriptions []string) error {
+	_, err := e.Svc.AuthorizeSecurityGroupIngressWithContext(aws.BackgroundContext(), &in,
+		withRuleDescriptions(in.IpPermissions, descriptions))
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "EC2", "request": "AuthorizeSecurityGroupIngress", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+
+	return nil
+}
+
+// withRuleDescriptions describes the sources of each permission with the description at the same
+// position. The vendored aws-sdk-go predates the IpRange, PrefixListId and UserIdGroupPair Description
+// fields.
+func withRuleDescriptions(permissions []*ec2.IpPermission, descriptions []string) request.Option {
+	params := make(map[string]string)
+	for i, p := range permissions {
+		if i >= len(descriptions) || descriptions[i] == "" {
+			continue
+		}
+		for j := range p.IpRanges {
+			params[fmt.Sprintf("IpPermissions.%d.IpRanges.%d.Description", i+1, j+1)] = descriptions[i]
+		}
+		for j := range p.Ipv6Ranges {
+			params[fmt.Sprintf("IpPermissions.%d.Ipv6Ranges.%d.Description", i+1, j+1)] = descriptions[i]
+		}
+		for j := range p.PrefixListIds {
+			params[fmt.Sprintf("IpPermissions.%d.PrefixListIds.%d.Description", i+1, j+1)] = descriptions[i]
+		}
+		for j := range p.UserIdGroupPairs {
+			params[fmt.Sprintf("IpPermissions.%d.Groups.%d.Description", i+1, j+1)] = descriptions[i]
+		}
+	}
+	return withQueryParams(params)
+}
+
+// RevokeSecurityGroupIngress removes inbound rules from a security group.
+func (e *EC2) RevokeSecurityGroupIngress(in ec2.RevokeSecurityGroupIngressInput) error {
+	if _, err := e.Svc.RevokeSecurityGroupIngress(&in); err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "EC2", "request": "RevokeSecurityGroupIngress", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+
+	return nil
+}
+
+// AuthorizeSecurityGroupEgress adds outbound rules to a security group. Descriptions are set like
+// in AuthorizeSecurityGroupIngress.
+func (e *EC2) AuthorizeSecurityGroupEgress(in ec2.AuthorizeSecurityGroupEgressInput, descriptions []string) error {
+	_, err := e.Svc.AuthorizeSecurityGroupEgressWithContext(aws.BackgroundContext(), &in,
+		withRuleDescriptions(in.IpPermissions, descriptions))
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "EC2", "request": "AuthorizeSecurityGroupEgress", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+
+	return nil
+}
+
+// RevokeSecurityGroupEgress removes outbound rules from a security group.
+func (e *EC2) RevokeSecurityGroupEgress(in ec2.RevokeSecurityGroupEgressInput) error {
+	if _, err := e.Svc.RevokeSecurityGroupEgress(&in); err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "EC2", "request": "RevokeSecurityGroupEgress", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+
+	return nil
+}
+
+// DeleteSecurityGroup removes a security group from AWS. A security group can't be deleted while the
+// network interfaces of a just deleted ALB still reference it, so deletion is reattempted for as long
+// as AWS reports a dependency violation.
+func (e *EC2) DeleteSecurityGroup(groupID *string) error {
+	in := &ec2.DeleteSecurityGroupInput{GroupId: groupID}
+	for i := 0; i < deleteSecurityGroupReattemptMax; i++ {
+		_, err := e.Svc.DeleteSecurityGroup(in)
+		switch {
+		case err == nil:
+			return nil
+		case ErrorCode(err) == "DependencyViolation":
+			AWSErrorCount.With(
+				prometheus.Labels{"service": "EC2", "request": "DeleteSecurityGroup", "code": ErrorCode(err)}).Add(float64(1))
+			time.Sleep(time.Duration(deleteSecurityGroupReattemptSleep) * time.Second)
+		default:
+			AWSErrorCount.With(
+				prometheus.Labels{"service": "EC2", "request": "DeleteSecurityGroup", "code": ErrorCode(err)}).Add(float64(1))
+			return err
+		}
+	}
+
+	return fmt.Errorf("Security group %s is still in use after %d deletion attempts", *groupID, deleteSecurityGroupReattemptMax)
+}
+
+// CreateTags adds or overwrites tags of EC2 resources.
+func (e *EC2) CreateTags(in ec2.CreateTagsInput) error {
+	if _, err := e.Svc.CreateTags(&in); err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "EC2", "request": "CreateTags", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+
+	return nil
+}
+
+// GetPrefixListID retrieves the ID of the AWS-managed prefix list with the given name, e.g.
+// com.amazonaws.global.cloudfront.origin-facing.
+func (e *EC2) GetPrefixListID(name string) (*string, error) {
+	key := fmt.Sprintf("%s-prefixlist", name)
+	if item := e.cache.Get(key); item != nil {
+		AWSCache.With(prometheus.Labels{"cache": "prefixlist", "action": "hit"}).Add(float64(1))
+		return item.Value().(*string), nil
+	}
+	AWSCache.With(prometheus.Labels{"cache": "prefixlist", "action": "miss"}).Add(float64(1))
+
+	o, err := e.Svc.DescribePrefixLists(&ec2.DescribePrefixListsInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("prefix-list-name"),
+			Values: []*string{aws.String(name)},
+		}},
+	})
+	if err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "EC2", "request": "DescribePrefixLists", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+	if len(o.PrefixLists) == 0 {
+		return nil, fmt.Errorf("Unable to find the %s prefix list", name)
+	}
+
+	e.cache.Set(key, o.PrefixLists[0].PrefixListId, time.Minute*60)
+	return o.PrefixLists[0].PrefixListId, nil
+}
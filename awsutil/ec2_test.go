@@ -2,11 +2,15 @@ package awsutil
 
 import (
 	//"fmt"
-	//"testing"
+	"io/ioutil"
+	"net/url"
+	"testing"
 
-	//"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws"
 	//"github.com/aws/aws-sdk-go/aws/awsutil"
-	//"github.com/aws/aws-sdk-go/service/ec2"	//"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	//"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/ec2"
 )
 
@@ -20,6 +24,58 @@ var (
 	mockedEC2responses *mockedEC2ResponsesT
 )
 
+func TestWithRuleDescriptions(t *testing.T) {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+
+	permissions := []*ec2.IpPermission{
+		{
+			IpProtocol: aws.String("tcp"), FromPort: aws.Int64(80), ToPort: aws.Int64(80),
+			IpRanges: []*ec2.IpRange{{CidrIp: aws.String("10.0.0.0/8")}},
+		},
+		{
+			IpProtocol: aws.String("tcp"), FromPort: aws.Int64(443), ToPort: aws.Int64(443),
+			PrefixListIds: []*ec2.PrefixListId{{PrefixListId: aws.String("pl-1234")}},
+		},
+		{
+			IpProtocol: aws.String("tcp"), FromPort: aws.Int64(0), ToPort: aws.Int64(65535),
+			UserIdGroupPairs: []*ec2.UserIdGroupPair{{GroupId: aws.String("sg-5678")}},
+		},
+	}
+	req, _ := ec2.New(sess).AuthorizeSecurityGroupIngressRequest(&ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId:       aws.String("sg-1234"),
+		IpPermissions: permissions,
+	})
+	req.ApplyOptions(withRuleDescriptions(permissions, []string{"port 80", "port 443", "all ports"}))
+	if err := req.Build(); err != nil {
+		t.Fatalf("Build(): returned error %v", err)
+	}
+
+	b, _ := ioutil.ReadAll(req.GetBody())
+	body, err := url.ParseQuery(string(b))
+	if err != nil {
+		t.Fatalf("ParseQuery(%s): returned error %v", b, err)
+	}
+	var tests = []struct {
+		key      string
+		expected string
+	}{
+		{"IpPermissions.1.IpRanges.1.CidrIp", "10.0.0.0/8"},
+		{"IpPermissions.1.IpRanges.1.Description", "port 80"},
+		{"IpPermissions.2.PrefixListIds.1.PrefixListId", "pl-1234"},
+		{"IpPermissions.2.PrefixListIds.1.Description", "port 443"},
+		{"IpPermissions.3.Groups.1.GroupId", "sg-5678"},
+		{"IpPermissions.3.Groups.1.Description", "all ports"},
+	}
+	for _, tt := range tests {
+		if body.Get(tt.key) != tt.expected {
+			t.Errorf("withRuleDescriptions(): expected %s=%s, actual %s", tt.key, tt.expected, body.Get(tt.key))
+		}
+	}
+}
+
 /*func TestGetVPCID(t *testing.T) {
 	setup()
 
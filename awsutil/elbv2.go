@@ -1,17 +1,21 @@
 package awsutil
 
 import (
+	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
 	"github.com/coreos/alb-ingress-controller/controller/util"
+	"github.com/karlseguin/ccache"
 	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/flowcontrol"
 )
 
 const (
@@ -21,26 +25,116 @@ const (
 	deleteTargetGroupReattemptMax int = 10
 )
 
+const (
+	// TargetTypeInstance registers EC2 instances, reached through a service's NodePort, as targets.
+	TargetTypeInstance = "instance"
+	// TargetTypeIP registers pod IPs, taken from a service's endpoints, as targets.
+	TargetTypeIP = "ip"
+	// IPAddressTypeIPv4 registers IPv4 addresses in target groups of target type ip, the AWS default.
+	IPAddressTypeIPv4 = "ipv4"
+	// IPAddressTypeIPv6 registers IPv6 addresses in target groups of target type ip.
+	IPAddressTypeIPv6 = "ipv6"
+	// AvailabilityZoneAll is the availability zone of IP targets outside of the target group's VPC.
+	AvailabilityZoneAll = "all"
+	// ProtocolVersionHTTP1 sends requests to targets using HTTP/1.1, the AWS default.
+	ProtocolVersionHTTP1 = "HTTP1"
+	// ProtocolVersionHTTP2 sends requests to targets using HTTP/2.
+	ProtocolVersionHTTP2 = "HTTP2"
+	// AttributeLoadBalancingAlgorithm is the target group attribute choosing how requests are
+	// spread over the targets.
+	AttributeLoadBalancingAlgorithm = "load_balancing.algorithm.type"
+	// AlgorithmRoundRobin sends requests to the targets in turn, the AWS default.
+	AlgorithmRoundRobin = "round_robin"
+	// AlgorithmLeastOutstandingRequests sends requests to the target with the fewest requests in
+	// progress.
+	AlgorithmLeastOutstandingRequests = "least_outstanding_requests"
+	// AttributeCrossZoneEnabled is the target group attribute choosing whether the ALB nodes of an
+	// availability zone send requests to the targets of the other zones.
+	AttributeCrossZoneEnabled = "load_balancing.cross_zone.enabled"
+	// CrossZoneLoadBalancerConfiguration leaves cross-zone load balancing of a target group to the
+	// ALB, which always has it enabled, the AWS default.
+	CrossZoneLoadBalancerConfiguration = "use_load_balancer_configuration"
+)
+
+// Names of the ELBV2 account limits, as returned by DescribeAccountLimits
+const (
+	LimitLoadBalancers               = "application-load-balancers"
+	LimitTargetGroups                = "target-groups"
+	LimitCertificatesPerLoadBalancer = "certificates-per-application-load-balancer"
+	LimitListenersPerLoadBalancer    = "listeners-per-application-load-balancer"
+	LimitRulesPerLoadBalancer        = "rules-per-application-load-balancer"
+	LimitTargetGroupsPerLoadBalancer = "target-groups-per-application-load-balancer"
+	LimitTargetsPerLoadBalancer      = "targets-per-application-load-balancer"
+)
+
+const (
+	// Default number of targets sent in a single RegisterTargets or DeregisterTargets call
+	defaultTargetBatchSize int = 100
+	// Default number of RegisterTargets and DeregisterTargets calls allowed per second
+	defaultTargetBatchRate float32 = 5
+)
+
 // ELBV2 is our extension to AWS's elbv2.ELBV2
 type ELBV2 struct {
 	Svc elbv2iface.ELBV2API
+	// targetBatchSize caps the number of targets sent in each (de)registration call
+	targetBatchSize int
+	// targetLimiter paces (de)registration calls so large scaling events don't burst the API
+	targetLimiter flowcontrol.RateLimiter
+	cache         APICache
+	// Tagging, when set, finds the ALBs and target groups of the cluster through the Resource
+	// Groups Tagging API rather than by listing every one of the region
+	Tagging *Tagging
 }
 
 // NewELBV2 returns an ELBV2 based off of the provided AWS session
 func NewELBV2(awsSession *session.Session) *ELBV2 {
+	return NewELBV2WithClient(elbv2.New(awsSession))
+}
+
+// NewELBV2WithClient returns an ELBV2 making its calls through svc, e.g. an in-memory fake from the
+// awsutil/fake package.
+func NewELBV2WithClient(svc elbv2iface.ELBV2API) *ELBV2 {
 	elbClient := ELBV2{
-		elbv2.New(awsSession),
+		svc,
+		defaultTargetBatchSize,
+		flowcontrol.NewTokenBucketRateLimiter(defaultTargetBatchRate, 1),
+		APICache{ccache.New(ccache.Configure())},
+		nil,
 	}
 	return &elbClient
 }
 
+// ListenerCertificatesAPI is implemented by ELBV2 clients handling the listener certificate
+// operations, which post-date the vendored aws-sdk-go, themselves. Fakes implement it, as the
+// operations otherwise need the request machinery of a real elbv2.ELBV2 client.
+type ListenerCertificatesAPI interface {
+	AddListenerCertificates(listenerArn *string, certificates []*elbv2.Certificate) error
+	RemoveListenerCertificates(listenerArn *string, certificates []*elbv2.Certificate) error
+	// DescribeListenerCertificates returns the SNI certificates of the listener, without its
+	// default certificate.
+	DescribeListenerCertificates(listenerArn *string) ([]*elbv2.Certificate, error)
+}
+
+// SetTargetBatching configures how target (de)registrations are split up. Each call carries at
+// most batchSize targets and no more than rate calls are made per second. Values less than or
+// equal to zero leave the respective default in place.
+func (e *ELBV2) SetTargetBatching(batchSize int, rate float32) {
+	if batchSize > 0 {
+		e.targetBatchSize = batchSize
+	}
+	if rate > 0 {
+		e.targetLimiter = flowcontrol.NewTokenBucketRateLimiter(rate, 1)
+	}
+}
+
 // Create makes a new ELBV2 (ALB) in AWS. It returns the elbv2.LoadBalancer created on success or an
 // error on failure.
 func (e *ELBV2) Create(in elbv2.CreateLoadBalancerInput) (*elbv2.LoadBalancer, error) {
 	o, err := e.Svc.CreateLoadBalancer(&in)
 	if err != nil {
 		AWSErrorCount.With(
-			prometheus.Labels{"service": "ELBV2", "request": "CreateLoadBalancer"}).Add(float64(1))
+			prometheus.Labels{"service": "ELBV2", "request": "CreateLoadBalancer", "code": ErrorCode(err)}).Add(float64(1))
 		return nil, err
 	}
 
@@ -53,33 +147,253 @@ func (e *ELBV2) AddListener(in elbv2.CreateListenerInput) (*elbv2.Listener, erro
 	o, err := e.Svc.CreateListener(&in)
 	if err != nil {
 		AWSErrorCount.With(
-			prometheus.Labels{"service": "ELBV2", "request": "CreateListener"}).Add(float64(1))
+			prometheus.Labels{"service": "ELBV2", "request": "CreateListener", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+
+	return o.Listeners[0], nil
+}
+
+// ModifyListener changes the port, protocol, certificates or default action of a Listener. It
+// returns the modified elbv2.Listener on success or an error returned on failure.
+func (e *ELBV2) ModifyListener(in elbv2.ModifyListenerInput) (*elbv2.Listener, error) {
+	o, err := e.Svc.ModifyListener(&in)
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "ELBV2", "request": "ModifyListener", "code": ErrorCode(err)}).Add(float64(1))
 		return nil, err
 	}
 
 	return o.Listeners[0], nil
 }
 
-// AddRule creates a new Rule and associates it with the Listener. It returns the elbv2.Rule created
-// on success or an error returned on failure.
+// ModifyWeightedListener updates a Listener like ModifyListener, its default action forwarding
+// requests to several target groups by weight. See ModifyWeightedRule.
+func (e *ELBV2) ModifyWeightedListener(in elbv2.ModifyListenerInput, weights []TargetGroupWeight) (*elbv2.Listener, error) {
+	in.DefaultActions = []*elbv2.Action{{Type: aws.String("forward"), TargetGroupArn: aws.String(weights[0].TargetGroupArn)}}
+
+	o, err := e.Svc.ModifyListenerWithContext(aws.BackgroundContext(), &in, withWeightedForward("DefaultActions.member.1", weights))
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "ELBV2", "request": "ModifyListener", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+
+	return o.Listeners[0], nil
+}
+
+// listenerCertificatesInput is the input of the AddListenerCertificates,
+// RemoveListenerCertificates and DescribeListenerCertificates operations, which post-date the
+// vendored aws-sdk-go.
+type listenerCertificatesInput struct {
+	_ struct{} `type:"structure"`
+
+	Certificates []*elbv2.Certificate `type:"list"`
+	ListenerArn  *string              `type:"string" required:"true"`
+	Marker       *string              `type:"string"`
+}
+
+// listenerCertificatesOutput is the output of the listener certificate operations.
+type listenerCertificatesOutput struct {
+	_ struct{} `type:"structure"`
+
+	Certificates []*listenerCertificate `type:"list"`
+	NextMarker   *string                `type:"string"`
+}
+
+// listenerCertificate is a certificate of a listener, as described by DescribeListenerCertificates.
+type listenerCertificate struct {
+	_ struct{} `type:"structure"`
+
+	CertificateArn *string `type:"string"`
+	IsDefault      *bool   `type:"boolean"`
+}
+
+// listenerCertificates sends one of the listener certificate operations.
+func (e *ELBV2) listenerCertificates(operation string, in *listenerCertificatesInput) (*listenerCertificatesOutput, error) {
+	svc, ok := e.Svc.(*elbv2.ELBV2)
+	if !ok {
+		return nil, fmt.Errorf("%s isn't supported by %T", operation, e.Svc)
+	}
+	out := &listenerCertificatesOutput{}
+	req := svc.NewRequest(&request.Operation{Name: operation, HTTPMethod: "POST", HTTPPath: "/"}, in, out)
+	if err := req.Send(); err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "ELBV2", "request": operation, "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+	return out, nil
+}
+
+// AddListenerCertificates adds certificates to the certificate list of a HTTPS Listener, which
+// serves them through SNI to clients asking for one of their hostnames.
+func (e *ELBV2) AddListenerCertificates(listenerArn *string, certificates []*elbv2.Certificate) error {
+	if api, ok := e.Svc.(ListenerCertificatesAPI); ok {
+		return api.AddListenerCertificates(listenerArn, certificates)
+	}
+	_, err := e.listenerCertificates("AddListenerCertificates", &listenerCertificatesInput{
+		ListenerArn:  listenerArn,
+		Certificates: certificates,
+	})
+	return err
+}
+
+// RemoveListenerCertificates removes certificates from the certificate list of a HTTPS Listener.
+func (e *ELBV2) RemoveListenerCertificates(listenerArn *string, certificates []*elbv2.Certificate) error {
+	if api, ok := e.Svc.(ListenerCertificatesAPI); ok {
+		return api.RemoveListenerCertificates(listenerArn, certificates)
+	}
+	_, err := e.listenerCertificates("RemoveListenerCertificates", &listenerCertificatesInput{
+		ListenerArn:  listenerArn,
+		Certificates: certificates,
+	})
+	return err
+}
+
+// DescribeListenerCertificates returns the certificates a HTTPS Listener serves through SNI,
+// leaving out its default certificate.
+func (e *ELBV2) DescribeListenerCertificates(listenerArn *string) ([]*elbv2.Certificate, error) {
+	if api, ok := e.Svc.(ListenerCertificatesAPI); ok {
+		return api.DescribeListenerCertificates(listenerArn)
+	}
+	var certificates []*elbv2.Certificate
+	in := &listenerCertificatesInput{ListenerArn: listenerArn}
+	for {
+		o, err := e.listenerCertificates("DescribeListenerCertificates", in)
+		if err != nil {
+			return nil, err
+		}
+		for _, certificate := range o.Certificates {
+			if !aws.BoolValue(certificate.IsDefault) {
+				certificates = append(certificates, &elbv2.Certificate{CertificateArn: certificate.CertificateArn})
+			}
+		}
+		if o.NextMarker == nil {
+			return certificates, nil
+		}
+		in.Marker = o.NextMarker
+	}
+}
+
+// AddRule creates a new Rule and associates it with the Listener. Its host-header conditions can
+// list several hostnames. It returns the elbv2.Rule created on success or an error returned on
+// failure.
 func (e *ELBV2) AddRule(in elbv2.CreateRuleInput) (*elbv2.Rule, error) {
-	o, err := e.Svc.CreateRule(&in)
+	o, err := e.Svc.CreateRuleWithContext(aws.BackgroundContext(), &in, withHostHeaderConfig())
 	if err != nil {
 		AWSErrorCount.With(
-			prometheus.Labels{"service": "ELBV2", "request": "CreateRule"}).Add(float64(1))
+			prometheus.Labels{"service": "ELBV2", "request": "CreateRule", "code": ErrorCode(err)}).Add(float64(1))
 		return nil, err
 	}
 
 	return o.Rules[0], nil
 }
 
-// AddTargetGroup creates a new TargetGroup in AWS. It returns the created elbv2.TargetGroup on
-// success and an error on failure.
-func (e *ELBV2) AddTargetGroup(in elbv2.CreateTargetGroupInput) (*elbv2.TargetGroup, error) {
-	o, err := e.Svc.CreateTargetGroup(&in)
+// AddRedirectRule creates a new Rule redirecting requests, as described by redirect, and associates
+// it with the Listener. The keys of redirect are RedirectConfig fields, e.g. Host or StatusCode. Any
+// actions of in are replaced. It returns the elbv2.Rule created on success or an error on failure.
+func (e *ELBV2) AddRedirectRule(in elbv2.CreateRuleInput, redirect map[string]string) (*elbv2.Rule, error) {
+	// The vendored aws-sdk-go requires actions to forward to a target group. The placeholder passes
+	// its validation and is replaced by the redirect once the request is built.
+	in.Actions = []*elbv2.Action{{Type: aws.String("forward"), TargetGroupArn: aws.String("redirect")}}
+
+	o, err := e.Svc.CreateRuleWithContext(aws.BackgroundContext(), &in, withRedirectAction(redirect), withHostHeaderConfig())
 	if err != nil {
 		AWSErrorCount.With(
-			prometheus.Labels{"service": "ELBV2", "request": "CreateTargetGroup"}).Add(float64(1))
+			prometheus.Labels{"service": "ELBV2", "request": "CreateRule", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+
+	return o.Rules[0], nil
+}
+
+// ModifyRule updates the conditions and actions of a Rule. It returns the modified elbv2.Rule on
+// success or an error on failure.
+func (e *ELBV2) ModifyRule(in elbv2.ModifyRuleInput) (*elbv2.Rule, error) {
+	o, err := e.Svc.ModifyRuleWithContext(aws.BackgroundContext(), &in, withHostHeaderConfig())
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "ELBV2", "request": "ModifyRule", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+
+	return o.Rules[0], nil
+}
+
+// ModifyRedirectRule updates a Rule to redirect requests, as described by redirect. See
+// AddRedirectRule. It returns the modified elbv2.Rule on success or an error on failure.
+func (e *ELBV2) ModifyRedirectRule(in elbv2.ModifyRuleInput, redirect map[string]string) (*elbv2.Rule, error) {
+	in.Actions = []*elbv2.Action{{Type: aws.String("forward"), TargetGroupArn: aws.String("redirect")}}
+
+	o, err := e.Svc.ModifyRuleWithContext(aws.BackgroundContext(), &in, withRedirectAction(redirect), withHostHeaderConfig())
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "ELBV2", "request": "ModifyRule", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+
+	return o.Rules[0], nil
+}
+
+// TargetGroupWeight is the share of the requests of a weighted forward action a target group gets.
+type TargetGroupWeight struct {
+	TargetGroupArn string
+	Weight         int64
+}
+
+// ModifyWeightedRule updates a Rule to forward requests to several target groups, each getting a
+// share of them relative to its weight. Any actions of in are replaced. It returns the modified
+// elbv2.Rule on success or an error on failure.
+func (e *ELBV2) ModifyWeightedRule(in elbv2.ModifyRuleInput, weights []TargetGroupWeight) (*elbv2.Rule, error) {
+	// The placeholder passes validation and is replaced by the weighted target groups once the
+	// request is built.
+	in.Actions = []*elbv2.Action{{Type: aws.String("forward"), TargetGroupArn: aws.String(weights[0].TargetGroupArn)}}
+
+	o, err := e.Svc.ModifyRuleWithContext(aws.BackgroundContext(), &in, withWeightedForward("Actions.member.1", weights), withHostHeaderConfig())
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "ELBV2", "request": "ModifyRule", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+
+	return o.Rules[0], nil
+}
+
+// SetRulePriorities sets the priorities of rules of a listener in a single, atomic call. Rules can
+// swap priorities with each other, but not take the priority of a rule left out.
+func (e *ELBV2) SetRulePriorities(in elbv2.SetRulePrioritiesInput) error {
+	if _, err := e.Svc.SetRulePriorities(&in); err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "ELBV2", "request": "SetRulePriorities", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+	return nil
+}
+
+// AddTargetGroup creates a new TargetGroup in AWS. The targetType is either instance (the AWS
+// default) or ip, the protocolVersion either HTTP1 (the AWS default) or HTTP2, and the
+// ipAddressType of ip targets either ipv4 (the AWS default) or ipv6. It returns the created
+// elbv2.TargetGroup on success and an error on failure.
+func (e *ELBV2) AddTargetGroup(in elbv2.CreateTargetGroupInput, targetType, protocolVersion, ipAddressType *string) (*elbv2.TargetGroup, error) {
+	params := make(map[string]string)
+	if targetType != nil && *targetType != TargetTypeInstance {
+		params["TargetType"] = *targetType
+	}
+	if ipAddressType != nil && *ipAddressType != IPAddressTypeIPv4 {
+		params["IpAddressType"] = *ipAddressType
+	}
+	if protocolVersion != nil && *protocolVersion != ProtocolVersionHTTP1 {
+		params["ProtocolVersion"] = *protocolVersion
+	}
+	var opts []request.Option
+	if len(params) > 0 {
+		opts = append(opts, withQueryParams(params))
+	}
+
+	o, err := e.Svc.CreateTargetGroupWithContext(aws.BackgroundContext(), &in, opts...)
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "ELBV2", "request": "CreateTargetGroup", "code": ErrorCode(err)}).Add(float64(1))
 		return nil, err
 	}
 
@@ -92,7 +406,7 @@ func (e *ELBV2) Delete(in elbv2.DeleteLoadBalancerInput) error {
 	_, err := e.Svc.DeleteLoadBalancer(&in)
 	if err != nil {
 		AWSErrorCount.With(
-			prometheus.Labels{"service": "ELBV2", "request": "DeleteLoadBalancer"}).Add(float64(1))
+			prometheus.Labels{"service": "ELBV2", "request": "DeleteLoadBalancer", "code": ErrorCode(err)}).Add(float64(1))
 		return err
 	}
 	return nil
@@ -103,10 +417,9 @@ func (e *ELBV2) Delete(in elbv2.DeleteLoadBalancerInput) error {
 // listener has already been removed. If removal fails for another reason, an error is returned.
 func (e *ELBV2) RemoveListener(in elbv2.DeleteListenerInput) error {
 	if _, err := e.Svc.DeleteListener(&in); err != nil {
-		awsErr := err.(awserr.Error)
-		if awsErr.Code() != elbv2.ErrCodeListenerNotFoundException {
+		if ErrorCode(err) != elbv2.ErrCodeListenerNotFoundException {
 			AWSErrorCount.With(
-				prometheus.Labels{"service": "ELBV2", "request": "DeleteListener"}).Add(float64(1))
+				prometheus.Labels{"service": "ELBV2", "request": "DeleteListener", "code": ErrorCode(err)}).Add(float64(1))
 			return err
 		}
 	}
@@ -120,7 +433,7 @@ func (e *ELBV2) RemoveRule(in elbv2.DeleteRuleInput) error {
 	_, err := e.Svc.DeleteRule(&in)
 	if err != nil {
 		AWSErrorCount.With(
-			prometheus.Labels{"service": "ELBV2", "request": "DeleteRule"}).Add(float64(1))
+			prometheus.Labels{"service": "ELBV2", "request": "DeleteRule", "code": ErrorCode(err)}).Add(float64(1))
 		return err
 	}
 	return nil
@@ -134,14 +447,16 @@ func (e *ELBV2) RemoveTargetGroup(in elbv2.DeleteTargetGroupInput) error {
 	for i := 0; i < deleteTargetGroupReattemptMax; i++ {
 		_, err := e.Svc.DeleteTargetGroup(&in)
 		switch {
-		case err != nil && err.(awserr.Error).Code() == elbv2.ErrCodeResourceInUseException:
+		case err == nil:
+			return nil
+		case ErrorCode(err) == elbv2.ErrCodeResourceInUseException:
 			AWSErrorCount.With(
-				prometheus.Labels{"service": "ELBV2", "request": "DeleteTargetGroup"}).Add(float64(1))
+				prometheus.Labels{"service": "ELBV2", "request": "DeleteTargetGroup", "code": ErrorCode(err)}).Add(float64(1))
 			time.Sleep(time.Duration(deleteTargetGroupReattemptSleep) * time.Second)
 			continue
 		case err != nil:
 			AWSErrorCount.With(
-				prometheus.Labels{"service": "ELBV2", "request": "DeleteRule"}).Add(float64(1))
+				prometheus.Labels{"service": "ELBV2", "request": "DeleteRule", "code": ErrorCode(err)}).Add(float64(1))
 			return err
 		}
 	}
@@ -155,7 +470,7 @@ func (e *ELBV2) ModifyTargetGroup(in elbv2.ModifyTargetGroupInput) (*elbv2.Targe
 	o, err := e.Svc.ModifyTargetGroup(&in)
 	if err != nil {
 		AWSErrorCount.With(
-			prometheus.Labels{"service": "ELBV2", "request": "ModifyTargetGroup"}).Add(float64(1))
+			prometheus.Labels{"service": "ELBV2", "request": "ModifyTargetGroup", "code": ErrorCode(err)}).Add(float64(1))
 		return nil, err
 	}
 
@@ -168,7 +483,19 @@ func (e *ELBV2) SetSecurityGroups(in elbv2.SetSecurityGroupsInput) error {
 	_, err := e.Svc.SetSecurityGroups(&in)
 	if err != nil {
 		AWSErrorCount.With(
-			prometheus.Labels{"service": "ELBV2", "request": "SetSecurityGroups"}).Add(float64(1))
+			prometheus.Labels{"service": "ELBV2", "request": "SetSecurityGroups", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+	return nil
+}
+
+// SetIpAddressType switches an ELBV2 (ALB) between ipv4 and dualstack. It returns an error when
+// unsuccessful.
+func (e *ELBV2) SetIpAddressType(in elbv2.SetIpAddressTypeInput) error {
+	_, err := e.Svc.SetIpAddressType(&in)
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "ELBV2", "request": "SetIpAddressType", "code": ErrorCode(err)}).Add(float64(1))
 		return err
 	}
 	return nil
@@ -179,26 +506,172 @@ func (e *ELBV2) SetSubnets(in elbv2.SetSubnetsInput) error {
 	_, err := e.Svc.SetSubnets(&in)
 	if err != nil {
 		AWSErrorCount.With(
-			prometheus.Labels{"service": "ELBV2", "request": "SetSubnets"}).Add(float64(1))
+			prometheus.Labels{"service": "ELBV2", "request": "SetSubnets", "code": ErrorCode(err)}).Add(float64(1))
 		return err
 	}
 	return nil
 }
 
-// RegisterTargets adds EC2 instances to a Target Group. It returns an error when unsuccessful.
-func (e *ELBV2) RegisterTargets(in elbv2.RegisterTargetsInput) error {
-	_, err := e.Svc.RegisterTargets(&in)
+// ModifyLoadBalancerAttributes sets attributes of an ELBV2 (ALB). Attributes not passed keep their
+// value. It returns an error when unsuccessful.
+func (e *ELBV2) ModifyLoadBalancerAttributes(in elbv2.ModifyLoadBalancerAttributesInput) error {
+	_, err := e.Svc.ModifyLoadBalancerAttributes(&in)
 	if err != nil {
 		AWSErrorCount.With(
-			prometheus.Labels{"service": "ELBV2", "request": "RegisterTargets"}).Add(float64(1))
+			prometheus.Labels{"service": "ELBV2", "request": "ModifyLoadBalancerAttributes", "code": ErrorCode(err)}).Add(float64(1))
 		return err
 	}
 	return nil
 }
 
-// DescribeLoadBalancers looks up all ELBV2 (ALB) instances in AWS that are part of the cluster.
+// DescribeLoadBalancerAttributes looks up the attributes of an ELBV2 (ALB) by its ARN.
+func (e *ELBV2) DescribeLoadBalancerAttributes(arn *string) ([]*elbv2.LoadBalancerAttribute, error) {
+	o, err := e.Svc.DescribeLoadBalancerAttributes(&elbv2.DescribeLoadBalancerAttributesInput{
+		LoadBalancerArn: arn,
+	})
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "ELBV2", "request": "DescribeLoadBalancerAttributes", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+	return o.Attributes, nil
+}
+
+// ModifyTargetGroupAttributes sets attributes of a Target Group. Attributes not passed keep their
+// value. It returns an error when unsuccessful.
+func (e *ELBV2) ModifyTargetGroupAttributes(in elbv2.ModifyTargetGroupAttributesInput) error {
+	_, err := e.Svc.ModifyTargetGroupAttributes(&in)
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "ELBV2", "request": "ModifyTargetGroupAttributes", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+	return nil
+}
+
+// DescribeTargetGroupAttributes looks up the attributes of a Target Group by its ARN.
+func (e *ELBV2) DescribeTargetGroupAttributes(arn *string) ([]*elbv2.TargetGroupAttribute, error) {
+	o, err := e.Svc.DescribeTargetGroupAttributes(&elbv2.DescribeTargetGroupAttributesInput{
+		TargetGroupArn: arn,
+	})
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "ELBV2", "request": "DescribeTargetGroupAttributes", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+	return o.Attributes, nil
+}
+
+// RegisterTargets adds targets to a Target Group. The targets are sent in rate limited batches, see
+// SetTargetBatching. It returns an error when unsuccessful.
+func (e *ELBV2) RegisterTargets(in elbv2.RegisterTargetsInput) error {
+	return e.batchTargets("register", in.Targets, func(targets []*elbv2.TargetDescription) error {
+		_, err := e.Svc.RegisterTargets(&elbv2.RegisterTargetsInput{
+			TargetGroupArn: in.TargetGroupArn,
+			Targets:        targets,
+		})
+		if err != nil {
+			AWSErrorCount.With(
+				prometheus.Labels{"service": "ELBV2", "request": "RegisterTargets", "code": ErrorCode(err)}).Add(float64(1))
+		}
+		return err
+	})
+}
+
+// DeregisterTargets removes targets from a Target Group. The targets are sent in rate limited
+// batches, see SetTargetBatching. It returns an error when unsuccessful.
+func (e *ELBV2) DeregisterTargets(in elbv2.DeregisterTargetsInput) error {
+	return e.batchTargets("deregister", in.Targets, func(targets []*elbv2.TargetDescription) error {
+		_, err := e.Svc.DeregisterTargets(&elbv2.DeregisterTargetsInput{
+			TargetGroupArn: in.TargetGroupArn,
+			Targets:        targets,
+		})
+		if err != nil {
+			AWSErrorCount.With(
+				prometheus.Labels{"service": "ELBV2", "request": "DeregisterTargets", "code": ErrorCode(err)}).Add(float64(1))
+		}
+		return err
+	})
+}
+
+// RegisterExternalTargets adds IP targets that live outside of the Target Group's VPC, such as
+// peered VPC or on-premises addresses, to a Target Group. It otherwise behaves like RegisterTargets.
+func (e *ELBV2) RegisterExternalTargets(in elbv2.RegisterTargetsInput) error {
+	return e.batchTargets("register", in.Targets, func(targets []*elbv2.TargetDescription) error {
+		_, err := e.Svc.RegisterTargetsWithContext(aws.BackgroundContext(), &elbv2.RegisterTargetsInput{
+			TargetGroupArn: in.TargetGroupArn,
+			Targets:        targets,
+		}, withExternalTargets(len(targets)))
+		if err != nil {
+			AWSErrorCount.With(
+				prometheus.Labels{"service": "ELBV2", "request": "RegisterTargets", "code": ErrorCode(err)}).Add(float64(1))
+		}
+		return err
+	})
+}
+
+// DeregisterExternalTargets removes IP targets that live outside of the Target Group's VPC from a
+// Target Group. It otherwise behaves like DeregisterTargets.
+func (e *ELBV2) DeregisterExternalTargets(in elbv2.DeregisterTargetsInput) error {
+	return e.batchTargets("deregister", in.Targets, func(targets []*elbv2.TargetDescription) error {
+		_, err := e.Svc.DeregisterTargetsWithContext(aws.BackgroundContext(), &elbv2.DeregisterTargetsInput{
+			TargetGroupArn: in.TargetGroupArn,
+			Targets:        targets,
+		}, withExternalTargets(len(targets)))
+		if err != nil {
+			AWSErrorCount.With(
+				prometheus.Labels{"service": "ELBV2", "request": "DeregisterTargets", "code": ErrorCode(err)}).Add(float64(1))
+		}
+		return err
+	})
+}
+
+// withExternalTargets sets the AvailabilityZone of the first n targets of a request to
+// AvailabilityZoneAll. The vendored aws-sdk-go predates the TargetDescription AvailabilityZone field.
+func withExternalTargets(n int) request.Option {
+	params := make(map[string]string)
+	for i := 1; i <= n; i++ {
+		params[fmt.Sprintf("Targets.member.%d.AvailabilityZone", i)] = AvailabilityZoneAll
+	}
+	return withQueryParams(params)
+}
+
+// batchTargets splits targets into batches of at most targetBatchSize and calls fn on each,
+// waiting on targetLimiter between calls. Progress is tracked by the PendingTargets gauge and the
+// TargetChanges counter.
+func (e *ELBV2) batchTargets(action string, targets []*elbv2.TargetDescription, fn func([]*elbv2.TargetDescription) error) error {
+	pending := PendingTargets.With(prometheus.Labels{"action": action})
+	pending.Add(float64(len(targets)))
+
+	for len(targets) > 0 {
+		n := e.targetBatchSize
+		if n > len(targets) {
+			n = len(targets)
+		}
+
+		e.targetLimiter.Accept()
+		if err := fn(targets[:n]); err != nil {
+			pending.Sub(float64(len(targets)))
+			return err
+		}
+
+		TargetChanges.With(prometheus.Labels{"action": action}).Add(float64(n))
+		pending.Sub(float64(n))
+		targets = targets[n:]
+	}
+
+	return nil
+}
+
+// DescribeLoadBalancers looks up all ELBV2 (ALB) instances in AWS that are part of the cluster. ALBs
+// are part of the cluster when their name was generated for it, or when they carry its ClusterName
+// tag, which covers ALBs named through the load-balancer-name annotation.
 func (e *ELBV2) DescribeLoadBalancers(clusterName *string) ([]*elbv2.LoadBalancer, error) {
+	if e.Tagging != nil {
+		return e.describeTaggedLoadBalancers(clusterName)
+	}
 	var loadbalancers []*elbv2.LoadBalancer
+	var otherLoadBalancers []*elbv2.LoadBalancer
 	describeLoadBalancersInput := &elbv2.DescribeLoadBalancersInput{
 		PageSize: aws.Int64(100),
 	}
@@ -216,18 +689,268 @@ func (e *ELBV2) DescribeLoadBalancers(clusterName *string) ([]*elbv2.LoadBalance
 				if s := strings.Split(*loadBalancer.LoadBalancerName, "-"); len(s) == 2 {
 					if s[0] == *clusterName {
 						loadbalancers = append(loadbalancers, loadBalancer)
+						continue
 					}
 				}
 			}
+			otherLoadBalancers = append(otherLoadBalancers, loadBalancer)
 		}
 
 		if describeLoadBalancersOutput.NextMarker == nil {
 			break
 		}
 	}
+
+	var arns []*string
+	for _, loadBalancer := range otherLoadBalancers {
+		arns = append(arns, loadBalancer.LoadBalancerArn)
+	}
+	tags, err := e.DescribeTagsBatch(arns)
+	if err != nil {
+		return nil, err
+	}
+	for _, loadBalancer := range otherLoadBalancers {
+		lbTags := tags[*loadBalancer.LoadBalancerArn]
+		if name, ok := lbTags.Get(util.ClusterNameTag); ok && name == *clusterName {
+			loadbalancers = append(loadbalancers, loadBalancer)
+		}
+	}
 	return loadbalancers, nil
 }
 
+// describeTaggedLoadBalancers looks up the ALBs carrying the ClusterName tag of the cluster through
+// the Resource Groups Tagging API. ALBs without the tag aren't found, even if their name was
+// generated for the cluster.
+func (e *ELBV2) describeTaggedLoadBalancers(clusterName *string) ([]*elbv2.LoadBalancer, error) {
+	resources, err := e.Tagging.GetResources(ResourceTypeLoadBalancer,
+		util.Tags{{Key: aws.String(util.ClusterNameTag), Value: clusterName}})
+	if err != nil {
+		return nil, err
+	}
+	var arns []*string
+	for _, arn := range sortedArns(resources) {
+		// Classic ELBs are of the same resource type.
+		if strings.Contains(*arn, ":loadbalancer/app/") {
+			arns = append(arns, arn)
+		}
+	}
+
+	var loadBalancers []*elbv2.LoadBalancer
+	for len(arns) > 0 {
+		n := 20
+		if len(arns) < n {
+			n = len(arns)
+		}
+		batch, err := e.describeLoadBalancersByArn(arns[:n])
+		if err != nil {
+			return nil, err
+		}
+		loadBalancers = append(loadBalancers, batch...)
+		arns = arns[n:]
+	}
+	return loadBalancers, nil
+}
+
+// describeLoadBalancersByArn looks up the ALBs arns, at most 20. The Resource Groups Tagging API is
+// eventually consistent, so ALBs it returns may be deleted already: they're left out.
+func (e *ELBV2) describeLoadBalancersByArn(arns []*string) ([]*elbv2.LoadBalancer, error) {
+	o, err := e.Svc.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{LoadBalancerArns: arns})
+	switch {
+	case err == nil:
+		return o.LoadBalancers, nil
+	case ErrorCode(err) != elbv2.ErrCodeLoadBalancerNotFoundException:
+		AWSErrorCount.With(prometheus.Labels{"service": "ELBV2", "request": "DescribeLoadBalancers", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	case len(arns) == 1:
+		return nil, nil
+	}
+	// One of them is gone, describe them one by one.
+	var loadBalancers []*elbv2.LoadBalancer
+	for _, arn := range arns {
+		lbs, err := e.describeLoadBalancersByArn([]*string{arn})
+		if err != nil {
+			return nil, err
+		}
+		loadBalancers = append(loadBalancers, lbs...)
+	}
+	return loadBalancers, nil
+}
+
+// DescribeOwnedTargetGroups looks up the target groups that may carry the ownership tags owner.
+// Through the Resource Groups Tagging API only the ones carrying them are returned, otherwise every
+// target group of the region is, leaving the caller to check their tags.
+func (e *ELBV2) DescribeOwnedTargetGroups(owner util.Tags) ([]*elbv2.TargetGroup, error) {
+	if e.Tagging == nil {
+		return e.DescribeTargetGroups(nil)
+	}
+	resources, err := e.Tagging.GetResources(ResourceTypeTargetGroup, owner)
+	if err != nil {
+		return nil, err
+	}
+	arns := sortedArns(resources)
+
+	var targetGroups []*elbv2.TargetGroup
+	for len(arns) > 0 {
+		n := 20
+		if len(arns) < n {
+			n = len(arns)
+		}
+		batch, err := e.describeTargetGroupsByArn(arns[:n])
+		if err != nil {
+			return nil, err
+		}
+		targetGroups = append(targetGroups, batch...)
+		arns = arns[n:]
+	}
+	return targetGroups, nil
+}
+
+// describeTargetGroupsByArn looks up the target groups arns, at most 20, leaving out the ones that
+// were deleted already.
+func (e *ELBV2) describeTargetGroupsByArn(arns []*string) ([]*elbv2.TargetGroup, error) {
+	o, err := e.Svc.DescribeTargetGroups(&elbv2.DescribeTargetGroupsInput{TargetGroupArns: arns})
+	switch {
+	case err == nil:
+		return o.TargetGroups, nil
+	case ErrorCode(err) != elbv2.ErrCodeTargetGroupNotFoundException:
+		AWSErrorCount.With(prometheus.Labels{"service": "ELBV2", "request": "DescribeTargetGroups", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	case len(arns) == 1:
+		return nil, nil
+	}
+	// One of them is gone, describe them one by one.
+	var targetGroups []*elbv2.TargetGroup
+	for _, arn := range arns {
+		tgs, err := e.describeTargetGroupsByArn([]*string{arn})
+		if err != nil {
+			return nil, err
+		}
+		targetGroups = append(targetGroups, tgs...)
+	}
+	return targetGroups, nil
+}
+
+// DescribeSSLPolicy returns the security policy named name, which decides the TLS protocols and
+// ciphers HTTPS listeners negotiate. Policies don't change, so they're cached for an hour.
+func (e *ELBV2) DescribeSSLPolicy(name *string) (*elbv2.SslPolicy, error) {
+	key := "sslpolicy" + *name
+	if item := e.cache.Get(key); item != nil {
+		AWSCache.With(prometheus.Labels{"cache": "sslpolicy", "action": "hit"}).Add(float64(1))
+		return item.Value().(*elbv2.SslPolicy), nil
+	}
+	AWSCache.With(prometheus.Labels{"cache": "sslpolicy", "action": "miss"}).Add(float64(1))
+
+	o, err := e.Svc.DescribeSSLPolicies(&elbv2.DescribeSSLPoliciesInput{Names: []*string{name}})
+	if err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "ELBV2", "request": "DescribeSSLPolicies", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+	if len(o.SslPolicies) == 0 {
+		return nil, fmt.Errorf("SSL policy %s not found", *name)
+	}
+	e.cache.Set(key, o.SslPolicies[0], time.Hour)
+	return o.SslPolicies[0], nil
+}
+
+// DescribeAccountLimits returns the ELBV2 limits of the account, keyed by limit name. Limits change
+// rarely, so they're cached for an hour.
+func (e *ELBV2) DescribeAccountLimits() (map[string]int64, error) {
+	if item := e.cache.Get("limits"); item != nil {
+		AWSCache.With(prometheus.Labels{"cache": "limits", "action": "hit"}).Add(float64(1))
+		return item.Value().(map[string]int64), nil
+	}
+	AWSCache.With(prometheus.Labels{"cache": "limits", "action": "miss"}).Add(float64(1))
+
+	limits := make(map[string]int64)
+	in := &elbv2.DescribeAccountLimitsInput{}
+	for {
+		o, err := e.Svc.DescribeAccountLimits(in)
+		if err != nil {
+			AWSErrorCount.With(prometheus.Labels{"service": "ELBV2", "request": "DescribeAccountLimits", "code": ErrorCode(err)}).Add(float64(1))
+			return nil, err
+		}
+		for _, limit := range o.Limits {
+			max, err := strconv.ParseInt(aws.StringValue(limit.Max), 10, 64)
+			if err != nil {
+				continue
+			}
+			limits[aws.StringValue(limit.Name)] = max
+		}
+		if aws.StringValue(o.NextMarker) == "" {
+			break
+		}
+		in.Marker = o.NextMarker
+	}
+
+	e.cache.Set("limits", limits, time.Hour)
+	return limits, nil
+}
+
+// CountLoadBalancers returns the number of ELBV2 (ALB) instances in the region, whether they're part
+// of the cluster or not.
+func (e *ELBV2) CountLoadBalancers() (int64, error) {
+	var count int64
+	in := &elbv2.DescribeLoadBalancersInput{PageSize: aws.Int64(400)}
+	for {
+		o, err := e.Svc.DescribeLoadBalancers(in)
+		if err != nil {
+			AWSErrorCount.With(prometheus.Labels{"service": "ELBV2", "request": "DescribeLoadBalancers", "code": ErrorCode(err)}).Add(float64(1))
+			return 0, err
+		}
+		for _, loadBalancer := range o.LoadBalancers {
+			if aws.StringValue(loadBalancer.Type) == elbv2.LoadBalancerTypeEnumApplication {
+				count++
+			}
+		}
+		if o.NextMarker == nil {
+			break
+		}
+		in.Marker = o.NextMarker
+	}
+	return count, nil
+}
+
+// DescribeLoadBalancer looks up an ELBV2 (ALB) by its ARN. When the ALB doesn't exist, nil is
+// returned without an error.
+func (e *ELBV2) DescribeLoadBalancer(arn *string) (*elbv2.LoadBalancer, error) {
+	o, err := e.Svc.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{
+		LoadBalancerArns: []*string{arn},
+	})
+	if err != nil {
+		if ErrorCode(err) == elbv2.ErrCodeLoadBalancerNotFoundException {
+			return nil, nil
+		}
+		AWSErrorCount.With(prometheus.Labels{"service": "ELBV2", "request": "DescribeLoadBalancers", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+	if len(o.LoadBalancers) == 0 {
+		return nil, nil
+	}
+	return o.LoadBalancers[0], nil
+}
+
+// DescribeLoadBalancerByName looks up an ELBV2 (ALB) by its name. When the ALB doesn't exist, nil
+// is returned without an error. A nil name is an error.
+func (e *ELBV2) DescribeLoadBalancerByName(name *string) (*elbv2.LoadBalancer, error) {
+	if name == nil {
+		return nil, fmt.Errorf("DescribeLoadBalancerByName requires a name")
+	}
+	o, err := e.Svc.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{
+		Names: []*string{name},
+	})
+	if err != nil {
+		if ErrorCode(err) == elbv2.ErrCodeLoadBalancerNotFoundException {
+			return nil, nil
+		}
+		AWSErrorCount.With(prometheus.Labels{"service": "ELBV2", "request": "DescribeLoadBalancers", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+	if len(o.LoadBalancers) == 0 {
+		return nil, nil
+	}
+	return o.LoadBalancers[0], nil
+}
+
 // DescribeTargetGroups looks up all ELBV2 (ALB) target groups in AWS that are part of the cluster.
 func (e *ELBV2) DescribeTargetGroups(loadBalancerArn *string) ([]*elbv2.TargetGroup, error) {
 	var targetGroups []*elbv2.TargetGroup
@@ -266,7 +989,7 @@ func (e *ELBV2) DescribeListeners(loadBalancerArn *string) ([]*elbv2.Listener, e
 	for {
 		describeListenersOutput, err := e.Svc.DescribeListeners(describeListenersInput)
 		if err != nil {
-			AWSErrorCount.With(prometheus.Labels{"service": "ELBV2", "request": "DescribeListeners"}).Add(float64(1))
+			AWSErrorCount.With(prometheus.Labels{"service": "ELBV2", "request": "DescribeListeners", "code": ErrorCode(err)}).Add(float64(1))
 			return nil, err
 		}
 
@@ -297,6 +1020,28 @@ func (e *ELBV2) DescribeTags(arn *string) (util.Tags, error) {
 	return tags, err
 }
 
+// DescribeTagsBatch looks up the tags of the ALBs and target groups arns, keyed by ARN. It makes one
+// call per 20 ARNs, the most DescribeTags accepts, rather than one per resource.
+func (e *ELBV2) DescribeTagsBatch(arns []*string) (map[string]util.Tags, error) {
+	tags := make(map[string]util.Tags)
+	for len(arns) > 0 {
+		n := 20
+		if len(arns) < n {
+			n = len(arns)
+		}
+		o, err := e.Svc.DescribeTags(&elbv2.DescribeTagsInput{ResourceArns: arns[:n]})
+		if err != nil {
+			AWSErrorCount.With(prometheus.Labels{"service": "ELBV2", "request": "DescribeTags", "code": ErrorCode(err)}).Add(float64(1))
+			return nil, err
+		}
+		arns = arns[n:]
+		for _, description := range o.TagDescriptions {
+			tags[*description.ResourceArn] = util.Tags(description.Tags)
+		}
+	}
+	return tags, nil
+}
+
 // DescribeTargetGroup looks up a target group by an ARN.
 func (e *ELBV2) DescribeTargetGroup(arn *string) (*elbv2.TargetGroup, error) {
 	targetGroups, err := e.Svc.DescribeTargetGroups(&elbv2.DescribeTargetGroupsInput{
@@ -308,22 +1053,88 @@ func (e *ELBV2) DescribeTargetGroup(arn *string) (*elbv2.TargetGroup, error) {
 	return targetGroups.TargetGroups[0], nil
 }
 
-// DescribeTargetGroupTargets looks up target group targets by an ARN.
-func (e *ELBV2) DescribeTargetGroupTargets(arn *string) (util.AWSStringSlice, error) {
-	var targets util.AWSStringSlice
+// DescribeTargetGroupHealth looks up the health of every target registered to a target group ARN.
+func (e *ELBV2) DescribeTargetGroupHealth(arn *string) ([]*elbv2.TargetHealthDescription, error) {
 	targetGroupHealth, err := e.Svc.DescribeTargetHealth(&elbv2.DescribeTargetHealthInput{
 		TargetGroupArn: arn,
 	})
 	if err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "ELBV2", "request": "DescribeTargetHealth", "code": ErrorCode(err)}).Add(float64(1))
 		return nil, err
 	}
-	for _, targetHealthDescription := range targetGroupHealth.TargetHealthDescriptions {
+	return targetGroupHealth.TargetHealthDescriptions, nil
+}
+
+// DescribeTargetGroupTargets looks up target group targets by an ARN. Targets that are draining are
+// on their way out of the target group and are not returned.
+func (e *ELBV2) DescribeTargetGroupTargets(arn *string) (util.AWSStringSlice, error) {
+	var targets util.AWSStringSlice
+	targetHealthDescriptions, err := e.DescribeTargetGroupHealth(arn)
+	if err != nil {
+		return nil, err
+	}
+	for _, targetHealthDescription := range targetHealthDescriptions {
+		if targetHealthDescription.TargetHealth != nil &&
+			aws.StringValue(targetHealthDescription.TargetHealth.State) == elbv2.TargetHealthStateEnumDraining {
+			continue
+		}
 		targets = append(targets, targetHealthDescription.Target.Id)
 	}
 	sort.Sort(targets)
 	return targets, err
 }
 
+// externalTargetHealthOutput is the output of DescribeTargetHealth, with the AvailabilityZone of the
+// targets the vendored aws-sdk-go predates.
+type externalTargetHealthOutput struct {
+	_ struct{} `type:"structure"`
+
+	TargetHealthDescriptions []*externalTargetHealth `type:"list"`
+}
+
+type externalTargetHealth struct {
+	_ struct{} `type:"structure"`
+
+	Target       *externalTarget     `type:"structure"`
+	TargetHealth *elbv2.TargetHealth `type:"structure"`
+}
+
+type externalTarget struct {
+	_ struct{} `type:"structure"`
+
+	AvailabilityZone *string `type:"string"`
+	Id               *string `type:"string"`
+}
+
+// DescribeTargetGroupExternalTargets looks up the IP targets outside of the target group's VPC, as
+// registered by RegisterExternalTargets, by an ARN. Like DescribeTargetGroupTargets it leaves out
+// targets that are draining. Clients other than a elbv2.ELBV2, e.g. fakes, don't tell external
+// targets apart and none are returned.
+func (e *ELBV2) DescribeTargetGroupExternalTargets(arn *string) (util.AWSStringSlice, error) {
+	svc, ok := e.Svc.(*elbv2.ELBV2)
+	if !ok {
+		return nil, nil
+	}
+	out := &externalTargetHealthOutput{}
+	op := &request.Operation{Name: "DescribeTargetHealth", HTTPMethod: "POST", HTTPPath: "/"}
+	if err := svc.NewRequest(op, &elbv2.DescribeTargetHealthInput{TargetGroupArn: arn}, out).Send(); err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "ELBV2", "request": "DescribeTargetHealth", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+	var targets util.AWSStringSlice
+	for _, d := range out.TargetHealthDescriptions {
+		if d.Target == nil || aws.StringValue(d.Target.AvailabilityZone) != AvailabilityZoneAll {
+			continue
+		}
+		if d.TargetHealth != nil && aws.StringValue(d.TargetHealth.State) == elbv2.TargetHealthStateEnumDraining {
+			continue
+		}
+		targets = append(targets, d.Target.Id)
+	}
+	sort.Sort(targets)
+	return targets, nil
+}
+
 // DescribeRules looks up all rules for a listener ARN.
 func (e *ELBV2) DescribeRules(listenerArn *string) ([]*elbv2.Rule, error) {
 	describeRulesInput := &elbv2.DescribeRulesInput{
@@ -366,7 +1177,7 @@ func (e *ELBV2) UpdateTags(arn *string, old util.Tags, new util.Tags) error {
 		Tags:         new,
 	}
 	if _, err := e.Svc.AddTags(addParams); err != nil {
-		AWSErrorCount.With(prometheus.Labels{"service": "ELBV2", "request": "AddTags"}).Add(float64(1))
+		AWSErrorCount.With(prometheus.Labels{"service": "ELBV2", "request": "AddTags", "code": ErrorCode(err)}).Add(float64(1))
 		return err
 	}
 
@@ -378,7 +1189,7 @@ func (e *ELBV2) UpdateTags(arn *string, old util.Tags, new util.Tags) error {
 		}
 
 		if _, err := e.Svc.RemoveTags(removeParams); err != nil {
-			AWSErrorCount.With(prometheus.Labels{"service": "ELBV2", "request": "RemoveTags"}).Add(float64(1))
+			AWSErrorCount.With(prometheus.Labels{"service": "ELBV2", "request": "RemoveTags", "code": ErrorCode(err)}).Add(float64(1))
 			return err
 		}
 	}
@@ -1,9 +1,21 @@
 package awsutil
 
 import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/coreos/alb-ingress-controller/controller/util"
+	"k8s.io/client-go/util/flowcontrol"
 )
 
 type mockedELBV2ResponsesT struct {
@@ -22,6 +34,7 @@ var (
 
 type mockedELBV2Client struct {
 	elbv2iface.ELBV2API
+	batches []int
 }
 
 func (m *mockedELBV2Client) CreateListener(input *elbv2.CreateListenerInput) (*elbv2.CreateListenerOutput, error) {
@@ -45,3 +58,297 @@ func (m *mockedELBV2Client) DeleteListener(input *elbv2.DeleteListenerInput) (*e
 	output := &elbv2.DeleteListenerOutput{}
 	return output, mockedELBV2responses.Error
 }
+
+func (m *mockedELBV2Client) RegisterTargets(input *elbv2.RegisterTargetsInput) (*elbv2.RegisterTargetsOutput, error) {
+	m.batches = append(m.batches, len(input.Targets))
+	return &elbv2.RegisterTargetsOutput{}, mockedELBV2responses.Error
+}
+
+func (m *mockedELBV2Client) DescribeTags(input *elbv2.DescribeTagsInput) (*elbv2.DescribeTagsOutput, error) {
+	m.batches = append(m.batches, len(input.ResourceArns))
+	output := &elbv2.DescribeTagsOutput{}
+	for _, arn := range input.ResourceArns {
+		output.TagDescriptions = append(output.TagDescriptions, &elbv2.TagDescription{
+			ResourceArn: arn,
+			Tags:        []*elbv2.Tag{{Key: aws.String("Name"), Value: arn}},
+		})
+	}
+	return output, mockedELBV2responses.Error
+}
+
+func TestDescribeTagsBatch(t *testing.T) {
+	mockedELBV2responses = &mockedELBV2ResponsesT{}
+	client := &mockedELBV2Client{}
+	e := &ELBV2{Svc: client}
+
+	var arns []*string
+	for i := 0; i < 45; i++ {
+		arns = append(arns, aws.String(fmt.Sprintf("arn-%d", i)))
+	}
+	tags, err := e.DescribeTagsBatch(arns)
+	if err != nil {
+		t.Fatalf("DescribeTagsBatch(45 ARNs): returned error %v", err)
+	}
+	if expected := []int{20, 20, 5}; !reflect.DeepEqual(client.batches, expected) {
+		t.Errorf("DescribeTagsBatch(45 ARNs): expected batches %v, actual %v", expected, client.batches)
+	}
+	if len(tags) != 45 {
+		t.Errorf("DescribeTagsBatch(45 ARNs): expected the tags of 45 ARNs, actual %d", len(tags))
+	}
+	arnTags := tags["arn-44"]
+	if value, ok := arnTags.Get("Name"); !ok || value != "arn-44" {
+		t.Errorf("DescribeTagsBatch(45 ARNs): expected the tags of arn-44, actual %v", tags["arn-44"])
+	}
+}
+
+func TestRegisterTargetsBatches(t *testing.T) {
+	var tests = []struct {
+		targets   int
+		batchSize int
+		expected  []int
+	}{
+		{0, 2, nil},
+		{2, 2, []int{2}},
+		{5, 2, []int{2, 2, 1}},
+		{3, 0, []int{3}},
+	}
+
+	for _, tt := range tests {
+		mockedELBV2responses = &mockedELBV2ResponsesT{}
+		client := &mockedELBV2Client{}
+		e := &ELBV2{Svc: client, targetBatchSize: defaultTargetBatchSize, targetLimiter: flowcontrol.NewFakeAlwaysRateLimiter()}
+		e.SetTargetBatching(tt.batchSize, 0)
+
+		in := elbv2.RegisterTargetsInput{TargetGroupArn: aws.String("arn")}
+		for i := 0; i < tt.targets; i++ {
+			in.Targets = append(in.Targets, &elbv2.TargetDescription{Id: aws.String(fmt.Sprintf("i-%d", i))})
+		}
+
+		if err := e.RegisterTargets(in); err != nil {
+			t.Errorf("RegisterTargets(%d targets): returned error %v", tt.targets, err)
+		}
+		if !reflect.DeepEqual(client.batches, tt.expected) {
+			t.Errorf("RegisterTargets(%d targets, batch size %d): expected batches %v, actual %v", tt.targets, tt.batchSize, tt.expected, client.batches)
+		}
+	}
+}
+
+func TestWithExternalTargets(t *testing.T) {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+
+	req, _ := elbv2.New(sess).RegisterTargetsRequest(&elbv2.RegisterTargetsInput{
+		TargetGroupArn: aws.String("arn"),
+		Targets: []*elbv2.TargetDescription{
+			{Id: aws.String("10.1.0.1"), Port: aws.Int64(80)},
+			{Id: aws.String("10.1.0.2"), Port: aws.Int64(80)},
+		},
+	})
+	req.ApplyOptions(withExternalTargets(2))
+	if err := req.Build(); err != nil {
+		t.Fatalf("Build(): returned error %v", err)
+	}
+
+	b, _ := ioutil.ReadAll(req.GetBody())
+	body, err := url.ParseQuery(string(b))
+	if err != nil {
+		t.Fatalf("ParseQuery(%s): returned error %v", b, err)
+	}
+	for _, k := range []string{"Targets.member.1.AvailabilityZone", "Targets.member.2.AvailabilityZone"} {
+		if body.Get(k) != AvailabilityZoneAll {
+			t.Errorf("withExternalTargets(2): expected %s=%s, actual %s", k, AvailabilityZoneAll, body.Get(k))
+		}
+	}
+	if body.Get("Targets.member.1.Id") != "10.1.0.1" {
+		t.Errorf("withExternalTargets(2): expected Targets.member.1.Id=10.1.0.1, actual %s", body.Get("Targets.member.1.Id"))
+	}
+}
+
+func TestWithRedirectAction(t *testing.T) {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+
+	req, _ := elbv2.New(sess).CreateRuleRequest(&elbv2.CreateRuleInput{
+		ListenerArn: aws.String("arn"),
+		Priority:    aws.Int64(1),
+		Actions:     []*elbv2.Action{{Type: aws.String("forward"), TargetGroupArn: aws.String("redirect")}},
+		Conditions: []*elbv2.RuleCondition{
+			{Field: aws.String("path-pattern"), Values: []*string{aws.String("/old/*")}},
+		},
+	})
+	req.ApplyOptions(withRedirectAction(map[string]string{"Host": "example.com", "Path": "", "StatusCode": "HTTP_301"}))
+	if err := req.Build(); err != nil {
+		t.Fatalf("Build(): returned error %v", err)
+	}
+
+	b, _ := ioutil.ReadAll(req.GetBody())
+	body, err := url.ParseQuery(string(b))
+	if err != nil {
+		t.Fatalf("ParseQuery(%s): returned error %v", b, err)
+	}
+	expected := map[string]string{
+		"Actions.member.1.Type":                      "redirect",
+		"Actions.member.1.TargetGroupArn":            "",
+		"Actions.member.1.RedirectConfig.Host":       "example.com",
+		"Actions.member.1.RedirectConfig.StatusCode": "HTTP_301",
+		"Conditions.member.1.Values.member.1":        "/old/*",
+	}
+	for k, v := range expected {
+		if body.Get(k) != v {
+			t.Errorf("withRedirectAction: expected %s=%s, actual %s", k, v, body.Get(k))
+		}
+	}
+	if _, ok := body["Actions.member.1.RedirectConfig.Path"]; ok {
+		t.Errorf("withRedirectAction: expected empty Path to be left out")
+	}
+}
+
+func TestWithWeightedForward(t *testing.T) {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+
+	req, _ := elbv2.New(sess).ModifyListenerRequest(&elbv2.ModifyListenerInput{
+		ListenerArn:    aws.String("arn"),
+		DefaultActions: []*elbv2.Action{{Type: aws.String("forward"), TargetGroupArn: aws.String("new")}},
+	})
+	req.ApplyOptions(withWeightedForward("DefaultActions.member.1", []TargetGroupWeight{
+		{TargetGroupArn: "new", Weight: 30},
+		{TargetGroupArn: "old", Weight: 70},
+	}))
+	if err := req.Build(); err != nil {
+		t.Fatalf("Build(): returned error %v", err)
+	}
+
+	b, _ := ioutil.ReadAll(req.GetBody())
+	body, err := url.ParseQuery(string(b))
+	if err != nil {
+		t.Fatalf("ParseQuery(%s): returned error %v", b, err)
+	}
+	expected := map[string]string{
+		"DefaultActions.member.1.Type": "forward",
+		"DefaultActions.member.1.ForwardConfig.TargetGroups.member.1.TargetGroupArn": "new",
+		"DefaultActions.member.1.ForwardConfig.TargetGroups.member.1.Weight":         "30",
+		"DefaultActions.member.1.ForwardConfig.TargetGroups.member.2.TargetGroupArn": "old",
+		"DefaultActions.member.1.ForwardConfig.TargetGroups.member.2.Weight":         "70",
+	}
+	for k, v := range expected {
+		if body.Get(k) != v {
+			t.Errorf("withWeightedForward: expected %s=%s, actual %s", k, v, body.Get(k))
+		}
+	}
+	if _, ok := body["DefaultActions.member.1.TargetGroupArn"]; ok {
+		t.Errorf("withWeightedForward: expected the placeholder TargetGroupArn to be removed")
+	}
+}
+
+func TestWithHostHeaderConfig(t *testing.T) {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+
+	req, _ := elbv2.New(sess).ModifyRuleRequest(&elbv2.ModifyRuleInput{
+		RuleArn: aws.String("arn"),
+		Conditions: []*elbv2.RuleCondition{
+			{Field: aws.String("path-pattern"), Values: aws.StringSlice([]string{"/api/*"})},
+			{Field: aws.String("host-header"), Values: aws.StringSlice([]string{"example.com", "www.example.com"})},
+		},
+	})
+	req.ApplyOptions(withHostHeaderConfig())
+	if err := req.Build(); err != nil {
+		t.Fatalf("Build(): returned error %v", err)
+	}
+
+	b, _ := ioutil.ReadAll(req.GetBody())
+	body, err := url.ParseQuery(string(b))
+	if err != nil {
+		t.Fatalf("ParseQuery(%s): returned error %v", b, err)
+	}
+	expected := map[string]string{
+		"Conditions.member.1.Values.member.1":                  "/api/*",
+		"Conditions.member.2.Field":                            "host-header",
+		"Conditions.member.2.HostHeaderConfig.Values.member.1": "example.com",
+		"Conditions.member.2.HostHeaderConfig.Values.member.2": "www.example.com",
+	}
+	for k, v := range expected {
+		if body.Get(k) != v {
+			t.Errorf("withHostHeaderConfig: expected %s=%s, actual %s", k, v, body.Get(k))
+		}
+	}
+	if _, ok := body["Conditions.member.2.Values.member.1"]; ok {
+		t.Errorf("withHostHeaderConfig: expected the Values of the host-header condition to be removed")
+	}
+}
+
+func TestDescribeListenerCertificates(t *testing.T) {
+	var body url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body, _ = url.ParseQuery(string(b))
+		fmt.Fprint(w, `<DescribeListenerCertificatesResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2015-12-01/">
+  <DescribeListenerCertificatesResult>
+    <Certificates>
+      <member><CertificateArn>default</CertificateArn><IsDefault>true</IsDefault></member>
+      <member><CertificateArn>sni</CertificateArn><IsDefault>false</IsDefault></member>
+    </Certificates>
+  </DescribeListenerCertificatesResult>
+</DescribeListenerCertificatesResponse>`)
+	}))
+	defer server.Close()
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+	e := &ELBV2{Svc: elbv2.New(sess)}
+
+	certificates, err := e.DescribeListenerCertificates(aws.String("listener"))
+	if err != nil {
+		t.Fatalf("DescribeListenerCertificates(): returned error %v", err)
+	}
+	if body.Get("Action") != "DescribeListenerCertificates" || body.Get("ListenerArn") != "listener" {
+		t.Errorf("DescribeListenerCertificates(): unexpected request %v", body)
+	}
+	expected := []*elbv2.Certificate{{CertificateArn: aws.String("sni")}}
+	if !reflect.DeepEqual(certificates, expected) {
+		t.Errorf("DescribeListenerCertificates(): expected %v, actual %v", expected, certificates)
+	}
+}
+
+func TestDescribeTargetGroupExternalTargets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<DescribeTargetHealthResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2015-12-01/">
+  <DescribeTargetHealthResult>
+    <TargetHealthDescriptions>
+      <member><Target><Id>10.0.1.10</Id><Port>80</Port></Target><TargetHealth><State>healthy</State></TargetHealth></member>
+      <member><Target><Id>192.168.1.10</Id><Port>80</Port><AvailabilityZone>all</AvailabilityZone></Target><TargetHealth><State>healthy</State></TargetHealth></member>
+      <member><Target><Id>192.168.1.11</Id><Port>80</Port><AvailabilityZone>all</AvailabilityZone></Target><TargetHealth><State>draining</State></TargetHealth></member>
+    </TargetHealthDescriptions>
+  </DescribeTargetHealthResult>
+</DescribeTargetHealthResponse>`)
+	}))
+	defer server.Close()
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+	e := &ELBV2{Svc: elbv2.New(sess)}
+
+	targets, err := e.DescribeTargetGroupExternalTargets(aws.String("targetgroup"))
+	if err != nil {
+		t.Fatalf("DescribeTargetGroupExternalTargets(): returned error %v", err)
+	}
+	expected := util.AWSStringSlice{aws.String("192.168.1.10")}
+	if !reflect.DeepEqual(targets, expected) {
+		t.Errorf("DescribeTargetGroupExternalTargets(): expected %v, actual %v", Prettify(expected), Prettify(targets))
+	}
+}
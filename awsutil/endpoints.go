@@ -0,0 +1,91 @@
+package awsutil
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/golang/glog"
+)
+
+// fipsRegions are the regions with FIPS 140-2 endpoints, keyed by the endpoint ID of each service
+// the controller calls that has them. The vendored aws-sdk-go doesn't know about these endpoints.
+// Route 53 and IAM have none outside GovCloud.
+var fipsRegions = map[string][]string{
+	"acm":                  {"ca-central-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
+	"ec2":                  {"ca-central-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
+	"elasticloadbalancing": {"ca-central-1", "us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
+	"sts":                  {"us-east-1", "us-east-2", "us-gov-east-1", "us-gov-west-1", "us-west-1", "us-west-2"},
+}
+
+// EndpointResolver resolves the endpoints of the AWS services the controller calls with the default
+// resolver, unless it's set to prefer FIPS endpoints or regional STS endpoints. The vendored
+// aws-sdk-go knows about neither.
+type EndpointResolver struct {
+	// FIPS resolves the FIPS endpoints of ELBV2, EC2, ACM and STS in the regions that have them. A
+	// warning is logged the first time a service is resolved without a FIPS endpoint in a region.
+	FIPS bool
+	// RegionalSTS resolves the regional STS endpoint of the region calls are made in, rather than
+	// the global sts.amazonaws.com.
+	RegionalSTS bool
+	// STSRegion, when set, is the region of the regional STS endpoint, whatever region the other
+	// services are called in. It implies RegionalSTS.
+	STSRegion string
+
+	mu     sync.Mutex
+	warned map[string]bool
+}
+
+// IsDefault reports whether the resolver resolves every endpoint with the default resolver, so
+// it doesn't need to be installed.
+func (e *EndpointResolver) IsDefault() bool {
+	return !e.FIPS && !e.RegionalSTS && e.STSRegion == ""
+}
+
+// EndpointFor implements endpoints.Resolver.
+func (e *EndpointResolver) EndpointFor(service, region string, opts ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+	regionalSTS := service == "sts" && (e.RegionalSTS || e.STSRegion != "")
+	if service == "sts" && e.STSRegion != "" {
+		region = e.STSRegion
+	}
+
+	if e.FIPS {
+		for _, r := range fipsRegions[service] {
+			if r == region {
+				return resolvedEndpoint(service+"-fips", service, region), nil
+			}
+		}
+		e.warnOnce(service, region)
+	}
+	if regionalSTS {
+		return resolvedEndpoint(service, service, region), nil
+	}
+	return endpoints.DefaultResolver().EndpointFor(service, region, opts...)
+}
+
+// warnOnce logs that service has no FIPS endpoint in region, the first time it's resolved there.
+func (e *EndpointResolver) warnOnce(service, region string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.warned == nil {
+		e.warned = make(map[string]bool)
+	}
+	if key := service + " " + region; !e.warned[key] {
+		e.warned[key] = true
+		glog.Warningf("%s has no FIPS endpoint in %s, using its default endpoint.", service, region)
+	}
+}
+
+// resolvedEndpoint returns the regional endpoint host.region of service.
+func resolvedEndpoint(host, service, region string) endpoints.ResolvedEndpoint {
+	domain := "amazonaws.com"
+	if strings.HasPrefix(region, "cn-") {
+		domain = "amazonaws.com.cn"
+	}
+	return endpoints.ResolvedEndpoint{
+		URL:           fmt.Sprintf("https://%s.%s.%s", host, region, domain),
+		SigningRegion: region,
+		SigningName:   service,
+	}
+}
@@ -0,0 +1,41 @@
+package awsutil
+
+import "testing"
+
+func TestEndpointResolver(t *testing.T) {
+	var tests = []struct {
+		resolver EndpointResolver
+		service  string
+		region   string
+		url      string
+		signing  string
+	}{
+		{EndpointResolver{}, "sts", "eu-west-1", "https://sts.amazonaws.com", "us-east-1"},
+		{EndpointResolver{FIPS: true}, "elasticloadbalancing", "us-east-1", "https://elasticloadbalancing-fips.us-east-1.amazonaws.com", "us-east-1"},
+		{EndpointResolver{FIPS: true}, "ec2", "us-gov-west-1", "https://ec2-fips.us-gov-west-1.amazonaws.com", "us-gov-west-1"},
+		{EndpointResolver{FIPS: true}, "acm", "ca-central-1", "https://acm-fips.ca-central-1.amazonaws.com", "ca-central-1"},
+		{EndpointResolver{FIPS: true}, "sts", "us-west-2", "https://sts-fips.us-west-2.amazonaws.com", "us-west-2"},
+		{EndpointResolver{FIPS: true}, "sts", "ca-central-1", "https://sts.amazonaws.com", "us-east-1"},
+		{EndpointResolver{FIPS: true}, "elasticloadbalancing", "eu-west-1", "https://elasticloadbalancing.eu-west-1.amazonaws.com", "eu-west-1"},
+		{EndpointResolver{FIPS: true}, "route53", "us-east-1", "https://route53.amazonaws.com", "us-east-1"},
+		{EndpointResolver{RegionalSTS: true}, "sts", "eu-west-1", "https://sts.eu-west-1.amazonaws.com", "eu-west-1"},
+		{EndpointResolver{RegionalSTS: true}, "sts", "cn-north-1", "https://sts.cn-north-1.amazonaws.com.cn", "cn-north-1"},
+		{EndpointResolver{RegionalSTS: true}, "ec2", "eu-west-1", "https://ec2.eu-west-1.amazonaws.com", "eu-west-1"},
+		{EndpointResolver{STSRegion: "eu-central-1"}, "sts", "eu-west-1", "https://sts.eu-central-1.amazonaws.com", "eu-central-1"},
+		{EndpointResolver{FIPS: true, STSRegion: "us-east-2"}, "sts", "eu-west-1", "https://sts-fips.us-east-2.amazonaws.com", "us-east-2"},
+		{EndpointResolver{FIPS: true, RegionalSTS: true}, "sts", "ca-central-1", "https://sts.ca-central-1.amazonaws.com", "ca-central-1"},
+	}
+
+	for i := range tests {
+		tt := &tests[i]
+		e, err := tt.resolver.EndpointFor(tt.service, tt.region)
+		if err != nil {
+			t.Errorf("EndpointFor(%s, %s) of resolver %d returned error %v", tt.service, tt.region, i, err)
+			continue
+		}
+		if e.URL != tt.url || e.SigningRegion != tt.signing {
+			t.Errorf("EndpointFor(%s, %s) of resolver %d returned %s signed for %s, expected %s signed for %s",
+				tt.service, tt.region, i, e.URL, e.SigningRegion, tt.url, tt.signing)
+		}
+	}
+}
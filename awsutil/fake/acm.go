@@ -0,0 +1,250 @@
+package fake
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"github.com/aws/aws-sdk-go/service/acm/acmiface"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+)
+
+// ACM is an in-memory acmiface.ACMAPI, also serving the calls of an awsutil.ACMRequests. Issued
+// certificates are added with AddCertificate, imported ones are described from their PEM encoded
+// certificate. Requested certificates are pending validation until they're issued with Issue.
+type ACM struct {
+	acmiface.ACMAPI
+
+	mu           sync.Mutex
+	ids          ids
+	certificates map[string]*acm.CertificateDetail
+	tags         map[string][]*acm.Tag
+	requests     map[string]string // ARNs of the requested certificates, keyed by idempotency token
+}
+
+// NewACM returns an ACM without any certificates.
+func NewACM() *ACM {
+	return &ACM{
+		certificates: make(map[string]*acm.CertificateDetail),
+		tags:         make(map[string][]*acm.Tag),
+		requests:     make(map[string]string),
+	}
+}
+
+func (a *ACM) arn() string {
+	return fmt.Sprintf("arn:aws:acm:%s:%s:certificate/%s", region, account, a.ids.next())
+}
+
+// AddCertificate adds a certificate issued by ACM for domainName and subjectAlternativeNames, valid
+// until notAfter, and returns its ARN.
+func (a *ACM) AddCertificate(domainName string, subjectAlternativeNames []string, notAfter time.Time) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	arn := a.arn()
+	now := time.Now()
+	a.certificates[arn] = &acm.CertificateDetail{
+		CertificateArn:          aws.String(arn),
+		CreatedAt:               &now,
+		DomainName:              aws.String(domainName),
+		IssuedAt:                &now,
+		NotAfter:                aws.Time(notAfter),
+		NotBefore:               &now,
+		Status:                  aws.String(acm.CertificateStatusIssued),
+		SubjectAlternativeNames: aws.StringSlice(append([]string{domainName}, subjectAlternativeNames...)),
+		Type:                    aws.String(acm.CertificateTypeAmazonIssued),
+	}
+	return arn
+}
+
+// Issue issues the requested certificate arn, as if ACM found its validation records.
+func (a *ACM) Issue(arn string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	c := a.certificates[arn]
+	c.IssuedAt, c.NotBefore, c.NotAfter = &now, &now, aws.Time(now.AddDate(1, 0, 1))
+	c.Status = aws.String(acm.CertificateStatusIssued)
+}
+
+// validationRecord returns the CNAME record validating domain, the same for a wildcard domain and
+// the domain it's a wildcard of, like ACM.
+func validationRecord(domain string) *awsutil.ResourceRecord {
+	domain = strings.TrimPrefix(domain, "*.")
+	return &awsutil.ResourceRecord{
+		Name:  aws.String(fmt.Sprintf("_%x.%s.", len(domain), domain)),
+		Type:  aws.String(route53.RRTypeCname),
+		Value: aws.String(fmt.Sprintf("_%x.acm-validations.aws.", len(domain))),
+	}
+}
+
+func (a *ACM) client() *client.Client {
+	return newClient("acm", a.serve)
+}
+
+func (a *ACM) serve(operation string, in, out interface{}) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch operation {
+	case "RequestCertificate":
+		token := *stringField(in, "IdempotencyToken")
+		arn, ok := a.requests[token]
+		if !ok {
+			arn = a.arn()
+			a.requests[token] = arn
+			now := time.Now()
+			domainName := stringField(in, "DomainName")
+			a.certificates[arn] = &acm.CertificateDetail{
+				CertificateArn:          aws.String(arn),
+				CreatedAt:               &now,
+				DomainName:              aws.String(*domainName),
+				Status:                  aws.String(acm.CertificateStatusPendingValidation),
+				SubjectAlternativeNames: []*string{aws.String(*domainName)},
+				Type:                    aws.String(acm.CertificateTypeAmazonIssued),
+			}
+			if names := field(in, "SubjectAlternativeNames").Interface().([]*string); len(names) > 0 {
+				a.certificates[arn].SubjectAlternativeNames = copyOf(names).([]*string)
+			}
+			tags := field(in, "Tags")
+			for i := 0; i < tags.Len(); i++ {
+				tag := tags.Index(i).Interface()
+				a.tags[arn] = append(a.tags[arn], &acm.Tag{
+					Key:   aws.String(*stringField(tag, "Key")),
+					Value: aws.String(*stringField(tag, "Value")),
+				})
+			}
+		}
+		field(out, "CertificateArn").Set(reflect.ValueOf(aws.String(arn)))
+	case "DescribeCertificate":
+		c, err := a.certificate(stringField(in, "CertificateArn"))
+		if err != nil {
+			return err
+		}
+		detail := field(out, "Certificate")
+		detail.Set(reflect.New(detail.Type().Elem()))
+		detail.Elem().FieldByName("Status").Set(reflect.ValueOf(aws.String(*c.Status)))
+		options := detail.Elem().FieldByName("DomainValidationOptions")
+		for _, name := range c.SubjectAlternativeNames {
+			option := reflect.New(options.Type().Elem().Elem())
+			option.Elem().FieldByName("DomainName").Set(reflect.ValueOf(aws.String(*name)))
+			option.Elem().FieldByName("ResourceRecord").Set(reflect.ValueOf(validationRecord(*name)))
+			options.Set(reflect.Append(options, option))
+		}
+	default:
+		panic("fake ACM doesn't implement " + operation)
+	}
+	return nil
+}
+
+func (a *ACM) certificate(arn *string) (*acm.CertificateDetail, error) {
+	c, ok := a.certificates[aws.StringValue(arn)]
+	if !ok {
+		return nil, awserr.New(acm.ErrCodeResourceNotFoundException, fmt.Sprintf("Could not find certificate %s", aws.StringValue(arn)), nil)
+	}
+	return c, nil
+}
+
+func (a *ACM) DescribeCertificate(in *acm.DescribeCertificateInput) (*acm.DescribeCertificateOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	c, err := a.certificate(in.CertificateArn)
+	if err != nil {
+		return nil, err
+	}
+	return &acm.DescribeCertificateOutput{Certificate: copyOf(c).(*acm.CertificateDetail)}, nil
+}
+
+// ListCertificatesPages pages through the certificates with one of in.CertificateStatuses, a
+// single page holding all of them.
+func (a *ACM) ListCertificatesPages(in *acm.ListCertificatesInput, fn func(*acm.ListCertificatesOutput, bool) bool) error {
+	a.mu.Lock()
+	page := &acm.ListCertificatesOutput{}
+	for _, c := range a.certificates {
+		if matches(in.CertificateStatuses, c.Status) {
+			page.CertificateSummaryList = append(page.CertificateSummaryList, &acm.CertificateSummary{
+				CertificateArn: aws.String(*c.CertificateArn),
+				DomainName:     aws.String(*c.DomainName),
+			})
+		}
+	}
+	a.mu.Unlock()
+	sort.Slice(page.CertificateSummaryList, func(i, j int) bool {
+		return *page.CertificateSummaryList[i].CertificateArn < *page.CertificateSummaryList[j].CertificateArn
+	})
+	fn(page, true)
+	return nil
+}
+
+// ImportCertificate imports in.Certificate, replacing the certificate in.CertificateArn when it's
+// set.
+func (a *ACM) ImportCertificate(in *acm.ImportCertificateInput) (*acm.ImportCertificateOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	block, _ := pem.Decode(in.Certificate)
+	if block == nil {
+		return nil, awserr.New("ValidationException", "The certificate field contains more than one certificate or isn't PEM encoded", nil)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, awserr.New("ValidationException", fmt.Sprintf("Unable to parse certificate: %s", err.Error()), nil)
+	}
+
+	arn := aws.StringValue(in.CertificateArn)
+	if arn == "" {
+		arn = a.arn()
+	} else if _, err := a.certificate(in.CertificateArn); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	a.certificates[arn] = &acm.CertificateDetail{
+		CertificateArn:          aws.String(arn),
+		DomainName:              aws.String(cert.Subject.CommonName),
+		ImportedAt:              &now,
+		NotAfter:                aws.Time(cert.NotAfter),
+		NotBefore:               aws.Time(cert.NotBefore),
+		Serial:                  aws.String(cert.SerialNumber.String()),
+		Status:                  aws.String(acm.CertificateStatusIssued),
+		SubjectAlternativeNames: aws.StringSlice(cert.DNSNames),
+		Type:                    aws.String(acm.CertificateTypeImported),
+	}
+	return &acm.ImportCertificateOutput{CertificateArn: aws.String(arn)}, nil
+}
+
+func (a *ACM) AddTagsToCertificate(in *acm.AddTagsToCertificateInput) (*acm.AddTagsToCertificateOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.certificate(in.CertificateArn); err != nil {
+		return nil, err
+	}
+	tags := a.tags[*in.CertificateArn]
+next:
+	for _, t := range in.Tags {
+		for _, current := range tags {
+			if *current.Key == *t.Key {
+				current.Value = aws.String(aws.StringValue(t.Value))
+				continue next
+			}
+		}
+		tags = append(tags, copyOf(t).(*acm.Tag))
+	}
+	a.tags[*in.CertificateArn] = tags
+	return &acm.AddTagsToCertificateOutput{}, nil
+}
+
+func (a *ACM) ListTagsForCertificate(in *acm.ListTagsForCertificateInput) (*acm.ListTagsForCertificateOutput, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.certificate(in.CertificateArn); err != nil {
+		return nil, err
+	}
+	return &acm.ListTagsForCertificateOutput{Tags: copyOf(a.tags[*in.CertificateArn]).([]*acm.Tag)}, nil
+}
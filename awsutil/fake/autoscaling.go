@@ -0,0 +1,44 @@
+package fake
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+)
+
+// AutoScaling is an in-memory Auto Scaling, serving the calls of an awsutil.AutoScaling. It keeps
+// the lifecycle actions that were completed, without checking that their hooks exist.
+type AutoScaling struct {
+	mu        sync.Mutex
+	completed map[string]string
+}
+
+// NewAutoScaling returns an AutoScaling without any completed lifecycle actions.
+func NewAutoScaling() *AutoScaling {
+	return &AutoScaling{completed: make(map[string]string)}
+}
+
+// Completed returns the result the lifecycle action of the instance id was completed with, empty
+// when it wasn't completed.
+func (a *AutoScaling) Completed(id string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.completed[id]
+}
+
+func (a *AutoScaling) client() *client.Client {
+	return newClient("autoscaling", a.serve)
+}
+
+func (a *AutoScaling) serve(operation string, in, out interface{}) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch operation {
+	case "CompleteLifecycleAction":
+		a.completed[aws.StringValue(stringField(in, "InstanceId"))] = aws.StringValue(stringField(in, "LifecycleActionResult"))
+	default:
+		panic("fake AutoScaling doesn't implement " + operation)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package fake
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+)
+
+// CloudWatch is an in-memory CloudWatch, serving the calls of an awsutil.CloudWatch. It keeps the
+// alarms and metric values it's given, without evaluating them.
+type CloudWatch struct {
+	mu      sync.Mutex
+	alarms  map[string]*awsutil.MetricAlarm
+	metrics map[string][]*awsutil.MetricDatum
+}
+
+// NewCloudWatch returns a CloudWatch without any alarms.
+func NewCloudWatch() *CloudWatch {
+	return &CloudWatch{alarms: make(map[string]*awsutil.MetricAlarm), metrics: make(map[string][]*awsutil.MetricDatum)}
+}
+
+// Metrics returns the metric values published under namespace, in the order they were published.
+func (c *CloudWatch) Metrics(namespace string) []*awsutil.MetricDatum {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var data []*awsutil.MetricDatum
+	for _, datum := range c.metrics[namespace] {
+		data = append(data, copyOf(datum).(*awsutil.MetricDatum))
+	}
+	return data
+}
+
+// Alarms returns the alarms, sorted by name.
+func (c *CloudWatch) Alarms() []*awsutil.MetricAlarm {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var names []string
+	for name := range c.alarms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var alarms []*awsutil.MetricAlarm
+	for _, name := range names {
+		alarms = append(alarms, copyOf(c.alarms[name]).(*awsutil.MetricAlarm))
+	}
+	return alarms
+}
+
+func (c *CloudWatch) client() *client.Client {
+	return newClient("monitoring", c.serve)
+}
+
+func (c *CloudWatch) serve(operation string, in, out interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch operation {
+	case "PutMetricAlarm":
+		alarm := copyOf(in).(*awsutil.MetricAlarm)
+		c.alarms[*alarm.AlarmName] = alarm
+	case "DescribeAlarms":
+		var alarms []*awsutil.MetricAlarm
+		for _, name := range field(in, "AlarmNames").Interface().([]*string) {
+			if alarm, ok := c.alarms[aws.StringValue(name)]; ok {
+				alarms = append(alarms, copyOf(alarm).(*awsutil.MetricAlarm))
+			}
+		}
+		field(out, "MetricAlarms").Set(reflect.ValueOf(alarms))
+	case "DeleteAlarms":
+		names := field(in, "AlarmNames").Interface().([]*string)
+		for _, name := range names {
+			if _, ok := c.alarms[aws.StringValue(name)]; !ok {
+				return notFound("ResourceNotFound", "Alarm", aws.StringValue(name))
+			}
+		}
+		for _, name := range names {
+			delete(c.alarms, aws.StringValue(name))
+		}
+	case "PutMetricData":
+		namespace := aws.StringValue(field(in, "Namespace").Interface().(*string))
+		for _, datum := range field(in, "MetricData").Interface().([]*awsutil.MetricDatum) {
+			c.metrics[namespace] = append(c.metrics[namespace], copyOf(datum).(*awsutil.MetricDatum))
+		}
+	default:
+		panic("fake CloudWatch doesn't implement " + operation)
+	}
+	return nil
+}
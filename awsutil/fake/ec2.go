@@ -0,0 +1,353 @@
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+// EC2 is an in-memory ec2iface.EC2API holding subnets, prefix lists and security groups. Subnets and
+// prefix lists aren't managed by the controller, so tests add them with AddSubnet and AddPrefixList.
+// Only the group-id, group-name, subnet-id, vpc-id, availability-zone, prefix-list-name, tag-key and
+// tag:<key> filters are supported. Rule descriptions, which the awsutil EC2 sends as request
+// options, aren't seen.
+type EC2 struct {
+	ec2iface.EC2API
+
+	mu             sync.Mutex
+	ids            ids
+	subnets        map[string]*ec2.Subnet
+	prefixLists    map[string]*ec2.PrefixList
+	securityGroups map[string]*ec2.SecurityGroup
+}
+
+// NewEC2 returns an EC2 without any resources.
+func NewEC2() *EC2 {
+	return &EC2{
+		subnets:        make(map[string]*ec2.Subnet),
+		prefixLists:    make(map[string]*ec2.PrefixList),
+		securityGroups: make(map[string]*ec2.SecurityGroup),
+	}
+}
+
+// AddSubnet adds the subnet id of the VPC vpcID in the availability zone zone, tagged with tags.
+func (e *EC2) AddSubnet(id, vpcID, zone string, tags map[string]string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.subnets[id] = &ec2.Subnet{
+		AvailabilityZone: aws.String(zone),
+		State:            aws.String(ec2.SubnetStateAvailable),
+		SubnetId:         aws.String(id),
+		Tags:             ec2Tags(tags),
+		VpcId:            aws.String(vpcID),
+	}
+}
+
+// AddPrefixList adds the AWS-managed prefix list id named name, e.g.
+// com.amazonaws.global.cloudfront.origin-facing.
+func (e *EC2) AddPrefixList(id, name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.prefixLists[id] = &ec2.PrefixList{PrefixListId: aws.String(id), PrefixListName: aws.String(name)}
+}
+
+// SecurityGroup returns the security group id, or nil when it doesn't exist.
+func (e *EC2) SecurityGroup(id string) *ec2.SecurityGroup {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sg, ok := e.securityGroups[id]
+	if !ok {
+		return nil
+	}
+	return copyOf(sg).(*ec2.SecurityGroup)
+}
+
+// subnet returns the subnet id, or nil when it doesn't exist or e is nil.
+func (e *EC2) subnet(id string) *ec2.Subnet {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.subnets[id]
+}
+
+func ec2Tags(tags map[string]string) []*ec2.Tag {
+	var out []*ec2.Tag
+	for k, v := range tags {
+		out = append(out, &ec2.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	sort.Slice(out, func(i, j int) bool { return *out[i].Key < *out[j].Key })
+	return out
+}
+
+// filtered returns true when a resource with the given filter values and tags matches all filters.
+func filtered(filters []*ec2.Filter, values map[string]*string, tags []*ec2.Tag) bool {
+	for _, f := range filters {
+		var names []*string
+		switch {
+		case *f.Name == "tag-key":
+			for _, t := range tags {
+				names = append(names, t.Key)
+			}
+		case strings.HasPrefix(*f.Name, "tag:"):
+			for _, t := range tags {
+				if *t.Key == strings.TrimPrefix(*f.Name, "tag:") {
+					names = append(names, t.Value)
+				}
+			}
+		default:
+			if v, ok := values[*f.Name]; ok {
+				names = append(names, v)
+			}
+		}
+		found := false
+		for _, name := range names {
+			found = found || contains(f.Values, name)
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *EC2) DescribeSubnets(in *ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, id := range in.SubnetIds {
+		if _, ok := e.subnets[*id]; !ok {
+			return nil, awserr.New("InvalidSubnetID.NotFound", fmt.Sprintf("The subnet ID '%s' does not exist", *id), nil)
+		}
+	}
+	out := &ec2.DescribeSubnetsOutput{}
+	for _, s := range e.subnets {
+		values := map[string]*string{"subnet-id": s.SubnetId, "vpc-id": s.VpcId, "availability-zone": s.AvailabilityZone}
+		if matches(in.SubnetIds, s.SubnetId) && filtered(in.Filters, values, s.Tags) {
+			out.Subnets = append(out.Subnets, copyOf(s).(*ec2.Subnet))
+		}
+	}
+	sort.Slice(out.Subnets, func(i, j int) bool { return *out.Subnets[i].SubnetId < *out.Subnets[j].SubnetId })
+	return out, nil
+}
+
+func (e *EC2) DescribePrefixLists(in *ec2.DescribePrefixListsInput) (*ec2.DescribePrefixListsOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := &ec2.DescribePrefixListsOutput{}
+	for _, p := range e.prefixLists {
+		values := map[string]*string{"prefix-list-id": p.PrefixListId, "prefix-list-name": p.PrefixListName}
+		if matches(in.PrefixListIds, p.PrefixListId) && filtered(in.Filters, values, nil) {
+			out.PrefixLists = append(out.PrefixLists, copyOf(p).(*ec2.PrefixList))
+		}
+	}
+	sort.Slice(out.PrefixLists, func(i, j int) bool { return *out.PrefixLists[i].PrefixListId < *out.PrefixLists[j].PrefixListId })
+	return out, nil
+}
+
+func (e *EC2) CreateSecurityGroup(in *ec2.CreateSecurityGroupInput) (*ec2.CreateSecurityGroupOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, sg := range e.securityGroups {
+		if *sg.GroupName == *in.GroupName && aws.StringValue(sg.VpcId) == aws.StringValue(in.VpcId) {
+			return nil, awserr.New("InvalidGroup.Duplicate", fmt.Sprintf("The security group '%s' already exists", *in.GroupName), nil)
+		}
+	}
+	id := "sg-" + e.ids.next()
+	e.securityGroups[id] = &ec2.SecurityGroup{
+		Description: aws.String(aws.StringValue(in.Description)),
+		GroupId:     aws.String(id),
+		GroupName:   aws.String(*in.GroupName),
+		OwnerId:     aws.String(account),
+		VpcId:       aws.String(aws.StringValue(in.VpcId)),
+		// New security groups allow all outbound traffic.
+		IpPermissionsEgress: []*ec2.IpPermission{
+			{IpProtocol: aws.String("-1"), IpRanges: []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}}},
+		},
+	}
+	return &ec2.CreateSecurityGroupOutput{GroupId: aws.String(id)}, nil
+}
+
+func (e *EC2) securityGroup(id *string) (*ec2.SecurityGroup, error) {
+	sg, ok := e.securityGroups[aws.StringValue(id)]
+	if !ok {
+		return nil, awserr.New("InvalidGroup.NotFound", fmt.Sprintf("The security group '%s' does not exist", aws.StringValue(id)), nil)
+	}
+	return sg, nil
+}
+
+func (e *EC2) DescribeSecurityGroups(in *ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, id := range in.GroupIds {
+		if _, err := e.securityGroup(id); err != nil {
+			return nil, err
+		}
+	}
+	out := &ec2.DescribeSecurityGroupsOutput{}
+	for _, sg := range e.securityGroups {
+		values := map[string]*string{"group-id": sg.GroupId, "group-name": sg.GroupName, "vpc-id": sg.VpcId}
+		if matches(in.GroupIds, sg.GroupId) && matches(in.GroupNames, sg.GroupName) && filtered(in.Filters, values, sg.Tags) {
+			out.SecurityGroups = append(out.SecurityGroups, copyOf(sg).(*ec2.SecurityGroup))
+		}
+	}
+	sort.Slice(out.SecurityGroups, func(i, j int) bool { return *out.SecurityGroups[i].GroupId < *out.SecurityGroups[j].GroupId })
+	return out, nil
+}
+
+func (e *EC2) DeleteSecurityGroup(in *ec2.DeleteSecurityGroupInput) (*ec2.DeleteSecurityGroupOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.securityGroup(in.GroupId); err != nil {
+		return nil, err
+	}
+	delete(e.securityGroups, *in.GroupId)
+	return &ec2.DeleteSecurityGroupOutput{}, nil
+}
+
+func (e *EC2) AuthorizeSecurityGroupIngress(in *ec2.AuthorizeSecurityGroupIngressInput) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sg, err := e.securityGroup(in.GroupId)
+	if err != nil {
+		return nil, err
+	}
+	sg.IpPermissions = append(sg.IpPermissions, copyOf(in.IpPermissions).([]*ec2.IpPermission)...)
+	return &ec2.AuthorizeSecurityGroupIngressOutput{}, nil
+}
+
+func (e *EC2) AuthorizeSecurityGroupIngressWithContext(ctx aws.Context, in *ec2.AuthorizeSecurityGroupIngressInput, opts ...request.Option) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	return e.AuthorizeSecurityGroupIngress(in)
+}
+
+// RevokeSecurityGroupIngress removes the sources of in from the permissions with the same protocol
+// and ports. Permissions left without sources are removed.
+func (e *EC2) RevokeSecurityGroupIngress(in *ec2.RevokeSecurityGroupIngressInput) (*ec2.RevokeSecurityGroupIngressOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sg, err := e.securityGroup(in.GroupId)
+	if err != nil {
+		return nil, err
+	}
+	sg.IpPermissions = revokePermissions(sg.IpPermissions, in.IpPermissions)
+	return &ec2.RevokeSecurityGroupIngressOutput{}, nil
+}
+
+func (e *EC2) AuthorizeSecurityGroupEgress(in *ec2.AuthorizeSecurityGroupEgressInput) (*ec2.AuthorizeSecurityGroupEgressOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sg, err := e.securityGroup(in.GroupId)
+	if err != nil {
+		return nil, err
+	}
+	sg.IpPermissionsEgress = append(sg.IpPermissionsEgress, copyOf(in.IpPermissions).([]*ec2.IpPermission)...)
+	return &ec2.AuthorizeSecurityGroupEgressOutput{}, nil
+}
+
+func (e *EC2) AuthorizeSecurityGroupEgressWithContext(ctx aws.Context, in *ec2.AuthorizeSecurityGroupEgressInput, opts ...request.Option) (*ec2.AuthorizeSecurityGroupEgressOutput, error) {
+	return e.AuthorizeSecurityGroupEgress(in)
+}
+
+// RevokeSecurityGroupEgress removes outbound rules like RevokeSecurityGroupIngress removes inbound
+// ones.
+func (e *EC2) RevokeSecurityGroupEgress(in *ec2.RevokeSecurityGroupEgressInput) (*ec2.RevokeSecurityGroupEgressOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sg, err := e.securityGroup(in.GroupId)
+	if err != nil {
+		return nil, err
+	}
+	sg.IpPermissionsEgress = revokePermissions(sg.IpPermissionsEgress, in.IpPermissions)
+	return &ec2.RevokeSecurityGroupEgressOutput{}, nil
+}
+
+// revokePermissions returns permissions without the sources of revoked.
+func revokePermissions(permissions, revoked []*ec2.IpPermission) []*ec2.IpPermission {
+	var kept []*ec2.IpPermission
+	for _, p := range permissions {
+		for _, r := range revoked {
+			if aws.StringValue(p.IpProtocol) == aws.StringValue(r.IpProtocol) &&
+				aws.Int64Value(p.FromPort) == aws.Int64Value(r.FromPort) &&
+				aws.Int64Value(p.ToPort) == aws.Int64Value(r.ToPort) {
+				p = revokeSources(p, r)
+			}
+		}
+		if len(p.IpRanges)+len(p.Ipv6Ranges)+len(p.PrefixListIds)+len(p.UserIdGroupPairs) > 0 {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// revokeSources returns p without the sources of revoked.
+func revokeSources(p, revoked *ec2.IpPermission) *ec2.IpPermission {
+	out := &ec2.IpPermission{FromPort: p.FromPort, IpProtocol: p.IpProtocol, ToPort: p.ToPort}
+	var cidrs, cidrsIpv6, prefixLists, groups []*string
+	for _, r := range revoked.IpRanges {
+		cidrs = append(cidrs, r.CidrIp)
+	}
+	for _, r := range revoked.Ipv6Ranges {
+		cidrsIpv6 = append(cidrsIpv6, r.CidrIpv6)
+	}
+	for _, r := range revoked.PrefixListIds {
+		prefixLists = append(prefixLists, r.PrefixListId)
+	}
+	for _, r := range revoked.UserIdGroupPairs {
+		groups = append(groups, r.GroupId)
+	}
+	for _, r := range p.IpRanges {
+		if !contains(cidrs, r.CidrIp) {
+			out.IpRanges = append(out.IpRanges, r)
+		}
+	}
+	for _, r := range p.Ipv6Ranges {
+		if !contains(cidrsIpv6, r.CidrIpv6) {
+			out.Ipv6Ranges = append(out.Ipv6Ranges, r)
+		}
+	}
+	for _, r := range p.PrefixListIds {
+		if !contains(prefixLists, r.PrefixListId) {
+			out.PrefixListIds = append(out.PrefixListIds, r)
+		}
+	}
+	for _, r := range p.UserIdGroupPairs {
+		if !contains(groups, r.GroupId) {
+			out.UserIdGroupPairs = append(out.UserIdGroupPairs, r)
+		}
+	}
+	return out
+}
+
+// CreateTags adds or overwrites the tags of subnets and security groups.
+func (e *EC2) CreateTags(in *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, id := range in.Resources {
+		var tags *[]*ec2.Tag
+		if sg, ok := e.securityGroups[*id]; ok {
+			tags = &sg.Tags
+		} else if s, ok := e.subnets[*id]; ok {
+			tags = &s.Tags
+		} else {
+			return nil, awserr.New("InvalidID", fmt.Sprintf("The ID '%s' is not valid", *id), nil)
+		}
+	next:
+		for _, t := range in.Tags {
+			for _, current := range *tags {
+				if *current.Key == *t.Key {
+					current.Value = aws.String(aws.StringValue(t.Value))
+					continue next
+				}
+			}
+			*tags = append(*tags, copyOf(t).(*ec2.Tag))
+		}
+	}
+	return &ec2.CreateTagsOutput{}, nil
+}
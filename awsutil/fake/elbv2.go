@@ -0,0 +1,954 @@
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+)
+
+// Hosted zone of the DNS names of ALBs in us-east-1
+const canonicalHostedZoneID = "Z35SXDOTRQ7X7K"
+
+// sslPolicies are the predefined security policies of HTTPS listeners.
+var sslPolicies = []string{
+	"ELBSecurityPolicy-2016-08",
+	"ELBSecurityPolicy-TLS-1-1-2017-01",
+	"ELBSecurityPolicy-TLS-1-2-2017-01",
+	"ELBSecurityPolicy-TLS-1-2-Ext-2018-06",
+	"ELBSecurityPolicy-FS-2018-06",
+}
+
+// ELBV2 is an in-memory elbv2iface.ELBV2API. It also implements awsutil.ListenerCertificatesAPI.
+// Registered targets are healthy unless SetTargetHealth says otherwise. Parameters the awsutil
+// ELBV2 sends as request options, such as target types and redirect actions, aren't seen.
+type ELBV2 struct {
+	elbv2iface.ELBV2API
+
+	// AccountLimits are returned by DescribeAccountLimits, keyed by the awsutil Limit names
+	AccountLimits map[string]int64
+
+	mu            sync.Mutex
+	ids           ids
+	ec2           *EC2
+	loadBalancers map[string]*elbv2.LoadBalancer
+	lbAttributes  map[string][]*elbv2.LoadBalancerAttribute
+	listeners     map[string]*elbv2.Listener
+	certificates  map[string][]*elbv2.Certificate
+	rules         map[string]*elbv2.Rule
+	ruleListeners map[string]string
+	targetGroups  map[string]*elbv2.TargetGroup
+	tgAttributes  map[string][]*elbv2.TargetGroupAttribute
+	targets       map[string][]*elbv2.TargetHealthDescription
+	tags          map[string][]*elbv2.Tag
+}
+
+var _ awsutil.ListenerCertificatesAPI = &ELBV2{}
+
+// NewELBV2 returns an ELBV2 without any resources and with the default account limits. The VPCs
+// and availability zones of the subnets of new ALBs are looked up in ec2, when it's not nil.
+func NewELBV2(ec2 *EC2) *ELBV2 {
+	return &ELBV2{
+		AccountLimits: map[string]int64{
+			awsutil.LimitLoadBalancers:               20,
+			awsutil.LimitTargetGroups:                3000,
+			awsutil.LimitCertificatesPerLoadBalancer: 25,
+			awsutil.LimitListenersPerLoadBalancer:    50,
+			awsutil.LimitRulesPerLoadBalancer:        100,
+			awsutil.LimitTargetGroupsPerLoadBalancer: 100,
+			awsutil.LimitTargetsPerLoadBalancer:      1000,
+		},
+		ec2:           ec2,
+		loadBalancers: make(map[string]*elbv2.LoadBalancer),
+		lbAttributes:  make(map[string][]*elbv2.LoadBalancerAttribute),
+		listeners:     make(map[string]*elbv2.Listener),
+		certificates:  make(map[string][]*elbv2.Certificate),
+		rules:         make(map[string]*elbv2.Rule),
+		ruleListeners: make(map[string]string),
+		targetGroups:  make(map[string]*elbv2.TargetGroup),
+		tgAttributes:  make(map[string][]*elbv2.TargetGroupAttribute),
+		targets:       make(map[string][]*elbv2.TargetHealthDescription),
+		tags:          make(map[string][]*elbv2.Tag),
+	}
+}
+
+func (e *ELBV2) arn(resource, name string) string {
+	return fmt.Sprintf("arn:aws:elasticloadbalancing:%s:%s:%s/%s/%s", region, account, resource, name, e.ids.next())
+}
+
+// SetTargetHealth sets the state, e.g. unhealthy or draining, DescribeTargetHealth returns for the
+// target id of a target group.
+func (e *ELBV2) SetTargetHealth(targetGroupArn, id, state string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, t := range e.targets[targetGroupArn] {
+		if *t.Target.Id == id {
+			t.TargetHealth.State = aws.String(state)
+		}
+	}
+}
+
+// subnetZones returns the availability zones of subnets, along with their VPC.
+func (e *ELBV2) subnetZones(subnets []*string) ([]*elbv2.AvailabilityZone, *string) {
+	var zones []*elbv2.AvailabilityZone
+	var vpcID *string
+	for _, s := range subnets {
+		zone := &elbv2.AvailabilityZone{SubnetId: aws.String(*s)}
+		if subnet := e.ec2.subnet(*s); subnet != nil {
+			zone.ZoneName, vpcID = subnet.AvailabilityZone, subnet.VpcId
+		}
+		zones = append(zones, zone)
+	}
+	return zones, vpcID
+}
+
+func (e *ELBV2) CreateLoadBalancer(in *elbv2.CreateLoadBalancerInput) (*elbv2.CreateLoadBalancerOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, lb := range e.loadBalancers {
+		if *lb.LoadBalancerName == *in.Name {
+			return nil, awserr.New(elbv2.ErrCodeDuplicateLoadBalancerNameException, "A load balancer with the same name exists", nil)
+		}
+	}
+	if int64(len(e.loadBalancers)) >= e.AccountLimits[awsutil.LimitLoadBalancers] {
+		return nil, awserr.New(elbv2.ErrCodeTooManyLoadBalancersException, "The quota of load balancers was reached", nil)
+	}
+
+	arn := e.arn("loadbalancer/app", *in.Name)
+	zones, vpcID := e.subnetZones(in.Subnets)
+	now := time.Now()
+	lb := &elbv2.LoadBalancer{
+		AvailabilityZones:     zones,
+		CanonicalHostedZoneId: aws.String(canonicalHostedZoneID),
+		CreatedTime:           &now,
+		DNSName:               aws.String(fmt.Sprintf("%s-%s.%s.elb.amazonaws.com", *in.Name, arn[len(arn)-8:], region)),
+		IpAddressType:         in.IpAddressType,
+		LoadBalancerArn:       aws.String(arn),
+		LoadBalancerName:      in.Name,
+		Scheme:                in.Scheme,
+		SecurityGroups:        in.SecurityGroups,
+		State:                 &elbv2.LoadBalancerState{Code: aws.String(elbv2.LoadBalancerStateEnumActive)},
+		Type:                  aws.String("application"),
+		VpcId:                 vpcID,
+	}
+	if lb.IpAddressType == nil {
+		lb.IpAddressType = aws.String(elbv2.IpAddressTypeIpv4)
+	}
+	if lb.Scheme == nil {
+		lb.Scheme = aws.String(elbv2.LoadBalancerSchemeEnumInternetFacing)
+	}
+	lb = copyOf(lb).(*elbv2.LoadBalancer)
+	e.loadBalancers[arn] = lb
+	e.tags[arn] = copyOf(in.Tags).([]*elbv2.Tag)
+	return &elbv2.CreateLoadBalancerOutput{LoadBalancers: []*elbv2.LoadBalancer{copyOf(lb).(*elbv2.LoadBalancer)}}, nil
+}
+
+func (e *ELBV2) loadBalancer(arn *string) (*elbv2.LoadBalancer, error) {
+	lb, ok := e.loadBalancers[aws.StringValue(arn)]
+	if !ok {
+		return nil, notFound(elbv2.ErrCodeLoadBalancerNotFoundException, "Load balancer", aws.StringValue(arn))
+	}
+	return lb, nil
+}
+
+func (e *ELBV2) DescribeLoadBalancers(in *elbv2.DescribeLoadBalancersInput) (*elbv2.DescribeLoadBalancersOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := &elbv2.DescribeLoadBalancersOutput{}
+	for _, arn := range in.LoadBalancerArns {
+		if _, err := e.loadBalancer(arn); err != nil {
+			return nil, err
+		}
+	}
+	for _, lb := range e.loadBalancers {
+		if matches(in.LoadBalancerArns, lb.LoadBalancerArn) && matches(in.Names, lb.LoadBalancerName) {
+			out.LoadBalancers = append(out.LoadBalancers, copyOf(lb).(*elbv2.LoadBalancer))
+		}
+	}
+	if len(out.LoadBalancers) == 0 && len(in.Names) > 0 {
+		return nil, notFound(elbv2.ErrCodeLoadBalancerNotFoundException, "Load balancer", *in.Names[0])
+	}
+	sort.Slice(out.LoadBalancers, func(i, j int) bool {
+		return *out.LoadBalancers[i].LoadBalancerArn < *out.LoadBalancers[j].LoadBalancerArn
+	})
+	return out, nil
+}
+
+func (e *ELBV2) DeleteLoadBalancer(in *elbv2.DeleteLoadBalancerInput) (*elbv2.DeleteLoadBalancerOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.loadBalancers[*in.LoadBalancerArn]; !ok {
+		// Deleting an ALB that doesn't exist succeeds.
+		return &elbv2.DeleteLoadBalancerOutput{}, nil
+	}
+	for arn, l := range e.listeners {
+		if *l.LoadBalancerArn == *in.LoadBalancerArn {
+			e.deleteListener(arn)
+		}
+	}
+	delete(e.loadBalancers, *in.LoadBalancerArn)
+	delete(e.lbAttributes, *in.LoadBalancerArn)
+	delete(e.tags, *in.LoadBalancerArn)
+	return &elbv2.DeleteLoadBalancerOutput{}, nil
+}
+
+func (e *ELBV2) SetSecurityGroups(in *elbv2.SetSecurityGroupsInput) (*elbv2.SetSecurityGroupsOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	lb, err := e.loadBalancer(in.LoadBalancerArn)
+	if err != nil {
+		return nil, err
+	}
+	lb.SecurityGroups = copyOf(in.SecurityGroups).([]*string)
+	return &elbv2.SetSecurityGroupsOutput{SecurityGroupIds: copyOf(in.SecurityGroups).([]*string)}, nil
+}
+
+func (e *ELBV2) SetSubnets(in *elbv2.SetSubnetsInput) (*elbv2.SetSubnetsOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	lb, err := e.loadBalancer(in.LoadBalancerArn)
+	if err != nil {
+		return nil, err
+	}
+	lb.AvailabilityZones, _ = e.subnetZones(in.Subnets)
+	return &elbv2.SetSubnetsOutput{AvailabilityZones: copyOf(lb.AvailabilityZones).([]*elbv2.AvailabilityZone)}, nil
+}
+
+func (e *ELBV2) SetIpAddressType(in *elbv2.SetIpAddressTypeInput) (*elbv2.SetIpAddressTypeOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	lb, err := e.loadBalancer(in.LoadBalancerArn)
+	if err != nil {
+		return nil, err
+	}
+	lb.IpAddressType = aws.String(*in.IpAddressType)
+	return &elbv2.SetIpAddressTypeOutput{IpAddressType: aws.String(*in.IpAddressType)}, nil
+}
+
+func (e *ELBV2) ModifyLoadBalancerAttributes(in *elbv2.ModifyLoadBalancerAttributesInput) (*elbv2.ModifyLoadBalancerAttributesOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.loadBalancer(in.LoadBalancerArn); err != nil {
+		return nil, err
+	}
+	attributes := e.lbAttributes[*in.LoadBalancerArn]
+next:
+	for _, a := range in.Attributes {
+		for _, current := range attributes {
+			if *current.Key == *a.Key {
+				current.Value = aws.String(*a.Value)
+				continue next
+			}
+		}
+		attributes = append(attributes, copyOf(a).(*elbv2.LoadBalancerAttribute))
+	}
+	e.lbAttributes[*in.LoadBalancerArn] = attributes
+	return &elbv2.ModifyLoadBalancerAttributesOutput{Attributes: copyOf(attributes).([]*elbv2.LoadBalancerAttribute)}, nil
+}
+
+func (e *ELBV2) DescribeLoadBalancerAttributes(in *elbv2.DescribeLoadBalancerAttributesInput) (*elbv2.DescribeLoadBalancerAttributesOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.loadBalancer(in.LoadBalancerArn); err != nil {
+		return nil, err
+	}
+	return &elbv2.DescribeLoadBalancerAttributesOutput{Attributes: copyOf(e.lbAttributes[*in.LoadBalancerArn]).([]*elbv2.LoadBalancerAttribute)}, nil
+}
+
+func (e *ELBV2) DescribeAccountLimits(in *elbv2.DescribeAccountLimitsInput) (*elbv2.DescribeAccountLimitsOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := &elbv2.DescribeAccountLimitsOutput{}
+	for name, max := range e.AccountLimits {
+		out.Limits = append(out.Limits, &elbv2.Limit{Name: aws.String(name), Max: aws.String(strconv.FormatInt(max, 10))})
+	}
+	sort.Slice(out.Limits, func(i, j int) bool { return *out.Limits[i].Name < *out.Limits[j].Name })
+	return out, nil
+}
+
+func (e *ELBV2) DescribeSSLPolicies(in *elbv2.DescribeSSLPoliciesInput) (*elbv2.DescribeSSLPoliciesOutput, error) {
+	out := &elbv2.DescribeSSLPoliciesOutput{}
+	for _, name := range in.Names {
+		found := false
+		for _, policy := range sslPolicies {
+			if *name == policy {
+				out.SslPolicies = append(out.SslPolicies, &elbv2.SslPolicy{Name: aws.String(policy)})
+				found = true
+			}
+		}
+		if !found {
+			return nil, notFound(elbv2.ErrCodeSSLPolicyNotFoundException, "SSL policy", *name)
+		}
+	}
+	return out, nil
+}
+
+func (e *ELBV2) CreateListener(in *elbv2.CreateListenerInput) (*elbv2.CreateListenerOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	lb, err := e.loadBalancer(in.LoadBalancerArn)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range e.listeners {
+		if *l.LoadBalancerArn == *in.LoadBalancerArn && *l.Port == *in.Port {
+			return nil, awserr.New(elbv2.ErrCodeDuplicateListenerException, "A listener with the same port exists", nil)
+		}
+	}
+	if err := e.targetGroupsExist(in.DefaultActions); err != nil {
+		return nil, err
+	}
+
+	arn := e.arn("listener/app", *lb.LoadBalancerName+"/"+(*lb.LoadBalancerArn)[len(*lb.LoadBalancerArn)-16:])
+	l := &elbv2.Listener{
+		Certificates:    in.Certificates,
+		DefaultActions:  in.DefaultActions,
+		ListenerArn:     aws.String(arn),
+		LoadBalancerArn: in.LoadBalancerArn,
+		Port:            in.Port,
+		Protocol:        in.Protocol,
+		SslPolicy:       in.SslPolicy,
+	}
+	if *l.Protocol == elbv2.ProtocolEnumHttps && l.SslPolicy == nil {
+		l.SslPolicy = aws.String(sslPolicies[0])
+	}
+	l = copyOf(l).(*elbv2.Listener)
+	e.listeners[arn] = l
+
+	ruleArn := e.arn("listener-rule/app", *lb.LoadBalancerName)
+	e.rules[ruleArn] = &elbv2.Rule{
+		Actions:   copyOf(in.DefaultActions).([]*elbv2.Action),
+		IsDefault: aws.Bool(true),
+		Priority:  aws.String("default"),
+		RuleArn:   aws.String(ruleArn),
+	}
+	e.ruleListeners[ruleArn] = arn
+	return &elbv2.CreateListenerOutput{Listeners: []*elbv2.Listener{copyOf(l).(*elbv2.Listener)}}, nil
+}
+
+func (e *ELBV2) listener(arn *string) (*elbv2.Listener, error) {
+	l, ok := e.listeners[aws.StringValue(arn)]
+	if !ok {
+		return nil, notFound(elbv2.ErrCodeListenerNotFoundException, "Listener", aws.StringValue(arn))
+	}
+	return l, nil
+}
+
+func (e *ELBV2) ModifyListener(in *elbv2.ModifyListenerInput) (*elbv2.ModifyListenerOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	l, err := e.listener(in.ListenerArn)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.targetGroupsExist(in.DefaultActions); err != nil {
+		return nil, err
+	}
+	if in.Certificates != nil {
+		l.Certificates = copyOf(in.Certificates).([]*elbv2.Certificate)
+	}
+	if in.DefaultActions != nil {
+		l.DefaultActions = copyOf(in.DefaultActions).([]*elbv2.Action)
+		for arn, listenerArn := range e.ruleListeners {
+			if listenerArn == *in.ListenerArn && *e.rules[arn].IsDefault {
+				e.rules[arn].Actions = copyOf(in.DefaultActions).([]*elbv2.Action)
+			}
+		}
+	}
+	if in.Port != nil {
+		l.Port = aws.Int64(*in.Port)
+	}
+	if in.Protocol != nil {
+		l.Protocol = aws.String(*in.Protocol)
+	}
+	if in.SslPolicy != nil {
+		l.SslPolicy = aws.String(*in.SslPolicy)
+	}
+	return &elbv2.ModifyListenerOutput{Listeners: []*elbv2.Listener{copyOf(l).(*elbv2.Listener)}}, nil
+}
+
+func (e *ELBV2) ModifyListenerWithContext(ctx aws.Context, in *elbv2.ModifyListenerInput, opts ...request.Option) (*elbv2.ModifyListenerOutput, error) {
+	return e.ModifyListener(in)
+}
+
+func (e *ELBV2) DescribeListeners(in *elbv2.DescribeListenersInput) (*elbv2.DescribeListenersOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if in.LoadBalancerArn != nil {
+		if _, err := e.loadBalancer(in.LoadBalancerArn); err != nil {
+			return nil, err
+		}
+	}
+	out := &elbv2.DescribeListenersOutput{}
+	for _, l := range e.listeners {
+		if (in.LoadBalancerArn == nil || *l.LoadBalancerArn == *in.LoadBalancerArn) && matches(in.ListenerArns, l.ListenerArn) {
+			out.Listeners = append(out.Listeners, copyOf(l).(*elbv2.Listener))
+		}
+	}
+	sort.Slice(out.Listeners, func(i, j int) bool { return *out.Listeners[i].ListenerArn < *out.Listeners[j].ListenerArn })
+	return out, nil
+}
+
+func (e *ELBV2) DeleteListener(in *elbv2.DeleteListenerInput) (*elbv2.DeleteListenerOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.listener(in.ListenerArn); err != nil {
+		return nil, err
+	}
+	e.deleteListener(*in.ListenerArn)
+	return &elbv2.DeleteListenerOutput{}, nil
+}
+
+// deleteListener deletes a listener along with its rules and certificates.
+func (e *ELBV2) deleteListener(arn string) {
+	for ruleArn, listenerArn := range e.ruleListeners {
+		if listenerArn == arn {
+			delete(e.rules, ruleArn)
+			delete(e.ruleListeners, ruleArn)
+		}
+	}
+	delete(e.listeners, arn)
+	delete(e.certificates, arn)
+}
+
+func (e *ELBV2) AddListenerCertificates(listenerArn *string, certificates []*elbv2.Certificate) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.listener(listenerArn); err != nil {
+		return err
+	}
+next:
+	for _, c := range certificates {
+		for _, current := range e.certificates[*listenerArn] {
+			if *current.CertificateArn == *c.CertificateArn {
+				continue next
+			}
+		}
+		e.certificates[*listenerArn] = append(e.certificates[*listenerArn], copyOf(c).(*elbv2.Certificate))
+	}
+	return nil
+}
+
+func (e *ELBV2) RemoveListenerCertificates(listenerArn *string, certificates []*elbv2.Certificate) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.listener(listenerArn); err != nil {
+		return err
+	}
+	var kept []*elbv2.Certificate
+next:
+	for _, current := range e.certificates[*listenerArn] {
+		for _, c := range certificates {
+			if *current.CertificateArn == *c.CertificateArn {
+				continue next
+			}
+		}
+		kept = append(kept, current)
+	}
+	e.certificates[*listenerArn] = kept
+	return nil
+}
+
+func (e *ELBV2) DescribeListenerCertificates(listenerArn *string) ([]*elbv2.Certificate, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.listener(listenerArn); err != nil {
+		return nil, err
+	}
+	return copyOf(e.certificates[*listenerArn]).([]*elbv2.Certificate), nil
+}
+
+func (e *ELBV2) CreateRule(in *elbv2.CreateRuleInput) (*elbv2.CreateRuleOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	l, err := e.listener(in.ListenerArn)
+	if err != nil {
+		return nil, err
+	}
+	priority := strconv.FormatInt(*in.Priority, 10)
+	for arn, listenerArn := range e.ruleListeners {
+		if listenerArn == *in.ListenerArn && *e.rules[arn].Priority == priority {
+			return nil, awserr.New(elbv2.ErrCodePriorityInUseException, fmt.Sprintf("Priority '%s' is currently in use", priority), nil)
+		}
+	}
+	if err := e.targetGroupsExist(in.Actions); err != nil {
+		return nil, err
+	}
+
+	lb := e.loadBalancers[*l.LoadBalancerArn]
+	arn := e.arn("listener-rule/app", *lb.LoadBalancerName)
+	r := &elbv2.Rule{
+		Actions:    copyOf(in.Actions).([]*elbv2.Action),
+		Conditions: copyOf(in.Conditions).([]*elbv2.RuleCondition),
+		IsDefault:  aws.Bool(false),
+		Priority:   aws.String(priority),
+		RuleArn:    aws.String(arn),
+	}
+	e.rules[arn] = r
+	e.ruleListeners[arn] = *in.ListenerArn
+	return &elbv2.CreateRuleOutput{Rules: []*elbv2.Rule{copyOf(r).(*elbv2.Rule)}}, nil
+}
+
+func (e *ELBV2) CreateRuleWithContext(ctx aws.Context, in *elbv2.CreateRuleInput, opts ...request.Option) (*elbv2.CreateRuleOutput, error) {
+	return e.CreateRule(in)
+}
+
+func (e *ELBV2) ModifyRule(in *elbv2.ModifyRuleInput) (*elbv2.ModifyRuleOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	r, ok := e.rules[aws.StringValue(in.RuleArn)]
+	if !ok {
+		return nil, notFound(elbv2.ErrCodeRuleNotFoundException, "Rule", aws.StringValue(in.RuleArn))
+	}
+	if err := e.targetGroupsExist(in.Actions); err != nil {
+		return nil, err
+	}
+	if in.Actions != nil {
+		r.Actions = copyOf(in.Actions).([]*elbv2.Action)
+	}
+	if in.Conditions != nil {
+		r.Conditions = copyOf(in.Conditions).([]*elbv2.RuleCondition)
+	}
+	return &elbv2.ModifyRuleOutput{Rules: []*elbv2.Rule{copyOf(r).(*elbv2.Rule)}}, nil
+}
+
+func (e *ELBV2) ModifyRuleWithContext(ctx aws.Context, in *elbv2.ModifyRuleInput, opts ...request.Option) (*elbv2.ModifyRuleOutput, error) {
+	return e.ModifyRule(in)
+}
+
+// SetRulePriorities applies all priorities of in, or none of them when one would be shared by two
+// rules of a listener.
+func (e *ELBV2) SetRulePriorities(in *elbv2.SetRulePrioritiesInput) (*elbv2.SetRulePrioritiesOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	priorities := make(map[string]string)
+	for _, p := range in.RulePriorities {
+		r, ok := e.rules[aws.StringValue(p.RuleArn)]
+		if !ok {
+			return nil, notFound(elbv2.ErrCodeRuleNotFoundException, "Rule", aws.StringValue(p.RuleArn))
+		}
+		if *r.IsDefault {
+			return nil, awserr.New(elbv2.ErrCodeOperationNotPermittedException, "Default rule priority cannot be modified", nil)
+		}
+		priorities[*p.RuleArn] = strconv.FormatInt(*p.Priority, 10)
+	}
+	used := make(map[string]bool)
+	for arn, r := range e.rules {
+		if *r.IsDefault {
+			continue
+		}
+		priority, ok := priorities[arn]
+		if !ok {
+			priority = *r.Priority
+		}
+		key := e.ruleListeners[arn] + " " + priority
+		if used[key] {
+			return nil, awserr.New(elbv2.ErrCodePriorityInUseException, fmt.Sprintf("Priority '%s' is currently in use", priority), nil)
+		}
+		used[key] = true
+	}
+	out := &elbv2.SetRulePrioritiesOutput{}
+	for arn, priority := range priorities {
+		e.rules[arn].Priority = aws.String(priority)
+		out.Rules = append(out.Rules, copyOf(e.rules[arn]).(*elbv2.Rule))
+	}
+	return out, nil
+}
+
+// DescribeRules returns the rules of a listener in order of priority, the default rule last.
+func (e *ELBV2) DescribeRules(in *elbv2.DescribeRulesInput) (*elbv2.DescribeRulesOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if in.ListenerArn != nil {
+		if _, err := e.listener(in.ListenerArn); err != nil {
+			return nil, err
+		}
+	}
+	out := &elbv2.DescribeRulesOutput{}
+	for arn, r := range e.rules {
+		if (in.ListenerArn == nil || e.ruleListeners[arn] == *in.ListenerArn) && matches(in.RuleArns, r.RuleArn) {
+			out.Rules = append(out.Rules, copyOf(r).(*elbv2.Rule))
+		}
+	}
+	sort.Slice(out.Rules, func(i, j int) bool {
+		if *out.Rules[i].IsDefault || *out.Rules[j].IsDefault {
+			return *out.Rules[j].IsDefault
+		}
+		pi, _ := strconv.Atoi(*out.Rules[i].Priority)
+		pj, _ := strconv.Atoi(*out.Rules[j].Priority)
+		return pi < pj
+	})
+	return out, nil
+}
+
+func (e *ELBV2) DeleteRule(in *elbv2.DeleteRuleInput) (*elbv2.DeleteRuleOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	r, ok := e.rules[aws.StringValue(in.RuleArn)]
+	if !ok {
+		return nil, notFound(elbv2.ErrCodeRuleNotFoundException, "Rule", aws.StringValue(in.RuleArn))
+	}
+	if *r.IsDefault {
+		return nil, awserr.New(elbv2.ErrCodeOperationNotPermittedException, "Default rules cannot be deleted", nil)
+	}
+	delete(e.rules, *in.RuleArn)
+	delete(e.ruleListeners, *in.RuleArn)
+	return &elbv2.DeleteRuleOutput{}, nil
+}
+
+// targetGroupsExist returns an error when actions forward to a target group that doesn't exist.
+func (e *ELBV2) targetGroupsExist(actions []*elbv2.Action) error {
+	for _, a := range actions {
+		if a.TargetGroupArn == nil {
+			continue
+		}
+		if _, err := e.targetGroup(a.TargetGroupArn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *ELBV2) CreateTargetGroup(in *elbv2.CreateTargetGroupInput) (*elbv2.CreateTargetGroupOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, tg := range e.targetGroups {
+		if *tg.TargetGroupName == *in.Name {
+			return nil, awserr.New(elbv2.ErrCodeDuplicateTargetGroupNameException, "A target group with the same name exists", nil)
+		}
+	}
+	if int64(len(e.targetGroups)) >= e.AccountLimits[awsutil.LimitTargetGroups] {
+		return nil, awserr.New(elbv2.ErrCodeTooManyTargetGroupsException, "The quota of target groups was reached", nil)
+	}
+
+	arn := e.arn("targetgroup", *in.Name)
+	tg := &elbv2.TargetGroup{
+		HealthCheckIntervalSeconds: in.HealthCheckIntervalSeconds,
+		HealthCheckPath:            in.HealthCheckPath,
+		HealthCheckPort:            in.HealthCheckPort,
+		HealthCheckProtocol:        in.HealthCheckProtocol,
+		HealthCheckTimeoutSeconds:  in.HealthCheckTimeoutSeconds,
+		HealthyThresholdCount:      in.HealthyThresholdCount,
+		Matcher:                    in.Matcher,
+		Port:                       in.Port,
+		Protocol:                   in.Protocol,
+		TargetGroupArn:             aws.String(arn),
+		TargetGroupName:            in.Name,
+		UnhealthyThresholdCount:    in.UnhealthyThresholdCount,
+		VpcId:                      in.VpcId,
+	}
+	tg = copyOf(tg).(*elbv2.TargetGroup)
+	e.targetGroups[arn] = tg
+	return &elbv2.CreateTargetGroupOutput{TargetGroups: []*elbv2.TargetGroup{e.describeTargetGroup(tg)}}, nil
+}
+
+func (e *ELBV2) CreateTargetGroupWithContext(ctx aws.Context, in *elbv2.CreateTargetGroupInput, opts ...request.Option) (*elbv2.CreateTargetGroupOutput, error) {
+	return e.CreateTargetGroup(in)
+}
+
+func (e *ELBV2) targetGroup(arn *string) (*elbv2.TargetGroup, error) {
+	tg, ok := e.targetGroups[aws.StringValue(arn)]
+	if !ok {
+		return nil, notFound(elbv2.ErrCodeTargetGroupNotFoundException, "Target group", aws.StringValue(arn))
+	}
+	return tg, nil
+}
+
+// describeTargetGroup returns a copy of tg with the ALBs forwarding to it.
+func (e *ELBV2) describeTargetGroup(tg *elbv2.TargetGroup) *elbv2.TargetGroup {
+	out := copyOf(tg).(*elbv2.TargetGroup)
+	out.LoadBalancerArns = nil
+	for _, arn := range e.targetGroupUsers(*tg.TargetGroupArn) {
+		out.LoadBalancerArns = append(out.LoadBalancerArns, aws.String(arn))
+	}
+	return out
+}
+
+// targetGroupUsers returns the ARNs of the ALBs with a listener or rule forwarding to the target
+// group arn.
+func (e *ELBV2) targetGroupUsers(arn string) []string {
+	users := make(map[string]bool)
+	for ruleArn, r := range e.rules {
+		for _, a := range r.Actions {
+			if aws.StringValue(a.TargetGroupArn) == arn {
+				users[*e.listeners[e.ruleListeners[ruleArn]].LoadBalancerArn] = true
+			}
+		}
+	}
+	var arns []string
+	for user := range users {
+		arns = append(arns, user)
+	}
+	sort.Strings(arns)
+	return arns
+}
+
+func (e *ELBV2) DescribeTargetGroups(in *elbv2.DescribeTargetGroupsInput) (*elbv2.DescribeTargetGroupsOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, arn := range in.TargetGroupArns {
+		if _, err := e.targetGroup(arn); err != nil {
+			return nil, err
+		}
+	}
+	out := &elbv2.DescribeTargetGroupsOutput{}
+	for _, tg := range e.targetGroups {
+		if !matches(in.TargetGroupArns, tg.TargetGroupArn) || !matches(in.Names, tg.TargetGroupName) {
+			continue
+		}
+		described := e.describeTargetGroup(tg)
+		if in.LoadBalancerArn != nil && !contains(described.LoadBalancerArns, in.LoadBalancerArn) {
+			continue
+		}
+		out.TargetGroups = append(out.TargetGroups, described)
+	}
+	sort.Slice(out.TargetGroups, func(i, j int) bool { return *out.TargetGroups[i].TargetGroupArn < *out.TargetGroups[j].TargetGroupArn })
+	return out, nil
+}
+
+func (e *ELBV2) ModifyTargetGroup(in *elbv2.ModifyTargetGroupInput) (*elbv2.ModifyTargetGroupOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	tg, err := e.targetGroup(in.TargetGroupArn)
+	if err != nil {
+		return nil, err
+	}
+	modified := copyOf(tg).(*elbv2.TargetGroup)
+	modifyHealthCheck(modified, in)
+	e.targetGroups[*in.TargetGroupArn] = modified
+	return &elbv2.ModifyTargetGroupOutput{TargetGroups: []*elbv2.TargetGroup{e.describeTargetGroup(modified)}}, nil
+}
+
+// modifyHealthCheck changes the health check settings of tg that in sets.
+func modifyHealthCheck(tg *elbv2.TargetGroup, in *elbv2.ModifyTargetGroupInput) {
+	if in.HealthCheckIntervalSeconds != nil {
+		tg.HealthCheckIntervalSeconds = aws.Int64(*in.HealthCheckIntervalSeconds)
+	}
+	if in.HealthCheckPath != nil {
+		tg.HealthCheckPath = aws.String(*in.HealthCheckPath)
+	}
+	if in.HealthCheckPort != nil {
+		tg.HealthCheckPort = aws.String(*in.HealthCheckPort)
+	}
+	if in.HealthCheckProtocol != nil {
+		tg.HealthCheckProtocol = aws.String(*in.HealthCheckProtocol)
+	}
+	if in.HealthCheckTimeoutSeconds != nil {
+		tg.HealthCheckTimeoutSeconds = aws.Int64(*in.HealthCheckTimeoutSeconds)
+	}
+	if in.HealthyThresholdCount != nil {
+		tg.HealthyThresholdCount = aws.Int64(*in.HealthyThresholdCount)
+	}
+	if in.Matcher != nil {
+		tg.Matcher = copyOf(in.Matcher).(*elbv2.Matcher)
+	}
+	if in.UnhealthyThresholdCount != nil {
+		tg.UnhealthyThresholdCount = aws.Int64(*in.UnhealthyThresholdCount)
+	}
+}
+
+func (e *ELBV2) DeleteTargetGroup(in *elbv2.DeleteTargetGroupInput) (*elbv2.DeleteTargetGroupOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.targetGroups[*in.TargetGroupArn]; !ok {
+		// Deleting a target group that doesn't exist succeeds.
+		return &elbv2.DeleteTargetGroupOutput{}, nil
+	}
+	if len(e.targetGroupUsers(*in.TargetGroupArn)) > 0 {
+		return nil, awserr.New(elbv2.ErrCodeResourceInUseException, fmt.Sprintf("Target group '%s' is currently in use by a listener or a rule", *in.TargetGroupArn), nil)
+	}
+	delete(e.targetGroups, *in.TargetGroupArn)
+	delete(e.tgAttributes, *in.TargetGroupArn)
+	delete(e.targets, *in.TargetGroupArn)
+	delete(e.tags, *in.TargetGroupArn)
+	return &elbv2.DeleteTargetGroupOutput{}, nil
+}
+
+func (e *ELBV2) ModifyTargetGroupAttributes(in *elbv2.ModifyTargetGroupAttributesInput) (*elbv2.ModifyTargetGroupAttributesOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.targetGroup(in.TargetGroupArn); err != nil {
+		return nil, err
+	}
+	attributes := e.tgAttributes[*in.TargetGroupArn]
+next:
+	for _, a := range in.Attributes {
+		for _, current := range attributes {
+			if *current.Key == *a.Key {
+				current.Value = aws.String(*a.Value)
+				continue next
+			}
+		}
+		attributes = append(attributes, copyOf(a).(*elbv2.TargetGroupAttribute))
+	}
+	e.tgAttributes[*in.TargetGroupArn] = attributes
+	return &elbv2.ModifyTargetGroupAttributesOutput{Attributes: copyOf(attributes).([]*elbv2.TargetGroupAttribute)}, nil
+}
+
+func (e *ELBV2) DescribeTargetGroupAttributes(in *elbv2.DescribeTargetGroupAttributesInput) (*elbv2.DescribeTargetGroupAttributesOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.targetGroup(in.TargetGroupArn); err != nil {
+		return nil, err
+	}
+	return &elbv2.DescribeTargetGroupAttributesOutput{Attributes: copyOf(e.tgAttributes[*in.TargetGroupArn]).([]*elbv2.TargetGroupAttribute)}, nil
+}
+
+func (e *ELBV2) RegisterTargets(in *elbv2.RegisterTargetsInput) (*elbv2.RegisterTargetsOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	tg, err := e.targetGroup(in.TargetGroupArn)
+	if err != nil {
+		return nil, err
+	}
+next:
+	for _, t := range in.Targets {
+		target := copyOf(t).(*elbv2.TargetDescription)
+		if target.Port == nil {
+			target.Port = aws.Int64(*tg.Port)
+		}
+		for _, current := range e.targets[*in.TargetGroupArn] {
+			if *current.Target.Id == *target.Id && *current.Target.Port == *target.Port {
+				continue next
+			}
+		}
+		e.targets[*in.TargetGroupArn] = append(e.targets[*in.TargetGroupArn], &elbv2.TargetHealthDescription{
+			HealthCheckPort: aws.String(strconv.FormatInt(*target.Port, 10)),
+			Target:          target,
+			TargetHealth:    &elbv2.TargetHealth{State: aws.String(elbv2.TargetHealthStateEnumHealthy)},
+		})
+	}
+	return &elbv2.RegisterTargetsOutput{}, nil
+}
+
+func (e *ELBV2) RegisterTargetsWithContext(ctx aws.Context, in *elbv2.RegisterTargetsInput, opts ...request.Option) (*elbv2.RegisterTargetsOutput, error) {
+	return e.RegisterTargets(in)
+}
+
+func (e *ELBV2) DeregisterTargets(in *elbv2.DeregisterTargetsInput) (*elbv2.DeregisterTargetsOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.targetGroup(in.TargetGroupArn); err != nil {
+		return nil, err
+	}
+	var kept []*elbv2.TargetHealthDescription
+next:
+	for _, current := range e.targets[*in.TargetGroupArn] {
+		for _, t := range in.Targets {
+			if *current.Target.Id == *t.Id && (t.Port == nil || *current.Target.Port == *t.Port) {
+				continue next
+			}
+		}
+		kept = append(kept, current)
+	}
+	e.targets[*in.TargetGroupArn] = kept
+	return &elbv2.DeregisterTargetsOutput{}, nil
+}
+
+func (e *ELBV2) DeregisterTargetsWithContext(ctx aws.Context, in *elbv2.DeregisterTargetsInput, opts ...request.Option) (*elbv2.DeregisterTargetsOutput, error) {
+	return e.DeregisterTargets(in)
+}
+
+func (e *ELBV2) DescribeTargetHealth(in *elbv2.DescribeTargetHealthInput) (*elbv2.DescribeTargetHealthOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.targetGroup(in.TargetGroupArn); err != nil {
+		return nil, err
+	}
+	out := &elbv2.DescribeTargetHealthOutput{}
+	for _, t := range e.targets[*in.TargetGroupArn] {
+		if !matches(targetIDs(in.Targets), t.Target.Id) {
+			continue
+		}
+		out.TargetHealthDescriptions = append(out.TargetHealthDescriptions, copyOf(t).(*elbv2.TargetHealthDescription))
+	}
+	return out, nil
+}
+
+func targetIDs(targets []*elbv2.TargetDescription) []*string {
+	var ids []*string
+	for _, t := range targets {
+		ids = append(ids, t.Id)
+	}
+	return ids
+}
+
+// resourceExists returns an error when there's no ALB or target group with the ARN arn.
+func (e *ELBV2) resourceExists(arn *string) error {
+	if _, ok := e.loadBalancers[aws.StringValue(arn)]; ok {
+		return nil
+	}
+	if _, ok := e.targetGroups[aws.StringValue(arn)]; ok {
+		return nil
+	}
+	return notFound(elbv2.ErrCodeLoadBalancerNotFoundException, "Resource", aws.StringValue(arn))
+}
+
+func (e *ELBV2) AddTags(in *elbv2.AddTagsInput) (*elbv2.AddTagsOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, arn := range in.ResourceArns {
+		if err := e.resourceExists(arn); err != nil {
+			return nil, err
+		}
+	}
+	for _, arn := range in.ResourceArns {
+		tags := e.tags[*arn]
+	next:
+		for _, t := range in.Tags {
+			for _, current := range tags {
+				if *current.Key == *t.Key {
+					current.Value = aws.String(aws.StringValue(t.Value))
+					continue next
+				}
+			}
+			tags = append(tags, copyOf(t).(*elbv2.Tag))
+		}
+		e.tags[*arn] = tags
+	}
+	return &elbv2.AddTagsOutput{}, nil
+}
+
+func (e *ELBV2) RemoveTags(in *elbv2.RemoveTagsInput) (*elbv2.RemoveTagsOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, arn := range in.ResourceArns {
+		if err := e.resourceExists(arn); err != nil {
+			return nil, err
+		}
+	}
+	for _, arn := range in.ResourceArns {
+		var kept []*elbv2.Tag
+		for _, current := range e.tags[*arn] {
+			if !contains(in.TagKeys, current.Key) {
+				kept = append(kept, current)
+			}
+		}
+		e.tags[*arn] = kept
+	}
+	return &elbv2.RemoveTagsOutput{}, nil
+}
+
+func (e *ELBV2) DescribeTags(in *elbv2.DescribeTagsInput) (*elbv2.DescribeTagsOutput, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := &elbv2.DescribeTagsOutput{}
+	for _, arn := range in.ResourceArns {
+		if err := e.resourceExists(arn); err != nil {
+			return nil, err
+		}
+		out.TagDescriptions = append(out.TagDescriptions, &elbv2.TagDescription{
+			ResourceArn: aws.String(*arn),
+			Tags:        copyOf(e.tags[*arn]).([]*elbv2.Tag),
+		})
+	}
+	return out, nil
+}
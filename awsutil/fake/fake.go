@@ -0,0 +1,166 @@
+// Package fake provides in-memory implementations of the AWS APIs called by the controller, so the
+// awsutil clients, and the reconciliation of ingresses through them, can be tested without AWS
+// credentials.
+//
+// The fakes keep the resources they're asked to create and describe them back like AWS does, but
+// don't validate input beyond what the controller relies on. Their methods are safe for concurrent
+// use. Methods of the SDK interfaces the controller doesn't call panic.
+//
+//	clients := fake.New()
+//	defer clients.Install()()
+//	clients.EC2.AddSubnet("subnet-1", "vpc-1", "us-east-1a", map[string]string{"Name": "public"})
+package fake
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	sdkutil "github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+)
+
+const (
+	// Region of the resources of the fakes
+	region = "us-east-1"
+	// AWS account of the resources of the fakes
+	account = "123456789012"
+)
+
+// Clients are fakes of every AWS API the controller calls. The ELBV2 fake looks up the subnets of
+// new ALBs in the EC2 one, and the Tagging fake finds the tags of ALBs and target groups in the
+// ELBV2 one.
+type Clients struct {
+	ELBV2             *ELBV2
+	EC2               *EC2
+	Route53           *Route53
+	ACM               *ACM
+	IAM               *IAM
+	WAFRegional       *WAFRegional
+	GlobalAccelerator *GlobalAccelerator
+	Tagging           *Tagging
+	SQS               *SQS
+	AutoScaling       *AutoScaling
+	CloudWatch        *CloudWatch
+	S3                *S3
+}
+
+// New returns Clients without any resources.
+func New() *Clients {
+	ec2 := NewEC2()
+	elbv2 := NewELBV2(ec2)
+	return &Clients{
+		ELBV2:             elbv2,
+		EC2:               ec2,
+		Route53:           NewRoute53(),
+		ACM:               NewACM(),
+		IAM:               NewIAM(),
+		WAFRegional:       NewWAFRegional(),
+		GlobalAccelerator: NewGlobalAccelerator(),
+		Tagging:           NewTagging(elbv2),
+		SQS:               NewSQS(),
+		AutoScaling:       NewAutoScaling(),
+		CloudWatch:        NewCloudWatch(),
+		S3:                NewS3(),
+	}
+}
+
+// Install swaps the awsutil clients for ones calling the fakes until the returned function is
+// called. The ALBs and target groups of the cluster are found through the Tagging fake when
+// awsutil.ALBsvc used the Resource Groups Tagging API before. Namespace IAM roles are assumed
+// without swapping the clients, so the ingresses of namespaces annotated with one call the fakes
+// too.
+func (c *Clients) Install() func() {
+	albsvc, ec2svc, route53svc, acmsvc, iamsvc := awsutil.ALBsvc, awsutil.Ec2svc, awsutil.Route53svc, awsutil.ACMsvc, awsutil.IAMsvc
+	wafsvc, sqssvc, autoscalingsvc, cloudwatchsvc, s3svc := awsutil.WAFsvc, awsutil.SQSsvc, awsutil.AutoScalingsvc, awsutil.CloudWatchsvc, awsutil.S3svc
+	gasvc := awsutil.GlobalAcceleratorsvc
+	awsutil.ALBsvc = awsutil.NewELBV2WithClient(c.ELBV2)
+	if albsvc != nil && albsvc.Tagging != nil {
+		awsutil.ALBsvc.Tagging = awsutil.NewTaggingWithClient(c.Tagging.client())
+	}
+	awsutil.Ec2svc = awsutil.NewEC2WithClient(c.EC2)
+	awsutil.Route53svc = awsutil.NewRoute53WithClient(c.Route53)
+	awsutil.ACMsvc = awsutil.NewACMWithClient(c.ACM)
+	awsutil.ACMsvc.Requests = awsutil.NewACMRequestsWithClient(c.ACM.client())
+	awsutil.IAMsvc = awsutil.NewIAMWithClient(c.IAM)
+	awsutil.WAFsvc = awsutil.NewWAFRegionalWithClient(c.WAFRegional.client())
+	awsutil.GlobalAcceleratorsvc = awsutil.NewGlobalAcceleratorWithClient(c.GlobalAccelerator.client())
+	awsutil.SQSsvc = awsutil.NewSQSWithClient(c.SQS.client())
+	awsutil.AutoScalingsvc = awsutil.NewAutoScalingWithClient(c.AutoScaling.client())
+	awsutil.CloudWatchsvc = awsutil.NewCloudWatchWithClient(c.CloudWatch.client())
+	awsutil.S3svc = awsutil.NewS3WithClient(c.S3.client())
+	restoreRoles := awsutil.KeepClientsForRoles()
+	return func() {
+		restoreRoles()
+		awsutil.ALBsvc, awsutil.Ec2svc, awsutil.Route53svc, awsutil.ACMsvc, awsutil.IAMsvc = albsvc, ec2svc, route53svc, acmsvc, iamsvc
+		awsutil.WAFsvc, awsutil.SQSsvc, awsutil.AutoScalingsvc, awsutil.CloudWatchsvc, awsutil.S3svc = wafsvc, sqssvc, autoscalingsvc, cloudwatchsvc, s3svc
+		awsutil.GlobalAcceleratorsvc = gasvc
+	}
+}
+
+// newClient returns a client of the AWS API service, for the awsutil clients the vendored
+// aws-sdk-go has no client for. Its requests aren't sent, serve is called with the name, input and
+// output of their operation instead, and the error serve returns is the one of the request.
+func newClient(service string, serve func(operation string, in, out interface{}) error) *client.Client {
+	handlers := request.Handlers{}
+	handlers.Send.PushBack(func(r *request.Request) {
+		r.Error = serve(r.Operation.Name, r.Params, r.Data)
+	})
+	return client.New(aws.Config{Region: aws.String(region), MaxRetries: aws.Int(0)},
+		metadata.ClientInfo{ServiceName: service}, handlers)
+}
+
+// field returns the field name of the input or output structure v, for the ones of the awsutil
+// clients, which aren't exported.
+func field(v interface{}, name string) reflect.Value {
+	return reflect.ValueOf(v).Elem().FieldByName(name)
+}
+
+// stringField returns the string field name of the input structure in.
+func stringField(in interface{}, name string) *string {
+	s, _ := field(in, name).Interface().(*string)
+	return s
+}
+
+// ids hands out the unique identifiers of the resources of a fake, in increasing order so resources
+// sorted by identifier are in order of creation.
+type ids int64
+
+func (i *ids) next() string {
+	*i++
+	return fmt.Sprintf("%016x", int64(*i))
+}
+
+// copyOf returns a deep copy of v, so callers changing the resources they're given or were
+// described don't change the state of the fakes.
+func copyOf(v interface{}) interface{} {
+	src, dst := reflect.New(reflect.TypeOf(v)), reflect.New(reflect.TypeOf(v))
+	src.Elem().Set(reflect.ValueOf(v))
+	sdkutil.Copy(dst.Interface(), src.Interface())
+	return dst.Elem().Interface()
+}
+
+// notFound returns the error AWS responds with when a resource doesn't exist.
+func notFound(code, kind, id string) error {
+	return awserr.New(code, fmt.Sprintf("%s '%s' not found", kind, id), nil)
+}
+
+// matches returns true when s is one of values, or values is empty, like AWS treats the lists of
+// identifiers of describe calls.
+func matches(values []*string, s *string) bool {
+	return len(values) == 0 || contains(values, s)
+}
+
+// contains returns true when s is one of values.
+func contains(values []*string, s *string) bool {
+	for _, v := range values {
+		if aws.StringValue(v) == aws.StringValue(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,469 @@
+package fake
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/controller/util"
+)
+
+func TestELBV2(t *testing.T) {
+	clients := New()
+	defer clients.Install()()
+	clients.EC2.AddSubnet("subnet-1", "vpc-1", "us-east-1a", nil)
+	clients.EC2.AddSubnet("subnet-2", "vpc-1", "us-east-1b", nil)
+
+	lb, err := awsutil.ALBsvc.Create(elbv2.CreateLoadBalancerInput{
+		Name:    aws.String("cluster-0123456789"),
+		Subnets: aws.StringSlice([]string{"subnet-1", "subnet-2"}),
+		Tags:    []*elbv2.Tag{{Key: aws.String(util.ClusterNameTag), Value: aws.String("cluster")}},
+	})
+	if err != nil {
+		t.Fatalf("Create returned error %v", err)
+	}
+	if *lb.VpcId != "vpc-1" || *lb.AvailabilityZones[1].ZoneName != "us-east-1b" {
+		t.Errorf("Create returned an ALB in %v of %v, expected us-east-1b of vpc-1", *lb.AvailabilityZones[1].ZoneName, *lb.VpcId)
+	}
+
+	tg, err := awsutil.ALBsvc.AddTargetGroup(elbv2.CreateTargetGroupInput{
+		Name:     aws.String("cluster-80-HTTP-0123456"),
+		Port:     aws.Int64(30080),
+		Protocol: aws.String(elbv2.ProtocolEnumHttp),
+		VpcId:    lb.VpcId,
+	}, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("AddTargetGroup returned error %v", err)
+	}
+	listener, err := awsutil.ALBsvc.AddListener(elbv2.CreateListenerInput{
+		LoadBalancerArn: lb.LoadBalancerArn,
+		Port:            aws.Int64(80),
+		Protocol:        aws.String(elbv2.ProtocolEnumHttp),
+		DefaultActions:  []*elbv2.Action{{Type: aws.String(elbv2.ActionTypeEnumForward), TargetGroupArn: tg.TargetGroupArn}},
+	})
+	if err != nil {
+		t.Fatalf("AddListener returned error %v", err)
+	}
+	rule, err := awsutil.ALBsvc.AddRule(elbv2.CreateRuleInput{
+		ListenerArn: listener.ListenerArn,
+		Priority:    aws.Int64(1),
+		Actions:     []*elbv2.Action{{Type: aws.String(elbv2.ActionTypeEnumForward), TargetGroupArn: tg.TargetGroupArn}},
+		Conditions:  []*elbv2.RuleCondition{{Field: aws.String("path-pattern"), Values: aws.StringSlice([]string{"/api"})}},
+	})
+	if err != nil {
+		t.Fatalf("AddRule returned error %v", err)
+	}
+
+	swapped, err := awsutil.ALBsvc.AddRule(elbv2.CreateRuleInput{
+		ListenerArn: listener.ListenerArn,
+		Priority:    aws.Int64(2),
+		Actions:     []*elbv2.Action{{Type: aws.String(elbv2.ActionTypeEnumForward), TargetGroupArn: tg.TargetGroupArn}},
+		Conditions:  []*elbv2.RuleCondition{{Field: aws.String("path-pattern"), Values: aws.StringSlice([]string{"/api/v2"})}},
+	})
+	if err != nil {
+		t.Fatalf("AddRule returned error %v", err)
+	}
+	if err := awsutil.ALBsvc.SetRulePriorities(elbv2.SetRulePrioritiesInput{RulePriorities: []*elbv2.RulePriorityPair{
+		{RuleArn: swapped.RuleArn, Priority: aws.Int64(1)},
+	}}); err == nil {
+		t.Errorf("SetRulePriorities returned no error giving two rules priority 1")
+	}
+	if err := awsutil.ALBsvc.SetRulePriorities(elbv2.SetRulePrioritiesInput{RulePriorities: []*elbv2.RulePriorityPair{
+		{RuleArn: swapped.RuleArn, Priority: aws.Int64(1)},
+		{RuleArn: rule.RuleArn, Priority: aws.Int64(2)},
+	}}); err != nil {
+		t.Errorf("SetRulePriorities returned error %v swapping two rules", err)
+	}
+	if err := awsutil.ALBsvc.RemoveRule(elbv2.DeleteRuleInput{RuleArn: rule.RuleArn}); err != nil {
+		t.Fatalf("RemoveRule returned error %v", err)
+	}
+
+	loadBalancers, err := awsutil.ALBsvc.DescribeLoadBalancers(aws.String("cluster"))
+	if err != nil || len(loadBalancers) != 1 {
+		t.Fatalf("DescribeLoadBalancers returned %v, %v, expected the ALB", loadBalancers, err)
+	}
+	rules, err := awsutil.ALBsvc.DescribeRules(listener.ListenerArn)
+	if err != nil || len(rules) != 2 || *rules[0].Priority != "1" || !*rules[1].IsDefault {
+		t.Errorf("DescribeRules returned %v, %v, expected the rule followed by the default rule", rules, err)
+	}
+	targetGroups, err := awsutil.ALBsvc.DescribeTargetGroups(lb.LoadBalancerArn)
+	if err != nil || len(targetGroups) != 1 || *targetGroups[0].LoadBalancerArns[0] != *lb.LoadBalancerArn {
+		t.Errorf("DescribeTargetGroups returned %v, %v, expected the target group used by the ALB", targetGroups, err)
+	}
+
+	targets := []*elbv2.TargetDescription{{Id: aws.String("i-1")}, {Id: aws.String("i-2")}}
+	if err := awsutil.ALBsvc.RegisterTargets(elbv2.RegisterTargetsInput{TargetGroupArn: tg.TargetGroupArn, Targets: targets}); err != nil {
+		t.Fatalf("RegisterTargets returned error %v", err)
+	}
+	clients.ELBV2.SetTargetHealth(*tg.TargetGroupArn, "i-2", elbv2.TargetHealthStateEnumUnhealthy)
+	health, err := awsutil.ALBsvc.DescribeTargetGroupHealth(tg.TargetGroupArn)
+	if err != nil || len(health) != 2 || *health[0].TargetHealth.State != "healthy" || *health[1].TargetHealth.State != "unhealthy" {
+		t.Errorf("DescribeTargetGroupHealth returned %v, %v, expected i-1 healthy and i-2 unhealthy", health, err)
+	}
+
+	if err := awsutil.ALBsvc.Delete(elbv2.DeleteLoadBalancerInput{LoadBalancerArn: lb.LoadBalancerArn}); err != nil {
+		t.Fatalf("Delete returned error %v", err)
+	}
+	if err := awsutil.ALBsvc.RemoveTargetGroup(elbv2.DeleteTargetGroupInput{TargetGroupArn: tg.TargetGroupArn}); err != nil {
+		t.Errorf("RemoveTargetGroup returned error %v once the ALB was deleted", err)
+	}
+	if targetGroups, _ := awsutil.ALBsvc.DescribeTargetGroups(nil); len(targetGroups) != 0 {
+		t.Errorf("DescribeTargetGroups returned %v, expected none", targetGroups)
+	}
+}
+
+func TestEC2(t *testing.T) {
+	clients := New()
+	defer clients.Install()()
+	clients.EC2.AddSubnet("subnet-1", "vpc-1", "us-east-1a", map[string]string{"Name": "public"})
+	clients.EC2.AddSubnet("subnet-2", "vpc-1", "us-east-1b", map[string]string{"Name": "private"})
+
+	subnets, err := awsutil.Ec2svc.DescribeSubnets(ec2.DescribeSubnetsInput{Filters: []*ec2.Filter{{
+		Name:   aws.String("tag:Name"),
+		Values: aws.StringSlice([]string{"public"}),
+	}}})
+	if err != nil || len(subnets) != 1 || *subnets[0].SubnetId != "subnet-1" {
+		t.Errorf("DescribeSubnets returned %v, %v, expected subnet-1", subnets, err)
+	}
+
+	id, err := awsutil.Ec2svc.CreateSecurityGroup(ec2.CreateSecurityGroupInput{
+		GroupName:   aws.String("instance"),
+		Description: aws.String("instance"),
+		VpcId:       aws.String("vpc-1"),
+	})
+	if err != nil {
+		t.Fatalf("CreateSecurityGroup returned error %v", err)
+	}
+	permission := func(cidrs ...string) []*ec2.IpPermission {
+		p := &ec2.IpPermission{FromPort: aws.Int64(80), ToPort: aws.Int64(80), IpProtocol: aws.String("tcp")}
+		for _, cidr := range cidrs {
+			p.IpRanges = append(p.IpRanges, &ec2.IpRange{CidrIp: aws.String(cidr)})
+		}
+		return []*ec2.IpPermission{p}
+	}
+	if err := awsutil.Ec2svc.AuthorizeSecurityGroupIngress(ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId:       id,
+		IpPermissions: permission("10.0.0.0/8", "192.168.0.0/16"),
+	}, []string{"port 80"}); err != nil {
+		t.Fatalf("AuthorizeSecurityGroupIngress returned error %v", err)
+	}
+	if err := awsutil.Ec2svc.RevokeSecurityGroupIngress(ec2.RevokeSecurityGroupIngressInput{
+		GroupId:       id,
+		IpPermissions: permission("10.0.0.0/8"),
+	}); err != nil {
+		t.Fatalf("RevokeSecurityGroupIngress returned error %v", err)
+	}
+	sg := clients.EC2.SecurityGroup(*id)
+	if len(sg.IpPermissions) != 1 || len(sg.IpPermissions[0].IpRanges) != 1 || *sg.IpPermissions[0].IpRanges[0].CidrIp != "192.168.0.0/16" {
+		t.Errorf("security group has permissions %v, expected port 80 from 192.168.0.0/16", sg.IpPermissions)
+	}
+
+	// New security groups allow all outbound traffic, which is replaced by a single rule.
+	if err := awsutil.Ec2svc.AuthorizeSecurityGroupEgress(ec2.AuthorizeSecurityGroupEgressInput{
+		GroupId:       id,
+		IpPermissions: permission("10.0.0.0/8"),
+	}, nil); err != nil {
+		t.Fatalf("AuthorizeSecurityGroupEgress returned error %v", err)
+	}
+	if err := awsutil.Ec2svc.RevokeSecurityGroupEgress(ec2.RevokeSecurityGroupEgressInput{
+		GroupId: id,
+		IpPermissions: []*ec2.IpPermission{
+			{IpProtocol: aws.String("-1"), IpRanges: []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}}},
+		},
+	}); err != nil {
+		t.Fatalf("RevokeSecurityGroupEgress returned error %v", err)
+	}
+	sg = clients.EC2.SecurityGroup(*id)
+	if len(sg.IpPermissionsEgress) != 1 || *sg.IpPermissionsEgress[0].IpRanges[0].CidrIp != "10.0.0.0/8" {
+		t.Errorf("security group has outbound permissions %v, expected port 80 to 10.0.0.0/8", sg.IpPermissionsEgress)
+	}
+
+	if err := awsutil.Ec2svc.DeleteSecurityGroup(id); err != nil {
+		t.Fatalf("DeleteSecurityGroup returned error %v", err)
+	}
+	if sg := clients.EC2.SecurityGroup(*id); sg != nil {
+		t.Errorf("security group %v exists after being deleted", *id)
+	}
+}
+
+func TestRoute53(t *testing.T) {
+	clients := New()
+	defer clients.Install()()
+	zoneID := clients.Route53.AddHostedZone("example.com")
+
+	zone, err := awsutil.Route53svc.GetZoneID(aws.String("www.example.com"))
+	if err != nil || *zone.Id != zoneID {
+		t.Fatalf("GetZoneID returned %v, %v, expected zone %v", zone, err, zoneID)
+	}
+
+	record := &route53.ResourceRecordSet{
+		Name: aws.String("www.example.com"),
+		Type: aws.String(route53.RRTypeA),
+		AliasTarget: &route53.AliasTarget{
+			DNSName:              aws.String("cluster-0123456789.us-east-1.elb.amazonaws.com"),
+			HostedZoneId:         aws.String(canonicalHostedZoneID),
+			EvaluateTargetHealth: aws.Bool(false),
+		},
+	}
+	change := func(action string) route53.ChangeResourceRecordSetsInput {
+		return route53.ChangeResourceRecordSetsInput{
+			HostedZoneId: zone.Id,
+			ChangeBatch:  &route53.ChangeBatch{Changes: []*route53.Change{{Action: aws.String(action), ResourceRecordSet: record}}},
+		}
+	}
+	if err := awsutil.Route53svc.Modify(change(route53.ChangeActionUpsert)); err != nil {
+		t.Fatalf("Modify returned error %v", err)
+	}
+	rrs, err := awsutil.Route53svc.DescribeResourceRecordSets(zone.Id, record.Name)
+	if err != nil || *rrs.AliasTarget.DNSName != *record.AliasTarget.DNSName {
+		t.Errorf("DescribeResourceRecordSets returned %v, %v, expected the record", rrs, err)
+	}
+
+	if err := awsutil.Route53svc.Delete(change(route53.ChangeActionDelete)); err != nil {
+		t.Fatalf("Delete returned error %v", err)
+	}
+	if records := clients.Route53.Records(zoneID); len(records) != 0 {
+		t.Errorf("zone has records %v, expected none", records)
+	}
+}
+
+func TestIAM(t *testing.T) {
+	clients := New()
+	defer clients.Install()()
+	clients.IAM.Deny("elasticloadbalancing:CreateRule", "sts:AssumeRole on arn:aws:iam::123456789012:role/team-b")
+
+	denied, err := awsutil.IAMsvc.DeniedActions("arn:aws:iam::123456789012:role/alb-ingress",
+		[]string{"elasticloadbalancing:CreateListener", "elasticloadbalancing:CreateRule", "sts:AssumeRole"},
+		map[string][]string{"sts:AssumeRole": {"arn:aws:iam::123456789012:role/team-a", "arn:aws:iam::123456789012:role/team-b"}})
+	expected := []string{"sts:AssumeRole on arn:aws:iam::123456789012:role/team-b", "elasticloadbalancing:CreateRule"}
+	if err != nil || !reflect.DeepEqual(denied, expected) {
+		t.Errorf("DeniedActions returned %v, %v, expected %v", denied, err, expected)
+	}
+
+	arn := clients.IAM.AddServerCertificate("www", "-----BEGIN CERTIFICATE-----")
+	certificates, err := awsutil.IAMsvc.DescribeServerCertificates()
+	if err != nil || len(certificates) != 1 || *certificates[0].ServerCertificateMetadata.Arn != arn || *certificates[0].CertificateBody == "" {
+		t.Errorf("DescribeServerCertificates returned %v, %v, expected %v with its body", certificates, err, arn)
+	}
+}
+
+func TestWAFRegional(t *testing.T) {
+	clients := New()
+	defer clients.Install()()
+
+	if err := awsutil.WAFsvc.AssociateWebACL(aws.String("acl-1"), aws.String("arn-1")); err != nil {
+		t.Fatalf("AssociateWebACL returned error %v", err)
+	}
+	if acl := clients.WAFRegional.WebACL("arn-1"); acl != "acl-1" {
+		t.Errorf("ALB is associated with %q, expected acl-1", acl)
+	}
+	if acl, err := awsutil.WAFsvc.GetWebACLForResource(aws.String("arn-1")); err != nil || aws.StringValue(acl) != "acl-1" {
+		t.Errorf("GetWebACLForResource returned %v, %v, expected acl-1", aws.StringValue(acl), err)
+	}
+	if err := awsutil.WAFsvc.DisassociateWebACL(aws.String("arn-1")); err != nil {
+		t.Fatalf("DisassociateWebACL returned error %v", err)
+	}
+	if acl := clients.WAFRegional.WebACL("arn-1"); acl != "" {
+		t.Errorf("ALB is associated with %q, expected none", acl)
+	}
+	if acl, err := awsutil.WAFsvc.GetWebACLForResource(aws.String("arn-1")); err != nil || acl != nil {
+		t.Errorf("GetWebACLForResource returned %v, %v, expected none", aws.StringValue(acl), err)
+	}
+}
+
+func TestGlobalAccelerator(t *testing.T) {
+	clients := New()
+	defer clients.Install()()
+	ga := awsutil.GlobalAcceleratorsvc
+
+	tags := util.Tags{{Key: aws.String("IngressName"), Value: aws.String("app")}}
+	accelerator, err := ga.CreateAccelerator(aws.String("app"), tags)
+	if err != nil {
+		t.Fatalf("CreateAccelerator returned error %v", err)
+	}
+	// Like Global Accelerator, creating it again with the same token returns the first one.
+	if again, err := ga.CreateAccelerator(aws.String("app"), tags); err != nil || *again.AcceleratorArn != *accelerator.AcceleratorArn {
+		t.Errorf("CreateAccelerator again returned %v, %v, expected %s", again, err, *accelerator.AcceleratorArn)
+	}
+	if actual, err := ga.ListTagsForResource(accelerator.AcceleratorArn); err != nil || util.Tags(actual).OwnershipConflict(tags) != nil {
+		t.Errorf("ListTagsForResource returned %v, %v, expected %v", actual, err, tags)
+	}
+	port := []*awsutil.PortRange{{FromPort: aws.Int64(80), ToPort: aws.Int64(80)}}
+	listener, err := ga.CreateListener(accelerator.AcceleratorArn, port)
+	if err != nil {
+		t.Fatalf("CreateListener returned error %v", err)
+	}
+	endpoints := []*awsutil.EndpointConfiguration{{EndpointId: aws.String("arn-1")}}
+	group, err := ga.CreateEndpointGroup(listener.ListenerArn, aws.String(region), endpoints)
+	if err != nil {
+		t.Fatalf("CreateEndpointGroup returned error %v", err)
+	}
+	if actual := clients.GlobalAccelerator.Endpoints(*listener.ListenerArn)[region]; len(actual) != 1 || actual[0] != "arn-1" {
+		t.Errorf("Endpoints(): expected arn-1, actual %v", actual)
+	}
+
+	// The listener and accelerator can't be deleted before what they contain, nor the accelerator
+	// while it's enabled or its change isn't deployed.
+	if err := ga.DeleteListener(listener.ListenerArn); err == nil {
+		t.Errorf("DeleteListener with an endpoint group returned no error")
+	}
+	if err := ga.DeleteEndpointGroup(group.EndpointGroupArn); err != nil {
+		t.Fatalf("DeleteEndpointGroup returned error %v", err)
+	}
+	if err := ga.DeleteListener(listener.ListenerArn); err != nil {
+		t.Fatalf("DeleteListener returned error %v", err)
+	}
+	if err := ga.DeleteAccelerator(accelerator.AcceleratorArn); err == nil {
+		t.Errorf("DeleteAccelerator of an enabled accelerator returned no error")
+	}
+	disabled, err := ga.DisableAccelerator(accelerator.AcceleratorArn)
+	if err != nil || *disabled.Status != awsutil.AcceleratorStatusInProgress {
+		t.Fatalf("DisableAccelerator returned %v, %v, expected IN_PROGRESS", disabled, err)
+	}
+	if err := ga.DeleteAccelerator(accelerator.AcceleratorArn); err == nil {
+		t.Errorf("DeleteAccelerator while it's being disabled returned no error")
+	}
+	if described, err := ga.DescribeAccelerator(accelerator.AcceleratorArn); err != nil || *described.Enabled {
+		t.Fatalf("DescribeAccelerator returned %v, %v, expected it disabled", described, err)
+	}
+	if err := ga.DeleteAccelerator(accelerator.AcceleratorArn); err != nil {
+		t.Fatalf("DeleteAccelerator returned error %v", err)
+	}
+	if accelerators, err := ga.ListAccelerators(); err != nil || len(accelerators) != 0 {
+		t.Errorf("ListAccelerators returned %v, %v, expected none", accelerators, err)
+	}
+}
+
+func TestTagging(t *testing.T) {
+	clients := New()
+	defer clients.Install()()
+	clients.EC2.AddSubnet("subnet-1", "vpc-1", "us-east-1a", nil)
+
+	owned := []*elbv2.Tag{{Key: aws.String(util.ClusterNameTag), Value: aws.String("cluster")}}
+	lb, err := awsutil.ALBsvc.Create(elbv2.CreateLoadBalancerInput{
+		Name:    aws.String("cluster-0123456789"),
+		Subnets: aws.StringSlice([]string{"subnet-1"}),
+		Tags:    owned,
+	})
+	if err != nil {
+		t.Fatalf("Create returned error %v", err)
+	}
+	if _, err := awsutil.ALBsvc.Create(elbv2.CreateLoadBalancerInput{
+		Name:    aws.String("other-0123456789"),
+		Subnets: aws.StringSlice([]string{"subnet-1"}),
+	}); err != nil {
+		t.Fatalf("Create returned error %v", err)
+	}
+	tg, err := awsutil.ALBsvc.AddTargetGroup(elbv2.CreateTargetGroupInput{
+		Name:     aws.String("cluster-80-HTTP-0123456"),
+		Port:     aws.Int64(30080),
+		Protocol: aws.String(elbv2.ProtocolEnumHttp),
+		VpcId:    lb.VpcId,
+	}, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("AddTargetGroup returned error %v", err)
+	}
+	if _, err := clients.ELBV2.AddTags(&elbv2.AddTagsInput{ResourceArns: []*string{tg.TargetGroupArn}, Tags: owned}); err != nil {
+		t.Fatalf("AddTags returned error %v", err)
+	}
+
+	resources, err := awsutil.NewTaggingWithClient(clients.Tagging.client()).GetResources(awsutil.ResourceTypeLoadBalancer, util.Tags(owned))
+	if err != nil {
+		t.Fatalf("GetResources returned error %v", err)
+	}
+	if _, ok := resources[*lb.LoadBalancerArn]; !ok || len(resources) != 1 {
+		t.Errorf("GetResources returned %v, expected only %v", resources, *lb.LoadBalancerArn)
+	}
+}
+
+func TestSQS(t *testing.T) {
+	clients := New()
+	defer clients.Install()()
+	clients.SQS.SendMessage("queue", "a")
+	clients.SQS.SendMessage("queue", "b")
+
+	messages, err := awsutil.SQSsvc.ReceiveMessages("queue")
+	if err != nil || len(messages) != 2 || *messages[0].Body != "a" {
+		t.Fatalf("ReceiveMessages returned %v, %v, expected a and b", messages, err)
+	}
+	if err := awsutil.SQSsvc.DeleteMessage("queue", messages[0].ReceiptHandle); err != nil {
+		t.Fatalf("DeleteMessage returned error %v", err)
+	}
+	if bodies := clients.SQS.Messages("queue"); len(bodies) != 1 || bodies[0] != "b" {
+		t.Errorf("queue has messages %v, expected b", bodies)
+	}
+}
+
+func TestAutoScaling(t *testing.T) {
+	clients := New()
+	defer clients.Install()()
+
+	action := &awsutil.LifecycleAction{HookName: "drain", GroupName: "nodes", InstanceID: "i-1", Token: "token-1"}
+	if err := awsutil.AutoScalingsvc.CompleteLifecycleAction(action, awsutil.LifecycleActionResultContinue); err != nil {
+		t.Fatalf("CompleteLifecycleAction returned error %v", err)
+	}
+	if result := clients.AutoScaling.Completed("i-1"); result != awsutil.LifecycleActionResultContinue {
+		t.Errorf("lifecycle action of i-1 was completed with %q, expected CONTINUE", result)
+	}
+	if result := clients.AutoScaling.Completed("i-2"); result != "" {
+		t.Errorf("lifecycle action of i-2 was completed with %q, expected none", result)
+	}
+}
+
+func TestCloudWatch(t *testing.T) {
+	clients := New()
+	defer clients.Install()()
+
+	alarm := &awsutil.MetricAlarm{AlarmName: aws.String("app-response-time"), Threshold: aws.Float64(1)}
+	if err := awsutil.CloudWatchsvc.PutMetricAlarm(alarm); err != nil {
+		t.Fatalf("PutMetricAlarm returned error %v", err)
+	}
+	names := aws.StringSlice([]string{"app-response-time", "app-5xx-rate"})
+	alarms, err := awsutil.CloudWatchsvc.DescribeAlarms(names)
+	if err != nil || len(alarms) != 1 || *alarms[0].Threshold != 1 {
+		t.Fatalf("DescribeAlarms returned %v, %v, expected app-response-time", alarms, err)
+	}
+	// Like CloudWatch, nothing is deleted when one of the alarms doesn't exist.
+	if err := awsutil.CloudWatchsvc.DeleteAlarms(names); err == nil {
+		t.Errorf("DeleteAlarms of a missing alarm returned no error")
+	}
+	if err := awsutil.CloudWatchsvc.DeleteAlarms(names[:1]); err != nil || len(clients.CloudWatch.Alarms()) != 0 {
+		t.Errorf("DeleteAlarms returned %v, left %v", err, clients.CloudWatch.Alarms())
+	}
+
+	datum := &awsutil.MetricDatum{MetricName: aws.String("ManagedIngresses"), Value: aws.Float64(2)}
+	if err := awsutil.CloudWatchsvc.PutMetricData("ALBIngressController", []*awsutil.MetricDatum{datum}); err != nil {
+		t.Fatalf("PutMetricData returned error %v", err)
+	}
+	if data := clients.CloudWatch.Metrics("ALBIngressController"); len(data) != 1 || *data[0].Value != 2 {
+		t.Errorf("Metrics(): expected ManagedIngresses 2, actual %v", data)
+	}
+}
+
+func TestS3(t *testing.T) {
+	clients := New()
+	defer clients.Install()()
+
+	if exists, err := awsutil.S3svc.BucketExists("logs"); exists || err != nil {
+		t.Fatalf("BucketExists(logs) = %v, %v, want false", exists, err)
+	}
+	if err := awsutil.S3svc.CreateBucket("logs"); err != nil {
+		t.Fatalf("CreateBucket returned error %v", err)
+	}
+	if err := awsutil.S3svc.PutBucketPolicy("logs", "{}"); err != nil {
+		t.Fatalf("PutBucketPolicy returned error %v", err)
+	}
+	if err := awsutil.S3svc.PutBucketExpiration("logs", "expire", 7); err != nil {
+		t.Fatalf("PutBucketExpiration returned error %v", err)
+	}
+	if b := clients.S3.Bucket("logs"); b == nil || b.Region != "us-east-1" || b.Policy != "{}" || b.ExpirationDays != 7 {
+		t.Errorf("Bucket(logs) = %+v, want a us-east-1 bucket with its policy and expiration", b)
+	}
+	if err := awsutil.S3svc.PutBucketPolicy("missing", "{}"); err == nil {
+		t.Errorf("PutBucketPolicy of a missing bucket returned no error")
+	}
+}
@@ -0,0 +1,287 @@
+package fake
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+)
+
+// GlobalAccelerator is an in-memory Global Accelerator, serving the calls of an
+// awsutil.GlobalAccelerator. Accelerators are IN_PROGRESS after they're created or changed, until
+// they're next described or listed, and can only be deleted once disabled and without listeners,
+// like in AWS. Listeners can only be deleted without endpoint groups. Every call is answered in one
+// page.
+type GlobalAccelerator struct {
+	mu           sync.Mutex
+	ids          ids
+	accelerators map[string]*awsutil.Accelerator
+	tags         map[string][]*elbv2.Tag
+	listeners    map[string]*awsutil.AcceleratorListener
+	groups       map[string]*awsutil.EndpointGroup
+	// tokens are the ARNs of the resources created with each idempotency token
+	tokens map[string]string
+}
+
+// NewGlobalAccelerator returns a GlobalAccelerator without any accelerators.
+func NewGlobalAccelerator() *GlobalAccelerator {
+	return &GlobalAccelerator{
+		accelerators: make(map[string]*awsutil.Accelerator),
+		tags:         make(map[string][]*elbv2.Tag),
+		listeners:    make(map[string]*awsutil.AcceleratorListener),
+		groups:       make(map[string]*awsutil.EndpointGroup),
+		tokens:       make(map[string]string),
+	}
+}
+
+// Accelerators returns the accelerators, in order of creation.
+func (g *GlobalAccelerator) Accelerators() []*awsutil.Accelerator {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var accelerators []*awsutil.Accelerator
+	for _, arn := range sortedKeys(g.accelerators) {
+		accelerators = append(accelerators, copyOf(g.accelerators[arn]).(*awsutil.Accelerator))
+	}
+	return accelerators
+}
+
+// Endpoints returns the IDs of the endpoints of the endpoint groups of the listener arn, keyed by
+// region.
+func (g *GlobalAccelerator) Endpoints(arn string) map[string][]string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	endpoints := make(map[string][]string)
+	for _, group := range g.groupsOf(arn) {
+		region := *group.EndpointGroupRegion
+		endpoints[region] = []string{}
+		for _, endpoint := range group.EndpointDescriptions {
+			endpoints[region] = append(endpoints[region], *endpoint.EndpointId)
+		}
+	}
+	return endpoints
+}
+
+// AddListener adds an accelerator named name, not created by the controller, with a listener on
+// ports, and returns the ARN of the listener.
+func (g *GlobalAccelerator) AddListener(name string, ports ...int64) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	accelerator := g.createAccelerator(aws.String(name), nil)
+	var ranges []*awsutil.PortRange
+	for _, port := range ports {
+		ranges = append(ranges, &awsutil.PortRange{FromPort: aws.Int64(port), ToPort: aws.Int64(port)})
+	}
+	return *g.createListener(accelerator.AcceleratorArn, ranges).ListenerArn
+}
+
+func (g *GlobalAccelerator) client() *client.Client {
+	return newClient("globalaccelerator", g.serve)
+}
+
+func (g *GlobalAccelerator) serve(operation string, in, out interface{}) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch operation {
+	case "ListAccelerators":
+		var accelerators []*awsutil.Accelerator
+		for _, arn := range sortedKeys(g.accelerators) {
+			accelerators = append(accelerators, g.describe(arn))
+		}
+		field(out, "Accelerators").Set(reflect.ValueOf(accelerators))
+	case "DescribeAccelerator":
+		arn := *stringField(in, "AcceleratorArn")
+		if _, ok := g.accelerators[arn]; !ok {
+			return notFound("AcceleratorNotFoundException", "Accelerator", arn)
+		}
+		field(out, "Accelerator").Set(reflect.ValueOf(g.describe(arn)))
+	case "CreateAccelerator":
+		token := *stringField(in, "IdempotencyToken")
+		if arn, ok := g.tokens[token]; ok && g.accelerators[arn] != nil {
+			field(out, "Accelerator").Set(reflect.ValueOf(copyOf(g.accelerators[arn])))
+			break
+		}
+		accelerator := g.createAccelerator(stringField(in, "Name"), field(in, "Tags").Interface().([]*elbv2.Tag))
+		g.tokens[token] = *accelerator.AcceleratorArn
+		field(out, "Accelerator").Set(reflect.ValueOf(copyOf(accelerator)))
+	case "UpdateAccelerator":
+		arn := *stringField(in, "AcceleratorArn")
+		accelerator, ok := g.accelerators[arn]
+		if !ok {
+			return notFound("AcceleratorNotFoundException", "Accelerator", arn)
+		}
+		accelerator.Enabled = field(in, "Enabled").Interface().(*bool)
+		accelerator.Status = aws.String(awsutil.AcceleratorStatusInProgress)
+		field(out, "Accelerator").Set(reflect.ValueOf(copyOf(accelerator)))
+	case "DeleteAccelerator":
+		arn := *stringField(in, "AcceleratorArn")
+		accelerator, ok := g.accelerators[arn]
+		if !ok {
+			return notFound("AcceleratorNotFoundException", "Accelerator", arn)
+		}
+		if *accelerator.Enabled || *accelerator.Status != awsutil.AcceleratorStatusDeployed {
+			return awserr.New("AcceleratorNotDisabledException", fmt.Sprintf("Accelerator '%s' isn't disabled", arn), nil)
+		}
+		for _, listener := range g.listeners {
+			if strings.HasPrefix(*listener.ListenerArn, arn+"/") {
+				return awserr.New("AssociatedListenerFoundException", fmt.Sprintf("Accelerator '%s' has listeners", arn), nil)
+			}
+		}
+		delete(g.accelerators, arn)
+		delete(g.tags, arn)
+	case "ListTagsForResource":
+		arn := *stringField(in, "ResourceArn")
+		if _, ok := g.accelerators[arn]; !ok {
+			return notFound("AcceleratorNotFoundException", "Accelerator", arn)
+		}
+		field(out, "Tags").Set(reflect.ValueOf(copyOf(g.tags[arn])))
+	case "ListListeners":
+		arn := *stringField(in, "AcceleratorArn")
+		var listeners []*awsutil.AcceleratorListener
+		for _, listenerArn := range sortedKeys(g.listeners) {
+			if strings.HasPrefix(listenerArn, arn+"/") {
+				listeners = append(listeners, copyOf(g.listeners[listenerArn]).(*awsutil.AcceleratorListener))
+			}
+		}
+		field(out, "Listeners").Set(reflect.ValueOf(listeners))
+	case "CreateListener":
+		arn := stringField(in, "AcceleratorArn")
+		if _, ok := g.accelerators[*arn]; !ok {
+			return notFound("AcceleratorNotFoundException", "Accelerator", *arn)
+		}
+		listener := g.createListener(arn, field(in, "PortRanges").Interface().([]*awsutil.PortRange))
+		field(out, "Listener").Set(reflect.ValueOf(copyOf(listener)))
+	case "UpdateListener":
+		arn := *stringField(in, "ListenerArn")
+		listener, ok := g.listeners[arn]
+		if !ok {
+			return notFound("ListenerNotFoundException", "Listener", arn)
+		}
+		listener.PortRanges = copyOf(field(in, "PortRanges").Interface()).([]*awsutil.PortRange)
+		field(out, "Listener").Set(reflect.ValueOf(copyOf(listener)))
+	case "DeleteListener":
+		arn := *stringField(in, "ListenerArn")
+		if _, ok := g.listeners[arn]; !ok {
+			return notFound("ListenerNotFoundException", "Listener", arn)
+		}
+		if len(g.groupsOf(arn)) > 0 {
+			return awserr.New("AssociatedEndpointGroupFoundException", fmt.Sprintf("Listener '%s' has endpoint groups", arn), nil)
+		}
+		delete(g.listeners, arn)
+	case "ListEndpointGroups":
+		arn := *stringField(in, "ListenerArn")
+		if _, ok := g.listeners[arn]; !ok {
+			return notFound("ListenerNotFoundException", "Listener", arn)
+		}
+		field(out, "EndpointGroups").Set(reflect.ValueOf(copyOf(g.groupsOf(arn))))
+	case "CreateEndpointGroup":
+		arn, region := *stringField(in, "ListenerArn"), stringField(in, "EndpointGroupRegion")
+		if _, ok := g.listeners[arn]; !ok {
+			return notFound("ListenerNotFoundException", "Listener", arn)
+		}
+		for _, group := range g.groupsOf(arn) {
+			if *group.EndpointGroupRegion == *region {
+				return awserr.New("EndpointGroupAlreadyExistsException", fmt.Sprintf("Listener '%s' has an endpoint group in %s", arn, *region), nil)
+			}
+		}
+		group := &awsutil.EndpointGroup{
+			EndpointDescriptions: endpointDescriptions(field(in, "EndpointConfigurations").Interface().([]*awsutil.EndpointConfiguration)),
+			EndpointGroupArn:     aws.String(arn + "/endpoint-group/" + g.ids.next()),
+			EndpointGroupRegion:  region,
+		}
+		g.groups[*group.EndpointGroupArn] = group
+		field(out, "EndpointGroup").Set(reflect.ValueOf(copyOf(group)))
+	case "UpdateEndpointGroup":
+		arn := *stringField(in, "EndpointGroupArn")
+		group, ok := g.groups[arn]
+		if !ok {
+			return notFound("EndpointGroupNotFoundException", "Endpoint group", arn)
+		}
+		group.EndpointDescriptions = endpointDescriptions(field(in, "EndpointConfigurations").Interface().([]*awsutil.EndpointConfiguration))
+		field(out, "EndpointGroup").Set(reflect.ValueOf(copyOf(group)))
+	case "DeleteEndpointGroup":
+		arn := *stringField(in, "EndpointGroupArn")
+		if _, ok := g.groups[arn]; !ok {
+			return notFound("EndpointGroupNotFoundException", "Endpoint group", arn)
+		}
+		delete(g.groups, arn)
+	default:
+		panic("fake GlobalAccelerator doesn't implement " + operation)
+	}
+	return nil
+}
+
+// describe returns a copy of the accelerator arn, deploying the changes made to it since it was
+// last described.
+func (g *GlobalAccelerator) describe(arn string) *awsutil.Accelerator {
+	accelerator := copyOf(g.accelerators[arn]).(*awsutil.Accelerator)
+	g.accelerators[arn].Status = aws.String(awsutil.AcceleratorStatusDeployed)
+	return accelerator
+}
+
+func (g *GlobalAccelerator) createAccelerator(name *string, tags []*elbv2.Tag) *awsutil.Accelerator {
+	id := g.ids.next()
+	accelerator := &awsutil.Accelerator{
+		AcceleratorArn: aws.String(fmt.Sprintf("arn:aws:globalaccelerator::%s:accelerator/%s", account, id)),
+		DnsName:        aws.String(id + ".awsglobalaccelerator.com"),
+		Enabled:        aws.Bool(true),
+		IpAddressType:  aws.String("IPV4"),
+		Name:           name,
+		Status:         aws.String(awsutil.AcceleratorStatusInProgress),
+	}
+	g.accelerators[*accelerator.AcceleratorArn] = accelerator
+	g.tags[*accelerator.AcceleratorArn] = copyOf(tags).([]*elbv2.Tag)
+	return accelerator
+}
+
+func (g *GlobalAccelerator) createListener(arn *string, ports []*awsutil.PortRange) *awsutil.AcceleratorListener {
+	listener := &awsutil.AcceleratorListener{
+		ListenerArn: aws.String(*arn + "/listener/" + g.ids.next()),
+		PortRanges:  copyOf(ports).([]*awsutil.PortRange),
+		Protocol:    aws.String("TCP"),
+	}
+	g.listeners[*listener.ListenerArn] = listener
+	return listener
+}
+
+// groupsOf returns the endpoint groups of the listener arn, in order of creation.
+func (g *GlobalAccelerator) groupsOf(arn string) []*awsutil.EndpointGroup {
+	var groups []*awsutil.EndpointGroup
+	for _, groupArn := range sortedKeys(g.groups) {
+		if strings.HasPrefix(groupArn, arn+"/") {
+			groups = append(groups, g.groups[groupArn])
+		}
+	}
+	return groups
+}
+
+// endpointDescriptions returns the endpoints described like AWS does for endpoints configured as
+// configurations.
+func endpointDescriptions(configurations []*awsutil.EndpointConfiguration) []*awsutil.EndpointDescription {
+	var endpoints []*awsutil.EndpointDescription
+	for _, c := range configurations {
+		endpoints = append(endpoints, &awsutil.EndpointDescription{
+			ClientIPPreservationEnabled: c.ClientIPPreservationEnabled,
+			EndpointId:                  c.EndpointId,
+			HealthState:                 aws.String("INITIAL"),
+			Weight:                      c.Weight,
+		})
+	}
+	return endpoints
+}
+
+// sortedKeys returns the keys of m, a map keyed by string, sorted.
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	for _, key := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,120 @@
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+)
+
+// IAM is an in-memory iamiface.IAMAPI holding server certificates and their PEM encoded bodies. Principals are allowed every
+// action unless Deny says otherwise.
+type IAM struct {
+	iamiface.IAMAPI
+
+	mu                 sync.Mutex
+	serverCertificates map[string]string
+	denied             map[string]bool
+}
+
+// NewIAM returns an IAM without any server certificates or denied actions.
+func NewIAM() *IAM {
+	return &IAM{
+		serverCertificates: make(map[string]string),
+		denied:             make(map[string]bool),
+	}
+}
+
+// AddServerCertificate adds the server certificate name with the PEM encoded body and returns its
+// ARN.
+func (i *IAM) AddServerCertificate(name, body string) string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.serverCertificates[name] = body
+	return serverCertificateArn(name)
+}
+
+func serverCertificateArn(name string) string {
+	return fmt.Sprintf("arn:aws:iam::%s:server-certificate/%s", account, name)
+}
+
+// Deny makes SimulatePrincipalPolicy deny actions, e.g. elasticloadbalancing:CreateRule, or an
+// action on a resource, e.g. "sts:AssumeRole on arn:aws:iam::123456789012:role/team", to every
+// principal.
+func (i *IAM) Deny(actions ...string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, action := range actions {
+		i.denied[action] = true
+	}
+}
+
+func (i *IAM) GetServerCertificate(in *iam.GetServerCertificateInput) (*iam.GetServerCertificateOutput, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	name := aws.StringValue(in.ServerCertificateName)
+	body, ok := i.serverCertificates[name]
+	if !ok {
+		return nil, awserr.New(iam.ErrCodeNoSuchEntityException, fmt.Sprintf("The Server Certificate with name %s cannot be found.", name), nil)
+	}
+	return &iam.GetServerCertificateOutput{ServerCertificate: &iam.ServerCertificate{
+		CertificateBody:           aws.String(body),
+		ServerCertificateMetadata: serverCertificateMetadata(name),
+	}}, nil
+}
+
+func serverCertificateMetadata(name string) *iam.ServerCertificateMetadata {
+	return &iam.ServerCertificateMetadata{
+		Arn:                   aws.String(serverCertificateArn(name)),
+		ServerCertificateName: aws.String(name),
+	}
+}
+
+// ListServerCertificatesPages pages through the server certificates in order of name, a single page
+// holding all of them.
+func (i *IAM) ListServerCertificatesPages(in *iam.ListServerCertificatesInput, fn func(*iam.ListServerCertificatesOutput, bool) bool) error {
+	i.mu.Lock()
+	var names []string
+	for name := range i.serverCertificates {
+		names = append(names, name)
+	}
+	i.mu.Unlock()
+	sort.Strings(names)
+	page := &iam.ListServerCertificatesOutput{IsTruncated: aws.Bool(false)}
+	for _, name := range names {
+		page.ServerCertificateMetadataList = append(page.ServerCertificateMetadataList, serverCertificateMetadata(name))
+	}
+	fn(page, true)
+	return nil
+}
+
+// SimulatePrincipalPolicyPages evaluates in.ActionNames on each of in.ResourceArns, or on any
+// resource without them, in a single page. An action is denied on "<action> on <resource>" too.
+func (i *IAM) SimulatePrincipalPolicyPages(in *iam.SimulatePrincipalPolicyInput, fn func(*iam.SimulatePolicyResponse, bool) bool) error {
+	i.mu.Lock()
+	resources := aws.StringValueSlice(in.ResourceArns)
+	if len(resources) == 0 {
+		resources = []string{"*"}
+	}
+	page := &iam.SimulatePolicyResponse{IsTruncated: aws.Bool(false)}
+	for _, action := range in.ActionNames {
+		for _, resource := range resources {
+			decision := iam.PolicyEvaluationDecisionTypeAllowed
+			if i.denied[*action] || i.denied[*action+" on "+resource] {
+				decision = iam.PolicyEvaluationDecisionTypeImplicitDeny
+			}
+			page.EvaluationResults = append(page.EvaluationResults, &iam.EvaluationResult{
+				EvalActionName:   aws.String(*action),
+				EvalDecision:     aws.String(decision),
+				EvalResourceName: aws.String(resource),
+			})
+		}
+	}
+	i.mu.Unlock()
+	fn(page, true)
+	return nil
+}
@@ -0,0 +1,275 @@
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+)
+
+// Route53 is an in-memory route53iface.Route53API holding hosted zones, their records and health
+// checks. Hosted zones aren't managed by the controller, so tests add them with AddHostedZone.
+// Changes are reported pending when submitted and in sync from then on.
+type Route53 struct {
+	route53iface.Route53API
+
+	mu           sync.Mutex
+	ids          ids
+	zones        map[string]*route53.HostedZone
+	records      map[string]map[string]*route53.ResourceRecordSet
+	healthChecks map[string]*route53.HealthCheck
+	healthTags   map[string][]*route53.Tag
+}
+
+// NewRoute53 returns a Route53 without any hosted zones or health checks.
+func NewRoute53() *Route53 {
+	return &Route53{
+		zones:        make(map[string]*route53.HostedZone),
+		records:      make(map[string]map[string]*route53.ResourceRecordSet),
+		healthChecks: make(map[string]*route53.HealthCheck),
+		healthTags:   make(map[string][]*route53.Tag),
+	}
+}
+
+// fqdn returns name with the trailing dot Route 53 describes names with.
+func fqdn(name string) string {
+	return strings.TrimSuffix(name, ".") + "."
+}
+
+// recordKey identifies a record by its name, type and set identifier.
+func recordKey(r *route53.ResourceRecordSet) string {
+	return fmt.Sprintf("%s %s %s", fqdn(*r.Name), *r.Type, aws.StringValue(r.SetIdentifier))
+}
+
+// AddHostedZone adds a public hosted zone for the domain name and returns its ID.
+func (r *Route53) AddHostedZone(name string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := "/hostedzone/Z" + strings.ToUpper(r.ids.next())
+	r.zones[id] = &route53.HostedZone{
+		CallerReference: aws.String(id),
+		Config:          &route53.HostedZoneConfig{PrivateZone: aws.Bool(false)},
+		Id:              aws.String(id),
+		Name:            aws.String(fqdn(name)),
+	}
+	r.records[id] = make(map[string]*route53.ResourceRecordSet)
+	return id
+}
+
+// Records returns the records of the hosted zone zoneID in order of name.
+func (r *Route53) Records(zoneID string) []*route53.ResourceRecordSet {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sortedRecords(zoneID)
+}
+
+func (r *Route53) sortedRecords(zoneID string) []*route53.ResourceRecordSet {
+	var records []*route53.ResourceRecordSet
+	for _, record := range r.records[zoneID] {
+		records = append(records, copyOf(record).(*route53.ResourceRecordSet))
+	}
+	sort.Slice(records, func(i, j int) bool { return recordKey(records[i]) < recordKey(records[j]) })
+	return records
+}
+
+// zone returns the hosted zone id, which is described with or without the /hostedzone/ prefix.
+func (r *Route53) zone(id *string) (string, error) {
+	key := "/hostedzone/" + strings.TrimPrefix(aws.StringValue(id), "/hostedzone/")
+	if _, ok := r.zones[key]; !ok {
+		return "", awserr.New(route53.ErrCodeNoSuchHostedZone, fmt.Sprintf("No hosted zone found with ID: %s", aws.StringValue(id)), nil)
+	}
+	return key, nil
+}
+
+// ListHostedZonesByName returns the hosted zones in order of name, starting at in.DNSName.
+func (r *Route53) ListHostedZonesByName(in *route53.ListHostedZonesByNameInput) (*route53.ListHostedZonesByNameOutput, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := &route53.ListHostedZonesByNameOutput{DNSName: in.DNSName, IsTruncated: aws.Bool(false)}
+	for _, zone := range r.zones {
+		if in.DNSName == nil || *zone.Name >= fqdn(*in.DNSName) {
+			out.HostedZones = append(out.HostedZones, copyOf(zone).(*route53.HostedZone))
+		}
+	}
+	sort.Slice(out.HostedZones, func(i, j int) bool { return *out.HostedZones[i].Name < *out.HostedZones[j].Name })
+	return out, nil
+}
+
+// ChangeResourceRecordSets applies all changes of in, or none of them when one is invalid.
+func (r *Route53) ChangeResourceRecordSets(in *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	zoneID, err := r.zone(in.HostedZoneId)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make(map[string]*route53.ResourceRecordSet)
+	for k, v := range r.records[zoneID] {
+		records[k] = v
+	}
+	for _, c := range in.ChangeBatch.Changes {
+		key := recordKey(c.ResourceRecordSet)
+		_, exists := records[key]
+		switch *c.Action {
+		case route53.ChangeActionCreate:
+			if exists {
+				return nil, awserr.New(route53.ErrCodeInvalidChangeBatch,
+					fmt.Sprintf("Tried to create resource record set %s but it already exists", key), nil)
+			}
+			fallthrough
+		case route53.ChangeActionUpsert:
+			record := copyOf(c.ResourceRecordSet).(*route53.ResourceRecordSet)
+			record.Name = aws.String(fqdn(*record.Name))
+			records[key] = record
+		case route53.ChangeActionDelete:
+			if !exists {
+				return nil, awserr.New(route53.ErrCodeInvalidChangeBatch,
+					fmt.Sprintf("Tried to delete resource record set %s but it was not found", key), nil)
+			}
+			delete(records, key)
+		}
+	}
+	r.records[zoneID] = records
+
+	now := time.Now()
+	return &route53.ChangeResourceRecordSetsOutput{ChangeInfo: &route53.ChangeInfo{
+		Id:          aws.String("/change/C" + strings.ToUpper(r.ids.next())),
+		Status:      aws.String(route53.ChangeStatusPending),
+		SubmittedAt: &now,
+	}}, nil
+}
+
+func (r *Route53) GetChange(in *route53.GetChangeInput) (*route53.GetChangeOutput, error) {
+	return &route53.GetChangeOutput{ChangeInfo: &route53.ChangeInfo{
+		Id:     aws.String(*in.Id),
+		Status: aws.String(route53.ChangeStatusInsync),
+	}}, nil
+}
+
+// ListResourceRecordSets returns the records of a hosted zone in order of name and type, starting
+// at in.StartRecordName and in.StartRecordType.
+func (r *Route53) ListResourceRecordSets(in *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	zoneID, err := r.zone(in.HostedZoneId)
+	if err != nil {
+		return nil, err
+	}
+	max := 100
+	if in.MaxItems != nil {
+		max, _ = strconv.Atoi(*in.MaxItems)
+	}
+
+	out := &route53.ListResourceRecordSetsOutput{IsTruncated: aws.Bool(false), MaxItems: aws.String(strconv.Itoa(max))}
+	for _, record := range r.sortedRecords(zoneID) {
+		if in.StartRecordName != nil && *record.Name < fqdn(*in.StartRecordName) ||
+			in.StartRecordName != nil && *record.Name == fqdn(*in.StartRecordName) && in.StartRecordType != nil && *record.Type < *in.StartRecordType {
+			continue
+		}
+		if len(out.ResourceRecordSets) == max {
+			out.IsTruncated = aws.Bool(true)
+			out.NextRecordName, out.NextRecordType, out.NextRecordIdentifier = record.Name, record.Type, record.SetIdentifier
+			break
+		}
+		out.ResourceRecordSets = append(out.ResourceRecordSets, record)
+	}
+	return out, nil
+}
+
+func (r *Route53) CreateHealthCheck(in *route53.CreateHealthCheckInput) (*route53.CreateHealthCheckOutput, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := r.ids.next()
+	h := &route53.HealthCheck{
+		CallerReference:    aws.String(*in.CallerReference),
+		HealthCheckConfig:  copyOf(in.HealthCheckConfig).(*route53.HealthCheckConfig),
+		HealthCheckVersion: aws.Int64(1),
+		Id:                 aws.String(id),
+	}
+	r.healthChecks[id] = h
+	return &route53.CreateHealthCheckOutput{HealthCheck: copyOf(h).(*route53.HealthCheck)}, nil
+}
+
+func (r *Route53) healthCheck(id *string) (*route53.HealthCheck, error) {
+	h, ok := r.healthChecks[aws.StringValue(id)]
+	if !ok {
+		return nil, awserr.New(route53.ErrCodeNoSuchHealthCheck, fmt.Sprintf("No health check exists with the specified ID %s", aws.StringValue(id)), nil)
+	}
+	return h, nil
+}
+
+func (r *Route53) ChangeTagsForResource(in *route53.ChangeTagsForResourceInput) (*route53.ChangeTagsForResourceOutput, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.healthCheck(in.ResourceId); err != nil {
+		return nil, err
+	}
+	var kept []*route53.Tag
+	for _, t := range r.healthTags[*in.ResourceId] {
+		if !contains(in.RemoveTagKeys, t.Key) {
+			kept = append(kept, t)
+		}
+	}
+	r.healthTags[*in.ResourceId] = append(kept, copyOf(in.AddTags).([]*route53.Tag)...)
+	return &route53.ChangeTagsForResourceOutput{}, nil
+}
+
+func (r *Route53) GetHealthCheck(in *route53.GetHealthCheckInput) (*route53.GetHealthCheckOutput, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, err := r.healthCheck(in.HealthCheckId)
+	if err != nil {
+		return nil, err
+	}
+	return &route53.GetHealthCheckOutput{HealthCheck: copyOf(h).(*route53.HealthCheck)}, nil
+}
+
+func (r *Route53) UpdateHealthCheck(in *route53.UpdateHealthCheckInput) (*route53.UpdateHealthCheckOutput, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, err := r.healthCheck(in.HealthCheckId)
+	if err != nil {
+		return nil, err
+	}
+	c := h.HealthCheckConfig
+	if in.FullyQualifiedDomainName != nil {
+		c.FullyQualifiedDomainName = aws.String(*in.FullyQualifiedDomainName)
+	}
+	if in.Port != nil {
+		c.Port = aws.Int64(*in.Port)
+	}
+	if in.ResourcePath != nil {
+		c.ResourcePath = aws.String(*in.ResourcePath)
+	}
+	if in.FailureThreshold != nil {
+		c.FailureThreshold = aws.Int64(*in.FailureThreshold)
+	}
+	h.HealthCheckVersion = aws.Int64(*h.HealthCheckVersion + 1)
+	return &route53.UpdateHealthCheckOutput{HealthCheck: copyOf(h).(*route53.HealthCheck)}, nil
+}
+
+func (r *Route53) DeleteHealthCheck(in *route53.DeleteHealthCheckInput) (*route53.DeleteHealthCheckOutput, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.healthCheck(in.HealthCheckId); err != nil {
+		return nil, err
+	}
+	for _, records := range r.records {
+		for _, record := range records {
+			if aws.StringValue(record.HealthCheckId) == *in.HealthCheckId {
+				return nil, awserr.New(route53.ErrCodeHealthCheckInUse, "The health check is still referenced by a record", nil)
+			}
+		}
+	}
+	delete(r.healthChecks, *in.HealthCheckId)
+	delete(r.healthTags, *in.HealthCheckId)
+	return &route53.DeleteHealthCheckOutput{}, nil
+}
@@ -0,0 +1,80 @@
+package fake
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+)
+
+// S3 is an in-memory S3, serving the calls of an awsutil.S3. It keeps buckets without objects.
+type S3 struct {
+	mu      sync.Mutex
+	buckets map[string]*Bucket
+}
+
+// Bucket is a bucket of the S3 fake.
+type Bucket struct {
+	Region         string
+	Policy         string
+	ExpirationDays int64 // days objects expire after, 0 without a lifecycle rule
+}
+
+// NewS3 returns an S3 without any buckets.
+func NewS3() *S3 {
+	return &S3{buckets: make(map[string]*Bucket)}
+}
+
+// AddBucket adds the bucket name.
+func (s *S3) AddBucket(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buckets[name] = &Bucket{Region: region}
+}
+
+// Bucket returns the bucket name, nil when it doesn't exist.
+func (s *S3) Bucket(name string) *Bucket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[name]
+	if !ok {
+		return nil
+	}
+	copied := *b
+	return &copied
+}
+
+func (s *S3) client() *client.Client {
+	return newClient("s3", s.serve)
+}
+
+func (s *S3) serve(operation string, in, out interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := aws.StringValue(stringField(in, "Bucket"))
+	b, ok := s.buckets[name]
+	switch {
+	case operation == "CreateBucket" && ok:
+		return awserr.New("BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it.", nil)
+	case operation == "CreateBucket":
+		b = &Bucket{Region: region}
+		if config := field(in, "CreateBucketConfiguration"); !config.IsNil() {
+			b.Region = aws.StringValue(config.Elem().FieldByName("LocationConstraint").Interface().(*string))
+		}
+		s.buckets[name] = b
+	case !ok && operation == "HeadBucket":
+		return awserr.New("NotFound", "Not Found", nil)
+	case !ok:
+		return awserr.New("NoSuchBucket", "The specified bucket does not exist", nil)
+	case operation == "HeadBucket":
+	case operation == "PutBucketPolicy":
+		b.Policy = aws.StringValue(stringField(in, "Policy"))
+	case operation == "PutBucketLifecycleConfiguration":
+		rule := field(in, "LifecycleConfiguration").Elem().FieldByName("Rules").Index(0).Elem()
+		b.ExpirationDays = aws.Int64Value(rule.FieldByName("Expiration").Elem().FieldByName("Days").Interface().(*int64))
+	default:
+		panic("fake S3 doesn't implement " + operation)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package fake
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+)
+
+// SQS is an in-memory SQS, serving the calls of an awsutil.SQS. Messages are received until
+// they're deleted, without a visibility timeout, and receiving doesn't wait for messages to arrive.
+type SQS struct {
+	mu       sync.Mutex
+	ids      ids
+	messages map[string][]*awsutil.SQSMessage
+}
+
+// NewSQS returns an SQS without any messages.
+func NewSQS() *SQS {
+	return &SQS{messages: make(map[string][]*awsutil.SQSMessage)}
+}
+
+// SendMessage adds a message with body to the queue queueURL.
+func (s *SQS) SendMessage(queueURL, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	message := &awsutil.SQSMessage{Body: aws.String(body), ReceiptHandle: aws.String(s.ids.next())}
+	s.messages[queueURL] = append(s.messages[queueURL], message)
+}
+
+// Messages returns the bodies of the messages of the queue queueURL that weren't deleted.
+func (s *SQS) Messages(queueURL string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var bodies []string
+	for _, message := range s.messages[queueURL] {
+		bodies = append(bodies, *message.Body)
+	}
+	return bodies
+}
+
+func (s *SQS) client() *client.Client {
+	return newClient("sqs", s.serve)
+}
+
+func (s *SQS) serve(operation string, in, out interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queueURL := *stringField(in, "QueueUrl")
+	switch operation {
+	case "ReceiveMessage":
+		messages := s.messages[queueURL]
+		if max := int(*field(in, "MaxNumberOfMessages").Interface().(*int64)); len(messages) > max {
+			messages = messages[:max]
+		}
+		field(out, "Messages").Set(reflect.ValueOf(copyOf(messages)))
+	case "DeleteMessage":
+		handle := stringField(in, "ReceiptHandle")
+		var kept []*awsutil.SQSMessage
+		for _, message := range s.messages[queueURL] {
+			if *message.ReceiptHandle != *handle {
+				kept = append(kept, message)
+			}
+		}
+		s.messages[queueURL] = kept
+	default:
+		panic("fake SQS doesn't implement " + operation)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package fake
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+)
+
+// Tagging is an in-memory Resource Groups Tagging API, serving the calls of an awsutil.Tagging. It
+// finds the ALBs and target groups of an ELBV2 fake by their tags. Every resource is returned in
+// one page.
+type Tagging struct {
+	elbv2 *ELBV2
+}
+
+// NewTagging returns a Tagging finding the resources of elbv2.
+func NewTagging(elbv2 *ELBV2) *Tagging {
+	return &Tagging{elbv2: elbv2}
+}
+
+func (t *Tagging) client() *client.Client {
+	return newClient("tagging", t.serve)
+}
+
+// resourceTypes are the resource types of the Resource Groups Tagging API, keyed by the resource
+// part of the ARNs of their resources.
+var resourceTypes = map[string]string{
+	":loadbalancer/app/": awsutil.ResourceTypeLoadBalancer,
+	":targetgroup/":      awsutil.ResourceTypeTargetGroup,
+}
+
+func (t *Tagging) serve(operation string, in, out interface{}) error {
+	if operation != "GetResources" {
+		panic("fake Tagging doesn't implement " + operation)
+	}
+	types := field(in, "ResourceTypeFilters").Interface().([]*string)
+	filters := field(in, "TagFilters")
+
+	t.elbv2.mu.Lock()
+	defer t.elbv2.mu.Unlock()
+	list := field(out, "ResourceTagMappingList")
+	for arn, tags := range t.elbv2.tags {
+		if !matches(types, aws.String(resourceType(arn))) || !matchesTagFilters(tags, filters) {
+			continue
+		}
+		mapping := reflect.New(list.Type().Elem().Elem())
+		mapping.Elem().FieldByName("ResourceARN").Set(reflect.ValueOf(aws.String(arn)))
+		mapping.Elem().FieldByName("Tags").Set(reflect.ValueOf(copyOf(tags)))
+		list.Set(reflect.Append(list, mapping))
+	}
+	return nil
+}
+
+// resourceType returns the Resource Groups Tagging API resource type of the resource arn.
+func resourceType(arn string) string {
+	for part, resourceType := range resourceTypes {
+		if strings.Contains(arn, part) {
+			return resourceType
+		}
+	}
+	return ""
+}
+
+// matchesTagFilters returns true when tags carry a value of every one of filters, a slice of
+// the tag filters of the awsutil Tagging client.
+func matchesTagFilters(tags []*elbv2.Tag, filters reflect.Value) bool {
+	for i := 0; i < filters.Len(); i++ {
+		filter := filters.Index(i).Interface()
+		key, values := stringField(filter, "Key"), field(filter, "Values").Interface().([]*string)
+		found := false
+		for _, tag := range tags {
+			if *tag.Key == *key && matches(values, tag.Value) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package fake
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+)
+
+// WAFRegional is an in-memory WAF Regional, serving the calls of an awsutil.WAFRegional. It keeps
+// the web ACL each ALB is associated with, without checking that either exists.
+type WAFRegional struct {
+	mu           sync.Mutex
+	associations map[string]string
+}
+
+// NewWAFRegional returns a WAFRegional without any associations.
+func NewWAFRegional() *WAFRegional {
+	return &WAFRegional{associations: make(map[string]string)}
+}
+
+// WebACL returns the ID of the web ACL the ALB arn is associated with, empty when there's none.
+func (w *WAFRegional) WebACL(arn string) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.associations[arn]
+}
+
+// Associate associates the ALB arn with the web ACL webACLID, as if done outside of the controller.
+func (w *WAFRegional) Associate(arn, webACLID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.associations[arn] = webACLID
+}
+
+func (w *WAFRegional) client() *client.Client {
+	return newClient("waf-regional", w.serve)
+}
+
+func (w *WAFRegional) serve(operation string, in, out interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	arn := *stringField(in, "ResourceArn")
+	switch operation {
+	case "AssociateWebACL":
+		w.associations[arn] = *stringField(in, "WebACLId")
+	case "DisassociateWebACL":
+		delete(w.associations, arn)
+	case "GetWebACLForResource":
+		if webACLID, ok := w.associations[arn]; ok {
+			summary := field(out, "WebACLSummary")
+			summary.Set(reflect.New(summary.Type().Elem()))
+			summary.Elem().FieldByName("WebACLId").Set(reflect.ValueOf(&webACLID))
+		}
+	default:
+		panic("fake WAFRegional doesn't implement " + operation)
+	}
+	return nil
+}
@@ -0,0 +1,486 @@
+package awsutil
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/jsonrpc"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/controller/util"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Global Accelerator only has an endpoint in us-west-2, whatever the region of its endpoints.
+const globalAcceleratorRegion = "us-west-2"
+
+// Statuses of an accelerator
+const (
+	AcceleratorStatusDeployed   = "DEPLOYED"
+	AcceleratorStatusInProgress = "IN_PROGRESS"
+)
+
+// GlobalAccelerator is a client of the Global Accelerator operations managing the accelerators and
+// endpoint groups ALBs are endpoints of, which the vendored aws-sdk-go has no client for.
+type GlobalAccelerator struct {
+	*client.Client
+}
+
+// Accelerator is a Global Accelerator accelerator, routing the traffic to its static IP addresses
+// to the endpoints of its listeners.
+type Accelerator struct {
+	_ struct{} `type:"structure"`
+
+	AcceleratorArn *string  `type:"string"`
+	DnsName        *string  `type:"string"`
+	Enabled        *bool    `type:"boolean"`
+	IpAddressType  *string  `type:"string"`
+	IpSets         []*IpSet `type:"list"`
+	Name           *string  `type:"string"`
+	Status         *string  `type:"string"`
+}
+
+// IpSet are the static IP addresses of an accelerator.
+type IpSet struct {
+	_ struct{} `type:"structure"`
+
+	IpAddresses []*string `type:"list"`
+	IpFamily    *string   `type:"string"`
+}
+
+// AcceleratorListener is a listener of an accelerator, forwarding the connections to its ports to
+// the endpoint groups of the listener.
+type AcceleratorListener struct {
+	_ struct{} `type:"structure"`
+
+	ClientAffinity *string      `type:"string"`
+	ListenerArn    *string      `type:"string"`
+	PortRanges     []*PortRange `type:"list"`
+	Protocol       *string      `type:"string"`
+}
+
+// PortRange is a range of the ports of an accelerator listener.
+type PortRange struct {
+	_ struct{} `type:"structure"`
+
+	FromPort *int64 `type:"integer"`
+	ToPort   *int64 `type:"integer"`
+}
+
+// EndpointGroup are the endpoints of an accelerator listener in one region.
+type EndpointGroup struct {
+	_ struct{} `type:"structure"`
+
+	EndpointDescriptions []*EndpointDescription `type:"list"`
+	EndpointGroupArn     *string                `type:"string"`
+	EndpointGroupRegion  *string                `type:"string"`
+}
+
+// EndpointDescription is an endpoint of an endpoint group, e.g. an ALB identified by its ARN.
+type EndpointDescription struct {
+	_ struct{} `type:"structure"`
+
+	ClientIPPreservationEnabled *bool   `type:"boolean"`
+	EndpointId                  *string `type:"string"`
+	HealthState                 *string `type:"string"`
+	Weight                      *int64  `type:"integer"`
+}
+
+// EndpointConfiguration is an endpoint to add to an endpoint group.
+type EndpointConfiguration struct {
+	_ struct{} `type:"structure"`
+
+	ClientIPPreservationEnabled *bool   `type:"boolean"`
+	EndpointId                  *string `type:"string"`
+	Weight                      *int64  `type:"integer"`
+}
+
+// NewGlobalAccelerator returns a GlobalAccelerator client based off of the provided AWS session.
+// Its calls are made to us-west-2, the only region of the Global Accelerator API.
+func NewGlobalAccelerator(awsSession *session.Session) *GlobalAccelerator {
+	c := awsSession.ClientConfig("globalaccelerator", &aws.Config{Region: aws.String(globalAcceleratorRegion)})
+	g := &GlobalAccelerator{
+		Client: client.New(
+			*c.Config,
+			metadata.ClientInfo{
+				ServiceName:   "globalaccelerator",
+				SigningName:   c.SigningName,
+				SigningRegion: c.SigningRegion,
+				Endpoint:      c.Endpoint,
+				APIVersion:    "2018-08-08",
+				JSONVersion:   "1.1",
+				TargetPrefix:  "GlobalAccelerator_V20180706",
+			},
+			c.Handlers,
+		),
+	}
+	g.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	g.Handlers.Build.PushBackNamed(jsonrpc.BuildHandler)
+	g.Handlers.Unmarshal.PushBackNamed(jsonrpc.UnmarshalHandler)
+	g.Handlers.UnmarshalMeta.PushBackNamed(jsonrpc.UnmarshalMetaHandler)
+	g.Handlers.UnmarshalError.PushBackNamed(jsonrpc.UnmarshalErrorHandler)
+	return g
+}
+
+// NewGlobalAcceleratorWithClient returns a GlobalAccelerator making its calls through c, e.g. one
+// served by an in-memory fake from the awsutil/fake package.
+func NewGlobalAcceleratorWithClient(c *client.Client) *GlobalAccelerator {
+	return &GlobalAccelerator{Client: c}
+}
+
+type listAcceleratorsInput struct {
+	_ struct{} `type:"structure"`
+
+	MaxResults *int64  `type:"integer"`
+	NextToken  *string `type:"string"`
+}
+
+type listAcceleratorsOutput struct {
+	_ struct{} `type:"structure"`
+
+	Accelerators []*Accelerator `type:"list"`
+	NextToken    *string        `type:"string"`
+}
+
+type describeAcceleratorInput struct {
+	_ struct{} `type:"structure"`
+
+	AcceleratorArn *string `type:"string"`
+}
+
+type describeAcceleratorOutput struct {
+	_ struct{} `type:"structure"`
+
+	Accelerator *Accelerator `type:"structure"`
+}
+
+type createAcceleratorInput struct {
+	_ struct{} `type:"structure"`
+
+	Enabled          *bool        `type:"boolean"`
+	IdempotencyToken *string      `type:"string"`
+	IpAddressType    *string      `type:"string"`
+	Name             *string      `type:"string"`
+	Tags             []*elbv2.Tag `type:"list"`
+}
+
+type createAcceleratorOutput struct {
+	_ struct{} `type:"structure"`
+
+	Accelerator *Accelerator `type:"structure"`
+}
+
+type updateAcceleratorInput struct {
+	_ struct{} `type:"structure"`
+
+	AcceleratorArn *string `type:"string"`
+	Enabled        *bool   `type:"boolean"`
+}
+
+type updateAcceleratorOutput struct {
+	_ struct{} `type:"structure"`
+
+	Accelerator *Accelerator `type:"structure"`
+}
+
+type deleteAcceleratorInput struct {
+	_ struct{} `type:"structure"`
+
+	AcceleratorArn *string `type:"string"`
+}
+
+type deleteAcceleratorOutput struct {
+	_ struct{} `type:"structure"`
+}
+
+type listTagsForResourceInput struct {
+	_ struct{} `type:"structure"`
+
+	ResourceArn *string `type:"string"`
+}
+
+type listTagsForResourceOutput struct {
+	_ struct{} `type:"structure"`
+
+	Tags []*elbv2.Tag `type:"list"`
+}
+
+type listListenersInput struct {
+	_ struct{} `type:"structure"`
+
+	AcceleratorArn *string `type:"string"`
+	MaxResults     *int64  `type:"integer"`
+	NextToken      *string `type:"string"`
+}
+
+type listListenersOutput struct {
+	_ struct{} `type:"structure"`
+
+	Listeners []*AcceleratorListener `type:"list"`
+	NextToken *string                `type:"string"`
+}
+
+type createListenerInput struct {
+	_ struct{} `type:"structure"`
+
+	AcceleratorArn   *string      `type:"string"`
+	IdempotencyToken *string      `type:"string"`
+	PortRanges       []*PortRange `type:"list"`
+	Protocol         *string      `type:"string"`
+}
+
+type createListenerOutput struct {
+	_ struct{} `type:"structure"`
+
+	Listener *AcceleratorListener `type:"structure"`
+}
+
+type updateListenerInput struct {
+	_ struct{} `type:"structure"`
+
+	ListenerArn *string      `type:"string"`
+	PortRanges  []*PortRange `type:"list"`
+}
+
+type updateListenerOutput struct {
+	_ struct{} `type:"structure"`
+
+	Listener *AcceleratorListener `type:"structure"`
+}
+
+type deleteListenerInput struct {
+	_ struct{} `type:"structure"`
+
+	ListenerArn *string `type:"string"`
+}
+
+type deleteListenerOutput struct {
+	_ struct{} `type:"structure"`
+}
+
+type listEndpointGroupsInput struct {
+	_ struct{} `type:"structure"`
+
+	ListenerArn *string `type:"string"`
+	MaxResults  *int64  `type:"integer"`
+	NextToken   *string `type:"string"`
+}
+
+type listEndpointGroupsOutput struct {
+	_ struct{} `type:"structure"`
+
+	EndpointGroups []*EndpointGroup `type:"list"`
+	NextToken      *string          `type:"string"`
+}
+
+type createEndpointGroupInput struct {
+	_ struct{} `type:"structure"`
+
+	EndpointConfigurations []*EndpointConfiguration `type:"list"`
+	EndpointGroupRegion    *string                  `type:"string"`
+	IdempotencyToken       *string                  `type:"string"`
+	ListenerArn            *string                  `type:"string"`
+}
+
+type createEndpointGroupOutput struct {
+	_ struct{} `type:"structure"`
+
+	EndpointGroup *EndpointGroup `type:"structure"`
+}
+
+type updateEndpointGroupInput struct {
+	_ struct{} `type:"structure"`
+
+	EndpointConfigurations []*EndpointConfiguration `type:"list"`
+	EndpointGroupArn       *string                  `type:"string"`
+}
+
+type updateEndpointGroupOutput struct {
+	_ struct{} `type:"structure"`
+
+	EndpointGroup *EndpointGroup `type:"structure"`
+}
+
+type deleteEndpointGroupInput struct {
+	_ struct{} `type:"structure"`
+
+	EndpointGroupArn *string `type:"string"`
+}
+
+type deleteEndpointGroupOutput struct {
+	_ struct{} `type:"structure"`
+}
+
+// send sends the request of operation, counting its errors in AWSErrorCount.
+func (g *GlobalAccelerator) send(operation string, in, out interface{}) error {
+	op := &request.Operation{Name: operation, HTTPMethod: "POST", HTTPPath: "/"}
+	if err := g.NewRequest(op, in, out).Send(); err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "GlobalAccelerator", "request": operation, "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+	return nil
+}
+
+// ListAccelerators returns every accelerator of the account.
+func (g *GlobalAccelerator) ListAccelerators() ([]*Accelerator, error) {
+	var accelerators []*Accelerator
+	in := &listAcceleratorsInput{MaxResults: aws.Int64(100)}
+	for {
+		out := &listAcceleratorsOutput{}
+		if err := g.send("ListAccelerators", in, out); err != nil {
+			return nil, err
+		}
+		accelerators = append(accelerators, out.Accelerators...)
+		if aws.StringValue(out.NextToken) == "" {
+			return accelerators, nil
+		}
+		in.NextToken = out.NextToken
+	}
+}
+
+// DescribeAccelerator returns the accelerator arn.
+func (g *GlobalAccelerator) DescribeAccelerator(arn *string) (*Accelerator, error) {
+	out := &describeAcceleratorOutput{}
+	if err := g.send("DescribeAccelerator", &describeAcceleratorInput{AcceleratorArn: arn}, out); err != nil {
+		return nil, err
+	}
+	return out.Accelerator, nil
+}
+
+// CreateAccelerator creates an enabled IPv4 accelerator named name, carrying tags. Creating it
+// again with the same name returns the accelerator created first instead.
+func (g *GlobalAccelerator) CreateAccelerator(name *string, tags util.Tags) (*Accelerator, error) {
+	in := &createAcceleratorInput{
+		Enabled:          aws.Bool(true),
+		IdempotencyToken: name,
+		IpAddressType:    aws.String("IPV4"),
+		Name:             name,
+		Tags:             tags,
+	}
+	out := &createAcceleratorOutput{}
+	if err := g.send("CreateAccelerator", in, out); err != nil {
+		return nil, err
+	}
+	return out.Accelerator, nil
+}
+
+// DisableAccelerator stops the accelerator arn from accepting traffic, which it must before it can
+// be deleted. The accelerator is IN_PROGRESS until it's disabled.
+func (g *GlobalAccelerator) DisableAccelerator(arn *string) (*Accelerator, error) {
+	out := &updateAcceleratorOutput{}
+	if err := g.send("UpdateAccelerator", &updateAcceleratorInput{AcceleratorArn: arn, Enabled: aws.Bool(false)}, out); err != nil {
+		return nil, err
+	}
+	return out.Accelerator, nil
+}
+
+// DeleteAccelerator deletes the accelerator arn, which must be disabled and DEPLOYED, without any
+// listeners.
+func (g *GlobalAccelerator) DeleteAccelerator(arn *string) error {
+	return g.send("DeleteAccelerator", &deleteAcceleratorInput{AcceleratorArn: arn}, &deleteAcceleratorOutput{})
+}
+
+// ListTagsForResource returns the tags of the accelerator arn.
+func (g *GlobalAccelerator) ListTagsForResource(arn *string) (util.Tags, error) {
+	out := &listTagsForResourceOutput{}
+	if err := g.send("ListTagsForResource", &listTagsForResourceInput{ResourceArn: arn}, out); err != nil {
+		return nil, err
+	}
+	return util.Tags(out.Tags), nil
+}
+
+// ListListeners returns the listeners of the accelerator arn.
+func (g *GlobalAccelerator) ListListeners(arn *string) ([]*AcceleratorListener, error) {
+	var listeners []*AcceleratorListener
+	in := &listListenersInput{AcceleratorArn: arn, MaxResults: aws.Int64(100)}
+	for {
+		out := &listListenersOutput{}
+		if err := g.send("ListListeners", in, out); err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, out.Listeners...)
+		if aws.StringValue(out.NextToken) == "" {
+			return listeners, nil
+		}
+		in.NextToken = out.NextToken
+	}
+}
+
+// CreateListener creates a TCP listener of the accelerator arn on ports.
+func (g *GlobalAccelerator) CreateListener(arn *string, ports []*PortRange) (*AcceleratorListener, error) {
+	in := &createListenerInput{
+		AcceleratorArn:   arn,
+		IdempotencyToken: arn,
+		PortRanges:       ports,
+		Protocol:         aws.String("TCP"),
+	}
+	out := &createListenerOutput{}
+	if err := g.send("CreateListener", in, out); err != nil {
+		return nil, err
+	}
+	return out.Listener, nil
+}
+
+// UpdateListenerPorts replaces the ports of the accelerator listener arn.
+func (g *GlobalAccelerator) UpdateListenerPorts(arn *string, ports []*PortRange) (*AcceleratorListener, error) {
+	out := &updateListenerOutput{}
+	if err := g.send("UpdateListener", &updateListenerInput{ListenerArn: arn, PortRanges: ports}, out); err != nil {
+		return nil, err
+	}
+	return out.Listener, nil
+}
+
+// DeleteListener deletes the accelerator listener arn, which must not have any endpoint groups.
+func (g *GlobalAccelerator) DeleteListener(arn *string) error {
+	return g.send("DeleteListener", &deleteListenerInput{ListenerArn: arn}, &deleteListenerOutput{})
+}
+
+// ListEndpointGroups returns the endpoint groups of the accelerator listener arn.
+func (g *GlobalAccelerator) ListEndpointGroups(arn *string) ([]*EndpointGroup, error) {
+	var groups []*EndpointGroup
+	in := &listEndpointGroupsInput{ListenerArn: arn, MaxResults: aws.Int64(100)}
+	for {
+		out := &listEndpointGroupsOutput{}
+		if err := g.send("ListEndpointGroups", in, out); err != nil {
+			return nil, err
+		}
+		groups = append(groups, out.EndpointGroups...)
+		if aws.StringValue(out.NextToken) == "" {
+			return groups, nil
+		}
+		in.NextToken = out.NextToken
+	}
+}
+
+// CreateEndpointGroup creates the endpoint group of the accelerator listener arn in region, with
+// endpoints.
+func (g *GlobalAccelerator) CreateEndpointGroup(arn, region *string, endpoints []*EndpointConfiguration) (*EndpointGroup, error) {
+	in := &createEndpointGroupInput{
+		EndpointConfigurations: endpoints,
+		EndpointGroupRegion:    region,
+		IdempotencyToken:       aws.String(aws.StringValue(arn) + "/" + aws.StringValue(region)),
+		ListenerArn:            arn,
+	}
+	out := &createEndpointGroupOutput{}
+	if err := g.send("CreateEndpointGroup", in, out); err != nil {
+		return nil, err
+	}
+	return out.EndpointGroup, nil
+}
+
+// UpdateEndpoints replaces the endpoints of the endpoint group arn.
+func (g *GlobalAccelerator) UpdateEndpoints(arn *string, endpoints []*EndpointConfiguration) (*EndpointGroup, error) {
+	out := &updateEndpointGroupOutput{}
+	in := &updateEndpointGroupInput{EndpointGroupArn: arn, EndpointConfigurations: endpoints}
+	if err := g.send("UpdateEndpointGroup", in, out); err != nil {
+		return nil, err
+	}
+	return out.EndpointGroup, nil
+}
+
+// DeleteEndpointGroup deletes the endpoint group arn.
+func (g *GlobalAccelerator) DeleteEndpointGroup(arn *string) error {
+	return g.send("DeleteEndpointGroup", &deleteEndpointGroupInput{EndpointGroupArn: arn}, &deleteEndpointGroupOutput{})
+}
@@ -0,0 +1,82 @@
+package awsutil
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+// Default number of consecutive failed heartbeats before the controller isn't ready any more
+const defaultHeartbeatThreshold = 3
+
+// credentialErrorCodes are the error codes of calls made with credentials that expired or can't be
+// found, which no retry fixes.
+var credentialErrorCodes = []string{"ExpiredToken", "ExpiredTokenException", "NoCredentialProviders"}
+
+// Heartbeat tracks whether the controller can still talk to AWS, from the outcome of a cheap read
+// call made on an interval, e.g. EC2's DescribeAccountAttributes.
+type Heartbeat struct {
+	threshold int
+	call      func() error
+
+	mu       sync.Mutex
+	failures int
+	err      error
+}
+
+// NewHeartbeat returns a Heartbeat making call on every beat, failing its check after threshold
+// consecutive failed calls, or right away when the credentials expired. A threshold that isn't
+// positive is the default.
+func NewHeartbeat(threshold int, call func() error) *Heartbeat {
+	if threshold <= 0 {
+		threshold = defaultHeartbeatThreshold
+	}
+	return &Heartbeat{threshold: threshold, call: call}
+}
+
+// Beat makes the heartbeat call and records its outcome.
+func (h *Heartbeat) Beat() {
+	err := h.call()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err == nil {
+		if h.failures >= h.threshold || h.credentialsExpired() {
+			glog.Infof("AWS heartbeat succeeded after %d failures.", h.failures)
+		}
+		h.failures = 0
+		h.err = nil
+		AWSHeartbeatFailures.Set(0)
+		return
+	}
+	h.failures++
+	h.err = err
+	AWSHeartbeatFailures.Set(float64(h.failures))
+	glog.Warningf("AWS heartbeat failed (%d consecutive failures): %s", h.failures, err.Error())
+}
+
+// Check returns an error once the heartbeat failed threshold times in a row, or failed because the
+// credentials expired.
+func (h *Heartbeat) Check() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.credentialsExpired() {
+		return fmt.Errorf("AWS credentials are expired or missing: %s", h.err.Error())
+	}
+	if h.failures >= h.threshold {
+		return fmt.Errorf("%d consecutive AWS heartbeats failed, last error: %s", h.failures, h.err.Error())
+	}
+	return nil
+}
+
+// credentialsExpired reports whether the last heartbeat failed because of the credentials.
+func (h *Heartbeat) credentialsExpired() bool {
+	code := ErrorCode(h.err)
+	for _, c := range credentialErrorCodes {
+		if code == c {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package awsutil
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+func TestHeartbeat(t *testing.T) {
+	var err error
+	h := NewHeartbeat(2, func() error { return err })
+
+	h.Beat()
+	if e := h.Check(); e != nil {
+		t.Fatalf("Check() = %v after a successful heartbeat", e)
+	}
+
+	err = errors.New("dial tcp: i/o timeout")
+	h.Beat()
+	if e := h.Check(); e != nil {
+		t.Errorf("Check() = %v before reaching the threshold", e)
+	}
+	h.Beat()
+	if e := h.Check(); e == nil {
+		t.Errorf("Check() = nil after %d consecutive failures", h.threshold)
+	}
+
+	err = nil
+	h.Beat()
+	if e := h.Check(); e != nil {
+		t.Errorf("Check() = %v once the heartbeat succeeded again", e)
+	}
+
+	// Expired credentials fail the check right away.
+	err = awserr.New("ExpiredToken", "The security token included in the request is expired", nil)
+	h.Beat()
+	if e := h.Check(); e == nil {
+		t.Errorf("Check() = nil with expired credentials")
+	}
+}
@@ -1,23 +1,35 @@
 package awsutil
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/karlseguin/ccache"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // IAM is our extension to AWS's IAM.iam
 type IAM struct {
-	Svc iamiface.IAMAPI
+	Svc   iamiface.IAMAPI
+	cache APICache
 }
 
 // NewIAM returns an IAM based off of the provided aws.Config
 func NewIAM(awsSession *session.Session) *IAM {
+	return NewIAMWithClient(iam.New(awsSession))
+}
+
+// NewIAMWithClient returns an IAM making its calls through svc.
+func NewIAMWithClient(svc iamiface.IAMAPI) *IAM {
 	iamClient := IAM{
-		iam.New(awsSession),
+		svc,
+		APICache{ccache.New(ccache.Configure())},
 	}
 	return &iamClient
 }
@@ -34,3 +46,103 @@ func (i *IAM) CertExists(arn *string) bool {
 	}
 	return true
 }
+
+// DescribeServerCertificates returns every IAM server certificate, along with its certificate body.
+// The certificates are cached for a few minutes.
+func (i *IAM) DescribeServerCertificates() ([]*iam.ServerCertificate, error) {
+	if item := i.cache.Get("servercertificates"); item != nil {
+		AWSCache.With(prometheus.Labels{"cache": "servercertificate", "action": "hit"}).Add(float64(1))
+		return item.Value().([]*iam.ServerCertificate), nil
+	}
+	AWSCache.With(prometheus.Labels{"cache": "servercertificate", "action": "miss"}).Add(float64(1))
+
+	var names []*string
+	err := i.Svc.ListServerCertificatesPages(&iam.ListServerCertificatesInput{}, func(page *iam.ListServerCertificatesOutput, lastPage bool) bool {
+		for _, metadata := range page.ServerCertificateMetadataList {
+			names = append(names, metadata.ServerCertificateName)
+		}
+		return true
+	})
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "IAM", "request": "ListServerCertificates", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+
+	var certificates []*iam.ServerCertificate
+	for _, name := range names {
+		o, err := i.Svc.GetServerCertificate(&iam.GetServerCertificateInput{ServerCertificateName: name})
+		if err != nil {
+			AWSErrorCount.With(
+				prometheus.Labels{"service": "IAM", "request": "GetServerCertificate", "code": ErrorCode(err)}).Add(float64(1))
+			return nil, err
+		}
+		certificates = append(certificates, o.ServerCertificate)
+	}
+	i.cache.Set("servercertificates", certificates, time.Minute*5)
+	return certificates, nil
+}
+
+// DeniedActions simulates the IAM policies of the principal principalArn, a user or role, and
+// returns the actions of actions it isn't allowed to call. Actions with resources in resourceArns
+// are simulated on each of them, and returned as "<action> on <resource>" for those they're denied
+// on; the others are simulated on any resource.
+func (i *IAM) DeniedActions(principalArn string, actions []string, resourceArns map[string][]string) ([]string, error) {
+	var denied, anyResource []string
+	collect := func(o *iam.SimulatePolicyResponse, _ bool) bool {
+		for _, result := range o.EvaluationResults {
+			if aws.StringValue(result.EvalDecision) == iam.PolicyEvaluationDecisionTypeAllowed {
+				continue
+			}
+			if resource := aws.StringValue(result.EvalResourceName); resource != "" && resource != "*" {
+				denied = append(denied, fmt.Sprintf("%s on %s", *result.EvalActionName, resource))
+				continue
+			}
+			denied = append(denied, *result.EvalActionName)
+		}
+		return true
+	}
+	for _, action := range actions {
+		resources := resourceArns[action]
+		if len(resources) == 0 {
+			anyResource = append(anyResource, action)
+			continue
+		}
+		in := &iam.SimulatePrincipalPolicyInput{
+			PolicySourceArn: aws.String(principalArn),
+			ActionNames:     aws.StringSlice([]string{action}),
+			ResourceArns:    aws.StringSlice(resources),
+		}
+		if err := i.Svc.SimulatePrincipalPolicyPages(in, collect); err != nil {
+			return nil, err
+		}
+	}
+	in := &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(principalArn),
+		ActionNames:     aws.StringSlice(anyResource),
+	}
+	err := i.Svc.SimulatePrincipalPolicyPages(in, collect)
+	return denied, err
+}
+
+// CallerArn returns the ARN of the IAM principal awsSession acts as. For an assumed role, e.g. the
+// role of the instance profile, that's the ARN of the role rather than of the session.
+func CallerArn(awsSession *session.Session) (string, error) {
+	o, err := sts.New(awsSession).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return principalArn(*o.Arn), nil
+}
+
+// principalArn returns the ARN of the role of an assumed role session ARN such as
+// arn:aws:sts::123456789012:assumed-role/name/session. Other ARNs are returned as they are. The ARN
+// of the session doesn't hold the path of the role, so the role is expected to have none.
+func principalArn(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[2] != "sts" || !strings.HasPrefix(parts[5], "assumed-role/") {
+		return arn
+	}
+	name := strings.Split(parts[5], "/")[1]
+	return fmt.Sprintf("%s:%s:iam::%s:role/%s", parts[0], parts[1], parts[4], name)
+}
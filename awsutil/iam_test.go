@@ -0,0 +1,21 @@
+package awsutil
+
+import "testing"
+
+func TestPrincipalArn(t *testing.T) {
+	var tests = []struct {
+		arn       string
+		principal string
+	}{
+		{"arn:aws:sts::123456789012:assumed-role/alb-ingress/i-0123456789abcdef0", "arn:aws:iam::123456789012:role/alb-ingress"},
+		{"arn:aws-cn:sts::123456789012:assumed-role/alb-ingress/session", "arn:aws-cn:iam::123456789012:role/alb-ingress"},
+		{"arn:aws:iam::123456789012:user/deploy", "arn:aws:iam::123456789012:user/deploy"},
+		{"arn:aws:sts::123456789012:federated-user/deploy", "arn:aws:sts::123456789012:federated-user/deploy"},
+	}
+
+	for _, tt := range tests {
+		if principal := principalArn(tt.arn); principal != tt.principal {
+			t.Errorf("principalArn(%v) returned %q, expected %q", tt.arn, principal, tt.principal)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package awsutil
+
+import "encoding/json"
+
+// States of EC2 instances, as state-change notifications report them.
+const (
+	InstanceStateRunning      = "running"
+	InstanceStateShuttingDown = "shutting-down"
+	InstanceStateTerminated   = "terminated"
+	InstanceStateStopping     = "stopping"
+	InstanceStateStopped      = "stopped"
+)
+
+// instanceStateChangeDetailType is the detail-type of EventBridge events of EC2 instances changing
+// state.
+const instanceStateChangeDetailType = "EC2 Instance State-change Notification"
+
+// InstanceStateChange is an EC2 instance changing state.
+type InstanceStateChange struct {
+	InstanceID string `json:"instance-id"`
+	State      string `json:"state"`
+}
+
+// instanceEventEnvelope is an EventBridge event an InstanceStateChange is parsed from.
+type instanceEventEnvelope struct {
+	DetailType string              `json:"detail-type"`
+	Detail     InstanceStateChange `json:"detail"`
+}
+
+// ParseInstanceStateChange parses the EventBridge event body. Events other than state-change
+// notifications are returned as nil.
+func ParseInstanceStateChange(body string) (*InstanceStateChange, error) {
+	var envelope instanceEventEnvelope
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil {
+		return nil, err
+	}
+	if envelope.DetailType != instanceStateChangeDetailType || envelope.Detail.InstanceID == "" {
+		return nil, nil
+	}
+	return &envelope.Detail, nil
+}
@@ -0,0 +1,42 @@
+package awsutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseInstanceStateChange(t *testing.T) {
+	var tests = []struct {
+		body     string
+		expected *InstanceStateChange
+		pass     bool
+	}{
+		{
+			`{"detail-type": "EC2 Instance State-change Notification", "source": "aws.ec2",
+  "detail": {"instance-id": "i-0123456789abcdef0", "state": "stopping"}}`,
+			&InstanceStateChange{"i-0123456789abcdef0", InstanceStateStopping},
+			true,
+		},
+		{
+			`{"detail-type": "EC2 Spot Instance Interruption Warning", "source": "aws.ec2",
+  "detail": {"instance-id": "i-0123456789abcdef0", "instance-action": "terminate"}}`,
+			nil,
+			true,
+		},
+		{`{"detail-type": "EC2 Instance State-change Notification", "detail": {}}`, nil, true},
+		{`[]`, nil, false},
+	}
+
+	for _, tt := range tests {
+		change, err := ParseInstanceStateChange(tt.body)
+		if err != nil && tt.pass {
+			t.Errorf("ParseInstanceStateChange(%s): returned error %v", tt.body, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("ParseInstanceStateChange(%s): expected an error", tt.body)
+		}
+		if !reflect.DeepEqual(change, tt.expected) {
+			t.Errorf("ParseInstanceStateChange(%s): expected %+v, actual %+v", tt.body, tt.expected, change)
+		}
+	}
+}
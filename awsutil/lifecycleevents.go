@@ -0,0 +1,42 @@
+package awsutil
+
+import "encoding/json"
+
+// lifecycleTransitionTerminating is the transition of the lifecycle actions of instances being
+// terminated.
+const lifecycleTransitionTerminating = "autoscaling:EC2_INSTANCE_TERMINATING"
+
+// LifecycleAction is a pending Auto Scaling lifecycle action, holding back the transition of an
+// instance until it's completed or its hook times out.
+type LifecycleAction struct {
+	HookName   string `json:"LifecycleHookName"`
+	GroupName  string `json:"AutoScalingGroupName"`
+	InstanceID string `json:"EC2InstanceId"`
+	Token      string `json:"LifecycleActionToken"`
+	Transition string `json:"LifecycleTransition"`
+}
+
+// lifecycleEventEnvelope is a lifecycle notification as Auto Scaling sends it to SQS, or as
+// EventBridge delivers it, with the action in its detail.
+type lifecycleEventEnvelope struct {
+	LifecycleAction
+	Detail *LifecycleAction `json:"detail"`
+}
+
+// ParseTerminationAction parses the lifecycle notification body, sent to SQS by Auto Scaling or by
+// an EventBridge rule. Notifications other than the actions of instances being terminated, e.g.
+// the test notification sent when a hook is created, are returned as nil.
+func ParseTerminationAction(body string) (*LifecycleAction, error) {
+	var envelope lifecycleEventEnvelope
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil {
+		return nil, err
+	}
+	action := &envelope.LifecycleAction
+	if envelope.Detail != nil {
+		action = envelope.Detail
+	}
+	if action.Transition != lifecycleTransitionTerminating || action.InstanceID == "" {
+		return nil, nil
+	}
+	return action, nil
+}
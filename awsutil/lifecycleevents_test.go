@@ -0,0 +1,91 @@
+package awsutil
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestParseTerminationAction(t *testing.T) {
+	action := &LifecycleAction{"drain", "nodes", "i-0123456789abcdef0", "token-1", lifecycleTransitionTerminating}
+	var tests = []struct {
+		body     string
+		expected *LifecycleAction
+		pass     bool
+	}{
+		{
+			`{"Service": "AWS Auto Scaling", "LifecycleHookName": "drain", "AutoScalingGroupName": "nodes",
+  "EC2InstanceId": "i-0123456789abcdef0", "LifecycleActionToken": "token-1",
+  "LifecycleTransition": "autoscaling:EC2_INSTANCE_TERMINATING"}`,
+			action,
+			true,
+		},
+		{
+			`{"source": "aws.autoscaling", "detail-type": "EC2 Instance-terminate Lifecycle Action", "detail": {
+  "LifecycleHookName": "drain", "AutoScalingGroupName": "nodes", "EC2InstanceId": "i-0123456789abcdef0",
+  "LifecycleActionToken": "token-1", "LifecycleTransition": "autoscaling:EC2_INSTANCE_TERMINATING"}}`,
+			action,
+			true,
+		},
+		{`{"Service": "AWS Auto Scaling", "Event": "autoscaling:TEST_NOTIFICATION", "AutoScalingGroupName": "nodes"}`, nil, true},
+		{
+			`{"LifecycleHookName": "warmup", "EC2InstanceId": "i-0123456789abcdef0",
+  "LifecycleTransition": "autoscaling:EC2_INSTANCE_LAUNCHING"}`,
+			nil,
+			true,
+		},
+		{`{"detail": `, nil, false},
+	}
+
+	for _, tt := range tests {
+		parsed, err := ParseTerminationAction(tt.body)
+		if err != nil && tt.pass {
+			t.Errorf("ParseTerminationAction(%s): returned error %v", tt.body, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("ParseTerminationAction(%s): expected an error", tt.body)
+		}
+		if !reflect.DeepEqual(parsed, tt.expected) {
+			t.Errorf("ParseTerminationAction(%s): expected %+v, actual %+v", tt.body, tt.expected, parsed)
+		}
+	}
+}
+
+func TestCompleteLifecycleAction(t *testing.T) {
+	var form map[string][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+		fmt.Fprint(w, `<CompleteLifecycleActionResponse><CompleteLifecycleActionResult/>
+<ResponseMetadata><RequestId>1</RequestId></ResponseMetadata></CompleteLifecycleActionResponse>`)
+	}))
+	defer server.Close()
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+	action := &LifecycleAction{"drain", "nodes", "i-0123456789abcdef0", "token-1", lifecycleTransitionTerminating}
+	if err := NewAutoScaling(sess).CompleteLifecycleAction(action, LifecycleActionResultContinue); err != nil {
+		t.Fatalf("CompleteLifecycleAction(): returned error %v", err)
+	}
+	expected := map[string][]string{
+		"Action":                {"CompleteLifecycleAction"},
+		"Version":               {"2011-01-01"},
+		"AutoScalingGroupName":  {"nodes"},
+		"InstanceId":            {"i-0123456789abcdef0"},
+		"LifecycleActionResult": {"CONTINUE"},
+		"LifecycleActionToken":  {"token-1"},
+		"LifecycleHookName":     {"drain"},
+	}
+	if !reflect.DeepEqual(form, expected) {
+		t.Errorf("CompleteLifecycleAction(): expected %v, actual %v", expected, form)
+	}
+}
@@ -0,0 +1,121 @@
+package awsutil
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+)
+
+// services holds the clients of the AWS services used to manage ingress resources, all acting
+// under the same credentials.
+type services struct {
+	albsvc     *ELBV2
+	ec2svc     *EC2
+	acmsvc     *ACM
+	route53svc *Route53
+	wafsvc     *WAFRegional
+	gasvc      *GlobalAccelerator
+}
+
+var (
+	// roleMu is held while ALBsvc, Ec2svc, ACMsvc, Route53svc, WAFsvc and GlobalAcceleratorsvc may
+	// be acting as a role, see AssumeRole.
+	roleMu sync.Mutex
+	// roleServices are the clients of each role assumed so far, keyed by role ARN
+	roleServices = make(map[string]*services)
+	// keepClients makes AssumeRoleIn keep the current clients in place for every role, see
+	// KeepClientsForRoles
+	keepClients bool
+)
+
+// AssumeRole swaps ALBsvc, Ec2svc, ACMsvc, Route53svc, WAFsvc and GlobalAcceleratorsvc for clients
+// acting as the IAM role roleArn, e.g. one in the AWS account of a tenant, until the returned
+// function is called. The clients are created from Session on first use, so they share its
+// handlers, and are reused afterwards. While swapped, other callers of AssumeRole block, so they
+// never act under the wrong credentials. An empty roleArn keeps the controller's own clients in
+// place, but still waits for other roles to be released.
+//
+//	defer awsutil.AssumeRole(roleArn)()
+//
+// AssumeRole must not be called again before the returned function was.
+func AssumeRole(roleArn string) func() {
+	return AssumeRoleIn(roleArn, "")
+}
+
+// AssumeRoleIn is like AssumeRole, but the clients make their calls to region. An empty region is
+// the region of Session.
+func AssumeRoleIn(roleArn, region string) func() {
+	roleMu.Lock()
+	if (roleArn == "" && region == "") || keepClients {
+		return roleMu.Unlock
+	}
+
+	defaults := &services{ALBsvc, Ec2svc, ACMsvc, Route53svc, WAFsvc, GlobalAcceleratorsvc}
+	key := roleArn + " " + region
+	s, ok := roleServices[key]
+	if !ok {
+		s = newRoleServices(roleArn, region, defaults)
+		roleServices[key] = s
+	}
+	ALBsvc, Ec2svc, ACMsvc, Route53svc, WAFsvc = s.albsvc, s.ec2svc, s.acmsvc, s.route53svc, s.wafsvc
+	GlobalAcceleratorsvc = s.gasvc
+
+	return func() {
+		ALBsvc, Ec2svc, ACMsvc, Route53svc, WAFsvc = defaults.albsvc, defaults.ec2svc, defaults.acmsvc, defaults.route53svc, defaults.wafsvc
+		GlobalAcceleratorsvc = defaults.gasvc
+		roleMu.Unlock()
+	}
+}
+
+// KeepClientsForRoles makes AssumeRole and AssumeRoleIn keep ALBsvc, Ec2svc, ACMsvc, Route53svc,
+// WAFsvc and GlobalAcceleratorsvc in place for every role and region until the returned function
+// is called, e.g. so the ingresses of namespaces with a role call the fakes of the awsutil/fake
+// package.
+func KeepClientsForRoles() func() {
+	roleMu.Lock()
+	defer roleMu.Unlock()
+	keepClients = true
+	return func() {
+		roleMu.Lock()
+		defer roleMu.Unlock()
+		keepClients = false
+	}
+}
+
+// newRoleServices returns clients acting as the IAM role roleArn in region, configured like
+// defaults. Target (de)registrations are paced together with the ones of defaults. Route 53 is left
+// out when it's disabled.
+func newRoleServices(roleArn, region string, defaults *services) *services {
+	config := &aws.Config{}
+	if roleArn != "" {
+		config.Credentials = stscreds.NewCredentials(Session, roleArn)
+	}
+	if region != "" {
+		config.Region = aws.String(region)
+	}
+	session := Session.Copy(config)
+
+	s := &services{
+		albsvc: NewELBV2(session),
+		ec2svc: NewEC2(session),
+		acmsvc: NewACM(session),
+	}
+	if defaults.albsvc != nil {
+		s.albsvc.targetBatchSize = defaults.albsvc.targetBatchSize
+		s.albsvc.targetLimiter = defaults.albsvc.targetLimiter
+		if defaults.albsvc.Tagging != nil {
+			s.albsvc.Tagging = NewTagging(session)
+		}
+	}
+	if defaults.route53svc != nil {
+		s.route53svc = NewRoute53(session)
+	}
+	if defaults.wafsvc != nil {
+		s.wafsvc = NewWAFRegional(session)
+	}
+	if defaults.gasvc != nil {
+		s.gasvc = NewGlobalAccelerator(session)
+	}
+	return s
+}
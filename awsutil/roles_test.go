@@ -0,0 +1,41 @@
+package awsutil
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestAssumeRole(t *testing.T) {
+	Session = session.New(&aws.Config{Region: aws.String("us-east-1")})
+	ALBsvc, Ec2svc, ACMsvc, Route53svc = NewELBV2(Session), NewEC2(Session), NewACM(Session), nil
+	defaults := ALBsvc
+	defer func() { Session, ALBsvc, Ec2svc, ACMsvc = nil, nil, nil, nil }()
+
+	restore := AssumeRole("")
+	if ALBsvc != defaults {
+		t.Errorf("AssumeRole with an empty role swapped the clients")
+	}
+	restore()
+
+	role := "arn:aws:iam::123456789012:role/tenant"
+	restore = AssumeRole(role)
+	assumed := ALBsvc
+	if assumed == defaults {
+		t.Fatalf("AssumeRole(%s) kept the controller's clients", role)
+	}
+	if Route53svc != nil {
+		t.Errorf("AssumeRole(%s) created a Route 53 client while Route 53 is disabled", role)
+	}
+	restore()
+	if ALBsvc != defaults {
+		t.Fatalf("the controller's clients weren't restored after AssumeRole(%s)", role)
+	}
+
+	restore = AssumeRole(role)
+	if ALBsvc != assumed {
+		t.Errorf("AssumeRole(%s) didn't reuse the clients of the role", role)
+	}
+	restore()
+}
@@ -36,8 +36,13 @@ type Route53 struct {
 
 // NewRoute53 returns a new Route53 based off of an AWS session
 func NewRoute53(awsSession *session.Session) *Route53 {
+	return NewRoute53WithClient(route53.New(awsSession))
+}
+
+// NewRoute53WithClient returns a new Route53 making its calls through svc.
+func NewRoute53WithClient(svc route53iface.Route53API) *Route53 {
 	r53 := Route53{
-		route53.New(awsSession),
+		svc,
 		APICache{ccache.New(ccache.Configure()), },
 	}
 	return &r53
@@ -72,7 +77,7 @@ func (r *Route53) GetZoneID(hostname *string) (*route53.HostedZone, error) {
 				DNSName: &hnAttempt,
 			})
 		if err != nil {
-			AWSErrorCount.With(prometheus.Labels{"service": "Route53", "request": "ListHostedZonesByName"}).Add(float64(1))
+			AWSErrorCount.With(prometheus.Labels{"service": "Route53", "request": "ListHostedZonesByName", "code": ErrorCode(err)}).Add(float64(1))
 			return nil, fmt.Errorf("Error calling route53.ListHostedZonesByName: %s", err)
 		}
 
@@ -86,7 +91,6 @@ func (r *Route53) GetZoneID(hostname *string) (*route53.HostedZone, error) {
 
 	}
 
-	AWSErrorCount.With(prometheus.Labels{"service": "Route53", "request": "GetZoneID"}).Add(float64(1))
 	r.cache.Set("r53zoneErr" + *hostname, "fail", time.Minute*60)
 	return nil, fmt.Errorf("Unable to find the zone using any subset of hostname: %s", *hostname)
 }
@@ -98,9 +102,10 @@ func (r *Route53) Modify(in route53.ChangeResourceRecordSetsInput) error {
 	o, err := r.Svc.ChangeResourceRecordSets(&in)
 	if err != nil {
 		AWSErrorCount.With(
-			prometheus.Labels{"service": "Route53", "request": "ChangeResourceRecordSets"}).Add(float64(1))
+			prometheus.Labels{"service": "Route53", "request": "ChangeResourceRecordSets", "code": ErrorCode(err)}).Add(float64(1))
 		return err
 	}
+	Route53ChangeBatches.With(prometheus.Labels{"action": route53.ChangeActionUpsert}).Add(float64(1))
 
 	if ok := r.verifyRecordCreated(*o.ChangeInfo.Id); !ok {
 		return fmt.Errorf("Failed Route 53 resource record set modification. Unable to verify DNS propagation. DNS: %s | Type: %s",
@@ -122,10 +127,83 @@ func (r *Route53) Delete(in route53.ChangeResourceRecordSetsInput) error {
 	_, err := r.Svc.ChangeResourceRecordSets(&in)
 	if err != nil && err.(awserr.Error).Code() != route53.ErrCodeInvalidChangeBatch {
 		AWSErrorCount.With(
-			prometheus.Labels{"service": "Route53", "request": "ChangeResourceRecordSets"}).Add(float64(1))
+			prometheus.Labels{"service": "Route53", "request": "ChangeResourceRecordSets", "code": ErrorCode(err)}).Add(float64(1))
 		return err
 	}
+	Route53ChangeBatches.With(prometheus.Labels{"action": route53.ChangeActionDelete}).Add(float64(1))
+
+	return nil
+}
+
+// CreateHealthCheck creates a Route 53 health check and tags it with the name it's shown under in
+// the console.
+func (r *Route53) CreateHealthCheck(config *route53.HealthCheckConfig, name string) (*route53.HealthCheck, error) {
+	// The caller reference only has to be unique, it makes retried requests idempotent.
+	reference := fmt.Sprintf("%d-%s", time.Now().UnixNano(), name)
+	if len(reference) > 64 {
+		reference = reference[:64]
+	}
+	o, err := r.Svc.CreateHealthCheck(&route53.CreateHealthCheckInput{
+		CallerReference:   aws.String(reference),
+		HealthCheckConfig: config,
+	})
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "Route53", "request": "CreateHealthCheck", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+
+	_, err = r.Svc.ChangeTagsForResource(&route53.ChangeTagsForResourceInput{
+		ResourceId:   o.HealthCheck.Id,
+		ResourceType: aws.String(route53.TagResourceTypeHealthcheck),
+		AddTags:      []*route53.Tag{{Key: aws.String("Name"), Value: aws.String(name)}},
+	})
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "Route53", "request": "ChangeTagsForResource", "code": ErrorCode(err)}).Add(float64(1))
+		return o.HealthCheck, err
+	}
+	return o.HealthCheck, nil
+}
+
+// UpdateHealthCheck changes the target of a Route 53 health check in place. Its type can't be
+// changed.
+func (r *Route53) UpdateHealthCheck(id *string, config *route53.HealthCheckConfig) (*route53.HealthCheck, error) {
+	o, err := r.Svc.UpdateHealthCheck(&route53.UpdateHealthCheckInput{
+		HealthCheckId:            id,
+		FullyQualifiedDomainName: config.FullyQualifiedDomainName,
+		Port:                     config.Port,
+		ResourcePath:             config.ResourcePath,
+		FailureThreshold:         config.FailureThreshold,
+	})
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "Route53", "request": "UpdateHealthCheck", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+	return o.HealthCheck, nil
+}
+
+// GetHealthCheck returns the Route 53 health check with the ID id.
+func (r *Route53) GetHealthCheck(id *string) (*route53.HealthCheck, error) {
+	o, err := r.Svc.GetHealthCheck(&route53.GetHealthCheckInput{HealthCheckId: id})
+	if err != nil {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "Route53", "request": "GetHealthCheck", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+	return o.HealthCheck, nil
+}
 
+// DeleteHealthCheck deletes a Route 53 health check. A health check that no longer exists is
+// considered deleted. Health checks still used by a record can't be deleted.
+func (r *Route53) DeleteHealthCheck(id *string) error {
+	_, err := r.Svc.DeleteHealthCheck(&route53.DeleteHealthCheckInput{HealthCheckId: id})
+	if err != nil && ErrorCode(err) != route53.ErrCodeNoSuchHealthCheck {
+		AWSErrorCount.With(
+			prometheus.Labels{"service": "Route53", "request": "DeleteHealthCheck", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
 	return nil
 }
 
@@ -161,6 +239,34 @@ func (r *Route53) DescribeResourceRecordSets(zoneID *string, hostname *string) (
 	return nil, fmt.Errorf("ListResourceRecordSets(%s, %s) did not return any valid records", *zoneID, *hostname)
 }
 
+// DescribeResourceRecordSetsByName returns the A, AAAA and CNAME records named hostname in a zone. A
+// name has several of them when they have a routing policy, each with its own set identifier.
+func (r *Route53) DescribeResourceRecordSetsByName(zoneID *string, hostname *string) ([]*route53.ResourceRecordSet, error) {
+	params := &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    zoneID,
+		MaxItems:        aws.String("100"),
+		StartRecordName: hostname,
+		StartRecordType: aws.String(route53.RRTypeA),
+	}
+
+	resp, err := r.Svc.ListResourceRecordSets(params)
+	if err != nil {
+		glog.Errorf("Failed to lookup resource record sets %s, with request %v", *hostname, params)
+		return nil, err
+	}
+
+	var records []*route53.ResourceRecordSet
+	for _, record := range resp.ResourceRecordSets {
+		if *record.Type != route53.RRTypeCname && *record.Type != route53.RRTypeA && *record.Type != route53.RRTypeAaaa {
+			continue
+		}
+		if strings.TrimSuffix(*record.Name, ".") == strings.TrimSuffix(*hostname, ".") {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
 // LookupExistingRecord returns the route53.ResourceRecordSet for a hostname
 func LookupExistingRecord(hostname *string) *route53.ResourceRecordSet {
 	// Lookup zone for hostname. Error is returned when zone cannot be found, a result of the
@@ -184,9 +290,12 @@ func (r *Route53) verifyRecordCreated(changeID string) bool {
 	created := false
 
 	// Attempt to verify the existence of the deisred Resource Record Set up to as many times defined in
-	// maxValidateRecordAttempts
+	// maxValidateRecordAttempts. The first attempt is made right away, so changes already in sync
+	// aren't waited for.
 	for i := 0; i < maxValidateRecordAttempts; i++ {
-		time.Sleep(time.Duration(validateSleepDuration) * time.Second)
+		if i > 0 {
+			time.Sleep(time.Duration(validateSleepDuration) * time.Second)
+		}
 		in := &route53.GetChangeInput{
 			Id: &changeID,
 		}
@@ -0,0 +1,141 @@
+package awsutil
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+// maxBatchUpserts caps the number of upserts in a change batch. Route 53 accepts up to 1000 records
+// per batch, and counts each upsert twice.
+const maxBatchUpserts = 500
+
+var (
+	// batchMu guards batching and batches
+	batchMu sync.Mutex
+	// batching is set while upserts are queued rather than made right away, see BeginRoute53Batch
+	batching bool
+	// batches are the queued upserts, per client and hosted zone
+	batches []*zoneBatch
+)
+
+// zoneBatch holds the upserts queued for a hosted zone, to be made by svc.
+type zoneBatch struct {
+	svc     *Route53
+	zoneID  string
+	upserts []*queuedUpsert
+}
+
+type queuedUpsert struct {
+	record *route53.ResourceRecordSet
+	lock   sync.Locker // held while done is called, nil for none
+	done   func(error)
+}
+
+// BeginRoute53Batch starts queueing the upserts of Upsert until FlushRoute53Batch is called, so the
+// records of many ingresses sharing a hosted zone are written in a few change batches, rather than
+// one batch per record.
+func BeginRoute53Batch() {
+	batchMu.Lock()
+	defer batchMu.Unlock()
+	batching = true
+}
+
+// FlushRoute53Batch writes the upserts queued since BeginRoute53Batch, in one change batch per
+// hosted zone of up to maxBatchUpserts records, and waits for each batch to propagate. Route 53
+// rejects a batch as a whole, so the upserts of a rejected batch are retried one by one, keeping a
+// single invalid record from failing the others. The done functions given to Upsert are then called
+// holding the lock given with them, and with Route53svc set to the client the upsert was queued
+// with, so they must not call AssumeRole.
+func FlushRoute53Batch() {
+	batchMu.Lock()
+	pending := batches
+	batches, batching = nil, false
+	batchMu.Unlock()
+
+	for _, b := range pending {
+		for len(b.upserts) > 0 {
+			n := len(b.upserts)
+			if n > maxBatchUpserts {
+				n = maxBatchUpserts
+			}
+			b.flush(b.upserts[:n])
+			b.upserts = b.upserts[n:]
+		}
+	}
+}
+
+// flush writes upserts in a single change batch, falling back to one batch per upsert when it's
+// rejected.
+func (b *zoneBatch) flush(upserts []*queuedUpsert) {
+	in := route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53.ChangeBatch{
+			Comment: aws.String("Managed by Kubernetes"),
+		},
+		HostedZoneId: aws.String(b.zoneID),
+	}
+	for _, u := range upserts {
+		in.ChangeBatch.Changes = append(in.ChangeBatch.Changes, &route53.Change{
+			Action:            aws.String(route53.ChangeActionUpsert),
+			ResourceRecordSet: u.record,
+		})
+	}
+
+	err := b.svc.Modify(in)
+	if err != nil && len(upserts) > 1 {
+		for _, u := range upserts {
+			b.flush([]*queuedUpsert{u})
+		}
+		return
+	}
+
+	for _, u := range upserts {
+		// The locks are taken before roleMu, as their holders call AssumeRole.
+		if u.lock != nil {
+			u.lock.Lock()
+		}
+		roleMu.Lock()
+		defaults := Route53svc
+		Route53svc = b.svc
+		u.done(err)
+		Route53svc = defaults
+		roleMu.Unlock()
+		if u.lock != nil {
+			u.lock.Unlock()
+		}
+	}
+}
+
+// Upsert creates or replaces record in the hosted zone zoneID and calls done with the outcome. While
+// a batch is open, the upsert is queued and done is called by FlushRoute53Batch, holding lock, e.g.
+// the one guarding the state done updates, unless it's nil. Otherwise it's made right away, and done
+// is called before Upsert returns, without taking lock.
+func (r *Route53) Upsert(zoneID *string, record *route53.ResourceRecordSet, lock sync.Locker, done func(error)) {
+	batchMu.Lock()
+	if batching {
+		defer batchMu.Unlock()
+		for _, b := range batches {
+			if b.svc == r && b.zoneID == *zoneID {
+				b.upserts = append(b.upserts, &queuedUpsert{record, lock, done})
+				return
+			}
+		}
+		batches = append(batches, &zoneBatch{r, *zoneID, []*queuedUpsert{{record, lock, done}}})
+		return
+	}
+	batchMu.Unlock()
+
+	done(r.Modify(route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53.ChangeBatch{
+			Changes: []*route53.Change{
+				{
+					Action:            aws.String(route53.ChangeActionUpsert),
+					ResourceRecordSet: record,
+				},
+			},
+			Comment: aws.String("Managed by Kubernetes"),
+		},
+		HostedZoneId: zoneID,
+	}))
+}
@@ -0,0 +1,83 @@
+package awsutil
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+)
+
+type mockedBatchR53Client struct {
+	route53iface.Route53API
+	batches []int // number of changes of each ChangeResourceRecordSets call
+}
+
+func (m *mockedBatchR53Client) ChangeResourceRecordSets(in *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	m.batches = append(m.batches, len(in.ChangeBatch.Changes))
+	return nil, errors.New("InvalidChangeBatch")
+}
+
+func TestFlushRoute53Batch(t *testing.T) {
+	client := &mockedBatchR53Client{}
+	r := &Route53{Svc: client}
+	record := func(name string) *route53.ResourceRecordSet {
+		return &route53.ResourceRecordSet{Name: aws.String(name), Type: aws.String(route53.RRTypeA)}
+	}
+
+	var failed []string
+	lock := &heldLocker{}
+	done := func(name string) func(error) {
+		return func(err error) {
+			if err != nil {
+				failed = append(failed, name)
+			}
+			if lock.held != (name != "a") {
+				t.Errorf("Upsert of %s: expected the lock to be held only by queued upserts with one", name)
+			}
+		}
+	}
+
+	BeginRoute53Batch()
+	r.Upsert(aws.String("Z1"), record("a.example.com."), nil, done("a"))
+	r.Upsert(aws.String("Z1"), record("b.example.com."), lock, done("b"))
+	r.Upsert(aws.String("Z2"), record("c.example.org."), lock, done("c"))
+	if len(client.batches) != 0 {
+		t.Fatalf("Upsert made %d calls while a batch was open", len(client.batches))
+	}
+	FlushRoute53Batch()
+
+	// The rejected batch of Z1 is retried one upsert at a time.
+	expected := []int{2, 1, 1, 1}
+	if len(client.batches) != len(expected) {
+		t.Fatalf("FlushRoute53Batch(): expected batches %v, actual %v", expected, client.batches)
+	}
+	for i := range expected {
+		if client.batches[i] != expected[i] {
+			t.Errorf("FlushRoute53Batch(): expected batches %v, actual %v", expected, client.batches)
+		}
+	}
+	if len(failed) != 3 {
+		t.Errorf("FlushRoute53Batch(): expected 3 failed upserts, actual %v", failed)
+	}
+
+	// Without a batch, upserts are made right away.
+	r.Upsert(aws.String("Z1"), record("a.example.com."), lock, done("a"))
+	if len(client.batches) != len(expected)+1 || len(failed) != 4 {
+		t.Errorf("Upsert outside of a batch wasn't made right away")
+	}
+}
+
+// heldLocker is a sync.Locker reporting whether it's held.
+type heldLocker struct {
+	held bool
+}
+
+func (l *heldLocker) Lock() {
+	l.held = true
+}
+
+func (l *heldLocker) Unlock() {
+	l.held = false
+}
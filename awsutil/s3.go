@@ -0,0 +1,225 @@
+package awsutil
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol"
+	"github.com/aws/aws-sdk-go/private/protocol/restxml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// S3 is a client of the S3 operations the controller calls, which the vendored aws-sdk-go has no
+// client for. Buckets are addressed in the path of the requests, in the region of the client.
+type S3 struct {
+	*client.Client
+}
+
+// NewS3 returns an S3 client based off of the provided AWS session.
+func NewS3(awsSession *session.Session) *S3 {
+	c := awsSession.ClientConfig("s3")
+	s := &S3{
+		Client: client.New(
+			*c.Config,
+			metadata.ClientInfo{
+				ServiceName:   "s3",
+				SigningName:   c.SigningName,
+				SigningRegion: c.SigningRegion,
+				Endpoint:      c.Endpoint,
+				APIVersion:    "2006-03-01",
+			},
+			c.Handlers,
+		),
+	}
+	s.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	s.Handlers.Build.PushBackNamed(restxml.BuildHandler)
+	// None of the operations called respond with more than their status code.
+	s.Handlers.Unmarshal.PushBackNamed(protocol.UnmarshalDiscardBodyHandler)
+	s.Handlers.UnmarshalMeta.PushBackNamed(restxml.UnmarshalMetaHandler)
+	s.Handlers.UnmarshalError.PushBack(unmarshalS3Error)
+	return s
+}
+
+// NewS3WithClient returns an S3 making its calls through c, e.g. one served by an in-memory fake
+// from the awsutil/fake package.
+func NewS3WithClient(c *client.Client) *S3 {
+	return &S3{Client: c}
+}
+
+// unmarshalS3Error unmarshals the errors of S3, which unlike the other query and REST XML APIs
+// aren't wrapped in an ErrorResponse. Responses to HEAD requests have no body, their error code is
+// the status text, e.g. NotFound.
+func unmarshalS3Error(r *request.Request) {
+	defer r.HTTPResponse.Body.Close()
+	resp := struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}{}
+	body, err := ioutil.ReadAll(r.HTTPResponse.Body)
+	if err != nil || xml.Unmarshal(body, &resp) != nil || resp.Code == "" {
+		resp.Code = strings.Replace(http.StatusText(r.HTTPResponse.StatusCode), " ", "", -1)
+		resp.Message = http.StatusText(r.HTTPResponse.StatusCode)
+	}
+	r.Error = awserr.NewRequestFailure(awserr.New(resp.Code, resp.Message, nil), r.HTTPResponse.StatusCode, r.RequestID)
+}
+
+// contentMD5 sets the Content-MD5 header S3 requires of some operations to the MD5 of the body.
+func contentMD5(r *request.Request) {
+	if r.Error != nil || r.Body == nil {
+		return
+	}
+	hasher := md5.New()
+	if _, err := io.Copy(hasher, r.Body); err != nil {
+		r.Error = awserr.New("SerializationError", "failed to hash the request body", err)
+		return
+	}
+	if _, err := r.Body.Seek(r.BodyStart, 0); err != nil {
+		r.Error = awserr.New("SerializationError", "failed to rewind the request body", err)
+		return
+	}
+	r.HTTPRequest.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(hasher.Sum(nil)))
+}
+
+type headBucketInput struct {
+	_ struct{} `type:"structure"`
+
+	Bucket *string `location:"uri" locationName:"Bucket" type:"string"`
+}
+
+type createBucketInput struct {
+	_ struct{} `type:"structure" payload:"CreateBucketConfiguration"`
+
+	Bucket                    *string                    `location:"uri" locationName:"Bucket" type:"string"`
+	CreateBucketConfiguration *createBucketConfiguration `locationName:"CreateBucketConfiguration" type:"structure" xmlURI:"http://s3.amazonaws.com/doc/2006-03-01/"`
+}
+
+type createBucketConfiguration struct {
+	_ struct{} `type:"structure"`
+
+	LocationConstraint *string `type:"string"`
+}
+
+type putBucketPolicyInput struct {
+	_ struct{} `type:"structure" payload:"Policy"`
+
+	Bucket *string `location:"uri" locationName:"Bucket" type:"string"`
+	Policy *string `type:"string"`
+}
+
+type putBucketLifecycleConfigurationInput struct {
+	_ struct{} `type:"structure" payload:"LifecycleConfiguration"`
+
+	Bucket                 *string                 `location:"uri" locationName:"Bucket" type:"string"`
+	LifecycleConfiguration *lifecycleConfiguration `locationName:"LifecycleConfiguration" type:"structure" xmlURI:"http://s3.amazonaws.com/doc/2006-03-01/"`
+}
+
+type lifecycleConfiguration struct {
+	_ struct{} `type:"structure"`
+
+	Rules []*lifecycleRule `locationName:"Rule" type:"list" flattened:"true"`
+}
+
+type lifecycleRule struct {
+	_ struct{} `type:"structure"`
+
+	Expiration *lifecycleExpiration `type:"structure"`
+	Filter     *lifecycleRuleFilter `type:"structure"`
+	ID         *string              `type:"string"`
+	Status     *string              `type:"string"`
+}
+
+type lifecycleExpiration struct {
+	_ struct{} `type:"structure"`
+
+	Days *int64 `type:"integer"`
+}
+
+type lifecycleRuleFilter struct {
+	_ struct{} `type:"structure"`
+
+	Prefix *string `type:"string"`
+}
+
+type s3Output struct {
+	_ struct{} `type:"structure"`
+}
+
+// Region returns the region of the buckets the client creates.
+func (s *S3) Region() string {
+	return aws.StringValue(s.Config.Region)
+}
+
+// BucketExists reports whether bucket exists. An error is returned when it exists but the
+// controller isn't allowed to access it.
+func (s *S3) BucketExists(bucket string) (bool, error) {
+	op := &request.Operation{Name: "HeadBucket", HTTPMethod: "HEAD", HTTPPath: "/{Bucket}"}
+	err := s.NewRequest(op, &headBucketInput{Bucket: aws.String(bucket)}, &s3Output{}).Send()
+	if ErrorCode(err) == "NotFound" {
+		return false, nil
+	}
+	if err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "S3", "request": "HeadBucket", "code": ErrorCode(err)}).Add(float64(1))
+		return false, err
+	}
+	return true, nil
+}
+
+// CreateBucket creates bucket in the region of the client.
+func (s *S3) CreateBucket(bucket string) error {
+	in := &createBucketInput{Bucket: aws.String(bucket)}
+	// Buckets are created in us-east-1 unless told otherwise, which rejects being told so.
+	if s.Region() != "us-east-1" {
+		in.CreateBucketConfiguration = &createBucketConfiguration{LocationConstraint: aws.String(s.Region())}
+	}
+	op := &request.Operation{Name: "CreateBucket", HTTPMethod: "PUT", HTTPPath: "/{Bucket}"}
+	if err := s.NewRequest(op, in, &s3Output{}).Send(); err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "S3", "request": "CreateBucket", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+	return nil
+}
+
+// PutBucketPolicy replaces the bucket policy of bucket with policy, a JSON policy document.
+func (s *S3) PutBucketPolicy(bucket, policy string) error {
+	op := &request.Operation{Name: "PutBucketPolicy", HTTPMethod: "PUT", HTTPPath: "/{Bucket}?policy"}
+	in := &putBucketPolicyInput{Bucket: aws.String(bucket), Policy: aws.String(policy)}
+	if err := s.NewRequest(op, in, &s3Output{}).Send(); err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "S3", "request": "PutBucketPolicy", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+	return nil
+}
+
+// PutBucketExpiration replaces the lifecycle rules of bucket with one, named id, expiring every
+// object days after it was created.
+func (s *S3) PutBucketExpiration(bucket, id string, days int64) error {
+	op := &request.Operation{Name: "PutBucketLifecycleConfiguration", HTTPMethod: "PUT", HTTPPath: "/{Bucket}?lifecycle"}
+	in := &putBucketLifecycleConfigurationInput{
+		Bucket: aws.String(bucket),
+		LifecycleConfiguration: &lifecycleConfiguration{Rules: []*lifecycleRule{{
+			Expiration: &lifecycleExpiration{Days: aws.Int64(days)},
+			Filter:     &lifecycleRuleFilter{Prefix: aws.String("")},
+			ID:         aws.String(id),
+			Status:     aws.String("Enabled"),
+		}}},
+	}
+	req := s.NewRequest(op, in, &s3Output{})
+	req.Handlers.Build.PushBack(contentMD5)
+	if err := req.Send(); err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "S3", "request": "PutBucketLifecycleConfiguration", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package awsutil
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestS3(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.RequestURI()+" "+string(body))
+		if md5sum := r.Header.Get("Content-MD5"); md5sum != "" {
+			sum := md5.Sum(body)
+			if md5sum != base64.StdEncoding.EncodeToString(sum[:]) {
+				t.Errorf("%s: Content-MD5 %s doesn't match the body", r.URL, md5sum)
+			}
+		} else if _, ok := r.URL.Query()["lifecycle"]; ok {
+			t.Errorf("%s: expected a Content-MD5 header", r.URL)
+		}
+		switch {
+		case r.Method == "HEAD" && r.URL.Path == "/logs":
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == "HEAD":
+			w.WriteHeader(http.StatusForbidden)
+		case r.Method == "PUT" && r.URL.RawQuery != "":
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("eu-west-1"),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:  aws.Int(0),
+	}))
+	s := NewS3(sess)
+	if exists, err := s.BucketExists("logs"); exists || err != nil {
+		t.Errorf("BucketExists(logs) = %v, %v, want false", exists, err)
+	}
+	if _, err := s.BucketExists("someone-elses"); ErrorCode(err) != "Forbidden" {
+		t.Errorf("BucketExists(someone-elses) returned error %v, want Forbidden", err)
+	}
+	if err := s.CreateBucket("logs"); err != nil {
+		t.Fatalf("CreateBucket(): returned error %v", err)
+	}
+	if err := s.PutBucketPolicy("logs", `{"Version":"2012-10-17"}`); err != nil {
+		t.Fatalf("PutBucketPolicy(): returned error %v", err)
+	}
+	if err := s.PutBucketExpiration("logs", "expire", 30); err != nil {
+		t.Fatalf("PutBucketExpiration(): returned error %v", err)
+	}
+
+	expected := []string{
+		"HEAD /logs ",
+		"HEAD /someone-elses ",
+		`PUT /logs <CreateBucketConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><LocationConstraint>eu-west-1</LocationConstraint></CreateBucketConfiguration>`,
+		`PUT /logs?policy= {"Version":"2012-10-17"}`,
+		"PUT /logs?lifecycle= <LifecycleConfiguration>",
+	}
+	// The elements of a rule aren't serialized in a fixed order.
+	lifecycle := requests[len(requests)-1]
+	for _, element := range []string{"<ID>expire</ID>", "<Status>Enabled</Status>", "<Expiration><Days>30</Days></Expiration>", "<Filter><Prefix></Prefix></Filter>"} {
+		if !strings.Contains(lifecycle, element) {
+			t.Errorf("PutBucketExpiration(): expected %s in %s", element, lifecycle)
+		}
+	}
+	requests[len(requests)-1] = "PUT /logs?lifecycle= <LifecycleConfiguration>"
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf("S3: expected requests\n%v\nactual\n%v", expected, requests)
+	}
+}
@@ -0,0 +1,112 @@
+package awsutil
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/query"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sqsWaitSeconds is how long ReceiveMessages waits for messages to arrive, the most SQS allows.
+const sqsWaitSeconds = 20
+
+// SQS is a client of the SQS operations the controller calls, which the vendored aws-sdk-go has no
+// client for.
+type SQS struct {
+	*client.Client
+}
+
+// SQSMessage is a message received from an SQS queue.
+type SQSMessage struct {
+	_ struct{} `type:"structure"`
+
+	Body          *string `type:"string"`
+	ReceiptHandle *string `type:"string"`
+}
+
+// NewSQS returns an SQS client based off of the provided AWS session.
+func NewSQS(awsSession *session.Session) *SQS {
+	c := awsSession.ClientConfig("sqs")
+	s := &SQS{
+		Client: client.New(
+			*c.Config,
+			metadata.ClientInfo{
+				ServiceName:   "sqs",
+				SigningName:   c.SigningName,
+				SigningRegion: c.SigningRegion,
+				Endpoint:      c.Endpoint,
+				APIVersion:    "2012-11-05",
+			},
+			c.Handlers,
+		),
+	}
+	s.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	s.Handlers.Build.PushBackNamed(query.BuildHandler)
+	s.Handlers.Unmarshal.PushBackNamed(query.UnmarshalHandler)
+	s.Handlers.UnmarshalMeta.PushBackNamed(query.UnmarshalMetaHandler)
+	s.Handlers.UnmarshalError.PushBackNamed(query.UnmarshalErrorHandler)
+	return s
+}
+
+// NewSQSWithClient returns a SQS making its calls through c, e.g. one served by an in-memory
+// fake from the awsutil/fake package.
+func NewSQSWithClient(c *client.Client) *SQS {
+	return &SQS{Client: c}
+}
+
+type receiveMessageInput struct {
+	_ struct{} `type:"structure"`
+
+	MaxNumberOfMessages *int64  `type:"integer"`
+	QueueUrl            *string `type:"string"`
+	WaitTimeSeconds     *int64  `type:"integer"`
+}
+
+type receiveMessageOutput struct {
+	_ struct{} `type:"structure"`
+
+	Messages []*SQSMessage `locationNameList:"Message" type:"list" flattened:"true"`
+}
+
+type deleteMessageInput struct {
+	_ struct{} `type:"structure"`
+
+	QueueUrl      *string `type:"string"`
+	ReceiptHandle *string `type:"string"`
+}
+
+type deleteMessageOutput struct {
+	_ struct{} `type:"structure"`
+}
+
+// ReceiveMessages receives up to 10 messages from the queue queueURL, waiting up to 20 seconds for
+// one to arrive.
+func (s *SQS) ReceiveMessages(queueURL string) ([]*SQSMessage, error) {
+	in := &receiveMessageInput{
+		MaxNumberOfMessages: aws.Int64(10),
+		QueueUrl:            aws.String(queueURL),
+		WaitTimeSeconds:     aws.Int64(sqsWaitSeconds),
+	}
+	out := &receiveMessageOutput{}
+	op := &request.Operation{Name: "ReceiveMessage", HTTPMethod: "POST", HTTPPath: "/"}
+	if err := s.NewRequest(op, in, out).Send(); err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "SQS", "request": "ReceiveMessage", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+	return out.Messages, nil
+}
+
+// DeleteMessage deletes the message received with receiptHandle from the queue queueURL.
+func (s *SQS) DeleteMessage(queueURL string, receiptHandle *string) error {
+	in := &deleteMessageInput{QueueUrl: aws.String(queueURL), ReceiptHandle: receiptHandle}
+	op := &request.Operation{Name: "DeleteMessage", HTTPMethod: "POST", HTTPPath: "/"}
+	if err := s.NewRequest(op, in, &deleteMessageOutput{}).Send(); err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "SQS", "request": "DeleteMessage", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+	return nil
+}
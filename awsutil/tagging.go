@@ -0,0 +1,131 @@
+package awsutil
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/jsonrpc"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/controller/util"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Resource types of the Resource Groups Tagging API
+const (
+	ResourceTypeLoadBalancer = "elasticloadbalancing:loadbalancer"
+	ResourceTypeTargetGroup  = "elasticloadbalancing:targetgroup"
+)
+
+// Tagging is a client of the Resource Groups Tagging API, which the vendored aws-sdk-go has none
+// for. It finds the resources carrying a set of tags in one paginated query, rather than listing
+// every resource of the region and filtering them on their tags.
+type Tagging struct {
+	*client.Client
+}
+
+// NewTagging returns a Tagging client based off of the provided AWS session.
+func NewTagging(awsSession *session.Session) *Tagging {
+	c := awsSession.ClientConfig("tagging")
+	t := &Tagging{
+		Client: client.New(
+			*c.Config,
+			metadata.ClientInfo{
+				ServiceName:   "tagging",
+				SigningName:   c.SigningName,
+				SigningRegion: c.SigningRegion,
+				Endpoint:      c.Endpoint,
+				APIVersion:    "2017-01-26",
+				JSONVersion:   "1.1",
+				TargetPrefix:  "ResourceGroupsTaggingAPI_20170126",
+			},
+			c.Handlers,
+		),
+	}
+	t.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	t.Handlers.Build.PushBackNamed(jsonrpc.BuildHandler)
+	t.Handlers.Unmarshal.PushBackNamed(jsonrpc.UnmarshalHandler)
+	t.Handlers.UnmarshalMeta.PushBackNamed(jsonrpc.UnmarshalMetaHandler)
+	t.Handlers.UnmarshalError.PushBackNamed(jsonrpc.UnmarshalErrorHandler)
+	return t
+}
+
+// NewTaggingWithClient returns a Tagging making its calls through c, e.g. one served by an in-memory
+// fake from the awsutil/fake package.
+func NewTaggingWithClient(c *client.Client) *Tagging {
+	return &Tagging{Client: c}
+}
+
+type getResourcesInput struct {
+	_ struct{} `type:"structure"`
+
+	PaginationToken     *string      `type:"string"`
+	ResourceTypeFilters []*string    `type:"list"`
+	ResourcesPerPage    *int64       `type:"integer"`
+	TagFilters          []*tagFilter `type:"list"`
+}
+
+type tagFilter struct {
+	_ struct{} `type:"structure"`
+
+	Key    *string   `type:"string"`
+	Values []*string `type:"list"`
+}
+
+type getResourcesOutput struct {
+	_ struct{} `type:"structure"`
+
+	PaginationToken        *string               `type:"string"`
+	ResourceTagMappingList []*resourceTagMapping `type:"list"`
+}
+
+type resourceTagMapping struct {
+	_ struct{} `type:"structure"`
+
+	ResourceARN *string      `type:"string"`
+	Tags        []*elbv2.Tag `type:"list"`
+}
+
+// GetResources returns the tags of the resources of resourceType carrying every tag of tags, keyed
+// by ARN.
+func (t *Tagging) GetResources(resourceType string, tags util.Tags) (map[string]util.Tags, error) {
+	in := &getResourcesInput{
+		ResourceTypeFilters: []*string{aws.String(resourceType)},
+		ResourcesPerPage:    aws.Int64(100),
+	}
+	for _, tag := range tags {
+		in.TagFilters = append(in.TagFilters, &tagFilter{Key: tag.Key, Values: []*string{tag.Value}})
+	}
+
+	resources := make(map[string]util.Tags)
+	op := &request.Operation{Name: "GetResources", HTTPMethod: "POST", HTTPPath: "/"}
+	for {
+		out := &getResourcesOutput{}
+		if err := t.NewRequest(op, in, out).Send(); err != nil {
+			AWSErrorCount.With(prometheus.Labels{"service": "Tagging", "request": "GetResources", "code": ErrorCode(err)}).Add(float64(1))
+			return nil, err
+		}
+		for _, mapping := range out.ResourceTagMappingList {
+			resources[*mapping.ResourceARN] = util.Tags(mapping.Tags)
+		}
+		if aws.StringValue(out.PaginationToken) == "" {
+			break
+		}
+		in.PaginationToken = out.PaginationToken
+	}
+	return resources, nil
+}
+
+// sortedArns returns the ARNs of resources, sorted.
+func sortedArns(resources map[string]util.Tags) []*string {
+	var arns []string
+	for arn := range resources {
+		arns = append(arns, arn)
+	}
+	sort.Strings(arns)
+	return aws.StringSlice(arns)
+}
@@ -0,0 +1,68 @@
+package awsutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/coreos/alb-ingress-controller/controller/util"
+)
+
+func TestGetResources(t *testing.T) {
+	var requests []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if target := r.Header.Get("X-Amz-Target"); target != "ResourceGroupsTaggingAPI_20170126.GetResources" {
+			t.Errorf("GetResources(): unexpected target %s", target)
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		var body map[string]interface{}
+		json.Unmarshal(b, &body)
+		requests = append(requests, body)
+
+		if body["PaginationToken"] == nil {
+			fmt.Fprint(w, `{"PaginationToken": "page-2", "ResourceTagMappingList": [
+  {"ResourceARN": "arn-1", "Tags": [{"Key": "ClusterName", "Value": "prod"}, {"Key": "IngressName", "Value": "web"}]}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"PaginationToken": "", "ResourceTagMappingList": [
+  {"ResourceARN": "arn-2", "Tags": [{"Key": "ClusterName", "Value": "prod"}]}]}`)
+	}))
+	defer server.Close()
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+	resources, err := NewTagging(sess).GetResources(ResourceTypeLoadBalancer,
+		util.Tags{{Key: aws.String("ClusterName"), Value: aws.String("prod")}})
+	if err != nil {
+		t.Fatalf("GetResources(): returned error %v", err)
+	}
+
+	if len(requests) != 2 || requests[1]["PaginationToken"] != "page-2" {
+		t.Fatalf("GetResources(): expected 2 paginated requests, actual %v", requests)
+	}
+	filters := []interface{}{map[string]interface{}{"Key": "ClusterName", "Values": []interface{}{"prod"}}}
+	if !reflect.DeepEqual(requests[0]["TagFilters"], filters) ||
+		!reflect.DeepEqual(requests[0]["ResourceTypeFilters"], []interface{}{ResourceTypeLoadBalancer}) {
+		t.Errorf("GetResources(): unexpected request %v", requests[0])
+	}
+	expected := map[string]util.Tags{
+		"arn-1": {
+			{Key: aws.String("ClusterName"), Value: aws.String("prod")},
+			{Key: aws.String("IngressName"), Value: aws.String("web")},
+		},
+		"arn-2": {{Key: aws.String("ClusterName"), Value: aws.String("prod")}},
+	}
+	if !reflect.DeepEqual(resources, expected) {
+		t.Errorf("GetResources(): expected %v, actual %v", expected, resources)
+	}
+}
@@ -0,0 +1,90 @@
+package awsutil
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// Delay before the first retry of a throttled call, and the least delay of later ones
+	throttleBaseDelay = 500 * time.Millisecond
+	// Upper bound of the delay between retries of a throttled call
+	throttleMaxDelay = 20 * time.Second
+	// Number of retries of a call when the aws.Config doesn't set MaxRetries
+	defaultMaxRetries = 3
+)
+
+// IsThrottle returns true when err is the error of an AWS call rejected because the controller
+// exceeded the request rate of the API, e.g. Throttling or RequestLimitExceeded.
+func IsThrottle(err error) bool {
+	return request.IsErrorThrottle(err)
+}
+
+// throttleRetryer retries throttled calls with decorrelated jitter: each delay is drawn between
+// throttleBaseDelay and three times the previous delay, capped at throttleMaxDelay. Unlike the
+// exponential backoff of client.DefaultRetryer, the retries of calls throttled at the same time
+// spread out rather than hitting the API again together. Other failures are retried like
+// client.DefaultRetryer does.
+type throttleRetryer struct {
+	client.DefaultRetryer
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// newThrottleRetryer returns a throttleRetryer retrying a call up to maxRetries times.
+func newThrottleRetryer(maxRetries int) *throttleRetryer {
+	return &throttleRetryer{
+		DefaultRetryer: client.DefaultRetryer{NumMaxRetries: maxRetries},
+		rand:           rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// RetryRules returns the delay before retrying r.
+func (t *throttleRetryer) RetryRules(r *request.Request) time.Duration {
+	if !r.IsErrorThrottle() {
+		return t.DefaultRetryer.RetryRules(r)
+	}
+	return t.throttleDelay(r.RetryDelay)
+}
+
+// throttleDelay returns the delay following previous, the delay before the last retry of a
+// throttled call, zero for its first one.
+func (t *throttleRetryer) throttleDelay(previous time.Duration) time.Duration {
+	if previous < throttleBaseDelay {
+		previous = throttleBaseDelay
+	}
+	t.mu.Lock()
+	delay := throttleBaseDelay + time.Duration(t.rand.Int63n(int64(3*previous-throttleBaseDelay)+1))
+	t.mu.Unlock()
+	if delay > throttleMaxDelay {
+		delay = throttleMaxDelay
+	}
+	return delay
+}
+
+// withThrottling returns awsconfig with a throttleRetryer making as many retries as awsconfig does.
+// A Retryer awsconfig already has is kept.
+func withThrottling(awsconfig *aws.Config) *aws.Config {
+	if awsconfig.Retryer != nil {
+		return awsconfig
+	}
+	maxRetries := aws.IntValue(awsconfig.MaxRetries)
+	if awsconfig.MaxRetries == nil || maxRetries == aws.UseServiceDefaultRetries {
+		maxRetries = defaultMaxRetries
+	}
+	return request.WithRetryer(awsconfig.Copy(), newThrottleRetryer(maxRetries))
+}
+
+// countThrottles counts every throttled attempt of r, retried or not, in AWSThrottles.
+func countThrottles(r *request.Request) {
+	if r.IsErrorThrottle() {
+		AWSThrottles.With(prometheus.Labels{"service": r.ClientInfo.ServiceName, "operation": r.Operation.Name}).Add(float64(1))
+	}
+}
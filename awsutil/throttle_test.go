@@ -0,0 +1,62 @@
+package awsutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+func TestThrottleDelay(t *testing.T) {
+	r := newThrottleRetryer(5)
+	for _, previous := range []time.Duration{0, throttleBaseDelay, 2 * time.Second, time.Minute} {
+		max := 3 * previous
+		if max < 3*throttleBaseDelay {
+			max = 3 * throttleBaseDelay
+		}
+		if max > throttleMaxDelay {
+			max = throttleMaxDelay
+		}
+		for i := 0; i < 100; i++ {
+			delay := r.throttleDelay(previous)
+			if delay < throttleBaseDelay || delay > max {
+				t.Fatalf("throttleDelay(%v): expected a delay between %v and %v, actual %v", previous, throttleBaseDelay, max, delay)
+			}
+		}
+	}
+}
+
+func TestWithThrottling(t *testing.T) {
+	var tests = []struct {
+		config     *aws.Config
+		maxRetries int
+	}{
+		{&aws.Config{MaxRetries: aws.Int(5)}, 5},
+		{&aws.Config{}, defaultMaxRetries},
+		{&aws.Config{MaxRetries: aws.Int(aws.UseServiceDefaultRetries)}, defaultMaxRetries},
+	}
+
+	for _, tt := range tests {
+		retryer, ok := withThrottling(tt.config).Retryer.(*throttleRetryer)
+		if !ok {
+			t.Errorf("withThrottling(%v): expected a throttleRetryer", tt.config)
+			continue
+		}
+		if retryer.MaxRetries() != tt.maxRetries {
+			t.Errorf("withThrottling(%v): expected %d retries, actual %d", tt.config, tt.maxRetries, retryer.MaxRetries())
+		}
+	}
+}
+
+func TestIsThrottle(t *testing.T) {
+	if !IsThrottle(awserr.New("Throttling", "Rate exceeded", nil)) {
+		t.Errorf("IsThrottle(Throttling): expected true")
+	}
+	if !IsThrottle(awserr.New("RequestLimitExceeded", "Request limit exceeded", nil)) {
+		t.Errorf("IsThrottle(RequestLimitExceeded): expected true")
+	}
+	if IsThrottle(awserr.New("AccessDenied", "User is not authorized", nil)) {
+		t.Errorf("IsThrottle(AccessDenied): expected false")
+	}
+}
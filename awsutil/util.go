@@ -1,9 +1,15 @@
 package awsutil
 
 import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/awsutil"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -16,9 +22,26 @@ func init() {
 	prometheus.MustRegister(OnUpdateCount)
 	prometheus.MustRegister(ReloadCount)
 	prometheus.MustRegister(AWSErrorCount)
+	prometheus.MustRegister(AWSThrottles)
 	prometheus.MustRegister(ManagedIngresses)
 	prometheus.MustRegister(AWSCache)
 	prometheus.MustRegister(AWSRequest)
+	prometheus.MustRegister(AWSRequestDuration)
+	prometheus.MustRegister(TargetChanges)
+	prometheus.MustRegister(PendingTargets)
+	prometheus.MustRegister(TargetHealth)
+	prometheus.MustRegister(TargetHealthFlaps)
+	prometheus.MustRegister(AWSCircuitOpen)
+	prometheus.MustRegister(AWSHeartbeatFailures)
+	prometheus.MustRegister(AWSMissingPermissions)
+	prometheus.MustRegister(Route53Records)
+	prometheus.MustRegister(AWSQuotaLimit)
+	prometheus.MustRegister(AWSQuotaUsage)
+	prometheus.MustRegister(Route53ChangeBatches)
+	prometheus.MustRegister(CertificateExpiry)
+	prometheus.MustRegister(CostEstimate)
+	prometheus.MustRegister(ReconcileErrors)
+	prometheus.MustRegister(DriftEvents)
 }
 
 type APICache struct {
@@ -38,8 +61,27 @@ var (
 	ACMsvc *ACM
 	// IAMsvc is a pointer to the awsutil IAM service
 	IAMsvc *IAM
+	// WAFsvc is a pointer to the awsutil WAFRegional service
+	WAFsvc *WAFRegional
+	// GlobalAcceleratorsvc is a pointer to the awsutil GlobalAccelerator service
+	GlobalAcceleratorsvc *GlobalAccelerator
+	// SQSsvc is a pointer to the awsutil SQS service, nil unless the controller receives events
+	// from a queue
+	SQSsvc *SQS
+	// AutoScalingsvc is a pointer to the awsutil AutoScaling service, nil unless the controller
+	// completes lifecycle actions
+	AutoScalingsvc *AutoScaling
+	// CloudWatchsvc is a pointer to the awsutil CloudWatch service, used for the alarms of target
+	// groups and to publish the controller metrics
+	CloudWatchsvc *CloudWatch
+	// S3svc is a pointer to the awsutil S3 service, nil unless the controller provisions access log
+	// buckets
+	S3svc *S3
 	// AWSDebug turns on AWS API debug logging
 	AWSDebug bool
+	// UserAgentSuffix is appended to the User-Agent of the AWS calls of sessions created by
+	// NewSession, see ExpandUserAgentSuffix
+	UserAgentSuffix string
 
 	// OnUpdateCount is a counter of the controller OnUpdate calls
 	OnUpdateCount = prometheus.NewCounter(prometheus.CounterOpts{
@@ -55,12 +97,21 @@ var (
 	},
 	)
 
-	// AWSErrorCount is a counter of AWS errors
+	// AWSErrorCount is a counter of AWS errors, labeled with the AWS error code (e.g. Throttling or
+	// AccessDenied) when one is known
 	AWSErrorCount = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "albingress_aws_errors",
 		Help: "Number of errors from the AWS API",
 	},
-		[]string{"service", "request"},
+		[]string{"service", "request", "code"},
+	)
+
+	// AWSThrottles counts the AWS calls rejected by throttling, including those that were retried
+	AWSThrottles = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "albingress_aws_throttles",
+		Help: "Number of AWS API calls rejected by throttling, including retried calls",
+	},
+		[]string{"service", "operation"},
 	)
 
 	// ManagedIngresses contains the current tally of managed ingresses
@@ -82,13 +133,161 @@ var (
 		Help: "Number of requests made to the AWS API",
 	},
 		[]string{"service", "operation"})
+
+	// AWSRequestDuration contains the time taken by requests to the AWS API, retries included
+	AWSRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "albingress_aws_request_duration_seconds",
+		Help:    "Time taken by requests to the AWS API, retries included",
+		Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	},
+		[]string{"service", "operation"})
+
+	// TargetChanges contains the targets registered to or deregistered from target groups
+	TargetChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "albingress_target_changes",
+		Help: "Number of targets registered or deregistered",
+	},
+		[]string{"action"})
+
+	// PendingTargets contains the targets waiting to be sent in a (de)registration batch
+	PendingTargets = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "albingress_pending_target_changes",
+		Help: "Number of targets waiting to be registered or deregistered",
+	},
+		[]string{"action"})
+
+	// TargetHealth contains the targets of each target group by their ALB reported health state
+	TargetHealth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "albingress_target_health",
+		Help: "Number of targets in a target group by health state",
+	},
+		[]string{"target_group", "state"})
+
+	// TargetHealthFlaps contains the number of times targets of a target group changed between healthy
+	// and unhealthy
+	TargetHealthFlaps = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "albingress_target_health_flaps",
+		Help: "Number of times targets in a target group changed between healthy and unhealthy",
+	},
+		[]string{"target_group"})
+
+	// AWSCircuitOpen is set to 1 while mutating calls to an AWS service are paused by the Breaker
+	AWSCircuitOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "albingress_aws_circuit_open",
+		Help: "Whether calls to an AWS service are paused after consecutive failures",
+	},
+		[]string{"service"})
+
+	// AWSHeartbeatFailures contains the number of consecutive failed AWS heartbeats
+	AWSHeartbeatFailures = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "albingress_aws_heartbeat_failures",
+		Help: "Number of consecutive failed AWS heartbeats, the controller isn't ready from the threshold on",
+	})
+
+	// AWSMissingPermissions is set to 1 for each IAM action the controller calls that its principal
+	// isn't allowed, as found on startup
+	AWSMissingPermissions = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "albingress_aws_missing_permissions",
+		Help: "IAM actions the controller calls that its principal isn't allowed, checked on startup",
+	},
+		[]string{"action"})
+
+	// AWSQuotaLimit contains the ELBV2 account limits, by limit name
+	AWSQuotaLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "albingress_aws_quota_limit",
+		Help: "ELBV2 account limits",
+	},
+		[]string{"quota"})
+
+	// AWSQuotaUsage contains the usage of the ELBV2 account limits, by limit name. Per load balancer
+	// limits report the usage of the managed ALB closest to the limit
+	AWSQuotaUsage = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "albingress_aws_quota_usage",
+		Help: "Usage of the ELBV2 account limits, including pending creations",
+	},
+		[]string{"quota"})
+
+	// Route53Records contains the Route 53 records the controller owns, and how many of them are
+	// present in Route 53 and resolving through DNS
+	Route53Records = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "albingress_route53_records",
+		Help: "Number of Route 53 records owned by the controller, present in Route 53 and resolving",
+	},
+		[]string{"state"})
+
+	// CertificateExpiry contains the days left until each ACM certificate used by the listeners of
+	// an ingress expires. It's negative for expired certificates
+	CertificateExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "albingress_certificate_expiry_days",
+		Help: "Days until a certificate attached to an ingress's listeners expires",
+	},
+		[]string{"ingress", "certificate"})
+
+	// CostEstimate contains a rough estimate of the monthly cost, in USD, of each ALB of an ingress,
+	// from its hours and an assumed number of LCUs
+	CostEstimate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "albingress_load_balancer_monthly_cost_estimate",
+		Help: "Estimated monthly cost of an ingress's ALB in USD, from ALB-hours and assumed LCUs",
+	},
+		[]string{"ingress", "load_balancer"})
+
+	// Route53ChangeBatches contains the change batches submitted to Route 53
+	Route53ChangeBatches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "albingress_route53_change_batches",
+		Help: "Number of change batches submitted to Route 53",
+	},
+		[]string{"action"})
+
+	// ReconcileErrors contains the ALBs that failed to reconcile, counted on every failed attempt
+	ReconcileErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "albingress_reconcile_errors",
+		Help: "Number of times an ALB failed to reconcile",
+	})
+
+	// DriftEvents contains the ALBs whose resources were found changed out of band
+	DriftEvents = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "albingress_drift_events",
+		Help: "Number of times out of band changes to an ALB's resources were found",
+	})
 )
 
+// ErrorCode returns the AWS error code of err, or an empty string when err doesn't come from AWS.
+func ErrorCode(err error) string {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code()
+	}
+	return ""
+}
+
+// RequestID returns the ID AWS assigned to the failed request err is the error of, for AWS support
+// to investigate it. It's empty when err isn't the error of a request that reached AWS.
+func RequestID(err error) string {
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		return reqErr.RequestID()
+	}
+	return ""
+}
+
+// DescribeError returns the message of err on a single line, for logs and Kubernetes Events. The
+// error of a failed AWS request is given with its request ID, e.g.
+// "AccessDenied: User is not authorized (request ID 8a5c3f6e-...)".
+func DescribeError(err error) string {
+	awsErr, ok := err.(awserr.Error)
+	if !ok {
+		return err.Error()
+	}
+	s := fmt.Sprintf("%s: %s", awsErr.Code(), awsErr.Message())
+	if id := RequestID(err); id != "" {
+		s += fmt.Sprintf(" (request ID %s)", id)
+	}
+	return s
+}
+
 // NewSession returns an AWS session based off of the provided AWS config
 func NewSession(awsconfig *aws.Config) *session.Session {
-	session, err := session.NewSession(awsconfig)
+	session, err := session.NewSession(withThrottling(awsconfig))
 	if err != nil {
-		AWSErrorCount.With(prometheus.Labels{"service": "AWS", "request": "NewSession"}).Add(float64(1))
+		AWSErrorCount.With(prometheus.Labels{"service": "AWS", "request": "NewSession", "code": ErrorCode(err)}).Add(float64(1))
 		glog.Errorf("Failed to create AWS session. Error: %s.", err.Error())
 		return nil
 	}
@@ -98,9 +297,118 @@ func NewSession(awsconfig *aws.Config) *session.Session {
 			glog.Infof("Request: %s/%s, Payload: %s", r.ClientInfo.ServiceName, r.Operation, r.Params)
 		}
 	})
+	if UserAgentSuffix != "" {
+		session.Handlers.Build.PushBack(request.MakeAddToUserAgentFreeFormHandler(UserAgentSuffix))
+	}
+	session.Handlers.Retry.PushBack(countThrottles)
+	session.Handlers.Complete.PushBack(func(r *request.Request) {
+		// Calls rejected by the Breaker never reached AWS.
+		if ErrorCode(r.Error) == ErrCodeCircuitOpen {
+			return
+		}
+		AWSRequestDuration.With(prometheus.Labels{"service": r.ClientInfo.ServiceName, "operation": r.Operation.Name}).Observe(time.Since(r.Time).Seconds())
+	})
 	return session;
 }
 
+// ExpandUserAgentSuffix returns the User-Agent suffix template with its {cluster} and {controller}
+// placeholders replaced by clusterName and controllerID, so CloudTrail records of the calls name the
+// installation that made them.
+func ExpandUserAgentSuffix(template, clusterName, controllerID string) (string, error) {
+	suffix := strings.NewReplacer("{cluster}", clusterName, "{controller}", controllerID).Replace(strings.TrimSpace(template))
+	for _, r := range suffix {
+		if r < ' ' || r > '~' {
+			return "", fmt.Errorf("AWS_USER_AGENT_SUFFIX %q must only hold printable ASCII characters", template)
+		}
+	}
+	return suffix, nil
+}
+
+// withQueryParams returns a request.Option that adds params to a query protocol request body once
+// it has been built. It allows passing API parameters that post-date the vendored aws-sdk-go.
+func withQueryParams(params map[string]string) request.Option {
+	return editQuery(func(body url.Values) {
+		for k, v := range params {
+			body.Set(k, v)
+		}
+	})
+}
+
+// withRedirectAction returns a request.Option that turns the first action of a CreateRule or
+// ModifyRule request into a redirect action, which the vendored aws-sdk-go doesn't know about. The
+// keys of redirect are RedirectConfig fields, e.g. Host or StatusCode. Empty values are left out.
+func withRedirectAction(redirect map[string]string) request.Option {
+	return editQuery(func(body url.Values) {
+		body.Del("Actions.member.1.TargetGroupArn")
+		body.Set("Actions.member.1.Type", "redirect")
+		for k, v := range redirect {
+			if v != "" {
+				body.Set("Actions.member.1.RedirectConfig."+k, v)
+			}
+		}
+	})
+}
+
+// withWeightedForward returns a request.Option that turns action, e.g. Actions.member.1 of a
+// ModifyRule request, into a forward action spreading requests over target groups by weight. The
+// vendored aws-sdk-go doesn't know about ForwardConfig.
+func withWeightedForward(action string, weights []TargetGroupWeight) request.Option {
+	return editQuery(func(body url.Values) {
+		body.Del(action + ".TargetGroupArn")
+		for i, w := range weights {
+			member := fmt.Sprintf("%s.ForwardConfig.TargetGroups.member.%d", action, i+1)
+			body.Set(member+".TargetGroupArn", w.TargetGroupArn)
+			body.Set(member+".Weight", strconv.FormatInt(w.Weight, 10))
+		}
+	})
+}
+
+// withHostHeaderConfig returns a request.Option that moves the values of the host-header conditions
+// of a CreateRule or ModifyRule request into a HostHeaderConfig, which, unlike Values, can hold
+// several hostnames. The vendored aws-sdk-go doesn't know about HostHeaderConfig.
+func withHostHeaderConfig() request.Option {
+	return editQuery(func(body url.Values) {
+		for i := 1; body.Get(fmt.Sprintf("Conditions.member.%d.Field", i)) != ""; i++ {
+			condition := fmt.Sprintf("Conditions.member.%d", i)
+			if body.Get(condition+".Field") != "host-header" {
+				continue
+			}
+			for j := 1; ; j++ {
+				value := fmt.Sprintf("%s.Values.member.%d", condition, j)
+				if _, ok := body[value]; !ok {
+					break
+				}
+				body.Set(fmt.Sprintf("%s.HostHeaderConfig.Values.member.%d", condition, j), body.Get(value))
+				body.Del(value)
+			}
+		}
+	})
+}
+
+// editQuery returns a request.Option that calls edit with the parameters of a query protocol request
+// once its body has been built, and rewrites the body with the edited parameters.
+func editQuery(edit func(url.Values)) request.Option {
+	return func(r *request.Request) {
+		r.Handlers.Build.PushBack(func(r *request.Request) {
+			if r.Error != nil || r.Body == nil {
+				return
+			}
+			b, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				r.Error = err
+				return
+			}
+			body, err := url.ParseQuery(string(b))
+			if err != nil {
+				r.Error = err
+				return
+			}
+			edit(body)
+			r.SetBufferBody([]byte(body.Encode()))
+		})
+	}
+}
+
 // Prettify wraps github.com/aws/aws-sdk-go/aws/awsutil.Prettify. Preventing the need to import it
 // in each package.
 func Prettify(i interface{}) string {
@@ -126,3 +434,8 @@ func (ac APICache) Get(key string) *ccache.Item {
 func (ac APICache) Set(key string, value interface{}, duration time.Duration) {
 	ac.cache.Set(key, value, duration)
 }
+
+// Delete removes a key from the API cache.
+func (ac APICache) Delete(key string) {
+	ac.cache.Delete(key)
+}
@@ -0,0 +1,68 @@
+package awsutil
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+func TestErrorCode(t *testing.T) {
+	var tests = []struct {
+		err  error
+		code string
+	}{
+		{awserr.New("Throttling", "Rate exceeded", nil), "Throttling"},
+		{awserr.New("AccessDenied", "User is not authorized", errors.New("cause")), "AccessDenied"},
+		{errors.New("not an AWS error"), ""},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		if code := ErrorCode(tt.err); code != tt.code {
+			t.Errorf("ErrorCode(%v) returned %q, expected %q", tt.err, code, tt.code)
+		}
+	}
+}
+
+func TestDescribeError(t *testing.T) {
+	var tests = []struct {
+		err         error
+		description string
+	}{
+		{awserr.NewRequestFailure(awserr.New("AccessDenied", "User is not authorized", nil), 403, "8a5c3f6e-1b2d"),
+			"AccessDenied: User is not authorized (request ID 8a5c3f6e-1b2d)"},
+		{awserr.NewRequestFailure(awserr.New("Throttling", "Rate exceeded", nil), 400, ""), "Throttling: Rate exceeded"},
+		{awserr.New("RequestError", "send request failed", errors.New("cause")), "RequestError: send request failed"},
+		{errors.New("not an AWS error"), "not an AWS error"},
+	}
+
+	for _, tt := range tests {
+		if description := DescribeError(tt.err); description != tt.description {
+			t.Errorf("DescribeError(%v) returned %q, expected %q", tt.err, description, tt.description)
+		}
+	}
+}
+
+func TestExpandUserAgentSuffix(t *testing.T) {
+	var tests = []struct {
+		template string
+		suffix   string
+		pass     bool
+	}{
+		{"", "", true},
+		{"{cluster}/{controller}", "prod/alb-ingress-controller", true},
+		{" team-a (cluster {cluster}) ", "team-a (cluster prod)", true},
+		{"team-a\r\nX-Injected: 1", "", false},
+	}
+
+	for _, tt := range tests {
+		suffix, err := ExpandUserAgentSuffix(tt.template, "prod", "alb-ingress-controller")
+		if (err == nil) != tt.pass {
+			t.Errorf("ExpandUserAgentSuffix(%q): expected pass %v, got error %v", tt.template, tt.pass, err)
+		}
+		if suffix != tt.suffix {
+			t.Errorf("ExpandUserAgentSuffix(%q) returned %q, expected %q", tt.template, suffix, tt.suffix)
+		}
+	}
+}
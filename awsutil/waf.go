@@ -0,0 +1,129 @@
+package awsutil
+
+import (
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/jsonrpc"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WAFRegional is a client of the WAF Regional operations associating web ACLs with ALBs, which the
+// vendored aws-sdk-go has no client for.
+type WAFRegional struct {
+	*client.Client
+}
+
+// NewWAFRegional returns a WAFRegional client based off of the provided AWS session.
+func NewWAFRegional(awsSession *session.Session) *WAFRegional {
+	c := awsSession.ClientConfig("waf-regional")
+	w := &WAFRegional{
+		Client: client.New(
+			*c.Config,
+			metadata.ClientInfo{
+				ServiceName:   "waf-regional",
+				SigningName:   c.SigningName,
+				SigningRegion: c.SigningRegion,
+				Endpoint:      c.Endpoint,
+				APIVersion:    "2016-11-28",
+				JSONVersion:   "1.1",
+				TargetPrefix:  "AWSWAF_Regional_20161128",
+			},
+			c.Handlers,
+		),
+	}
+	w.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	w.Handlers.Build.PushBackNamed(jsonrpc.BuildHandler)
+	w.Handlers.Unmarshal.PushBackNamed(jsonrpc.UnmarshalHandler)
+	w.Handlers.UnmarshalMeta.PushBackNamed(jsonrpc.UnmarshalMetaHandler)
+	w.Handlers.UnmarshalError.PushBackNamed(jsonrpc.UnmarshalErrorHandler)
+	return w
+}
+
+// NewWAFRegionalWithClient returns a WAFRegional making its calls through c, e.g. one served by an in-memory
+// fake from the awsutil/fake package.
+func NewWAFRegionalWithClient(c *client.Client) *WAFRegional {
+	return &WAFRegional{Client: c}
+}
+
+type associateWebACLInput struct {
+	_ struct{} `type:"structure"`
+
+	ResourceArn *string `type:"string"`
+	WebACLId    *string `type:"string"`
+}
+
+type associateWebACLOutput struct {
+	_ struct{} `type:"structure"`
+}
+
+type disassociateWebACLInput struct {
+	_ struct{} `type:"structure"`
+
+	ResourceArn *string `type:"string"`
+}
+
+type disassociateWebACLOutput struct {
+	_ struct{} `type:"structure"`
+}
+
+// AssociateWebACL associates the web ACL webACLID with the ALB arn, replacing the one it was
+// associated with, if any.
+func (w *WAFRegional) AssociateWebACL(webACLID, arn *string) error {
+	in := &associateWebACLInput{ResourceArn: arn, WebACLId: webACLID}
+	op := &request.Operation{Name: "AssociateWebACL", HTTPMethod: "POST", HTTPPath: "/"}
+	if err := w.NewRequest(op, in, &associateWebACLOutput{}).Send(); err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "WAFRegional", "request": "AssociateWebACL", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+	return nil
+}
+
+// DisassociateWebACL removes the web ACL the ALB arn is associated with. ALBs without one are left
+// as they are.
+func (w *WAFRegional) DisassociateWebACL(arn *string) error {
+	in := &disassociateWebACLInput{ResourceArn: arn}
+	op := &request.Operation{Name: "DisassociateWebACL", HTTPMethod: "POST", HTTPPath: "/"}
+	if err := w.NewRequest(op, in, &disassociateWebACLOutput{}).Send(); err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "WAFRegional", "request": "DisassociateWebACL", "code": ErrorCode(err)}).Add(float64(1))
+		return err
+	}
+	return nil
+}
+
+type getWebACLForResourceInput struct {
+	_ struct{} `type:"structure"`
+
+	ResourceArn *string `type:"string"`
+}
+
+type getWebACLForResourceOutput struct {
+	_ struct{} `type:"structure"`
+
+	WebACLSummary *webACLSummary `type:"structure"`
+}
+
+type webACLSummary struct {
+	_ struct{} `type:"structure"`
+
+	Name     *string `type:"string"`
+	WebACLId *string `type:"string"`
+}
+
+// GetWebACLForResource returns the ID of the web ACL the ALB arn is associated with, nil when
+// there's none.
+func (w *WAFRegional) GetWebACLForResource(arn *string) (*string, error) {
+	in := &getWebACLForResourceInput{ResourceArn: arn}
+	out := &getWebACLForResourceOutput{}
+	op := &request.Operation{Name: "GetWebACLForResource", HTTPMethod: "POST", HTTPPath: "/"}
+	if err := w.NewRequest(op, in, out).Send(); err != nil {
+		AWSErrorCount.With(prometheus.Labels{"service": "WAFRegional", "request": "GetWebACLForResource", "code": ErrorCode(err)}).Add(float64(1))
+		return nil, err
+	}
+	if out.WebACLSummary == nil {
+		return nil, nil
+	}
+	return out.WebACLSummary.WebACLId, nil
+}
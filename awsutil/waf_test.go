@@ -0,0 +1,63 @@
+package awsutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestWebACLAssociation(t *testing.T) {
+	var targets []string
+	var requests []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		targets = append(targets, r.Header.Get("X-Amz-Target"))
+		b, _ := ioutil.ReadAll(r.Body)
+		var body map[string]interface{}
+		json.Unmarshal(b, &body)
+		requests = append(requests, body)
+		if r.Header.Get("X-Amz-Target") == "AWSWAF_Regional_20161128.GetWebACLForResource" {
+			fmt.Fprint(w, `{"WebACLSummary":{"Name":"acl","WebACLId":"acl-1"}}`)
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+	waf := NewWAFRegional(sess)
+	if err := waf.AssociateWebACL(aws.String("acl-1"), aws.String("arn-1")); err != nil {
+		t.Fatalf("AssociateWebACL(): returned error %v", err)
+	}
+	if acl, err := waf.GetWebACLForResource(aws.String("arn-1")); err != nil || aws.StringValue(acl) != "acl-1" {
+		t.Fatalf("GetWebACLForResource(): returned %v, error %v", aws.StringValue(acl), err)
+	}
+	if err := waf.DisassociateWebACL(aws.String("arn-1")); err != nil {
+		t.Fatalf("DisassociateWebACL(): returned error %v", err)
+	}
+
+	expectedTargets := []string{"AWSWAF_Regional_20161128.AssociateWebACL", "AWSWAF_Regional_20161128.GetWebACLForResource",
+		"AWSWAF_Regional_20161128.DisassociateWebACL"}
+	if !reflect.DeepEqual(targets, expectedTargets) {
+		t.Errorf("WAFRegional: expected targets %v, actual %v", expectedTargets, targets)
+	}
+	expected := []map[string]interface{}{
+		{"ResourceArn": "arn-1", "WebACLId": "acl-1"},
+		{"ResourceArn": "arn-1"},
+		{"ResourceArn": "arn-1"},
+	}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf("WAFRegional: expected requests %v, actual %v", expected, requests)
+	}
+}
@@ -0,0 +1,335 @@
+package alb
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/controller/config"
+	"github.com/coreos/alb-ingress-controller/controller/util"
+	"github.com/coreos/alb-ingress-controller/log"
+)
+
+// acceleratorTag tags an ALB that's a Global Accelerator endpoint with the listener ARN of the
+// endpoint group, or ownAccelerator when the accelerator was created for the ALB, so the ALB is
+// removed from it when the annotations are removed while the controller isn't running.
+const (
+	acceleratorTag = "GlobalAccelerator"
+	ownAccelerator = "owned"
+)
+
+// Accelerator contains the current and desired state of the Global Accelerator endpoint group a
+// LoadBalancer is an endpoint of, in the region of the ALB. Unless the ALB is added to the listener
+// of an existing accelerator, an accelerator named like the ALB, with a TCP listener on the ports of
+// the ALB, is created for it and deleted with it. Its methods must be called with the awsutil
+// services of the role of the ALB, see awsutil.AssumeRole.
+type Accelerator struct {
+	IngressID             *string
+	Name                  *string // name of the accelerator created for the ALB, nil when ListenerArn is set
+	ListenerArn           *string // listener of an existing accelerator the ALB is added to, nil to create one
+	CurrentAccelerator    *awsutil.Accelerator
+	CurrentListener       *awsutil.AcceleratorListener
+	CurrentEndpointGroups []*awsutil.EndpointGroup // endpoint groups of the listener, one per region
+	DesiredPorts          []int64                  // ports of the listener of the created accelerator
+	DesiredTags           util.Tags
+	Desired               bool // whether the ALB should be an endpoint, false once its annotations were removed
+	Deleted               bool // flag representing the ALB was removed from the endpoint group, and its accelerator deleted
+	synced                bool // whether the current state was looked up
+}
+
+// NewAccelerator returns a new alb.Accelerator adding lb to the endpoint group of the
+// global-accelerator annotations.
+func NewAccelerator(lb *LoadBalancer, annotations *config.Annotations) *Accelerator {
+	a := &Accelerator{
+		IngressID:   lb.IngressID,
+		ListenerArn: annotations.GlobalAccelerator.ListenerArn,
+		DesiredTags: lb.DesiredTags,
+		Desired:     true,
+	}
+	if a.ListenerArn == nil {
+		a.Name = lb.ID
+		for _, port := range annotations.Ports {
+			a.DesiredPorts = append(a.DesiredPorts, port.Port)
+		}
+	}
+	return a
+}
+
+// ImportAccelerator returns the alb.Accelerator lb, an ALB imported from AWS, is an endpoint of,
+// nil when it isn't tagged with one.
+func ImportAccelerator(lb *LoadBalancer) *Accelerator {
+	v, ok := lb.CurrentTags.Get(acceleratorTag)
+	if !ok {
+		return nil
+	}
+	a := &Accelerator{IngressID: lb.IngressID, DesiredTags: lb.CurrentTags}
+	if v == ownAccelerator {
+		a.Name = lb.ID
+	} else {
+		a.ListenerArn = aws.String(v)
+	}
+	return a
+}
+
+// CurrentState returns an alb.Accelerator of the same endpoint group, as ImportAccelerator returns
+// it, so it's looked up again.
+func (a *Accelerator) CurrentState() *Accelerator {
+	return &Accelerator{IngressID: a.IngressID, Name: a.Name, ListenerArn: a.ListenerArn, DesiredTags: a.DesiredTags}
+}
+
+// SameEndpointGroup returns true when desired adds the ALB to the endpoint group of a, rather than
+// to the one of another accelerator.
+func (a *Accelerator) SameEndpointGroup(desired *Accelerator) bool {
+	return aws.StringValue(a.Name) == aws.StringValue(desired.Name) && aws.StringValue(a.ListenerArn) == aws.StringValue(desired.ListenerArn)
+}
+
+// SetDesiredState replaces the desired state of the accelerator with the one of desired.
+func (a *Accelerator) SetDesiredState(desired *Accelerator) {
+	a.DesiredPorts = desired.DesiredPorts
+	a.DesiredTags = desired.DesiredTags
+	a.Desired = true
+}
+
+// StripDesiredState removes the desired state of the accelerator, so Reconcile removes the ALB
+// from the endpoint group.
+func (a *Accelerator) StripDesiredState() {
+	a.DesiredPorts = nil
+	a.Desired = false
+}
+
+// Reconcile compares the current and desired state of this Accelerator instance. Comparison
+// results in no action, adding lb to the endpoint group of its region, along with the creation of
+// the accelerator and its listener, or removing it, along with their deletion. A disabled
+// accelerator can only be deleted once the change deployed, so until it is, Reconcile returns
+// without setting Deleted. The first Reconcile looks up the resources that already exist, e.g.
+// ones created before the controller restarted.
+func (a *Accelerator) Reconcile(lb *LoadBalancer) error {
+	if !a.synced {
+		if err := a.sync(); err != nil {
+			log.Errorf("Failed to look up Global Accelerator endpoint group. Error: %s", *a.IngressID, awsutil.DescribeError(err))
+			return err
+		}
+		a.synced = true
+	}
+
+	if !a.Desired {
+		return a.delete(lb)
+	}
+	if lb.CurrentLoadBalancer == nil {
+		return nil
+	}
+	if a.Name != nil {
+		if err := a.reconcileAccelerator(); err != nil {
+			return err
+		}
+	}
+	return a.reconcileEndpointGroup(lb)
+}
+
+// sync looks up the accelerator named Name and its listener, and the endpoint groups of the
+// listener.
+func (a *Accelerator) sync() error {
+	listenerArn := a.ListenerArn
+	if a.Name != nil {
+		accelerators, err := awsutil.GlobalAcceleratorsvc.ListAccelerators()
+		if err != nil {
+			return err
+		}
+		for _, accelerator := range accelerators {
+			if aws.StringValue(accelerator.Name) != *a.Name {
+				continue
+			}
+			tags, err := awsutil.GlobalAcceleratorsvc.ListTagsForResource(accelerator.AcceleratorArn)
+			if err != nil {
+				return err
+			}
+			if err := tags.OwnershipConflict(a.DesiredTags); err != nil {
+				return fmt.Errorf("accelerator %s already exists and is %s", *a.Name, err.Error())
+			}
+			a.CurrentAccelerator = accelerator
+		}
+		if a.CurrentAccelerator == nil {
+			return nil
+		}
+		listeners, err := awsutil.GlobalAcceleratorsvc.ListListeners(a.CurrentAccelerator.AcceleratorArn)
+		if err != nil || len(listeners) == 0 {
+			return err
+		}
+		a.CurrentListener = listeners[0]
+		listenerArn = a.CurrentListener.ListenerArn
+	}
+
+	groups, err := awsutil.GlobalAcceleratorsvc.ListEndpointGroups(listenerArn)
+	if err != nil {
+		return err
+	}
+	a.CurrentEndpointGroups = groups
+	return nil
+}
+
+// reconcileAccelerator creates the accelerator of the ALB and its listener, or updates the ports
+// of the listener.
+func (a *Accelerator) reconcileAccelerator() error {
+	if a.CurrentAccelerator == nil {
+		accelerator, err := awsutil.GlobalAcceleratorsvc.CreateAccelerator(a.Name, a.DesiredTags)
+		if err != nil {
+			log.Errorf("Failed to create Global Accelerator accelerator %s. Error: %s", *a.IngressID, *a.Name, awsutil.DescribeError(err))
+			return err
+		}
+		a.CurrentAccelerator = accelerator
+		log.Infof("Completed Global Accelerator accelerator creation. Name: %s | ARN: %s | DNS name: %s", *a.IngressID,
+			*a.Name, *accelerator.AcceleratorArn, aws.StringValue(accelerator.DnsName))
+	}
+
+	var ports []*awsutil.PortRange
+	for _, port := range a.DesiredPorts {
+		ports = append(ports, &awsutil.PortRange{FromPort: aws.Int64(port), ToPort: aws.Int64(port)})
+	}
+	if a.CurrentListener == nil {
+		listener, err := awsutil.GlobalAcceleratorsvc.CreateListener(a.CurrentAccelerator.AcceleratorArn, ports)
+		if err != nil {
+			log.Errorf("Failed to create listener of Global Accelerator accelerator %s. Error: %s", *a.IngressID, *a.Name, awsutil.DescribeError(err))
+			return err
+		}
+		a.CurrentListener = listener
+		return nil
+	}
+	if !reflect.DeepEqual(a.CurrentListener.PortRanges, ports) {
+		listener, err := awsutil.GlobalAcceleratorsvc.UpdateListenerPorts(a.CurrentListener.ListenerArn, ports)
+		if err != nil {
+			log.Errorf("Failed to update the ports of Global Accelerator accelerator %s. Error: %s", *a.IngressID, *a.Name, awsutil.DescribeError(err))
+			return err
+		}
+		a.CurrentListener = listener
+		log.Infof("Updated the ports of Global Accelerator accelerator %s to %v.", *a.IngressID, *a.Name, a.DesiredPorts)
+	}
+	return nil
+}
+
+// reconcileEndpointGroup adds the ALB to the endpoint group of its region, creating the group when
+// the listener has none there. An ALB replaced under the same name replaces its predecessor in the
+// group, and the other endpoints are kept.
+func (a *Accelerator) reconcileEndpointGroup(lb *LoadBalancer) error {
+	arn := lb.CurrentLoadBalancer.LoadBalancerArn
+	region := strings.Split(*arn, ":")[3]
+	listenerArn := a.listenerArn()
+
+	for i, group := range a.CurrentEndpointGroups {
+		if aws.StringValue(group.EndpointGroupRegion) != region {
+			continue
+		}
+		endpoints, found := otherEndpoints(group, *lb.ID), false
+		for _, e := range group.EndpointDescriptions {
+			found = found || aws.StringValue(e.EndpointId) == *arn
+		}
+		if found && len(endpoints) == len(group.EndpointDescriptions)-1 {
+			return nil
+		}
+		endpoints = append(endpoints, &awsutil.EndpointConfiguration{EndpointId: arn})
+		updated, err := awsutil.GlobalAcceleratorsvc.UpdateEndpoints(group.EndpointGroupArn, endpoints)
+		if err != nil {
+			log.Errorf("Failed to add ELBV2 (ALB) to Global Accelerator endpoint group %s. Error: %s", *a.IngressID,
+				*group.EndpointGroupArn, awsutil.DescribeError(err))
+			return err
+		}
+		a.CurrentEndpointGroups[i] = updated
+		log.Infof("Added ELBV2 (ALB) to Global Accelerator endpoint group %s.", *a.IngressID, *group.EndpointGroupArn)
+		return nil
+	}
+
+	endpoints := []*awsutil.EndpointConfiguration{{EndpointId: arn}}
+	group, err := awsutil.GlobalAcceleratorsvc.CreateEndpointGroup(listenerArn, aws.String(region), endpoints)
+	if err != nil {
+		log.Errorf("Failed to create Global Accelerator endpoint group in %s. Error: %s", *a.IngressID, region, awsutil.DescribeError(err))
+		return err
+	}
+	a.CurrentEndpointGroups = append(a.CurrentEndpointGroups, group)
+	log.Infof("Completed Global Accelerator endpoint group creation in %s. ARN: %s", *a.IngressID, region, *group.EndpointGroupArn)
+	return nil
+}
+
+// delete removes the ALB from the endpoint groups of the listener. The endpoint groups, listener
+// and accelerator created for the ALB are deleted, the ones of an existing accelerator are kept.
+func (a *Accelerator) delete(lb *LoadBalancer) error {
+	for len(a.CurrentEndpointGroups) > 0 {
+		group := a.CurrentEndpointGroups[0]
+		endpoints := otherEndpoints(group, *lb.ID)
+		switch {
+		case a.Name != nil:
+			if err := awsutil.GlobalAcceleratorsvc.DeleteEndpointGroup(group.EndpointGroupArn); err != nil {
+				log.Errorf("Failed to delete Global Accelerator endpoint group %s. Error: %s", *a.IngressID, *group.EndpointGroupArn, awsutil.DescribeError(err))
+				return err
+			}
+		case len(endpoints) < len(group.EndpointDescriptions):
+			if _, err := awsutil.GlobalAcceleratorsvc.UpdateEndpoints(group.EndpointGroupArn, endpoints); err != nil {
+				log.Errorf("Failed to remove ELBV2 (ALB) from Global Accelerator endpoint group %s. Error: %s", *a.IngressID,
+					*group.EndpointGroupArn, awsutil.DescribeError(err))
+				return err
+			}
+			log.Infof("Removed ELBV2 (ALB) from Global Accelerator endpoint group %s.", *a.IngressID, *group.EndpointGroupArn)
+		}
+		a.CurrentEndpointGroups = a.CurrentEndpointGroups[1:]
+	}
+
+	if a.CurrentListener != nil {
+		if err := awsutil.GlobalAcceleratorsvc.DeleteListener(a.CurrentListener.ListenerArn); err != nil {
+			log.Errorf("Failed to delete listener of Global Accelerator accelerator %s. Error: %s", *a.IngressID, *a.Name, awsutil.DescribeError(err))
+			return err
+		}
+		a.CurrentListener = nil
+	}
+
+	if accelerator := a.CurrentAccelerator; accelerator != nil {
+		var err error
+		if aws.BoolValue(accelerator.Enabled) {
+			log.Infof("Disabling Global Accelerator accelerator %s before deleting it.", *a.IngressID, *a.Name)
+			accelerator, err = awsutil.GlobalAcceleratorsvc.DisableAccelerator(accelerator.AcceleratorArn)
+		} else if aws.StringValue(accelerator.Status) != awsutil.AcceleratorStatusDeployed {
+			accelerator, err = awsutil.GlobalAcceleratorsvc.DescribeAccelerator(accelerator.AcceleratorArn)
+		}
+		if err != nil {
+			log.Errorf("Failed to disable Global Accelerator accelerator %s. Error: %s", *a.IngressID, *a.Name, awsutil.DescribeError(err))
+			return err
+		}
+		a.CurrentAccelerator = accelerator
+		if aws.StringValue(accelerator.Status) != awsutil.AcceleratorStatusDeployed {
+			log.Infof("Waiting for Global Accelerator accelerator %s to be disabled.", *a.IngressID, *a.Name)
+			return nil
+		}
+		if err := awsutil.GlobalAcceleratorsvc.DeleteAccelerator(accelerator.AcceleratorArn); err != nil {
+			log.Errorf("Failed to delete Global Accelerator accelerator %s. Error: %s", *a.IngressID, *a.Name, awsutil.DescribeError(err))
+			return err
+		}
+		a.CurrentAccelerator = nil
+		log.Infof("Completed Global Accelerator accelerator deletion. Name: %s", *a.IngressID, *a.Name)
+	}
+	a.Deleted = true
+	return nil
+}
+
+// listenerArn returns the ARN of the listener the endpoint group belongs to.
+func (a *Accelerator) listenerArn() *string {
+	if a.Name != nil {
+		return a.CurrentListener.ListenerArn
+	}
+	return a.ListenerArn
+}
+
+// otherEndpoints returns the endpoints of group other than the ALBs named name, the current one
+// and the ones it replaced.
+func otherEndpoints(group *awsutil.EndpointGroup, name string) []*awsutil.EndpointConfiguration {
+	var endpoints []*awsutil.EndpointConfiguration
+	for _, e := range group.EndpointDescriptions {
+		if strings.Contains(aws.StringValue(e.EndpointId), ":loadbalancer/app/"+name+"/") {
+			continue
+		}
+		endpoints = append(endpoints, &awsutil.EndpointConfiguration{
+			ClientIPPreservationEnabled: e.ClientIPPreservationEnabled,
+			EndpointId:                  e.EndpointId,
+			Weight:                      e.Weight,
+		})
+	}
+	return endpoints
+}
@@ -0,0 +1,114 @@
+package alb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/awsutil/fake"
+	"github.com/coreos/alb-ingress-controller/controller/config"
+)
+
+const acceleratedALB = "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/cluster-0123456789/1"
+
+func TestAcceleratorOwned(t *testing.T) {
+	clients := fake.New()
+	defer clients.Install()()
+
+	lb := &LoadBalancer{
+		ID:                  aws.String("cluster-0123456789"),
+		IngressID:           aws.String("default-app"),
+		CurrentLoadBalancer: &elbv2.LoadBalancer{LoadBalancerArn: aws.String(acceleratedALB)},
+	}
+	annotations := &config.Annotations{
+		GlobalAccelerator: &config.GlobalAccelerator{},
+		Ports:             []config.ListenerPort{{Port: 80}, {HTTPS: true, Port: 443}},
+	}
+	a := NewAccelerator(lb, annotations)
+	if err := a.Reconcile(lb); err != nil {
+		t.Fatalf("Reconcile() returned error %v", err)
+	}
+	accelerators := clients.GlobalAccelerator.Accelerators()
+	if len(accelerators) != 1 || *accelerators[0].Name != *lb.ID {
+		t.Fatalf("Reconcile(): expected accelerator %s, actual %v", *lb.ID, accelerators)
+	}
+	listenerArn := *a.CurrentListener.ListenerArn
+	expected := map[string][]string{"us-east-1": {acceleratedALB}}
+	if endpoints := clients.GlobalAccelerator.Endpoints(listenerArn); !reflect.DeepEqual(endpoints, expected) {
+		t.Errorf("Reconcile(): expected endpoints %v, actual %v", expected, endpoints)
+	}
+
+	// A replaced ALB replaces its predecessor in the endpoint group, and the endpoint group is
+	// found again after a restart.
+	replaced := "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/cluster-0123456789/2"
+	lb.CurrentLoadBalancer.LoadBalancerArn = aws.String(replaced)
+	a = a.CurrentState()
+	a.SetDesiredState(NewAccelerator(lb, annotations))
+	if err := a.Reconcile(lb); err != nil {
+		t.Fatalf("Reconcile() returned error %v", err)
+	}
+	expected = map[string][]string{"us-east-1": {replaced}}
+	if endpoints := clients.GlobalAccelerator.Endpoints(listenerArn); !reflect.DeepEqual(endpoints, expected) {
+		t.Errorf("Reconcile(): expected endpoints %v, actual %v", expected, endpoints)
+	}
+	if n := len(clients.GlobalAccelerator.Accelerators()); n != 1 {
+		t.Errorf("Reconcile(): expected the accelerator to be reused, actual %d accelerators", n)
+	}
+
+	// The accelerator is deleted once it's disabled.
+	a.StripDesiredState()
+	for i := 0; i < 5 && !a.Deleted; i++ {
+		if err := a.Reconcile(lb); err != nil {
+			t.Fatalf("Reconcile() returned error %v", err)
+		}
+		if i == 0 && a.Deleted {
+			t.Errorf("Reconcile(): expected the accelerator to be disabled before it's deleted")
+		}
+	}
+	if accelerators := clients.GlobalAccelerator.Accelerators(); !a.Deleted || len(accelerators) != 0 {
+		t.Errorf("Reconcile(): expected the accelerator to be deleted, actual %v", accelerators)
+	}
+}
+
+func TestAcceleratorExistingListener(t *testing.T) {
+	clients := fake.New()
+	defer clients.Install()()
+	listenerArn := clients.GlobalAccelerator.AddListener("shared", 443)
+
+	lb := &LoadBalancer{
+		ID:                  aws.String("cluster-0123456789"),
+		IngressID:           aws.String("default-app"),
+		CurrentLoadBalancer: &elbv2.LoadBalancer{LoadBalancerArn: aws.String(acceleratedALB)},
+	}
+	other := &LoadBalancer{
+		ID:                  aws.String("other-0123456789"),
+		IngressID:           aws.String("default-other"),
+		CurrentLoadBalancer: &elbv2.LoadBalancer{LoadBalancerArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/other-0123456789/1")},
+	}
+	annotations := &config.Annotations{GlobalAccelerator: &config.GlobalAccelerator{ListenerArn: aws.String(listenerArn)}}
+	a, b := NewAccelerator(lb, annotations), NewAccelerator(other, annotations)
+	if err := a.Reconcile(lb); err != nil {
+		t.Fatalf("Reconcile() returned error %v", err)
+	}
+	if err := b.Reconcile(other); err != nil {
+		t.Fatalf("Reconcile() returned error %v", err)
+	}
+	expected := map[string][]string{"us-east-1": {acceleratedALB, *other.CurrentLoadBalancer.LoadBalancerArn}}
+	if endpoints := clients.GlobalAccelerator.Endpoints(listenerArn); !reflect.DeepEqual(endpoints, expected) {
+		t.Errorf("Reconcile(): expected endpoints %v, actual %v", expected, endpoints)
+	}
+
+	// Only the ALB is removed, the endpoint group and the accelerator are kept.
+	a = a.CurrentState()
+	if err := a.Reconcile(lb); err != nil || !a.Deleted {
+		t.Fatalf("Reconcile() returned error %v, deleted %v", err, a.Deleted)
+	}
+	expected = map[string][]string{"us-east-1": {*other.CurrentLoadBalancer.LoadBalancerArn}}
+	if endpoints := clients.GlobalAccelerator.Endpoints(listenerArn); !reflect.DeepEqual(endpoints, expected) {
+		t.Errorf("Reconcile(): expected endpoints %v, actual %v", expected, endpoints)
+	}
+	if n := len(clients.GlobalAccelerator.Accelerators()); n != 1 {
+		t.Errorf("Reconcile(): expected the existing accelerator to be kept, actual %d accelerators", n)
+	}
+}
@@ -0,0 +1,138 @@
+package alb
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/log"
+)
+
+// accessLogExpirationRule is the ID of the lifecycle rule expiring the access logs of provisioned
+// buckets.
+const accessLogExpirationRule = "alb-access-logs-expiration"
+
+// provisionAccessLogBuckets makes ALBs create the buckets their access logs are sent to, expiring
+// the logs after accessLogRetentionDays. See SetAccessLogBucketProvisioning.
+var (
+	provisionAccessLogBuckets bool
+	accessLogRetentionDays    int64
+)
+
+// elbAccountIDs are the AWS accounts ELB delivers access logs from, by region. ELB delivers the
+// access logs of the regions missing here, opened since August 2022, as the
+// logdelivery.elasticloadbalancing.amazonaws.com service.
+var elbAccountIDs = map[string]string{
+	"us-east-1":      "127311923021",
+	"us-east-2":      "033677994240",
+	"us-west-1":      "027434742980",
+	"us-west-2":      "797873946194",
+	"af-south-1":     "098369216593",
+	"ap-east-1":      "754344448648",
+	"ap-south-1":     "718504428378",
+	"ap-northeast-1": "582318560864",
+	"ap-northeast-2": "600734575887",
+	"ap-northeast-3": "383597477331",
+	"ap-southeast-1": "114774131450",
+	"ap-southeast-2": "783225319266",
+	"ap-southeast-3": "589379963580",
+	"ca-central-1":   "985666609251",
+	"eu-central-1":   "054676820928",
+	"eu-west-1":      "156460612806",
+	"eu-west-2":      "652711504416",
+	"eu-west-3":      "009996457667",
+	"eu-south-1":     "635631232127",
+	"eu-north-1":     "897822967062",
+	"me-south-1":     "076674570225",
+	"sa-east-1":      "507241528517",
+	"us-gov-west-1":  "048591011584",
+	"us-gov-east-1":  "190560391635",
+	"cn-north-1":     "638102146993",
+	"cn-northwest-1": "037604701340",
+}
+
+// SetAccessLogBucketProvisioning makes ALBs whose attributes send access logs to a bucket that
+// doesn't exist create it, in the region of awsutil.S3svc, before their attributes are set. The
+// bucket gets a policy allowing ELB to deliver the logs and, unless retentionDays isn't positive, a
+// lifecycle rule expiring them after retentionDays. Buckets that exist are left as they are.
+func SetAccessLogBucketProvisioning(enabled bool, retentionDays int64) {
+	provisionAccessLogBuckets = enabled
+	accessLogRetentionDays = retentionDays
+}
+
+// accessLogBucket returns the bucket the DesiredAttributes send access logs to, empty when they
+// don't enable access logs.
+func (lb *LoadBalancer) accessLogBucket() string {
+	var enabled bool
+	var bucket string
+	for _, attribute := range lb.DesiredAttributes {
+		switch *attribute.Key {
+		case "access_logs.s3.enabled":
+			enabled = aws.StringValue(attribute.Value) == "true"
+		case "access_logs.s3.bucket":
+			bucket = aws.StringValue(attribute.Value)
+		}
+	}
+	if !enabled {
+		return ""
+	}
+	return bucket
+}
+
+// ensureAccessLogBucket creates the bucket the ALB sends access logs to when it doesn't exist and
+// buckets are provisioned. Setting the attributes of the ALB fails otherwise.
+func (lb *LoadBalancer) ensureAccessLogBucket() error {
+	bucket := lb.accessLogBucket()
+	if !provisionAccessLogBuckets || bucket == "" {
+		return nil
+	}
+	exists, err := awsutil.S3svc.BucketExists(bucket)
+	if err != nil {
+		return fmt.Errorf("unable to look up access log bucket %s: %s", bucket, err.Error())
+	}
+	if exists {
+		return nil
+	}
+
+	log.Infof("Creating access log bucket %s.", *lb.IngressID, bucket)
+	if err := awsutil.S3svc.CreateBucket(bucket); err != nil {
+		return fmt.Errorf("unable to create access log bucket %s: %s", bucket, err.Error())
+	}
+	if err := awsutil.S3svc.PutBucketPolicy(bucket, accessLogBucketPolicy(bucket, awsutil.S3svc.Region())); err != nil {
+		return fmt.Errorf("unable to set the policy of access log bucket %s: %s", bucket, err.Error())
+	}
+	if accessLogRetentionDays > 0 {
+		if err := awsutil.S3svc.PutBucketExpiration(bucket, accessLogExpirationRule, accessLogRetentionDays); err != nil {
+			return fmt.Errorf("unable to set the retention of access log bucket %s: %s", bucket, err.Error())
+		}
+	}
+	return nil
+}
+
+// accessLogBucketPolicy returns the policy allowing ELB to deliver the access logs of the ALBs of
+// region to bucket, under any prefix.
+func accessLogBucketPolicy(bucket, region string) string {
+	partition := "aws"
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		partition = "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		partition = "aws-us-gov"
+	}
+	principal := map[string]string{"Service": "logdelivery.elasticloadbalancing.amazonaws.com"}
+	if id, ok := elbAccountIDs[region]; ok {
+		principal = map[string]string{"AWS": fmt.Sprintf("arn:%s:iam::%s:root", partition, id)}
+	}
+	policy, _ := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect":    "Allow",
+			"Principal": principal,
+			"Action":    "s3:PutObject",
+			"Resource":  fmt.Sprintf("arn:%s:s3:::%s/*", partition, bucket),
+		}},
+	})
+	return string(policy)
+}
@@ -0,0 +1,184 @@
+package alb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/controller/config"
+	"github.com/coreos/alb-ingress-controller/controller/util"
+	"github.com/coreos/alb-ingress-controller/log"
+)
+
+// The kinds of alarms a target group can have. An alarm is named after its target group and kind,
+// e.g. mycluster-30080-HTTP-0123abc-5xx-rate.
+const (
+	alarmUnhealthyHosts = "unhealthy-hosts"
+	alarm5xxRate        = "5xx-rate"
+	alarmResponseTime   = "response-time"
+)
+
+var alarmKinds = []string{alarmUnhealthyHosts, alarm5xxRate, alarmResponseTime}
+
+// Every alarm evaluates one minute periods, going off after three periods in breach. Periods
+// without requests don't breach.
+const (
+	alarmPeriod            = 60
+	alarmEvaluationPeriods = 3
+	alarmNamespace         = "AWS/ApplicationELB"
+)
+
+// alarmsConfigHash returns the hash of alarms the AlarmsHashTag of a target group carries.
+func alarmsConfigHash(alarms *config.Alarms) string {
+	m := make(map[string]string)
+	if alarms.UnhealthyHosts != nil {
+		m[alarmUnhealthyHosts] = strconv.FormatInt(*alarms.UnhealthyHosts, 10)
+	}
+	if alarms.Target5xxRate != nil {
+		m[alarm5xxRate] = strconv.FormatFloat(*alarms.Target5xxRate, 'g', -1, 64)
+	}
+	if alarms.ResponseTime != nil {
+		m[alarmResponseTime] = strconv.FormatFloat(*alarms.ResponseTime, 'g', -1, 64)
+	}
+	m["sns-topic-arn"] = aws.StringValue(alarms.SNSTopicArn)
+	return configHash(m)
+}
+
+func (tg *TargetGroup) alarmName(kind string) *string {
+	return aws.String(*tg.ID + "-" + kind)
+}
+
+func (tg *TargetGroup) alarmNames() []*string {
+	var names []*string
+	for _, kind := range alarmKinds {
+		names = append(names, tg.alarmName(kind))
+	}
+	return names
+}
+
+// alarmsModified reports whether the alarms desired differ from the ones the AlarmsHashTag of the
+// target group says were put.
+func (tg *TargetGroup) alarmsModified() bool {
+	current, _ := tg.CurrentTags.Get(util.AlarmsHashTag)
+	desired, _ := tg.DesiredTags.Get(util.AlarmsHashTag)
+	return current != desired
+}
+
+// desiredAlarms returns the DesiredAlarms of the target group as CloudWatch alarms on the metrics
+// lb reports for it.
+func (tg *TargetGroup) desiredAlarms(lb *LoadBalancer) []*awsutil.MetricAlarm {
+	if tg.DesiredAlarms == nil {
+		return nil
+	}
+	// The metrics are reported for the ARN suffixes, e.g. app/name/0123456789abcdef and
+	// targetgroup/name/0123456789abcdef.
+	lbArn := *lb.CurrentLoadBalancer.LoadBalancerArn
+	tgArn := *tg.CurrentTargetGroup.TargetGroupArn
+	dimensions := []*awsutil.MetricDimension{
+		{Name: aws.String("LoadBalancer"), Value: aws.String(lbArn[strings.Index(lbArn, "loadbalancer/")+len("loadbalancer/"):])},
+		{Name: aws.String("TargetGroup"), Value: aws.String(tgArn[strings.LastIndex(tgArn, ":")+1:])},
+	}
+	alarm := func(kind, comparison string, threshold float64) *awsutil.MetricAlarm {
+		a := &awsutil.MetricAlarm{
+			AlarmName:          tg.alarmName(kind),
+			AlarmDescription:   aws.String(fmt.Sprintf("Target group %s of ingress %s", *tg.ID, *tg.IngressID)),
+			ComparisonOperator: aws.String(comparison),
+			EvaluationPeriods:  aws.Int64(alarmEvaluationPeriods),
+			Threshold:          aws.Float64(threshold),
+			TreatMissingData:   aws.String("notBreaching"),
+		}
+		if tg.DesiredAlarms.SNSTopicArn != nil {
+			a.ActionsEnabled = aws.Bool(true)
+			a.AlarmActions = []*string{tg.DesiredAlarms.SNSTopicArn}
+			a.OKActions = []*string{tg.DesiredAlarms.SNSTopicArn}
+		}
+		return a
+	}
+	metric := func(a *awsutil.MetricAlarm, name, statistic string) *awsutil.MetricAlarm {
+		a.Namespace = aws.String(alarmNamespace)
+		a.MetricName = aws.String(name)
+		a.Dimensions = dimensions
+		a.Period = aws.Int64(alarmPeriod)
+		a.Statistic = aws.String(statistic)
+		return a
+	}
+
+	var alarms []*awsutil.MetricAlarm
+	if tg.DesiredAlarms.UnhealthyHosts != nil {
+		alarms = append(alarms, metric(alarm(alarmUnhealthyHosts, "GreaterThanOrEqualToThreshold",
+			float64(*tg.DesiredAlarms.UnhealthyHosts)), "UnHealthyHostCount", "Maximum"))
+	}
+	if tg.DesiredAlarms.Target5xxRate != nil {
+		// CloudWatch has no 5xx rate metric, it's computed from the counts of 5xx responses and
+		// requests.
+		stat := func(id, name string) *awsutil.MetricDataQuery {
+			return &awsutil.MetricDataQuery{
+				Id: aws.String(id),
+				MetricStat: &awsutil.MetricStat{
+					Metric: &awsutil.Metric{Namespace: aws.String(alarmNamespace), MetricName: aws.String(name), Dimensions: dimensions},
+					Period: aws.Int64(alarmPeriod),
+					Stat:   aws.String("Sum"),
+				},
+				ReturnData: aws.Bool(false),
+			}
+		}
+		a := alarm(alarm5xxRate, "GreaterThanThreshold", *tg.DesiredAlarms.Target5xxRate)
+		a.Metrics = []*awsutil.MetricDataQuery{
+			stat("errors", "HTTPCode_Target_5XX_Count"),
+			stat("requests", "RequestCount"),
+			{Id: aws.String("rate"), Expression: aws.String("100 * errors / requests"), Label: aws.String("5xx rate"), ReturnData: aws.Bool(true)},
+		}
+		alarms = append(alarms, a)
+	}
+	if tg.DesiredAlarms.ResponseTime != nil {
+		alarms = append(alarms, metric(alarm(alarmResponseTime, "GreaterThanThreshold",
+			*tg.DesiredAlarms.ResponseTime), "TargetResponseTime", "Average"))
+	}
+	return alarms
+}
+
+// reconcileAlarms puts the DesiredAlarms of the target group and deletes the alarms it put before
+// that are no longer desired.
+func (tg *TargetGroup) reconcileAlarms(lb *LoadBalancer) error {
+	current, err := awsutil.CloudWatchsvc.DescribeAlarms(tg.alarmNames())
+	if err != nil {
+		return err
+	}
+	desired := make(map[string]bool)
+	for _, alarm := range tg.desiredAlarms(lb) {
+		if err := awsutil.CloudWatchsvc.PutMetricAlarm(alarm); err != nil {
+			return err
+		}
+		desired[*alarm.AlarmName] = true
+	}
+
+	var stale []*string
+	for _, alarm := range current {
+		if !desired[*alarm.AlarmName] {
+			stale = append(stale, alarm.AlarmName)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	log.Infof("Deleting CloudWatch alarms %s.", *tg.IngressID, strings.Join(aws.StringValueSlice(stale), ", "))
+	return awsutil.CloudWatchsvc.DeleteAlarms(stale)
+}
+
+// deleteAlarms deletes the alarms of the target group, when its AlarmsHashTag says it has some.
+func (tg *TargetGroup) deleteAlarms() error {
+	if _, ok := tg.CurrentTags.Get(util.AlarmsHashTag); !ok {
+		return nil
+	}
+	current, err := awsutil.CloudWatchsvc.DescribeAlarms(tg.alarmNames())
+	if err != nil || len(current) == 0 {
+		return err
+	}
+	var names []*string
+	for _, alarm := range current {
+		names = append(names, alarm.AlarmName)
+	}
+	return awsutil.CloudWatchsvc.DeleteAlarms(names)
+}
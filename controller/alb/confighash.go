@@ -0,0 +1,43 @@
+package alb
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+// configHash returns the hash of attributes, keyed by name, that the ConfigHashTag of an ALB or
+// target group carries. Resources whose tag matches the hash of their desired attributes have
+// those attributes set already, so they aren't described when the controller starts.
+func configHash(attributes map[string]string) string {
+	var keys []string
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	hasher := md5.New()
+	for _, k := range keys {
+		hasher.Write([]byte(k + "=" + attributes[k] + "\n"))
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+func loadBalancerConfigHash(attributes []*elbv2.LoadBalancerAttribute) string {
+	m := make(map[string]string)
+	for _, attribute := range attributes {
+		m[*attribute.Key] = aws.StringValue(attribute.Value)
+	}
+	return configHash(m)
+}
+
+func targetGroupConfigHash(attributes []*elbv2.TargetGroupAttribute) string {
+	m := make(map[string]string)
+	for _, attribute := range attributes {
+		m[*attribute.Key] = aws.StringValue(attribute.Value)
+	}
+	return configHash(m)
+}
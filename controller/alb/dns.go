@@ -0,0 +1,112 @@
+package alb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Names of the built-in DNS providers
+const (
+	// DNSProviderRoute53 publishes hostnames in Route 53 hosted zones, the default.
+	DNSProviderRoute53 = "route53"
+	// DNSProviderNone publishes nothing, leaving DNS to e.g. external-dns.
+	DNSProviderNone = "none"
+)
+
+// DNSProvider publishes the hostnames of ingresses, pointing each at the DNS name of its ALB.
+// Providers other than the built-in ones are registered with RegisterDNSProvider by programs
+// embedding the controller, e.g. to publish hostnames in Cloudflare or NS1.
+type DNSProvider interface {
+	// Name identifies the provider in the DNS_PROVIDER setting.
+	Name() string
+	// Prepare is called before lb is reconciled, e.g. to remove records the ALB will no longer
+	// answer for once it was changed.
+	Prepare(lb *LoadBalancer) error
+	// Publish is called once lb was reconciled. It points lb.Hostname and lb.ExtraHostnames at
+	// the DNS name of lb.CurrentLoadBalancer, or removes their records when lb.Deleted is set.
+	Publish(lb *LoadBalancer) error
+}
+
+var (
+	dnsProvidersMu sync.Mutex
+	dnsProviders   = map[string]DNSProvider{
+		DNSProviderRoute53: route53Provider{},
+		DNSProviderNone:    noneProvider{},
+	}
+)
+
+// RegisterDNSProvider makes p available under its name to LookupDNSProvider. It panics when a
+// provider of the same name is registered already.
+func RegisterDNSProvider(p DNSProvider) {
+	dnsProvidersMu.Lock()
+	defer dnsProvidersMu.Unlock()
+	if _, ok := dnsProviders[p.Name()]; ok {
+		panic(fmt.Sprintf("DNS provider %s is registered twice", p.Name()))
+	}
+	dnsProviders[p.Name()] = p
+}
+
+// LookupDNSProvider returns the DNS provider registered under name. An empty name is Route 53.
+func LookupDNSProvider(name string) (DNSProvider, error) {
+	if name == "" {
+		name = DNSProviderRoute53
+	}
+	dnsProvidersMu.Lock()
+	defer dnsProvidersMu.Unlock()
+	p, ok := dnsProviders[name]
+	if !ok {
+		var names []string
+		for n := range dnsProviders {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("DNS provider %s is unknown, must be one of %s", name, strings.Join(names, ", "))
+	}
+	return p, nil
+}
+
+// route53Provider reconciles the ResourceRecordSet and IPv6RecordSet of LoadBalancers, along with
+// their health checks and routing policies.
+type route53Provider struct{}
+
+func (route53Provider) Name() string {
+	return DNSProviderRoute53
+}
+
+// Prepare deletes the AAAA record of an ALB that is no longer dualstack before SetIpAddressType
+// drops its IPv6 addresses, so the record never points at an ALB IPv6 clients can't reach.
+func (route53Provider) Prepare(lb *LoadBalancer) error {
+	if lb.IPv6RecordSet == nil || lb.IPv6RecordSet.DesiredResourceRecordSet != nil {
+		return nil
+	}
+	return lb.reconcileIPv6RecordSet()
+}
+
+// Publish reconciles the A or CNAME record of lb, then its AAAA record and the records of its extra
+// hostnames. A new AAAA record is only created once the ALB is dualstack.
+func (route53Provider) Publish(lb *LoadBalancer) error {
+	if err := lb.ResourceRecordSet.Reconcile(lb); err != nil {
+		return err
+	}
+	if err := lb.reconcileIPv6RecordSet(); err != nil {
+		return err
+	}
+	return lb.reconcileExtraRecordSets()
+}
+
+// noneProvider leaves the hostnames of ingresses unpublished.
+type noneProvider struct{}
+
+func (noneProvider) Name() string {
+	return DNSProviderNone
+}
+
+func (noneProvider) Prepare(lb *LoadBalancer) error {
+	return nil
+}
+
+func (noneProvider) Publish(lb *LoadBalancer) error {
+	return nil
+}
@@ -0,0 +1,28 @@
+package alb
+
+import "testing"
+
+func TestLookupDNSProvider(t *testing.T) {
+	var tests = []struct {
+		name     string
+		provider string
+		ok       bool
+	}{
+		{"", DNSProviderRoute53, true},
+		{"route53", DNSProviderRoute53, true},
+		{"none", DNSProviderNone, true},
+		{"cloudflare", "", false},
+	}
+
+	for _, tt := range tests {
+		p, err := LookupDNSProvider(tt.name)
+		switch {
+		case tt.ok && err != nil:
+			t.Errorf("LookupDNSProvider(%v): expected %v, actual error %v", tt.name, tt.provider, err)
+		case !tt.ok && err == nil:
+			t.Errorf("LookupDNSProvider(%v): expected an error, actual %v", tt.name, p.Name())
+		case tt.ok && p.Name() != tt.provider:
+			t.Errorf("LookupDNSProvider(%v): expected %v, actual %v", tt.name, tt.provider, p.Name())
+		}
+	}
+}
@@ -6,16 +6,20 @@ import (
 	"github.com/coreos/alb-ingress-controller/awsutil"
 	"github.com/coreos/alb-ingress-controller/controller/config"
 	"github.com/coreos/alb-ingress-controller/log"
-	"github.com/golang/glog"
 )
 
-// Listener contains the relevant ID, Rules, and current/desired Listeners
+// Listener contains the relevant ID, Rules, and current/desired Listeners. The certificates a
+// HTTPS listener serves through SNI are kept apart from its default certificate, as the ELBV2 API
+// manages them separately.
 type Listener struct {
-	IngressID       *string
-	CurrentListener *elbv2.Listener
-	DesiredListener *elbv2.Listener
-	Rules           Rules
-	deleted         bool
+	IngressID              *string
+	CurrentListener        *elbv2.Listener
+	DesiredListener        *elbv2.Listener
+	CurrentSNICertificates []*elbv2.Certificate
+	DesiredSNICertificates []*elbv2.Certificate
+	CurrentWeights         []awsutil.TargetGroupWeight // of the weighted default action, which the vendored aws-sdk-go can't describe
+	Rules                  Rules
+	deleted                bool
 }
 
 // NewListener returns a new alb.Listener based on the parameters provided.
@@ -35,16 +39,20 @@ func NewListener(annotations *config.Annotations, ingressID *string) []*Listener
 			},
 		}
 
+		listenerT := &Listener{
+			DesiredListener: listener,
+			IngressID:       ingressID,
+		}
+
 		if port.HTTPS {
 			listener.Certificates = []*elbv2.Certificate{
 				{CertificateArn: annotations.CertificateArn},
 			}
 			listener.Protocol = aws.String("HTTPS")
-		}
-
-		listenerT := &Listener{
-			DesiredListener: listener,
-			IngressID:       ingressID,
+			listener.SslPolicy = annotations.SSLPolicy
+			for _, arn := range annotations.SNICertificateArns {
+				listenerT.DesiredSNICertificates = append(listenerT.DesiredSNICertificates, &elbv2.Certificate{CertificateArn: arn})
+			}
 		}
 
 		listeners = append(listeners, listenerT)
@@ -78,7 +86,7 @@ func (l *Listener) Reconcile(lb *LoadBalancer) error {
 			*l.IngressID, *l.CurrentListener.ListenerArn, *l.CurrentListener.Port,
 			*l.CurrentListener.Protocol)
 
-	case l.needsModification(l.DesiredListener): // current and desired diff; needs mod
+	case l.needsModification(l.DesiredListener) || l.defaultActionModified(lb): // current and desired diff; needs mod
 		log.Infof("Start Listener modification.", *l.IngressID)
 		if err := l.modify(lb); err != nil {
 			return err
@@ -88,32 +96,22 @@ func (l *Listener) Reconcile(lb *LoadBalancer) error {
 		log.Debugf("No listener modification required.", *l.IngressID)
 	}
 
+	if l.DesiredListener != nil && l.CurrentListener != nil && l.sniCertificatesModified() {
+		log.Infof("Start Listener SNI certificates modification.", *l.IngressID)
+		if err := l.modifySNICertificates(); err != nil {
+			return err
+		}
+		log.Infof("Completed Listener SNI certificates modification. Certificates: %s", *l.IngressID,
+			log.Prettify(l.CurrentSNICertificates))
+	}
+
 	return nil
 }
 
 // Adds a Listener to an existing ALB in AWS. This Listener maps the ALB to an existing TargetGroup.
 func (l *Listener) create(lb *LoadBalancer) error {
 	l.DesiredListener.LoadBalancerArn = lb.CurrentLoadBalancer.LoadBalancerArn
-
-	// TODO: If we couldn't resolve default, we 'default' to the first targetgroup known.
-	// Questionable approach.
-	l.DesiredListener.DefaultActions[0].TargetGroupArn = lb.TargetGroups[0].CurrentTargetGroup.TargetGroupArn
-
-	// Look for the default rule in the list of rules known to the Listener. If the default is found,
-	// use the Kubernetes service name attached to that.
-	for _, rule := range l.Rules {
-		if *rule.DesiredRule.IsDefault {
-			log.Infof("Located default rule. Rule: %s", *l.IngressID, log.Prettify(rule.DesiredRule))
-			tgIndex := lb.TargetGroups.LookupBySvc(rule.SvcName)
-			if tgIndex < 0 {
-				log.Errorf("Failed to locate TargetGroup related to this service. Defaulting to first Target Group. SVC: %s",
-					*l.IngressID, rule.SvcName)
-			} else {
-				ctg := lb.TargetGroups[tgIndex].CurrentTargetGroup
-				l.DesiredListener.DefaultActions[0].TargetGroupArn = ctg.TargetGroupArn
-			}
-		}
-	}
+	l.DesiredListener.DefaultActions[0].TargetGroupArn = l.defaultTargetGroupArn(lb)
 
 	// Attempt listener creation.
 	in := elbv2.CreateListenerInput{
@@ -121,6 +119,7 @@ func (l *Listener) create(lb *LoadBalancer) error {
 		LoadBalancerArn: l.DesiredListener.LoadBalancerArn,
 		Protocol:        l.DesiredListener.Protocol,
 		Port:            l.DesiredListener.Port,
+		SslPolicy:       l.DesiredListener.SslPolicy,
 		DefaultActions: []*elbv2.Action{
 			{
 				Type:           l.DesiredListener.DefaultActions[0].Type,
@@ -135,25 +134,142 @@ func (l *Listener) create(lb *LoadBalancer) error {
 	}
 
 	l.CurrentListener = o
+	l.CurrentWeights = nil
 	return nil
 }
 
-// Modifies a listener
-// TODO: Determine if this needs to be implemented and if so, implement it.
+// modify changes the protocol, certificates and security policy of an existing listener in place,
+// e.g. when the certificate-arn annotation points to a reissued certificate, so the listener and
+// its rules don't have to be recreated.
 func (l *Listener) modify(lb *LoadBalancer) error {
 	if l.CurrentListener == nil {
 		// not a modify, a create
 		return l.create(lb)
 	}
 
-	glog.Infof("Modifying existing %s listener %s", *lb.ID, *l.CurrentListener.ListenerArn)
-	glog.Info("NOT IMPLEMENTED!!!!")
+	in := elbv2.ModifyListenerInput{
+		ListenerArn:  l.CurrentListener.ListenerArn,
+		Port:         l.DesiredListener.Port,
+		Protocol:     l.DesiredListener.Protocol,
+		Certificates: l.DesiredListener.Certificates,
+		SslPolicy:    l.DesiredListener.SslPolicy,
+		DefaultActions: []*elbv2.Action{
+			{
+				Type:           l.DesiredListener.DefaultActions[0].Type,
+				TargetGroupArn: l.defaultTargetGroupArn(lb),
+			},
+		},
+	}
+	var o *elbv2.Listener
+	var err error
+	weights := l.shiftWeights(lb)
+	if weights != nil {
+		o, err = awsutil.ALBsvc.ModifyWeightedListener(in, weights)
+	} else {
+		o, err = awsutil.ALBsvc.ModifyListener(in)
+	}
+	if err != nil {
+		log.Errorf("Failed Listener modification. ARN: %s | Error: %s.", *l.IngressID,
+			*l.CurrentListener.ListenerArn, err.Error())
+		return err
+	}
+	l.CurrentListener = o
+	l.CurrentWeights = weights
+	if aws.StringValue(o.Protocol) != "HTTPS" {
+		// SNI certificates are dropped along with the default certificate.
+		l.CurrentSNICertificates = nil
+	}
 
 	log.Infof("Completed Listener modification. ARN: %s | Port: %s | Proto: %s.",
 		*l.IngressID, *l.CurrentListener.ListenerArn, *l.CurrentListener.Port, *l.CurrentListener.Protocol)
 	return nil
 }
 
+// modifySNICertificates adds the desired SNI certificates missing from the listener and removes
+// the ones no longer desired.
+func (l *Listener) modifySNICertificates() error {
+	current := certificateSet(l.CurrentSNICertificates)
+	desired := certificateSet(l.DesiredSNICertificates)
+
+	var additions, removals []*elbv2.Certificate
+	for _, c := range l.DesiredSNICertificates {
+		if !current[*c.CertificateArn] {
+			additions = append(additions, c)
+		}
+	}
+	for _, c := range l.CurrentSNICertificates {
+		if !desired[*c.CertificateArn] {
+			removals = append(removals, c)
+		}
+	}
+
+	if len(additions) > 0 {
+		if err := awsutil.ALBsvc.AddListenerCertificates(l.CurrentListener.ListenerArn, additions); err != nil {
+			log.Errorf("Failed adding Listener SNI certificates %s. ARN: %s | Error: %s.", *l.IngressID,
+				log.Prettify(additions), *l.CurrentListener.ListenerArn, err.Error())
+			return err
+		}
+	}
+	if len(removals) > 0 {
+		if err := awsutil.ALBsvc.RemoveListenerCertificates(l.CurrentListener.ListenerArn, removals); err != nil {
+			log.Errorf("Failed removing Listener SNI certificates %s. ARN: %s | Error: %s.", *l.IngressID,
+				log.Prettify(removals), *l.CurrentListener.ListenerArn, err.Error())
+			return err
+		}
+	}
+
+	l.CurrentSNICertificates = l.DesiredSNICertificates
+	return nil
+}
+
+// defaultTargetGroupArn returns the ARN of the target group the listener forwards to by default:
+// the one of the service of the default rule, or the first target group the ALB has.
+func (l *Listener) defaultTargetGroupArn(lb *LoadBalancer) *string {
+	// TODO: If we couldn't resolve default, we 'default' to the first targetgroup known.
+	// Questionable approach.
+	arn := lb.TargetGroups[0].CurrentTargetGroup.TargetGroupArn
+
+	// Look for the default rule in the list of rules known to the Listener. If the default is found,
+	// use the Kubernetes service name attached to that.
+	for _, rule := range l.Rules {
+		if *rule.DesiredRule.IsDefault {
+			log.Infof("Located default rule. Rule: %s", *l.IngressID, log.Prettify(rule.DesiredRule))
+			tgIndex := lb.TargetGroups.LookupBySvc(rule.SvcName, rule.SvcPort)
+			if tgIndex < 0 {
+				log.Errorf("Failed to locate TargetGroup related to this service. Defaulting to first Target Group. SVC: %s | Port: %s",
+					*l.IngressID, rule.SvcName, rule.SvcPort.String())
+			} else {
+				arn = lb.TargetGroups[tgIndex].CurrentTargetGroup.TargetGroupArn
+			}
+		}
+	}
+	return arn
+}
+
+// shiftWeights returns the weights the default action forwards to the target groups of the default
+// rule's service with while traffic shifts between them, nil otherwise.
+func (l *Listener) shiftWeights(lb *LoadBalancer) []awsutil.TargetGroupWeight {
+	for _, rule := range l.Rules {
+		if rule.DesiredRule != nil && *rule.DesiredRule.IsDefault {
+			return lb.TargetGroups.shiftWeights(rule.SvcName, rule.SvcPort)
+		}
+	}
+	return nil
+}
+
+// defaultActionModified reports whether the default action forwards elsewhere than desired, e.g.
+// because the target group of the default rule's service was replaced.
+func (l *Listener) defaultActionModified(lb *LoadBalancer) bool {
+	if len(lb.TargetGroups) == 0 || lb.TargetGroups[0].CurrentTargetGroup == nil || len(l.CurrentListener.DefaultActions) == 0 {
+		return false
+	}
+	weights := l.shiftWeights(lb)
+	if weights != nil || l.CurrentWeights != nil {
+		return !awsutil.DeepEqual(l.CurrentWeights, weights)
+	}
+	return aws.StringValue(l.CurrentListener.DefaultActions[0].TargetGroupArn) != aws.StringValue(l.defaultTargetGroupArn(lb))
+}
+
 // delete adds a Listener from an existing ALB in AWS.
 func (l *Listener) delete(lb *LoadBalancer) error {
 	in := elbv2.DeleteListenerInput{
@@ -180,6 +296,37 @@ func (l *Listener) needsModification(target *elbv2.Listener) bool {
 		return true
 	case !awsutil.DeepEqual(l.CurrentListener.Certificates, target.Certificates):
 		return true
+	// Without a desired policy, the one AWS picked is left alone.
+	case target.SslPolicy != nil && !awsutil.DeepEqual(l.CurrentListener.SslPolicy, target.SslPolicy):
+		return true
 	}
 	return false
 }
+
+// sniCertificatesModified reports whether the listener serves other SNI certificates than desired.
+func (l *Listener) sniCertificatesModified() bool {
+	return !sameCertificates(l.CurrentSNICertificates, l.DesiredSNICertificates)
+}
+
+// sameCertificates reports whether a and b hold the same certificates, regardless of their order.
+func sameCertificates(a, b []*elbv2.Certificate) bool {
+	setA, setB := certificateSet(a), certificateSet(b)
+	if len(setA) != len(setB) {
+		return false
+	}
+	for arn := range setA {
+		if !setB[arn] {
+			return false
+		}
+	}
+	return true
+}
+
+// certificateSet returns the ARNs of certificates as a set.
+func certificateSet(certificates []*elbv2.Certificate) map[string]bool {
+	out := make(map[string]bool)
+	for _, c := range certificates {
+		out[aws.StringValue(c.CertificateArn)] = true
+	}
+	return out
+}
@@ -1,16 +1,26 @@
 package alb
 
 import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/log"
 )
 
 // Listeners is a slice of Listener pointers
 type Listeners []*Listener
 
-// Find returns the position of the listener, returning -1 if unfound.
+// Find returns the position of the listener on the same port as listener, returning -1 if unfound.
 func (ls Listeners) Find(listener *elbv2.Listener) int {
 	for p, v := range ls {
-		if !v.needsModification(listener) {
+		// A listener on the same port is modified in place, whatever else changed.
+		l := v.CurrentListener
+		if l == nil {
+			l = v.DesiredListener
+		}
+		if l != nil && *l.Port == *listener.Port {
 			return p
 		}
 	}
@@ -29,6 +39,15 @@ func (ls Listeners) Reconcile(lb *LoadBalancer, tgs *TargetGroups) error {
 		if err := listener.Reconcile(lb); err != nil {
 			return err
 		}
+		if listener.Rules.swappable(lb, listener) {
+			rules, err := listener.Rules.swap(lb, listener)
+			listener.Rules = rules
+			if err != nil {
+				return err
+			}
+		} else {
+			listener.Rules = listener.Rules.adoptReplaced()
+		}
 		if err := listener.Rules.Reconcile(lb, listener); err != nil {
 			return err
 		}
@@ -48,10 +67,67 @@ func (ls Listeners) Reconcile(lb *LoadBalancer, tgs *TargetGroups) error {
 	return nil
 }
 
+// DetectDrift re-describes the listeners of the ALB, returning a description of each listener and
+// rule that was deleted out of band, and of listeners whose default or SNI certificates were
+// changed. Deleted listeners and rules lose their current state, so the next Reconcile creates
+// them again.
+func (ls Listeners) DetectDrift(lb *LoadBalancer) ([]string, error) {
+	listeners, err := awsutil.ALBsvc.DescribeListeners(lb.CurrentLoadBalancer.LoadBalancerArn)
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool)
+	for _, listener := range listeners {
+		exists[*listener.ListenerArn] = true
+	}
+
+	var drifts []string
+	for _, listener := range ls {
+		if listener.CurrentListener == nil || listener.DesiredListener == nil {
+			continue
+		}
+		if !exists[*listener.CurrentListener.ListenerArn] {
+			drifts = append(drifts, fmt.Sprintf("listener on port %d was deleted", *listener.CurrentListener.Port))
+			listener.CurrentListener = nil
+			listener.CurrentSNICertificates = nil
+			listener.Rules.StripCurrentState()
+			continue
+		}
+		for _, current := range listeners {
+			if *current.ListenerArn != *listener.CurrentListener.ListenerArn {
+				continue
+			}
+			if !awsutil.DeepEqual(current.Certificates, listener.CurrentListener.Certificates) {
+				drifts = append(drifts, fmt.Sprintf("certificates of listener on port %d changed to %s",
+					*listener.CurrentListener.Port, log.Prettify(current.Certificates)))
+			}
+			listener.CurrentListener = current
+		}
+		if aws.StringValue(listener.CurrentListener.Protocol) == "HTTPS" {
+			certificates, err := awsutil.ALBsvc.DescribeListenerCertificates(listener.CurrentListener.ListenerArn)
+			if err != nil {
+				return drifts, err
+			}
+			if !sameCertificates(certificates, listener.CurrentSNICertificates) {
+				drifts = append(drifts, fmt.Sprintf("SNI certificates of listener on port %d changed to %s",
+					*listener.CurrentListener.Port, log.Prettify(certificates)))
+			}
+			listener.CurrentSNICertificates = certificates
+		}
+		ruleDrifts, err := listener.Rules.DetectDrift(listener)
+		if err != nil {
+			return drifts, err
+		}
+		drifts = append(drifts, ruleDrifts...)
+	}
+	return drifts, nil
+}
+
 // StripDesiredState removes the DesiredListener from all Listeners in the slice.
 func (ls Listeners) StripDesiredState() {
 	for _, listener := range ls {
 		listener.DesiredListener = nil
+		listener.DesiredSNICertificates = nil
 	}
 }
 
@@ -64,6 +140,7 @@ func (ls Listeners) StripDesiredState() {
 func (ls Listeners) StripCurrentState() {
 	for _, listener := range ls {
 		listener.CurrentListener = nil
+		listener.CurrentSNICertificates = nil
 		listener.Rules.StripCurrentState()
 	}
 }
@@ -5,8 +5,12 @@ import (
 	"encoding/hex"
 	"fmt"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/coreos/alb-ingress-controller/awsutil"
 	"github.com/coreos/alb-ingress-controller/controller/config"
@@ -22,13 +26,27 @@ type LoadBalancer struct {
 	CurrentLoadBalancer *elbv2.LoadBalancer // current version of load balancer in AWS
 	DesiredLoadBalancer *elbv2.LoadBalancer // desired version of load balancer in AWS
 	ResourceRecordSet   *ResourceRecordSet
+	IPv6RecordSet       *ResourceRecordSet   // AAAA alias record of a dualstack ALB, nil otherwise
+	ExtraHostnames      []string             // hostnames of the hostname annotations pointing at the ALB besides Hostname
+	ExtraRecordSets     []*ResourceRecordSet // records of the ExtraHostnames
+	SecurityGroup       *SecurityGroup       // security group managed for the ALB, nil when the ingress names its own
+	SharedPermissions   []*ec2.IpPermission  // desired inbound rules of the ALB in the SharedSecurityGroup, nil when it doesn't use it
+	Standby             *Standby             // ALB mirroring this one in a standby region, if any
+	Accelerator         *Accelerator         // Global Accelerator endpoint group the ALB is an endpoint of, if any
 	TargetGroups        TargetGroups
 	Listeners           Listeners
 	CurrentTags         util.Tags
 	DesiredTags         util.Tags
-	Deleted             bool // flag representing the LoadBalancer instance was fully deleted.
+	CurrentAttributes   []*elbv2.LoadBalancerAttribute
+	DesiredAttributes   []*elbv2.LoadBalancerAttribute // only the attributes set through annotations
+	CurrentWebACLID     *string                        // WAF Regional web ACL associated with the ALB, nil when none is
+	DesiredWebACLID     *string                        // web ACL of the waf-acl-id annotation, nil to have none
+	Deleted             bool                           // flag representing the LoadBalancer instance was fully deleted.
+	UnmanagedDNS        bool                           // the ingress opted out of DNS management, its hostname isn't published
 	LastRulePriority    int64
-	LastError           error // last error (if any) this load balancer experienced when attempting to reconcile
+	LastError           error       // last error (if any) this load balancer experienced when attempting to reconcile
+	LastReconciled      time.Time   // when the load balancer was last reconciled, zero until it was
+	IngressLock         sync.Locker // lock of the ingress owning the ALB, held by deferred Route 53 changes
 }
 
 type loadBalancerChange uint
@@ -38,6 +56,9 @@ const (
 	subnetsModified
 	tagsModified
 	schemeModified
+	attributesModified
+	ipAddressTypeModified
+	webACLModified
 )
 
 // NewLoadBalancer returns a new alb.LoadBalancer based on the parameters provided.
@@ -51,19 +72,67 @@ func NewLoadBalancer(clustername, namespace, ingressname, hostname string, ingre
 	}
 
 	name := fmt.Sprintf("%s-%s", clustername, output)
+	if annotations.LoadBalancerName != nil {
+		name = *annotations.LoadBalancerName
+	}
 
 	tags = append(tags, &elbv2.Tag{
 		Key:   aws.String("Hostname"),
 		Value: aws.String(hostname),
 	})
 
+	// The other hostnames are tagged as well, so their records are found again on restart.
+	var extraHostnames []string
+	for _, h := range annotations.Hostnames {
+		if h != hostname {
+			extraHostnames = append(extraHostnames, h)
+		}
+	}
+	if len(extraHostnames) > 0 {
+		tags = append(tags, &elbv2.Tag{
+			Key:   aws.String("ExtraHostnames"),
+			Value: aws.String(strings.Join(extraHostnames, " ")),
+		})
+	}
+
+	// So is the web ACL, so it's disassociated when the annotation is removed while the controller
+	// isn't running.
+	if annotations.WebACLID != nil {
+		tags = append(tags, &elbv2.Tag{
+			Key:   aws.String("WebACLId"),
+			Value: annotations.WebACLID,
+		})
+	}
+
+	// And the Global Accelerator endpoint group, so the ALB is removed from it.
+	if a := annotations.GlobalAccelerator; a != nil {
+		value := ownAccelerator
+		if a.ListenerArn != nil {
+			value = *a.ListenerArn
+		}
+		tags = append(tags, &elbv2.Tag{
+			Key:   aws.String(acceleratorTag),
+			Value: aws.String(value),
+		})
+	}
+
+	// The security group isn't tagged with the hash of the ALB's attributes.
+	lbTags := append(util.Tags{{
+		Key:   aws.String(util.ConfigHashTag),
+		Value: aws.String(loadBalancerConfigHash(annotations.LoadBalancerAttributes)),
+	}}, tags...)
+
 	lb := &LoadBalancer{
-		ID:          aws.String(name),
-		IngressID:   ingressID,
-		Hostname:    aws.String(hostname),
-		DesiredTags: tags,
+		ID:                aws.String(name),
+		IngressID:         ingressID,
+		Hostname:          aws.String(hostname),
+		ExtraHostnames:    extraHostnames,
+		DesiredTags:       lbTags,
+		DesiredAttributes: annotations.LoadBalancerAttributes,
+		DesiredWebACLID:   annotations.WebACLID,
 		DesiredLoadBalancer: &elbv2.LoadBalancer{
 			AvailabilityZones: annotations.Subnets.AsAvailabilityZones(),
+			IpAddressType:     annotations.IPAddressType,
 			LoadBalancerName:  aws.String(name),
 			Scheme:            annotations.Scheme,
 			SecurityGroups:    annotations.SecurityGroups,
@@ -72,6 +141,11 @@ func NewLoadBalancer(clustername, namespace, ingressname, hostname string, ingre
 		LastRulePriority: 1,
 	}
 
+	// Without security groups from the ingress, the controller manages one for the ALB.
+	if len(annotations.SecurityGroups) == 0 {
+		lb.SecurityGroup = NewSecurityGroup(annotations, tags, namespace, ingressname, lb.ID, ingressID)
+	}
+
 	return lb
 }
 
@@ -79,6 +153,18 @@ func NewLoadBalancer(clustername, namespace, ingressname, hostname string, ingre
 // results in no action, the creation, the deletion, or the modification of an AWS ELBV2 (ALB) to
 // satisfy the ingress's current state.
 func (lb *LoadBalancer) Reconcile() error {
+	// A managed security group must exist before the ALB can use it.
+	if lb.DesiredLoadBalancer != nil && lb.SecurityGroup != nil && lb.SecurityGroup.DesiredSecurityGroup != nil {
+		if err := lb.SecurityGroup.Reconcile(); err != nil {
+			return err
+		}
+		lb.DesiredLoadBalancer.SecurityGroups = []*string{lb.SecurityGroup.CurrentSecurityGroup.GroupId}
+	}
+	// Without security groups, the ALB would be created with the default security group of the VPC.
+	if lb.DesiredLoadBalancer != nil && lb.SharedPermissions != nil && len(lb.DesiredLoadBalancer.SecurityGroups) == 0 {
+		return fmt.Errorf("the shared security group of ALB %s isn't available", *lb.ID)
+	}
+
 	switch {
 	case lb.DesiredLoadBalancer == nil: // lb should be deleted
 		if lb.CurrentLoadBalancer == nil {
@@ -105,7 +191,7 @@ func (lb *LoadBalancer) Reconcile() error {
 		needsModification, _ := lb.needsModification()
 		if needsModification == 0 {
 			log.Debugf("No modification of ELBV2 (ALB) required.", *lb.IngressID)
-			return nil
+			break
 		}
 
 		log.Infof("Start ELBV2 (ALB) modification.", *lb.IngressID)
@@ -114,6 +200,17 @@ func (lb *LoadBalancer) Reconcile() error {
 		}
 	}
 
+	// A managed security group that's no longer desired can only be deleted once the ALB has been
+	// deleted or moved to other security groups.
+	if lb.SecurityGroup != nil && lb.SecurityGroup.DesiredSecurityGroup == nil {
+		if err := lb.SecurityGroup.Reconcile(); err != nil {
+			return err
+		}
+		if lb.SecurityGroup.CurrentSecurityGroup == nil {
+			lb.SecurityGroup = nil
+		}
+	}
+
 	return nil
 }
 
@@ -126,15 +223,52 @@ func (lb *LoadBalancer) create() error {
 		Scheme:         lb.DesiredLoadBalancer.Scheme,
 		Tags:           lb.DesiredTags,
 		SecurityGroups: lb.DesiredLoadBalancer.SecurityGroups,
+		IpAddressType:  lb.DesiredLoadBalancer.IpAddressType,
 	}
 
 	o, err := awsutil.ALBsvc.Create(in)
 	if err != nil {
-		log.Errorf("Failed to create ELBV2 (ALB). Error: %s", *lb.IngressID, err.Error())
+		log.Errorf("Failed to create ELBV2 (ALB). Error: %s", *lb.IngressID, awsutil.DescribeError(err))
 		return err
 	}
 
+	// Creating an ALB that already exists with the same settings returns the existing ALB. Refuse to
+	// take it over if another cluster or controller owns it.
+	tags, err := awsutil.ALBsvc.DescribeTags(o.LoadBalancerArn)
+	if err != nil {
+		log.Errorf("Failed to describe tags of ELBV2 (ALB). Error: %s", *lb.IngressID, awsutil.DescribeError(err))
+		return err
+	}
+	if err := tags.OwnershipConflict(lb.DesiredTags); err != nil {
+		log.Errorf("Failed to create ELBV2 (ALB). ALB %s already exists and is %s", *lb.IngressID, *o.LoadBalancerName, err.Error())
+		return fmt.Errorf("ALB %s already exists and is %s", *o.LoadBalancerName, err.Error())
+	}
+
 	lb.CurrentLoadBalancer = o
+
+	if len(lb.DesiredAttributes) > 0 {
+		if err := lb.ensureAccessLogBucket(); err != nil {
+			log.Errorf("Failed to set ELBV2 (ALB) attributes. Error: %s", *lb.IngressID, err.Error())
+			return err
+		}
+		in := elbv2.ModifyLoadBalancerAttributesInput{
+			LoadBalancerArn: o.LoadBalancerArn,
+			Attributes:      lb.DesiredAttributes,
+		}
+		if err := awsutil.ALBsvc.ModifyLoadBalancerAttributes(in); err != nil {
+			log.Errorf("Failed to set ELBV2 (ALB) attributes. Error: %s", *lb.IngressID, awsutil.DescribeError(err))
+			return err
+		}
+	}
+	lb.CurrentAttributes = lb.DesiredAttributes
+
+	if lb.DesiredWebACLID != nil {
+		if err := awsutil.WAFsvc.AssociateWebACL(lb.DesiredWebACLID, o.LoadBalancerArn); err != nil {
+			log.Errorf("Failed to associate web ACL %s with ELBV2 (ALB). Error: %s", *lb.IngressID, *lb.DesiredWebACLID, awsutil.DescribeError(err))
+			return err
+		}
+	}
+	lb.CurrentWebACLID = lb.DesiredWebACLID
 	return nil
 }
 
@@ -151,7 +285,7 @@ func (lb *LoadBalancer) modify() error {
 				SecurityGroups:  lb.DesiredLoadBalancer.SecurityGroups,
 			}
 			if err := awsutil.ALBsvc.SetSecurityGroups(in); err != nil {
-				log.Errorf("Failed ELBV2 security groups modification. Error: %s", err.Error())
+				log.Errorf("Failed ELBV2 security groups modification. Error: %s", *lb.IngressID, awsutil.DescribeError(err))
 				return err
 			}
 			lb.CurrentLoadBalancer.SecurityGroups = lb.DesiredLoadBalancer.SecurityGroups
@@ -174,11 +308,66 @@ func (lb *LoadBalancer) modify() error {
 				log.Prettify(lb.CurrentLoadBalancer.AvailabilityZones))
 		}
 
-		// Modify Tags
+		// Modify IP address type
+		if needsMod&ipAddressTypeModified != 0 {
+			log.Infof("Start ELBV2 IP address type modification.", *lb.IngressID)
+			in := elbv2.SetIpAddressTypeInput{
+				LoadBalancerArn: lb.CurrentLoadBalancer.LoadBalancerArn,
+				IpAddressType:   lb.DesiredLoadBalancer.IpAddressType,
+			}
+			if err := awsutil.ALBsvc.SetIpAddressType(in); err != nil {
+				log.Errorf("Failed ELBV2 IP address type modification. Error: %s", *lb.IngressID, awsutil.DescribeError(err))
+				return err
+			}
+			lb.CurrentLoadBalancer.IpAddressType = lb.DesiredLoadBalancer.IpAddressType
+			log.Infof("Completed ELBV2 IP address type modification. Type is %s.", *lb.IngressID,
+				*lb.CurrentLoadBalancer.IpAddressType)
+		}
+
+		// Modify Attributes
+		if needsMod&attributesModified != 0 {
+			log.Infof("Start ELBV2 attributes modification.", *lb.IngressID)
+			if err := lb.ensureAccessLogBucket(); err != nil {
+				log.Errorf("Failed ELBV2 (ALB) attributes modification. Error: %s", *lb.IngressID, err.Error())
+				return err
+			}
+			in := elbv2.ModifyLoadBalancerAttributesInput{
+				LoadBalancerArn: lb.CurrentLoadBalancer.LoadBalancerArn,
+				Attributes:      lb.DesiredAttributes,
+			}
+			if err := awsutil.ALBsvc.ModifyLoadBalancerAttributes(in); err != nil {
+				log.Errorf("Failed ELBV2 (ALB) attributes modification. Error: %s", *lb.IngressID, awsutil.DescribeError(err))
+				return err
+			}
+			lb.CurrentAttributes = lb.DesiredAttributes
+			log.Infof("Completed ELBV2 attributes modification. Attributes are %s.", *lb.IngressID,
+				log.Prettify(lb.CurrentAttributes))
+		}
+
+		// Modify the web ACL association
+		if needsMod&webACLModified != 0 {
+			log.Infof("Start ELBV2 web ACL modification.", *lb.IngressID)
+			var err error
+			if lb.DesiredWebACLID != nil {
+				err = awsutil.WAFsvc.AssociateWebACL(lb.DesiredWebACLID, lb.CurrentLoadBalancer.LoadBalancerArn)
+			} else {
+				err = awsutil.WAFsvc.DisassociateWebACL(lb.CurrentLoadBalancer.LoadBalancerArn)
+			}
+			if err != nil {
+				log.Errorf("Failed ELBV2 (ALB) web ACL modification. Error: %s", *lb.IngressID, awsutil.DescribeError(err))
+				return err
+			}
+			lb.CurrentWebACLID = lb.DesiredWebACLID
+			log.Infof("Completed ELBV2 web ACL modification. Web ACL is %s.", *lb.IngressID,
+				aws.StringValue(lb.CurrentWebACLID))
+		}
+
+		// Modify Tags, once the attributes their ConfigHash tag vouches for are set.
 		if needsMod&tagsModified != 0 {
 			log.Infof("Start ELBV2 tag modification.", *lb.IngressID)
 			if err := awsutil.ALBsvc.UpdateTags(lb.CurrentLoadBalancer.LoadBalancerArn, lb.CurrentTags, lb.DesiredTags); err != nil {
-				log.Errorf("Failed ELBV2 (ALB) tag modification. Error: %s", err.Error())
+				log.Errorf("Failed ELBV2 (ALB) tag modification. Error: %s", *lb.IngressID, awsutil.DescribeError(err))
+				return err
 			}
 			lb.CurrentTags = lb.DesiredTags
 			log.Infof("Completed ELBV2 tag modification. Tags are %s.", *lb.IngressID,
@@ -212,10 +401,148 @@ func (lb *LoadBalancer) delete() error {
 		return err
 	}
 
+	// Listeners and rules are deleted along with the ALB, deleting them afterwards would fail.
+	lb.Listeners.StripCurrentState()
 	lb.Deleted = true
 	return nil
 }
 
+// associatedWebACL returns true when the controller associated the ALB with its current web ACL,
+// as recorded by the WebACLId tag.
+func (lb *LoadBalancer) associatedWebACL() bool {
+	webACLID, ok := lb.CurrentTags.Get("WebACLId")
+	return ok && webACLID == aws.StringValue(lb.CurrentWebACLID)
+}
+
+// DetectDrift re-describes the ALB and the resources the controller created for it, replacing their
+// current state with what exists in AWS. Changes made out of band, such as a deleted listener, an
+// edited security group or a changed attribute, are returned as human readable descriptions and
+// corrected by the next Reconcile. Only resources that are meant to exist are checked.
+func (lb *LoadBalancer) DetectDrift() ([]string, error) {
+	if lb.CurrentLoadBalancer == nil || lb.DesiredLoadBalancer == nil {
+		return nil, nil
+	}
+
+	current, err := awsutil.ALBsvc.DescribeLoadBalancer(lb.CurrentLoadBalancer.LoadBalancerArn)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		// Listeners and rules are deleted along with the ALB. Target groups aren't.
+		drifts := []string{fmt.Sprintf("ALB %s was deleted", *lb.ID)}
+		lb.CurrentLoadBalancer = nil
+		lb.Listeners.StripCurrentState()
+		return drifts, nil
+	}
+
+	var drifts []string
+	currentSecurityGroups := util.AWSStringSlice(current.SecurityGroups)
+	previousSecurityGroups := util.AWSStringSlice(lb.CurrentLoadBalancer.SecurityGroups)
+	sort.Sort(currentSecurityGroups)
+	sort.Sort(previousSecurityGroups)
+	if awsutil.Prettify(currentSecurityGroups) != awsutil.Prettify(previousSecurityGroups) {
+		drifts = append(drifts, fmt.Sprintf("security groups of ALB %s changed to %s", *lb.ID, log.Prettify(currentSecurityGroups)))
+	}
+	currentSubnets := util.AvailabilityZones(current.AvailabilityZones).AsSubnets()
+	previousSubnets := util.AvailabilityZones(lb.CurrentLoadBalancer.AvailabilityZones).AsSubnets()
+	sort.Sort(currentSubnets)
+	sort.Sort(previousSubnets)
+	if awsutil.Prettify(currentSubnets) != awsutil.Prettify(previousSubnets) {
+		drifts = append(drifts, fmt.Sprintf("subnets of ALB %s changed to %s", *lb.ID, log.Prettify(currentSubnets)))
+	}
+	lb.CurrentLoadBalancer = current
+
+	// Like when reconciling, only the attributes set through annotations are compared.
+	if len(lb.DesiredAttributes) > 0 {
+		attributes, err := awsutil.ALBsvc.DescribeLoadBalancerAttributes(current.LoadBalancerArn)
+		if err != nil {
+			return drifts, err
+		}
+		described := make(map[string]string)
+		for _, attribute := range attributes {
+			described[*attribute.Key] = aws.StringValue(attribute.Value)
+		}
+		previous := make(map[string]string)
+		for _, attribute := range lb.CurrentAttributes {
+			previous[*attribute.Key] = aws.StringValue(attribute.Value)
+		}
+		for _, attribute := range lb.DesiredAttributes {
+			was, ok := previous[*attribute.Key]
+			// Attributes that weren't described on startup are the ones the ConfigHash tag was made from.
+			if lb.CurrentAttributes == nil {
+				was, ok = aws.StringValue(attribute.Value), true
+			}
+			if value := described[*attribute.Key]; ok && value != was {
+				drifts = append(drifts, fmt.Sprintf("attribute %s of ALB %s changed to %s", *attribute.Key, *lb.ID, value))
+			}
+		}
+		lb.CurrentAttributes = attributes
+	}
+
+	webACLID, err := awsutil.WAFsvc.GetWebACLForResource(current.LoadBalancerArn)
+	if err != nil {
+		return drifts, err
+	}
+	if aws.StringValue(webACLID) != aws.StringValue(lb.CurrentWebACLID) {
+		drifts = append(drifts, fmt.Sprintf("web ACL of ALB %s changed to %s", *lb.ID, aws.StringValue(webACLID)))
+	}
+	lb.CurrentWebACLID = webACLID
+
+	if lb.SecurityGroup != nil {
+		sgDrifts, err := lb.SecurityGroup.DetectDrift()
+		if err != nil {
+			return drifts, err
+		}
+		drifts = append(drifts, sgDrifts...)
+	}
+
+	tgDrifts, err := lb.TargetGroups.DetectDrift()
+	if err != nil {
+		return drifts, err
+	}
+	drifts = append(drifts, tgDrifts...)
+
+	listenerDrifts, err := lb.Listeners.DetectDrift(lb)
+	if err != nil {
+		return drifts, err
+	}
+	return append(drifts, listenerDrifts...), nil
+}
+
+// CheckQuotas returns an error describing why the desired rules of the LoadBalancer can't fit on an
+// ALB. Each path gets a rule with a single path-pattern condition on every listener, and its hosts
+// are split across several rules when they exceed maxRuleConditionValues, see NewRules. The number
+// of rules and the paths themselves, see validatePathPattern, are the remaining limits.
+func (lb *LoadBalancer) CheckQuotas() error {
+	rules := 0
+	for _, l := range lb.Listeners {
+		if l.DesiredListener == nil {
+			continue
+		}
+		for _, r := range l.Rules {
+			if r.DesiredRule == nil || *r.DesiredRule.IsDefault {
+				continue
+			}
+			rules++
+			for _, condition := range r.DesiredRule.Conditions {
+				if *condition.Field != "path-pattern" {
+					continue
+				}
+				for _, value := range condition.Values {
+					if err := validatePathPattern(*value); err != nil {
+						return fmt.Errorf("host %s: %s", *lb.Hostname, err.Error())
+					}
+				}
+			}
+		}
+	}
+	if rules > ruleQuota {
+		return fmt.Errorf("host %s needs %d rules (one per path, listener port and %d hosts), ALBs can have at most %d rules besides the default ones",
+			*lb.Hostname, rules, maxRuleConditionValues-1, ruleQuota)
+	}
+	return nil
+}
+
 // needsModification returns if a LB needs to be modified and if it can be modified in place
 // first parameter is true if the LB needs to be changed
 // second parameter true if it can be changed in place
@@ -240,6 +567,19 @@ func (lb *LoadBalancer) needsModification() (loadBalancerChange, bool) {
 		changes |= subnetsModified
 	}
 
+	// ALBs created before the IP address type was managed have none set, they're ipv4.
+	if lb.DesiredLoadBalancer.IpAddressType != nil &&
+		aws.StringValue(lb.CurrentLoadBalancer.IpAddressType) != *lb.DesiredLoadBalancer.IpAddressType {
+		changes |= ipAddressTypeModified
+	}
+
+	// Web ACLs associated outside of the controller with ALBs it never associated one with are left
+	// alone.
+	if aws.StringValue(lb.CurrentWebACLID) != aws.StringValue(lb.DesiredWebACLID) &&
+		(lb.DesiredWebACLID != nil || lb.associatedWebACL()) {
+		changes |= webACLModified
+	}
+
 	currentSecurityGroups := util.AWSStringSlice(lb.CurrentLoadBalancer.SecurityGroups)
 	desiredSecurityGroups := util.AWSStringSlice(lb.DesiredLoadBalancer.SecurityGroups)
 	sort.Sort(currentSecurityGroups)
@@ -254,5 +594,106 @@ func (lb *LoadBalancer) needsModification() (loadBalancerChange, bool) {
 		changes |= tagsModified
 	}
 
+	// Only the attributes set through annotations are compared; the others are left as they are.
+	// Attributes that weren't described on startup are the ones the ConfigHash tag was made from.
+	if hash, ok := lb.CurrentTags.Get(util.ConfigHashTag); ok && lb.CurrentAttributes == nil {
+		if len(lb.DesiredAttributes) > 0 && hash != loadBalancerConfigHash(lb.DesiredAttributes) {
+			changes |= attributesModified
+		}
+		return changes, true
+	}
+	current := make(map[string]string)
+	for _, attribute := range lb.CurrentAttributes {
+		current[*attribute.Key] = aws.StringValue(attribute.Value)
+	}
+	for _, attribute := range lb.DesiredAttributes {
+		if value, ok := current[*attribute.Key]; !ok || value != aws.StringValue(attribute.Value) {
+			changes |= attributesModified
+			break
+		}
+	}
+
 	return changes, true
 }
+
+// creations holds the resources of a LoadBalancer that don't exist in AWS yet. When reconciling
+// the LoadBalancer fails part way through, the ones that did get created are rolled back, so the
+// ALB doesn't serve traffic with a partial set of listeners and rules.
+type creations struct {
+	targetGroups TargetGroups
+	listeners    Listeners
+	rules        Rules // rules to add to listeners that already exist
+}
+
+// pendingCreations returns the target groups, listeners and rules that the next reconcile of this
+// LoadBalancer will create.
+func (lb *LoadBalancer) pendingCreations() creations {
+	var c creations
+	for _, tg := range lb.TargetGroups {
+		if tg.CurrentTargetGroup == nil && tg.DesiredTargetGroup != nil {
+			c.targetGroups = append(c.targetGroups, tg)
+		}
+	}
+	for _, l := range lb.Listeners {
+		if l.CurrentListener == nil && l.DesiredListener != nil {
+			c.listeners = append(c.listeners, l)
+			continue
+		}
+		// Rules taking over the rule they replace are modified rather than created.
+		replaced := l.Rules.replacements()
+		for _, r := range l.Rules {
+			if r.CurrentRule == nil && r.DesiredRule != nil && !*r.DesiredRule.IsDefault && replaced[r] == nil {
+				c.rules = append(c.rules, r)
+			}
+		}
+	}
+	return c
+}
+
+// rollback deletes the resources in c that were created. Rules go first and target groups last, as
+// a target group can't be deleted while a listener or rule forwards to it. Resources that fail to
+// delete keep their current state, so the next reconcile picks them up as if the rollback never
+// started.
+func (lb *LoadBalancer) rollback(c creations) {
+	if len(c.rules) == 0 && len(c.listeners) == 0 && len(c.targetGroups) == 0 {
+		return
+	}
+	log.Infof("Start rollback of the target groups, listeners and rules created.", *lb.IngressID)
+	for _, r := range c.rules {
+		if r.CurrentRule == nil {
+			continue
+		}
+		if err := r.delete(lb); err != nil {
+			continue
+		}
+		r.CurrentRule = nil
+		r.deleted = false
+	}
+	for _, l := range c.listeners {
+		if l.CurrentListener == nil {
+			continue
+		}
+		if err := l.delete(lb); err != nil {
+			continue
+		}
+		l.CurrentListener = nil
+		l.CurrentSNICertificates = nil
+		l.deleted = false
+		l.Rules.StripCurrentState()
+	}
+	for _, tg := range c.targetGroups {
+		if tg.CurrentTargetGroup == nil {
+			continue
+		}
+		if err := tg.delete(); err != nil {
+			continue
+		}
+		tg.CurrentTargetGroup = nil
+		tg.CurrentTags = nil
+		tg.CurrentTargets = nil
+		tg.CurrentStaticTargets = nil
+		tg.TargetHealth = nil
+		tg.deleted = false
+	}
+	log.Infof("Completed rollback of the target groups, listeners and rules created.", *lb.IngressID)
+}
@@ -0,0 +1,165 @@
+package alb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/awsutil/fake"
+	"github.com/coreos/alb-ingress-controller/controller/util"
+)
+
+func TestDetectAttributeDrift(t *testing.T) {
+	clients := fake.New()
+	defer clients.Install()()
+	clients.EC2.AddSubnet("subnet-1", "vpc-1", "us-east-1a", nil)
+
+	current, err := awsutil.ALBsvc.Create(elbv2.CreateLoadBalancerInput{
+		Name:    aws.String("cluster-0123456789"),
+		Subnets: aws.StringSlice([]string{"subnet-1"}),
+	})
+	if err != nil {
+		t.Fatalf("Create returned error %v", err)
+	}
+	attributes := []*elbv2.LoadBalancerAttribute{
+		{Key: aws.String("idle_timeout.timeout_seconds"), Value: aws.String("120")},
+	}
+	lb := &LoadBalancer{
+		ID:                  aws.String("cluster-0123456789"),
+		CurrentLoadBalancer: current,
+		DesiredLoadBalancer: current,
+		CurrentAttributes:   attributes,
+		DesiredAttributes:   attributes,
+	}
+	in := elbv2.ModifyLoadBalancerAttributesInput{LoadBalancerArn: current.LoadBalancerArn, Attributes: attributes}
+	if err := awsutil.ALBsvc.ModifyLoadBalancerAttributes(in); err != nil {
+		t.Fatalf("ModifyLoadBalancerAttributes returned error %v", err)
+	}
+
+	if drifts, err := lb.DetectDrift(); err != nil || len(drifts) > 0 {
+		t.Errorf("DetectDrift() = %v, %v, expected no drift", drifts, err)
+	}
+
+	in.Attributes = []*elbv2.LoadBalancerAttribute{{Key: aws.String("idle_timeout.timeout_seconds"), Value: aws.String("60")}}
+	if err := awsutil.ALBsvc.ModifyLoadBalancerAttributes(in); err != nil {
+		t.Fatalf("ModifyLoadBalancerAttributes returned error %v", err)
+	}
+	drifts, err := lb.DetectDrift()
+	if err != nil || len(drifts) != 1 || !strings.Contains(drifts[0], "idle_timeout.timeout_seconds") {
+		t.Fatalf("DetectDrift() = %v, %v, expected the idle timeout to have drifted", drifts, err)
+	}
+	if changes, _ := lb.needsModification(); changes&attributesModified == 0 {
+		t.Errorf("needsModification() = %v, expected the attributes to be modified", changes)
+	}
+}
+
+func TestDetectWebACLDrift(t *testing.T) {
+	clients := fake.New()
+	defer clients.Install()()
+	clients.EC2.AddSubnet("subnet-1", "vpc-1", "us-east-1a", nil)
+
+	current, err := awsutil.ALBsvc.Create(elbv2.CreateLoadBalancerInput{
+		Name:    aws.String("cluster-0123456789"),
+		Subnets: aws.StringSlice([]string{"subnet-1"}),
+	})
+	if err != nil {
+		t.Fatalf("Create returned error %v", err)
+	}
+	clients.WAFRegional.Associate(*current.LoadBalancerArn, "acl-1")
+	lb := &LoadBalancer{
+		ID:                  aws.String("cluster-0123456789"),
+		CurrentLoadBalancer: current,
+		DesiredLoadBalancer: current,
+		CurrentTags:         util.Tags{{Key: aws.String("WebACLId"), Value: aws.String("acl-1")}},
+		CurrentWebACLID:     aws.String("acl-1"),
+		DesiredWebACLID:     aws.String("acl-1"),
+	}
+
+	clients.WAFRegional.Associate(*current.LoadBalancerArn, "acl-2")
+	drifts, err := lb.DetectDrift()
+	if err != nil || len(drifts) != 1 || !strings.Contains(drifts[0], "acl-2") {
+		t.Fatalf("DetectDrift() = %v, %v, expected the web ACL to have drifted", drifts, err)
+	}
+	if changes, _ := lb.needsModification(); changes&webACLModified == 0 {
+		t.Errorf("needsModification() = %v, expected the web ACL to be modified", changes)
+	}
+}
+
+func TestWebACLModification(t *testing.T) {
+	current := &elbv2.LoadBalancer{Scheme: aws.String("internet-facing")}
+	var tests = []struct {
+		current, desired *string
+		tagged           bool
+		modified         bool
+	}{
+		{nil, nil, false, false},
+		{nil, aws.String("acl-1"), false, true},
+		{aws.String("acl-1"), aws.String("acl-1"), true, false},
+		{aws.String("acl-1"), aws.String("acl-2"), true, true},
+		{aws.String("acl-1"), nil, true, true},
+		// A web ACL associated outside of the controller is kept without the annotation.
+		{aws.String("acl-1"), nil, false, false},
+		{aws.String("acl-1"), aws.String("acl-2"), false, true},
+	}
+	for _, tt := range tests {
+		lb := &LoadBalancer{
+			CurrentLoadBalancer: current,
+			DesiredLoadBalancer: current,
+			CurrentWebACLID:     tt.current,
+			DesiredWebACLID:     tt.desired,
+		}
+		if tt.tagged {
+			lb.CurrentTags = util.Tags{{Key: aws.String("WebACLId"), Value: tt.current}}
+		}
+		changes, _ := lb.needsModification()
+		if modified := changes&webACLModified != 0; modified != tt.modified {
+			t.Errorf("needsModification() with web ACL %v desired %v tagged %v: expected modified %v, actual %v",
+				aws.StringValue(tt.current), aws.StringValue(tt.desired), tt.tagged, tt.modified, modified)
+		}
+	}
+}
+
+func TestEnsureAccessLogBucket(t *testing.T) {
+	clients := fake.New()
+	defer clients.Install()()
+	clients.S3.AddBucket("existing")
+	defer SetAccessLogBucketProvisioning(false, 0)
+	SetAccessLogBucketProvisioning(true, 30)
+
+	for _, bucket := range []string{"existing", "logs"} {
+		lb := &LoadBalancer{IngressID: aws.String("default-app"), DesiredAttributes: []*elbv2.LoadBalancerAttribute{
+			{Key: aws.String("access_logs.s3.enabled"), Value: aws.String("true")},
+			{Key: aws.String("access_logs.s3.bucket"), Value: aws.String(bucket)},
+		}}
+		if err := lb.ensureAccessLogBucket(); err != nil {
+			t.Fatalf("ensureAccessLogBucket() of %s returned error %v", bucket, err)
+		}
+	}
+	if b := clients.S3.Bucket("existing"); b.Policy != "" || b.ExpirationDays != 0 {
+		t.Errorf("ensureAccessLogBucket(): expected the existing bucket to be left alone, actual %+v", b)
+	}
+	expected := `{"Statement":[{"Action":"s3:PutObject","Effect":"Allow","Principal":{"AWS":"arn:aws:iam::127311923021:root"},` +
+		`"Resource":"arn:aws:s3:::logs/*"}],"Version":"2012-10-17"}`
+	if b := clients.S3.Bucket("logs"); b == nil || b.Policy != expected || b.ExpirationDays != 30 {
+		t.Errorf("ensureAccessLogBucket(): expected logs to be created with the ELB policy and expiration, actual %+v", b)
+	}
+}
+
+func TestAccessLogBucketPolicy(t *testing.T) {
+	var tests = []struct {
+		region    string
+		principal string
+	}{
+		{"eu-west-1", `{"AWS":"arn:aws:iam::156460612806:root"}`},
+		{"us-gov-west-1", `{"AWS":"arn:aws-us-gov:iam::048591011584:root"}`},
+		// Newer regions deliver the logs as a service.
+		{"ap-southeast-5", `{"Service":"logdelivery.elasticloadbalancing.amazonaws.com"}`},
+	}
+	for _, tt := range tests {
+		if policy := accessLogBucketPolicy("logs", tt.region); !strings.Contains(policy, `"Principal":`+tt.principal) {
+			t.Errorf("accessLogBucketPolicy(%s) = %s, expected principal %s", tt.region, policy, tt.principal)
+		}
+	}
+}
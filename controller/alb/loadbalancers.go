@@ -1,5 +1,7 @@
 package alb
 
+import "time"
+
 // LoadBalancers is a slice of LoadBalancer pointers
 type LoadBalancers []*LoadBalancer
 
@@ -14,34 +16,54 @@ func (l LoadBalancers) Find(lb *LoadBalancer) int {
 }
 
 // Reconcile calls for state synchronization (comparison of current and desired) for the load
-// balancer and its resource record set, target group(s), and listener(s). It returns 2
-// LoadBalancers (slices), the first being the list of all known LoadBalancers and the subset
-// second being of LoadBalancers, from the first list, that failed to reconcile.
-func (l LoadBalancers) Reconcile(disableRoute53 bool) (LoadBalancers, LoadBalancers) {
+// balancer and its resource record set, target group(s), and listener(s). The hostnames of the
+// load balancers are published by dns, unless they're UnmanagedDNS. It returns 2 LoadBalancers
+// (slices), the first being the list of all known LoadBalancers and the subset second being of
+// LoadBalancers, from the first list, that failed to reconcile.
+func (l LoadBalancers) Reconcile(dns DNSProvider) (LoadBalancers, LoadBalancers) {
 	loadbalancers := l
 	errLBs := LoadBalancers{}
 
 	for i, loadbalancer := range l {
+		loadbalancer.LastError = nil
+		loadbalancer.LastReconciled = time.Now()
 
+		publisher := dns
+		if loadbalancer.UnmanagedDNS {
+			publisher = noneProvider{}
+		}
+		if err := publisher.Prepare(loadbalancer); err != nil {
+			loadbalancer.LastError = err
+			errLBs = append(errLBs, loadbalancer)
+			continue
+		}
 		if err := loadbalancer.Reconcile(); err != nil {
 			loadbalancer.LastError = err
 			errLBs = append(errLBs, loadbalancer)
 			continue
 		}
-		if !disableRoute53 {
-			if err := loadbalancer.ResourceRecordSet.Reconcile(loadbalancer); err != nil {
-				loadbalancer.LastError = err
-				errLBs = append(errLBs, loadbalancer)
-				continue
-			}
+		if err := publisher.Publish(loadbalancer); err != nil {
+			loadbalancer.LastError = err
+			errLBs = append(errLBs, loadbalancer)
+			continue
 		}
+		// Target groups, listeners and rules are rolled back together when one of them fails.
+		created := loadbalancer.pendingCreations()
 		if err := loadbalancer.TargetGroups.Reconcile(loadbalancer); err != nil {
+			loadbalancer.rollback(created)
 			loadbalancer.LastError = err
 			errLBs = append(errLBs, loadbalancer)
 			continue
 		}
 		// This syncs listeners and rules
 		if err := loadbalancer.Listeners.Reconcile(loadbalancer, &loadbalancer.TargetGroups); err != nil {
+			loadbalancer.rollback(created)
+			loadbalancer.LastError = err
+			errLBs = append(errLBs, loadbalancer)
+			continue
+		}
+		// Target groups listeners and rules forwarded to are deleted once they forward elsewhere.
+		if err := loadbalancer.TargetGroups.DeleteUnused(loadbalancer); err != nil {
 			loadbalancer.LastError = err
 			errLBs = append(errLBs, loadbalancer)
 			continue
@@ -55,12 +77,78 @@ func (l LoadBalancers) Reconcile(disableRoute53 bool) (LoadBalancers, LoadBalanc
 	return loadbalancers, errLBs
 }
 
+// reconcileIPv6RecordSet reconciles the AAAA record of the LoadBalancer, if any, and forgets it
+// once it's deleted.
+func (lb *LoadBalancer) reconcileIPv6RecordSet() error {
+	r := lb.IPv6RecordSet
+	if r == nil {
+		return nil
+	}
+	r.SharedHealthCheckID = nil
+	if a := lb.ResourceRecordSet; a != nil && a.CurrentHealthCheck != nil {
+		r.SharedHealthCheckID = a.CurrentHealthCheck.Id
+	}
+	if err := r.Reconcile(lb); err != nil {
+		return err
+	}
+	// The ALB is no longer dualstack.
+	if r.DesiredResourceRecordSet == nil && r.CurrentResourceRecordSet == nil {
+		lb.IPv6RecordSet = nil
+	}
+	return nil
+}
+
+// reconcileExtraRecordSets reconciles the records of the ExtraHostnames of the LoadBalancer, and
+// forgets the ones deleted.
+func (lb *LoadBalancer) reconcileExtraRecordSets() error {
+	var kept []*ResourceRecordSet
+	for _, r := range lb.ExtraRecordSets {
+		if err := r.Reconcile(lb); err != nil {
+			return err
+		}
+		if r.DesiredResourceRecordSet != nil || r.CurrentResourceRecordSet != nil {
+			kept = append(kept, r)
+		}
+	}
+	lb.ExtraRecordSets = kept
+	return nil
+}
+
+// RecordSets returns the Route 53 records of the LoadBalancer.
+func (lb *LoadBalancer) RecordSets() []*ResourceRecordSet {
+	var records []*ResourceRecordSet
+	for _, r := range append([]*ResourceRecordSet{lb.ResourceRecordSet, lb.IPv6RecordSet}, lb.ExtraRecordSets...) {
+		if r != nil {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
 // StripDesiredState removes the DesiredLoadBalancers from a LoadBalancers slice
 func (l LoadBalancers) StripDesiredState() {
 	for _, lb := range l {
 		lb.DesiredLoadBalancer = nil
 		if lb.ResourceRecordSet != nil {
 			lb.ResourceRecordSet.DesiredResourceRecordSet = nil
+			lb.ResourceRecordSet.DesiredHealthCheck = nil
+		}
+		if lb.IPv6RecordSet != nil {
+			lb.IPv6RecordSet.DesiredResourceRecordSet = nil
+			lb.IPv6RecordSet.DesiredHealthCheck = nil
+		}
+		for _, r := range lb.ExtraRecordSets {
+			r.DesiredResourceRecordSet = nil
+			r.DesiredHealthCheck = nil
+		}
+		if lb.SecurityGroup != nil {
+			lb.SecurityGroup.DesiredSecurityGroup = nil
+		}
+		if lb.Standby != nil {
+			lb.Standby.StripDesiredState()
+		}
+		if lb.Accelerator != nil {
+			lb.Accelerator.StripDesiredState()
 		}
 	}
 }
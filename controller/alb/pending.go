@@ -0,0 +1,182 @@
+package alb
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+)
+
+// loadBalancerChangeNames describe the loadBalancerChanges an ALB can be modified in place with.
+var loadBalancerChangeNames = []struct {
+	change loadBalancerChange
+	name   string
+}{
+	{securityGroupsModified, "security groups"},
+	{subnetsModified, "subnets"},
+	{tagsModified, "tags"},
+	{attributesModified, "attributes"},
+	{ipAddressTypeModified, "IP address type"},
+}
+
+// PendingChanges describes the changes the next reconcile would make to the AWS resources of the
+// LoadBalancer, for troubleshooting. Route 53 records are only reported when they'd be created or
+// deleted, as their alias target and health check are resolved while reconciling.
+func (lb *LoadBalancer) PendingChanges() []string {
+	changes := []string{}
+	switch {
+	case lb.DesiredLoadBalancer == nil:
+		if lb.CurrentLoadBalancer != nil {
+			changes = append(changes, fmt.Sprintf("delete ALB %s", *lb.ID))
+		}
+	case lb.CurrentLoadBalancer == nil:
+		changes = append(changes, fmt.Sprintf("create ALB %s", *lb.ID))
+	default:
+		// The security groups managed for the ALB are only filled in while reconciling.
+		desired := *lb.DesiredLoadBalancer
+		switch {
+		case lb.SecurityGroup != nil && lb.SecurityGroup.DesiredSecurityGroup != nil && lb.SecurityGroup.CurrentSecurityGroup != nil:
+			desired.SecurityGroups = []*string{lb.SecurityGroup.CurrentSecurityGroup.GroupId}
+		case lb.SharedPermissions != nil && len(desired.SecurityGroups) == 0:
+			desired.SecurityGroups = lb.CurrentLoadBalancer.SecurityGroups
+		}
+		l := *lb
+		l.DesiredLoadBalancer = &desired
+		modified, inPlace := l.needsModification()
+		if !inPlace {
+			changes = append(changes, fmt.Sprintf("replace ALB %s, its scheme changed", *lb.ID))
+			break
+		}
+		for _, c := range loadBalancerChangeNames {
+			if modified&c.change != 0 {
+				changes = append(changes, fmt.Sprintf("modify %s of ALB %s", c.name, *lb.ID))
+			}
+		}
+	}
+
+	if sg := lb.SecurityGroup; sg != nil {
+		switch {
+		case sg.DesiredSecurityGroup == nil:
+			if sg.CurrentSecurityGroup != nil {
+				changes = append(changes, fmt.Sprintf("delete security group %s", *sg.CurrentSecurityGroup.GroupId))
+			}
+		case sg.CurrentSecurityGroup == nil:
+			changes = append(changes, fmt.Sprintf("create security group %s", *sg.DesiredSecurityGroup.GroupName))
+		case sg.needsModification():
+			changes = append(changes, fmt.Sprintf("modify inbound rules of security group %s", *sg.CurrentSecurityGroup.GroupId))
+		}
+	}
+
+	for _, r := range lb.RecordSets() {
+		switch {
+		case r.DesiredResourceRecordSet == nil && r.CurrentResourceRecordSet != nil:
+			changes = append(changes, fmt.Sprintf("delete %s record %s", *r.CurrentResourceRecordSet.Type, *r.CurrentResourceRecordSet.Name))
+		case r.DesiredResourceRecordSet != nil && r.CurrentResourceRecordSet == nil:
+			changes = append(changes, fmt.Sprintf("create %s record %s", *r.DesiredResourceRecordSet.Type, *r.DesiredResourceRecordSet.Name))
+		}
+	}
+
+	for _, tg := range lb.TargetGroups {
+		changes = append(changes, tg.pendingChanges()...)
+	}
+	for _, l := range lb.Listeners {
+		changes = append(changes, l.pendingChanges(lb)...)
+	}
+
+	if s := lb.Standby; s != nil {
+		switch {
+		case !s.synced:
+			changes = append(changes, fmt.Sprintf("look up standby ALB in %s", s.Region))
+		case s.DesiredLoadBalancer == nil && s.CurrentLoadBalancer != nil:
+			changes = append(changes, fmt.Sprintf("delete standby ALB in %s", s.Region))
+		case s.DesiredLoadBalancer != nil && s.CurrentLoadBalancer == nil:
+			changes = append(changes, fmt.Sprintf("create standby ALB in %s", s.Region))
+		}
+	}
+	return changes
+}
+
+// pendingChanges describes the changes the next reconcile would make to the target group and its
+// targets.
+func (tg *TargetGroup) pendingChanges() []string {
+	var changes []string
+	switch {
+	case tg.DesiredTargetGroup == nil:
+		if tg.CurrentTargetGroup != nil {
+			changes = append(changes, fmt.Sprintf("delete target group %s", *tg.ID))
+		}
+		return changes
+	case tg.CurrentTargetGroup == nil:
+		return append(changes, fmt.Sprintf("create target group %s with %d targets", *tg.ID, len(tg.DesiredTargets)))
+	}
+
+	if tg.healthCheckModified() {
+		changes = append(changes, fmt.Sprintf("modify health check of target group %s", *tg.ID))
+	}
+	if tg.attributesModified() {
+		changes = append(changes, fmt.Sprintf("modify attributes of target group %s", *tg.ID))
+	}
+	if *tg.CurrentTags.Hash() != *tg.DesiredTags.Hash() {
+		changes = append(changes, fmt.Sprintf("modify tags of target group %s", *tg.ID))
+	}
+	if additions := tg.DesiredTargets.Difference(tg.CurrentTargets); len(additions) > 0 {
+		changes = append(changes, fmt.Sprintf("register %d targets in target group %s", len(additions), *tg.ID))
+	}
+	if removals := tg.CurrentTargets.Difference(tg.DesiredTargets); len(removals) > 0 {
+		changes = append(changes, fmt.Sprintf("deregister %d targets from target group %s", len(removals), *tg.ID))
+	}
+	return changes
+}
+
+// pendingChanges describes the changes the next reconcile would make to the listener and its rules.
+func (l *Listener) pendingChanges(lb *LoadBalancer) []string {
+	var changes []string
+	switch {
+	case l.DesiredListener == nil:
+		if l.CurrentListener != nil {
+			changes = append(changes, fmt.Sprintf("delete listener %d of ALB %s", *l.CurrentListener.Port, *lb.ID))
+		}
+		return changes
+	case l.CurrentListener == nil:
+		changes = append(changes, fmt.Sprintf("create listener %d of ALB %s", *l.DesiredListener.Port, *lb.ID))
+	case l.needsModification(l.DesiredListener):
+		changes = append(changes, fmt.Sprintf("modify listener %d of ALB %s", *l.DesiredListener.Port, *lb.ID))
+	case l.sniCertificatesModified():
+		changes = append(changes, fmt.Sprintf("modify SNI certificates of listener %d of ALB %s", *l.DesiredListener.Port, *lb.ID))
+	}
+
+	port := *l.DesiredListener.Port
+	replaced := l.Rules.replacements()
+	adopted := make(map[*Rule]bool)
+	for _, old := range replaced {
+		adopted[old] = true
+	}
+	for _, r := range l.Rules {
+		switch {
+		case adopted[r]:
+		case r.DesiredRule == nil:
+			if r.CurrentRule != nil && !aws.BoolValue(r.CurrentRule.IsDefault) {
+				changes = append(changes, fmt.Sprintf("delete rule %s of listener %d", rulePath(r.CurrentRule), port))
+			}
+		case aws.BoolValue(r.DesiredRule.IsDefault):
+		case replaced[r] != nil:
+			changes = append(changes, fmt.Sprintf("modify rule %s of listener %d into %s", rulePath(replaced[r].CurrentRule), port,
+				rulePath(r.DesiredRule)))
+		case r.CurrentRule == nil:
+			changes = append(changes, fmt.Sprintf("create rule %s of listener %d", rulePath(r.DesiredRule), port))
+		case r.needsModification(lb):
+			changes = append(changes, fmt.Sprintf("modify rule %s of listener %d", rulePath(r.DesiredRule), port))
+		}
+	}
+	return changes
+}
+
+// rulePath returns the path pattern a rule matches, or "default" for the default rule.
+func rulePath(rule *elbv2.Rule) string {
+	for _, condition := range rule.Conditions {
+		if len(condition.Values) > 0 {
+			return aws.StringValue(condition.Values[0])
+		}
+	}
+	return "default"
+}
@@ -0,0 +1,55 @@
+package alb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/controller/util"
+)
+
+func TestTargetGroupPendingChanges(t *testing.T) {
+	targets := func(ids ...string) util.AWSStringSlice {
+		var out util.AWSStringSlice
+		for _, id := range ids {
+			out = append(out, aws.String(id))
+		}
+		return out
+	}
+	healthCheck := func() *elbv2.TargetGroup {
+		return &elbv2.TargetGroup{
+			HealthCheckIntervalSeconds: aws.Int64(15),
+			HealthCheckPath:            aws.String("/"),
+			HealthCheckPort:            aws.String("traffic-port"),
+			HealthCheckProtocol:        aws.String("HTTP"),
+			HealthCheckTimeoutSeconds:  aws.Int64(5),
+			HealthyThresholdCount:      aws.Int64(2),
+			UnhealthyThresholdCount:    aws.Int64(2),
+			Matcher:                    &elbv2.Matcher{HttpCode: aws.String("200")},
+		}
+	}
+
+	tg := &TargetGroup{
+		ID:                 aws.String("tg"),
+		DesiredTargetGroup: healthCheck(),
+		DesiredTargets:     targets("i-1", "i-2"),
+	}
+	expected := []string{"create target group tg with 2 targets"}
+	if changes := tg.pendingChanges(); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("pendingChanges(): expected %v, actual %v", expected, changes)
+	}
+
+	tg.CurrentTargetGroup = healthCheck()
+	tg.CurrentTargets = targets("i-2", "i-3", "i-4")
+	expected = []string{"register 1 targets in target group tg", "deregister 2 targets from target group tg"}
+	if changes := tg.pendingChanges(); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("pendingChanges(): expected %v, actual %v", expected, changes)
+	}
+
+	tg.DesiredTargetGroup = nil
+	expected = []string{"delete target group tg"}
+	if changes := tg.pendingChanges(); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("pendingChanges(): expected %v, actual %v", expected, changes)
+	}
+}
@@ -2,12 +2,14 @@ package alb
 
 import (
 	"fmt"
+	"net"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/aws/aws-sdk-go/service/route53"
 	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/controller/config"
 	"github.com/coreos/alb-ingress-controller/log"
 )
 
@@ -19,10 +21,16 @@ type ResourceRecordSet struct {
 	Resolveable              bool
 	CurrentResourceRecordSet *route53.ResourceRecordSet
 	DesiredResourceRecordSet *route53.ResourceRecordSet
+	CurrentHealthCheck       *route53.HealthCheck
+	DesiredHealthCheck       *route53.HealthCheckConfig
+	// SharedHealthCheckID is the health check of another record this one uses, when it has no
+	// DesiredHealthCheck of its own
+	SharedHealthCheckID *string
 }
 
 // NewResourceRecordSet returns a new route53.ResourceRecordSet based on the LoadBalancer provided.
-func NewResourceRecordSet(hostname *string, ingressID *string) *ResourceRecordSet {
+// An alias A record is used unless the annotations ask for a CNAME, which carries their TTL.
+func NewResourceRecordSet(hostname *string, annotations *config.Annotations, ingressID *string) *ResourceRecordSet {
 	zoneID, err := awsutil.Route53svc.GetZoneID(hostname)
 	resolveable := true
 	if err != nil {
@@ -36,15 +44,50 @@ func NewResourceRecordSet(hostname *string, ingressID *string) *ResourceRecordSe
 	}
 	record := &ResourceRecordSet{
 		DesiredResourceRecordSet: &route53.ResourceRecordSet{
-			AliasTarget: &route53.AliasTarget{
-				EvaluateTargetHealth: aws.Bool(false),
-			},
 			Name: aws.String(name),
-			Type: aws.String("A"),
+			Type: annotations.Route53RecordType,
 		},
 		IngressID:   ingressID,
 		Resolveable: resolveable,
 	}
+	switch *annotations.Route53RecordType {
+	case route53.RRTypeCname:
+		record.DesiredResourceRecordSet.TTL = annotations.Route53TTL
+	default:
+		record.DesiredResourceRecordSet.AliasTarget = &route53.AliasTarget{
+			EvaluateTargetHealth: aws.Bool(false),
+		}
+	}
+	// With a standby ALB, Route 53 answers with the standby's failover record while this ALB is
+	// unhealthy.
+	if annotations.Standby != nil {
+		record.DesiredResourceRecordSet.SetIdentifier = aws.String(primaryRecordIdentifier)
+		record.DesiredResourceRecordSet.Failover = aws.String(route53.ResourceRecordSetFailoverPrimary)
+		record.DesiredResourceRecordSet.AliasTarget.EvaluateTargetHealth = aws.Bool(true)
+	}
+	// With a routing policy, the record shares the hostname with the records of other clusters.
+	// Route 53 skips alias records whose ALB is unhealthy.
+	if policy := annotations.Route53RoutingPolicy; policy != nil {
+		desired := record.DesiredResourceRecordSet
+		desired.SetIdentifier = aws.String(policy.SetIdentifier)
+		switch policy.Type {
+		case config.RoutingPolicyWeighted:
+			desired.Weight = aws.Int64(policy.Weight)
+		case config.RoutingPolicyLatency:
+			desired.Region = awsutil.Session.Config.Region
+		case config.RoutingPolicyFailover:
+			desired.Failover = aws.String(policy.Failover)
+		}
+		if desired.AliasTarget != nil {
+			desired.AliasTarget.EvaluateTargetHealth = aws.Bool(true)
+		}
+	}
+	// Route 53 only fails over from a primary record whose health check fails. Internal ALBs can't be
+	// reached by the health checkers, so they fail over on the health of their targets alone.
+	if aws.StringValue(record.DesiredResourceRecordSet.Failover) == route53.ResourceRecordSetFailoverPrimary &&
+		aws.StringValue(annotations.Scheme) == "internet-facing" {
+		record.DesiredHealthCheck = newHealthCheckConfig(annotations)
+	}
 	if record.Resolveable {
 		record.ZoneID = zoneID.Id
 	}
@@ -52,6 +95,65 @@ func NewResourceRecordSet(hostname *string, ingressID *string) *ResourceRecordSe
 	return record
 }
 
+// NewIPv6ResourceRecordSet returns the AAAA alias record of a dualstack ALB, pointing IPv6 clients
+// at it. It mirrors the A record of NewResourceRecordSet, including its routing policy, but has no
+// health check of its own: it uses the one of the A record, see SharedHealthCheckID, so both fail
+// over together.
+func NewIPv6ResourceRecordSet(hostname *string, annotations *config.Annotations, ingressID *string) *ResourceRecordSet {
+	record := NewResourceRecordSet(hostname, annotations, ingressID)
+	record.DesiredResourceRecordSet.Type = aws.String(route53.RRTypeAaaa)
+	record.DesiredHealthCheck = nil
+	return record
+}
+
+// NewExtraRecordSets returns the records of the ExtraHostnames of lb, keeping the current state of
+// the records lb has already. Records of hostnames lb no longer has are kept without a desired
+// state, so they're deleted.
+func NewExtraRecordSets(lb *LoadBalancer, annotations *config.Annotations) []*ResourceRecordSet {
+	current := make(map[string]*ResourceRecordSet)
+	for _, r := range lb.ExtraRecordSets {
+		current[r.hostname(lb)] = r
+	}
+
+	var records []*ResourceRecordSet
+	for _, hostname := range lb.ExtraHostnames {
+		record := NewResourceRecordSet(aws.String(hostname), annotations, lb.IngressID)
+		if r, ok := current[hostname]; ok {
+			record.CurrentResourceRecordSet = r.CurrentResourceRecordSet
+			record.CurrentHealthCheck = r.CurrentHealthCheck
+			delete(current, hostname)
+		}
+		records = append(records, record)
+	}
+	for _, r := range lb.ExtraRecordSets {
+		if current[r.hostname(lb)] == r && r.CurrentResourceRecordSet != nil {
+			r.DesiredResourceRecordSet, r.DesiredHealthCheck = nil, nil
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// newHealthCheckConfig returns the health check of a primary failover record, against the first
+// listener of the ALB. With a path it requests the path, otherwise it only connects to the listener.
+func newHealthCheckConfig(annotations *config.Annotations) *route53.HealthCheckConfig {
+	port := annotations.Ports[0]
+	hc := &route53.HealthCheckConfig{
+		Port:             aws.Int64(port.Port),
+		Type:             aws.String(route53.HealthCheckTypeTcp),
+		RequestInterval:  aws.Int64(30),
+		FailureThreshold: aws.Int64(3),
+	}
+	if annotations.Route53HealthCheckPath != nil {
+		hc.ResourcePath = annotations.Route53HealthCheckPath
+		hc.Type = aws.String(route53.HealthCheckTypeHttp)
+		if port.HTTPS {
+			hc.Type = aws.String(route53.HealthCheckTypeHttps)
+		}
+	}
+	return hc
+}
+
 // Reconcile compares the current and desired state of this ResourceRecordSet instance. Comparison
 // results in no action, the creation, the deletion, or the modification of Route 53 resource
 // record set to satisfy the ingress's current state.
@@ -59,66 +161,144 @@ func (r *ResourceRecordSet) Reconcile(lb *LoadBalancer) error {
 	switch {
 	case !r.Resolveable:
 		return fmt.Errorf("Route53 Resource record set flagged as unresolveable. Record: %s",
-			*lb.Hostname)
+			r.hostname(lb))
 	case r.DesiredResourceRecordSet == nil: // rrs should be deleted
-		if r.CurrentResourceRecordSet == nil {
-			break
-		}
-		log.Infof("Start Route53 resource record set deletion.", *r.IngressID)
-		if err := r.delete(lb); err != nil {
-			return err
+		if r.CurrentResourceRecordSet != nil {
+			hostname := r.hostname(lb)
+			log.Infof("Start Route53 resource record set deletion.", *r.IngressID)
+			if err := r.delete(lb); err != nil {
+				return err
+			}
+			log.Infof("Completed deletion of Route 53 resource record set. DNS: %s",
+				*lb.IngressID, hostname)
 		}
-		log.Infof("Completed deletion of Route 53 resource record set. DNS: %s",
-			*lb.IngressID, *lb.Hostname)
+		// The health check can only be deleted once no record uses it.
+		return r.deleteHealthCheck(r.CurrentHealthCheck)
 
 	case r.CurrentResourceRecordSet == nil: // rrs doesn't exist and should be created
 		log.Infof("Start Route53 resource record set creation.", *r.IngressID)
 		r.PopulateFromLoadBalancer(lb.CurrentLoadBalancer)
-		if err := r.create(lb); err != nil {
+		stale, err := r.reconcileHealthCheck()
+		if err != nil {
 			return err
 		}
-		log.Infof("Completed Route 53 resource record set creation. DNS: %s | Type: %s | Target: %s.",
-			*lb.IngressID, *lb.Hostname, *r.CurrentResourceRecordSet.Type,
-			log.Prettify(*r.CurrentResourceRecordSet.AliasTarget))
+		return r.create(lb, stale)
 
 	default: // check for diff between current and desired rrs; mod if needed
 		r.PopulateFromLoadBalancer(lb.CurrentLoadBalancer)
+		stale, err := r.reconcileHealthCheck()
+		if err != nil {
+			return err
+		}
 		// Only perform modifictation if needed.
 		if r.needsModification() {
 			log.Infof("Start Route 53 resource record set modification.", *r.IngressID)
-			if err := r.modify(lb); err != nil {
-				return err
-			}
-			log.Infof("Completed Route 53 resource record set modification. DNS: %s | Type: %s | AliasTarget: %s",
-				*r.IngressID, *r.CurrentResourceRecordSet.Name, *r.CurrentResourceRecordSet.Type, log.Prettify(*r.CurrentResourceRecordSet.AliasTarget))
-		} else {
-			log.Debugf("No modification of Route 53 resource record set required.", *r.IngressID)
+			return r.modify(lb, stale, "modification")
 		}
+		log.Debugf("No modification of Route 53 resource record set required.", *r.IngressID)
+		return r.deleteHealthCheck(stale)
 	}
+}
 
-	return nil
+// hostname returns the hostname the record is named after, which is lb.Hostname unless the record
+// is one of lb.ExtraRecordSets.
+func (r *ResourceRecordSet) hostname(lb *LoadBalancer) string {
+	record := r.DesiredResourceRecordSet
+	if record == nil {
+		record = r.CurrentResourceRecordSet
+	}
+	if record == nil {
+		return *lb.Hostname
+	}
+	return strings.TrimSuffix(*record.Name, ".")
 }
 
-func (r *ResourceRecordSet) create(lb *LoadBalancer) error {
-	// If a record pre-exists, delete it.
-	existing := awsutil.LookupExistingRecord(lb.Hostname)
-	if existing != nil {
-		if *existing.Type != route53.RRTypeA {
-			r.CurrentResourceRecordSet = existing
-			r.delete(lb)
+// reconcileHealthCheck creates or updates the health check of DesiredResourceRecordSet, and points
+// the record at it, or at the SharedHealthCheckID when it has none. A health check whose type
+// changed is replaced. The health check the record no longer uses is returned, to be deleted once
+// the record was changed.
+func (r *ResourceRecordSet) reconcileHealthCheck() (stale *route53.HealthCheck, err error) {
+	current, desired := r.CurrentHealthCheck, r.DesiredHealthCheck
+	switch {
+	case desired == nil:
+		r.DesiredResourceRecordSet.HealthCheckId = r.SharedHealthCheckID
+		r.CurrentHealthCheck = nil
+		if current != nil && aws.StringValue(current.Id) == aws.StringValue(r.SharedHealthCheckID) {
+			return nil, nil
+		}
+		return current, nil
+	case current == nil || *current.HealthCheckConfig.Type != *desired.Type:
+		hc, err := awsutil.Route53svc.CreateHealthCheck(desired, strings.TrimSuffix(*r.DesiredResourceRecordSet.Name, "."))
+		if hc == nil {
+			log.Errorf("Failed Route 53 health check creation. Error: %s", *r.IngressID, awsutil.DescribeError(err))
+			return nil, err
+		}
+		if err != nil {
+			log.Warnf("Failed to tag Route 53 health check %s. Error: %s", *r.IngressID, *hc.Id, err.Error())
 		}
+		log.Infof("Created Route 53 health check %s of %s:%d.", *r.IngressID, *hc.Id,
+			*desired.FullyQualifiedDomainName, *desired.Port)
+		r.CurrentHealthCheck, stale = hc, current
+	case aws.StringValue(current.HealthCheckConfig.FullyQualifiedDomainName) != aws.StringValue(desired.FullyQualifiedDomainName) ||
+		aws.Int64Value(current.HealthCheckConfig.Port) != aws.Int64Value(desired.Port) ||
+		aws.StringValue(current.HealthCheckConfig.ResourcePath) != aws.StringValue(desired.ResourcePath):
+		hc, err := awsutil.Route53svc.UpdateHealthCheck(current.Id, desired)
+		if err != nil {
+			log.Errorf("Failed Route 53 health check %s modification. Error: %s", *r.IngressID, *current.Id, awsutil.DescribeError(err))
+			return nil, err
+		}
+		log.Infof("Modified Route 53 health check %s.", *r.IngressID, *hc.Id)
+		r.CurrentHealthCheck = hc
 	}
+	r.DesiredResourceRecordSet.HealthCheckId = r.CurrentHealthCheck.Id
+	return stale, nil
+}
 
-	err := r.modify(lb)
-	if err != nil {
-		log.Infof("Failed Route 53 resource record set creation. DNS: %s | Type: %s | Target: %s | Error: %s.",
-			*lb.IngressID, *lb.Hostname, *r.CurrentResourceRecordSet.Type, log.Prettify(*r.CurrentResourceRecordSet.AliasTarget), err.Error())
+// deleteHealthCheck deletes hc, unless it's nil.
+func (r *ResourceRecordSet) deleteHealthCheck(hc *route53.HealthCheck) error {
+	if hc == nil {
+		return nil
+	}
+	if err := awsutil.Route53svc.DeleteHealthCheck(hc.Id); err != nil {
 		return err
 	}
-
+	log.Infof("Deleted Route 53 health check %s.", *r.IngressID, *hc.Id)
+	if r.CurrentHealthCheck == hc {
+		r.CurrentHealthCheck = nil
+	}
 	return nil
 }
 
+// restoreHealthCheck undoes reconcileHealthCheck when the record couldn't be changed, deleting the
+// health check replacing stale, so the replacement is retried on the next reconcile.
+func (r *ResourceRecordSet) restoreHealthCheck(stale *route53.HealthCheck) {
+	if stale == nil {
+		return
+	}
+	if hc := r.CurrentHealthCheck; hc != nil {
+		if err := r.deleteHealthCheck(hc); err != nil {
+			log.Errorf("Failed deletion of Route 53 health check %s. Error: %s", *r.IngressID, *hc.Id, awsutil.DescribeError(err))
+		}
+	}
+	r.CurrentHealthCheck = stale
+}
+
+func (r *ResourceRecordSet) create(lb *LoadBalancer, stale *route53.HealthCheck) error {
+	// If a record of another type pre-exists, delete it. Route 53 doesn't allow a CNAME to coexist
+	// with other records of the same name, while A and AAAA records can. Records with a set
+	// identifier may belong to another cluster sharing the name, so they're left alone.
+	existing := awsutil.LookupExistingRecord(aws.String(r.hostname(lb)))
+	if existing != nil && existing.SetIdentifier == nil {
+		if *existing.Type != *r.DesiredResourceRecordSet.Type &&
+			(*existing.Type == route53.RRTypeCname || *r.DesiredResourceRecordSet.Type == route53.RRTypeCname) {
+			r.CurrentResourceRecordSet = existing
+			r.delete(lb)
+		}
+	}
+
+	return r.modify(lb, stale, "creation")
+}
+
 func (r *ResourceRecordSet) delete(lb *LoadBalancer) error {
 	// Attempt record deletion
 	in := route53.ChangeResourceRecordSetsInput{
@@ -135,7 +315,7 @@ func (r *ResourceRecordSet) delete(lb *LoadBalancer) error {
 
 	if err := awsutil.Route53svc.Delete(in); err != nil {
 		log.Errorf("Failed deletion of route53 resource record set. DNS: %s | Target: %s | Error: %s",
-			*r.IngressID, *r.CurrentResourceRecordSet.Name, log.Prettify(*r.CurrentResourceRecordSet.AliasTarget), err.Error())
+			*r.IngressID, *r.CurrentResourceRecordSet.Name, recordTarget(r.CurrentResourceRecordSet), err.Error())
 		return err
 	}
 
@@ -143,40 +323,60 @@ func (r *ResourceRecordSet) delete(lb *LoadBalancer) error {
 	return nil
 }
 
-func (r *ResourceRecordSet) modify(lb *LoadBalancer) error {
-	// Use all values from DesiredResourceRecordSet to run upsert against existing RecordSet in AWS.
-	in := route53.ChangeResourceRecordSetsInput{
-		ChangeBatch: &route53.ChangeBatch{
-			Changes: []*route53.Change{
-				{
-					Action: aws.String(route53.ChangeActionUpsert),
-					ResourceRecordSet: &route53.ResourceRecordSet{
-						Name:        r.DesiredResourceRecordSet.Name,
-						Type:        r.DesiredResourceRecordSet.Type,
-						AliasTarget: r.DesiredResourceRecordSet.AliasTarget,
-					},
-				},
-			},
-			Comment: aws.String("Managed by Kubernetes"),
-		},
-		HostedZoneId: r.ZoneID, // Required
+// modify writes DesiredResourceRecordSet with an upsert. While a Route 53 batch is open, the upsert
+// is queued with the ones of other ingresses, and CurrentResourceRecordSet, the health check stale
+// and lb.LastError are updated once it's made, holding lb.IngressLock, see
+// awsutil.FlushRoute53Batch. change names the
+// change in the logs.
+func (r *ResourceRecordSet) modify(lb *LoadBalancer, stale *route53.HealthCheck, change string) error {
+	// Route 53 rejects a CNAME sharing its name with other records, so a record changing type is
+	// deleted before its replacement is written. Likewise, a record changing set identifier, e.g.
+	// from a simple record to one with a routing policy, would otherwise be left behind.
+	if r.CurrentResourceRecordSet != nil && (*r.CurrentResourceRecordSet.Type != *r.DesiredResourceRecordSet.Type ||
+		aws.StringValue(r.CurrentResourceRecordSet.SetIdentifier) != aws.StringValue(r.DesiredResourceRecordSet.SetIdentifier)) {
+		if err := r.delete(lb); err != nil {
+			r.restoreHealthCheck(stale)
+			return err
+		}
 	}
 
-	if err := awsutil.Route53svc.Modify(in); err != nil {
-		log.Errorf("Failed Route 53 resource record set modification. UPSERT to AWS API failed. Error: %s",
-			*r.IngressID, err.Error())
-		return err
+	// Use all values from DesiredResourceRecordSet to run upsert against existing RecordSet in AWS.
+	desired := r.DesiredResourceRecordSet
+	record := &route53.ResourceRecordSet{
+		Name:            desired.Name,
+		Type:            desired.Type,
+		AliasTarget:     desired.AliasTarget,
+		TTL:             desired.TTL,
+		ResourceRecords: desired.ResourceRecords,
+		SetIdentifier:   desired.SetIdentifier,
+		Failover:        desired.Failover,
+		Weight:          desired.Weight,
+		Region:          desired.Region,
+		HealthCheckId:   desired.HealthCheckId,
 	}
+	awsutil.Route53svc.Upsert(r.ZoneID, record, lb.IngressLock, func(err error) {
+		if err != nil {
+			log.Errorf("Failed Route 53 resource record set %s. UPSERT to AWS API failed. DNS: %s | Type: %s | Target: %s | Error: %s",
+				*r.IngressID, change, *desired.Name, *desired.Type, recordTarget(desired), err.Error())
+			r.restoreHealthCheck(stale)
+			lb.LastError = err
+			return
+		}
 
-	// When delete is required, delete the CurrentResourceRecordSet.
-	deleteRequired := r.isDeleteRequired()
-	if deleteRequired {
-		r.delete(lb)
-	}
+		// When delete is required, delete the CurrentResourceRecordSet.
+		if r.isDeleteRequired() {
+			r.delete(lb)
+		}
 
-	// Upon success, ensure all possible updated attributes are updated in local Resource Record Set reference
-	r.CurrentResourceRecordSet = r.DesiredResourceRecordSet
+		// Upon success, ensure all possible updated attributes are updated in local Resource Record Set reference
+		r.CurrentResourceRecordSet = desired
+		log.Infof("Completed Route 53 resource record set %s. DNS: %s | Type: %s | Target: %s",
+			*r.IngressID, change, *desired.Name, *desired.Type, recordTarget(desired))
 
+		if err := r.deleteHealthCheck(stale); err != nil {
+			log.Errorf("Failed deletion of Route 53 health check %s. Error: %s", *r.IngressID, *stale.Id, awsutil.DescribeError(err))
+		}
+	})
 	return nil
 }
 
@@ -195,8 +395,8 @@ func (r *ResourceRecordSet) isDeleteRequired() bool {
 }
 
 // Determine whether there is a difference between CurrentResourceRecordSet and DesiredResourceRecordSet that requires
-// a modification. Checks for whether hostname, record type, load balancer alias target (host name), and/or load
-// balancer alias target's hosted zone are different.
+// a modification. Checks for whether hostname, record type, load balancer alias target (host name), load
+// balancer alias target's hosted zone and/or, for CNAME records, value and TTL are different.
 func (r *ResourceRecordSet) needsModification() bool {
 	switch {
 	// No resource record set currently exists; modification required.
@@ -205,12 +405,31 @@ func (r *ResourceRecordSet) needsModification() bool {
 		// not sure if we need both conditions here.
 		// Hostname has changed; modification required.
 	case *r.CurrentResourceRecordSet.Name != *r.DesiredResourceRecordSet.Name:
-		return true
-		// Load balancer's hostname has changed; modification required.
-	case *r.CurrentResourceRecordSet.AliasTarget.DNSName != *r.DesiredResourceRecordSet.AliasTarget.DNSName:
 		return true
 		// DNS record's resource type has changed; modification required.
 	case *r.CurrentResourceRecordSet.Type != *r.DesiredResourceRecordSet.Type:
+		return true
+		// Record has switched between a simple and a failover record; modification required.
+	case aws.StringValue(r.CurrentResourceRecordSet.SetIdentifier) != aws.StringValue(r.DesiredResourceRecordSet.SetIdentifier):
+		return true
+		// Record's routing policy has changed; modification required.
+	case aws.Int64Value(r.CurrentResourceRecordSet.Weight) != aws.Int64Value(r.DesiredResourceRecordSet.Weight) ||
+		(r.CurrentResourceRecordSet.Weight == nil) != (r.DesiredResourceRecordSet.Weight == nil) ||
+		aws.StringValue(r.CurrentResourceRecordSet.Region) != aws.StringValue(r.DesiredResourceRecordSet.Region) ||
+		aws.StringValue(r.CurrentResourceRecordSet.Failover) != aws.StringValue(r.DesiredResourceRecordSet.Failover):
+		return true
+		// Record's health check has changed; modification required.
+	case aws.StringValue(r.CurrentResourceRecordSet.HealthCheckId) != aws.StringValue(r.DesiredResourceRecordSet.HealthCheckId):
+		return true
+		// Record has switched between alias and non-alias; modification required.
+	case (r.CurrentResourceRecordSet.AliasTarget == nil) != (r.DesiredResourceRecordSet.AliasTarget == nil):
+		return true
+		// CNAME value or TTL has changed; modification required.
+	case r.DesiredResourceRecordSet.AliasTarget == nil:
+		return recordTarget(r.CurrentResourceRecordSet) != recordTarget(r.DesiredResourceRecordSet) ||
+			aws.Int64Value(r.CurrentResourceRecordSet.TTL) != aws.Int64Value(r.DesiredResourceRecordSet.TTL)
+		// Load balancer's hostname has changed; modification required.
+	case *r.CurrentResourceRecordSet.AliasTarget.DNSName != *r.DesiredResourceRecordSet.AliasTarget.DNSName:
 		return true
 		// Load balancer's dns hosted zone has changed; modification required.
 	case *r.CurrentResourceRecordSet.AliasTarget.HostedZoneId != *r.DesiredResourceRecordSet.AliasTarget.HostedZoneId:
@@ -221,6 +440,74 @@ func (r *ResourceRecordSet) needsModification() bool {
 
 // PopulateFromLoadBalancer configures the DesiredResourceRecordSet with values from a n elbv2.LoadBalancer
 func (r *ResourceRecordSet) PopulateFromLoadBalancer(lb *elbv2.LoadBalancer) {
+	if r.DesiredHealthCheck != nil {
+		r.DesiredHealthCheck.FullyQualifiedDomainName = lb.DNSName
+	}
+	if r.DesiredResourceRecordSet.AliasTarget == nil {
+		r.DesiredResourceRecordSet.ResourceRecords = []*route53.ResourceRecord{
+			{Value: aws.String(*lb.DNSName + ".")},
+		}
+		return
+	}
 	r.DesiredResourceRecordSet.AliasTarget.DNSName = aws.String(*lb.DNSName + ".")
 	r.DesiredResourceRecordSet.AliasTarget.HostedZoneId = lb.CanonicalHostedZoneId
 }
+
+// CheckState reports whether the CurrentResourceRecordSet is present in Route 53, pointing at the
+// same target, and whether its name resolves through DNS.
+func (r *ResourceRecordSet) CheckState() (present bool, resolving bool) {
+	if r.CurrentResourceRecordSet == nil || r.ZoneID == nil {
+		return false, false
+	}
+
+	rrs, err := awsutil.Route53svc.DescribeResourceRecordSetsByName(r.ZoneID, r.CurrentResourceRecordSet.Name)
+	for _, record := range rrs {
+		if *record.Type == *r.CurrentResourceRecordSet.Type &&
+			aws.StringValue(record.SetIdentifier) == aws.StringValue(r.CurrentResourceRecordSet.SetIdentifier) {
+			present = err == nil && recordTarget(record) == recordTarget(r.CurrentResourceRecordSet)
+		}
+	}
+
+	_, err = net.LookupHost(strings.TrimSuffix(*r.CurrentResourceRecordSet.Name, "."))
+	resolving = err == nil
+	return present, resolving
+}
+
+// FindResourceRecordSet returns the record among records, all named after the hostname of an ALB,
+// that points at the ALB with the DNS name dnsName. Only AAAA records are considered when ipv6 is
+// set, and only A and CNAME records otherwise. When there's none, the simple record of the
+// hostname is returned, if there is one, as records with a set identifier may belong to other
+// clusters sharing the hostname.
+func FindResourceRecordSet(records []*route53.ResourceRecordSet, dnsName *string, ipv6 bool) *route53.ResourceRecordSet {
+	var simple *route53.ResourceRecordSet
+	for _, record := range records {
+		if (*record.Type == route53.RRTypeAaaa) != ipv6 {
+			continue
+		}
+		if RecordPointsAt(record, dnsName) {
+			return record
+		}
+		if record.SetIdentifier == nil {
+			simple = record
+		}
+	}
+	return simple
+}
+
+// RecordPointsAt returns true when the record points at the ALB with the DNS name dnsName.
+func RecordPointsAt(record *route53.ResourceRecordSet, dnsName *string) bool {
+	return recordTarget(record) == aws.StringValue(dnsName)+"."
+}
+
+// recordTarget returns what the record points at, for logging and comparison.
+func recordTarget(rrs *route53.ResourceRecordSet) string {
+	switch {
+	case rrs == nil:
+		return ""
+	case rrs.AliasTarget != nil:
+		return aws.StringValue(rrs.AliasTarget.DNSName)
+	case len(rrs.ResourceRecords) > 0:
+		return aws.StringValue(rrs.ResourceRecords[0].Value)
+	}
+	return ""
+}
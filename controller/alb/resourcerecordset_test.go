@@ -0,0 +1,42 @@
+package alb
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+func TestSharedHealthCheck(t *testing.T) {
+	shared := &route53.HealthCheck{Id: aws.String("hc-a")}
+	var tests = []struct {
+		current *route53.HealthCheck
+		stale   *route53.HealthCheck
+	}{
+		{nil, nil},
+		// An AAAA record carrying the A record's health check mustn't delete it.
+		{shared, nil},
+		// A health check of its own, from before the health check was shared, is deleted.
+		{&route53.HealthCheck{Id: aws.String("hc-aaaa")}, &route53.HealthCheck{Id: aws.String("hc-aaaa")}},
+	}
+
+	for _, tt := range tests {
+		r := &ResourceRecordSet{
+			IngressID:                aws.String("default-app"),
+			DesiredResourceRecordSet: &route53.ResourceRecordSet{Type: aws.String(route53.RRTypeAaaa)},
+			CurrentHealthCheck:       tt.current,
+			SharedHealthCheckID:      shared.Id,
+		}
+		stale, err := r.reconcileHealthCheck()
+		if err != nil {
+			t.Fatalf("reconcileHealthCheck() returned error %v", err)
+		}
+		if aws.StringValue(r.DesiredResourceRecordSet.HealthCheckId) != "hc-a" {
+			t.Errorf("reconcileHealthCheck(): expected the record to use hc-a, actual %v",
+				aws.StringValue(r.DesiredResourceRecordSet.HealthCheckId))
+		}
+		if (stale == nil) != (tt.stale == nil) || (stale != nil && *stale.Id != *tt.stale.Id) {
+			t.Errorf("reconcileHealthCheck() with health check %v: expected stale %v, actual %v", tt.current, tt.stale, stale)
+		}
+	}
+}
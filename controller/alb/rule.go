@@ -4,7 +4,9 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/controller/config"
 	"github.com/coreos/alb-ingress-controller/log"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	extensions "k8s.io/client-go/pkg/apis/extensions/v1beta1"
 )
 
@@ -12,13 +14,23 @@ import (
 type Rule struct {
 	IngressID   *string
 	SvcName     string
+	SvcPort     intstr.IntOrString
 	CurrentRule *elbv2.Rule
 	DesiredRule *elbv2.Rule
-	deleted     bool
+	// The vendored aws-sdk-go can't describe redirect actions, so the redirect last applied is tracked
+	// here. It's nil for rules assembled from AWS, which get their redirect reapplied once.
+	CurrentRedirect *config.RedirectConfig
+	DesiredRedirect *config.RedirectConfig
+	// Likewise for weighted forward actions, which the rule has while traffic shifts between target
+	// groups of its service.
+	CurrentWeights []awsutil.TargetGroupWeight
+	deleted        bool
 }
 
-// NewRule returns an alb.Rule based on the provided parameters.
-func NewRule(path extensions.HTTPIngressPath, ingressID *string) *Rule {
+// NewRule returns an alb.Rule based on the provided parameters. When redirect is set, the rule
+// redirects requests instead of forwarding them to the path's service. With hosts, the rule only
+// matches requests for one of them.
+func NewRule(path extensions.HTTPIngressPath, ingressID *string, redirect *config.RedirectConfig, hosts []string) *Rule {
 	r := &elbv2.Rule{
 		Actions: []*elbv2.Action{
 			{
@@ -27,6 +39,9 @@ func NewRule(path extensions.HTTPIngressPath, ingressID *string) *Rule {
 			},
 		},
 	}
+	if redirect != nil {
+		r.Actions[0].Type = aws.String(config.ActionTypeRedirect)
+	}
 
 	if path.Path == "/" {
 		r.IsDefault = aws.Bool(true)
@@ -39,16 +54,39 @@ func NewRule(path extensions.HTTPIngressPath, ingressID *string) *Rule {
 				Values: []*string{&path.Path},
 			},
 		}
+		if len(hosts) > 0 {
+			r.Conditions = append(r.Conditions, &elbv2.RuleCondition{
+				Field:  aws.String("host-header"),
+				Values: aws.StringSlice(hosts),
+			})
+		}
 	}
 
 	rule := &Rule{
-		IngressID:   ingressID,
-		SvcName:     path.Backend.ServiceName,
-		DesiredRule: r,
+		IngressID:       ingressID,
+		SvcName:         path.Backend.ServiceName,
+		SvcPort:         path.Backend.ServicePort,
+		DesiredRule:     r,
+		DesiredRedirect: redirect,
 	}
 	return rule
 }
 
+// NewRules returns the rules of an ingress path, like NewRule. ALB rules match at most
+// maxRuleConditionValues values, so hosts that don't fit next to the path pattern are split across
+// several rules of the path.
+func NewRules(path extensions.HTTPIngressPath, ingressID *string, redirect *config.RedirectConfig, hosts []string) Rules {
+	n := maxRuleConditionValues - 1
+	if path.Path == "/" || len(hosts) <= n {
+		return Rules{NewRule(path, ingressID, redirect, hosts)}
+	}
+	var rules Rules
+	for ; len(hosts) > n; hosts = hosts[n:] {
+		rules = append(rules, NewRule(path, ingressID, redirect, hosts[:n]))
+	}
+	return append(rules, NewRule(path, ingressID, redirect, hosts))
+}
+
 // Reconcile compares the current and desired state of this Rule instance. Comparison
 // results in no action, the creation, the deletion, or the modification of an AWS Rule to
 // satisfy the ingress's current state.
@@ -81,12 +119,13 @@ func (r *Rule) Reconcile(lb *LoadBalancer, l *Listener) error {
 		log.Infof("Completed Rule creation. Rule: %s | Condition: %s", *r.IngressID,
 			log.Prettify(r.CurrentRule.Conditions))
 
-	case r.needsModification(): // diff between current and desired, modify rule
+	case r.needsModification(lb): // diff between current and desired, modify rule
 		log.Infof("Start Rule modification.", *r.IngressID)
 		if err := r.modify(lb); err != nil {
 			return err
 		}
-		log.Infof("Completed Rule modification. [UNIMPLEMENTED]", *r.IngressID)
+		log.Infof("Completed Rule modification. Rule: %s | Action: %s", *r.IngressID,
+			log.Prettify(r.CurrentRule.Conditions), *r.CurrentRule.Actions[0].Type)
 
 	default:
 		log.Debugf("No listener modification required.", *r.IngressID)
@@ -103,23 +142,22 @@ func (r *Rule) create(lb *LoadBalancer, l *Listener) error {
 		Priority:    aws.Int64(lb.LastRulePriority),
 	}
 
-	in.Actions[0].TargetGroupArn = lb.TargetGroups[0].CurrentTargetGroup.TargetGroupArn
-	tgIndex := lb.TargetGroups.LookupBySvc(r.SvcName)
-
-	if tgIndex < 0 {
-		log.Errorf("Failed to locate TargetGroup related to this service. Defaulting to first Target Group. SVC: %s", *r.IngressID, r.SvcName)
+	var o *elbv2.Rule
+	var err error
+	if r.DesiredRedirect != nil {
+		o, err = awsutil.ALBsvc.AddRedirectRule(in, r.DesiredRedirect.AsParams())
 	} else {
-		ctg := lb.TargetGroups[tgIndex].CurrentTargetGroup
-		in.Actions[0].TargetGroupArn = ctg.TargetGroupArn
+		in.Actions[0].TargetGroupArn = r.targetGroupArn(lb)
+		o, err = awsutil.ALBsvc.AddRule(in)
 	}
-
-	o, err := awsutil.ALBsvc.AddRule(in)
 	if err != nil {
 		log.Errorf("Failed Rule creation. Rule: %s | Error: %s", *r.IngressID,
 			log.Prettify(r.DesiredRule), err.Error())
 		return err
 	}
 	r.CurrentRule = o
+	r.CurrentRedirect = r.DesiredRedirect
+	r.CurrentWeights = nil
 
 	// Increase rule priority by 1 for each creation of a rule on this listener.
 	// Note: All rules must have a unique priority.
@@ -127,11 +165,68 @@ func (r *Rule) create(lb *LoadBalancer, l *Listener) error {
 	return nil
 }
 
+// modify updates the conditions and action of the rule in place, keeping its priority, e.g. to
+// switch it between forwarding and redirecting, to forward to another service or to shift traffic
+// between the target groups of its service.
 func (r *Rule) modify(lb *LoadBalancer) error {
-	// TODO: Unimplemented
+	in := elbv2.ModifyRuleInput{
+		RuleArn:    r.CurrentRule.RuleArn,
+		Conditions: r.DesiredRule.Conditions,
+	}
+
+	var o *elbv2.Rule
+	var err error
+	weights := r.shiftWeights(lb)
+	switch {
+	case r.DesiredRedirect != nil:
+		weights = nil
+		o, err = awsutil.ALBsvc.ModifyRedirectRule(in, r.DesiredRedirect.AsParams())
+	case weights != nil:
+		o, err = awsutil.ALBsvc.ModifyWeightedRule(in, weights)
+	default:
+		in.Actions = []*elbv2.Action{{Type: aws.String("forward"), TargetGroupArn: r.targetGroupArn(lb)}}
+		o, err = awsutil.ALBsvc.ModifyRule(in)
+	}
+	if err != nil {
+		log.Errorf("Failed Rule modification. Rule: %s | Error: %s", *r.IngressID,
+			log.Prettify(r.DesiredRule), err.Error())
+		return err
+	}
+	r.CurrentRule = o
+	r.CurrentRedirect = r.DesiredRedirect
+	r.CurrentWeights = weights
 	return nil
 }
 
+// forwardsElsewhere reports whether the current rule forwards to another target group than the one
+// of the rule's service, e.g. because the path's backend changed. The target groups of the
+// LoadBalancer are reconciled before its rules, so the service's target group exists.
+func (r *Rule) forwardsElsewhere(lb *LoadBalancer) bool {
+	i := lb.TargetGroups.LookupBySvc(r.SvcName, r.SvcPort)
+	if i < 0 || lb.TargetGroups[i].CurrentTargetGroup == nil || len(r.CurrentRule.Actions) == 0 {
+		return false
+	}
+	current := aws.StringValue(r.CurrentRule.Actions[0].TargetGroupArn)
+	return current != aws.StringValue(lb.TargetGroups[i].CurrentTargetGroup.TargetGroupArn)
+}
+
+// shiftWeights returns the weights the rule forwards to the target groups of its service with while
+// traffic shifts between them, nil otherwise.
+func (r *Rule) shiftWeights(lb *LoadBalancer) []awsutil.TargetGroupWeight {
+	return lb.TargetGroups.shiftWeights(r.SvcName, r.SvcPort)
+}
+
+// targetGroupArn returns the ARN of the target group of the rule's service. When it can't be found,
+// the first target group of the LoadBalancer is used.
+func (r *Rule) targetGroupArn(lb *LoadBalancer) *string {
+	tgIndex := lb.TargetGroups.LookupBySvc(r.SvcName, r.SvcPort)
+	if tgIndex < 0 {
+		log.Errorf("Failed to locate TargetGroup related to this service. Defaulting to first Target Group. SVC: %s | Port: %s", *r.IngressID, r.SvcName, r.SvcPort.String())
+		return lb.TargetGroups[0].CurrentTargetGroup.TargetGroupArn
+	}
+	return lb.TargetGroups[tgIndex].CurrentTargetGroup.TargetGroupArn
+}
+
 func (r *Rule) delete(lb *LoadBalancer) error {
 	if r.CurrentRule == nil {
 		log.Infof("Rule entered delete with no CurrentRule to delete. Rule: %s",
@@ -156,18 +251,23 @@ func (r *Rule) delete(lb *LoadBalancer) error {
 	return nil
 }
 
-func (r *Rule) needsModification() bool {
+func (r *Rule) needsModification(lb *LoadBalancer) bool {
 	cr := r.CurrentRule
 	dr := r.DesiredRule
 
 	switch {
 	case cr == nil:
 		return true
-		// TODO: If we can populate the TargetGroupArn in NewALBIngressFromIngress, we can enable this
-		// case awsutil.Prettify(cr.Actions) != awsutil.Prettify(dr.Actions):
-		// 	return true
 	case awsutil.Prettify(cr.Conditions) != awsutil.Prettify(dr.Conditions):
 		return true
+	case aws.StringValue(cr.Actions[0].Type) != aws.StringValue(dr.Actions[0].Type):
+		return true
+	case r.DesiredRedirect != nil && (r.CurrentRedirect == nil || *r.CurrentRedirect != *r.DesiredRedirect):
+		return true
+	case r.DesiredRedirect == nil && !awsutil.DeepEqual(r.CurrentWeights, r.shiftWeights(lb)):
+		return true
+	case r.DesiredRedirect == nil && r.CurrentWeights == nil && r.forwardsElsewhere(lb):
+		return true
 	}
 
 	return false
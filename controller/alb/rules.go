@@ -2,12 +2,77 @@ package alb
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/controller/config"
+	"github.com/coreos/alb-ingress-controller/log"
+)
+
+const (
+	// Default number of rules, default rules not included, an ALB can have
+	defaultRuleQuota = 100
+	// Maximum length of a path pattern condition value
+	maxPathPatternLength = 128
+	// Maximum number of wildcards, * and ?, in the conditions of a rule
+	maxRuleWildcards = 5
+	// Maximum number of values of the conditions of a rule
+	maxRuleConditionValues = 5
 )
 
+// pathPatternChars are the characters besides letters and digits ALB path patterns can contain. *
+// matches 0 or more characters and ? exactly one.
+const pathPatternChars = "_-.$/~\"'@:+&*?"
+
+// ruleQuota is the number of rules, default rules not included, an ALB can have. AWS raises the
+// quota on request. See SetRuleQuota.
+var ruleQuota = defaultRuleQuota
+
+// ruleSwapThreshold is the number of rule changes from which the rules of a listener are swapped
+// all at once rather than changed one by one, 0 when they never are. See SetRuleSwapThreshold.
+var ruleSwapThreshold int
+
 // Rules contains a slice of Rules
 type Rules []*Rule
 
+// SetRuleQuota sets the number of rules, default rules not included, an ALB can have. A value of 0
+// restores the AWS default quota of 100.
+func SetRuleQuota(quota int) {
+	if quota <= 0 {
+		quota = defaultRuleQuota
+	}
+	ruleQuota = quota
+}
+
+// SetRuleSwapThreshold sets the number of rules of a listener to create, modify or delete from
+// which its rule set is swapped atomically, see Rules.swap. A value of 0 disables swapping.
+func SetRuleSwapThreshold(threshold int) {
+	ruleSwapThreshold = threshold
+}
+
+// validatePathPattern returns an error when an ingress path can't be used as is as the value of a
+// path-pattern condition. Paths are passed through unchanged, so ALB wildcards in them keep their
+// meaning.
+func validatePathPattern(path string) error {
+	if len(path) > maxPathPatternLength {
+		return fmt.Errorf("path %s is %d characters long, ALB path patterns can be at most %d characters",
+			path, len(path), maxPathPatternLength)
+	}
+	for _, c := range path {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune(pathPatternChars, c)) {
+			return fmt.Errorf("path %s contains %q, ALB path patterns can only contain letters, digits and %s",
+				path, c, pathPatternChars)
+		}
+	}
+	if wildcards := strings.Count(path, "*") + strings.Count(path, "?"); wildcards > maxRuleWildcards {
+		return fmt.Errorf("path %s has %d wildcards, ALB rules can have at most %d", path, wildcards, maxRuleWildcards)
+	}
+	return nil
+}
+
 // Reconcile kicks off the state synchronization for every Rule in this Rules slice.
 func (r Rules) Reconcile(lb *LoadBalancer, l *Listener) error {
 
@@ -27,6 +92,186 @@ func (r Rules) Reconcile(lb *LoadBalancer, l *Listener) error {
 	return nil
 }
 
+// replacements pairs the rules about to be created with the rules about to be deleted, e.g. when
+// the path of a backend changed, so each pair can be updated in place through ModifyRule. Deleting
+// a rule before its replacement is created would send its requests to the default action in
+// between. Rules of the same service are paired first. The rules replaced are keyed by the rules
+// replacing them.
+func (r Rules) replacements() map[*Rule]*Rule {
+	var stale, created Rules
+	for _, rule := range r {
+		switch {
+		case rule.DesiredRule == nil && rule.CurrentRule != nil && !aws.BoolValue(rule.CurrentRule.IsDefault):
+			stale = append(stale, rule)
+		case rule.DesiredRule != nil && rule.CurrentRule == nil && !aws.BoolValue(rule.DesiredRule.IsDefault):
+			created = append(created, rule)
+		}
+	}
+
+	replaced := make(map[*Rule]*Rule)
+	adopted := make(map[*Rule]bool)
+	for _, sameService := range []bool{true, false} {
+		for _, rule := range created {
+			if replaced[rule] != nil {
+				continue
+			}
+			for _, old := range stale {
+				if adopted[old] || sameService && (old.SvcName != rule.SvcName || old.SvcPort != rule.SvcPort) {
+					continue
+				}
+				replaced[rule] = old
+				adopted[old] = true
+				break
+			}
+		}
+	}
+	return replaced
+}
+
+// adoptReplaced has the rules about to be created take over the AWS rules of the rules they
+// replace, see replacements. It returns the rules left once the replaced ones are dropped.
+func (r Rules) adoptReplaced() Rules {
+	replaced := r.replacements()
+	if len(replaced) == 0 {
+		return r
+	}
+	adopted := make(map[*Rule]bool)
+	for rule, old := range replaced {
+		rule.CurrentRule, rule.CurrentRedirect = old.CurrentRule, old.CurrentRedirect
+		adopted[old] = true
+	}
+	var left Rules
+	for _, rule := range r {
+		if !adopted[rule] {
+			left = append(left, rule)
+		}
+	}
+	return left
+}
+
+// swappable reports whether the rule changes of listener l are swapped in atomically. They are when
+// there are at least ruleSwapThreshold of them, some replace current rules, and the ALB has room for
+// the new rule set next to the current rules.
+func (r Rules) swappable(lb *LoadBalancer, l *Listener) bool {
+	if ruleSwapThreshold <= 0 || l.CurrentListener == nil || l.DesiredListener == nil {
+		return false
+	}
+	var changes, desired int
+	var replacing bool
+	for _, rule := range r {
+		switch {
+		case rule.DesiredRule == nil:
+			if rule.CurrentRule != nil && !aws.BoolValue(rule.CurrentRule.IsDefault) {
+				changes++
+				replacing = true
+			}
+		case aws.BoolValue(rule.DesiredRule.IsDefault):
+			continue
+		case rule.CurrentRule == nil:
+			changes++
+		case rule.needsModification(lb):
+			changes++
+			replacing = true
+		}
+		if rule.DesiredRule != nil {
+			desired++
+		}
+	}
+	if changes < ruleSwapThreshold || !replacing {
+		return false
+	}
+
+	current := 0
+	for _, listener := range lb.Listeners {
+		for _, rule := range listener.Rules {
+			if rule.CurrentRule != nil && !aws.BoolValue(rule.CurrentRule.IsDefault) {
+				current++
+			}
+		}
+	}
+	return current+desired <= ruleQuota
+}
+
+// swap replaces the rules of listener l all at once, so requests are never routed by a partially
+// applied rule set. The desired rules are created anew behind the current ones, at unused
+// priorities, then moved ahead of them in order in a single SetRulePriorities call, after which the
+// current rules are deleted. When creating the rules or setting their priorities fails, the rules
+// created are deleted and the current ones are left untouched. It returns the rules of the
+// listener, including the current rules that failed to delete, which Reconcile retries.
+func (r Rules) swap(lb *LoadBalancer, l *Listener) (Rules, error) {
+	type previous struct {
+		rule     *elbv2.Rule
+		redirect *config.RedirectConfig
+	}
+	var desired, rules Rules
+	var retired []*elbv2.Rule
+	replaced := make(map[*Rule]previous)
+	for _, rule := range r {
+		switch {
+		case rule.DesiredRule == nil:
+			if rule.CurrentRule != nil && !aws.BoolValue(rule.CurrentRule.IsDefault) {
+				retired = append(retired, rule.CurrentRule)
+			}
+			continue
+		case !aws.BoolValue(rule.DesiredRule.IsDefault):
+			desired = append(desired, rule)
+			replaced[rule] = previous{rule.CurrentRule, rule.CurrentRedirect}
+			if rule.CurrentRule != nil {
+				retired = append(retired, rule.CurrentRule)
+			}
+		}
+		rules = append(rules, rule)
+	}
+
+	// restore deletes the rules created so far and has every desired rule point at its current rule
+	// again.
+	restore := func() {
+		for _, rule := range desired {
+			if p := replaced[rule]; rule.CurrentRule != p.rule {
+				if err := rule.delete(lb); err != nil {
+					log.Errorf("Failed to delete rule %s created for the swap. Error: %s", *l.IngressID,
+						aws.StringValue(rule.CurrentRule.RuleArn), err.Error())
+				}
+				rule.CurrentRule, rule.CurrentRedirect, rule.deleted = p.rule, p.redirect, false
+			}
+		}
+	}
+
+	log.Infof("Start swap of %d rules of listener on port %d.", *l.IngressID, len(desired), *l.CurrentListener.Port)
+	for _, rule := range desired {
+		if err := rule.create(lb, l); err != nil {
+			restore()
+			return r, err
+		}
+	}
+
+	var pairs []*elbv2.RulePriorityPair
+	for _, rule := range desired {
+		pairs = append(pairs, &elbv2.RulePriorityPair{RuleArn: rule.CurrentRule.RuleArn, Priority: aws.Int64(int64(len(pairs) + 1))})
+	}
+	for _, old := range retired {
+		pairs = append(pairs, &elbv2.RulePriorityPair{RuleArn: old.RuleArn, Priority: aws.Int64(int64(len(pairs) + 1))})
+	}
+	if err := awsutil.ALBsvc.SetRulePriorities(elbv2.SetRulePrioritiesInput{RulePriorities: pairs}); err != nil {
+		log.Errorf("Failed to swap the rules of listener on port %d. Error: %s", *l.IngressID, *l.CurrentListener.Port, err.Error())
+		restore()
+		return r, err
+	}
+	for i, rule := range desired {
+		rule.CurrentRule.Priority = aws.String(strconv.FormatInt(*pairs[i].Priority, 10))
+	}
+
+	// The retired rules no longer match any request, as the new rule set takes precedence.
+	for _, old := range retired {
+		stale := &Rule{IngressID: l.IngressID, CurrentRule: old}
+		if err := stale.delete(lb); err != nil {
+			rules = append(rules, stale)
+		}
+	}
+	log.Infof("Completed swap of %d rules of listener on port %d.", *l.IngressID, len(desired), *l.CurrentListener.Port)
+	return rules, nil
+}
+
 // Find returns the position in the Rules slice of the rule parameter
 func (r Rules) Find(rule *elbv2.Rule) int {
 	for p, v := range r {
@@ -37,6 +282,37 @@ func (r Rules) Find(rule *elbv2.Rule) int {
 	return -1
 }
 
+// DetectDrift re-describes the rules of the listener, returning a description of each rule that was
+// deleted out of band.
+func (r Rules) DetectDrift(l *Listener) ([]string, error) {
+	rules, err := awsutil.ALBsvc.DescribeRules(l.CurrentListener.ListenerArn)
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool)
+	for _, rule := range rules {
+		exists[*rule.RuleArn] = true
+	}
+
+	var drifts []string
+	for _, rule := range r {
+		if rule.CurrentRule == nil || rule.DesiredRule == nil || *rule.DesiredRule.IsDefault {
+			continue
+		}
+		if rule.CurrentRule.RuleArn == nil || exists[*rule.CurrentRule.RuleArn] {
+			continue
+		}
+		var paths []string
+		for _, condition := range rule.CurrentRule.Conditions {
+			paths = append(paths, aws.StringValueSlice(condition.Values)...)
+		}
+		drifts = append(drifts, fmt.Sprintf("rule for path %s of listener on port %d was deleted",
+			strings.Join(paths, ","), *l.CurrentListener.Port))
+		rule.CurrentRule = nil
+	}
+	return drifts, nil
+}
+
 // StripDesiredState removes the DesiredListener from all Rules in the slice.
 func (r Rules) StripDesiredState() {
 	for _, rule := range r {
@@ -0,0 +1,66 @@
+package alb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	extensions "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+func TestValidatePathPattern(t *testing.T) {
+	var tests = []struct {
+		path string
+		pass bool
+	}{
+		{"/api", true},
+		{"/api/*", true},
+		{"/img/*.png", true},
+		{"/v?/users", true},
+		{"/*/orders/*/items/?", true},
+		{"/a*/b*/c*/d*/e*/f*", false},
+		{"/search?q=*", false},
+		{"/café", false},
+		{"/" + strings.Repeat("a", maxPathPatternLength), false},
+	}
+
+	for _, tt := range tests {
+		err := validatePathPattern(tt.path)
+		if err != nil && tt.pass {
+			t.Errorf("validatePathPattern(%v): expected %v, actual %v", tt.path, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("validatePathPattern(%v): expected %v, actual %v", tt.path, tt.pass, err)
+		}
+	}
+}
+
+func TestNewRulesSplitsHosts(t *testing.T) {
+	path := extensions.HTTPIngressPath{Path: "/api"}
+	hosts := []string{"a.example.com", "b.example.com", "c.example.com", "d.example.com", "e.example.com", "f.example.com"}
+	rules := NewRules(path, aws.String("default-app"), nil, hosts)
+	if len(rules) != 2 {
+		t.Fatalf("NewRules(): expected 2 rules, actual %d", len(rules))
+	}
+	var split []string
+	for _, r := range rules {
+		values := 0
+		for _, condition := range r.DesiredRule.Conditions {
+			values += len(condition.Values)
+			if *condition.Field == "host-header" {
+				split = append(split, aws.StringValueSlice(condition.Values)...)
+			}
+		}
+		if values > maxRuleConditionValues {
+			t.Errorf("NewRules(): rule matches %d values, ALB rules can match at most %d", values, maxRuleConditionValues)
+		}
+	}
+	if !reflect.DeepEqual(split, hosts) {
+		t.Errorf("NewRules(): expected hosts %v, actual %v", hosts, split)
+	}
+
+	if rules := NewRules(path, aws.String("default-app"), nil, hosts[:4]); len(rules) != 1 {
+		t.Errorf("NewRules(): expected 1 rule for 4 hosts, actual %d", len(rules))
+	}
+}
@@ -0,0 +1,384 @@
+package alb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/controller/config"
+	"github.com/coreos/alb-ingress-controller/controller/util"
+	"github.com/coreos/alb-ingress-controller/log"
+)
+
+// allowAllOutbound returns the outbound rule AWS gives new security groups.
+func allowAllOutbound() *ec2.IpPermission {
+	return &ec2.IpPermission{IpProtocol: aws.String("-1"), IpRanges: []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}}}
+}
+
+// SecurityGroup contains the current and desired state of the security group the controller
+// manages for an ALB when its ingress doesn't specify security groups. Outbound rules are left alone
+// when the desired security group has none.
+type SecurityGroup struct {
+	IngressID            *string
+	Owner                string // namespace/name of the ingress, stamped on rule descriptions
+	CurrentSecurityGroup *ec2.SecurityGroup
+	DesiredSecurityGroup *ec2.SecurityGroup
+	DesiredTags          util.EC2Tags
+}
+
+// NewSecurityGroup returns a new alb.SecurityGroup, named after the ALB it belongs to, that allows
+// inbound traffic to each of the ALB's listener ports, and outbound traffic as the outbound-rules
+// annotation allows, or to all destinations without it.
+func NewSecurityGroup(annotations *config.Annotations, tags util.Tags, namespace, ingressName string, loadBalancerID, ingressID *string) *SecurityGroup {
+	var permissions []*ec2.IpPermission
+	for _, port := range annotations.Ports {
+		permission := &ec2.IpPermission{
+			IpProtocol: aws.String("tcp"),
+			FromPort:   aws.Int64(port.Port),
+			ToPort:     aws.Int64(port.Port),
+		}
+		// Inbound traffic is restricted to the CIDR blocks and prefix lists given, otherwise it's open
+		// to all.
+		for _, cidr := range annotations.InboundCIDRs {
+			permission.IpRanges = append(permission.IpRanges, &ec2.IpRange{CidrIp: cidr})
+		}
+		for _, id := range annotations.InboundPrefixLists {
+			permission.PrefixListIds = append(permission.PrefixListIds, &ec2.PrefixListId{PrefixListId: id})
+		}
+		if len(permission.IpRanges) == 0 && len(permission.PrefixListIds) == 0 {
+			permission.IpRanges = []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}}
+			// Dualstack ALBs are reached by IPv6 clients as well.
+			if aws.StringValue(annotations.IPAddressType) == elbv2.IpAddressTypeDualstack {
+				permission.Ipv6Ranges = []*ec2.Ipv6Range{{CidrIpv6: aws.String("::/0")}}
+			}
+		}
+		permissions = append(permissions, permission)
+	}
+
+	// Like a new security group, one without outbound rules allows all outbound traffic.
+	egress := []*ec2.IpPermission{allowAllOutbound()}
+	if len(annotations.OutboundRules) > 0 {
+		egress = nil
+	}
+	for _, rule := range annotations.OutboundRules {
+		permission := &ec2.IpPermission{
+			IpProtocol: aws.String("tcp"),
+			FromPort:   aws.Int64(rule.FromPort),
+			ToPort:     aws.Int64(rule.ToPort),
+		}
+		switch destination := aws.String(rule.Destination); {
+		case strings.HasPrefix(rule.Destination, "pl-"):
+			permission.PrefixListIds = []*ec2.PrefixListId{{PrefixListId: destination}}
+		case strings.HasPrefix(rule.Destination, "sg-"):
+			permission.UserIdGroupPairs = []*ec2.UserIdGroupPair{{GroupId: destination}}
+		case strings.Contains(rule.Destination, ":"):
+			permission.Ipv6Ranges = []*ec2.Ipv6Range{{CidrIpv6: destination}}
+		default:
+			permission.IpRanges = []*ec2.IpRange{{CidrIp: destination}}
+		}
+		egress = append(egress, permission)
+	}
+
+	return &SecurityGroup{
+		IngressID: ingressID,
+		Owner:     fmt.Sprintf("%s/%s", namespace, ingressName),
+		DesiredSecurityGroup: &ec2.SecurityGroup{
+			GroupName:           loadBalancerID,
+			Description:         aws.String(fmt.Sprintf("Managed by the ALB ingress controller for %s", *ingressID)),
+			VpcId:               annotations.VPCID,
+			IpPermissions:       permissions,
+			IpPermissionsEgress: egress,
+		},
+		DesiredTags: tags.AsEC2Tags(),
+	}
+}
+
+// Reconcile compares the current and desired state of this SecurityGroup instance. Comparison
+// results in no action, the creation, the deletion, or the modification of the inbound rules of an
+// AWS security group to satisfy the ingress's current state.
+func (sg *SecurityGroup) Reconcile() error {
+	switch {
+	case sg.DesiredSecurityGroup == nil: // sg should be deleted
+		if sg.CurrentSecurityGroup == nil {
+			break
+		}
+		log.Infof("Start security group deletion.", *sg.IngressID)
+		if err := sg.delete(); err != nil {
+			return err
+		}
+		log.Infof("Completed security group deletion.", *sg.IngressID)
+
+	case sg.CurrentSecurityGroup == nil: // sg doesn't exist and should be created
+		log.Infof("Start security group creation.", *sg.IngressID)
+		if err := sg.create(); err != nil {
+			return err
+		}
+		log.Infof("Completed security group creation. ID: %s | Name: %s", *sg.IngressID,
+			*sg.CurrentSecurityGroup.GroupId, *sg.CurrentSecurityGroup.GroupName)
+
+	default: // check for diff between sg current and desired rules, modify if necessary
+		if !sg.needsModification() {
+			log.Debugf("No modification of security group required.", *sg.IngressID)
+			break
+		}
+		log.Infof("Start security group modification.", *sg.IngressID)
+		if err := sg.modify(); err != nil {
+			return err
+		}
+		log.Infof("Completed security group modification. Rules: %s", *sg.IngressID,
+			log.Prettify(sg.CurrentSecurityGroup.IpPermissions))
+	}
+
+	return nil
+}
+
+func (sg *SecurityGroup) create() error {
+	id, err := awsutil.Ec2svc.CreateSecurityGroup(ec2.CreateSecurityGroupInput{
+		GroupName:   sg.DesiredSecurityGroup.GroupName,
+		Description: sg.DesiredSecurityGroup.Description,
+		VpcId:       sg.DesiredSecurityGroup.VpcId,
+	})
+	if err != nil {
+		log.Errorf("Failed security group creation. Error: %s", *sg.IngressID, awsutil.DescribeError(err))
+		return err
+	}
+
+	sg.CurrentSecurityGroup = &ec2.SecurityGroup{
+		GroupId:             id,
+		GroupName:           sg.DesiredSecurityGroup.GroupName,
+		Description:         sg.DesiredSecurityGroup.Description,
+		VpcId:               sg.DesiredSecurityGroup.VpcId,
+		IpPermissionsEgress: []*ec2.IpPermission{allowAllOutbound()},
+	}
+
+	if len(sg.DesiredTags) > 0 {
+		in := ec2.CreateTagsInput{Resources: []*string{id}, Tags: sg.DesiredTags}
+		if err := awsutil.Ec2svc.CreateTags(in); err != nil {
+			log.Errorf("Failed security group tagging. ID: %s | Error: %s", *sg.IngressID, *id, awsutil.DescribeError(err))
+			return err
+		}
+	}
+	sg.CurrentSecurityGroup.Tags = sg.DesiredTags
+
+	return sg.modify()
+}
+
+// modify authorizes the desired inbound and outbound rules missing from the security group and
+// revokes the rules that are no longer desired.
+func (sg *SecurityGroup) modify() error {
+	additions, removals := permissionChanges(sg.CurrentSecurityGroup.IpPermissions, sg.DesiredSecurityGroup.IpPermissions, false)
+
+	if len(additions) > 0 {
+		in := ec2.AuthorizeSecurityGroupIngressInput{
+			GroupId:       sg.CurrentSecurityGroup.GroupId,
+			IpPermissions: additions,
+		}
+		var descriptions []string
+		for _, permission := range additions {
+			descriptions = append(descriptions, sg.ruleDescription(permission))
+		}
+		if err := awsutil.Ec2svc.AuthorizeSecurityGroupIngress(in, descriptions); err != nil {
+			log.Errorf("Failed adding security group rules %s. Error: %s", *sg.IngressID, log.Prettify(additions), awsutil.DescribeError(err))
+			return err
+		}
+	}
+
+	if len(removals) > 0 {
+		in := ec2.RevokeSecurityGroupIngressInput{
+			GroupId:       sg.CurrentSecurityGroup.GroupId,
+			IpPermissions: removals,
+		}
+		if err := awsutil.Ec2svc.RevokeSecurityGroupIngress(in); err != nil {
+			log.Errorf("Failed removing security group rules %s. Error: %s", *sg.IngressID, log.Prettify(removals), awsutil.DescribeError(err))
+			return err
+		}
+	}
+
+	sg.CurrentSecurityGroup.IpPermissions = sg.DesiredSecurityGroup.IpPermissions
+	return sg.modifyEgress()
+}
+
+// modifyEgress authorizes the desired outbound rules missing from the security group, then revokes
+// the rules that are no longer desired, so outbound traffic the ALB needs is never cut off.
+func (sg *SecurityGroup) modifyEgress() error {
+	if sg.DesiredSecurityGroup.IpPermissionsEgress == nil {
+		return nil
+	}
+	additions, removals := permissionChanges(sg.CurrentSecurityGroup.IpPermissionsEgress, sg.DesiredSecurityGroup.IpPermissionsEgress, true)
+
+	if len(additions) > 0 {
+		in := ec2.AuthorizeSecurityGroupEgressInput{
+			GroupId:       sg.CurrentSecurityGroup.GroupId,
+			IpPermissions: additions,
+		}
+		var descriptions []string
+		for range additions {
+			descriptions = append(descriptions, fmt.Sprintf("%s outbound rule", sg.Owner))
+		}
+		if err := awsutil.Ec2svc.AuthorizeSecurityGroupEgress(in, descriptions); err != nil {
+			log.Errorf("Failed adding security group outbound rules %s. Error: %s", *sg.IngressID, log.Prettify(additions), awsutil.DescribeError(err))
+			return err
+		}
+	}
+
+	if len(removals) > 0 {
+		in := ec2.RevokeSecurityGroupEgressInput{
+			GroupId:       sg.CurrentSecurityGroup.GroupId,
+			IpPermissions: removals,
+		}
+		if err := awsutil.Ec2svc.RevokeSecurityGroupEgress(in); err != nil {
+			log.Errorf("Failed removing security group outbound rules %s. Error: %s", *sg.IngressID, log.Prettify(removals), awsutil.DescribeError(err))
+			return err
+		}
+	}
+
+	sg.CurrentSecurityGroup.IpPermissionsEgress = sg.DesiredSecurityGroup.IpPermissionsEgress
+	return nil
+}
+
+// permissionChanges returns the desired permissions missing from current, and the current ones that
+// aren't desired, one per source. Security group sources are only compared with groupPairs.
+func permissionChanges(current, desired []*ec2.IpPermission, groupPairs bool) (additions, removals []*ec2.IpPermission) {
+	currentRules := flattenPermissions(current, groupPairs)
+	desiredRules := flattenPermissions(desired, groupPairs)
+	for key, permission := range desiredRules {
+		if _, ok := currentRules[key]; !ok {
+			additions = append(additions, permission)
+		}
+	}
+	for key, permission := range currentRules {
+		if _, ok := desiredRules[key]; !ok {
+			removals = append(removals, permission)
+		}
+	}
+	return additions, removals
+}
+
+func (sg *SecurityGroup) delete() error {
+	if err := awsutil.Ec2svc.DeleteSecurityGroup(sg.CurrentSecurityGroup.GroupId); err != nil {
+		log.Errorf("Failed security group deletion. ID: %s | Error: %s", *sg.IngressID,
+			*sg.CurrentSecurityGroup.GroupId, err.Error())
+		return err
+	}
+
+	sg.CurrentSecurityGroup = nil
+	return nil
+}
+
+// DetectDrift re-describes the CurrentSecurityGroup, returning a description of its inbound rules
+// that were changed out of band, or of its deletion.
+func (sg *SecurityGroup) DetectDrift() ([]string, error) {
+	if sg.CurrentSecurityGroup == nil || sg.DesiredSecurityGroup == nil {
+		return nil, nil
+	}
+
+	sgs, err := awsutil.Ec2svc.DescribeSecurityGroups(ec2.DescribeSecurityGroupsInput{
+		GroupIds: []*string{sg.CurrentSecurityGroup.GroupId},
+	})
+	if err != nil && awsutil.ErrorCode(err) != "InvalidGroup.NotFound" {
+		return nil, err
+	}
+	if len(sgs) == 0 {
+		drifts := []string{fmt.Sprintf("security group %s was deleted", *sg.CurrentSecurityGroup.GroupId)}
+		sg.CurrentSecurityGroup = nil
+		return drifts, nil
+	}
+
+	drifts := permissionDrifts("inbound", *sg.CurrentSecurityGroup.GroupId, sg.CurrentSecurityGroup.IpPermissions, sgs[0].IpPermissions)
+	if sg.DesiredSecurityGroup.IpPermissionsEgress != nil {
+		drifts = append(drifts, permissionDrifts("outbound", *sg.CurrentSecurityGroup.GroupId,
+			sg.CurrentSecurityGroup.IpPermissionsEgress, sgs[0].IpPermissionsEgress)...)
+	}
+	sort.Strings(drifts)
+	sg.CurrentSecurityGroup.IpPermissions = sgs[0].IpPermissions
+	sg.CurrentSecurityGroup.IpPermissionsEgress = sgs[0].IpPermissionsEgress
+	return drifts, nil
+}
+
+// permissionDrifts describes the rules of the given direction, inbound or outbound, that were
+// removed from or added to a security group since it was last described. Only outbound rules are
+// compared by security group.
+func permissionDrifts(direction, groupID string, previous, current []*ec2.IpPermission) []string {
+	groupPairs := direction == "outbound"
+	added, removed := permissionChanges(previous, current, groupPairs)
+	var drifts []string
+	for key := range flattenPermissions(removed, groupPairs) {
+		drifts = append(drifts, fmt.Sprintf("%s rule %s of security group %s was removed", direction, key, groupID))
+	}
+	for key := range flattenPermissions(added, groupPairs) {
+		drifts = append(drifts, fmt.Sprintf("%s rule %s was added to security group %s", direction, key, groupID))
+	}
+	return drifts
+}
+
+// ruleDescription returns the description of an inbound rule, tracing it back to the ingress and
+// listener it was added for.
+func (sg *SecurityGroup) ruleDescription(permission *ec2.IpPermission) string {
+	return fmt.Sprintf("%s listener port %d", sg.Owner, aws.Int64Value(permission.FromPort))
+}
+
+func (sg *SecurityGroup) needsModification() bool {
+	additions, removals := permissionChanges(sg.CurrentSecurityGroup.IpPermissions, sg.DesiredSecurityGroup.IpPermissions, false)
+	if len(additions)+len(removals) > 0 {
+		return true
+	}
+	if sg.DesiredSecurityGroup.IpPermissionsEgress == nil {
+		return false
+	}
+	additions, removals = permissionChanges(sg.CurrentSecurityGroup.IpPermissionsEgress, sg.DesiredSecurityGroup.IpPermissionsEgress, true)
+	return len(additions)+len(removals) > 0
+}
+
+// PeakRules returns the number of inbound rules the security group holds while it's reconciled. The
+// desired rules are added before the rules no longer desired are revoked, so both count.
+func (sg *SecurityGroup) PeakRules() int64 {
+	rules := flattenPermissions(sg.DesiredSecurityGroup.IpPermissions, false)
+	if sg.CurrentSecurityGroup != nil {
+		for key, permission := range flattenPermissions(sg.CurrentSecurityGroup.IpPermissions, false) {
+			rules[key] = permission
+		}
+	}
+	return int64(len(rules))
+}
+
+// flattenPermissions splits permissions into one permission per source, keyed by protocol, port
+// range and source, so permissions can be compared regardless of how AWS groups them. Security group
+// sources are only kept with groupPairs, for the outbound rules to backend security groups; inbound
+// rules are managed by CIDR and prefix list, and the ones referencing security groups are left alone.
+func flattenPermissions(permissions []*ec2.IpPermission, groupPairs bool) map[string]*ec2.IpPermission {
+	out := make(map[string]*ec2.IpPermission)
+	for _, p := range permissions {
+		rule := func(source string) (string, *ec2.IpPermission) {
+			return fmt.Sprintf("%s:%d-%d:%s", aws.StringValue(p.IpProtocol), aws.Int64Value(p.FromPort), aws.Int64Value(p.ToPort), source),
+				&ec2.IpPermission{IpProtocol: p.IpProtocol, FromPort: p.FromPort, ToPort: p.ToPort}
+		}
+		for _, r := range p.IpRanges {
+			key, permission := rule(aws.StringValue(r.CidrIp))
+			permission.IpRanges = []*ec2.IpRange{r}
+			out[key] = permission
+		}
+		for _, r := range p.Ipv6Ranges {
+			key, permission := rule(aws.StringValue(r.CidrIpv6))
+			permission.Ipv6Ranges = []*ec2.Ipv6Range{r}
+			out[key] = permission
+		}
+		for _, pl := range p.PrefixListIds {
+			key, permission := rule(aws.StringValue(pl.PrefixListId))
+			permission.PrefixListIds = []*ec2.PrefixListId{pl}
+			out[key] = permission
+		}
+		if !groupPairs {
+			continue
+		}
+		for _, g := range p.UserIdGroupPairs {
+			key, permission := rule(aws.StringValue(g.GroupId))
+			permission.UserIdGroupPairs = []*ec2.UserIdGroupPair{{GroupId: g.GroupId}}
+			out[key] = permission
+		}
+	}
+	return out
+}
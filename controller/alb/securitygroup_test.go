@@ -0,0 +1,67 @@
+package alb
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestPeakRules(t *testing.T) {
+	permission := func(port int64, cidrs ...string) *ec2.IpPermission {
+		p := &ec2.IpPermission{IpProtocol: aws.String("tcp"), FromPort: aws.Int64(port), ToPort: aws.Int64(port)}
+		for _, cidr := range cidrs {
+			p.IpRanges = append(p.IpRanges, &ec2.IpRange{CidrIp: aws.String(cidr)})
+		}
+		return p
+	}
+
+	var tests = []struct {
+		current  []*ec2.IpPermission
+		desired  []*ec2.IpPermission
+		expected int64
+	}{
+		{nil, []*ec2.IpPermission{permission(443, "10.0.0.0/8", "192.168.0.0/16")}, 2},
+		{[]*ec2.IpPermission{permission(443, "10.0.0.0/8")}, []*ec2.IpPermission{permission(443, "10.0.0.0/8", "192.168.0.0/16")}, 2},
+		{[]*ec2.IpPermission{permission(80, "0.0.0.0/0")}, []*ec2.IpPermission{permission(443, "0.0.0.0/0")}, 2},
+	}
+
+	for _, tt := range tests {
+		sg := &SecurityGroup{DesiredSecurityGroup: &ec2.SecurityGroup{IpPermissions: tt.desired}}
+		if tt.current != nil {
+			sg.CurrentSecurityGroup = &ec2.SecurityGroup{IpPermissions: tt.current}
+		}
+		if rules := sg.PeakRules(); rules != tt.expected {
+			t.Errorf("PeakRules(): expected %v, actual %v", tt.expected, rules)
+		}
+	}
+}
+
+func TestPermissionChanges(t *testing.T) {
+	group := func(port int64, id string) *ec2.IpPermission {
+		return &ec2.IpPermission{IpProtocol: aws.String("tcp"), FromPort: aws.Int64(port), ToPort: aws.Int64(port),
+			UserIdGroupPairs: []*ec2.UserIdGroupPair{{GroupId: aws.String(id), UserId: aws.String("123456789012")}}}
+	}
+
+	var tests = []struct {
+		current    []*ec2.IpPermission
+		desired    []*ec2.IpPermission
+		groupPairs bool
+		additions  int
+		removals   int
+	}{
+		{[]*ec2.IpPermission{allowAllOutbound()}, []*ec2.IpPermission{allowAllOutbound()}, true, 0, 0},
+		{[]*ec2.IpPermission{allowAllOutbound()}, []*ec2.IpPermission{group(8080, "sg-1")}, true, 1, 1},
+		{[]*ec2.IpPermission{group(8080, "sg-1")}, []*ec2.IpPermission{group(8080, "sg-1"), group(8080, "sg-2")}, true, 1, 0},
+		// Inbound rules referencing security groups aren't managed, so they're never revoked.
+		{[]*ec2.IpPermission{group(443, "sg-1")}, nil, false, 0, 0},
+	}
+
+	for _, tt := range tests {
+		additions, removals := permissionChanges(tt.current, tt.desired, tt.groupPairs)
+		if len(additions) != tt.additions || len(removals) != tt.removals {
+			t.Errorf("permissionChanges(%v, %v, %v): expected %v additions and %v removals, actual %v and %v",
+				tt.current, tt.desired, tt.groupPairs, tt.additions, tt.removals, len(additions), len(removals))
+		}
+	}
+}
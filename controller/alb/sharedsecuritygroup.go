@@ -0,0 +1,167 @@
+package alb
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/controller/util"
+	"github.com/coreos/alb-ingress-controller/log"
+)
+
+// SharedSecurityGroup is the security group every ALB without security groups of its own uses in
+// shared mode, instead of one security group per ALB. Its inbound rules are the union of the rules
+// the ALBs would get in their own security groups. Each of NodeSecurityGroups gets a single rule
+// allowing TCP traffic from it, rather than the nodes allowing each ALB separately.
+type SharedSecurityGroup struct {
+	SecurityGroup
+	Name               string
+	NodeSecurityGroups util.AWSStringSlice
+	nodeRules          bool // whether the node security groups were given their rule
+}
+
+// NewSharedSecurityGroup returns the alb.SharedSecurityGroup named name, tagged with tags.
+func NewSharedSecurityGroup(name string, nodeSecurityGroups util.AWSStringSlice, tags util.Tags) *SharedSecurityGroup {
+	return &SharedSecurityGroup{
+		SecurityGroup: SecurityGroup{
+			IngressID:   aws.String("controller"),
+			Owner:       name,
+			DesiredTags: tags.AsEC2Tags(),
+		},
+		Name:               name,
+		NodeSecurityGroups: nodeSecurityGroups,
+	}
+}
+
+// ShareSecurityGroup moves the inbound rules of the security group managed for the ALB to the
+// SharedSecurityGroup. The ALB gets no security group of its own, and one it had is deleted once the
+// ALB uses the shared one.
+func (lb *LoadBalancer) ShareSecurityGroup() {
+	if lb.SecurityGroup == nil {
+		return
+	}
+	lb.SharedPermissions = lb.SecurityGroup.DesiredSecurityGroup.IpPermissions
+	lb.SecurityGroup = nil
+}
+
+// Reconcile creates, modifies or deletes the SharedSecurityGroup to allow the traffic of every ALB
+// in loadBalancers sharing it, and points those ALBs at it. It's deleted once no ALB uses it
+// anymore, which for ALBs deleted in this reconcile is the next one.
+func (s *SharedSecurityGroup) Reconcile(loadBalancers LoadBalancers) error {
+	var permissions []*ec2.IpPermission
+	var vpcID *string
+	var sharing LoadBalancers
+	inUse := false
+	for _, lb := range loadBalancers {
+		if lb.DesiredLoadBalancer != nil && lb.SharedPermissions != nil {
+			permissions = append(permissions, lb.SharedPermissions...)
+			vpcID = lb.DesiredLoadBalancer.VpcId
+			sharing = append(sharing, lb)
+		}
+		if s.CurrentSecurityGroup != nil && lb.CurrentLoadBalancer != nil &&
+			len(util.AWSStringSlice(lb.CurrentLoadBalancer.SecurityGroups).Intersect(util.AWSStringSlice{s.CurrentSecurityGroup.GroupId})) > 0 {
+			inUse = true
+		}
+	}
+
+	if len(sharing) == 0 {
+		if s.CurrentSecurityGroup == nil || inUse {
+			return nil
+		}
+		if err := s.revokeNodeRules(); err != nil {
+			return err
+		}
+		s.DesiredSecurityGroup = nil
+		return s.SecurityGroup.Reconcile()
+	}
+
+	// The shared security group outlives controller restarts, it's looked up by name.
+	if s.CurrentSecurityGroup == nil {
+		sgs, err := awsutil.Ec2svc.DescribeSecurityGroups(ec2.DescribeSecurityGroupsInput{
+			Filters: []*ec2.Filter{
+				{Name: aws.String("group-name"), Values: []*string{aws.String(s.Name)}},
+				{Name: aws.String("vpc-id"), Values: []*string{vpcID}},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if len(sgs) > 0 {
+			s.CurrentSecurityGroup = sgs[0]
+		}
+	}
+
+	s.DesiredSecurityGroup = &ec2.SecurityGroup{
+		GroupName:     aws.String(s.Name),
+		Description:   aws.String("Managed by the ALB ingress controller for the ALBs sharing it"),
+		VpcId:         vpcID,
+		IpPermissions: permissions,
+	}
+	created := s.CurrentSecurityGroup == nil
+	if err := s.SecurityGroup.Reconcile(); err != nil {
+		return err
+	}
+	if created {
+		s.nodeRules = false
+	}
+	if err := s.authorizeNodeRules(); err != nil {
+		return err
+	}
+
+	for _, lb := range sharing {
+		lb.DesiredLoadBalancer.SecurityGroups = []*string{s.CurrentSecurityGroup.GroupId}
+	}
+	return nil
+}
+
+// nodePermission returns the inbound rule of the node security groups allowing traffic from the
+// SharedSecurityGroup, to any port as the ALBs reach NodePorts as well as pod ports.
+func (s *SharedSecurityGroup) nodePermission() *ec2.IpPermission {
+	return &ec2.IpPermission{
+		IpProtocol:       aws.String("tcp"),
+		FromPort:         aws.Int64(0),
+		ToPort:           aws.Int64(65535),
+		UserIdGroupPairs: []*ec2.UserIdGroupPair{{GroupId: s.CurrentSecurityGroup.GroupId}},
+	}
+}
+
+// authorizeNodeRules adds the nodePermission to each of the NodeSecurityGroups, once per
+// SharedSecurityGroup. Rules that already exist are left as they are.
+func (s *SharedSecurityGroup) authorizeNodeRules() error {
+	if s.nodeRules {
+		return nil
+	}
+	description := fmt.Sprintf("%s ALBs", s.Name)
+	for _, id := range s.NodeSecurityGroups {
+		in := ec2.AuthorizeSecurityGroupIngressInput{
+			GroupId:       id,
+			IpPermissions: []*ec2.IpPermission{s.nodePermission()},
+		}
+		err := awsutil.Ec2svc.AuthorizeSecurityGroupIngress(in, []string{description})
+		if err != nil && awsutil.ErrorCode(err) != "InvalidPermission.Duplicate" {
+			log.Errorf("Failed adding the shared security group rule to node security group %s. Error: %s", *s.IngressID, *id, awsutil.DescribeError(err))
+			return err
+		}
+	}
+	s.nodeRules = true
+	return nil
+}
+
+// revokeNodeRules removes the nodePermission from each of the NodeSecurityGroups, as the
+// SharedSecurityGroup can't be deleted while they reference it.
+func (s *SharedSecurityGroup) revokeNodeRules() error {
+	for _, id := range s.NodeSecurityGroups {
+		in := ec2.RevokeSecurityGroupIngressInput{
+			GroupId:       id,
+			IpPermissions: []*ec2.IpPermission{s.nodePermission()},
+		}
+		err := awsutil.Ec2svc.RevokeSecurityGroupIngress(in)
+		if err != nil && awsutil.ErrorCode(err) != "InvalidPermission.NotFound" {
+			log.Errorf("Failed removing the shared security group rule from node security group %s. Error: %s", *s.IngressID, *id, awsutil.DescribeError(err))
+			return err
+		}
+	}
+	s.nodeRules = false
+	return nil
+}
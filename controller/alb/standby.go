@@ -0,0 +1,488 @@
+package alb
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/controller/config"
+	"github.com/coreos/alb-ingress-controller/controller/util"
+	"github.com/coreos/alb-ingress-controller/log"
+)
+
+const (
+	// SetIdentifier of the failover record pointing at the primary ALB
+	primaryRecordIdentifier = "primary"
+	// SetIdentifier of the failover record pointing at the standby ALB
+	standbyRecordIdentifier = "standby"
+)
+
+// Standby contains the current and desired state of the ALB mirroring a LoadBalancer in a standby
+// region for disaster recovery, along with its target group, listeners and the Route 53 failover
+// record Route 53 answers with when the primary ALB is unhealthy. The standby ALB forwards all
+// requests to a fixed list of IP targets. Its methods must be called with the awsutil services of
+// the standby region, see awsutil.AssumeRoleIn.
+type Standby struct {
+	IngressID                *string
+	Region                   string
+	ZoneID                   *string // hosted zone of the failover records, nil without Route 53
+	CurrentLoadBalancer      *elbv2.LoadBalancer
+	DesiredLoadBalancer      *elbv2.LoadBalancer
+	CurrentTargetGroup       *elbv2.TargetGroup
+	DesiredTargetGroup       *elbv2.TargetGroup
+	CurrentTargets           util.AWSStringSlice
+	DesiredTargets           util.AWSStringSlice
+	CurrentListeners         []*elbv2.Listener
+	DesiredListeners         []*elbv2.Listener
+	CurrentResourceRecordSet *route53.ResourceRecordSet
+	DesiredResourceRecordSet *route53.ResourceRecordSet
+	DesiredTags              util.Tags
+	Deleted                  bool // flag representing the standby resources were fully deleted
+	synced                   bool // whether the current state was looked up in the standby region
+	// name and record are the name of the standby ALB and its failover record, without its alias
+	// target, kept when the desired state is stripped so the standby can still be looked up
+	name   *string
+	record *route53.ResourceRecordSet
+}
+
+// NewStandby returns a new alb.Standby mirroring lb, named like lb, in the region of the standby
+// annotations. Its failover record pairs up with the ResourceRecordSet of lb, if there is one.
+func NewStandby(lb *LoadBalancer, annotations *config.Annotations) *Standby {
+	standby := annotations.Standby
+
+	hasher := md5.New()
+	hasher.Write([]byte(*lb.ID))
+	tgName := "standby-" + hex.EncodeToString(hasher.Sum(nil))[:15]
+
+	s := &Standby{
+		IngressID:   lb.IngressID,
+		Region:      standby.Region,
+		DesiredTags: lb.DesiredTags,
+		DesiredLoadBalancer: &elbv2.LoadBalancer{
+			AvailabilityZones: standby.Subnets.AsAvailabilityZones(),
+			LoadBalancerName:  lb.ID,
+			Scheme:            annotations.Scheme,
+			SecurityGroups:    standby.SecurityGroups,
+		},
+		DesiredTargetGroup: &elbv2.TargetGroup{
+			TargetGroupName: aws.String(tgName),
+			Port:            aws.Int64(standby.TargetPort),
+			Protocol:        aws.String("HTTP"),
+			HealthCheckPath: annotations.HealthcheckPath,
+			Matcher:         &elbv2.Matcher{HttpCode: annotations.SuccessCodes},
+		},
+		DesiredTargets: standby.Targets,
+		name:           lb.ID,
+	}
+
+	for _, port := range annotations.Ports {
+		listener := &elbv2.Listener{
+			Port:     aws.Int64(port.Port),
+			Protocol: aws.String("HTTP"),
+		}
+		if port.HTTPS {
+			listener.Protocol = aws.String("HTTPS")
+			listener.Certificates = []*elbv2.Certificate{{CertificateArn: standby.CertificateArn}}
+			listener.SslPolicy = annotations.SSLPolicy
+		}
+		s.DesiredListeners = append(s.DesiredListeners, listener)
+	}
+
+	if r := lb.ResourceRecordSet; r != nil && r.Resolveable && r.DesiredResourceRecordSet != nil {
+		s.ZoneID = r.ZoneID
+		s.DesiredResourceRecordSet = &route53.ResourceRecordSet{
+			Name:          r.DesiredResourceRecordSet.Name,
+			Type:          aws.String(route53.RRTypeA),
+			SetIdentifier: aws.String(standbyRecordIdentifier),
+			Failover:      aws.String(route53.ResourceRecordSetFailoverSecondary),
+			AliasTarget:   &route53.AliasTarget{EvaluateTargetHealth: aws.Bool(true)},
+		}
+		s.record = s.DesiredResourceRecordSet
+	}
+	return s
+}
+
+// SetDesiredState replaces the desired state of the standby with the one of desired.
+func (s *Standby) SetDesiredState(desired *Standby) {
+	s.ZoneID = desired.ZoneID
+	s.DesiredLoadBalancer = desired.DesiredLoadBalancer
+	s.DesiredTargetGroup = desired.DesiredTargetGroup
+	s.DesiredTargets = desired.DesiredTargets
+	s.DesiredListeners = desired.DesiredListeners
+	s.DesiredResourceRecordSet = desired.DesiredResourceRecordSet
+	s.DesiredTags = desired.DesiredTags
+	s.name = desired.name
+	if desired.record != nil {
+		s.record = desired.record
+	}
+}
+
+// StripDesiredState removes the desired state of the standby, so Reconcile deletes it.
+func (s *Standby) StripDesiredState() {
+	s.DesiredLoadBalancer = nil
+	s.DesiredTargetGroup = nil
+	s.DesiredTargets = nil
+	s.DesiredListeners = nil
+	s.DesiredResourceRecordSet = nil
+}
+
+// Reconcile compares the current and desired state of this Standby instance. Comparison results
+// in no action, the creation, the deletion, or the modification of the standby ALB, its target
+// group and listeners, and the deletion of its failover record, which is otherwise written by
+// ReconcileResourceRecordSet. The first Reconcile looks up the standby resources that already
+// exist, e.g. ones created before the controller restarted.
+func (s *Standby) Reconcile() error {
+	if !s.synced {
+		if err := s.sync(); err != nil {
+			log.Errorf("Failed to look up standby ELBV2 (ALB) in %s. Error: %s", *s.IngressID, s.Region, awsutil.DescribeError(err))
+			return err
+		}
+		s.synced = true
+	}
+
+	if s.DesiredLoadBalancer == nil {
+		if s.CurrentLoadBalancer == nil && s.CurrentTargetGroup == nil {
+			s.Deleted = true
+			return nil
+		}
+		log.Infof("Start standby ELBV2 (ALB) deletion in %s.", *s.IngressID, s.Region)
+		if err := s.delete(); err != nil {
+			return err
+		}
+		log.Infof("Completed standby ELBV2 (ALB) deletion in %s.", *s.IngressID, s.Region)
+		return nil
+	}
+
+	if err := s.reconcileLoadBalancer(); err != nil {
+		return err
+	}
+	if err := s.reconcileTargetGroup(); err != nil {
+		return err
+	}
+	return s.reconcileListeners()
+}
+
+// sync looks up the standby ALB by name, along with the target group its listeners forward to.
+func (s *Standby) sync() error {
+	name := s.name
+	if name == nil {
+		return nil
+	}
+	lb, err := awsutil.ALBsvc.DescribeLoadBalancerByName(name)
+	if err != nil || lb == nil {
+		return err
+	}
+	tags, err := awsutil.ALBsvc.DescribeTags(lb.LoadBalancerArn)
+	if err != nil {
+		return err
+	}
+	if err := tags.OwnershipConflict(s.DesiredTags); err != nil {
+		return fmt.Errorf("ALB %s already exists in %s and is %s", *name, s.Region, err.Error())
+	}
+	s.CurrentLoadBalancer = lb
+
+	if s.CurrentListeners, err = awsutil.ALBsvc.DescribeListeners(lb.LoadBalancerArn); err != nil {
+		return err
+	}
+	for _, l := range s.CurrentListeners {
+		for _, action := range l.DefaultActions {
+			if action.TargetGroupArn == nil || s.CurrentTargetGroup != nil {
+				continue
+			}
+			if s.CurrentTargetGroup, err = awsutil.ALBsvc.DescribeTargetGroup(action.TargetGroupArn); err != nil {
+				return err
+			}
+			if s.CurrentTargets, err = awsutil.ALBsvc.DescribeTargetGroupTargets(action.TargetGroupArn); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The failover record is written with an UPSERT, so it's assumed to point at the ALB. Deleting
+	// a record that doesn't exist is a no-op.
+	if s.record != nil {
+		s.CurrentResourceRecordSet = s.aliasRecord(s.record)
+	}
+	return nil
+}
+
+func (s *Standby) reconcileLoadBalancer() error {
+	desired := s.DesiredLoadBalancer
+	if s.CurrentLoadBalancer == nil {
+		o, err := awsutil.ALBsvc.Create(elbv2.CreateLoadBalancerInput{
+			Name:           desired.LoadBalancerName,
+			Subnets:        util.AvailabilityZones(desired.AvailabilityZones).AsSubnets(),
+			Scheme:         desired.Scheme,
+			Tags:           s.DesiredTags,
+			SecurityGroups: desired.SecurityGroups,
+		})
+		if err != nil {
+			log.Errorf("Failed to create standby ELBV2 (ALB) in %s. Error: %s", *s.IngressID, s.Region, awsutil.DescribeError(err))
+			return err
+		}
+		s.CurrentLoadBalancer = o
+		log.Infof("Completed standby ELBV2 (ALB) creation in %s. Name: %s | ARN: %s", *s.IngressID, s.Region,
+			*o.LoadBalancerName, *o.LoadBalancerArn)
+		return nil
+	}
+
+	current := s.CurrentLoadBalancer
+	subnets := util.AvailabilityZones(desired.AvailabilityZones).AsSubnets()
+	if *util.AvailabilityZones(current.AvailabilityZones).AsSubnets().Hash() != *subnets.Hash() {
+		in := elbv2.SetSubnetsInput{LoadBalancerArn: current.LoadBalancerArn, Subnets: subnets}
+		if err := awsutil.ALBsvc.SetSubnets(in); err != nil {
+			log.Errorf("Failed to set subnets of standby ELBV2 (ALB) in %s. Error: %s", *s.IngressID, s.Region, awsutil.DescribeError(err))
+			return err
+		}
+		current.AvailabilityZones = desired.AvailabilityZones
+	}
+	// Without security groups from the annotations, the ALB keeps the VPC's default security group.
+	if len(desired.SecurityGroups) > 0 && *util.AWSStringSlice(current.SecurityGroups).Hash() != *util.AWSStringSlice(desired.SecurityGroups).Hash() {
+		in := elbv2.SetSecurityGroupsInput{LoadBalancerArn: current.LoadBalancerArn, SecurityGroups: desired.SecurityGroups}
+		if err := awsutil.ALBsvc.SetSecurityGroups(in); err != nil {
+			log.Errorf("Failed to set security groups of standby ELBV2 (ALB) in %s. Error: %s", *s.IngressID, s.Region, awsutil.DescribeError(err))
+			return err
+		}
+		current.SecurityGroups = desired.SecurityGroups
+	}
+	return nil
+}
+
+func (s *Standby) reconcileTargetGroup() error {
+	desired := s.DesiredTargetGroup
+	if s.CurrentTargetGroup == nil {
+		in := elbv2.CreateTargetGroupInput{
+			Name:            desired.TargetGroupName,
+			Port:            desired.Port,
+			Protocol:        desired.Protocol,
+			HealthCheckPath: desired.HealthCheckPath,
+			Matcher:         desired.Matcher,
+			VpcId:           s.CurrentLoadBalancer.VpcId,
+		}
+		o, err := awsutil.ALBsvc.AddTargetGroup(in, aws.String(awsutil.TargetTypeIP), nil, nil)
+		if err != nil {
+			log.Errorf("Failed to create standby TargetGroup in %s. Error: %s", *s.IngressID, s.Region, awsutil.DescribeError(err))
+			return err
+		}
+		s.CurrentTargetGroup = o
+		s.CurrentTargets = nil
+		if err := awsutil.ALBsvc.UpdateTags(o.TargetGroupArn, nil, s.DesiredTags); err != nil {
+			log.Errorf("Failed to tag standby TargetGroup in %s. Error: %s", *s.IngressID, s.Region, awsutil.DescribeError(err))
+			return err
+		}
+	}
+
+	current := s.CurrentTargetGroup
+	if aws.StringValue(current.HealthCheckPath) != aws.StringValue(desired.HealthCheckPath) ||
+		!awsutil.DeepEqual(current.Matcher, desired.Matcher) {
+		o, err := awsutil.ALBsvc.ModifyTargetGroup(elbv2.ModifyTargetGroupInput{
+			TargetGroupArn:  current.TargetGroupArn,
+			HealthCheckPath: desired.HealthCheckPath,
+			Matcher:         desired.Matcher,
+		})
+		if err != nil {
+			log.Errorf("Failed to modify standby TargetGroup in %s. Error: %s", *s.IngressID, s.Region, awsutil.DescribeError(err))
+			return err
+		}
+		s.CurrentTargetGroup = o
+	}
+
+	var additions, removals []*elbv2.TargetDescription
+	for _, target := range s.DesiredTargets.Difference(s.CurrentTargets) {
+		additions = append(additions, &elbv2.TargetDescription{Id: target, Port: desired.Port})
+	}
+	for _, target := range s.CurrentTargets.Difference(s.DesiredTargets) {
+		removals = append(removals, &elbv2.TargetDescription{Id: target, Port: desired.Port})
+	}
+	if len(additions) > 0 {
+		in := elbv2.RegisterTargetsInput{TargetGroupArn: current.TargetGroupArn, Targets: additions}
+		if err := awsutil.ALBsvc.RegisterTargets(in); err != nil {
+			log.Errorf("Failed to register standby targets in %s. Error: %s", *s.IngressID, s.Region, awsutil.DescribeError(err))
+			return err
+		}
+	}
+	if len(removals) > 0 {
+		in := elbv2.DeregisterTargetsInput{TargetGroupArn: current.TargetGroupArn, Targets: removals}
+		if err := awsutil.ALBsvc.DeregisterTargets(in); err != nil {
+			log.Errorf("Failed to deregister standby targets in %s. Error: %s", *s.IngressID, s.Region, awsutil.DescribeError(err))
+			return err
+		}
+	}
+	s.CurrentTargets = s.DesiredTargets
+	return nil
+}
+
+// reconcileListeners creates the desired listeners missing from the standby ALB, modifies the ones
+// whose protocol, certificate or security policy differ and deletes the ones no longer desired.
+// Every listener forwards to the standby target group.
+func (s *Standby) reconcileListeners() error {
+	current := make(map[int64]*elbv2.Listener)
+	for _, l := range s.CurrentListeners {
+		current[*l.Port] = l
+	}
+	actions := []*elbv2.Action{{Type: aws.String("forward"), TargetGroupArn: s.CurrentTargetGroup.TargetGroupArn}}
+
+	var listeners []*elbv2.Listener
+	for _, desired := range s.DesiredListeners {
+		l, ok := current[*desired.Port]
+		delete(current, *desired.Port)
+		switch {
+		case !ok:
+			o, err := awsutil.ALBsvc.AddListener(elbv2.CreateListenerInput{
+				LoadBalancerArn: s.CurrentLoadBalancer.LoadBalancerArn,
+				Port:            desired.Port,
+				Protocol:        desired.Protocol,
+				Certificates:    desired.Certificates,
+				SslPolicy:       desired.SslPolicy,
+				DefaultActions:  actions,
+			})
+			if err != nil {
+				log.Errorf("Failed to create standby Listener on port %d in %s. Error: %s", *s.IngressID, *desired.Port, s.Region, awsutil.DescribeError(err))
+				return err
+			}
+			l = o
+		case standbyListenerModified(l, desired, s.CurrentTargetGroup.TargetGroupArn):
+			o, err := awsutil.ALBsvc.ModifyListener(elbv2.ModifyListenerInput{
+				ListenerArn:    l.ListenerArn,
+				Port:           desired.Port,
+				Protocol:       desired.Protocol,
+				Certificates:   desired.Certificates,
+				SslPolicy:      desired.SslPolicy,
+				DefaultActions: actions,
+			})
+			if err != nil {
+				log.Errorf("Failed to modify standby Listener on port %d in %s. Error: %s", *s.IngressID, *desired.Port, s.Region, awsutil.DescribeError(err))
+				return err
+			}
+			l = o
+		}
+		listeners = append(listeners, l)
+	}
+	for port, l := range current {
+		if err := awsutil.ALBsvc.RemoveListener(elbv2.DeleteListenerInput{ListenerArn: l.ListenerArn}); err != nil {
+			log.Errorf("Failed to delete standby Listener on port %d in %s. Error: %s", *s.IngressID, port, s.Region, awsutil.DescribeError(err))
+			return err
+		}
+	}
+
+	s.CurrentListeners = listeners
+	return nil
+}
+
+// standbyListenerModified reports whether listener differs from desired, or doesn't forward to the
+// target group with the ARN targetGroupArn.
+func standbyListenerModified(listener, desired *elbv2.Listener, targetGroupArn *string) bool {
+	switch {
+	case !awsutil.DeepEqual(listener.Protocol, desired.Protocol):
+		return true
+	case !awsutil.DeepEqual(listener.Certificates, desired.Certificates):
+		return true
+	case desired.SslPolicy != nil && !awsutil.DeepEqual(listener.SslPolicy, desired.SslPolicy):
+		return true
+	case len(listener.DefaultActions) != 1 || !awsutil.DeepEqual(listener.DefaultActions[0].TargetGroupArn, targetGroupArn):
+		return true
+	}
+	return false
+}
+
+// ReconcileResourceRecordSet writes the failover record of the standby ALB, once primary, the
+// record of the LoadBalancer it mirrors, is the primary failover record. Route 53 rejects a failover
+// record sharing its name with a simple record, so until the simple record was converted, which
+// may only happen when a Route 53 batch is flushed, the standby record is left for a later
+// reconcile.
+func (s *Standby) ReconcileResourceRecordSet(primary *ResourceRecordSet) error {
+	if s.DesiredResourceRecordSet == nil || s.CurrentLoadBalancer == nil || s.ZoneID == nil || awsutil.Route53svc == nil {
+		return nil
+	}
+	if primary == nil || primary.CurrentResourceRecordSet == nil ||
+		aws.StringValue(primary.CurrentResourceRecordSet.Failover) != route53.ResourceRecordSetFailoverPrimary {
+		return nil
+	}
+	desired := s.aliasRecord(s.DesiredResourceRecordSet)
+	if current := s.CurrentResourceRecordSet; current != nil && recordTarget(current) == recordTarget(desired) &&
+		*current.Name == *desired.Name {
+		return nil
+	}
+
+	in := route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53.ChangeBatch{
+			Changes: []*route53.Change{
+				{
+					Action:            aws.String(route53.ChangeActionUpsert),
+					ResourceRecordSet: desired,
+				},
+			},
+			Comment: aws.String("Managed by Kubernetes"),
+		},
+		HostedZoneId: s.ZoneID,
+	}
+	if err := awsutil.Route53svc.Modify(in); err != nil {
+		log.Errorf("Failed Route 53 standby failover record modification. Error: %s", *s.IngressID, awsutil.DescribeError(err))
+		return err
+	}
+	s.CurrentResourceRecordSet = desired
+	log.Infof("Completed Route 53 standby failover record modification. DNS: %s | Target: %s", *s.IngressID,
+		*desired.Name, recordTarget(desired))
+	return nil
+}
+
+// aliasRecord returns a copy of record aliasing the standby ALB.
+func (s *Standby) aliasRecord(r *route53.ResourceRecordSet) *route53.ResourceRecordSet {
+	record := *r
+	record.AliasTarget = &route53.AliasTarget{
+		DNSName:              aws.String(*s.CurrentLoadBalancer.DNSName + "."),
+		HostedZoneId:         s.CurrentLoadBalancer.CanonicalHostedZoneId,
+		EvaluateTargetHealth: aws.Bool(true),
+	}
+	return &record
+}
+
+// delete removes the failover record, the standby ALB, along with its listeners, and the standby
+// target group.
+func (s *Standby) delete() error {
+	if s.CurrentResourceRecordSet != nil && s.ZoneID != nil && awsutil.Route53svc != nil {
+		in := route53.ChangeResourceRecordSetsInput{
+			ChangeBatch: &route53.ChangeBatch{
+				Changes: []*route53.Change{
+					{
+						Action:            aws.String("DELETE"),
+						ResourceRecordSet: s.CurrentResourceRecordSet,
+					},
+				},
+			},
+			HostedZoneId: s.ZoneID,
+		}
+		if err := awsutil.Route53svc.Delete(in); err != nil {
+			log.Errorf("Failed deletion of Route 53 standby failover record. Error: %s", *s.IngressID, awsutil.DescribeError(err))
+			return err
+		}
+		s.CurrentResourceRecordSet = nil
+	}
+
+	if s.CurrentLoadBalancer != nil {
+		in := elbv2.DeleteLoadBalancerInput{LoadBalancerArn: s.CurrentLoadBalancer.LoadBalancerArn}
+		if err := awsutil.ALBsvc.Delete(in); err != nil {
+			log.Errorf("Failed deletion of standby ELBV2 (ALB) in %s. Error: %s", *s.IngressID, s.Region, awsutil.DescribeError(err))
+			return err
+		}
+		s.CurrentLoadBalancer = nil
+		s.CurrentListeners = nil
+	}
+
+	if s.CurrentTargetGroup != nil {
+		in := elbv2.DeleteTargetGroupInput{TargetGroupArn: s.CurrentTargetGroup.TargetGroupArn}
+		if err := awsutil.ALBsvc.RemoveTargetGroup(in); err != nil {
+			log.Errorf("Failed deletion of standby TargetGroup in %s. Error: %s", *s.IngressID, s.Region, awsutil.DescribeError(err))
+			return err
+		}
+		s.CurrentTargetGroup = nil
+		s.CurrentTargets = nil
+	}
+
+	s.Deleted = true
+	return nil
+}
@@ -0,0 +1,68 @@
+package alb
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/coreos/alb-ingress-controller/awsutil/fake"
+)
+
+func TestStandbyRecordWaitsForPrimary(t *testing.T) {
+	clients := fake.New()
+	defer clients.Install()()
+	zoneID := clients.Route53.AddHostedZone("example.com")
+
+	s := &Standby{
+		IngressID: aws.String("default-app"),
+		ZoneID:    aws.String(zoneID),
+		CurrentLoadBalancer: &elbv2.LoadBalancer{
+			DNSName:               aws.String("standby.eu-west-1.elb.amazonaws.com"),
+			CanonicalHostedZoneId: aws.String("Z32O12XQLNTSW2"),
+		},
+		DesiredResourceRecordSet: &route53.ResourceRecordSet{
+			Name:          aws.String("app.example.com."),
+			Type:          aws.String(route53.RRTypeA),
+			SetIdentifier: aws.String(standbyRecordIdentifier),
+			Failover:      aws.String(route53.ResourceRecordSetFailoverSecondary),
+		},
+	}
+	primary := &ResourceRecordSet{
+		CurrentResourceRecordSet: &route53.ResourceRecordSet{
+			Name: aws.String("app.example.com."),
+			Type: aws.String(route53.RRTypeA),
+		},
+	}
+
+	if err := s.ReconcileResourceRecordSet(primary); err != nil {
+		t.Fatalf("ReconcileResourceRecordSet() returned error %v", err)
+	}
+	if records := clients.Route53.Records(zoneID); len(records) != 0 || s.CurrentResourceRecordSet != nil {
+		t.Fatalf("ReconcileResourceRecordSet(): expected no record next to a simple record, actual %v", records)
+	}
+
+	primary.CurrentResourceRecordSet.SetIdentifier = aws.String(primaryRecordIdentifier)
+	primary.CurrentResourceRecordSet.Failover = aws.String(route53.ResourceRecordSetFailoverPrimary)
+	if err := s.ReconcileResourceRecordSet(primary); err != nil {
+		t.Fatalf("ReconcileResourceRecordSet() returned error %v", err)
+	}
+	records := clients.Route53.Records(zoneID)
+	if len(records) != 1 || aws.StringValue(records[0].Failover) != route53.ResourceRecordSetFailoverSecondary {
+		t.Errorf("ReconcileResourceRecordSet(): expected the secondary failover record, actual %v", records)
+	}
+}
+
+func TestStandbyDeletedBeforeSync(t *testing.T) {
+	clients := fake.New()
+	defer clients.Install()()
+
+	s := &Standby{IngressID: aws.String("default-app"), Region: "eu-west-1", name: aws.String("cluster-0123456789")}
+	s.StripDesiredState()
+	if err := s.Reconcile(); err != nil {
+		t.Fatalf("Reconcile() returned error %v", err)
+	}
+	if !s.Deleted {
+		t.Errorf("Reconcile(): expected a standby without resources to be deleted")
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/elbv2"
@@ -11,34 +14,125 @@ import (
 	"github.com/coreos/alb-ingress-controller/controller/config"
 	"github.com/coreos/alb-ingress-controller/controller/util"
 	"github.com/coreos/alb-ingress-controller/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// targetHealthStates are the health states an ALB reports for its targets.
+var targetHealthStates = []string{
+	elbv2.TargetHealthStateEnumInitial,
+	elbv2.TargetHealthStateEnumHealthy,
+	elbv2.TargetHealthStateEnumUnhealthy,
+	elbv2.TargetHealthStateEnumUnused,
+	elbv2.TargetHealthStateEnumDraining,
+}
+
+// targetGroupNameTemplate, when set, generates the names of target groups. See
+// SetTargetGroupNameTemplate.
+var targetGroupNameTemplate string
+
+// targetGroupShiftPeriod is how long traffic takes to shift from a target group to the one replacing
+// it. See SetTargetGroupShiftPeriod.
+var targetGroupShiftPeriod time.Duration
+
+// targetWarmupTimeout is how long newly registered targets are waited on to turn healthy. See
+// SetTargetWarmupTimeout.
+var targetWarmupTimeout time.Duration
+
+// targetFlapThreshold is how many times the targets of a target group change between healthy and
+// unhealthy within targetFlapWindow before it's reported as flapping. See SetTargetFlapDetection.
+var (
+	targetFlapThreshold int
+	targetFlapWindow    time.Duration
+)
+
+// targetGroupNamePlaceholders are the placeholders a target group name template can use.
+var targetGroupNamePlaceholders = []string{"{cluster}", "{ingress}", "{service}", "{port}", "{protocol}"}
+
 // TargetGroup contains the current/desired tags & targetgroup for the ALB
 type TargetGroup struct {
-	ID                 *string
-	IngressID          *string
-	SvcName            string
-	CurrentTags        util.Tags
-	DesiredTags        util.Tags
-	CurrentTargets     util.AWSStringSlice
-	DesiredTargets     util.AWSStringSlice
-	CurrentTargetGroup *elbv2.TargetGroup
-	DesiredTargetGroup *elbv2.TargetGroup
-	deleted            bool
+	ID                   *string
+	IngressID            *string
+	SvcName              string
+	SvcPort              intstr.IntOrString
+	TargetType           *string
+	ProtocolVersion      *string // HTTP version the ALB uses towards the targets
+	IPAddressType        *string // IP family of the targets in ip mode
+	CurrentTags          util.Tags
+	DesiredTags          util.Tags
+	CurrentTargets       util.AWSStringSlice
+	DesiredTargets       util.AWSStringSlice
+	CurrentStaticTargets util.AWSStringSlice
+	DesiredStaticTargets util.AWSStringSlice
+	CurrentTargetGroup   *elbv2.TargetGroup
+	DesiredTargetGroup   *elbv2.TargetGroup
+	CurrentAttributes    []*elbv2.TargetGroupAttribute
+	DesiredAttributes    []*elbv2.TargetGroupAttribute // only the attributes set through annotations
+	TargetHealth         map[string]string             // last polled health state of each target, keyed by target ID
+	DesiredAlarms        *config.Alarms                // CloudWatch alarms of the target group, nil when it has none
+	replacedBy           *TargetGroup                  // the target group traffic shifts to, while this one is retiring
+	retiring             time.Time                     // when traffic started shifting to replacedBy
+	warming              map[string]time.Time          // registered targets not yet reported healthy, with when they were registered
+	flaps                []time.Time                   // when targets changed between healthy and unhealthy, within the flap window
+	deleted              bool
 }
 
 // NewTargetGroup returns a new alb.TargetGroup based on the parameters provided.
-func NewTargetGroup(annotations *config.Annotations, tags util.Tags, clustername, loadBalancerID *string, port *int64, ingressID *string, svcName string) *TargetGroup {
+func NewTargetGroup(annotations *config.Annotations, tags util.Tags, clustername, loadBalancerID *string, port *int64, ingressID *string, svcName string, svcPort intstr.IntOrString) *TargetGroup {
 	hasher := md5.New()
 	hasher.Write([]byte(*loadBalancerID))
+	// In ip mode the port is the pod's target port, which distinct services can share. The service
+	// and target type are hashed in to keep those target groups apart.
+	if *annotations.TargetType == awsutil.TargetTypeIP {
+		hasher.Write([]byte(*annotations.TargetType + svcName + svcPort.String()))
+	}
+	// The protocol version can't be changed once a target group exists, so target groups using HTTP2
+	// get a name of their own. HTTP1 isn't hashed in, keeping the names of existing target groups.
+	if *annotations.BackendProtocolVersion != awsutil.ProtocolVersionHTTP1 {
+		hasher.Write([]byte(*annotations.BackendProtocolVersion))
+	}
+	// Likewise for the IP address type, IPv4 isn't hashed in.
+	if *annotations.TargetIPAddressType != awsutil.IPAddressTypeIPv4 {
+		hasher.Write([]byte(*annotations.TargetIPAddressType))
+	}
 	output := hex.EncodeToString(hasher.Sum(nil))
 
 	id := fmt.Sprintf("%.12s-%.5d-%.5s-%.7s", *clustername, *port, *annotations.BackendProtocol, output)
+	if targetGroupNameTemplate != "" {
+		// Everything that tells target groups apart is hashed, so the name stays unique whichever
+		// placeholders the template leaves out.
+		hasher := md5.New()
+		hasher.Write([]byte(fmt.Sprintf("%s/%d/%s/%s/%s/%s", *loadBalancerID, *port, *annotations.BackendProtocol,
+			*annotations.TargetType, svcName, svcPort.String())))
+		if *annotations.BackendProtocolVersion != awsutil.ProtocolVersionHTTP1 {
+			hasher.Write([]byte(*annotations.BackendProtocolVersion))
+		}
+		if *annotations.TargetIPAddressType != awsutil.IPAddressTypeIPv4 {
+			hasher.Write([]byte(*annotations.TargetIPAddressType))
+		}
+		id = targetGroupName(targetGroupNameTemplate, map[string]string{
+			"{cluster}":  *clustername,
+			"{ingress}":  *ingressID,
+			"{service}":  svcName,
+			"{port}":     svcPort.String(),
+			"{protocol}": *annotations.BackendProtocol,
+		}, hex.EncodeToString(hasher.Sum(nil))[:8])
+	}
 
-	// Add the service name tag to the Target group as it's needed when reassembling ingresses after
-	// controller relaunch.
+	// Add the service name and port tags to the Target group as they're needed when reassembling
+	// ingresses after controller relaunch.
 	tags = append(tags, &elbv2.Tag{
 		Key: aws.String("ServiceName"), Value: aws.String(svcName)})
+	tags = append(tags, &elbv2.Tag{
+		Key: aws.String("ServicePort"), Value: aws.String(svcPort.String())})
+	tags = append(tags, &elbv2.Tag{
+		Key: aws.String("TargetType"), Value: annotations.TargetType})
+	tags = append(tags, &elbv2.Tag{
+		Key: aws.String(util.ConfigHashTag), Value: aws.String(targetGroupConfigHash(annotations.TargetGroupAttributes))})
+	if annotations.Alarms != nil {
+		tags = append(tags, &elbv2.Tag{
+			Key: aws.String(util.AlarmsHashTag), Value: aws.String(alarmsConfigHash(annotations.Alarms))})
+	}
 
 	// TODO: Quick fix as we can't have the loadbalancer and target groups share pointers to the same
 	// tags. Each modify tags individually and can cause bad side-effects.
@@ -55,15 +149,21 @@ func NewTargetGroup(annotations *config.Annotations, tags util.Tags, clustername
 	}
 
 	targetGroup := &TargetGroup{
-		IngressID:   ingressID,
-		ID:          aws.String(id),
-		SvcName:     svcName,
-		DesiredTags: newTagList,
+		IngressID:         ingressID,
+		ID:                aws.String(id),
+		SvcName:           svcName,
+		SvcPort:           svcPort,
+		TargetType:        annotations.TargetType,
+		ProtocolVersion:   annotations.BackendProtocolVersion,
+		IPAddressType:     annotations.TargetIPAddressType,
+		DesiredTags:       newTagList,
+		DesiredAttributes: annotations.TargetGroupAttributes,
+		DesiredAlarms:     annotations.Alarms,
 		DesiredTargetGroup: &elbv2.TargetGroup{
 			HealthCheckPath:            annotations.HealthcheckPath,
 			HealthCheckIntervalSeconds: annotations.HealthcheckIntervalSeconds,
 			HealthCheckPort:            annotations.HealthcheckPort,
-			HealthCheckProtocol:        annotations.BackendProtocol,
+			HealthCheckProtocol:        annotations.HealthcheckProtocol,
 			HealthCheckTimeoutSeconds:  annotations.HealthcheckTimeoutSeconds,
 			HealthyThresholdCount:      annotations.HealthyThresholdCount,
 			// LoadBalancerArns:
@@ -79,16 +179,92 @@ func NewTargetGroup(annotations *config.Annotations, tags util.Tags, clustername
 	return targetGroup
 }
 
+// SetTargetGroupNameTemplate sets the template target group names are generated from, e.g.
+// "{cluster}-{service}". The template can use the {cluster}, {ingress}, {service}, {port} and
+// {protocol} placeholders. An empty template restores the default naming scheme.
+func SetTargetGroupNameTemplate(template string) error {
+	rest := template
+	for _, placeholder := range targetGroupNamePlaceholders {
+		rest = strings.Replace(rest, placeholder, "", -1)
+	}
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("target group name template %s contains an unknown placeholder, must only use %s",
+			template, strings.Join(targetGroupNamePlaceholders, ", "))
+	}
+	targetGroupNameTemplate = template
+	return nil
+}
+
+// SetTargetGroupShiftPeriod sets how long traffic takes to shift from a target group that has to be
+// recreated, e.g. because its protocol or port changed, to its replacement. Rules and default
+// actions forward to both through weighted forward actions meanwhile, the replacement getting a
+// tenth more of the requests every tenth of the period, and the old target group is deleted once
+// the shift completed. A period of 0 cuts traffic over at once.
+func SetTargetGroupShiftPeriod(period time.Duration) {
+	targetGroupShiftPeriod = period
+}
+
+// SetTargetWarmupTimeout sets how long targets registered with a target group are waited on to be
+// reported healthy. Until they are, or the timeout passed, the ALB isn't reported as reconciled
+// successfully. A timeout of 0 doesn't wait on targets.
+func SetTargetWarmupTimeout(timeout time.Duration) {
+	targetWarmupTimeout = timeout
+}
+
+// targetGroupName fills in the placeholders of template and appends hash. Characters target group
+// names don't allow are replaced by hyphens, and the name is truncated so it fits in the 32
+// characters allowed while keeping the hash.
+func targetGroupName(template string, values map[string]string, hash string) string {
+	name := template
+	for placeholder, value := range values {
+		name = strings.Replace(name, placeholder, value, -1)
+	}
+	name = strings.Map(func(c rune) rune {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' {
+			return c
+		}
+		return '-'
+	}, name)
+
+	if max := 32 - len(hash) - 1; len(name) > max {
+		name = name[:max]
+	}
+	name = strings.Trim(name, "-")
+	if name == "" {
+		return hash
+	}
+	return name + "-" + hash
+}
+
 // Reconcile compares the current and desired state of this TargetGroup instance. Comparison
 // results in no action, the creation, the deletion, or the modification of an AWS target group to
 // satisfy the ingress's current state.
 func (tg *TargetGroup) Reconcile(lb *LoadBalancer) error {
+	// Once traffic shifted to its replacement, a retiring target group goes away.
+	if tg.shifted() {
+		tg.DesiredTargetGroup = nil
+	}
+
+	// Diff targets against what is actually registered rather than what was last registered.
+	if tg.CurrentTargetGroup != nil && tg.DesiredTargetGroup != nil {
+		if err := tg.refreshTargets(); err != nil {
+			return err
+		}
+	}
+
 	switch {
 	// No DesiredState means target group should be deleted.
 	case tg.DesiredTargetGroup == nil:
 		if tg.CurrentTargetGroup == nil {
 			break
 		}
+		// Listeners and rules are reconciled after target groups. The target group is deleted once
+		// they forward elsewhere, see TargetGroups.DeleteUnused.
+		if tg.inUse(lb) {
+			log.Debugf("Deferring TargetGroup deletion until nothing forwards to it. ARN: %s.",
+				*tg.IngressID, *tg.CurrentTargetGroup.TargetGroupArn)
+			break
+		}
 		log.Infof("Start TargetGroup deletion.", *tg.IngressID)
 		if err := tg.delete(); err != nil {
 			return err
@@ -122,6 +298,53 @@ func (tg *TargetGroup) Reconcile(lb *LoadBalancer) error {
 	return nil
 }
 
+// shifted reports whether the target group was retiring and traffic shifted to its replacement.
+func (tg *TargetGroup) shifted() bool {
+	return tg.replacedBy != nil && !tg.retiring.IsZero() && time.Since(tg.retiring) >= targetGroupShiftPeriod
+}
+
+// inUse reports whether a listener or rule of lb still forwards to the target group.
+func (tg *TargetGroup) inUse(lb *LoadBalancer) bool {
+	// Listeners and rules are deleted along with the ALB.
+	if lb.Deleted {
+		return false
+	}
+	arn := aws.StringValue(tg.CurrentTargetGroup.TargetGroupArn)
+	forwards := func(action *elbv2.Action, weights []awsutil.TargetGroupWeight) bool {
+		if action != nil && aws.StringValue(action.TargetGroupArn) == arn {
+			return true
+		}
+		for _, w := range weights {
+			if w.TargetGroupArn == arn {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, l := range lb.Listeners {
+		if l.CurrentListener == nil || l.deleted {
+			continue
+		}
+		var action *elbv2.Action
+		if len(l.CurrentListener.DefaultActions) > 0 {
+			action = l.CurrentListener.DefaultActions[0]
+		}
+		if forwards(action, l.CurrentWeights) {
+			return true
+		}
+		for _, r := range l.Rules {
+			if r.CurrentRule == nil || r.deleted || len(r.CurrentRule.Actions) == 0 {
+				continue
+			}
+			if forwards(r.CurrentRule.Actions[0], r.CurrentWeights) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // Creates a new TargetGroup in AWS.
 func (tg *TargetGroup) create(lb *LoadBalancer) error {
 	// Debug logger to introspect CreateTargetGroup request
@@ -142,13 +365,33 @@ func (tg *TargetGroup) create(lb *LoadBalancer) error {
 		VpcId: lb.CurrentLoadBalancer.VpcId,
 	}
 
-	o, err := awsutil.ALBsvc.AddTargetGroup(in)
+	o, err := awsutil.ALBsvc.AddTargetGroup(in, tg.TargetType, tg.ProtocolVersion, tg.IPAddressType)
 	if err != nil {
 		log.Infof("Failed TargetGroup creation. Error: %s.", *tg.IngressID, err.Error())
 		return err
 	}
+
+	// Creating a target group that already exists with the same settings returns the existing target
+	// group. Refuse to take it over if another cluster or controller owns it.
+	tags, err := awsutil.ALBsvc.DescribeTags(o.TargetGroupArn)
+	if err != nil {
+		log.Infof("Failed TargetGroup creation. Unable to describe tags. Error: %s.", *tg.IngressID, err.Error())
+		return err
+	}
+	if err := tags.OwnershipConflict(tg.DesiredTags); err != nil {
+		log.Errorf("Failed TargetGroup creation. TargetGroup %s already exists and is %s.", *tg.IngressID, *o.TargetGroupName, err.Error())
+		return fmt.Errorf("target group %s already exists and is %s", *o.TargetGroupName, err.Error())
+	}
 	tg.CurrentTargetGroup = o
 
+	// Put alarms, before the tags whose AlarmsHash vouches for them
+	if tg.alarmsModified() {
+		if err = tg.reconcileAlarms(lb); err != nil {
+			log.Infof("Failed TargetGroup creation. Unable to put alarms. Error: %s.", *tg.IngressID, err.Error())
+			return err
+		}
+	}
+
 	// Add tags
 	if err = awsutil.ALBsvc.UpdateTags(tg.CurrentTargetGroup.TargetGroupArn, tg.CurrentTags, tg.DesiredTags); err != nil {
 		log.Infof("Failed TargetGroup creation. Unable to add tags. Error: %s.",
@@ -157,8 +400,20 @@ func (tg *TargetGroup) create(lb *LoadBalancer) error {
 	}
 	tg.CurrentTags = tg.DesiredTags
 
+	if len(tg.DesiredAttributes) > 0 {
+		in := elbv2.ModifyTargetGroupAttributesInput{
+			TargetGroupArn: tg.CurrentTargetGroup.TargetGroupArn,
+			Attributes:     tg.DesiredAttributes,
+		}
+		if err = awsutil.ALBsvc.ModifyTargetGroupAttributes(in); err != nil {
+			log.Infof("Failed TargetGroup creation. Unable to set attributes. Error: %s.", *tg.IngressID, err.Error())
+			return err
+		}
+	}
+	tg.CurrentAttributes = tg.DesiredAttributes
+
 	// Register Targets
-	if err = tg.registerTargets(); err != nil {
+	if err = tg.reconcileTargets(); err != nil {
 		log.Infof("Failed TargetGroup creation. Unable to register targets. Error:  %s.",
 			*tg.IngressID, err.Error())
 		return err
@@ -170,8 +425,8 @@ func (tg *TargetGroup) create(lb *LoadBalancer) error {
 // Modifies the attributes of an existing TargetGroup.
 // ALBIngress is only passed along for logging
 func (tg *TargetGroup) modify(lb *LoadBalancer) error {
-	// check/change attributes
-	if tg.needsModification() {
+	// check/change health check
+	if tg.healthCheckModified() {
 		in := elbv2.ModifyTargetGroupInput{
 			HealthCheckIntervalSeconds: tg.DesiredTargetGroup.HealthCheckIntervalSeconds,
 			HealthCheckPath:            tg.DesiredTargetGroup.HealthCheckPath,
@@ -194,7 +449,31 @@ func (tg *TargetGroup) modify(lb *LoadBalancer) error {
 		tg.CurrentTargetGroup.HealthCheckPath = tg.DesiredTargetGroup.HealthCheckPath
 	}
 
-	// check/change tags
+	// check/change attributes
+	if tg.attributesModified() {
+		in := elbv2.ModifyTargetGroupAttributesInput{
+			TargetGroupArn: tg.CurrentTargetGroup.TargetGroupArn,
+			Attributes:     tg.DesiredAttributes,
+		}
+		if err := awsutil.ALBsvc.ModifyTargetGroupAttributes(in); err != nil {
+			log.Errorf("Failed TargetGroup modification. Unable to modify attributes. ARN: %s | Error: %s.",
+				*tg.IngressID, *tg.CurrentTargetGroup.TargetGroupArn, err.Error())
+			return err
+		}
+		tg.CurrentAttributes = tg.DesiredAttributes
+	}
+
+	// check/change alarms
+	if tg.alarmsModified() {
+		if err := tg.reconcileAlarms(lb); err != nil {
+			log.Errorf("Failed TargetGroup modification. Unable to modify alarms. ARN: %s | Error: %s.",
+				*tg.IngressID, *tg.CurrentTargetGroup.TargetGroupArn, err.Error())
+			return err
+		}
+	}
+
+	// check/change tags, once the attributes and alarms their ConfigHash and AlarmsHash tags vouch
+	// for are set
 	if *tg.CurrentTags.Hash() != *tg.DesiredTags.Hash() {
 		if err := awsutil.ALBsvc.UpdateTags(tg.CurrentTargetGroup.TargetGroupArn, tg.CurrentTags, tg.DesiredTags); err != nil {
 			log.Errorf("Failed TargetGroup modification. Unable to modify tags. ARN: %s | Error: %s.",
@@ -206,7 +485,7 @@ func (tg *TargetGroup) modify(lb *LoadBalancer) error {
 
 	// check/change targets
 	if *tg.CurrentTargets.Hash() != *tg.DesiredTargets.Hash() {
-		if err := tg.registerTargets(); err != nil {
+		if err := tg.reconcileTargets(); err != nil {
 			log.Infof("Failed TargetGroup modification. Unable to change targets. Error: %s.",
 				*tg.IngressID, err.Error())
 			return err
@@ -219,24 +498,234 @@ func (tg *TargetGroup) modify(lb *LoadBalancer) error {
 
 // Deletes a TargetGroup in AWS.
 func (tg *TargetGroup) delete() error {
+	if err := tg.deleteAlarms(); err != nil {
+		log.Errorf("Failed TargetGroup deletion. Unable to delete alarms. ARN: %s | Error: %s.",
+			*tg.IngressID, *tg.CurrentTargetGroup.TargetGroupArn, err.Error())
+		return err
+	}
 	in := elbv2.DeleteTargetGroupInput{TargetGroupArn: tg.CurrentTargetGroup.TargetGroupArn}
 	if err := awsutil.ALBsvc.RemoveTargetGroup(in); err != nil {
 		log.Errorf("Failed TargetGroup deletion. ARN: %s.", *tg.IngressID, *tg.CurrentTargetGroup.TargetGroupArn)
 		return err
 	}
 
+	for _, state := range targetHealthStates {
+		awsutil.TargetHealth.Delete(prometheus.Labels{"target_group": *tg.ID, "state": state})
+	}
+	awsutil.TargetHealthFlaps.Delete(prometheus.Labels{"target_group": *tg.ID})
 	tg.deleted = true
 	return nil
 }
 
+// UpdateTargetHealth polls the health of the CurrentTargetGroup's targets and records the number of
+// targets in each state in the albingress_target_health gauge. The targets that turned unhealthy
+// since the previous poll are returned. Targets changing between healthy and unhealthy are counted
+// in the albingress_target_health_flaps counter.
+func (tg *TargetGroup) UpdateTargetHealth() ([]*elbv2.TargetHealthDescription, error) {
+	if tg.CurrentTargetGroup == nil {
+		return nil, nil
+	}
+
+	descriptions, err := awsutil.ALBsvc.DescribeTargetGroupHealth(tg.CurrentTargetGroup.TargetGroupArn)
+	if err != nil {
+		return nil, err
+	}
+
+	var unhealthy []*elbv2.TargetHealthDescription
+	now := time.Now()
+	counts := make(map[string]int)
+	health := make(map[string]string)
+	for _, d := range descriptions {
+		if d.Target == nil || d.TargetHealth == nil {
+			continue
+		}
+		id := aws.StringValue(d.Target.Id)
+		state := aws.StringValue(d.TargetHealth.State)
+		if state == elbv2.TargetHealthStateEnumUnhealthy && tg.TargetHealth[id] != elbv2.TargetHealthStateEnumUnhealthy {
+			unhealthy = append(unhealthy, d)
+		}
+		tg.recordTransition(tg.TargetHealth[id], state, now)
+		counts[state]++
+		health[id] = state
+	}
+
+	for _, state := range targetHealthStates {
+		awsutil.TargetHealth.With(prometheus.Labels{"target_group": *tg.ID, "state": state}).Set(float64(counts[state]))
+	}
+	tg.TargetHealth = health
+	return unhealthy, nil
+}
+
+// SetTargetFlapDetection reports a target group as flapping once its targets changed between healthy
+// and unhealthy threshold times within window. A threshold that isn't positive disables it.
+func SetTargetFlapDetection(threshold int, window time.Duration) {
+	targetFlapThreshold = threshold
+	targetFlapWindow = window
+}
+
+// recordTransition counts a target whose health changed from the previous to the current state,
+// when it changed between healthy and unhealthy.
+func (tg *TargetGroup) recordTransition(previous, current string, now time.Time) {
+	if !(previous == elbv2.TargetHealthStateEnumHealthy && current == elbv2.TargetHealthStateEnumUnhealthy) &&
+		!(previous == elbv2.TargetHealthStateEnumUnhealthy && current == elbv2.TargetHealthStateEnumHealthy) {
+		return
+	}
+	awsutil.TargetHealthFlaps.With(prometheus.Labels{"target_group": *tg.ID}).Inc()
+	if targetFlapThreshold > 0 {
+		tg.flaps = append(tg.flaps, now)
+	}
+}
+
+// UpdateFlapping returns how many times the targets changed between healthy and unhealthy within
+// the flap window, once that reaches the flap threshold, and 0 otherwise. The count starts over once
+// returned, so a target group that keeps flapping is reported once per threshold transitions.
+func (tg *TargetGroup) UpdateFlapping() int {
+	if targetFlapThreshold <= 0 {
+		return 0
+	}
+	cutoff := time.Now().Add(-targetFlapWindow)
+	i := 0
+	for i < len(tg.flaps) && tg.flaps[i].Before(cutoff) {
+		i++
+	}
+	tg.flaps = tg.flaps[i:]
+	if len(tg.flaps) < targetFlapThreshold {
+		return 0
+	}
+	flaps := len(tg.flaps)
+	tg.flaps = nil
+	return flaps
+}
+
+// startWarmup starts waiting on the targets ids to be reported healthy, when a warmup timeout is set.
+func (tg *TargetGroup) startWarmup(ids util.AWSStringSlice) {
+	if targetWarmupTimeout <= 0 {
+		return
+	}
+	if tg.warming == nil {
+		tg.warming = make(map[string]time.Time)
+	}
+	now := time.Now()
+	for _, id := range ids {
+		tg.warming[*id] = now
+		// A health state polled before the target was registered doesn't count.
+		delete(tg.TargetHealth, *id)
+	}
+}
+
+// stopWarmup stops waiting on the deregistered targets ids.
+func (tg *TargetGroup) stopWarmup(ids util.AWSStringSlice) {
+	for _, id := range ids {
+		delete(tg.warming, *id)
+	}
+}
+
+// UpdateWarmupStatus stops waiting on the warming targets last polled healthy, and on the ones that
+// weren't healthy within the warmup timeout. The IDs of the latter are returned. Only the status
+// reported by WarmingUp changes, the target group and its targets are left as they are.
+func (tg *TargetGroup) UpdateWarmupStatus() []string {
+	var timedOut []string
+	for id, registered := range tg.warming {
+		switch {
+		case tg.TargetHealth[id] == elbv2.TargetHealthStateEnumHealthy:
+			delete(tg.warming, id)
+		case time.Since(registered) >= targetWarmupTimeout:
+			delete(tg.warming, id)
+			timedOut = append(timedOut, id)
+		}
+	}
+	sort.Strings(timedOut)
+	return timedOut
+}
+
+// WarmingUp reports whether targets registered with the target group are still waited on to be
+// reported healthy.
+func (tg *TargetGroup) WarmingUp() bool {
+	return len(tg.warming) > 0
+}
+
+// DetectDrift re-describes the CurrentTargetGroup and its targets, returning a description of the
+// health check settings or targets that were changed out of band, or of its deletion.
+func (tg *TargetGroup) DetectDrift() ([]string, error) {
+	if tg.CurrentTargetGroup == nil || tg.DesiredTargetGroup == nil {
+		return nil, nil
+	}
+
+	current, err := awsutil.ALBsvc.DescribeTargetGroup(tg.CurrentTargetGroup.TargetGroupArn)
+	if awsutil.ErrorCode(err) == elbv2.ErrCodeTargetGroupNotFoundException {
+		drifts := []string{fmt.Sprintf("target group %s was deleted", *tg.ID)}
+		tg.CurrentTargetGroup = nil
+		tg.CurrentTargets = nil
+		tg.CurrentStaticTargets = nil
+		tg.CurrentTags = nil
+		return drifts, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var drifts []string
+	if healthCheck(current) != healthCheck(tg.CurrentTargetGroup) {
+		drifts = append(drifts, fmt.Sprintf("health check of target group %s changed to %s", *tg.ID, healthCheck(current)))
+	}
+	tg.CurrentTargetGroup = current
+
+	previous := tg.CurrentTargets
+	if err := tg.refreshTargets(); err != nil {
+		return drifts, err
+	}
+	if removed := previous.Difference(tg.CurrentTargets); len(removed) > 0 {
+		drifts = append(drifts, fmt.Sprintf("targets %s were deregistered from target group %s", log.Prettify(removed), *tg.ID))
+	}
+	if added := tg.CurrentTargets.Difference(previous); len(added) > 0 {
+		drifts = append(drifts, fmt.Sprintf("targets %s were registered to target group %s", log.Prettify(added), *tg.ID))
+	}
+	return drifts, nil
+}
+
+// healthCheck returns the health check settings of a target group in a comparable form.
+func healthCheck(tg *elbv2.TargetGroup) string {
+	return awsutil.Prettify(&elbv2.TargetGroup{
+		HealthCheckIntervalSeconds: tg.HealthCheckIntervalSeconds,
+		HealthCheckPath:            tg.HealthCheckPath,
+		HealthCheckPort:            tg.HealthCheckPort,
+		HealthCheckProtocol:        tg.HealthCheckProtocol,
+		HealthCheckTimeoutSeconds:  tg.HealthCheckTimeoutSeconds,
+		HealthyThresholdCount:      tg.HealthyThresholdCount,
+		Matcher:                    tg.Matcher,
+		UnhealthyThresholdCount:    tg.UnhealthyThresholdCount,
+	})
+}
+
 func (tg *TargetGroup) needsModification() bool {
+	switch {
+	// No target group set currently exists; modification required.
+	case tg.CurrentTargetGroup == nil:
+		return true
+	case tg.healthCheckModified():
+		return true
+	case tg.attributesModified():
+		return true
+	case tg.alarmsModified():
+		return true
+	case *tg.CurrentTargets.Hash() != *tg.DesiredTargets.Hash():
+		log.Infof("Found node list change. Updating target groups.", *tg.IngressID)
+		return true
+	}
+	// These fields require a rebuild and are enforced via TG name hash
+	//	Port *int64 `min:"1" type:"integer"`
+	//	Protocol *string `type:"string" enum:"ProtocolEnum"`
+
+	return false
+}
+
+// healthCheckModified reports whether the health check settings of the CurrentTargetGroup differ
+// from the DesiredTargetGroup's.
+func (tg *TargetGroup) healthCheckModified() bool {
 	ctg := tg.CurrentTargetGroup
 	dtg := tg.DesiredTargetGroup
 
 	switch {
-	// No target group set currently exists; modification required.
-	case ctg == nil:
-		return true
 	case *ctg.HealthCheckIntervalSeconds != *dtg.HealthCheckIntervalSeconds:
 		return true
 	case *ctg.HealthCheckPath != *dtg.HealthCheckPath:
@@ -253,40 +742,175 @@ func (tg *TargetGroup) needsModification() bool {
 		return true
 	case *ctg.UnhealthyThresholdCount != *dtg.UnhealthyThresholdCount:
 		return true
-	case *tg.CurrentTargets.Hash() != *tg.DesiredTargets.Hash():
-		log.Infof("Found node list change. Updating target groups.", *tg.IngressID)
-		return true
 	}
-	// These fields require a rebuild and are enforced via TG name hash
-	//	Port *int64 `min:"1" type:"integer"`
-	//	Protocol *string `type:"string" enum:"ProtocolEnum"`
+	return false
+}
 
+// attributesModified reports whether any of the DesiredAttributes differs from its current value.
+// Only the attributes set through annotations are compared; the others are left as they are.
+func (tg *TargetGroup) attributesModified() bool {
+	// Attributes that weren't described on startup are the ones the ConfigHash tag was made from.
+	if hash, ok := tg.CurrentTags.Get(util.ConfigHashTag); ok && tg.CurrentAttributes == nil {
+		return len(tg.DesiredAttributes) > 0 && hash != targetGroupConfigHash(tg.DesiredAttributes)
+	}
+	current := make(map[string]string)
+	for _, attribute := range tg.CurrentAttributes {
+		current[*attribute.Key] = aws.StringValue(attribute.Value)
+	}
+	for _, attribute := range tg.DesiredAttributes {
+		if value, ok := current[*attribute.Key]; !ok || value != aws.StringValue(attribute.Value) {
+			return true
+		}
+	}
 	return false
 }
 
-// Registers Targets (ec2 instances) to the CurrentTargetGroup, must be called when CurrentTargetGroup == DesiredTargetGroup
-func (tg *TargetGroup) registerTargets() error {
-	targets := []*elbv2.TargetDescription{}
-	for _, target := range tg.DesiredTargets {
-		targets = append(targets, &elbv2.TargetDescription{
-			Id:   target,
-			Port: tg.CurrentTargetGroup.Port,
-		})
+// reconcileTargets registers DesiredTargets (ec2 instances or pod IPs) missing from the
+// CurrentTargetGroup and deregisters CurrentTargets no longer desired. Targets present in both are
+// left alone so their health checks aren't reset. Must be called when CurrentTargetGroup ==
+// DesiredTargetGroup.
+func (tg *TargetGroup) reconcileTargets() error {
+	additions := tg.DesiredTargets.Difference(tg.CurrentTargets)
+	removals := tg.CurrentTargets.Difference(tg.DesiredTargets)
+
+	// Targets outside of the VPC are sent separately as they need an availability zone of "all".
+	staticAdditions := additions.Intersect(tg.DesiredStaticTargets)
+	additions = additions.Difference(staticAdditions)
+	staticRemovals := removals.Intersect(tg.CurrentStaticTargets)
+	removals = removals.Difference(staticRemovals)
+
+	if len(additions) > 0 {
+		in := elbv2.RegisterTargetsInput{
+			TargetGroupArn: tg.CurrentTargetGroup.TargetGroupArn,
+			Targets:        tg.targetDescriptions(additions),
+		}
+		if err := awsutil.ALBsvc.RegisterTargets(in); err != nil {
+			return err
+		}
+		tg.startWarmup(additions)
+		log.Infof("Registered targets: %s", *tg.IngressID, log.Prettify(additions))
+	}
+
+	if len(staticAdditions) > 0 {
+		in := elbv2.RegisterTargetsInput{
+			TargetGroupArn: tg.CurrentTargetGroup.TargetGroupArn,
+			Targets:        tg.targetDescriptions(staticAdditions),
+		}
+		if err := awsutil.ALBsvc.RegisterExternalTargets(in); err != nil {
+			return err
+		}
+		tg.startWarmup(staticAdditions)
+		log.Infof("Registered static targets: %s", *tg.IngressID, log.Prettify(staticAdditions))
+	}
+
+	if len(removals) > 0 {
+		in := elbv2.DeregisterTargetsInput{
+			TargetGroupArn: tg.CurrentTargetGroup.TargetGroupArn,
+			Targets:        tg.targetDescriptions(removals),
+		}
+		if err := awsutil.ALBsvc.DeregisterTargets(in); err != nil {
+			return err
+		}
+		tg.stopWarmup(removals)
+		log.Infof("Deregistered targets: %s", *tg.IngressID, log.Prettify(removals))
 	}
 
+	if len(staticRemovals) > 0 {
+		in := elbv2.DeregisterTargetsInput{
+			TargetGroupArn: tg.CurrentTargetGroup.TargetGroupArn,
+			Targets:        tg.targetDescriptions(staticRemovals),
+		}
+		if err := awsutil.ALBsvc.DeregisterExternalTargets(in); err != nil {
+			return err
+		}
+		tg.stopWarmup(staticRemovals)
+		log.Infof("Deregistered static targets: %s", *tg.IngressID, log.Prettify(staticRemovals))
+	}
+
+	tg.CurrentTargets = tg.DesiredTargets
+	tg.CurrentStaticTargets = tg.DesiredStaticTargets
+	return nil
+}
+
+// AddTarget registers the instance id with the CurrentTargetGroup right away, rather than at the
+// next reconcile, e.g. when it joined the cluster. Target groups in ip mode are left alone. It
+// returns whether the instance was registered.
+func (tg *TargetGroup) AddTarget(id *string) (bool, error) {
+	if tg.CurrentTargetGroup == nil || aws.StringValue(tg.TargetType) != awsutil.TargetTypeInstance {
+		return false, nil
+	}
+	ids := util.AWSStringSlice{id}
+	if len(tg.CurrentTargets.Intersect(ids)) > 0 {
+		return false, nil
+	}
 	in := elbv2.RegisterTargetsInput{
 		TargetGroupArn: tg.CurrentTargetGroup.TargetGroupArn,
-		Targets:        targets,
+		Targets:        tg.targetDescriptions(ids),
 	}
-
 	if err := awsutil.ALBsvc.RegisterTargets(in); err != nil {
-		return err
+		return false, err
 	}
+	tg.startWarmup(ids)
+	// CurrentTargets and DesiredTargets may share their backing array, so neither is appended to.
+	tg.CurrentTargets = append(util.AWSStringSlice{id}, tg.CurrentTargets...)
+	sort.Sort(tg.CurrentTargets)
+	if len(tg.DesiredTargets.Intersect(ids)) == 0 {
+		tg.DesiredTargets = append(util.AWSStringSlice{id}, tg.DesiredTargets...)
+		sort.Sort(tg.DesiredTargets)
+	}
+	log.Infof("Registered target: %s", *tg.IngressID, *id)
+	return true, nil
+}
 
-	tg.CurrentTargets = tg.DesiredTargets
+// RemoveTarget deregisters the instance id from the CurrentTargetGroup right away, rather than at
+// the next reconcile, e.g. when it's about to be terminated. Target groups in ip mode are left
+// alone. It returns whether the instance was deregistered.
+func (tg *TargetGroup) RemoveTarget(id *string) (bool, error) {
+	if tg.CurrentTargetGroup == nil || aws.StringValue(tg.TargetType) != awsutil.TargetTypeInstance {
+		return false, nil
+	}
+	ids := util.AWSStringSlice{id}
+	if len(tg.CurrentTargets.Intersect(ids)) == 0 {
+		return false, nil
+	}
+	in := elbv2.DeregisterTargetsInput{
+		TargetGroupArn: tg.CurrentTargetGroup.TargetGroupArn,
+		Targets:        tg.targetDescriptions(ids),
+	}
+	if err := awsutil.ALBsvc.DeregisterTargets(in); err != nil {
+		return false, err
+	}
+	tg.stopWarmup(ids)
+	tg.CurrentTargets = tg.CurrentTargets.Difference(ids)
+	tg.DesiredTargets = tg.DesiredTargets.Difference(ids)
+	log.Infof("Deregistered target: %s", *tg.IngressID, *id)
+	return true, nil
+}
+
+// refreshTargets replaces CurrentTargets with the targets registered in AWS, so targets added or
+// removed outside of the controller are accounted for when diffing against DesiredTargets.
+func (tg *TargetGroup) refreshTargets() error {
+	targets, err := awsutil.ALBsvc.DescribeTargetGroupTargets(tg.CurrentTargetGroup.TargetGroupArn)
+	if err != nil {
+		log.Errorf("Failed to describe TargetGroup targets. ARN: %s | Error: %s.",
+			*tg.IngressID, *tg.CurrentTargetGroup.TargetGroupArn, err.Error())
+		return err
+	}
+	tg.CurrentTargets = targets
 	return nil
 }
 
+func (tg *TargetGroup) targetDescriptions(ids util.AWSStringSlice) []*elbv2.TargetDescription {
+	targets := []*elbv2.TargetDescription{}
+	for _, id := range ids {
+		targets = append(targets, &elbv2.TargetDescription{
+			Id:   id,
+			Port: tg.CurrentTargetGroup.Port,
+		})
+	}
+	return targets
+}
+
 // TODO: Must be implemented
 func (tg *TargetGroup) online() bool {
 	return true
@@ -0,0 +1,160 @@
+package alb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/awsutil/fake"
+	"github.com/coreos/alb-ingress-controller/controller/config"
+	"github.com/coreos/alb-ingress-controller/controller/util"
+)
+
+func TestTargetGroupName(t *testing.T) {
+	values := map[string]string{
+		"{cluster}":  "prod",
+		"{ingress}":  "default-web",
+		"{service}":  "web_api",
+		"{port}":     "http",
+		"{protocol}": "HTTP",
+	}
+
+	var tests = []struct {
+		template string
+		name     string
+	}{
+		{"{cluster}-{service}", "prod-web-api-0123abcd"},
+		{"{ingress}-{port}-{protocol}", "default-web-http-HTTP-0123abcd"},
+		{"{cluster}-{ingress}-{service}-{port}", "prod-default-web-web-api-0123abcd"[:23] + "-0123abcd"},
+		{"-{service}-", "web-api-0123abcd"},
+		{"___", "0123abcd"},
+	}
+
+	for _, tt := range tests {
+		name := targetGroupName(tt.template, values, "0123abcd")
+		if name != tt.name {
+			t.Errorf("targetGroupName(%q) = %q, want %q", tt.template, name, tt.name)
+		}
+		if len(name) > 32 {
+			t.Errorf("targetGroupName(%q) = %q, longer than 32 characters", tt.template, name)
+		}
+	}
+}
+
+func TestSetTargetGroupNameTemplate(t *testing.T) {
+	defer SetTargetGroupNameTemplate("")
+
+	for _, template := range []string{"", "{cluster}-{service}-{port}", "tg-{ingress}-{protocol}"} {
+		if err := SetTargetGroupNameTemplate(template); err != nil {
+			t.Errorf("SetTargetGroupNameTemplate(%q) returned %v", template, err)
+		}
+	}
+	for _, template := range []string{"{namespace}-{service}", "{cluster", "service}"} {
+		if err := SetTargetGroupNameTemplate(template); err == nil {
+			t.Errorf("SetTargetGroupNameTemplate(%q) returned no error", template)
+		}
+	}
+}
+
+func TestUpdateWarmupStatus(t *testing.T) {
+	defer SetTargetWarmupTimeout(0)
+	SetTargetWarmupTimeout(time.Minute)
+
+	tg := &TargetGroup{}
+	tg.startWarmup(util.AWSStringSlice{aws.String("i-healthy"), aws.String("i-initial"), aws.String("i-slow"), aws.String("i-gone")})
+	tg.warming["i-slow"] = time.Now().Add(-2 * time.Minute)
+	tg.stopWarmup(util.AWSStringSlice{aws.String("i-gone")})
+	tg.TargetHealth = map[string]string{"i-healthy": "healthy", "i-initial": "initial", "i-slow": "unhealthy"}
+
+	if timedOut := tg.UpdateWarmupStatus(); !reflect.DeepEqual(timedOut, []string{"i-slow"}) {
+		t.Errorf("UpdateWarmupStatus() = %v, want [i-slow]", timedOut)
+	}
+	if !tg.WarmingUp() {
+		t.Errorf("WarmingUp() = false while i-initial is warming up")
+	}
+
+	tg.TargetHealth["i-initial"] = "healthy"
+	if timedOut := tg.UpdateWarmupStatus(); len(timedOut) > 0 || tg.WarmingUp() {
+		t.Errorf("UpdateWarmupStatus() = %v, WarmingUp() = %v once every target is healthy", timedOut, tg.WarmingUp())
+	}
+}
+
+func TestUpdateFlapping(t *testing.T) {
+	defer SetTargetFlapDetection(0, 0)
+	SetTargetFlapDetection(3, time.Minute)
+
+	tg := &TargetGroup{ID: aws.String("tg")}
+	now := time.Now()
+	tg.recordTransition("healthy", "unhealthy", now.Add(-2*time.Minute))
+	tg.recordTransition("unhealthy", "healthy", now)
+	tg.recordTransition("initial", "healthy", now)
+	tg.recordTransition("healthy", "draining", now)
+	tg.recordTransition("healthy", "unhealthy", now)
+	if flaps := tg.UpdateFlapping(); flaps != 0 {
+		t.Errorf("UpdateFlapping() = %d with 2 changes within the window, want 0", flaps)
+	}
+
+	tg.recordTransition("unhealthy", "healthy", now)
+	if flaps := tg.UpdateFlapping(); flaps != 3 {
+		t.Errorf("UpdateFlapping() = %d, want 3", flaps)
+	}
+	if flaps := tg.UpdateFlapping(); flaps != 0 {
+		t.Errorf("UpdateFlapping() = %d once reported, want 0", flaps)
+	}
+}
+
+func TestReconcileAlarms(t *testing.T) {
+	clients := fake.New()
+	defer clients.Install()()
+
+	lb := &LoadBalancer{CurrentLoadBalancer: &elbv2.LoadBalancer{
+		LoadBalancerArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/web/50dc6c495c0c9188"),
+	}}
+	tg := &TargetGroup{
+		ID:        aws.String("prod-30080-HTTP-0123abc"),
+		IngressID: aws.String("default-web"),
+		CurrentTargetGroup: &elbv2.TargetGroup{
+			TargetGroupArn: aws.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/prod-30080-HTTP-0123abc/73e2d6bc24d8a067"),
+		},
+		DesiredAlarms: &config.Alarms{
+			UnhealthyHosts: aws.Int64(1),
+			Target5xxRate:  aws.Float64(5),
+			SNSTopicArn:    aws.String("arn:aws:sns:us-east-1:123456789012:alerts"),
+		},
+	}
+	if err := tg.reconcileAlarms(lb); err != nil {
+		t.Fatalf("reconcileAlarms() returned error %v", err)
+	}
+	alarms := clients.CloudWatch.Alarms()
+	if len(alarms) != 2 || *alarms[0].AlarmName != "prod-30080-HTTP-0123abc-5xx-rate" ||
+		*alarms[1].AlarmName != "prod-30080-HTTP-0123abc-unhealthy-hosts" {
+		t.Fatalf("reconcileAlarms(): expected the 5xx-rate and unhealthy-hosts alarms, actual %v", alarms)
+	}
+	if len(alarms[0].Metrics) != 3 || *alarms[0].Metrics[2].Expression != "100 * errors / requests" {
+		t.Errorf("reconcileAlarms(): expected the 5xx rate to be computed from the counts, actual %v", alarms[0].Metrics)
+	}
+	dimensions := alarms[1].Dimensions
+	if len(dimensions) != 2 || *dimensions[0].Value != "app/web/50dc6c495c0c9188" ||
+		*dimensions[1].Value != "targetgroup/prod-30080-HTTP-0123abc/73e2d6bc24d8a067" {
+		t.Errorf("reconcileAlarms(): expected the ARN suffixes as dimensions, actual %v", dimensions)
+	}
+	if aws.StringValueSlice(alarms[1].AlarmActions)[0] != "arn:aws:sns:us-east-1:123456789012:alerts" {
+		t.Errorf("reconcileAlarms(): expected the SNS topic to be notified, actual %v", alarms[1].AlarmActions)
+	}
+
+	// Alarms no longer desired are deleted.
+	tg.DesiredAlarms = &config.Alarms{ResponseTime: aws.Float64(0.5)}
+	if err := tg.reconcileAlarms(lb); err != nil {
+		t.Fatalf("reconcileAlarms() returned error %v", err)
+	}
+	if alarms := clients.CloudWatch.Alarms(); len(alarms) != 1 || *alarms[0].AlarmName != "prod-30080-HTTP-0123abc-response-time" {
+		t.Fatalf("reconcileAlarms(): expected only the response-time alarm, actual %v", alarms)
+	}
+
+	tg.CurrentTags = util.Tags{{Key: aws.String(util.AlarmsHashTag), Value: aws.String(alarmsConfigHash(tg.DesiredAlarms))}}
+	if err := tg.deleteAlarms(); err != nil || len(clients.CloudWatch.Alarms()) != 0 {
+		t.Errorf("deleteAlarms() returned %v, left %v", err, clients.CloudWatch.Alarms())
+	}
+}
@@ -1,20 +1,35 @@
 package alb
 
 import (
+	"time"
+
+	"github.com/coreos/alb-ingress-controller/awsutil"
 	"github.com/coreos/alb-ingress-controller/log"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // TargetGroups is a slice of TargetGroup pointers
 type TargetGroups []*TargetGroup
 
-// LookupBySvc returns the position of a TargetGroup by its SvcName, returning -1 if unfound.
-func (t TargetGroups) LookupBySvc(svc string) int {
+// LookupBySvc returns the position of a TargetGroup by its SvcName and SvcPort, returning -1 if
+// unfound. Target groups being deleted or retiring are only returned when the service has no other.
+func (t TargetGroups) LookupBySvc(svc string, port intstr.IntOrString) int {
+	found := -1
 	for p, v := range t {
-		if v.SvcName == svc {
+		if v.SvcName != svc || v.SvcPort.String() != port.String() {
+			continue
+		}
+		if v.DesiredTargetGroup != nil && v.replacedBy == nil {
 			return p
 		}
+		if found < 0 {
+			found = p
+		}
+	}
+	if found >= 0 {
+		return found
 	}
-	log.Infof("No TG matching service found. SVC %s", "controller", svc)
+	log.Infof("No TG matching service found. SVC %s | Port: %s", "controller", svc, port.String())
 	return -1
 }
 
@@ -41,9 +56,129 @@ func (t TargetGroups) Reconcile(lb *LoadBalancer) error {
 		}
 	}
 
+	// Traffic starts shifting once the replacement of a retiring target group exists.
+	for _, targetgroup := range lb.TargetGroups {
+		if targetgroup.replacedBy != nil && targetgroup.replacedBy.CurrentTargetGroup != nil && targetgroup.retiring.IsZero() {
+			log.Infof("Start shifting traffic from TargetGroup %s to %s.", *targetgroup.IngressID,
+				*targetgroup.ID, *targetgroup.replacedBy.ID)
+			targetgroup.retiring = time.Now()
+		}
+	}
+
+	return nil
+}
+
+// DeleteUnused deletes the target groups no longer desired whose deletion was deferred while
+// listeners or rules forwarded to them. It's called once the listeners and rules were reconciled.
+func (t TargetGroups) DeleteUnused(lb *LoadBalancer) error {
+	for _, targetgroup := range t {
+		if targetgroup.DesiredTargetGroup != nil || targetgroup.CurrentTargetGroup == nil {
+			continue
+		}
+		if err := targetgroup.Reconcile(lb); err != nil {
+			return err
+		}
+		if targetgroup.deleted {
+			i := lb.TargetGroups.Find(targetgroup)
+			lb.TargetGroups = append(lb.TargetGroups[:i], lb.TargetGroups[i+1:]...)
+		}
+	}
 	return nil
 }
 
+// RetireReplaced keeps the target groups no longer desired that are replaced by another target
+// group of the same service, so traffic shifts to the replacement over the period set with
+// SetTargetGroupShiftPeriod. A retiring target group keeps its settings and targets.
+func (t TargetGroups) RetireReplaced() {
+	var retiring []*TargetGroup
+	for _, targetgroup := range t {
+		if targetgroup.DesiredTargetGroup == nil && targetgroup.CurrentTargetGroup != nil && targetGroupShiftPeriod > 0 {
+			retiring = append(retiring, targetgroup)
+			continue
+		}
+		targetgroup.replacedBy, targetgroup.retiring = nil, time.Time{}
+	}
+
+	for _, targetgroup := range retiring {
+		var replacement *TargetGroup
+		for _, v := range t {
+			if v.DesiredTargetGroup != nil && v.replacedBy == nil && v.SvcName == targetgroup.SvcName &&
+				v.SvcPort.String() == targetgroup.SvcPort.String() {
+				replacement = v
+				break
+			}
+		}
+		// The shift starts over when the replacement changed.
+		if replacement != targetgroup.replacedBy {
+			targetgroup.retiring = time.Time{}
+		}
+		targetgroup.replacedBy = replacement
+		if replacement == nil {
+			continue
+		}
+		targetgroup.DesiredTargetGroup = targetgroup.CurrentTargetGroup
+		targetgroup.DesiredTags = targetgroup.CurrentTags
+		targetgroup.DesiredTargets = targetgroup.CurrentTargets
+		targetgroup.DesiredStaticTargets = targetgroup.CurrentStaticTargets
+		targetgroup.DesiredAttributes = targetgroup.CurrentAttributes
+	}
+}
+
+// ShiftingTraffic reports whether traffic is shifting from one of the target groups to its
+// replacement, or is about to.
+func (t TargetGroups) ShiftingTraffic() bool {
+	for _, targetgroup := range t {
+		if targetgroup.replacedBy != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// WarmingUp reports whether targets registered with one of the target groups are still waited on
+// to be reported healthy.
+func (t TargetGroups) WarmingUp() bool {
+	for _, targetgroup := range t {
+		if !targetgroup.deleted && targetgroup.WarmingUp() {
+			return true
+		}
+	}
+	return false
+}
+
+// shiftWeights returns the weights of a forward action to the target groups of a service while
+// traffic shifts from a retiring target group to its replacement. It's nil when the service is
+// served by a single target group. The replacement gets a tenth more of the requests every tenth
+// of the shift period.
+func (t TargetGroups) shiftWeights(svc string, port intstr.IntOrString) []awsutil.TargetGroupWeight {
+	for _, v := range t {
+		if v.SvcName != svc || v.SvcPort.String() != port.String() || v.replacedBy == nil ||
+			v.retiring.IsZero() || v.shifted() || v.CurrentTargetGroup == nil || v.replacedBy.CurrentTargetGroup == nil {
+			continue
+		}
+		weight := 10 * int64(10*time.Since(v.retiring)/targetGroupShiftPeriod)
+		return []awsutil.TargetGroupWeight{
+			{TargetGroupArn: *v.replacedBy.CurrentTargetGroup.TargetGroupArn, Weight: weight},
+			{TargetGroupArn: *v.CurrentTargetGroup.TargetGroupArn, Weight: 100 - weight},
+		}
+	}
+	return nil
+}
+
+// DetectDrift re-describes every target group inside this TargetGroups instance, returning a
+// description of each out of band change.
+func (t TargetGroups) DetectDrift() ([]string, error) {
+	var drifts []string
+	for _, targetgroup := range t {
+		tgDrifts, err := targetgroup.DetectDrift()
+		if err != nil {
+			return drifts, err
+		}
+		drifts = append(drifts, tgDrifts...)
+	}
+	return drifts, nil
+}
+
 // StripDesiredState removes the DesiredTags, DesiredTargetGroup, and DesiredTargets from all TargetGroups
 func (t TargetGroups) StripDesiredState() {
 	for _, targetgroup := range t {
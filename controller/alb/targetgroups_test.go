@@ -0,0 +1,212 @@
+package alb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/awsutil/fake"
+	"github.com/coreos/alb-ingress-controller/controller/util"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// testTargetGroup returns a target group of the service svc on port 80, existing with the ARN arn
+// unless it's empty, and desired unless it's retired.
+func testTargetGroup(svc, arn string, retired bool) *TargetGroup {
+	tg := &TargetGroup{
+		ID:        aws.String(svc + "-" + arn),
+		IngressID: aws.String("default-app"),
+		SvcName:   svc,
+		SvcPort:   intstr.FromInt(80),
+	}
+	if arn != "" {
+		tg.CurrentTargetGroup = &elbv2.TargetGroup{TargetGroupArn: aws.String(arn), Port: aws.Int64(30080)}
+		tg.CurrentTags = util.Tags{{Key: aws.String("ServiceName"), Value: aws.String(svc)}}
+		tg.CurrentTargets = util.AWSStringSlice{aws.String("i-1")}
+	}
+	if !retired {
+		tg.DesiredTargetGroup = &elbv2.TargetGroup{Port: aws.Int64(30081)}
+	}
+	return tg
+}
+
+func TestRetireReplaced(t *testing.T) {
+	defer SetTargetGroupShiftPeriod(0)
+
+	var tests = []struct {
+		name        string
+		period      time.Duration
+		replacement *TargetGroup // the target group of the service besides the retired one, if any
+		replacedBy  bool         // whether the retired target group is expected to be replaced by it
+		restarted   bool         // whether a shift in progress is expected to start over
+	}{
+		{"replaced by a target group of the same service", time.Minute, testTargetGroup("web", "", false), true, false},
+		{"without a shift period", 0, testTargetGroup("web", "", false), false, true},
+		{"without a replacement", time.Minute, testTargetGroup("api", "", false), false, true},
+		{"by a retiring target group", time.Minute, testTargetGroup("web", "arn-2", true), false, true},
+	}
+	for _, tt := range tests {
+		SetTargetGroupShiftPeriod(tt.period)
+		retired := testTargetGroup("web", "arn-1", true)
+		started := time.Now().Add(-time.Second)
+		retired.replacedBy, retired.retiring = tt.replacement, started
+		TargetGroups{retired, tt.replacement}.RetireReplaced()
+
+		if (retired.replacedBy == tt.replacement) != tt.replacedBy {
+			t.Errorf("%s: expected replacedBy %v, actual %v", tt.name, tt.replacedBy, retired.replacedBy != nil)
+		}
+		if retired.retiring.IsZero() != tt.restarted {
+			t.Errorf("%s: expected the shift to restart %v, actual %v", tt.name, tt.restarted, retired.retiring.IsZero())
+		}
+		// A retiring target group is kept as it is.
+		if tt.replacedBy && (retired.DesiredTargetGroup != retired.CurrentTargetGroup ||
+			*retired.DesiredTargets.Hash() != *retired.CurrentTargets.Hash() || *retired.DesiredTags.Hash() != *retired.CurrentTags.Hash()) {
+			t.Errorf("%s: expected the desired state to be the current one", tt.name)
+		}
+	}
+
+	// Target groups that are desired again stop retiring.
+	SetTargetGroupShiftPeriod(time.Minute)
+	tg := testTargetGroup("web", "arn-1", false)
+	tg.replacedBy, tg.retiring = testTargetGroup("web", "", false), time.Now()
+	TargetGroups{tg}.RetireReplaced()
+	if tg.replacedBy != nil || !tg.retiring.IsZero() {
+		t.Errorf("RetireReplaced(): expected a desired target group not to retire")
+	}
+}
+
+func TestShiftWeights(t *testing.T) {
+	defer SetTargetGroupShiftPeriod(0)
+	SetTargetGroupShiftPeriod(100 * time.Second)
+
+	var tests = []struct {
+		name        string
+		elapsed     time.Duration // since traffic started shifting, none while 0
+		svc         string
+		replacement string // ARN of the replacement, not yet created while empty
+		expected    []awsutil.TargetGroupWeight
+	}{
+		{"right after the shift started", time.Second, "web", "arn-new",
+			[]awsutil.TargetGroupWeight{{TargetGroupArn: "arn-new", Weight: 0}, {TargetGroupArn: "arn-old", Weight: 100}}},
+		{"35 seconds into a 100 second shift", 35 * time.Second, "web", "arn-new",
+			[]awsutil.TargetGroupWeight{{TargetGroupArn: "arn-new", Weight: 30}, {TargetGroupArn: "arn-old", Weight: 70}}},
+		{"once the shift completed", 100 * time.Second, "web", "arn-new", nil},
+		{"before the shift started", 0, "web", "arn-new", nil},
+		{"before the replacement exists", time.Second, "web", "", nil},
+		{"of another service", time.Second, "api", "arn-new", nil},
+	}
+	for _, tt := range tests {
+		retiring := testTargetGroup("web", "arn-old", false)
+		retiring.replacedBy = testTargetGroup("web", tt.replacement, false)
+		if tt.elapsed > 0 {
+			retiring.retiring = time.Now().Add(-tt.elapsed)
+		}
+		weights := TargetGroups{retiring, retiring.replacedBy}.shiftWeights(tt.svc, intstr.FromInt(80))
+		if !reflect.DeepEqual(weights, tt.expected) {
+			t.Errorf("shiftWeights() %s: expected %v, actual %v", tt.name, tt.expected, weights)
+		}
+	}
+}
+
+func TestInUse(t *testing.T) {
+	forward := func(arn string) []*elbv2.Action {
+		return []*elbv2.Action{{Type: aws.String("forward"), TargetGroupArn: aws.String(arn)}}
+	}
+	weighted := []awsutil.TargetGroupWeight{{TargetGroupArn: "arn-new", Weight: 50}, {TargetGroupArn: "arn-1", Weight: 50}}
+
+	var tests = []struct {
+		name     string
+		lb       *LoadBalancer
+		expected bool
+	}{
+		{"forwarded to by a default action", &LoadBalancer{Listeners: Listeners{
+			{CurrentListener: &elbv2.Listener{DefaultActions: forward("arn-1")}},
+		}}, true},
+		{"weighted in a default action", &LoadBalancer{Listeners: Listeners{
+			{CurrentListener: &elbv2.Listener{DefaultActions: forward("arn-new")}, CurrentWeights: weighted},
+		}}, true},
+		{"forwarded to by a rule", &LoadBalancer{Listeners: Listeners{
+			{CurrentListener: &elbv2.Listener{DefaultActions: forward("arn-2")}, Rules: Rules{
+				{CurrentRule: &elbv2.Rule{Actions: forward("arn-1")}},
+			}},
+		}}, true},
+		{"weighted in a rule", &LoadBalancer{Listeners: Listeners{
+			{CurrentListener: &elbv2.Listener{DefaultActions: forward("arn-2")}, Rules: Rules{
+				{CurrentRule: &elbv2.Rule{Actions: forward("arn-new")}, CurrentWeights: weighted},
+			}},
+		}}, true},
+		{"forwarded to by a deleted rule", &LoadBalancer{Listeners: Listeners{
+			{CurrentListener: &elbv2.Listener{DefaultActions: forward("arn-2")}, Rules: Rules{
+				{CurrentRule: &elbv2.Rule{Actions: forward("arn-1")}, deleted: true},
+			}},
+		}}, false},
+		{"forwarded to by a deleted listener", &LoadBalancer{Listeners: Listeners{
+			{CurrentListener: &elbv2.Listener{DefaultActions: forward("arn-1")}, deleted: true},
+		}}, false},
+		{"forwarded to by a listener not yet created", &LoadBalancer{Listeners: Listeners{
+			{DesiredListener: &elbv2.Listener{DefaultActions: forward("arn-1")}},
+		}}, false},
+		{"forwarded to by the listeners of a deleted ALB", &LoadBalancer{Deleted: true, Listeners: Listeners{
+			{CurrentListener: &elbv2.Listener{DefaultActions: forward("arn-1")}},
+		}}, false},
+	}
+	tg := testTargetGroup("web", "arn-1", true)
+	for _, tt := range tests {
+		if inUse := tg.inUse(tt.lb); inUse != tt.expected {
+			t.Errorf("inUse() %s: expected %v, actual %v", tt.name, tt.expected, inUse)
+		}
+	}
+}
+
+func TestDeleteUnused(t *testing.T) {
+	clients := fake.New()
+	defer clients.Install()()
+	arn := func(name string) string {
+		o, err := clients.ELBV2.CreateTargetGroup(&elbv2.CreateTargetGroupInput{Name: aws.String(name)})
+		if err != nil {
+			t.Fatalf("CreateTargetGroup(%s) returned error %v", name, err)
+		}
+		return *o.TargetGroups[0].TargetGroupArn
+	}
+
+	var tests = []struct {
+		name    string
+		tg      *TargetGroup
+		deleted bool
+	}{
+		{"no longer desired", testTargetGroup("web", arn("unused"), true), true},
+		{"still desired", testTargetGroup("api", arn("desired"), false), false},
+		{"still forwarded to", testTargetGroup("admin", arn("forwarded"), true), false},
+		{"never created", testTargetGroup("docs", "", true), false},
+	}
+	var tgs TargetGroups
+	for _, tt := range tests {
+		tgs = append(tgs, tt.tg)
+	}
+	lb := &LoadBalancer{IngressID: aws.String("default-app"), TargetGroups: tgs, Listeners: Listeners{{
+		CurrentListener: &elbv2.Listener{DefaultActions: []*elbv2.Action{
+			{Type: aws.String("forward"), TargetGroupArn: tests[2].tg.CurrentTargetGroup.TargetGroupArn},
+		}},
+	}}}
+	if err := lb.TargetGroups.DeleteUnused(lb); err != nil {
+		t.Fatalf("DeleteUnused() returned error %v", err)
+	}
+
+	for _, tt := range tests {
+		if (lb.TargetGroups.Find(tt.tg) < 0) != tt.deleted {
+			t.Errorf("DeleteUnused() %s: expected deleted %v, actual %v", tt.name, tt.deleted, lb.TargetGroups.Find(tt.tg) < 0)
+		}
+		if tt.tg.CurrentTargetGroup == nil {
+			continue
+		}
+		_, err := clients.ELBV2.DescribeTargetGroups(&elbv2.DescribeTargetGroupsInput{
+			TargetGroupArns: []*string{tt.tg.CurrentTargetGroup.TargetGroupArn},
+		})
+		if (err != nil) != tt.deleted {
+			t.Errorf("DeleteUnused() %s: expected the target group to be deleted %v, actual %v", tt.name, tt.deleted, err != nil)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/coreos/alb-ingress-controller/controller/config"
+	"github.com/coreos/alb-ingress-controller/log"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	extensions "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+// AlbConfigs are a ThirdPartyResource, see examples/alb-config.yaml. The vendored client-go has no
+// typed client for them, so they're listed through the raw REST client.
+const (
+	albConfigGroup    = "alb.ingress.kubernetes.io"
+	albConfigVersion  = "v1"
+	albConfigResource = "albconfigs"
+)
+
+// albConfig is an AlbConfig resource.
+type albConfig struct {
+	Metadata metav1.ObjectMeta    `json:"metadata"`
+	Spec     config.AlbConfigSpec `json:"spec"`
+}
+
+// albConfigList is the response to listing AlbConfig resources.
+type albConfigList struct {
+	Items []albConfig `json:"items"`
+}
+
+// syncAlbConfigs lists the AlbConfigs of the albConfigNamespace, replacing the ones ingresses are
+// built with. Ingresses pick up changed AlbConfigs on their next sync.
+func (ac *ALBController) syncAlbConfigs() {
+	body, err := ac.client.Extensions().RESTClient().Get().
+		AbsPath("/apis", albConfigGroup, albConfigVersion, "namespaces", ac.albConfigNamespace, albConfigResource).
+		DoRaw()
+	if err != nil {
+		log.Errorf("Failed to list the AlbConfigs of namespace %s. Error: %s", "controller", ac.albConfigNamespace, err.Error())
+		return
+	}
+	var list albConfigList
+	if err := json.Unmarshal(body, &list); err != nil {
+		log.Errorf("Failed to decode the AlbConfigs of namespace %s. Error: %s", "controller", ac.albConfigNamespace, err.Error())
+		return
+	}
+
+	configs := make(map[string]*config.AlbConfigSpec)
+	for i := range list.Items {
+		configs[list.Items[i].Metadata.Name] = &list.Items[i].Spec
+	}
+	ac.albConfigMutex.Lock()
+	defer ac.albConfigMutex.Unlock()
+	ac.albConfigs = configs
+}
+
+// albConfigAnnotations returns the annotations of ingress with the ones of the AlbConfig named by
+// its alb-config annotation set over them. The annotations of ingresses without one are returned as
+// they are. An error is returned when the AlbConfig doesn't exist.
+func (ac *ALBController) albConfigAnnotations(ingress *extensions.Ingress) (map[string]string, error) {
+	name := ingress.Annotations[config.AlbConfigKey]
+	if name == "" {
+		return ingress.Annotations, nil
+	}
+	if ac.albConfigNamespace == "" {
+		return nil, fmt.Errorf("%s %s can't be used, ALB_CONFIG_NAMESPACE isn't set", config.AlbConfigKey, name)
+	}
+
+	ac.albConfigMutex.Lock()
+	configs := ac.albConfigs
+	ac.albConfigMutex.Unlock()
+	if configs == nil {
+		return nil, fmt.Errorf("the AlbConfigs of namespace %s weren't listed yet", ac.albConfigNamespace)
+	}
+	spec, ok := configs[name]
+	if !ok {
+		return nil, fmt.Errorf("AlbConfig %s doesn't exist in namespace %s", name, ac.albConfigNamespace)
+	}
+	annotations, err := config.ApplyAlbConfig(ingress.Annotations, spec)
+	if err != nil {
+		return nil, fmt.Errorf("AlbConfig %s: %s", name, err.Error())
+	}
+	return annotations, nil
+}
@@ -0,0 +1,293 @@
+package controller
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/controller/config"
+	"github.com/coreos/alb-ingress-controller/log"
+	extensions "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+// Names of the built-in certificate resolvers
+const (
+	// CertificateResolverAnnotation picks the certificates of a spec.tls block among the ones the
+	// certificate-arn annotation lists.
+	CertificateResolverAnnotation = "annotation"
+	// CertificateResolverSecret imports the TLS secret of a spec.tls block into ACM, when
+	// SYNC_TLS_SECRETS is enabled.
+	CertificateResolverSecret = "secret"
+	// CertificateResolverACM discovers the certificates of a spec.tls block among the issued ACM
+	// certificates.
+	CertificateResolverACM = "acm"
+	// CertificateResolverIAM discovers the certificates of a spec.tls block among the IAM server
+	// certificates.
+	CertificateResolverIAM = "iam"
+	// CertificateResolverRequest requests a DNS validated ACM certificate for the hosts of a
+	// spec.tls block, creating its validation records in Route 53.
+	CertificateResolverRequest = "request"
+)
+
+// defaultCertificateResolvers is the chain used when CERTIFICATE_RESOLVERS isn't set. Discovering
+// certificates lists every issued ACM certificate and turns the default listener of ingresses
+// with spec.tls into HTTPS, so the acm and iam resolvers have to be opted in to.
+const defaultCertificateResolvers = "annotation,secret"
+
+// CertificateResolver selects the certificates serving the hosts of a spec.tls block. Resolvers
+// are chained, and the first one that resolves a block stops the chain for it. Resolvers other
+// than the built-in ones are registered with RegisterCertificateResolver by programs embedding the
+// controller, e.g. to issue certificates from a private PKI.
+type CertificateResolver interface {
+	// Name identifies the resolver in the CERTIFICATE_RESOLVERS setting.
+	Name() string
+	// Resolve returns the ARNs of the certificates for tls, a spec.tls block of ingress. ok is
+	// false when the resolver doesn't apply to the block, so the next resolver of the chain is
+	// tried.
+	Resolve(ingress *extensions.Ingress, tls extensions.IngressTLS) (arns []string, ok bool, err error)
+}
+
+var (
+	certificateResolversMu sync.Mutex
+	certificateResolvers   = map[string]CertificateResolver{}
+)
+
+// builtinCertificateResolvers are the names reserved for the resolvers of the controller.
+var builtinCertificateResolvers = []string{
+	CertificateResolverAnnotation, CertificateResolverSecret, CertificateResolverACM, CertificateResolverIAM,
+	CertificateResolverRequest,
+}
+
+// RegisterCertificateResolver makes r available under its name to CERTIFICATE_RESOLVERS. It panics
+// when a resolver of the same name is registered already.
+func RegisterCertificateResolver(r CertificateResolver) {
+	certificateResolversMu.Lock()
+	defer certificateResolversMu.Unlock()
+	_, ok := certificateResolvers[r.Name()]
+	for _, name := range builtinCertificateResolvers {
+		ok = ok || name == r.Name()
+	}
+	if ok {
+		panic(fmt.Sprintf("certificate resolver %s is registered twice", r.Name()))
+	}
+	certificateResolvers[r.Name()] = r
+}
+
+// newCertificateResolvers returns the chain of resolvers named by the comma separated names, in
+// order. An empty names is the default chain.
+func (ac *ALBController) newCertificateResolvers(names string) ([]CertificateResolver, error) {
+	if strings.TrimSpace(names) == "" {
+		names = defaultCertificateResolvers
+	}
+	certificateResolversMu.Lock()
+	defer certificateResolversMu.Unlock()
+	available := map[string]CertificateResolver{
+		CertificateResolverAnnotation: annotationResolver{},
+		CertificateResolverSecret:     secretResolver{ac},
+		CertificateResolverACM:        acmResolver{},
+		CertificateResolverIAM:        iamResolver{},
+		CertificateResolverRequest:    requestResolver{ac},
+	}
+	for name, r := range certificateResolvers {
+		available[name] = r
+	}
+
+	var chain []CertificateResolver
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		r, ok := available[name]
+		if !ok {
+			var known []string
+			for n := range available {
+				known = append(known, n)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("certificate resolver %s is unknown, must be one of %s", name, strings.Join(known, ", "))
+		}
+		chain = append(chain, r)
+	}
+	return chain, nil
+}
+
+// requestsCertificates reports whether the chain requests certificates from ACM.
+func (ac *ALBController) requestsCertificates() bool {
+	for _, r := range ac.certificateResolvers {
+		if r.Name() == CertificateResolverRequest {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveCertificates returns the ARNs of the certificates for a spec.tls block, from the first
+// resolver of the chain that resolves it.
+func (ac *ALBController) resolveCertificates(ingress *extensions.Ingress, tls extensions.IngressTLS) ([]string, error) {
+	for _, r := range ac.certificateResolvers {
+		arns, ok, err := r.Resolve(ingress, tls)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return arns, nil
+		}
+	}
+	if len(tls.Hosts) > 0 {
+		ingressID := fmt.Sprintf("%s-%s", ingress.Namespace, ingress.Name)
+		log.Warnf("No certificate found for the %s hosts of spec.tls.", ingressID, strings.Join(tls.Hosts, ", "))
+	}
+	return nil, nil
+}
+
+// matchHosts returns the ARNs of the certificates covering the hosts of a spec.tls block, warning
+// about the hosts none covers.
+func matchHosts(ingress *extensions.Ingress, tls extensions.IngressTLS, candidates []*acm.CertificateDetail) []string {
+	ingressID := fmt.Sprintf("%s-%s", ingress.Namespace, ingress.Name)
+	var arns []string
+	for _, host := range tls.Hosts {
+		certificate := matchCertificate(candidates, host)
+		if certificate == nil {
+			log.Warnf("No certificate found for the %s host of spec.tls.", ingressID, host)
+			continue
+		}
+		arns = append(arns, *certificate.CertificateArn)
+	}
+	return arns
+}
+
+// annotationResolver resolves every block of an ingress with the certificate-arn annotation,
+// picking the certificates among the ACM certificates the annotation lists.
+type annotationResolver struct{}
+
+func (annotationResolver) Name() string {
+	return CertificateResolverAnnotation
+}
+
+func (annotationResolver) Resolve(ingress *extensions.Ingress, tls extensions.IngressTLS) ([]string, bool, error) {
+	listed := config.CertificateArns(ingress.Annotations)
+	if len(listed) == 0 {
+		return nil, false, nil
+	}
+	if len(tls.Hosts) == 0 {
+		return nil, true, nil
+	}
+	candidates, err := candidateCertificates(listed)
+	if err != nil {
+		return nil, false, err
+	}
+	return matchHosts(ingress, tls, candidates), true, nil
+}
+
+// secretResolver resolves the blocks naming a TLS secret by importing it into ACM.
+type secretResolver struct {
+	ac *ALBController
+}
+
+func (secretResolver) Name() string {
+	return CertificateResolverSecret
+}
+
+func (r secretResolver) Resolve(ingress *extensions.Ingress, tls extensions.IngressTLS) ([]string, bool, error) {
+	if tls.SecretName == "" || !r.ac.syncTLSSecrets {
+		return nil, false, nil
+	}
+	arn, err := r.ac.syncTLSSecret(ingress.Namespace, tls.SecretName)
+	if err != nil {
+		return nil, false, err
+	}
+	return []string{*arn}, true, nil
+}
+
+// acmResolver resolves the blocks with a host covered by an issued ACM certificate.
+type acmResolver struct{}
+
+func (acmResolver) Name() string {
+	return CertificateResolverACM
+}
+
+func (acmResolver) Resolve(ingress *extensions.Ingress, tls extensions.IngressTLS) ([]string, bool, error) {
+	if len(tls.Hosts) == 0 {
+		return nil, false, nil
+	}
+	candidates, err := candidateCertificates(nil)
+	if err != nil {
+		return nil, false, err
+	}
+	return resolvedHosts(ingress, tls, candidates)
+}
+
+// iamResolver resolves the blocks with a host covered by an unexpired IAM server certificate.
+type iamResolver struct{}
+
+func (iamResolver) Name() string {
+	return CertificateResolverIAM
+}
+
+func (iamResolver) Resolve(ingress *extensions.Ingress, tls extensions.IngressTLS) ([]string, bool, error) {
+	if len(tls.Hosts) == 0 {
+		return nil, false, nil
+	}
+	serverCertificates, err := awsutil.IAMsvc.DescribeServerCertificates()
+	if err != nil {
+		return nil, false, fmt.Errorf("Unable to discover IAM server certificates: %s", err.Error())
+	}
+	var candidates []*acm.CertificateDetail
+	for _, c := range serverCertificates {
+		block, _ := pem.Decode([]byte(aws.StringValue(c.CertificateBody)))
+		if block == nil {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil || certificate.NotAfter.Before(time.Now()) {
+			continue
+		}
+		candidates = append(candidates, &acm.CertificateDetail{
+			CertificateArn:          c.ServerCertificateMetadata.Arn,
+			DomainName:              aws.String(certificate.Subject.CommonName),
+			NotAfter:                aws.Time(certificate.NotAfter),
+			SubjectAlternativeNames: aws.StringSlice(certificate.DNSNames),
+		})
+	}
+	return resolvedHosts(ingress, tls, candidates)
+}
+
+// resolvedHosts resolves a block with the candidates covering its hosts. The block is left to the
+// next resolver when no candidate covers any of them.
+func resolvedHosts(ingress *extensions.Ingress, tls extensions.IngressTLS, candidates []*acm.CertificateDetail) ([]string, bool, error) {
+	for _, host := range tls.Hosts {
+		if matchCertificate(candidates, host) != nil {
+			return matchHosts(ingress, tls, candidates), true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// requestResolver resolves every block with hosts by requesting a certificate for them from ACM.
+// As it applies to every such block, it belongs at the end of the chain.
+type requestResolver struct {
+	ac *ALBController
+}
+
+func (requestResolver) Name() string {
+	return CertificateResolverRequest
+}
+
+func (r requestResolver) Resolve(ingress *extensions.Ingress, tls extensions.IngressTLS) ([]string, bool, error) {
+	if len(tls.Hosts) == 0 {
+		return nil, false, nil
+	}
+	if awsutil.Route53svc == nil {
+		return nil, false, fmt.Errorf("Requested certificates are validated through Route 53, which DISABLE_ROUTE53 disables")
+	}
+	arn, err := r.ac.requestCertificate(ingress.Namespace, tls.Hosts)
+	if err != nil {
+		return nil, false, err
+	}
+	return []string{*arn}, true, nil
+}
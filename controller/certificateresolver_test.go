@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/awsutil/fake"
+	extensions "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+// stubResolver resolves every block with arns, unless it doesn't apply or fails with err.
+type stubResolver struct {
+	name    string
+	arns    []string
+	applies bool
+	err     error
+}
+
+func (r stubResolver) Name() string {
+	return r.name
+}
+
+func (r stubResolver) Resolve(ingress *extensions.Ingress, tls extensions.IngressTLS) ([]string, bool, error) {
+	return r.arns, r.applies, r.err
+}
+
+func TestNewCertificateResolvers(t *testing.T) {
+	certificateResolversMu.Lock()
+	certificateResolvers["stub"] = stubResolver{name: "stub"}
+	certificateResolversMu.Unlock()
+	defer func() {
+		certificateResolversMu.Lock()
+		delete(certificateResolvers, "stub")
+		certificateResolversMu.Unlock()
+	}()
+
+	var tests = []struct {
+		names    string
+		expected []string
+		err      bool
+	}{
+		{"", []string{CertificateResolverAnnotation, CertificateResolverSecret}, false},
+		{"iam, annotation", []string{CertificateResolverIAM, CertificateResolverAnnotation}, false},
+		{"stub,acm", []string{"stub", CertificateResolverACM}, false},
+		{"annotation,vault", nil, true},
+	}
+	ac := &ALBController{}
+	for _, tt := range tests {
+		chain, err := ac.newCertificateResolvers(tt.names)
+		if (err != nil) != tt.err {
+			t.Errorf("newCertificateResolvers(%q): expected error %v, actual %v", tt.names, tt.err, err)
+			continue
+		}
+		var names []string
+		for _, r := range chain {
+			names = append(names, r.Name())
+		}
+		if !reflect.DeepEqual(names, tt.expected) {
+			t.Errorf("newCertificateResolvers(%q): expected %v, actual %v", tt.names, tt.expected, names)
+		}
+	}
+}
+
+func TestResolveCertificates(t *testing.T) {
+	failure := errors.New("PKI unavailable")
+	var tests = []struct {
+		chain    []CertificateResolver
+		expected []string
+		err      error
+	}{
+		// The first resolver that applies to a block stops the chain.
+		{[]CertificateResolver{
+			stubResolver{name: "a"},
+			stubResolver{name: "b", arns: []string{"arn-b"}, applies: true},
+			stubResolver{name: "c", arns: []string{"arn-c"}, applies: true},
+		}, []string{"arn-b"}, nil},
+		// An error stops the chain too.
+		{[]CertificateResolver{
+			stubResolver{name: "a", err: failure},
+			stubResolver{name: "b", arns: []string{"arn-b"}, applies: true},
+		}, nil, failure},
+		// Blocks no resolver applies to get no certificates.
+		{[]CertificateResolver{stubResolver{name: "a"}}, nil, nil},
+	}
+	ingress := &extensions.Ingress{}
+	ingress.Namespace, ingress.Name = "default", "app"
+	tls := extensions.IngressTLS{Hosts: []string{"www.example.com"}}
+	for _, tt := range tests {
+		ac := &ALBController{certificateResolvers: tt.chain}
+		arns, err := ac.resolveCertificates(ingress, tls)
+		if err != tt.err || !reflect.DeepEqual(arns, tt.expected) {
+			t.Errorf("resolveCertificates(): expected %v, %v, actual %v, %v", tt.expected, tt.err, arns, err)
+		}
+	}
+}
+
+func TestIAMResolver(t *testing.T) {
+	clients := fake.New()
+	defer clients.Install()()
+	arn := clients.IAM.AddServerCertificate("www", selfSignedCertificate(t, "www.example.com", time.Now().Add(time.Hour)))
+	clients.IAM.AddServerCertificate("expired", selfSignedCertificate(t, "api.example.com", time.Now().Add(-time.Hour)))
+
+	ingress := &extensions.Ingress{}
+	ingress.Namespace, ingress.Name = "default", "app"
+	var tests = []struct {
+		hosts    []string
+		expected []string
+		ok       bool
+	}{
+		{[]string{"www.example.com"}, []string{arn}, true},
+		// Expired certificates are left out, so the block is left to the next resolver.
+		{[]string{"api.example.com"}, nil, false},
+		{nil, nil, false},
+	}
+	for _, tt := range tests {
+		arns, ok, err := iamResolver{}.Resolve(ingress, extensions.IngressTLS{Hosts: tt.hosts})
+		if err != nil {
+			t.Fatalf("Resolve(%v) returned error %v", tt.hosts, err)
+		}
+		if ok != tt.ok || !reflect.DeepEqual(arns, tt.expected) {
+			t.Errorf("Resolve(%v): expected %v, %v, actual %v, %v", tt.hosts, tt.expected, tt.ok, arns, ok)
+		}
+	}
+}
+
+func TestRequestResolver(t *testing.T) {
+	clients := fake.New()
+	defer clients.Install()()
+	zoneID := clients.Route53.AddHostedZone("example.com")
+
+	ingress := &extensions.Ingress{}
+	ingress.Namespace, ingress.Name = "default", "app"
+	tls := extensions.IngressTLS{Hosts: []string{"www.example.com", "WWW.example.com", "*.example.com"}}
+	newController := func() *ALBController {
+		return &ALBController{
+			clusterName:           aws.String("cluster"),
+			controllerID:          "controller",
+			requestedCertificates: make(map[string]*requestedCertificate),
+		}
+	}
+
+	// The certificate is pending validation until its records are found.
+	ac := newController()
+	if _, _, err := (requestResolver{ac}).Resolve(ingress, tls); err == nil {
+		t.Fatalf("Resolve(): expected an error while the certificate is pending validation")
+	}
+	var records []string
+	for _, record := range clients.Route53.Records(zoneID) {
+		records = append(records, *record.Name+" "+*record.Type)
+	}
+	expected := []string{"_b.example.com. CNAME", "_f.www.example.com. CNAME"}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("Resolve(): expected validation records %v, actual %v", expected, records)
+	}
+
+	// The certificate is found again after a restart instead of being requested twice.
+	ac = newController()
+	if _, _, err := (requestResolver{ac}).Resolve(ingress, tls); err == nil {
+		t.Fatalf("Resolve(): expected an error while the certificate is pending validation")
+	}
+	var certificates []*acm.CertificateSummary
+	clients.ACM.ListCertificatesPages(&acm.ListCertificatesInput{}, func(page *acm.ListCertificatesOutput, lastPage bool) bool {
+		certificates = append(certificates, page.CertificateSummaryList...)
+		return true
+	})
+	if len(certificates) != 1 {
+		t.Fatalf("Resolve(): expected one requested certificate, actual %v", certificates)
+	}
+	arn := *certificates[0].CertificateArn
+
+	clients.ACM.Issue(arn)
+	arns, ok, err := (requestResolver{ac}).Resolve(ingress, tls)
+	if err != nil || !ok || !reflect.DeepEqual(arns, []string{arn}) {
+		t.Errorf("Resolve(): expected %v, actual %v, %v, error %v", []string{arn}, arns, ok, err)
+	}
+
+	// Blocks without hosts are left to the next resolver, and certificates can't be validated
+	// without Route 53.
+	if _, ok, err := (requestResolver{ac}).Resolve(ingress, extensions.IngressTLS{}); ok || err != nil {
+		t.Errorf("Resolve(): expected a block without hosts not to be resolved, actual %v, error %v", ok, err)
+	}
+	awsutil.Route53svc = nil
+	if _, _, err := (requestResolver{newController()}).Resolve(ingress, tls); err == nil {
+		t.Errorf("Resolve(): expected an error without Route 53")
+	}
+}
+
+// selfSignedCertificate returns a PEM encoded certificate for host, expiring at notAfter.
+func selfSignedCertificate(t *testing.T, host string, notAfter time.Time) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() returned error %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() returned error %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
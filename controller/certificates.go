@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/log"
+	"github.com/prometheus/client_golang/prometheus"
+	api "k8s.io/client-go/pkg/api/v1"
+)
+
+// syncCertificates checks the ACM certificates the listeners of every ALBIngress use. The days
+// left until each of them expires are exported, and a warning Event is emitted on the ingress
+// resource for each certificate that expired, or that expires within the warning threshold and
+// won't be renewed by ACM. Certificates ACM renews keep their ARN, so the listeners pick up the
+// renewed certificate without being modified. Each issue is reported once. The ALBIngresses aren't
+// rebuilt or reconciled meanwhile.
+func (ac *ALBController) syncCertificates() {
+	if ac.syncTLSSecrets {
+		ac.resyncTLSSecrets()
+	}
+
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+
+	certificates := make(map[string]*acm.CertificateDetail)
+	issues := make(map[string]string)
+	expiry := make(map[string]prometheus.Labels)
+	for _, ALBIngress := range ac.ALBIngresses {
+		if ALBIngress.tainted {
+			continue
+		}
+		for _, arn := range ALBIngress.CertificateArns() {
+			// IAM server certificates aren't managed by ACM.
+			if strings.Contains(arn, ":iam::") {
+				continue
+			}
+			key := fmt.Sprintf("%s %s", *ALBIngress.id, arn)
+			certificate, ok := certificates[arn]
+			if !ok {
+				var err error
+				restore := awsutil.AssumeRole(ALBIngress.roleArn)
+				certificate, err = awsutil.ACMsvc.DescribeCertificate(aws.String(arn))
+				restore()
+				if err != nil {
+					log.Warnf("Unable to describe certificate %s. Error: %s", *ALBIngress.id, arn, err.Error())
+					// The last known expiry keeps being exported.
+					if labels, ok := ac.certificateExpiry[key]; ok {
+						expiry[key] = labels
+					}
+					continue
+				}
+				certificates[arn] = certificate
+			}
+
+			now := time.Now()
+			if certificate.NotAfter != nil {
+				labels := prometheus.Labels{"ingress": *ALBIngress.id, "certificate": arn}
+				awsutil.CertificateExpiry.With(labels).Set(certificate.NotAfter.Sub(now).Hours() / 24)
+				expiry[key] = labels
+			}
+
+			reason, message := certificateIssue(certificate, now, ac.expiryWarning)
+			if reason == "" {
+				continue
+			}
+			issues[key] = reason
+			if ac.certificateIssues[key] == reason {
+				continue
+			}
+
+			log.Warnf("Certificate %s %s.", *ALBIngress.id, arn, message)
+			ingResource, exists := ac.ingressResource(*ALBIngress.namespace, *ALBIngress.ingressName)
+			if !exists {
+				continue
+			}
+			ac.recorder.Eventf(ingResource, api.EventTypeWarning, reason, "Certificate %s %s", arn, message)
+		}
+	}
+	ac.certificateIssues = issues
+
+	// Certificates no longer in use, or used by an ingress that went away, stop being exported.
+	for key, labels := range ac.certificateExpiry {
+		if _, ok := expiry[key]; !ok {
+			awsutil.CertificateExpiry.Delete(labels)
+		}
+	}
+	ac.certificateExpiry = expiry
+}
+
+// certificateIssue returns the reason and message of the Event reporting an issue with certificate,
+// or an empty reason when there is none. Certificates expiring within warning are reported, unless
+// warning is negative, in which case only expired certificates are. Imported certificates are never renewed by ACM, and
+// certificates ACM issued are renewed well before they come close to expiring, unless their
+// renewal failed or they aren't eligible for it.
+func certificateIssue(certificate *acm.CertificateDetail, now time.Time, warning time.Duration) (string, string) {
+	if certificate.NotAfter == nil {
+		return "", ""
+	}
+	notAfter := *certificate.NotAfter
+	if aws.StringValue(certificate.Status) == acm.CertificateStatusExpired || !now.Before(notAfter) {
+		return "CertificateExpired", fmt.Sprintf("expired on %s", notAfter.Format(time.RFC3339))
+	}
+	if warning < 0 || notAfter.Sub(now) > warning {
+		return "", ""
+	}
+
+	switch {
+	case aws.StringValue(certificate.Type) == acm.CertificateTypeImported:
+		return "CertificateExpiring", fmt.Sprintf("expires on %s and, being imported, isn't renewed by ACM", notAfter.Format(time.RFC3339))
+	case certificate.RenewalSummary == nil:
+		return "CertificateExpiring", fmt.Sprintf("expires on %s and isn't eligible for renewal by ACM", notAfter.Format(time.RFC3339))
+	case aws.StringValue(certificate.RenewalSummary.RenewalStatus) == acm.RenewalStatusFailed:
+		return "CertificateExpiring", fmt.Sprintf("expires on %s and its renewal by ACM failed", notAfter.Format(time.RFC3339))
+	}
+	return "", ""
+}
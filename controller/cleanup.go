@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/controller/util"
+	"github.com/coreos/alb-ingress-controller/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// CleanupOrphans deletes the AWS resources of the controller that no ingress resource exists for:
+// the ALBs of deleted ingresses along with their target groups, security groups and Route 53
+// records, and the target groups and security groups tagged for the controller that no ALB uses,
+// as left behind when the controller stopped part way through creating or deleting an ALB. With
+// dryRun nothing is deleted. The changes made, or that would be made, are returned.
+//
+// Without access to the Kubernetes API, e.g. once the cluster was torn down, the ingress resources
+// can't be looked up and every resource of the controller is orphaned. That must be confirmed with
+// all, which also ignores the ingress resources when they can be looked up.
+func (ac *ALBController) CleanupOrphans(dryRun, all bool) ([]string, error) {
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	switch {
+	case all:
+	case ac.client == nil:
+		return nil, fmt.Errorf("Unable to list the ingress resources without access to the Kubernetes API, every resource of the controller is orphaned only with --all")
+	default:
+		ingresses, err := ac.client.Extensions().Ingresses(metav1.NamespaceAll).List(metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for i := range ingresses.Items {
+			store.Add(&ingresses.Items[i])
+		}
+	}
+	ac.storeLister.Ingress.Store = store
+
+	ac.assembleIngresses()
+
+	changes := []string{}
+	var failed []string
+	for _, ALBIngress := range ac.ALBIngresses {
+		if _, exists := ac.ingressResource(*ALBIngress.namespace, *ALBIngress.ingressName); exists {
+			continue
+		}
+		ALBIngress.StripDesiredState()
+		for _, lb := range ALBIngress.LoadBalancers {
+			changes = append(changes, lb.PendingChanges()...)
+		}
+		if dryRun {
+			continue
+		}
+		ALBIngress.Reconcile(ac.dnsProvider)
+		if len(ALBIngress.LoadBalancers) > 0 {
+			failed = append(failed, ALBIngress.Name())
+		}
+	}
+
+	roles, err := ac.namespaceRoles()
+	if err != nil {
+		return changes, err
+	}
+	roleArns := []string{""}
+	for _, role := range roles {
+		roleArns = append(roleArns, role)
+	}
+	cleaned := make(map[string]bool)
+	for _, roleArn := range roleArns {
+		if cleaned[roleArn] {
+			continue
+		}
+		cleaned[roleArn] = true
+		c, err := ac.cleanupUnusedResources(roleArn, dryRun)
+		changes = append(changes, c...)
+		if err != nil {
+			return changes, err
+		}
+	}
+
+	if len(failed) > 0 {
+		return changes, fmt.Errorf("Failed to delete the ALBs of %s", strings.Join(failed, ", "))
+	}
+	return changes, nil
+}
+
+// cleanupUnusedResources deletes the target groups and security groups of the controller under the
+// IAM role roleArn that aren't used by any ALB. The shared security group is left to the
+// controller, as the node security groups reference it.
+func (ac *ALBController) cleanupUnusedResources(roleArn string, dryRun bool) ([]string, error) {
+	defer awsutil.AssumeRole(roleArn)()
+
+	var changes []string
+	owner := ownershipTags(*ac.clusterName, ac.controllerID)
+
+	targetGroups, err := awsutil.ALBsvc.DescribeOwnedTargetGroups(owner)
+	if err != nil {
+		return changes, err
+	}
+	var unused []*elbv2.TargetGroup
+	var unusedArns []*string
+	for _, targetGroup := range targetGroups {
+		if len(targetGroup.LoadBalancerArns) == 0 {
+			unused = append(unused, targetGroup)
+			unusedArns = append(unusedArns, targetGroup.TargetGroupArn)
+		}
+	}
+	tags, err := awsutil.ALBsvc.DescribeTagsBatch(unusedArns)
+	if err != nil {
+		return changes, err
+	}
+	for _, targetGroup := range unused {
+		if !ownedBy(tags[*targetGroup.TargetGroupArn], owner) {
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("delete target group %s", *targetGroup.TargetGroupName))
+		if dryRun {
+			continue
+		}
+		log.Infof("Deleting unused target group %s.", "controller", *targetGroup.TargetGroupName)
+		if err := awsutil.ALBsvc.RemoveTargetGroup(elbv2.DeleteTargetGroupInput{TargetGroupArn: targetGroup.TargetGroupArn}); err != nil {
+			return changes, err
+		}
+	}
+
+	loadBalancers, err := awsutil.ALBsvc.DescribeLoadBalancers(ac.clusterName)
+	if err != nil {
+		return changes, err
+	}
+	var inUse util.AWSStringSlice
+	for _, loadBalancer := range loadBalancers {
+		inUse = append(inUse, loadBalancer.SecurityGroups...)
+	}
+
+	var filters []*ec2.Filter
+	for _, tag := range owner {
+		filters = append(filters, &ec2.Filter{Name: aws.String("tag:" + *tag.Key), Values: []*string{tag.Value}})
+	}
+	sgs, err := awsutil.Ec2svc.DescribeSecurityGroups(ec2.DescribeSecurityGroupsInput{Filters: filters})
+	if err != nil {
+		return changes, err
+	}
+	for _, sg := range sgs {
+		if ac.isSharedSecurityGroupName(*sg.GroupName) ||
+			len(inUse.Intersect(util.AWSStringSlice{sg.GroupId})) > 0 {
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("delete security group %s", *sg.GroupId))
+		if dryRun {
+			continue
+		}
+		log.Infof("Deleting unused security group %s.", "controller", *sg.GroupId)
+		if err := awsutil.Ec2svc.DeleteSecurityGroup(sg.GroupId); err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
+
+// ownedBy returns true when the ownership tags of t, the tags of an existing AWS resource, are those
+// of owner. Unlike with OwnershipConflict, resources without ownership tags aren't owned.
+func ownedBy(t util.Tags, owner util.Tags) bool {
+	for _, key := range []string{util.ClusterNameTag, util.ControllerIDTag} {
+		want, _ := owner.Get(key)
+		if got, ok := t.Get(key); !ok || got != want {
+			return false
+		}
+	}
+	return true
+}
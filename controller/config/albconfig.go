@@ -0,0 +1,85 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AlbConfigKey is the ingress annotation naming the AlbConfig resource the ALBs of the ingress are
+// configured by.
+const AlbConfigKey = "alb.ingress.kubernetes.io/alb-config"
+
+// AlbConfigSpec is the load balancer level configuration of an AlbConfig resource. It lets the
+// platform team owning the resource control the settings of the ALBs of every ingress referencing
+// it, while the teams owning the ingresses control their routing. Each field that is set replaces
+// the annotation of the same name of the ingresses.
+type AlbConfigSpec struct {
+	Scheme         string             `json:"scheme,omitempty"`
+	Subnets        []string           `json:"subnets,omitempty"`
+	SecurityGroups []string           `json:"securityGroups,omitempty"`
+	IPAddressType  string             `json:"ipAddressType,omitempty"`
+	InboundCIDRs   []string           `json:"inboundCIDRs,omitempty"`
+	ListenPorts    []map[string]int64 `json:"listenPorts,omitempty"`
+	SSLPolicy      string             `json:"sslPolicy,omitempty"`
+	Attributes     map[string]string  `json:"attributes,omitempty"`
+	Tags           map[string]string  `json:"tags,omitempty"`
+	WebACLID       string             `json:"webACLId,omitempty"`
+}
+
+// Annotations returns the ingress annotations equivalent to spec, keyed by annotation. An error is
+// returned when the spec can't be expressed as annotations.
+func (s *AlbConfigSpec) Annotations() (map[string]string, error) {
+	annotations := make(map[string]string)
+	set := func(key, value string) {
+		if value != "" {
+			annotations[key] = value
+		}
+	}
+	set(schemeKey, s.Scheme)
+	set(subnetsKey, strings.Join(s.Subnets, ","))
+	set(securityGroupsKey, strings.Join(s.SecurityGroups, ","))
+	set(ipAddressTypeKey, s.IPAddressType)
+	set(inboundCIDRsKey, strings.Join(s.InboundCIDRs, ","))
+	set(sslPolicyKey, s.SSLPolicy)
+	set(loadBalancerAttributesKey, joinPairs(s.Attributes))
+	set(tagsKey, joinPairs(s.Tags))
+	set(webACLIDKey, s.WebACLID)
+	if len(s.ListenPorts) > 0 {
+		ports, err := json.Marshal(s.ListenPorts)
+		if err != nil {
+			return nil, fmt.Errorf("listenPorts are invalid: %s", err.Error())
+		}
+		annotations[portKey] = string(ports)
+	}
+	return annotations, nil
+}
+
+// ApplyAlbConfig returns annotations with the ones of spec set over them. annotations isn't
+// modified.
+func ApplyAlbConfig(annotations map[string]string, spec *AlbConfigSpec) (map[string]string, error) {
+	overrides, err := spec.Annotations()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string)
+	for k, v := range annotations {
+		out[k] = v
+	}
+	for k, v := range overrides {
+		out[k] = v
+	}
+	return out, nil
+}
+
+// joinPairs returns the key=value pairs of m, sorted by key and separated by commas, as the
+// attributes and tags annotations take them.
+func joinPairs(m map[string]string) string {
+	var pairs []string
+	for k, v := range m {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyAlbConfig(t *testing.T) {
+	spec := &AlbConfigSpec{
+		Scheme:      "internal",
+		Subnets:     []string{"subnet-1", "subnet-2"},
+		ListenPorts: []map[string]int64{{"HTTP": 80}, {"HTTPS": 443}},
+		Attributes:  map[string]string{"idle_timeout.timeout_seconds": "120", "deletion_protection.enabled": "true"},
+		Tags:        map[string]string{"Team": "platform"},
+		WebACLID:    "acl-1",
+	}
+	annotations := map[string]string{
+		schemeKey:                     "internet-facing",
+		healthcheckPathKey:            "/healthz",
+		"kubernetes.io/ingress.class": "alb",
+	}
+
+	applied, err := ApplyAlbConfig(annotations, spec)
+	if err != nil {
+		t.Fatalf("ApplyAlbConfig(): returned error %v", err)
+	}
+	expected := map[string]string{
+		schemeKey:                     "internal",
+		subnetsKey:                    "subnet-1,subnet-2",
+		portKey:                       `[{"HTTP":80},{"HTTPS":443}]`,
+		loadBalancerAttributesKey:     "deletion_protection.enabled=true,idle_timeout.timeout_seconds=120",
+		tagsKey:                       "Team=platform",
+		webACLIDKey:                   "acl-1",
+		healthcheckPathKey:            "/healthz",
+		"kubernetes.io/ingress.class": "alb",
+	}
+	if !reflect.DeepEqual(applied, expected) {
+		t.Errorf("ApplyAlbConfig(): expected %v, actual %v", expected, applied)
+	}
+	if annotations[schemeKey] != "internet-facing" {
+		t.Errorf("ApplyAlbConfig(): modified the annotations of the ingress")
+	}
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -11,85 +13,338 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/route53"
 	"github.com/coreos/alb-ingress-controller/awsutil"
 	"github.com/coreos/alb-ingress-controller/controller/util"
 	"github.com/coreos/alb-ingress-controller/log"
 	"github.com/golang/glog"
 	"github.com/karlseguin/ccache"
 	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 var cache = ccache.New(ccache.Configure())
 
+// annotationPrefix is the prefix of the annotations of the controller
+const annotationPrefix = "alb.ingress.kubernetes.io/"
+
 const (
+	actionsKeyPrefix              = "alb.ingress.kubernetes.io/actions."
+	alarm5xxRateKey               = "alb.ingress.kubernetes.io/alarm-5xx-rate"
+	alarmResponseTimeKey          = "alb.ingress.kubernetes.io/alarm-response-time"
+	alarmSNSTopicArnKey           = "alb.ingress.kubernetes.io/alarm-sns-topic-arn"
+	alarmUnhealthyHostsKey        = "alb.ingress.kubernetes.io/alarm-unhealthy-hosts"
+	allowHTTPKey                  = "alb.ingress.kubernetes.io/allow-http"
+	allowedIngressNamespacesKey   = "alb.ingress.kubernetes.io/allowed-ingress-namespaces"
 	backendProtocolKey            = "alb.ingress.kubernetes.io/backend-protocol"
+	backendProtocolVersionKey     = "alb.ingress.kubernetes.io/backend-protocol-version"
 	certificateArnKey             = "alb.ingress.kubernetes.io/certificate-arn"
+	cloudFrontOnlyKey             = "alb.ingress.kubernetes.io/cloudfront-only"
+	crossZoneLoadBalancingKey     = "alb.ingress.kubernetes.io/cross-zone-load-balancing"
+	globalAcceleratorKey          = "alb.ingress.kubernetes.io/global-accelerator"
+	globalAcceleratorListenerKey  = "alb.ingress.kubernetes.io/global-accelerator-listener-arn"
 	healthcheckIntervalSecondsKey = "alb.ingress.kubernetes.io/healthcheck-interval-seconds"
 	healthcheckPathKey            = "alb.ingress.kubernetes.io/healthcheck-path"
 	healthcheckPortKey            = "alb.ingress.kubernetes.io/healthcheck-port"
 	healthcheckProtocolKey        = "alb.ingress.kubernetes.io/healthcheck-protocol"
 	healthcheckTimeoutSecondsKey  = "alb.ingress.kubernetes.io/healthcheck-timeout-seconds"
 	healthyThresholdCountKey      = "alb.ingress.kubernetes.io/healthy-threshold-count"
+	hostHeaderConditionsKey       = "alb.ingress.kubernetes.io/host-header-conditions"
+	hostnameKey                   = "alb.ingress.kubernetes.io/hostname"
+	inboundCIDRsKey               = "alb.ingress.kubernetes.io/inbound-cidrs"
+	ipAddressTypeKey              = "alb.ingress.kubernetes.io/ip-address-type"
+	loadBalancerAttributesKey     = "alb.ingress.kubernetes.io/load-balancer-attributes"
+	loadBalancerNameKey           = "alb.ingress.kubernetes.io/load-balancer-name"
+	loadBalancingAlgorithmKey     = "alb.ingress.kubernetes.io/load-balancing-algorithm"
+	manageDNSKey                  = "alb.ingress.kubernetes.io/manage-dns"
+	outboundRulesKey              = "alb.ingress.kubernetes.io/outbound-rules"
 	unhealthyThresholdCountKey    = "alb.ingress.kubernetes.io/unhealthy-threshold-count"
 	portKey                       = "alb.ingress.kubernetes.io/listen-ports"
+	route53FailoverKey            = "alb.ingress.kubernetes.io/route53-failover"
+	route53HealthCheckPathKey     = "alb.ingress.kubernetes.io/route53-health-check-path"
+	route53RecordTypeKey          = "alb.ingress.kubernetes.io/route53-record-type"
+	route53RoutingPolicyKey       = "alb.ingress.kubernetes.io/route53-routing-policy"
+	route53SetIdentifierKey       = "alb.ingress.kubernetes.io/route53-set-identifier"
+	route53TTLKey                 = "alb.ingress.kubernetes.io/route53-ttl"
+	route53WeightKey              = "alb.ingress.kubernetes.io/route53-weight"
 	schemeKey                     = "alb.ingress.kubernetes.io/scheme"
 	securityGroupsKey             = "alb.ingress.kubernetes.io/security-groups"
+	serviceNamespaceKeyPrefix     = "alb.ingress.kubernetes.io/service-namespace."
+	sslPolicyKey                  = "alb.ingress.kubernetes.io/ssl-policy"
+	standbyCertificateArnKey      = "alb.ingress.kubernetes.io/standby-certificate-arn"
+	standbyRegionKey              = "alb.ingress.kubernetes.io/standby-region"
+	standbySecurityGroupsKey      = "alb.ingress.kubernetes.io/standby-security-groups"
+	standbySubnetsKey             = "alb.ingress.kubernetes.io/standby-subnets"
+	standbyTargetPortKey          = "alb.ingress.kubernetes.io/standby-target-port"
+	standbyTargetsKey             = "alb.ingress.kubernetes.io/standby-targets"
+	staticTargetsKey              = "alb.ingress.kubernetes.io/static-targets"
 	subnetsKey                    = "alb.ingress.kubernetes.io/subnets"
 	successCodesKey               = "alb.ingress.kubernetes.io/successCodes"
 	tagsKey                       = "alb.ingress.kubernetes.io/tags"
+	targetGroupAttributesKey      = "alb.ingress.kubernetes.io/target-group-attributes"
+	targetIPAddressTypeKey        = "alb.ingress.kubernetes.io/target-ip-address-type"
+	targetTypeKey                 = "alb.ingress.kubernetes.io/target-type"
+	webACLIDKey                   = "alb.ingress.kubernetes.io/waf-acl-id"
+)
+
+// ShardGroupKey is the ingress annotation naming the shard group of the ingress. With SHARD_COUNT
+// set, the ingresses of a group are managed by the same shard.
+const ShardGroupKey = "alb.ingress.kubernetes.io/shard-group"
+
+// GroupKey is the ingress annotation naming the group of ingresses of its namespace the ingress
+// shares an ALB with, when GROUP_BY is group.
+const GroupKey = "alb.ingress.kubernetes.io/group"
+
+// externalDNSHostnameKey is the annotation external-dns publishes the hostnames of an ingress from.
+// It's honored like hostnameKey, so ingresses move between the two without changing annotations.
+const externalDNSHostnameKey = "external-dns.alpha.kubernetes.io/hostname"
+
+// maxHostnamesLength is the length the additional hostnames of an ingress can have together,
+// separated by spaces, as they're kept in a tag of its ALB.
+const maxHostnamesLength = 256
+
+const (
+	// Default TTL, in seconds, of non-alias Route 53 records
+	defaultRoute53TTL int64 = 300
+	// RoutingPolicyFailover answers with the PRIMARY record while it's healthy, and the SECONDARY
+	// one otherwise
+	RoutingPolicyFailover = "failover"
+	// RoutingPolicyLatency answers with the record of the region closest to the client
+	RoutingPolicyLatency = "latency"
+	// RoutingPolicySimple is a single record owning the hostname
+	RoutingPolicySimple = "simple"
+	// RoutingPolicyWeighted answers with each record in proportion to its weight
+	RoutingPolicyWeighted = "weighted"
+	// CloudFrontPrefixListName is the AWS-managed prefix list of CloudFront's origin-facing servers
+	CloudFrontPrefixListName = "com.amazonaws.global.cloudfront.origin-facing"
+	// UseAnnotation is the service port of ingress backends whose action is given by an actions
+	// annotation rather than by a service
+	UseAnnotation = "use-annotation"
+	// ActionTypeRedirect is the type of actions redirecting requests
+	ActionTypeRedirect = "redirect"
+	// HealthcheckTrafficPort health checks targets on the port they receive traffic on
+	HealthcheckTrafficPort = "traffic-port"
+)
+
+const (
+	// Target group attributes configuring stickiness
+	stickinessTypeAttribute              = "stickiness.type"
+	stickinessCookieNameAttribute        = "stickiness.app_cookie.cookie_name"
+	stickinessLBCookieDurationAttribute  = "stickiness.lb_cookie.duration_seconds"
+	stickinessAppCookieDurationAttribute = "stickiness.app_cookie.duration_seconds"
+	// Stickiness types: a cookie issued by the ALB, or one issued by the application
+	stickinessTypeLBCookie  = "lb_cookie"
+	stickinessTypeAppCookie = "app_cookie"
+	// Maximum number of seconds requests stick to a target, 7 days
+	maxStickinessDurationSeconds = 604800
+)
+
+// httpsOnly refuses plain HTTP listeners, see SetHTTPSOnly
+var httpsOnly bool
+
+// allowedAnnotations are the annotations of the controller ingresses may set, see
+// SetAllowedAnnotations. When nil, all of them are allowed.
+var allowedAnnotations map[string]bool
+
+// minSSLPolicy is the weakest security policy HTTPS listeners may use, see SetMinSSLPolicy
+var minSSLPolicy *string
+
+var (
+	// defaultLoadBalancerAttributes are set on every ALB, see SetDefaultAttributes
+	defaultLoadBalancerAttributes []*elbv2.LoadBalancerAttribute
+	// defaultTargetGroupAttributes are set on every target group, see SetDefaultAttributes
+	defaultTargetGroupAttributes []*elbv2.TargetGroupAttribute
 )
 
+// snsTopicArnPattern matches the ARN of an SNS topic
+var snsTopicArnPattern = regexp.MustCompile(`^arn:aws[a-z-]*:sns:[a-z0-9-]+:[0-9]{12}:[A-Za-z0-9_-]{1,256}$`)
+
+// globalAcceleratorListenerPattern matches the ARN of a Global Accelerator listener
+var globalAcceleratorListenerPattern = regexp.MustCompile(`^arn:aws[a-z-]*:globalaccelerator::[0-9]{12}:accelerator/[A-Za-z0-9-]+/listener/[A-Za-z0-9-]+$`)
+
+// cookieNamePattern matches the cookie names of RFC 6265, made of token characters
+var cookieNamePattern = regexp.MustCompile("^[!#$%&'*+\\-.^_`|~0-9A-Za-z]+$")
+
+// backendKeys are the annotations configuring target groups. Each of them can be overridden for the
+// target groups of a single service by suffixing it with the service's name, e.g.
+// alb.ingress.kubernetes.io/healthcheck-path.my-service.
+var backendKeys = []string{
+	alarm5xxRateKey,
+	alarmResponseTimeKey,
+	alarmSNSTopicArnKey,
+	alarmUnhealthyHostsKey,
+	backendProtocolKey,
+	backendProtocolVersionKey,
+	crossZoneLoadBalancingKey,
+	healthcheckIntervalSecondsKey,
+	healthcheckPathKey,
+	healthcheckPortKey,
+	healthcheckProtocolKey,
+	healthcheckTimeoutSecondsKey,
+	healthyThresholdCountKey,
+	loadBalancingAlgorithmKey,
+	successCodesKey,
+	targetGroupAttributesKey,
+	targetIPAddressTypeKey,
+	unhealthyThresholdCountKey,
+}
+
 // Annotations contains all of the annotation configuration for an ingress
 type Annotations struct {
+	Actions                    map[string]*Action
+	Alarms                     *Alarms // CloudWatch alarms of the target groups, nil when they have none
 	BackendProtocol            *string
+	BackendProtocolVersion     *string
 	CertificateArn             *string
+	GlobalAccelerator          *GlobalAccelerator // Global Accelerator endpoint group of the ALB, nil when it has none
 	HealthcheckIntervalSeconds *int64
 	HealthcheckPath            *string
 	HealthcheckPort            *string
 	HealthcheckProtocol        *string
 	HealthcheckTimeoutSeconds  *int64
 	HealthyThresholdCount      *int64
+	HostHeaderConditions       bool     // whether the rules of the ALB only match requests for its hostnames
+	Hostnames                  []string // additional hostnames pointing at the ALB, besides the host of its rule
 	UnhealthyThresholdCount    *int64
+	InboundCIDRs               util.AWSStringSlice
+	InboundPrefixLists         util.AWSStringSlice
+	IPAddressType              *string
+	LoadBalancerAttributes     []*elbv2.LoadBalancerAttribute
+	LoadBalancerName           *string
+	ManageDNS                  bool           // whether the hostnames of the ingress are published, true unless it opted out
+	OutboundRules              []OutboundRule // outbound rules of the managed security group, nil allows all outbound traffic
 	Ports                      []ListenerPort
+	Route53HealthCheckPath     *string
+	Route53RecordType          *string
+	Route53RoutingPolicy       *RoutingPolicy
+	Route53TTL                 *int64
 	Scheme                     *string
 	SecurityGroups             util.AWSStringSlice
+	ServiceNamespaces          map[string]string // namespaces of the services living outside the ingress's, keyed by service name
+	SNICertificateArns         util.AWSStringSlice
+	SSLPolicy                  *string
+	Standby                    *Standby
 	Subnets                    util.Subnets
 	SuccessCodes               *string
 	Tags                       []*elbv2.Tag
+	TargetGroupAttributes      []*elbv2.TargetGroupAttribute
+	TargetIPAddressType        *string
+	TargetType                 *string
+	UpgradedSSLPolicy          *string // ssl-policy replaced by the minimum policy, nil when it meets the minimum
 	VPCID                      *string
+	WebACLID                   *string                 // WAF Regional web ACL of the ALB, nil when it has none
+	backends                   map[string]*Annotations // annotations of services with overrides, keyed by service name
+}
+
+// Action is an ALB action defined in an actions.<name> ingress annotation. Ingress backends with
+// <name> as their service name and use-annotation as their service port get the action instead of
+// forwarding to a service.
+type Action struct {
+	Type           string
+	RedirectConfig *RedirectConfig
+}
+
+// Alarms are the CloudWatch alarms of a target group, on the metrics of its targets. Thresholds left
+// nil aren't alarmed on.
+type Alarms struct {
+	UnhealthyHosts *int64   // number of unhealthy targets
+	Target5xxRate  *float64 // percentage of requests the targets answered with a 5xx status code
+	ResponseTime   *float64 // average number of seconds the targets took to respond
+	SNSTopicArn    *string  // topic notified when an alarm changes state, nil when none is
+}
+
+// RedirectConfig describes where a redirect action sends requests. Fields left empty keep the
+// respective part of the request URL, as #{host}, #{path}, #{port}, #{protocol} and #{query} do.
+type RedirectConfig struct {
+	Host       string
+	Path       string
+	Port       string
+	Protocol   string
+	Query      string
+	StatusCode string
+}
+
+// AsParams returns the fields of the RedirectConfig, keyed by their ELBV2 API name.
+func (r *RedirectConfig) AsParams() map[string]string {
+	return map[string]string{
+		"Host":       r.Host,
+		"Path":       r.Path,
+		"Port":       r.Port,
+		"Protocol":   r.Protocol,
+		"Query":      r.Query,
+		"StatusCode": r.StatusCode,
+	}
+}
+
+// RoutingPolicy is the Route 53 routing policy of the record of an ingress, letting the records of
+// several clusters, each managed by its own controller, share one hostname. It's nil for a simple
+// record.
+type RoutingPolicy struct {
+	Type          string // RoutingPolicyWeighted, RoutingPolicyLatency or RoutingPolicyFailover
+	SetIdentifier string // distinguishes the record from the others of the hostname
+	Weight        int64  // weight of a weighted record
+	Failover      string // PRIMARY or SECONDARY, for a failover record
+}
+
+// Standby describes the mirrored ALB provisioned in a secondary region for disaster recovery. It
+// forwards to a fixed list of IP targets, e.g. the nodes of a peer cluster, and Route 53 fails
+// over to it when the primary ALB is unhealthy.
+type Standby struct {
+	Region         string
+	Subnets        util.Subnets
+	SecurityGroups util.AWSStringSlice
+	CertificateArn *string
+	Targets        util.AWSStringSlice
+	TargetPort     int64
+}
+
+// GlobalAccelerator describes the Global Accelerator endpoint group an ALB is an endpoint of, in
+// the region of the ALB. Without a ListenerArn, the ALB gets an accelerator of its own, listening
+// on the ports of the ALB.
+type GlobalAccelerator struct {
+	ListenerArn *string // listener of an existing accelerator the ALB is added to, nil to create one
 }
 
 // ListenerPort represents a listener defined in an ingress annotation. Specifically, it represents a
 // port that an ALB should listen on along with the protocol (HTTP or HTTPS). When HTTPS, it's
-// expected the certificate reprsented by Annotations.CertificateArn will be applied.
+// expected the certificate reprsented by Annotations.CertificateArn will be applied, along with the
+// Annotations.SNICertificateArns.
 type ListenerPort struct {
 	HTTPS bool
 	Port  int64
 }
 
+// OutboundRule is an outbound rule of the controller managed security group, allowing TCP traffic
+// to a port range of a destination.
+type OutboundRule struct {
+	Destination string // IPv4 or IPv6 CIDR block, prefix list ID (pl-xxxx) or security group ID (sg-xxxx)
+	FromPort    int64
+	ToPort      int64
+}
+
 // ParseAnnotations validates and loads all the annotations provided into the Annotations struct.
 // If there is an issue with an annotation, an error is returned. In the case of an error, the
 // annotations are also cached, meaning there will be no reattempt to parse annotations until the
-// cache expires or the value(s) change.
-func ParseAnnotations(annotations map[string]string) (*Annotations, error) {
+// cache expires or the value(s) change. Errors from throttled AWS calls aren't cached.
+func ParseAnnotations(annotations map[string]string) (_ *Annotations, err error) {
 	if annotations == nil {
-		return nil, fmt.Errorf(`Necessary annotations missing. Must include at least %s, %s`, subnetsKey, securityGroupsKey)
+		return nil, fmt.Errorf(`Necessary annotations missing. Must include at least %s`, subnetsKey)
 	}
 
 	sortedAnnotations := util.SortedMap(annotations)
 	cacheKey := "annotations " + awsutil.Prettify(sortedAnnotations)
+	// A throttled AWS lookup says nothing about the annotations, so they aren't cached as invalid.
+	defer func() {
+		if awsutil.IsThrottle(err) {
+			cache.Delete(cacheKey)
+		}
+	}()
 
 	if badAnnotations := cacheLookup(cacheKey); badAnnotations != nil {
 		return nil, nil
 	}
 
 	// Verify required annotations present and are valid
-	if annotations[successCodesKey] == "" {
-		annotations[successCodesKey] = "200"
-	}
-	if annotations[backendProtocolKey] == "" {
-		annotations[backendProtocolKey] = "HTTP"
-	}
 	if annotations[subnetsKey] == "" {
 		cache.Set(cacheKey, "error", 1*time.Hour)
 		return nil, fmt.Errorf(`Necessary annotations missing. Must include %s`, subnetsKey)
@@ -101,11 +356,46 @@ func ParseAnnotations(annotations map[string]string) (*Annotations, error) {
 		return nil, err
 	}
 
-	securitygroups, err := parseSecurityGroups(annotations[securityGroupsKey])
+	// When no security groups are given, the controller manages one for the ALB.
+	var securitygroups util.AWSStringSlice
+	if annotations[securityGroupsKey] != "" {
+		securitygroups, err = parseSecurityGroups(annotations[securityGroupsKey])
+		if err != nil {
+			cache.Set(cacheKey, "error", 1*time.Hour)
+			return nil, err
+		}
+	}
+
+	cloudFrontPrefixLists, err := parseCloudFrontOnly(annotations[cloudFrontOnlyKey])
+	if err != nil {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, err
+	}
+	if len(cloudFrontPrefixLists) > 0 && len(securitygroups) > 0 {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, fmt.Errorf("%s applies to the controller managed security group and can't be combined with %s", cloudFrontOnlyKey, securityGroupsKey)
+	}
+
+	inboundCIDRs, inboundPrefixLists, err := parseInboundCIDRs(annotations[inboundCIDRsKey])
+	if err != nil {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, err
+	}
+	if len(inboundCIDRs)+len(inboundPrefixLists) > 0 && len(securitygroups) > 0 {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, fmt.Errorf("%s applies to the controller managed security group and can't be combined with %s", inboundCIDRsKey, securityGroupsKey)
+	}
+	inboundPrefixLists = append(inboundPrefixLists, cloudFrontPrefixLists...)
+	sort.Sort(inboundPrefixLists)
+	outboundRules, err := parseOutboundRules(annotations[outboundRulesKey])
 	if err != nil {
 		cache.Set(cacheKey, "error", 1*time.Hour)
 		return nil, err
 	}
+	if len(outboundRules) > 0 && len(securitygroups) > 0 {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, fmt.Errorf("%s applies to the controller managed security group and can't be combined with %s", outboundRulesKey, securityGroupsKey)
+	}
 	scheme, err := parseScheme(annotations[schemeKey])
 	if err != nil {
 		cache.Set(cacheKey, "error", 1*time.Hour)
@@ -117,33 +407,179 @@ func ParseAnnotations(annotations map[string]string) (*Annotations, error) {
 		cache.Set(cacheKey, "error", 1*time.Hour)
 		return nil, err
 	}
+	if err := enforceHTTPSOnly(ports, annotations[allowHTTPKey]); err != nil {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, err
+	}
+
+	// Without an ssl-policy annotation, HTTPS listeners get the minimum policy, if any, so they
+	// meet it. Otherwise AWS picks its default policy. A policy below the minimum is upgraded to it.
+	sslPolicy := minSSLPolicy
+	var upgradedSSLPolicy *string
+	if policy := annotations[sslPolicyKey]; policy != "" {
+		result := "success"
+		if c := cacheLookup(sslPolicyKey + policy); c != nil && !c.Expired() {
+			result = c.Value().(string)
+		} else {
+			below, err := validateSSLPolicy(aws.String(policy))
+			if err != nil {
+				cache.Set(cacheKey, "error", 1*time.Hour)
+				return nil, err
+			}
+			if below {
+				result = "below minimum"
+			}
+			cache.Set(sslPolicyKey+policy, result, 30*time.Minute)
+		}
+		if result == "success" {
+			sslPolicy = aws.String(policy)
+		} else {
+			upgradedSSLPolicy = aws.String(policy)
+		}
+	}
+
+	targetType, err := parseTargetType(annotations[targetTypeKey])
+	if err != nil {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, err
+	}
+
+	recordType, err := parseRoute53RecordType(annotations[route53RecordTypeKey])
+	if err != nil {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, err
+	}
+
+	ttl, err := parseRoute53TTL(annotations[route53TTLKey])
+	if err != nil {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, err
+	}
+
+	loadBalancerName, err := parseLoadBalancerName(annotations[loadBalancerNameKey])
+	if err != nil {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, err
+	}
+
+	loadBalancerAttributes, err := parseLoadBalancerAttributes(annotations[loadBalancerAttributesKey])
+	if err != nil {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, err
+	}
+	loadBalancerAttributes = withDefaultLoadBalancerAttributes(loadBalancerAttributes)
+
+	actions, err := parseActions(annotations)
+	if err != nil {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, err
+	}
+
+	serviceNamespaces, err := parseServiceNamespaces(annotations)
+	if err != nil {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, err
+	}
+
+	standby, err := parseStandby(annotations, ports, recordType)
+	if err != nil {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, err
+	}
+
+	ipAddressType, err := parseIPAddressType(annotations[ipAddressTypeKey], scheme)
+	if err != nil {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, err
+	}
+
+	routingPolicy, err := parseRoutingPolicy(annotations, recordType, standby)
+	if err != nil {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, err
+	}
+
+	healthCheckPath, err := parseRoute53HealthCheckPath(annotations[route53HealthCheckPathKey], scheme, routingPolicy, standby)
+	if err != nil {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, err
+	}
+
+	manageDNS, err := parseManageDNS(annotations[manageDNSKey], annotations, standby)
+	if err != nil {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, err
+	}
+
+	hostnames, err := parseHostnames(annotations[hostnameKey], annotations[externalDNSHostnameKey])
+	if err != nil {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, err
+	}
+
+	hostHeaderConditions, err := parseHostHeaderConditions(annotations[hostHeaderConditionsKey])
+	if err != nil {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, err
+	}
+
+	globalAccelerator, err := parseGlobalAccelerator(annotations[globalAcceleratorKey], annotations[globalAcceleratorListenerKey])
+	if err != nil {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, err
+	}
 
 	a := &Annotations{
-		BackendProtocol: aws.String(annotations[backendProtocolKey]),
-		Ports:           ports,
-		Subnets:         subnets,
-		Scheme:          scheme,
-		SecurityGroups:  securitygroups,
-		SuccessCodes:    aws.String(annotations[successCodesKey]),
-		Tags:            stringToTags(annotations[tagsKey]),
-		HealthcheckIntervalSeconds: parseInt(annotations[healthcheckIntervalSecondsKey]),
-		HealthcheckPath:            parseHealthcheckPath(annotations[healthcheckPathKey]),
-		HealthcheckPort:            parseHealthcheckPort(annotations[healthcheckPortKey]),
-		HealthcheckProtocol:        parseString(annotations[healthcheckProtocolKey]),
-		HealthcheckTimeoutSeconds:  parseInt(annotations[healthcheckTimeoutSecondsKey]),
-		HealthyThresholdCount:      parseInt(annotations[healthyThresholdCountKey]),
-		UnhealthyThresholdCount:    parseInt(annotations[unhealthyThresholdCountKey]),
+		Actions:                actions,
+		GlobalAccelerator:      globalAccelerator,
+		HostHeaderConditions:   hostHeaderConditions,
+		Hostnames:              hostnames,
+		ManageDNS:              manageDNS,
+		Ports:                  ports,
+		Route53HealthCheckPath: healthCheckPath,
+		Route53RecordType:      recordType,
+		Route53RoutingPolicy:   routingPolicy,
+		Route53TTL:             ttl,
+		Subnets:                subnets,
+		Scheme:                 scheme,
+		SecurityGroups:         securitygroups,
+		ServiceNamespaces:      serviceNamespaces,
+		Tags:                   stringToTags(annotations[tagsKey]),
+		TargetType:             targetType,
+		InboundCIDRs:           inboundCIDRs,
+		InboundPrefixLists:     inboundPrefixLists,
+		OutboundRules:          outboundRules,
+		IPAddressType:          ipAddressType,
+		LoadBalancerAttributes: loadBalancerAttributes,
+		LoadBalancerName:       loadBalancerName,
+		SSLPolicy:              sslPolicy,
+		UpgradedSSLPolicy:      upgradedSSLPolicy,
+		Standby:                standby,
+		WebACLID:               parseWebACLID(annotations[webACLIDKey]),
+	}
+
+	if err := a.parseBackend(annotations); err != nil {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, err
+	}
+	if a.backends, err = a.parseBackendOverrides(annotations); err != nil {
+		cache.Set(cacheKey, "error", 1*time.Hour)
+		return nil, err
 	}
 
 	// Begin all validations needed to qualify the ingress resource.
-	if cert, ok := annotations[certificateArnKey]; ok {
-		a.CertificateArn = aws.String(cert)
-		if c := cacheLookup(cert); c == nil || c.Expired() {
-			if err := a.validateCertARN(); err != nil {
-				cache.Set(cacheKey, "error", 1*time.Hour)
-				return nil, err
+	// The first certificate is the default certificate of the HTTPS listeners, the others are
+	// served through SNI.
+	if certs := stringToAwsSlice(annotations[certificateArnKey]); len(certs) > 0 {
+		a.CertificateArn, a.SNICertificateArns = certs[0], certs[1:]
+		for _, cert := range certs {
+			if c := cacheLookup(*cert); c == nil || c.Expired() {
+				if err := validateCertARN(cert); err != nil {
+					cache.Set(cacheKey, "error", 1*time.Hour)
+					return nil, err
+				}
+				cache.Set(*cert, "success", 30*time.Minute)
 			}
-			cache.Set(cert, "success", 30*time.Minute)
 		}
 	}
 	if c := cacheLookup(a.Subnets.String()); c == nil || c.Expired() {
@@ -153,94 +589,1267 @@ func ParseAnnotations(annotations map[string]string) (*Annotations, error) {
 		}
 		cache.Set(a.Subnets.String(), "success", 30*time.Minute)
 	}
-	if c := cacheLookup(*a.SecurityGroups.Hash()); c == nil || c.Expired() {
-		if err := a.validateSecurityGroups(); err != nil {
-			cache.Set(cacheKey, "error", 1*time.Hour)
-			return nil, err
+	if len(a.SecurityGroups) > 0 {
+		if c := cacheLookup(*a.SecurityGroups.Hash()); c == nil || c.Expired() {
+			if err := a.validateSecurityGroups(); err != nil {
+				cache.Set(cacheKey, "error", 1*time.Hour)
+				return nil, err
+			}
+			cache.Set(*a.SecurityGroups.Hash(), "success", 30*time.Minute)
 		}
-		cache.Set(*a.SecurityGroups.Hash(), "success", 30*time.Minute)
 	}
 
 	return a, nil
 }
 
-// parsePorts takes a JSON array describing what ports and protocols should be used. When the JSON
-// is empty, implying the annotation was not present, desired ports are set to the default. The
-// default port value is 80 when a certArn is not present and 443 when it is.
-func parsePorts(data, certArn string) ([]ListenerPort, error) {
-	lps := []ListenerPort{}
-	// If port data is empty, default to port 80 or 443 contingent on whether a certArn was specified.
-	if data == "" {
-		switch certArn {
-		case "":
-			lps = append(lps, ListenerPort{false, int64(80)})
-		default:
-			lps = append(lps, ListenerPort{true, int64(443)})
+// MergeAnnotations returns the annotations of a group of ingresses sharing an ALB, from the
+// annotations of its members, in order, named by names. The annotations of the controller they set
+// must agree, except for the certificates and hostnames they list, which are combined. The hosts
+// of their rules are added to the hostnames, so each gets a record pointing at the ALB.
+func MergeAnnotations(names []string, members []map[string]string, hosts []string) (map[string]string, error) {
+	merged := make(map[string]string)
+	setBy := make(map[string]string)
+	lists := map[string][]string{hostnameKey: hosts}
+	for i, annotations := range members {
+		for k, v := range annotations {
+			switch {
+			case k == certificateArnKey || k == hostnameKey || k == externalDNSHostnameKey:
+				lists[k] = append(lists[k], v)
+			case !strings.HasPrefix(k, annotationPrefix):
+			case setBy[k] != "" && merged[k] != v:
+				return nil, fmt.Errorf("ingresses %s and %s share an ALB, but set %s to %q and %q", setBy[k], names[i], k, merged[k], v)
+			case setBy[k] == "":
+				merged[k], setBy[k] = v, names[i]
+			}
 		}
-		return lps, nil
 	}
 
-	// Container to hold json in structured format after unmarshaling.
-	c := []map[string]int64{}
-	err := json.Unmarshal([]byte(data), &c)
-	if err != nil {
-		return nil, fmt.Errorf("JSON structure was invalid. %s", err.Error())
+	for k, values := range lists {
+		seen := make(map[string]bool)
+		var combined []string
+		for _, value := range values {
+			for _, v := range stringToAwsSlice(value) {
+				if !seen[*v] {
+					seen[*v] = true
+					combined = append(combined, *v)
+				}
+			}
+		}
+		if len(combined) > 0 {
+			merged[k] = strings.Join(combined, ",")
+		}
 	}
+	return merged, nil
+}
 
-	// Iterate over listeners in list. Validate port and protcol are correct, then inject them into
-	// the list of ListenerPorts.
+// CertificateArns returns the ARNs listed in the certificate-arn annotation, the default
+// certificate first.
+func CertificateArns(annotations map[string]string) []string {
+	var arns []string
+	for _, arn := range stringToAwsSlice(annotations[certificateArnKey]) {
+		arns = append(arns, *arn)
+	}
+	return arns
+}
+
+// ListensHTTPOnly reports whether the listen-ports annotation of annotations is set and lists no
+// HTTPS port, so the ingress serves no certificate whatever its spec.tls says.
+func ListensHTTPOnly(annotations map[string]string) bool {
+	data := annotations[portKey]
+	if data == "" {
+		return false
+	}
+	c := []map[string]int64{}
+	if err := json.Unmarshal([]byte(data), &c); err != nil {
+		return false
+	}
 	for _, l := range c {
-		for k, v := range l {
-			// Verify port value is valid for ALB.
-			// ALBS (from AWS): Ports need to be a number between 1 and 65535
-			if v < 1 || v > 65535 {
-				return nil, fmt.Errorf("Invalid port provided. Must be between 1 and 65535. It was %d", v)
-			}
-			switch {
-			case k == "HTTP":
-				lps = append(lps, ListenerPort{false, v})
-			case k == "HTTPS" && certArn != "":
-				lps = append(lps, ListenerPort{true, v})
-			default:
-				return nil, fmt.Errorf("Invalid protocol provided. Must be HTTP or HTTPS and in order to use HTTPS you must have specified a certificate ARN")
-			}
+		if _, ok := l["HTTPS"]; ok {
+			return false
 		}
 	}
+	return true
+}
 
-	return lps, nil
+// ManagesDNS reports whether the hostnames of an ingress with annotations are published, like
+// Annotations.ManageDNS, without parsing the other annotations.
+func ManagesDNS(annotations map[string]string) bool {
+	manage, err := parseManageDNS(annotations[manageDNSKey], annotations, nil)
+	return err != nil || manage
 }
 
-func parseString(s string) *string {
-	if s == "" {
-		return nil
+// WithCertificateArns returns a copy of annotations setting the certificate-arn annotation to
+// arns, the first of which becomes the default certificate.
+func WithCertificateArns(annotations map[string]string, arns []string) map[string]string {
+	out := make(map[string]string, len(annotations)+1)
+	for k, v := range annotations {
+		out[k] = v
 	}
-	return aws.String(s)
+	out[certificateArnKey] = strings.Join(arns, ",")
+	return out
 }
 
-func parseHealthcheckPath(s string) *string {
-	switch {
-	case s == "":
-		return aws.String("/")
+// ForBackend returns the annotations applying to the target groups of a service. They're the
+// ingress's annotations, with the overrides suffixed with the service's name applied.
+func (a *Annotations) ForBackend(serviceName string) *Annotations {
+	if b, ok := a.backends[serviceName]; ok {
+		return b
 	}
-	return aws.String(s)
+	return a
 }
 
-func parseHealthcheckPort(s string) *string {
-	switch {
-	case s == "":
-		return aws.String("traffic-port")
+// parseBackend loads the annotations configuring target groups, see backendKeys.
+func (a *Annotations) parseBackend(annotations map[string]string) error {
+	protocol, err := parseBackendProtocol(annotations[backendProtocolKey])
+	if err != nil {
+		return err
 	}
-	return aws.String(s)
+	protocolVersion, err := parseBackendProtocolVersion(annotations[backendProtocolVersionKey], *protocol)
+	if err != nil {
+		return err
+	}
+	healthcheckPort, err := parseHealthcheckPort(annotations[healthcheckPortKey])
+	if err != nil {
+		return err
+	}
+	healthcheckProtocol, err := parseHealthcheckProtocol(annotations[healthcheckProtocolKey], *protocol)
+	if err != nil {
+		return err
+	}
+	successCodes, err := parseSuccessCodes(annotations[successCodesKey])
+	if err != nil {
+		return err
+	}
+	attributes, err := parseTargetGroupAttributes(annotations[targetGroupAttributesKey])
+	if err != nil {
+		return err
+	}
+	attributes = withDefaultTargetGroupAttributes(attributes)
+	algorithm, err := parseLoadBalancingAlgorithm(annotations[loadBalancingAlgorithmKey])
+	if err != nil {
+		return err
+	}
+	if algorithm != nil {
+		attributes = withTargetGroupAttribute(attributes, awsutil.AttributeLoadBalancingAlgorithm, *algorithm)
+	}
+	crossZone, err := parseCrossZoneLoadBalancing(annotations[crossZoneLoadBalancingKey])
+	if err != nil {
+		return err
+	}
+	if crossZone != nil {
+		attributes = withTargetGroupAttribute(attributes, awsutil.AttributeCrossZoneEnabled, *crossZone)
+	}
+	if err := validateStickiness(attributes); err != nil {
+		return err
+	}
+	targetIPAddressType, err := parseTargetIPAddressType(annotations[targetIPAddressTypeKey], aws.StringValue(a.TargetType), aws.StringValue(a.IPAddressType))
+	if err != nil {
+		return err
+	}
+	alarms, err := parseAlarms(annotations)
+	if err != nil {
+		return err
+	}
+
+	a.Alarms = alarms
+	a.BackendProtocol = protocol
+	a.BackendProtocolVersion = protocolVersion
+	a.HealthcheckIntervalSeconds = parseInt(annotations[healthcheckIntervalSecondsKey])
+	a.HealthcheckPath = parseHealthcheckPath(annotations[healthcheckPathKey])
+	a.HealthcheckPort = healthcheckPort
+	a.HealthcheckProtocol = healthcheckProtocol
+	a.HealthcheckTimeoutSeconds = parseInt(annotations[healthcheckTimeoutSecondsKey])
+	a.HealthyThresholdCount = parseInt(annotations[healthyThresholdCountKey])
+	a.SuccessCodes = successCodes
+	a.TargetGroupAttributes = attributes
+	a.TargetIPAddressType = targetIPAddressType
+	a.UnhealthyThresholdCount = parseInt(annotations[unhealthyThresholdCountKey])
+	return nil
 }
 
-func parseScheme(s string) (*string, error) {
-	switch {
-	case s == "":
-		return aws.String(""), fmt.Errorf(`Necessary annotations missing. Must include %s`, schemeKey)
-	case s != "internal" && s != "internet-facing":
-		return aws.String(""), fmt.Errorf("ALB Scheme [%v] must be either `internal` or `internet-facing`", s)
-	}
-	return aws.String(s), nil
+// parseBackendOverrides returns the annotations of every service that has overrides of the
+// backendKeys, keyed by service name. Settings a service doesn't override are inherited from a.
+// Overrides left empty are ignored.
+func (a *Annotations) parseBackendOverrides(annotations map[string]string) (map[string]*Annotations, error) {
+	overrides := make(map[string]map[string]string)
+	for key, value := range annotations {
+		for _, backendKey := range backendKeys {
+			if !strings.HasPrefix(key, backendKey+".") || value == "" {
+				continue
+			}
+			service := strings.TrimPrefix(key, backendKey+".")
+			if overrides[service] == nil {
+				overrides[service] = make(map[string]string)
+			}
+			overrides[service][backendKey] = value
+		}
+	}
+
+	out := make(map[string]*Annotations)
+	for service, values := range overrides {
+		merged := make(map[string]string)
+		for _, key := range backendKeys {
+			merged[key] = annotations[key]
+		}
+		for key, value := range values {
+			merged[key] = value
+		}
+
+		b := *a
+		b.backends = nil
+		if err := b.parseBackend(merged); err != nil {
+			return nil, fmt.Errorf("Invalid overrides for service %s: %s", service, err.Error())
+		}
+		out[service] = &b
+	}
+	return out, nil
+}
+
+// ParseStaticTargets returns the IP addresses listed in the static-targets annotation of a service.
+// They are registered in the service's target groups alongside its endpoints. An error is returned
+// when any of the addresses isn't a valid IPv4 address.
+func ParseStaticTargets(annotations map[string]string) (util.AWSStringSlice, error) {
+	var out util.AWSStringSlice
+	seen := make(map[string]bool)
+	for _, target := range stringToAwsSlice(annotations[staticTargetsKey]) {
+		if ip := net.ParseIP(*target); ip == nil || ip.To4() == nil {
+			return nil, fmt.Errorf("Static target [%v] in %s must be an IPv4 address", *target, staticTargetsKey)
+		}
+		if seen[*target] {
+			continue
+		}
+		seen[*target] = true
+		out = append(out, target)
+	}
+	sort.Sort(out)
+	return out, nil
+}
+
+// HasRequiredAnnotations returns true when annotations include the ones every ingress the
+// controller satisfies must have.
+func HasRequiredAnnotations(annotations map[string]string) bool {
+	return annotations[subnetsKey] != ""
+}
+
+// ParseNodeSecurityGroups returns the IDs in s, a comma separated list of the security groups of the
+// cluster's nodes, which are given a rule allowing traffic from the shared security group. An error
+// is returned when any of them isn't a security group ID.
+func ParseNodeSecurityGroups(s string) (util.AWSStringSlice, error) {
+	var out util.AWSStringSlice
+	for _, id := range stringToAwsSlice(s) {
+		if !strings.HasPrefix(*id, "sg-") {
+			return nil, fmt.Errorf("Node security group [%v] must be a security group ID", *id)
+		}
+		out = append(out, id)
+	}
+	return out, nil
+}
+
+// ParseCostAllocationTagKeys returns the keys in s, a comma separated list of the labels whose
+// values the AWS resources of ingresses are tagged with for cost allocation. An error is returned
+// when a key isn't a valid tag key, or is one of the tags the controller sets itself.
+func ParseCostAllocationTagKeys(s string) ([]string, error) {
+	var keys []string
+	for _, key := range stringToAwsSlice(s) {
+		switch *key {
+		case "Namespace", "IngressName", util.ClusterNameTag, util.ControllerIDTag, util.ShardTag:
+			return nil, fmt.Errorf("Cost allocation tag [%v] is set by the controller", *key)
+		}
+		if len(*key) > 128 || strings.HasPrefix(*key, "aws:") {
+			return nil, fmt.Errorf("Cost allocation tag [%v] must be up to 128 characters and not begin with aws:", *key)
+		}
+		keys = append(keys, *key)
+	}
+	return keys, nil
+}
+
+// SetHTTPSOnly sets whether ingresses are refused plain HTTP listeners, unless they're exempted by
+// the allow-http annotation, to enforce TLS across the cluster.
+func SetHTTPSOnly(enabled bool) {
+	httpsOnly = enabled
+}
+
+// SetAllowedAnnotations restricts the alb.ingress.kubernetes.io annotations ingresses may set to
+// names, a comma separated list of annotation names with or without their prefix, e.g.
+// "listen-ports,healthcheck-path". Other annotations are ignored, keeping e.g. the subnets and
+// security groups of ALBs in the hands of the cluster operator. An empty list allows all
+// annotations.
+func SetAllowedAnnotations(names string) {
+	allowedAnnotations = nil
+	for _, name := range stringToAwsSlice(names) {
+		if allowedAnnotations == nil {
+			allowedAnnotations = make(map[string]bool)
+		}
+		allowedAnnotations[annotationPrefix+strings.TrimPrefix(*name, annotationPrefix)] = true
+	}
+}
+
+// FilterAnnotations returns annotations without the controller's annotations that aren't allowed,
+// see SetAllowedAnnotations, along with the sorted names of the annotations left out. Annotations
+// of other controllers are kept.
+func FilterAnnotations(annotations map[string]string) (map[string]string, []string) {
+	if allowedAnnotations == nil {
+		return annotations, nil
+	}
+	out := make(map[string]string)
+	var ignored []string
+	for k, v := range annotations {
+		if strings.HasPrefix(k, annotationPrefix) && !allowedAnnotations[k] {
+			ignored = append(ignored, k)
+			continue
+		}
+		out[k] = v
+	}
+	sort.Strings(ignored)
+	return out, ignored
+}
+
+// SetMinSSLPolicy sets the security policy whose lowest TLS protocol is the lowest HTTPS listeners
+// may negotiate. HTTPS listeners without an ssl-policy annotation, or with one allowing older
+// protocols, get this policy, upgrading the ones below it. An empty
+// name sets no minimum. An error is returned when the policy doesn't exist.
+func SetMinSSLPolicy(name string) error {
+	if name == "" {
+		minSSLPolicy = nil
+		return nil
+	}
+	if _, err := awsutil.ALBsvc.DescribeSSLPolicy(aws.String(name)); err != nil {
+		return fmt.Errorf("MIN_SSL_POLICY %s could not be resolved: %s", name, err.Error())
+	}
+	minSSLPolicy = aws.String(name)
+	return nil
+}
+
+// enforceHTTPSOnly returns an error when HTTPS only is enforced and ports include a plain HTTP
+// listener, unless allowHTTP, the value of the allow-http annotation, is true.
+func enforceHTTPSOnly(ports []ListenerPort, allowHTTP string) error {
+	allowed := false
+	if allowHTTP != "" {
+		var err error
+		if allowed, err = strconv.ParseBool(allowHTTP); err != nil {
+			return fmt.Errorf("%s [%v] must be either `true` or `false`", allowHTTPKey, allowHTTP)
+		}
+	}
+	if !httpsOnly || allowed {
+		return nil
+	}
+	for _, port := range ports {
+		if !port.HTTPS {
+			return fmt.Errorf("HTTP listener on port %d refused, only HTTPS listeners are allowed in this cluster. Set %s to true to allow HTTP listeners",
+				port.Port, allowHTTPKey)
+		}
+	}
+	return nil
+}
+
+// parsePorts takes a JSON array describing what ports and protocols should be used. When the JSON
+// is empty, implying the annotation was not present, desired ports are set to the default. The
+// default port value is 80 when a certArn is not present and 443 when it is.
+func parsePorts(data, certArn string) ([]ListenerPort, error) {
+	lps := []ListenerPort{}
+	// If port data is empty, default to port 80 or 443 contingent on whether a certArn was specified.
+	if data == "" {
+		switch certArn {
+		case "":
+			lps = append(lps, ListenerPort{false, int64(80)})
+		default:
+			lps = append(lps, ListenerPort{true, int64(443)})
+		}
+		return lps, nil
+	}
+
+	// Container to hold json in structured format after unmarshaling.
+	c := []map[string]int64{}
+	err := json.Unmarshal([]byte(data), &c)
+	if err != nil {
+		return nil, fmt.Errorf("JSON structure was invalid. %s", err.Error())
+	}
+
+	// Iterate over listeners in list. Validate port and protcol are correct, then inject them into
+	// the list of ListenerPorts.
+	for _, l := range c {
+		for k, v := range l {
+			// Verify port value is valid for ALB.
+			// ALBS (from AWS): Ports need to be a number between 1 and 65535
+			if v < 1 || v > 65535 {
+				return nil, fmt.Errorf("Invalid port provided. Must be between 1 and 65535. It was %d", v)
+			}
+			switch {
+			case k == "HTTP":
+				lps = append(lps, ListenerPort{false, v})
+			case k == "HTTPS" && certArn != "":
+				lps = append(lps, ListenerPort{true, v})
+			default:
+				return nil, fmt.Errorf("Invalid protocol provided. Must be HTTP or HTTPS and in order to use HTTPS you must have specified a certificate ARN")
+			}
+		}
+	}
+
+	return lps, nil
+}
+
+func parseHealthcheckPath(s string) *string {
+	switch {
+	case s == "":
+		return aws.String("/")
+	}
+	return aws.String(s)
+}
+
+// parseHealthcheckPort returns the port the ALB health checks the targets on: traffic-port, the
+// default, a port number, or the name of a port of the service, which is resolved when the target
+// group is built.
+func parseHealthcheckPort(s string) (*string, error) {
+	if s == "" || s == HealthcheckTrafficPort {
+		return aws.String(HealthcheckTrafficPort), nil
+	}
+	if port, err := strconv.Atoi(s); err == nil {
+		if errs := validation.IsValidPortNum(port); len(errs) > 0 {
+			return nil, fmt.Errorf("Health check port [%v] in %s is invalid: %s", s, healthcheckPortKey, strings.Join(errs, ", "))
+		}
+		return aws.String(s), nil
+	}
+	if errs := validation.IsValidPortName(s); len(errs) > 0 {
+		return nil, fmt.Errorf("Health check port [%v] in %s must be %s, a port number or a port name: %s", s, healthcheckPortKey,
+			HealthcheckTrafficPort, strings.Join(errs, ", "))
+	}
+	return aws.String(s), nil
+}
+
+// parseSuccessCodes returns the HTTP codes healthy targets answer health checks with, 200 when s is
+// empty. Codes can be listed, e.g. 200,301,404, and given as ranges, e.g. 200-399. ALBs accept codes
+// from 200 to 499.
+func parseSuccessCodes(s string) (*string, error) {
+	if s == "" {
+		return aws.String("200"), nil
+	}
+
+	var codes []string
+	for _, code := range stringToAwsSlice(s) {
+		bounds := strings.SplitN(*code, "-", 2)
+		var values []int
+		for _, bound := range bounds {
+			value, err := strconv.Atoi(strings.TrimSpace(bound))
+			if err != nil || value < 200 || value > 499 {
+				return nil, fmt.Errorf("Success code [%v] in %s must be a code or a range of codes between 200 and 499", *code, successCodesKey)
+			}
+			values = append(values, value)
+		}
+		if len(values) == 2 && values[0] >= values[1] {
+			return nil, fmt.Errorf("Success code range [%v] in %s must start with its lower code", *code, successCodesKey)
+		}
+		if len(values) == 2 {
+			codes = append(codes, fmt.Sprintf("%d-%d", values[0], values[1]))
+		} else {
+			codes = append(codes, strconv.Itoa(values[0]))
+		}
+	}
+	if len(codes) == 0 {
+		return nil, fmt.Errorf("%s must list at least one success code", successCodesKey)
+	}
+	return aws.String(strings.Join(codes, ",")), nil
+}
+
+// IsNamedPort reports whether a health check port refers to a port of the service by its name.
+func IsNamedPort(port string) bool {
+	if port == HealthcheckTrafficPort {
+		return false
+	}
+	_, err := strconv.Atoi(port)
+	return err != nil
+}
+
+func parseScheme(s string) (*string, error) {
+	switch {
+	case s == "":
+		return aws.String(""), fmt.Errorf(`Necessary annotations missing. Must include %s`, schemeKey)
+	case s != "internal" && s != "internet-facing":
+		return aws.String(""), fmt.Errorf("ALB Scheme [%v] must be either `internal` or `internet-facing`", s)
+	}
+	return aws.String(s), nil
+}
+
+// parseIPAddressType validates the IP address type of the ALB, `ipv4` when omitted. Only
+// internet-facing ALBs can be dualstack, their subnets then need IPv6 CIDR blocks.
+func parseIPAddressType(s string, scheme *string) (*string, error) {
+	switch {
+	case s == "":
+		return aws.String(elbv2.IpAddressTypeIpv4), nil
+	case s != elbv2.IpAddressTypeIpv4 && s != elbv2.IpAddressTypeDualstack:
+		return nil, fmt.Errorf("%s [%v] must be either `%s` or `%s`", ipAddressTypeKey, s, elbv2.IpAddressTypeIpv4, elbv2.IpAddressTypeDualstack)
+	case s == elbv2.IpAddressTypeDualstack && *scheme != "internet-facing":
+		return nil, fmt.Errorf("%s `%s` requires an internet-facing ALB", ipAddressTypeKey, elbv2.IpAddressTypeDualstack)
+	}
+	return aws.String(s), nil
+}
+
+// parseTargetIPAddressType returns the IP family of the targets of a target group, `ipv4` when
+// omitted. IPv6 targets are pod IPs, so they require target-type `ip`, and are only reachable
+// through a dualstack ALB.
+func parseTargetIPAddressType(s, targetType, ipAddressType string) (*string, error) {
+	switch {
+	case s == "":
+		return aws.String(awsutil.IPAddressTypeIPv4), nil
+	case s != awsutil.IPAddressTypeIPv4 && s != awsutil.IPAddressTypeIPv6:
+		return nil, fmt.Errorf("%s [%v] must be either `%s` or `%s`", targetIPAddressTypeKey, s, awsutil.IPAddressTypeIPv4, awsutil.IPAddressTypeIPv6)
+	case s == awsutil.IPAddressTypeIPv6 && targetType != awsutil.TargetTypeIP:
+		return nil, fmt.Errorf("%s `%s` requires %s to be `%s`", targetIPAddressTypeKey, s, targetTypeKey, awsutil.TargetTypeIP)
+	case s == awsutil.IPAddressTypeIPv6 && ipAddressType != elbv2.IpAddressTypeDualstack:
+		return nil, fmt.Errorf("%s `%s` requires %s to be `%s`", targetIPAddressTypeKey, s, ipAddressTypeKey, elbv2.IpAddressTypeDualstack)
+	}
+	return aws.String(s), nil
+}
+
+func parseTargetType(s string) (*string, error) {
+	switch {
+	case s == "":
+		return aws.String(awsutil.TargetTypeInstance), nil
+	case s != awsutil.TargetTypeInstance && s != awsutil.TargetTypeIP:
+		return nil, fmt.Errorf("ALB target type [%v] must be either `%s` or `%s`", s, awsutil.TargetTypeInstance, awsutil.TargetTypeIP)
+	}
+	return aws.String(s), nil
+}
+
+// parseLoadBalancingAlgorithm returns the algorithm spreading requests over the targets of a target
+// group, or nil when s is empty, leaving the algorithm to target-group-attributes or AWS.
+// Least outstanding requests helps backends whose requests take uneven amounts of time.
+func parseLoadBalancingAlgorithm(s string) (*string, error) {
+	switch s {
+	case "":
+		return nil, nil
+	case awsutil.AlgorithmRoundRobin, awsutil.AlgorithmLeastOutstandingRequests:
+		return aws.String(s), nil
+	}
+	return nil, fmt.Errorf("Load balancing algorithm [%v] must be either `%s` or `%s`", s,
+		awsutil.AlgorithmRoundRobin, awsutil.AlgorithmLeastOutstandingRequests)
+}
+
+// parseCrossZoneLoadBalancing returns whether the ALB nodes of a zone send requests to the targets
+// of the other zones, or nil when s is empty, leaving it to target-group-attributes or AWS. It's
+// `true`, `false` or `use_load_balancer_configuration`, which is enabled for ALBs.
+func parseCrossZoneLoadBalancing(s string) (*string, error) {
+	switch s {
+	case "":
+		return nil, nil
+	case "true", "false", awsutil.CrossZoneLoadBalancerConfiguration:
+		return aws.String(s), nil
+	}
+	return nil, fmt.Errorf("Cross-zone load balancing [%v] in %s must be `true`, `false` or `%s`", s,
+		crossZoneLoadBalancingKey, awsutil.CrossZoneLoadBalancerConfiguration)
+}
+
+// parseBackendProtocol returns the protocol the ALB uses to reach the targets, HTTP when s is empty.
+// With HTTPS, targets terminate TLS themselves. The ALB doesn't verify their certificates, so
+// self-signed ones can be used.
+func parseBackendProtocol(s string) (*string, error) {
+	switch strings.ToUpper(s) {
+	case "":
+		return aws.String(elbv2.ProtocolEnumHttp), nil
+	case elbv2.ProtocolEnumHttp, elbv2.ProtocolEnumHttps:
+		return aws.String(strings.ToUpper(s)), nil
+	}
+	return nil, fmt.Errorf("Backend protocol [%v] in %s must be either `%s` or `%s`", s, backendProtocolKey,
+		elbv2.ProtocolEnumHttp, elbv2.ProtocolEnumHttps)
+}
+
+// parseHealthcheckProtocol returns the protocol the ALB health checks the targets with. When s is
+// empty, health checks use the backendProtocol traffic is sent with.
+func parseHealthcheckProtocol(s, backendProtocol string) (*string, error) {
+	switch strings.ToUpper(s) {
+	case "":
+		return aws.String(backendProtocol), nil
+	case elbv2.ProtocolEnumHttp, elbv2.ProtocolEnumHttps:
+		return aws.String(strings.ToUpper(s)), nil
+	}
+	return nil, fmt.Errorf("Health check protocol [%v] in %s must be either `%s` or `%s`", s, healthcheckProtocolKey,
+		elbv2.ProtocolEnumHttp, elbv2.ProtocolEnumHttps)
+}
+
+// parseBackendProtocolVersion returns the HTTP version the ALB uses to send requests to the targets,
+// HTTP1 when s is empty. HTTP2 needs the targets to be reached over HTTPS.
+func parseBackendProtocolVersion(s, protocol string) (*string, error) {
+	switch strings.ToUpper(s) {
+	case "":
+		return aws.String(awsutil.ProtocolVersionHTTP1), nil
+	case awsutil.ProtocolVersionHTTP1:
+		return aws.String(awsutil.ProtocolVersionHTTP1), nil
+	case awsutil.ProtocolVersionHTTP2:
+		if protocol != elbv2.ProtocolEnumHttps {
+			return nil, fmt.Errorf("Backend protocol version %s in %s requires %s to be `%s`", awsutil.ProtocolVersionHTTP2,
+				backendProtocolVersionKey, backendProtocolKey, elbv2.ProtocolEnumHttps)
+		}
+		return aws.String(awsutil.ProtocolVersionHTTP2), nil
+	}
+	return nil, fmt.Errorf("Backend protocol version [%v] in %s must be either `%s` or `%s`", s, backendProtocolVersionKey,
+		awsutil.ProtocolVersionHTTP1, awsutil.ProtocolVersionHTTP2)
+}
+
+// parseCloudFrontOnly returns the CloudFront origin-facing prefix list when the cloudfront-only
+// annotation is true, limiting inbound traffic of the managed security group to CloudFront.
+func parseCloudFrontOnly(s string) (util.AWSStringSlice, error) {
+	if s == "" {
+		return nil, nil
+	}
+	cloudFrontOnly, err := strconv.ParseBool(s)
+	if err != nil {
+		return nil, fmt.Errorf("%s [%v] must be either `true` or `false`", cloudFrontOnlyKey, s)
+	}
+	if !cloudFrontOnly {
+		return nil, nil
+	}
+	id, err := awsutil.Ec2svc.GetPrefixListID(CloudFrontPrefixListName)
+	if err != nil {
+		return nil, err
+	}
+	return util.AWSStringSlice{id}, nil
+}
+
+// parseAlarms loads the alarm annotations of a target group. Nil is returned when none of the
+// thresholds is set.
+func parseAlarms(annotations map[string]string) (*Alarms, error) {
+	alarms := &Alarms{}
+	if s := annotations[alarmUnhealthyHostsKey]; s != "" {
+		count, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || count < 1 {
+			return nil, fmt.Errorf("%s [%v] must be a number of targets of at least 1", alarmUnhealthyHostsKey, s)
+		}
+		alarms.UnhealthyHosts = aws.Int64(count)
+	}
+	if s := annotations[alarm5xxRateKey]; s != "" {
+		rate, err := strconv.ParseFloat(s, 64)
+		if err != nil || rate <= 0 || rate > 100 {
+			return nil, fmt.Errorf("%s [%v] must be a percentage above 0 and up to 100", alarm5xxRateKey, s)
+		}
+		alarms.Target5xxRate = aws.Float64(rate)
+	}
+	if s := annotations[alarmResponseTimeKey]; s != "" {
+		seconds, err := strconv.ParseFloat(s, 64)
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("%s [%v] must be a number of seconds above 0", alarmResponseTimeKey, s)
+		}
+		alarms.ResponseTime = aws.Float64(seconds)
+	}
+	if s := annotations[alarmSNSTopicArnKey]; s != "" {
+		if !snsTopicArnPattern.MatchString(s) {
+			return nil, fmt.Errorf("%s [%v] must be the ARN of an SNS topic", alarmSNSTopicArnKey, s)
+		}
+		alarms.SNSTopicArn = aws.String(s)
+	}
+
+	if alarms.UnhealthyHosts == nil && alarms.Target5xxRate == nil && alarms.ResponseTime == nil {
+		if alarms.SNSTopicArn != nil {
+			return nil, fmt.Errorf("%s needs one of %s, %s or %s to be set", alarmSNSTopicArnKey,
+				alarmUnhealthyHostsKey, alarm5xxRateKey, alarmResponseTimeKey)
+		}
+		return nil, nil
+	}
+	return alarms, nil
+}
+
+// parseInboundCIDRs splits the inbound-cidrs annotation into IPv4 CIDR blocks and prefix list IDs
+// (pl-xxxx), which the managed security group allows inbound traffic from. CIDR blocks are returned
+// in the normalized form AWS reports them in.
+func parseInboundCIDRs(s string) (cidrs util.AWSStringSlice, prefixLists util.AWSStringSlice, err error) {
+	for _, source := range stringToAwsSlice(s) {
+		if strings.HasPrefix(*source, "pl-") {
+			prefixLists = append(prefixLists, source)
+			continue
+		}
+		ip, ipNet, err := net.ParseCIDR(*source)
+		if err != nil || ip.To4() == nil {
+			return nil, nil, fmt.Errorf("Inbound CIDR [%v] in %s must be an IPv4 CIDR block or a prefix list ID", *source, inboundCIDRsKey)
+		}
+		cidrs = append(cidrs, aws.String(ipNet.String()))
+	}
+	sort.Sort(cidrs)
+	sort.Sort(prefixLists)
+	return cidrs, prefixLists, nil
+}
+
+// parseOutboundRules parses the outbound-rules annotation, a comma separated list of rules of the
+// form destination:port or destination:from-to. Destinations are IPv4 and IPv6 CIDR blocks, prefix
+// list IDs and security group IDs. CIDR blocks are returned in the normalized form AWS reports them
+// in.
+func parseOutboundRules(s string) ([]OutboundRule, error) {
+	var rules []OutboundRule
+	for _, rule := range stringToAwsSlice(s) {
+		i := strings.LastIndex(*rule, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("Outbound rule [%v] in %s must be of the form destination:port or destination:from-to", *rule, outboundRulesKey)
+		}
+		destination, ports := (*rule)[:i], (*rule)[i+1:]
+
+		switch {
+		case strings.HasPrefix(destination, "pl-"), strings.HasPrefix(destination, "sg-"):
+		default:
+			_, ipNet, err := net.ParseCIDR(destination)
+			if err != nil {
+				return nil, fmt.Errorf("Destination [%v] of outbound rule [%v] in %s must be a CIDR block, a prefix list ID or a security group ID",
+					destination, *rule, outboundRulesKey)
+			}
+			destination = ipNet.String()
+		}
+
+		from, to := ports, ports
+		if j := strings.Index(ports, "-"); j >= 0 {
+			from, to = ports[:j], ports[j+1:]
+		}
+		fromPort, errFrom := strconv.ParseInt(from, 10, 64)
+		toPort, errTo := strconv.ParseInt(to, 10, 64)
+		if errFrom != nil || errTo != nil || fromPort < 1 || toPort > 65535 || fromPort > toPort {
+			return nil, fmt.Errorf("Ports [%v] of outbound rule [%v] in %s must be a port or a range of ports between 1 and 65535",
+				ports, *rule, outboundRulesKey)
+		}
+		rules = append(rules, OutboundRule{Destination: destination, FromPort: fromPort, ToPort: toPort})
+	}
+	return rules, nil
+}
+
+func parseRoute53RecordType(s string) (*string, error) {
+	switch {
+	case s == "":
+		return aws.String(route53.RRTypeA), nil
+	case s != route53.RRTypeA && s != route53.RRTypeCname:
+		return nil, fmt.Errorf("Route 53 record type [%v] must be either `%s` or `%s`", s, route53.RRTypeA, route53.RRTypeCname)
+	}
+	return aws.String(s), nil
+}
+
+func parseRoute53TTL(s string) (*int64, error) {
+	if s == "" {
+		return aws.Int64(defaultRoute53TTL), nil
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || i < 0 || i > 2147483647 {
+		return nil, fmt.Errorf("Route 53 TTL [%v] must be a number of seconds between 0 and 2147483647", s)
+	}
+	return &i, nil
+}
+
+// parseRoutingPolicy returns the routing policy described by the route53-* annotations, or nil for
+// a simple record. Only alias records evaluate the health of their ALB, so failover requires one.
+func parseRoutingPolicy(annotations map[string]string, recordType *string, standby *Standby) (*RoutingPolicy, error) {
+	policy := annotations[route53RoutingPolicyKey]
+	if policy == "" || policy == RoutingPolicySimple {
+		for _, key := range []string{route53FailoverKey, route53SetIdentifierKey, route53WeightKey} {
+			if annotations[key] != "" {
+				return nil, fmt.Errorf("%s requires %s", key, route53RoutingPolicyKey)
+			}
+		}
+		return nil, nil
+	}
+
+	routingPolicy := &RoutingPolicy{Type: policy, SetIdentifier: annotations[route53SetIdentifierKey]}
+	switch {
+	case policy != RoutingPolicyWeighted && policy != RoutingPolicyLatency && policy != RoutingPolicyFailover:
+		return nil, fmt.Errorf("%s [%v] must be one of `%s`, `%s`, `%s` or `%s`", route53RoutingPolicyKey, policy,
+			RoutingPolicySimple, RoutingPolicyWeighted, RoutingPolicyLatency, RoutingPolicyFailover)
+	case standby != nil:
+		return nil, fmt.Errorf("%s can't be combined with %s, which manages failover records of its own", route53RoutingPolicyKey, standbyRegionKey)
+	case routingPolicy.SetIdentifier == "" || len(routingPolicy.SetIdentifier) > 128:
+		return nil, fmt.Errorf("%s `%s` requires a %s of 1 to 128 characters", route53RoutingPolicyKey, policy, route53SetIdentifierKey)
+	case policy != RoutingPolicyWeighted && annotations[route53WeightKey] != "":
+		return nil, fmt.Errorf("%s requires %s `%s`", route53WeightKey, route53RoutingPolicyKey, RoutingPolicyWeighted)
+	case policy != RoutingPolicyFailover && annotations[route53FailoverKey] != "":
+		return nil, fmt.Errorf("%s requires %s `%s`", route53FailoverKey, route53RoutingPolicyKey, RoutingPolicyFailover)
+	}
+
+	switch policy {
+	case RoutingPolicyWeighted:
+		s := annotations[route53WeightKey]
+		weight, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || weight < 0 || weight > 255 {
+			return nil, fmt.Errorf("%s [%v] must be a number between 0 and 255", route53WeightKey, s)
+		}
+		routingPolicy.Weight = weight
+	case RoutingPolicyFailover:
+		routingPolicy.Failover = annotations[route53FailoverKey]
+		if routingPolicy.Failover != route53.ResourceRecordSetFailoverPrimary && routingPolicy.Failover != route53.ResourceRecordSetFailoverSecondary {
+			return nil, fmt.Errorf("%s [%v] must be either `%s` or `%s`", route53FailoverKey, routingPolicy.Failover,
+				route53.ResourceRecordSetFailoverPrimary, route53.ResourceRecordSetFailoverSecondary)
+		}
+		if *recordType != route53.RRTypeA {
+			return nil, fmt.Errorf("%s `%s` requires %s `%s`, Route 53 can only fail over between alias records", route53RoutingPolicyKey,
+				RoutingPolicyFailover, route53RecordTypeKey, route53.RRTypeA)
+		}
+	}
+	return routingPolicy, nil
+}
+
+// parseManageDNS returns whether the hostnames of an ingress are published, true unless s is
+// false. Ingresses opting out can't configure the records they no longer have.
+func parseManageDNS(s string, annotations map[string]string, standby *Standby) (bool, error) {
+	if s == "" {
+		return true, nil
+	}
+	manage, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("%s [%v] must be either `true` or `false`", manageDNSKey, s)
+	}
+	if manage {
+		return true, nil
+	}
+	if standby != nil {
+		return false, fmt.Errorf("%s `false` can't be combined with %s, which fails over through the records of the ingress", manageDNSKey, standbyRegionKey)
+	}
+	for _, key := range []string{route53HealthCheckPathKey, route53RoutingPolicyKey} {
+		if annotations[key] != "" {
+			return false, fmt.Errorf("%s `false` can't be combined with %s, which configures the records of the ingress", manageDNSKey, key)
+		}
+	}
+	return false, nil
+}
+
+// parseHostnames returns the hostnames listed in the hostname annotations, comma separated lists,
+// lowercased and sorted. A hostname listed in both is returned once.
+func parseHostnames(values ...string) ([]string, error) {
+	seen := make(map[string]bool)
+	var out []string
+	for _, value := range values {
+		for _, hostname := range stringToAwsSlice(value) {
+			h := strings.ToLower(strings.TrimSuffix(*hostname, "."))
+			if strings.HasPrefix(h, "*.") {
+				return nil, fmt.Errorf("Hostname [%v] is a wildcard, which can't be kept in the tags of an ALB", *hostname)
+			}
+			if errs := validation.IsDNS1123Subdomain(h); len(errs) > 0 {
+				return nil, fmt.Errorf("Hostname [%v] is invalid. %s", *hostname, strings.Join(errs, ", "))
+			}
+			if seen[h] {
+				continue
+			}
+			seen[h] = true
+			out = append(out, h)
+		}
+	}
+	sort.Strings(out)
+	if length := len(strings.Join(out, " ")); length > maxHostnamesLength {
+		return nil, fmt.Errorf("Hostnames %s are %d characters long together, at most %d are supported",
+			strings.Join(out, ", "), length, maxHostnamesLength)
+	}
+	return out, nil
+}
+
+// parseHostHeaderConditions returns whether the rules of an ingress's ALB get a host-header
+// condition, false unless s is true.
+func parseHostHeaderConditions(s string) (bool, error) {
+	if s == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("%s [%v] must be either `true` or `false`", hostHeaderConditionsKey, s)
+	}
+	return enabled, nil
+}
+
+// parseGlobalAccelerator returns the Global Accelerator endpoint group of the global-accelerator
+// annotation s and the global-accelerator-listener-arn annotation listenerArn, nil when the ALB
+// isn't an endpoint of any.
+func parseGlobalAccelerator(s, listenerArn string) (*GlobalAccelerator, error) {
+	enabled := false
+	if s != "" {
+		var err error
+		if enabled, err = strconv.ParseBool(s); err != nil {
+			return nil, fmt.Errorf("%s [%v] must be either `true` or `false`", globalAcceleratorKey, s)
+		}
+	}
+	listenerArn = strings.TrimSpace(listenerArn)
+	switch {
+	case listenerArn == "" && !enabled:
+		return nil, nil
+	case listenerArn == "":
+		return &GlobalAccelerator{}, nil
+	case enabled:
+		return nil, fmt.Errorf("%s can't be combined with %s, the ALB is added to the existing listener instead of an accelerator of its own",
+			globalAcceleratorKey, globalAcceleratorListenerKey)
+	case !globalAcceleratorListenerPattern.MatchString(listenerArn):
+		return nil, fmt.Errorf("%s [%v] must be the ARN of a Global Accelerator listener", globalAcceleratorListenerKey, listenerArn)
+	}
+	return &GlobalAccelerator{ListenerArn: aws.String(listenerArn)}, nil
+}
+
+// parseRoute53HealthCheckPath validates the path the Route 53 health check of a primary failover
+// record requests. Route 53 health checkers only reach internet-facing ALBs, so internal ones fail
+// over on the health of their targets alone.
+func parseRoute53HealthCheckPath(s string, scheme *string, routingPolicy *RoutingPolicy, standby *Standby) (*string, error) {
+	primary := standby != nil || (routingPolicy != nil && routingPolicy.Failover == route53.ResourceRecordSetFailoverPrimary)
+	switch {
+	case s == "":
+		return nil, nil
+	case !primary:
+		return nil, fmt.Errorf("%s requires a primary failover record, from %s or %s `%s` with %s `%s`", route53HealthCheckPathKey,
+			standbyRegionKey, route53RoutingPolicyKey, RoutingPolicyFailover, route53FailoverKey, route53.ResourceRecordSetFailoverPrimary)
+	case *scheme != "internet-facing":
+		return nil, fmt.Errorf("%s requires an internet-facing ALB, Route 53 health checkers can't reach internal ones", route53HealthCheckPathKey)
+	case !strings.HasPrefix(s, "/") || len(s) > 255:
+		return nil, fmt.Errorf("%s [%v] must be a path starting with / of up to 255 characters", route53HealthCheckPathKey, s)
+	}
+	return aws.String(s), nil
+}
+
+// regionPattern matches the name of an AWS region, e.g. us-west-2.
+var regionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-[0-9]$`)
+
+// parseStandby returns the standby ALB described by the standby-* annotations, or nil when there
+// is no standby-region annotation. The subnets and security groups live in the standby region, so
+// only their format is checked. Route 53 can only fail over between alias records.
+func parseStandby(annotations map[string]string, ports []ListenerPort, recordType *string) (*Standby, error) {
+	region := annotations[standbyRegionKey]
+	if region == "" {
+		for _, key := range []string{standbyCertificateArnKey, standbySecurityGroupsKey, standbySubnetsKey, standbyTargetPortKey, standbyTargetsKey} {
+			if annotations[key] != "" {
+				return nil, fmt.Errorf("%s requires %s", key, standbyRegionKey)
+			}
+		}
+		return nil, nil
+	}
+	if !regionPattern.MatchString(region) {
+		return nil, fmt.Errorf("%s [%v] must be the name of an AWS region, e.g. us-west-2", standbyRegionKey, region)
+	}
+	if *recordType != route53.RRTypeA {
+		return nil, fmt.Errorf("%s requires %s `%s`, Route 53 can only fail over between alias records", standbyRegionKey,
+			route53RecordTypeKey, route53.RRTypeA)
+	}
+
+	standby := &Standby{Region: region, TargetPort: 80}
+	for _, subnet := range stringToAwsSlice(annotations[standbySubnetsKey]) {
+		if !strings.HasPrefix(*subnet, "subnet-") {
+			return nil, fmt.Errorf("%s [%v] must be a list of subnet IDs", standbySubnetsKey, annotations[standbySubnetsKey])
+		}
+		standby.Subnets = append(standby.Subnets, subnet)
+	}
+	if len(standby.Subnets) == 0 {
+		return nil, fmt.Errorf("%s requires %s", standbyRegionKey, standbySubnetsKey)
+	}
+	sort.Sort(util.AWSStringSlice(standby.Subnets))
+	for _, sg := range stringToAwsSlice(annotations[standbySecurityGroupsKey]) {
+		if !strings.HasPrefix(*sg, "sg-") {
+			return nil, fmt.Errorf("%s [%v] must be a list of security group IDs", standbySecurityGroupsKey, annotations[standbySecurityGroupsKey])
+		}
+		standby.SecurityGroups = append(standby.SecurityGroups, sg)
+	}
+	sort.Sort(standby.SecurityGroups)
+
+	for _, target := range stringToAwsSlice(annotations[standbyTargetsKey]) {
+		ip := net.ParseIP(*target)
+		if ip == nil || ip.To4() == nil {
+			return nil, fmt.Errorf("Standby target [%v] in %s must be an IPv4 address", *target, standbyTargetsKey)
+		}
+		standby.Targets = append(standby.Targets, aws.String(ip.String()))
+	}
+	if len(standby.Targets) == 0 {
+		return nil, fmt.Errorf("%s requires %s", standbyRegionKey, standbyTargetsKey)
+	}
+	sort.Sort(standby.Targets)
+	if s := annotations[standbyTargetPortKey]; s != "" {
+		port, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("%s [%v] must be a port between 1 and 65535", standbyTargetPortKey, s)
+		}
+		standby.TargetPort = port
+	}
+
+	if s := annotations[standbyCertificateArnKey]; s != "" {
+		standby.CertificateArn = aws.String(s)
+	}
+	for _, port := range ports {
+		if port.HTTPS && standby.CertificateArn == nil {
+			return nil, fmt.Errorf("%s requires %s for the HTTPS listener on port %d, as certificates can't be used "+
+				"outside of their region", standbyRegionKey, standbyCertificateArnKey, port.Port)
+		}
+	}
+	return standby, nil
+}
+
+// parseLoadBalancerName validates an ALB name override. ALB names are up to 32 alphanumeric
+// characters or hyphens, can't begin or end with a hyphen and can't begin with "internal-". When
+// no name is given, nil is returned and the name is generated.
+func parseLoadBalancerName(s string) (*string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if len(s) > 32 {
+		return nil, fmt.Errorf("ALB name [%v] must be 32 characters or less", s)
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			return nil, fmt.Errorf("ALB name [%v] must only contain alphanumeric characters and hyphens", s)
+		}
+	}
+	if strings.HasPrefix(s, "-") || strings.HasSuffix(s, "-") || strings.HasPrefix(s, "internal-") {
+		return nil, fmt.Errorf("ALB name [%v] can't begin or end with a hyphen, or begin with `internal-`", s)
+	}
+	return aws.String(s), nil
+}
+
+// parseActions returns the actions defined in actions.<name> annotations, keyed by name. Each
+// annotation holds a JSON object, e.g. {"Type":"redirect","RedirectConfig":{"Host":"example.com"}}.
+// Only redirect actions are supported.
+func parseActions(annotations map[string]string) (map[string]*Action, error) {
+	actions := make(map[string]*Action)
+	for key, value := range annotations {
+		if !strings.HasPrefix(key, actionsKeyPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(key, actionsKeyPrefix)
+		action := &Action{}
+		if err := json.Unmarshal([]byte(value), action); err != nil {
+			return nil, fmt.Errorf("%s JSON structure was invalid. %s", key, err.Error())
+		}
+		if action.Type != ActionTypeRedirect || action.RedirectConfig == nil {
+			return nil, fmt.Errorf("%s must be an action of Type `%s` with a RedirectConfig", key, ActionTypeRedirect)
+		}
+		if err := action.RedirectConfig.validate(); err != nil {
+			return nil, fmt.Errorf("%s is invalid. %s", key, err.Error())
+		}
+		actions[name] = action
+	}
+	return actions, nil
+}
+
+// parseServiceNamespaces returns the namespaces set in service-namespace.<service> annotations,
+// keyed by service name. Backends naming the service forward to it in that namespace instead of
+// the ingress's, provided the service grants the ingress's namespace access, see
+// AllowsIngressNamespace.
+func parseServiceNamespaces(annotations map[string]string) (map[string]string, error) {
+	namespaces := make(map[string]string)
+	for key, value := range annotations {
+		if !strings.HasPrefix(key, serviceNamespaceKeyPrefix) {
+			continue
+		}
+		service := strings.TrimPrefix(key, serviceNamespaceKeyPrefix)
+		if service == "" {
+			return nil, fmt.Errorf("%s must be followed by the name of a service", serviceNamespaceKeyPrefix)
+		}
+		if errs := validation.IsDNS1123Label(value); len(errs) > 0 {
+			return nil, fmt.Errorf("%s namespace [%v] is invalid. %s", key, value, strings.Join(errs, ", "))
+		}
+		namespaces[service] = value
+	}
+	return namespaces, nil
+}
+
+// AllowsIngressNamespace returns true when the allowed-ingress-namespaces annotation of a service
+// lists namespace, or `*` for every namespace. Ingresses only forward to services in other
+// namespaces that opted in this way.
+func AllowsIngressNamespace(annotations map[string]string, namespace string) bool {
+	for _, allowed := range stringToAwsSlice(annotations[allowedIngressNamespacesKey]) {
+		if *allowed == "*" || *allowed == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RedirectConfig) validate() error {
+	if r.StatusCode == "" {
+		r.StatusCode = "HTTP_301"
+	}
+	if r.StatusCode != "HTTP_301" && r.StatusCode != "HTTP_302" {
+		return fmt.Errorf("StatusCode [%v] must be either `HTTP_301` or `HTTP_302`", r.StatusCode)
+	}
+	if r.Protocol != "" && r.Protocol != "HTTP" && r.Protocol != "HTTPS" && r.Protocol != "#{protocol}" {
+		return fmt.Errorf("Protocol [%v] must be either `HTTP`, `HTTPS` or `#{protocol}`", r.Protocol)
+	}
+	if r.Port != "" && r.Port != "#{port}" {
+		if port, err := strconv.Atoi(r.Port); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("Port [%v] must be between 1 and 65535 or `#{port}`", r.Port)
+		}
+	}
+	if r.Path != "" && !strings.HasPrefix(r.Path, "/") {
+		return fmt.Errorf("Path [%v] must start with a `/`", r.Path)
+	}
+	// A redirect keeping every part of the URL would send clients back to where they came from.
+	if r.Host == "" && r.Path == "" && r.Port == "" && r.Protocol == "" && r.Query == "" {
+		return fmt.Errorf("RedirectConfig must change at least one of Host, Path, Port, Protocol or Query")
+	}
+	return nil
+}
+
+// parseLoadBalancerAttributes parses a comma separated list of key=value pairs into ALB attributes,
+// sorted by key. The keys aren't validated, so attributes added to ALBs after this controller was
+// released can be set as well; AWS rejects unknown ones.
+func parseLoadBalancerAttributes(s string) ([]*elbv2.LoadBalancerAttribute, error) {
+	var out []*elbv2.LoadBalancerAttribute
+	err := parseAttributes(s, loadBalancerAttributesKey, func(key, value string) {
+		out = append(out, &elbv2.LoadBalancerAttribute{Key: aws.String(key), Value: aws.String(value)})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool { return *out[i].Key < *out[j].Key })
+	return out, nil
+}
+
+// parseTargetGroupAttributes parses a comma separated list of key=value pairs into target group
+// attributes, sorted by key. Like ALB attributes, the keys aren't validated.
+func parseTargetGroupAttributes(s string) ([]*elbv2.TargetGroupAttribute, error) {
+	var out []*elbv2.TargetGroupAttribute
+	err := parseAttributes(s, targetGroupAttributesKey, func(key, value string) {
+		out = append(out, &elbv2.TargetGroupAttribute{Key: aws.String(key), Value: aws.String(value)})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool { return *out[i].Key < *out[j].Key })
+	return out, nil
+}
+
+// SetDefaultAttributes sets the attributes applied to every ALB and target group, e.g. to enable
+// access logs to a central bucket. Both are comma separated lists of key=value pairs, like the
+// load-balancer-attributes and target-group-attributes annotations, which take precedence over
+// them. An error is returned when a list is malformed.
+func SetDefaultAttributes(loadBalancerAttributes, targetGroupAttributes string) error {
+	var lbAttributes []*elbv2.LoadBalancerAttribute
+	err := parseAttributes(loadBalancerAttributes, "DEFAULT_LOAD_BALANCER_ATTRIBUTES", func(key, value string) {
+		lbAttributes = append(lbAttributes, &elbv2.LoadBalancerAttribute{Key: aws.String(key), Value: aws.String(value)})
+	})
+	if err != nil {
+		return err
+	}
+	var tgAttributes []*elbv2.TargetGroupAttribute
+	err = parseAttributes(targetGroupAttributes, "DEFAULT_TARGET_GROUP_ATTRIBUTES", func(key, value string) {
+		tgAttributes = append(tgAttributes, &elbv2.TargetGroupAttribute{Key: aws.String(key), Value: aws.String(value)})
+	})
+	if err != nil {
+		return err
+	}
+	if err := validateStickiness(tgAttributes); err != nil {
+		return fmt.Errorf("DEFAULT_TARGET_GROUP_ATTRIBUTES: %s", err.Error())
+	}
+
+	defaultLoadBalancerAttributes, defaultTargetGroupAttributes = lbAttributes, tgAttributes
+	return nil
+}
+
+// withDefaultLoadBalancerAttributes returns attributes along with the default ALB attributes of
+// the groups attributes doesn't set, see attributeGroup.
+func withDefaultLoadBalancerAttributes(attributes []*elbv2.LoadBalancerAttribute) []*elbv2.LoadBalancerAttribute {
+	if len(defaultLoadBalancerAttributes) == 0 {
+		return attributes
+	}
+	groups := make(map[string]bool)
+	for _, attribute := range attributes {
+		groups[attributeGroup(*attribute.Key)] = true
+	}
+	out := attributes
+	for _, attribute := range defaultLoadBalancerAttributes {
+		if !groups[attributeGroup(*attribute.Key)] {
+			out = append(out, attribute)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return *out[i].Key < *out[j].Key })
+	return out
+}
+
+// withDefaultTargetGroupAttributes returns attributes along with the default target group
+// attributes of the groups attributes doesn't set, see attributeGroup.
+func withDefaultTargetGroupAttributes(attributes []*elbv2.TargetGroupAttribute) []*elbv2.TargetGroupAttribute {
+	if len(defaultTargetGroupAttributes) == 0 {
+		return attributes
+	}
+	groups := make(map[string]bool)
+	for _, attribute := range attributes {
+		groups[attributeGroup(*attribute.Key)] = true
+	}
+	out := attributes
+	for _, attribute := range defaultTargetGroupAttributes {
+		if !groups[attributeGroup(*attribute.Key)] {
+			out = append(out, attribute)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return *out[i].Key < *out[j].Key })
+	return out
+}
+
+// attributeGroup returns the group of an attribute, the part of its key before the first dot, e.g.
+// stickiness for stickiness.type. Attributes of a group configure a feature together, so an
+// ingress setting any attribute of a group overrides all the defaults of that group. Mixing them
+// could otherwise send a default cookie name along with another stickiness type.
+func attributeGroup(key string) string {
+	return strings.SplitN(key, ".", 2)[0]
+}
+
+// withTargetGroupAttribute returns attributes with the attribute key set to value, replacing the
+// attribute when it's already listed.
+func withTargetGroupAttribute(attributes []*elbv2.TargetGroupAttribute, key, value string) []*elbv2.TargetGroupAttribute {
+	out := []*elbv2.TargetGroupAttribute{{Key: aws.String(key), Value: aws.String(value)}}
+	for _, attribute := range attributes {
+		if aws.StringValue(attribute.Key) != key {
+			out = append(out, attribute)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return *out[i].Key < *out[j].Key })
+	return out
+}
+
+// validateStickiness checks the stickiness attributes of a target group. With the app_cookie type,
+// the ALB follows a cookie the application already issues, named by the
+// stickiness.app_cookie.cookie_name attribute, instead of issuing its own.
+func validateStickiness(attributes []*elbv2.TargetGroupAttribute) error {
+	values := make(map[string]string)
+	for _, attribute := range attributes {
+		values[*attribute.Key] = aws.StringValue(attribute.Value)
+	}
+
+	stickinessType, hasType := values[stickinessTypeAttribute]
+	cookieName, hasCookieName := values[stickinessCookieNameAttribute]
+	switch {
+	case hasType && stickinessType != stickinessTypeLBCookie && stickinessType != stickinessTypeAppCookie:
+		return fmt.Errorf("%s must be either `%s` or `%s`, it was %v", stickinessTypeAttribute,
+			stickinessTypeLBCookie, stickinessTypeAppCookie, stickinessType)
+	case stickinessType == stickinessTypeAppCookie && !hasCookieName:
+		return fmt.Errorf("%s=%s requires %s", stickinessTypeAttribute, stickinessTypeAppCookie, stickinessCookieNameAttribute)
+	case hasCookieName && stickinessType != stickinessTypeAppCookie:
+		return fmt.Errorf("%s requires %s=%s", stickinessCookieNameAttribute, stickinessTypeAttribute, stickinessTypeAppCookie)
+	}
+	if hasCookieName {
+		if !cookieNamePattern.MatchString(cookieName) {
+			return fmt.Errorf("%s [%v] isn't a valid cookie name", stickinessCookieNameAttribute, cookieName)
+		}
+		// The ALB reserves the names of its own cookies, AWSALB, AWSALBAPP and AWSALBTG.
+		if strings.HasPrefix(cookieName, "AWSALB") {
+			return fmt.Errorf("%s [%v] can't start with AWSALB, it's reserved by the ALB", stickinessCookieNameAttribute, cookieName)
+		}
+	}
+
+	for _, key := range []string{stickinessLBCookieDurationAttribute, stickinessAppCookieDurationAttribute} {
+		value, ok := values[key]
+		if !ok {
+			continue
+		}
+		if seconds, err := strconv.Atoi(value); err != nil || seconds < 1 || seconds > maxStickinessDurationSeconds {
+			return fmt.Errorf("%s must be between 1 and %d seconds, it was %v", key, maxStickinessDurationSeconds, value)
+		}
+	}
+	return nil
+}
+
+// parseAttributes calls add with each key=value pair of the attributes annotation s. An error is
+// returned when a pair is malformed or a key is set twice.
+func parseAttributes(s, annotation string, add func(key, value string)) error {
+	seen := make(map[string]bool)
+	for _, rawAttribute := range stringToAwsSlice(s) {
+		parts := strings.SplitN(*rawAttribute, "=", 2)
+		if len(parts) < 2 || strings.TrimSpace(parts[0]) == "" {
+			return fmt.Errorf("Attribute [%v] in %s must be a key=value pair", *rawAttribute, annotation)
+		}
+		key := strings.TrimSpace(parts[0])
+		if seen[key] {
+			return fmt.Errorf("Attribute %s is set more than once in %s", key, annotation)
+		}
+		seen[key] = true
+		add(key, strings.TrimSpace(parts[1]))
+	}
+	return nil
+}
+
+// parseWebACLID returns the web ACL of the waf-acl-id annotation s, nil when it's empty.
+func parseWebACLID(s string) *string {
+	if s = strings.TrimSpace(s); s == "" {
+		return nil
+	}
+	return aws.String(s)
 }
 
 func parseInt(s string) *int64 {
@@ -1,8 +1,14 @@
 package config
 
-import "testing"
+import (
+	"reflect"
+	"strings"
+	"testing"
 
-//"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+)
 
 func TestParseAnnotations(t *testing.T) {
 	_, err := ParseAnnotations(nil)
@@ -54,6 +60,537 @@ func TestParseScheme(t *testing.T) {
 	}
 }
 
+func TestParseTargetType(t *testing.T) {
+	var tests = []struct {
+		targetType string
+		expected   string
+		pass       bool
+	}{
+		{"", "instance", true},
+		{"instance", "instance", true},
+		{"ip", "ip", true},
+		{"lambda", "", false},
+	}
+
+	for _, tt := range tests {
+		targetType, err := parseTargetType(tt.targetType)
+		if err != nil && tt.pass {
+			t.Errorf("parseTargetType(%v): expected %v, actual %v", tt.targetType, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseTargetType(%v): expected %v, actual %v", tt.targetType, tt.pass, err)
+		}
+		if err == nil && *targetType != tt.expected {
+			t.Errorf("parseTargetType(%v): expected %v, actual %v", tt.targetType, tt.expected, *targetType)
+		}
+	}
+}
+
+func TestParseBackendProtocol(t *testing.T) {
+	var tests = []struct {
+		protocol string
+		expected string
+		pass     bool
+	}{
+		{"", "HTTP", true},
+		{"HTTP", "HTTP", true},
+		{"HTTPS", "HTTPS", true},
+		{"https", "HTTPS", true},
+		{"TCP", "", false},
+	}
+
+	for _, tt := range tests {
+		protocol, err := parseBackendProtocol(tt.protocol)
+		if err != nil && tt.pass {
+			t.Errorf("parseBackendProtocol(%v): expected %v, actual %v", tt.protocol, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseBackendProtocol(%v): expected %v, actual %v", tt.protocol, tt.pass, err)
+		}
+		if err == nil && *protocol != tt.expected {
+			t.Errorf("parseBackendProtocol(%v): expected %v, actual %v", tt.protocol, tt.expected, *protocol)
+		}
+	}
+}
+
+func TestParseHealthcheckProtocol(t *testing.T) {
+	var tests = []struct {
+		protocol        string
+		backendProtocol string
+		expected        string
+		pass            bool
+	}{
+		{"", "HTTP", "HTTP", true},
+		{"", "HTTPS", "HTTPS", true},
+		{"HTTP", "HTTPS", "HTTP", true},
+		{"https", "HTTP", "HTTPS", true},
+		{"TCP", "HTTP", "", false},
+	}
+
+	for _, tt := range tests {
+		protocol, err := parseHealthcheckProtocol(tt.protocol, tt.backendProtocol)
+		if err != nil && tt.pass {
+			t.Errorf("parseHealthcheckProtocol(%v, %v): expected %v, actual %v", tt.protocol, tt.backendProtocol, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseHealthcheckProtocol(%v, %v): expected %v, actual %v", tt.protocol, tt.backendProtocol, tt.pass, err)
+		}
+		if err == nil && *protocol != tt.expected {
+			t.Errorf("parseHealthcheckProtocol(%v, %v): expected %v, actual %v", tt.protocol, tt.backendProtocol, tt.expected, *protocol)
+		}
+	}
+}
+
+func TestParseHealthcheckPort(t *testing.T) {
+	var tests = []struct {
+		port     string
+		expected string
+		pass     bool
+	}{
+		{"", "traffic-port", true},
+		{"traffic-port", "traffic-port", true},
+		{"8081", "8081", true},
+		{"management", "management", true},
+		{"0", "", false},
+		{"70000", "", false},
+		{"Not_A_Port", "", false},
+	}
+
+	for _, tt := range tests {
+		port, err := parseHealthcheckPort(tt.port)
+		if err != nil && tt.pass {
+			t.Errorf("parseHealthcheckPort(%v): expected %v, actual %v", tt.port, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseHealthcheckPort(%v): expected %v, actual %v", tt.port, tt.pass, err)
+		}
+		if err == nil && *port != tt.expected {
+			t.Errorf("parseHealthcheckPort(%v): expected %v, actual %v", tt.port, tt.expected, *port)
+		}
+	}
+}
+
+func TestParseSuccessCodes(t *testing.T) {
+	var tests = []struct {
+		codes    string
+		expected string
+		pass     bool
+	}{
+		{"", "200", true},
+		{"200", "200", true},
+		{"200-399", "200-399", true},
+		{"200, 301,404", "200,301,404", true},
+		{"200,300 - 399", "200,300-399", true},
+		{"100", "", false},
+		{"500", "", false},
+		{"399-200", "", false},
+		{"2xx", "", false},
+		{",", "", false},
+	}
+
+	for _, tt := range tests {
+		codes, err := parseSuccessCodes(tt.codes)
+		if err != nil && tt.pass {
+			t.Errorf("parseSuccessCodes(%v): expected %v, actual %v", tt.codes, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseSuccessCodes(%v): expected %v, actual %v", tt.codes, tt.pass, err)
+		}
+		if err == nil && *codes != tt.expected {
+			t.Errorf("parseSuccessCodes(%v): expected %v, actual %v", tt.codes, tt.expected, *codes)
+		}
+	}
+}
+
+func TestParseBackendProtocolVersion(t *testing.T) {
+	var tests = []struct {
+		version  string
+		protocol string
+		expected string
+		pass     bool
+	}{
+		{"", "HTTP", "HTTP1", true},
+		{"HTTP1", "HTTP", "HTTP1", true},
+		{"http2", "HTTPS", "HTTP2", true},
+		{"HTTP2", "HTTP", "", false},
+		{"GRPC", "HTTPS", "", false},
+	}
+
+	for _, tt := range tests {
+		version, err := parseBackendProtocolVersion(tt.version, tt.protocol)
+		if err != nil && tt.pass {
+			t.Errorf("parseBackendProtocolVersion(%v, %v): expected %v, actual %v", tt.version, tt.protocol, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseBackendProtocolVersion(%v, %v): expected %v, actual %v", tt.version, tt.protocol, tt.pass, err)
+		}
+		if err == nil && *version != tt.expected {
+			t.Errorf("parseBackendProtocolVersion(%v, %v): expected %v, actual %v", tt.version, tt.protocol, tt.expected, *version)
+		}
+	}
+}
+
+func TestParseRoute53RecordType(t *testing.T) {
+	var tests = []struct {
+		recordType string
+		expected   string
+		pass       bool
+	}{
+		{"", "A", true},
+		{"A", "A", true},
+		{"CNAME", "CNAME", true},
+		{"cname", "", false},
+		{"AAAA", "", false},
+	}
+
+	for _, tt := range tests {
+		recordType, err := parseRoute53RecordType(tt.recordType)
+		if err != nil && tt.pass {
+			t.Errorf("parseRoute53RecordType(%v): expected %v, actual %v", tt.recordType, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseRoute53RecordType(%v): expected %v, actual %v", tt.recordType, tt.pass, err)
+		}
+		if err == nil && *recordType != tt.expected {
+			t.Errorf("parseRoute53RecordType(%v): expected %v, actual %v", tt.recordType, tt.expected, *recordType)
+		}
+	}
+}
+
+func TestParseRoute53TTL(t *testing.T) {
+	var tests = []struct {
+		ttl      string
+		expected int64
+		pass     bool
+	}{
+		{"", 300, true},
+		{"0", 0, true},
+		{"60", 60, true},
+		{"-1", 0, false},
+		{"1m", 0, false},
+		{"2147483648", 0, false},
+	}
+
+	for _, tt := range tests {
+		ttl, err := parseRoute53TTL(tt.ttl)
+		if err != nil && tt.pass {
+			t.Errorf("parseRoute53TTL(%v): expected %v, actual %v", tt.ttl, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseRoute53TTL(%v): expected %v, actual %v", tt.ttl, tt.pass, err)
+		}
+		if err == nil && *ttl != tt.expected {
+			t.Errorf("parseRoute53TTL(%v): expected %v, actual %v", tt.ttl, tt.expected, *ttl)
+		}
+	}
+}
+
+func TestParseLoadBalancerName(t *testing.T) {
+	var tests = []struct {
+		name     string
+		expected *string
+		pass     bool
+	}{
+		{"", nil, true},
+		{"my-alb", aws.String("my-alb"), true},
+		{"MyALB01", aws.String("MyALB01"), true},
+		{"abcdefghijklmnopqrstuvwxyz012345", aws.String("abcdefghijklmnopqrstuvwxyz012345"), true},
+		{"abcdefghijklmnopqrstuvwxyz0123456", nil, false},
+		{"my_alb", nil, false},
+		{"-my-alb", nil, false},
+		{"my-alb-", nil, false},
+		{"internal-alb", nil, false},
+	}
+
+	for _, tt := range tests {
+		name, err := parseLoadBalancerName(tt.name)
+		if err != nil && tt.pass {
+			t.Errorf("parseLoadBalancerName(%v): expected %v, actual %v", tt.name, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseLoadBalancerName(%v): expected %v, actual %v", tt.name, tt.pass, err)
+		}
+		if err == nil && aws.StringValue(name) != aws.StringValue(tt.expected) {
+			t.Errorf("parseLoadBalancerName(%v): expected %v, actual %v", tt.name, aws.StringValue(tt.expected), aws.StringValue(name))
+		}
+	}
+}
+
+func TestParseStaticTargets(t *testing.T) {
+	var tests = []struct {
+		staticTargets string
+		expected      []string
+		pass          bool
+	}{
+		{"", nil, true},
+		{"10.1.0.2, 10.1.0.1", []string{"10.1.0.1", "10.1.0.2"}, true},
+		{"10.1.0.1,10.1.0.1", []string{"10.1.0.1"}, true},
+		{"10.1.0.1,example.com", nil, false},
+		{"fd00::1", nil, false},
+	}
+
+	for _, tt := range tests {
+		targets, err := ParseStaticTargets(map[string]string{staticTargetsKey: tt.staticTargets})
+		if err != nil && tt.pass {
+			t.Errorf("ParseStaticTargets(%v): expected %v, actual %v", tt.staticTargets, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("ParseStaticTargets(%v): expected %v, actual %v", tt.staticTargets, tt.pass, err)
+		}
+		var actual []string
+		for _, target := range targets {
+			actual = append(actual, *target)
+		}
+		if err == nil && !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("ParseStaticTargets(%v): expected %v, actual %v", tt.staticTargets, tt.expected, actual)
+		}
+	}
+}
+
+func TestParseCostAllocationTagKeys(t *testing.T) {
+	var tests = []struct {
+		keys     string
+		expected []string
+		pass     bool
+	}{
+		{"", nil, true},
+		{"team, example.com/cost-center", []string{"team", "example.com/cost-center"}, true},
+		{"team,Namespace", nil, false},
+		{"aws:createdBy", nil, false},
+	}
+
+	for _, tt := range tests {
+		keys, err := ParseCostAllocationTagKeys(tt.keys)
+		if err != nil && tt.pass {
+			t.Errorf("ParseCostAllocationTagKeys(%v): expected %v, actual %v", tt.keys, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("ParseCostAllocationTagKeys(%v): expected %v, actual %v", tt.keys, tt.pass, err)
+		}
+		if err == nil && !reflect.DeepEqual(keys, tt.expected) {
+			t.Errorf("ParseCostAllocationTagKeys(%v): expected %v, actual %v", tt.keys, tt.expected, keys)
+		}
+	}
+}
+
+func TestParseNodeSecurityGroups(t *testing.T) {
+	var tests = []struct {
+		nodeSecurityGroups string
+		expected           []string
+		pass               bool
+	}{
+		{"", nil, true},
+		{"sg-0a1b2c3d, sg-4e5f6a7b", []string{"sg-0a1b2c3d", "sg-4e5f6a7b"}, true},
+		{"sg-0a1b2c3d,nodes", nil, false},
+	}
+
+	for _, tt := range tests {
+		ids, err := ParseNodeSecurityGroups(tt.nodeSecurityGroups)
+		if err != nil && tt.pass {
+			t.Errorf("ParseNodeSecurityGroups(%v): expected %v, actual %v", tt.nodeSecurityGroups, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("ParseNodeSecurityGroups(%v): expected %v, actual %v", tt.nodeSecurityGroups, tt.pass, err)
+		}
+		var actual []string
+		for _, id := range ids {
+			actual = append(actual, *id)
+		}
+		if err == nil && !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("ParseNodeSecurityGroups(%v): expected %v, actual %v", tt.nodeSecurityGroups, tt.expected, actual)
+		}
+	}
+}
+
+func TestParseInboundCIDRs(t *testing.T) {
+	var tests = []struct {
+		inboundCIDRs        string
+		expectedCIDRs       []string
+		expectedPrefixLists []string
+		pass                bool
+	}{
+		{"", nil, nil, true},
+		{"10.0.0.0/8, 192.168.1.1/24", []string{"10.0.0.0/8", "192.168.1.0/24"}, nil, true},
+		{"pl-b6a144df,10.0.0.0/8", []string{"10.0.0.0/8"}, []string{"pl-b6a144df"}, true},
+		{"10.0.0.0", nil, nil, false},
+		{"fd00::/8", nil, nil, false},
+	}
+
+	for _, tt := range tests {
+		cidrs, prefixLists, err := parseInboundCIDRs(tt.inboundCIDRs)
+		if err != nil && tt.pass {
+			t.Errorf("parseInboundCIDRs(%v): expected %v, actual %v", tt.inboundCIDRs, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseInboundCIDRs(%v): expected %v, actual %v", tt.inboundCIDRs, tt.pass, err)
+		}
+		var actualCIDRs, actualPrefixLists []string
+		for _, cidr := range cidrs {
+			actualCIDRs = append(actualCIDRs, *cidr)
+		}
+		for _, prefixList := range prefixLists {
+			actualPrefixLists = append(actualPrefixLists, *prefixList)
+		}
+		if err == nil && !reflect.DeepEqual(actualCIDRs, tt.expectedCIDRs) {
+			t.Errorf("parseInboundCIDRs(%v): expected CIDRs %v, actual %v", tt.inboundCIDRs, tt.expectedCIDRs, actualCIDRs)
+		}
+		if err == nil && !reflect.DeepEqual(actualPrefixLists, tt.expectedPrefixLists) {
+			t.Errorf("parseInboundCIDRs(%v): expected prefix lists %v, actual %v", tt.inboundCIDRs, tt.expectedPrefixLists, actualPrefixLists)
+		}
+	}
+}
+
+func TestParseOutboundRules(t *testing.T) {
+	var tests = []struct {
+		outboundRules string
+		expected      []OutboundRule
+		pass          bool
+	}{
+		{"", nil, true},
+		{"10.0.0.1/16:30000-32767", []OutboundRule{{"10.0.0.0/16", 30000, 32767}}, true},
+		{"sg-0abc:8080, pl-b6a144df:443", []OutboundRule{{"sg-0abc", 8080, 8080}, {"pl-b6a144df", 443, 443}}, true},
+		{"fd00::/8:443", []OutboundRule{{"fd00::/8", 443, 443}}, true},
+		{"10.0.0.0/8", nil, false},
+		{"10.0.0.0:443", nil, false},
+		{"10.0.0.0/8:0", nil, false},
+		{"10.0.0.0/8:443-80", nil, false},
+		{"10.0.0.0/8:all", nil, false},
+	}
+
+	for _, tt := range tests {
+		rules, err := parseOutboundRules(tt.outboundRules)
+		if err != nil && tt.pass {
+			t.Errorf("parseOutboundRules(%v): expected %v, actual %v", tt.outboundRules, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseOutboundRules(%v): expected %v, actual %v", tt.outboundRules, tt.pass, err)
+		}
+		if err == nil && !reflect.DeepEqual(rules, tt.expected) {
+			t.Errorf("parseOutboundRules(%v): expected %v, actual %v", tt.outboundRules, tt.expected, rules)
+		}
+	}
+}
+
+func TestParseLoadBalancerAttributes(t *testing.T) {
+	var tests = []struct {
+		attributes string
+		expected   []string
+		pass       bool
+	}{
+		{"", nil, true},
+		{"idle_timeout.timeout_seconds=120", []string{"idle_timeout.timeout_seconds=120"}, true},
+		{"routing.http2.enabled=false, deletion_protection.enabled=true",
+			[]string{"deletion_protection.enabled=true", "routing.http2.enabled=false"}, true},
+		{"access_logs.s3.prefix=a=b", []string{"access_logs.s3.prefix=a=b"}, true},
+		{"access_logs.s3.prefix=", []string{"access_logs.s3.prefix="}, true},
+		{"idle_timeout.timeout_seconds", nil, false},
+		{"=120", nil, false},
+		{"idle_timeout.timeout_seconds=60,idle_timeout.timeout_seconds=120", nil, false},
+	}
+
+	for _, tt := range tests {
+		attributes, err := parseLoadBalancerAttributes(tt.attributes)
+		if err != nil && tt.pass {
+			t.Errorf("parseLoadBalancerAttributes(%v): expected %v, actual %v", tt.attributes, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseLoadBalancerAttributes(%v): expected %v, actual %v", tt.attributes, tt.pass, err)
+		}
+		var actual []string
+		for _, attribute := range attributes {
+			actual = append(actual, *attribute.Key+"="+*attribute.Value)
+		}
+		if err == nil && !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("parseLoadBalancerAttributes(%v): expected %v, actual %v", tt.attributes, tt.expected, actual)
+		}
+	}
+}
+
+func TestParseActions(t *testing.T) {
+	var tests = []struct {
+		annotation string
+		expected   *RedirectConfig
+		pass       bool
+	}{
+		{`{"Type":"redirect","RedirectConfig":{"Host":"example.com"}}`, &RedirectConfig{Host: "example.com", StatusCode: "HTTP_301"}, true},
+		{`{"Type":"redirect","RedirectConfig":{"Protocol":"HTTPS","Port":"443","StatusCode":"HTTP_302"}}`,
+			&RedirectConfig{Protocol: "HTTPS", Port: "443", StatusCode: "HTTP_302"}, true},
+		{`{"Type":"redirect","RedirectConfig":{"Path":"/new/#{path}","Query":"#{query}&from=old"}}`,
+			&RedirectConfig{Path: "/new/#{path}", Query: "#{query}&from=old", StatusCode: "HTTP_301"}, true},
+		{`{"Type":"redirect","RedirectConfig":{}}`, nil, false},
+		{`{"Type":"redirect","RedirectConfig":{"Host":"example.com","StatusCode":"HTTP_307"}}`, nil, false},
+		{`{"Type":"redirect","RedirectConfig":{"Protocol":"FTP"}}`, nil, false},
+		{`{"Type":"redirect","RedirectConfig":{"Port":"70000"}}`, nil, false},
+		{`{"Type":"redirect","RedirectConfig":{"Path":"new"}}`, nil, false},
+		{`{"Type":"forward"}`, nil, false},
+		{`{"Type":"redirect"`, nil, false},
+	}
+
+	for _, tt := range tests {
+		actions, err := parseActions(map[string]string{actionsKeyPrefix + "old-site": tt.annotation, subnetsKey: "subnet-a"})
+		if err != nil && tt.pass {
+			t.Errorf("parseActions(%v): expected %v, actual %v", tt.annotation, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseActions(%v): expected %v, actual %v", tt.annotation, tt.pass, err)
+		}
+		if err == nil && !reflect.DeepEqual(actions["old-site"].RedirectConfig, tt.expected) {
+			t.Errorf("parseActions(%v): expected %+v, actual %+v", tt.annotation, tt.expected, actions["old-site"].RedirectConfig)
+		}
+		if err == nil && len(actions) != 1 {
+			t.Errorf("parseActions(%v): expected 1 action, actual %d", tt.annotation, len(actions))
+		}
+	}
+}
+
+func TestParseServiceNamespaces(t *testing.T) {
+	var tests = []struct {
+		annotations map[string]string
+		expected    map[string]string
+		pass        bool
+	}{
+		{map[string]string{subnetsKey: "subnet-a"}, map[string]string{}, true},
+		{map[string]string{serviceNamespaceKeyPrefix + "api": "shared", serviceNamespaceKeyPrefix + "web": "frontend"},
+			map[string]string{"api": "shared", "web": "frontend"}, true},
+		{map[string]string{serviceNamespaceKeyPrefix + "api": "Shared"}, nil, false},
+		{map[string]string{serviceNamespaceKeyPrefix + "api": ""}, nil, false},
+		{map[string]string{serviceNamespaceKeyPrefix: "shared"}, nil, false},
+	}
+
+	for _, tt := range tests {
+		namespaces, err := parseServiceNamespaces(tt.annotations)
+		if err != nil && tt.pass {
+			t.Errorf("parseServiceNamespaces(%v): expected %v, actual %v", tt.annotations, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseServiceNamespaces(%v): expected %v, actual %v", tt.annotations, tt.pass, err)
+		}
+		if err == nil && !reflect.DeepEqual(namespaces, tt.expected) {
+			t.Errorf("parseServiceNamespaces(%v): expected %v, actual %v", tt.annotations, tt.expected, namespaces)
+		}
+	}
+}
+
+func TestAllowsIngressNamespace(t *testing.T) {
+	var tests = []struct {
+		annotation string
+		namespace  string
+		expected   bool
+	}{
+		{"", "team-a", false},
+		{"team-a, team-b", "team-b", true},
+		{"team-a", "team-c", false},
+		{"*", "team-c", true},
+	}
+
+	for _, tt := range tests {
+		allowed := AllowsIngressNamespace(map[string]string{allowedIngressNamespacesKey: tt.annotation}, tt.namespace)
+		if allowed != tt.expected {
+			t.Errorf("AllowsIngressNamespace(%v, %v): expected %v, actual %v", tt.annotation, tt.namespace, tt.expected, allowed)
+		}
+	}
+}
+
 // TODO: Fix this up, can't compare the pointers
 // func TestParseSecurityGroups(t *testing.T) {
 // 	setupEC2()
@@ -77,3 +614,712 @@ func TestParseScheme(t *testing.T) {
 // 		}
 // 	}
 // }
+
+func TestParseBackendOverrides(t *testing.T) {
+	annotations := map[string]string{
+		backendProtocolKey:                   "HTTP",
+		healthcheckPathKey:                   "/healthz",
+		successCodesKey:                      "200",
+		healthcheckPathKey + ".api":          "/api/health",
+		targetGroupAttributesKey + ".api":    "stickiness.enabled=true",
+		backendProtocolKey + ".secure":       "HTTPS",
+		healthcheckPortKey + ".empty":        "",
+		targetGroupAttributesKey + ".broken": "stickiness.enabled",
+	}
+
+	a := &Annotations{}
+	if err := a.parseBackend(annotations); err != nil {
+		t.Fatalf("parseBackend: unexpected error %v", err)
+	}
+	if _, err := a.parseBackendOverrides(annotations); err == nil {
+		t.Errorf("parseBackendOverrides: expected an error for the broken service's attributes")
+	}
+	delete(annotations, targetGroupAttributesKey+".broken")
+
+	backends, err := a.parseBackendOverrides(annotations)
+	if err != nil {
+		t.Fatalf("parseBackendOverrides: unexpected error %v", err)
+	}
+	a.backends = backends
+
+	if len(backends) != 2 {
+		t.Errorf("parseBackendOverrides: expected overrides for 2 services, actual %d", len(backends))
+	}
+	api := a.ForBackend("api")
+	if *api.HealthcheckPath != "/api/health" || *api.BackendProtocol != "HTTP" || len(api.TargetGroupAttributes) != 1 {
+		t.Errorf("ForBackend(api): expected the api overrides on top of the ingress annotations, actual %+v", api)
+	}
+	secure := a.ForBackend("secure")
+	if *secure.BackendProtocol != "HTTPS" || *secure.HealthcheckPath != "/healthz" || *secure.SuccessCodes != "200" {
+		t.Errorf("ForBackend(secure): expected the secure overrides on top of the ingress annotations, actual %+v", secure)
+	}
+	if a.ForBackend("empty") != a || a.ForBackend("other") != a {
+		t.Errorf("ForBackend: expected the ingress annotations for services without overrides")
+	}
+}
+
+func TestWithCertificateArns(t *testing.T) {
+	annotations := map[string]string{certificateArnKey: "arn:a, arn:b,", portKey: `[{"HTTPS": 443}]`}
+	if arns := CertificateArns(annotations); !reflect.DeepEqual(arns, []string{"arn:a", "arn:b"}) {
+		t.Errorf("CertificateArns(%v): expected [arn:a arn:b], actual %v", annotations, arns)
+	}
+
+	out := WithCertificateArns(annotations, []string{"arn:c", "arn:a"})
+	if out[certificateArnKey] != "arn:c,arn:a" || out[portKey] != annotations[portKey] {
+		t.Errorf("WithCertificateArns: expected certificate-arn arn:c,arn:a with the other annotations, actual %v", out)
+	}
+	if annotations[certificateArnKey] != "arn:a, arn:b," {
+		t.Errorf("WithCertificateArns: expected the annotations passed in to be left unchanged, actual %v", annotations)
+	}
+}
+
+func TestMergeAnnotations(t *testing.T) {
+	names := []string{"app", "api"}
+	members := []map[string]string{
+		{schemeKey: "internet-facing", certificateArnKey: "arn:a", hostnameKey: "www.example.com", "kubernetes.io/ingress.class": "alb"},
+		{schemeKey: "internet-facing", certificateArnKey: "arn:b,arn:a", externalDNSHostnameKey: "api.example.org"},
+	}
+	merged, err := MergeAnnotations(names, members, []string{"app.example.com", "www.example.com"})
+	if err != nil {
+		t.Fatalf("MergeAnnotations() returned error %v", err)
+	}
+	expected := map[string]string{
+		schemeKey:              "internet-facing",
+		certificateArnKey:      "arn:a,arn:b",
+		hostnameKey:            "app.example.com,www.example.com",
+		externalDNSHostnameKey: "api.example.org",
+	}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("MergeAnnotations(): expected %v, actual %v", expected, merged)
+	}
+
+	members[1][schemeKey] = "internal"
+	if _, err := MergeAnnotations(names, members, nil); err == nil || !strings.Contains(err.Error(), "app and api") {
+		t.Errorf("MergeAnnotations(): expected an error naming app and api for the conflicting schemes, actual %v", err)
+	}
+}
+
+func TestListensHTTPOnly(t *testing.T) {
+	var tests = []struct {
+		ports    string
+		expected bool
+	}{
+		{"", false},
+		{`[{"HTTP": 80}]`, true},
+		{`[{"HTTP": 80}, {"HTTP": 8080}]`, true},
+		{`[{"HTTP": 80}, {"HTTPS": 443}]`, false},
+		{`[{"HTTPS": 443}]`, false},
+		{`not json`, false},
+	}
+	for _, tt := range tests {
+		if actual := ListensHTTPOnly(map[string]string{portKey: tt.ports}); actual != tt.expected {
+			t.Errorf("ListensHTTPOnly(%q): expected %v, actual %v", tt.ports, tt.expected, actual)
+		}
+	}
+}
+
+func TestParseLoadBalancingAlgorithm(t *testing.T) {
+	var tests = []struct {
+		algorithm string
+		pass      bool
+	}{
+		{"", true},
+		{"round_robin", true},
+		{"least_outstanding_requests", true},
+		{"weighted_random", false},
+		{"ROUND_ROBIN", false},
+	}
+
+	for _, tt := range tests {
+		algorithm, err := parseLoadBalancingAlgorithm(tt.algorithm)
+		if err != nil && tt.pass {
+			t.Errorf("parseLoadBalancingAlgorithm(%v): expected %v, actual %v", tt.algorithm, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseLoadBalancingAlgorithm(%v): expected %v, actual %v", tt.algorithm, tt.pass, err)
+		}
+		if err == nil && aws.StringValue(algorithm) != tt.algorithm {
+			t.Errorf("parseLoadBalancingAlgorithm(%v): expected %v, actual %v", tt.algorithm, tt.algorithm, aws.StringValue(algorithm))
+		}
+	}
+
+	// The algorithm annotation takes precedence over the same attribute in target-group-attributes.
+	a := &Annotations{}
+	err := a.parseBackend(map[string]string{
+		targetGroupAttributesKey:  "load_balancing.algorithm.type=round_robin,stickiness.enabled=true",
+		loadBalancingAlgorithmKey: "least_outstanding_requests",
+	})
+	if err != nil {
+		t.Fatalf("parseBackend: unexpected error %v", err)
+	}
+	attributes := make(map[string]string)
+	for _, attribute := range a.TargetGroupAttributes {
+		attributes[*attribute.Key] = *attribute.Value
+	}
+	expected := map[string]string{
+		"load_balancing.algorithm.type": "least_outstanding_requests",
+		"stickiness.enabled":            "true",
+	}
+	if !reflect.DeepEqual(attributes, expected) {
+		t.Errorf("parseBackend: expected attributes %v, actual %v", expected, attributes)
+	}
+}
+
+func TestValidateStickiness(t *testing.T) {
+	var tests = []struct {
+		attributes string
+		pass       bool
+	}{
+		{"", true},
+		{"stickiness.enabled=true,stickiness.type=lb_cookie,stickiness.lb_cookie.duration_seconds=3600", true},
+		{"stickiness.enabled=true,stickiness.type=app_cookie,stickiness.app_cookie.cookie_name=SESSIONID", true},
+		{"stickiness.type=app_cookie,stickiness.app_cookie.cookie_name=session_id,stickiness.app_cookie.duration_seconds=604800", true},
+		{"stickiness.type=source_ip", false},
+		{"stickiness.type=app_cookie", false},
+		{"stickiness.type=lb_cookie,stickiness.app_cookie.cookie_name=SESSIONID", false},
+		{"stickiness.type=app_cookie,stickiness.app_cookie.cookie_name=AWSALBAPP-0", false},
+		{"stickiness.type=app_cookie,stickiness.app_cookie.cookie_name=session id", false},
+		{"stickiness.type=app_cookie,stickiness.app_cookie.cookie_name=a;b", false},
+		{"stickiness.lb_cookie.duration_seconds=0", false},
+		{"stickiness.app_cookie.duration_seconds=604801", false},
+	}
+
+	for _, tt := range tests {
+		attributes, err := parseTargetGroupAttributes(tt.attributes)
+		if err != nil {
+			t.Fatalf("parseTargetGroupAttributes(%v): unexpected error %v", tt.attributes, err)
+		}
+		err = validateStickiness(attributes)
+		if err != nil && tt.pass {
+			t.Errorf("validateStickiness(%v): expected %v, actual %v", tt.attributes, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("validateStickiness(%v): expected %v, actual %v", tt.attributes, tt.pass, err)
+		}
+	}
+}
+
+func TestDefaultAttributes(t *testing.T) {
+	if err := SetDefaultAttributes("access_logs.s3.enabled", ""); err == nil {
+		t.Errorf("SetDefaultAttributes: expected an error for a malformed attribute")
+	}
+	if err := SetDefaultAttributes("", "stickiness.type=app_cookie"); err == nil {
+		t.Errorf("SetDefaultAttributes: expected an error for app_cookie stickiness without a cookie name")
+	}
+
+	err := SetDefaultAttributes("access_logs.s3.enabled=true,access_logs.s3.bucket=logs",
+		"deregistration_delay.timeout_seconds=30,stickiness.enabled=true,stickiness.type=app_cookie,stickiness.app_cookie.cookie_name=SESSIONID")
+	if err != nil {
+		t.Fatalf("SetDefaultAttributes: unexpected error %v", err)
+	}
+	defer SetDefaultAttributes("", "")
+
+	lbAttributes := withDefaultLoadBalancerAttributes(nil)
+	if len(lbAttributes) != 2 {
+		t.Errorf("withDefaultLoadBalancerAttributes(nil): expected the 2 default attributes, actual %v", lbAttributes)
+	}
+	lbAttributes, _ = parseLoadBalancerAttributes("access_logs.s3.enabled=false,idle_timeout.timeout_seconds=120")
+	lbAttributes = withDefaultLoadBalancerAttributes(lbAttributes)
+	if len(lbAttributes) != 2 || *lbAttributes[0].Value != "false" {
+		t.Errorf("withDefaultLoadBalancerAttributes: expected the access_logs defaults to be overridden, actual %v", lbAttributes)
+	}
+
+	var tests = []struct {
+		annotations map[string]string
+		expected    map[string]string
+	}{
+		{
+			map[string]string{},
+			map[string]string{
+				"deregistration_delay.timeout_seconds": "30",
+				"stickiness.enabled":                   "true",
+				"stickiness.type":                      "app_cookie",
+				"stickiness.app_cookie.cookie_name":    "SESSIONID",
+			},
+		},
+		{
+			map[string]string{targetGroupAttributesKey: "stickiness.enabled=true,stickiness.type=lb_cookie"},
+			map[string]string{
+				"deregistration_delay.timeout_seconds": "30",
+				"stickiness.enabled":                   "true",
+				"stickiness.type":                      "lb_cookie",
+			},
+		},
+		{
+			map[string]string{targetGroupAttributesKey: "deregistration_delay.timeout_seconds=300", loadBalancingAlgorithmKey: "least_outstanding_requests"},
+			map[string]string{
+				"deregistration_delay.timeout_seconds": "300",
+				"load_balancing.algorithm.type":        "least_outstanding_requests",
+				"stickiness.enabled":                   "true",
+				"stickiness.type":                      "app_cookie",
+				"stickiness.app_cookie.cookie_name":    "SESSIONID",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		a := &Annotations{}
+		if err := a.parseBackend(tt.annotations); err != nil {
+			t.Fatalf("parseBackend(%v): unexpected error %v", tt.annotations, err)
+		}
+		attributes := make(map[string]string)
+		for _, attribute := range a.TargetGroupAttributes {
+			attributes[*attribute.Key] = *attribute.Value
+		}
+		if !reflect.DeepEqual(attributes, tt.expected) {
+			t.Errorf("parseBackend(%v): expected attributes %v, actual %v", tt.annotations, tt.expected, attributes)
+		}
+	}
+}
+
+func TestParseCrossZoneLoadBalancing(t *testing.T) {
+	var tests = []struct {
+		crossZone string
+		pass      bool
+	}{
+		{"", true},
+		{"true", true},
+		{"false", true},
+		{"use_load_balancer_configuration", true},
+		{"TRUE", false},
+		{"enabled", false},
+	}
+
+	for _, tt := range tests {
+		crossZone, err := parseCrossZoneLoadBalancing(tt.crossZone)
+		if err != nil && tt.pass {
+			t.Errorf("parseCrossZoneLoadBalancing(%v): expected %v, actual %v", tt.crossZone, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseCrossZoneLoadBalancing(%v): expected %v, actual %v", tt.crossZone, tt.pass, err)
+		}
+		if err == nil && aws.StringValue(crossZone) != tt.crossZone {
+			t.Errorf("parseCrossZoneLoadBalancing(%v): expected %v, actual %v", tt.crossZone, tt.crossZone, aws.StringValue(crossZone))
+		}
+	}
+
+	// The cross-zone annotation takes precedence over the same attribute in target-group-attributes.
+	a := &Annotations{}
+	err := a.parseBackend(map[string]string{
+		targetGroupAttributesKey:  "load_balancing.cross_zone.enabled=true,load_balancing.algorithm.type=round_robin",
+		crossZoneLoadBalancingKey: "false",
+	})
+	if err != nil {
+		t.Fatalf("parseBackend: unexpected error %v", err)
+	}
+	attributes := make(map[string]string)
+	for _, attribute := range a.TargetGroupAttributes {
+		attributes[*attribute.Key] = *attribute.Value
+	}
+	expected := map[string]string{
+		"load_balancing.algorithm.type":     "round_robin",
+		"load_balancing.cross_zone.enabled": "false",
+	}
+	if !reflect.DeepEqual(attributes, expected) {
+		t.Errorf("parseBackend: expected attributes %v, actual %v", expected, attributes)
+	}
+}
+
+func TestEnforceHTTPSOnly(t *testing.T) {
+	http := []ListenerPort{{HTTPS: false, Port: 80}, {HTTPS: true, Port: 443}}
+	https := []ListenerPort{{HTTPS: true, Port: 443}}
+
+	var tests = []struct {
+		httpsOnly bool
+		ports     []ListenerPort
+		allowHTTP string
+		pass      bool
+	}{
+		{false, http, "", true},
+		{true, https, "", true},
+		{true, http, "", false},
+		{true, http, "false", false},
+		{true, http, "true", true},
+		{false, http, "maybe", false},
+	}
+
+	defer SetHTTPSOnly(false)
+	for _, tt := range tests {
+		SetHTTPSOnly(tt.httpsOnly)
+		err := enforceHTTPSOnly(tt.ports, tt.allowHTTP)
+		if err != nil && tt.pass {
+			t.Errorf("enforceHTTPSOnly(%v, %v) with HTTPS only %v: expected %v, actual %v", tt.ports, tt.allowHTTP, tt.httpsOnly, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("enforceHTTPSOnly(%v, %v) with HTTPS only %v: expected %v, actual %v", tt.ports, tt.allowHTTP, tt.httpsOnly, tt.pass, err)
+		}
+	}
+}
+
+func TestLowestProtocol(t *testing.T) {
+	var tests = []struct {
+		protocols []string
+		expected  string
+	}{
+		{[]string{"TLSv1.2"}, "TLSv1.2"},
+		{[]string{"TLSv1.2", "TLSv1", "TLSv1.1"}, "TLSv1"},
+		{[]string{"SSLv3", "TLSv1"}, "SSLv3"},
+		{[]string{"TLSv1.3", "TLSv1.2"}, "TLSv1.2"},
+		{[]string{"TLSv9"}, "TLSv1.3"},
+		{nil, "TLSv1.3"},
+	}
+
+	for _, tt := range tests {
+		policy := &elbv2.SslPolicy{SslProtocols: aws.StringSlice(tt.protocols)}
+		actual := sslProtocols[lowestProtocol(policy)]
+		if actual != tt.expected {
+			t.Errorf("lowestProtocol(%v): expected %v, actual %v", tt.protocols, tt.expected, actual)
+		}
+	}
+}
+
+func TestFilterAnnotations(t *testing.T) {
+	annotations := map[string]string{
+		"alb.ingress.kubernetes.io/subnets":         "subnet-1",
+		"alb.ingress.kubernetes.io/security-groups": "sg-1",
+		"alb.ingress.kubernetes.io/listen-ports":    `[{"HTTP": 80}]`,
+		"kubernetes.io/ingress.class":               "alb",
+	}
+
+	var tests = []struct {
+		allowed  string
+		kept     []string
+		expected []string
+	}{
+		{"", []string{"alb.ingress.kubernetes.io/subnets", "alb.ingress.kubernetes.io/security-groups"}, nil},
+		{"subnets, alb.ingress.kubernetes.io/listen-ports", []string{"alb.ingress.kubernetes.io/subnets", "kubernetes.io/ingress.class"},
+			[]string{"alb.ingress.kubernetes.io/security-groups"}},
+		{"subnets", []string{"kubernetes.io/ingress.class"},
+			[]string{"alb.ingress.kubernetes.io/listen-ports", "alb.ingress.kubernetes.io/security-groups"}},
+	}
+
+	defer SetAllowedAnnotations("")
+	for _, tt := range tests {
+		SetAllowedAnnotations(tt.allowed)
+		out, ignored := FilterAnnotations(annotations)
+		if !reflect.DeepEqual(ignored, tt.expected) {
+			t.Errorf("FilterAnnotations(%v) allowing %q: expected %v, actual %v", annotations, tt.allowed, tt.expected, ignored)
+		}
+		for _, k := range tt.kept {
+			if out[k] != annotations[k] {
+				t.Errorf("FilterAnnotations(%v) allowing %q: expected %v to be kept", annotations, tt.allowed, k)
+			}
+		}
+	}
+}
+
+func TestParseStandby(t *testing.T) {
+	a := aws.String("A")
+	http := []ListenerPort{{HTTPS: false, Port: 80}}
+	https := []ListenerPort{{HTTPS: true, Port: 443}}
+	valid := map[string]string{
+		standbyRegionKey:  "us-west-2",
+		standbySubnetsKey: "subnet-2,subnet-1",
+		standbyTargetsKey: "10.1.0.2,10.1.0.1",
+	}
+	with := func(k, v string) map[string]string {
+		out := map[string]string{}
+		for key, value := range valid {
+			out[key] = value
+		}
+		out[k] = v
+		return out
+	}
+
+	var tests = []struct {
+		annotations map[string]string
+		ports       []ListenerPort
+		recordType  *string
+		pass        bool
+	}{
+		{map[string]string{}, http, a, true},
+		{valid, http, a, true},
+		{valid, http, aws.String("CNAME"), false},
+		{valid, https, a, false},
+		{with(standbyCertificateArnKey, "arn:aws:acm:us-west-2:123456789012:certificate/1"), https, a, true},
+		{with(standbyRegionKey, "west"), http, a, false},
+		{with(standbySubnetsKey, ""), http, a, false},
+		{with(standbySubnetsKey, "sg-1"), http, a, false},
+		{with(standbyTargetsKey, "node-1"), http, a, false},
+		{with(standbyTargetPortKey, "8080"), http, a, true},
+		{with(standbyTargetPortKey, "0"), http, a, false},
+		{with(standbySecurityGroupsKey, "subnet-1"), http, a, false},
+		{map[string]string{standbyTargetsKey: "10.1.0.1"}, http, a, false},
+	}
+
+	for _, tt := range tests {
+		standby, err := parseStandby(tt.annotations, tt.ports, tt.recordType)
+		if err != nil && tt.pass {
+			t.Errorf("parseStandby(%v): expected %v, actual %v", tt.annotations, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseStandby(%v): expected %v, actual %v", tt.annotations, tt.pass, err)
+		}
+		if standby != nil && (*standby.Subnets[0] != "subnet-1" || *standby.Targets[0] != "10.1.0.1") {
+			t.Errorf("parseStandby(%v): expected sorted subnets and targets, actual %v %v", tt.annotations,
+				awsutil.Prettify(standby.Subnets), awsutil.Prettify(standby.Targets))
+		}
+	}
+}
+
+func TestParseRoutingPolicy(t *testing.T) {
+	a, cname := aws.String("A"), aws.String("CNAME")
+	var tests = []struct {
+		annotations map[string]string
+		recordType  *string
+		standby     *Standby
+		expected    *RoutingPolicy
+		pass        bool
+	}{
+		{map[string]string{}, a, nil, nil, true},
+		{map[string]string{route53RoutingPolicyKey: "simple"}, a, nil, nil, true},
+		{map[string]string{route53WeightKey: "10"}, a, nil, nil, false},
+		{map[string]string{route53RoutingPolicyKey: "geolocation", route53SetIdentifierKey: "east"}, a, nil, nil, false},
+		{map[string]string{route53RoutingPolicyKey: "weighted", route53WeightKey: "10"}, a, nil, nil, false},
+		{map[string]string{route53RoutingPolicyKey: "weighted", route53SetIdentifierKey: "east", route53WeightKey: "10"}, cname, nil,
+			&RoutingPolicy{Type: RoutingPolicyWeighted, SetIdentifier: "east", Weight: 10}, true},
+		{map[string]string{route53RoutingPolicyKey: "weighted", route53SetIdentifierKey: "east"}, a, nil, nil, false},
+		{map[string]string{route53RoutingPolicyKey: "weighted", route53SetIdentifierKey: "east", route53WeightKey: "256"}, a, nil, nil, false},
+		{map[string]string{route53RoutingPolicyKey: "latency", route53SetIdentifierKey: "east"}, a, nil,
+			&RoutingPolicy{Type: RoutingPolicyLatency, SetIdentifier: "east"}, true},
+		{map[string]string{route53RoutingPolicyKey: "latency", route53SetIdentifierKey: "east", route53WeightKey: "10"}, a, nil, nil, false},
+		{map[string]string{route53RoutingPolicyKey: "failover", route53SetIdentifierKey: "east", route53FailoverKey: "PRIMARY"}, a, nil,
+			&RoutingPolicy{Type: RoutingPolicyFailover, SetIdentifier: "east", Failover: "PRIMARY"}, true},
+		{map[string]string{route53RoutingPolicyKey: "failover", route53SetIdentifierKey: "east", route53FailoverKey: "primary"}, a, nil, nil, false},
+		{map[string]string{route53RoutingPolicyKey: "failover", route53SetIdentifierKey: "east", route53FailoverKey: "PRIMARY"}, cname, nil, nil, false},
+		{map[string]string{route53RoutingPolicyKey: "latency", route53SetIdentifierKey: "east"}, a, &Standby{Region: "us-west-2"}, nil, false},
+	}
+
+	for _, tt := range tests {
+		policy, err := parseRoutingPolicy(tt.annotations, tt.recordType, tt.standby)
+		if err != nil && tt.pass {
+			t.Errorf("parseRoutingPolicy(%v): expected %v, actual %v", tt.annotations, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseRoutingPolicy(%v): expected %v, actual %v", tt.annotations, tt.pass, err)
+		}
+		if tt.pass && !reflect.DeepEqual(policy, tt.expected) {
+			t.Errorf("parseRoutingPolicy(%v): expected %v, actual %v", tt.annotations, tt.expected, policy)
+		}
+	}
+}
+
+func TestParseManageDNS(t *testing.T) {
+	var tests = []struct {
+		annotations map[string]string
+		standby     *Standby
+		expected    bool
+		pass        bool
+	}{
+		{map[string]string{}, nil, true, true},
+		{map[string]string{manageDNSKey: "true"}, &Standby{Region: "us-west-2"}, true, true},
+		{map[string]string{manageDNSKey: "false"}, nil, false, true},
+		{map[string]string{manageDNSKey: "no"}, nil, false, false},
+		{map[string]string{manageDNSKey: "false"}, &Standby{Region: "us-west-2"}, false, false},
+		{map[string]string{manageDNSKey: "false", route53RoutingPolicyKey: "latency"}, nil, false, false},
+		{map[string]string{manageDNSKey: "false", route53HealthCheckPathKey: "/healthz"}, nil, false, false},
+	}
+
+	for _, tt := range tests {
+		manage, err := parseManageDNS(tt.annotations[manageDNSKey], tt.annotations, tt.standby)
+		if err != nil && tt.pass {
+			t.Errorf("parseManageDNS(%v): expected %v, actual %v", tt.annotations, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseManageDNS(%v): expected %v, actual %v", tt.annotations, tt.pass, err)
+		}
+		if tt.pass && manage != tt.expected {
+			t.Errorf("parseManageDNS(%v): expected %v, actual %v", tt.annotations, tt.expected, manage)
+		}
+	}
+}
+
+func TestParseHostnames(t *testing.T) {
+	var tests = []struct {
+		native      string
+		externalDNS string
+		expected    []string
+		pass        bool
+	}{
+		{"", "", nil, true},
+		{"www.example.com", "", []string{"www.example.com"}, true},
+		{"", "b.example.com, A.example.com.", []string{"a.example.com", "b.example.com"}, true},
+		{"a.example.com", "a.example.com,c.example.com", []string{"a.example.com", "c.example.com"}, true},
+		{"*.example.com", "", nil, false},
+		{"", "not_a_hostname", nil, false},
+		{strings.Repeat("a", 60) + ".example.com," + strings.Repeat("b", 60) + ".example.com," +
+			strings.Repeat("c", 60) + ".example.com," + strings.Repeat("d", 60) + ".example.com", "", nil, false},
+	}
+
+	for _, tt := range tests {
+		hostnames, err := parseHostnames(tt.native, tt.externalDNS)
+		if err != nil && tt.pass {
+			t.Errorf("parseHostnames(%v, %v): expected %v, actual %v", tt.native, tt.externalDNS, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseHostnames(%v, %v): expected %v, actual %v", tt.native, tt.externalDNS, tt.pass, err)
+		}
+		if err == nil && !reflect.DeepEqual(hostnames, tt.expected) {
+			t.Errorf("parseHostnames(%v, %v): expected %v, actual %v", tt.native, tt.externalDNS, tt.expected, hostnames)
+		}
+	}
+}
+
+func TestParseRoute53HealthCheckPath(t *testing.T) {
+	public, internal := aws.String("internet-facing"), aws.String("internal")
+	primary := &RoutingPolicy{Type: RoutingPolicyFailover, SetIdentifier: "east", Failover: "PRIMARY"}
+	secondary := &RoutingPolicy{Type: RoutingPolicyFailover, SetIdentifier: "west", Failover: "SECONDARY"}
+	standby := &Standby{Region: "us-west-2"}
+	var tests = []struct {
+		path          string
+		scheme        *string
+		routingPolicy *RoutingPolicy
+		standby       *Standby
+		pass          bool
+	}{
+		{"", public, nil, nil, true},
+		{"/healthz", public, primary, nil, true},
+		{"/healthz", public, nil, standby, true},
+		{"/healthz", public, secondary, nil, false},
+		{"/healthz", public, nil, nil, false},
+		{"/healthz", internal, primary, nil, false},
+		{"healthz", public, primary, nil, false},
+	}
+
+	for _, tt := range tests {
+		_, err := parseRoute53HealthCheckPath(tt.path, tt.scheme, tt.routingPolicy, tt.standby)
+		if err != nil && tt.pass {
+			t.Errorf("parseRoute53HealthCheckPath(%v): expected %v, actual %v", tt.path, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseRoute53HealthCheckPath(%v): expected %v, actual %v", tt.path, tt.pass, err)
+		}
+	}
+}
+
+func TestParseIPAddressType(t *testing.T) {
+	public, internal := aws.String("internet-facing"), aws.String("internal")
+	var tests = []struct {
+		ipAddressType string
+		scheme        *string
+		expected      string
+		pass          bool
+	}{
+		{"", internal, "ipv4", true},
+		{"ipv4", internal, "ipv4", true},
+		{"dualstack", public, "dualstack", true},
+		{"dualstack", internal, "", false},
+		{"ipv6", public, "", false},
+	}
+
+	for _, tt := range tests {
+		ipAddressType, err := parseIPAddressType(tt.ipAddressType, tt.scheme)
+		if err != nil && tt.pass {
+			t.Errorf("parseIPAddressType(%v): expected %v, actual %v", tt.ipAddressType, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseIPAddressType(%v): expected %v, actual %v", tt.ipAddressType, tt.pass, err)
+		}
+		if tt.pass && *ipAddressType != tt.expected {
+			t.Errorf("parseIPAddressType(%v): expected %v, actual %v", tt.ipAddressType, tt.expected, *ipAddressType)
+		}
+	}
+}
+
+func TestParseTargetIPAddressType(t *testing.T) {
+	var tests = []struct {
+		targetIPAddressType string
+		targetType          string
+		ipAddressType       string
+		expected            string
+		pass                bool
+	}{
+		{"", "instance", "ipv4", "ipv4", true},
+		{"ipv4", "ip", "dualstack", "ipv4", true},
+		{"ipv6", "ip", "dualstack", "ipv6", true},
+		{"ipv6", "instance", "dualstack", "", false},
+		{"ipv6", "ip", "ipv4", "", false},
+		{"dualstack", "ip", "dualstack", "", false},
+	}
+
+	for _, tt := range tests {
+		targetIPAddressType, err := parseTargetIPAddressType(tt.targetIPAddressType, tt.targetType, tt.ipAddressType)
+		if err != nil && tt.pass {
+			t.Errorf("parseTargetIPAddressType(%v): expected %v, actual %v", tt.targetIPAddressType, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseTargetIPAddressType(%v): expected %v, actual %v", tt.targetIPAddressType, tt.pass, err)
+		}
+		if tt.pass && *targetIPAddressType != tt.expected {
+			t.Errorf("parseTargetIPAddressType(%v): expected %v, actual %v", tt.targetIPAddressType, tt.expected, *targetIPAddressType)
+		}
+	}
+}
+
+func TestParseAlarms(t *testing.T) {
+	topic := "arn:aws:sns:us-east-1:123456789012:alerts"
+	var tests = []struct {
+		annotations map[string]string
+		expected    *Alarms
+		pass        bool
+	}{
+		{map[string]string{}, nil, true},
+		{
+			map[string]string{alarmUnhealthyHostsKey: "2", alarm5xxRateKey: "5", alarmResponseTimeKey: "0.5", alarmSNSTopicArnKey: topic},
+			&Alarms{UnhealthyHosts: aws.Int64(2), Target5xxRate: aws.Float64(5), ResponseTime: aws.Float64(0.5), SNSTopicArn: aws.String(topic)},
+			true,
+		},
+		{map[string]string{alarmResponseTimeKey: "2"}, &Alarms{ResponseTime: aws.Float64(2)}, true},
+		{map[string]string{alarmUnhealthyHostsKey: "0"}, nil, false},
+		{map[string]string{alarm5xxRateKey: "150"}, nil, false},
+		{map[string]string{alarmResponseTimeKey: "fast"}, nil, false},
+		{map[string]string{alarmUnhealthyHostsKey: "1", alarmSNSTopicArnKey: "alerts"}, nil, false},
+		// A topic without anything to alarm on is a mistake.
+		{map[string]string{alarmSNSTopicArnKey: topic}, nil, false},
+	}
+
+	for _, tt := range tests {
+		alarms, err := parseAlarms(tt.annotations)
+		if err != nil && tt.pass {
+			t.Errorf("parseAlarms(%v): expected %v, actual %v", tt.annotations, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseAlarms(%v): expected %v, actual %v", tt.annotations, tt.pass, err)
+		}
+		if !reflect.DeepEqual(alarms, tt.expected) {
+			t.Errorf("parseAlarms(%v): expected %+v, actual %+v", tt.annotations, tt.expected, alarms)
+		}
+	}
+}
+
+func TestParseGlobalAccelerator(t *testing.T) {
+	listener := "arn:aws:globalaccelerator::123456789012:accelerator/1234abcd-abcd-1234-abcd-1234abcdefgh/listener/0123vxyz"
+	var tests = []struct {
+		enabled     string
+		listenerArn string
+		expected    *GlobalAccelerator
+		pass        bool
+	}{
+		{"", "", nil, true},
+		{"false", "", nil, true},
+		{"true", "", &GlobalAccelerator{}, true},
+		{"yes", "", nil, false},
+		{"", listener, &GlobalAccelerator{ListenerArn: aws.String(listener)}, true},
+		{"false", listener, &GlobalAccelerator{ListenerArn: aws.String(listener)}, true},
+		{"true", listener, nil, false},
+		{"", "arn:aws:elasticloadbalancing:us-east-1:123456789012:listener/app/app/1/2", nil, false},
+	}
+
+	for _, tt := range tests {
+		accelerator, err := parseGlobalAccelerator(tt.enabled, tt.listenerArn)
+		if err != nil && tt.pass {
+			t.Errorf("parseGlobalAccelerator(%v, %v): expected %v, actual %v", tt.enabled, tt.listenerArn, tt.pass, err)
+		}
+		if err == nil && !tt.pass {
+			t.Errorf("parseGlobalAccelerator(%v, %v): expected %v, actual %v", tt.enabled, tt.listenerArn, tt.pass, err)
+		}
+		if !reflect.DeepEqual(accelerator, tt.expected) {
+			t.Errorf("parseGlobalAccelerator(%v, %v): expected %+v, actual %+v", tt.enabled, tt.listenerArn, tt.expected, accelerator)
+		}
+	}
+}
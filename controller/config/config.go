@@ -2,7 +2,60 @@ package config
 
 // Config contains the ALB Ingress Controller configuration
 type Config struct {
-	ClusterName    string
-	AWSDebug       bool
-	DisableRoute53 bool
+	ClusterName                   string
+	ControllerID                  string
+	AWSDebug                      bool
+	UserAgentSuffix               string
+	UseFIPSEndpoints              bool
+	STSRegionalEndpoints          bool
+	STSRegion                     string
+	DiscoverByTags                bool
+	DisableRoute53                bool
+	DNSProvider                   string
+	SyncTLSSecrets                bool
+	CertificateResolvers          string
+	HTTPSOnly                     bool
+	MinSSLPolicy                  string
+	AllowedAnnotations            string
+	TargetBatchSize               int
+	TargetBatchRatePerSecond      float32
+	TargetHealthIntervalSeconds   int
+	PodTargetHealthConditions     bool
+	TargetWarmupTimeoutSeconds    int
+	TargetFlapThreshold           int
+	TargetFlapWindowSeconds       int
+	TargetGroupNameTemplate       string
+	TargetGroupShiftSeconds       int
+	DefaultLoadBalancerAttributes string
+	DefaultTargetGroupAttributes  string
+	RuleQuota                     int
+	RuleSwapThreshold             int
+	SecurityGroupRuleQuota        int
+	CircuitBreakerThreshold       int
+	CircuitBreakerCooldownSeconds int
+	HeartbeatIntervalSeconds      int
+	HeartbeatFailureThreshold     int
+	DisablePermissionCheck        bool
+	ReconcileWindowSeconds        int
+	DriftIntervalSeconds          int
+	ChangeEventsQueueURL          string
+	LifecycleEventsQueueURL       string
+	LifecycleDrainTimeoutSeconds  int
+	InstanceEventsQueueURL        string
+	CloudWatchMetricsNamespace    string
+	ProvisionAccessLogBuckets     bool
+	AccessLogRetentionDays        int
+	CertificateExpiryWarningDays  int
+	ShardCount                    int
+	ShardIndex                    int
+	ShardBy                       string
+	GroupBy                       string
+	SharedSecurityGroup           bool
+	NodeSecurityGroups            string
+	CostAllocationTags            string
+	ALBHourlyPrice                float64
+	LCUHourlyPrice                float64
+	EstimatedLCUs                 float64
+	StateConfigMap                string
+	AlbConfigNamespace            string
 }
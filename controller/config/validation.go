@@ -3,7 +3,9 @@ package config
 import (
 	"fmt"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/coreos/alb-ingress-controller/awsutil"
 )
 
@@ -22,15 +24,19 @@ func (a *Annotations) resolveVPCValidateSubnets() error {
 	}
 	subs, err := awsutil.Ec2svc.DescribeSubnets(in)
 	if err != nil {
-		return err
+		return fmt.Errorf("Subnets %s could not be described: %s", a.Subnets, err.Error())
 	}
 	subnetMap := make(map[string]string)
 	for _, sub := range subs {
 		if _, ok := subnetMap[*sub.AvailabilityZone]; ok {
-			return fmt.Errorf("Subnets %s contained duplicate availability zone.", subs)
+			return fmt.Errorf("Subnets %s contained duplicate availability zone.", a.Subnets)
 		}
 		subnetMap[*sub.AvailabilityZone] = *sub.SubnetId
 	}
+	// An ALB requires subnets in at least two availability zones.
+	if len(subnetMap) < 2 {
+		return fmt.Errorf("Subnets %s must span at least 2 availability zones.", a.Subnets)
+	}
 
 	return nil
 }
@@ -38,17 +44,51 @@ func (a *Annotations) resolveVPCValidateSubnets() error {
 func (a *Annotations) validateSecurityGroups() error {
 	in := ec2.DescribeSecurityGroupsInput{GroupIds: a.SecurityGroups}
 	if _, err := awsutil.Ec2svc.DescribeSecurityGroups(in); err != nil {
-		return err
+		return fmt.Errorf("Security groups %v could not be resolved: %s", aws.StringValueSlice(a.SecurityGroups), err.Error())
 	}
 	return nil
 }
 
-func (a *Annotations) validateCertARN() error {
-	if e := awsutil.ACMsvc.CertExists(a.CertificateArn); !e {
-		if awsutil.IAMsvc.CertExists(a.CertificateArn) {
+func validateCertARN(arn *string) error {
+	if e := awsutil.ACMsvc.CertExists(arn); !e {
+		if awsutil.IAMsvc.CertExists(arn) {
 			return nil
 		}
-		return fmt.Errorf("ACM certificate ARN does not exist. ARN: %s", *a.CertificateArn)
+		return fmt.Errorf("ACM certificate ARN does not exist. ARN: %s", *arn)
 	}
 	return nil
 }
+
+// validateSSLPolicy checks that the security policy named name exists, and reports whether it
+// allows TLS protocols older than the minimum policy does.
+func validateSSLPolicy(name *string) (bool, error) {
+	policy, err := awsutil.ALBsvc.DescribeSSLPolicy(name)
+	if err != nil {
+		return false, fmt.Errorf("SSL policy %s could not be resolved: %s", *name, err.Error())
+	}
+	if minSSLPolicy == nil {
+		return false, nil
+	}
+	floor, err := awsutil.ALBsvc.DescribeSSLPolicy(minSSLPolicy)
+	if err != nil {
+		return false, fmt.Errorf("SSL policy %s could not be resolved: %s", *minSSLPolicy, err.Error())
+	}
+	return lowestProtocol(policy) < lowestProtocol(floor), nil
+}
+
+// sslProtocols are the protocols security policies can allow, oldest first.
+var sslProtocols = []string{"SSLv3", "TLSv1", "TLSv1.1", "TLSv1.2", "TLSv1.3"}
+
+// lowestProtocol returns the index in sslProtocols of the oldest protocol policy allows. Unknown
+// protocols are ignored; a policy without known protocols ranks as the newest protocol.
+func lowestProtocol(policy *elbv2.SslPolicy) int {
+	lowest := len(sslProtocols) - 1
+	for _, protocol := range policy.SslProtocols {
+		for i, known := range sslProtocols {
+			if aws.StringValue(protocol) == known && i < lowest {
+				lowest = i
+			}
+		}
+	}
+	return lowest
+}
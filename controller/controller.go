@@ -3,68 +3,519 @@ package controller
 import (
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/coreos/alb-ingress-controller/awsutil"
 	"github.com/coreos/alb-ingress-controller/controller/alb"
 	"github.com/coreos/alb-ingress-controller/controller/config"
+	"github.com/coreos/alb-ingress-controller/controller/util"
 	"github.com/coreos/alb-ingress-controller/log"
 	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/pflag"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	def_api "k8s.io/client-go/pkg/api"
 	api "k8s.io/client-go/pkg/api/v1"
 	extensions "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/ingress/core/pkg/ingress"
 	"k8s.io/ingress/core/pkg/ingress/defaults"
 )
 
+const (
+	// Default number of seconds between target health polls
+	defaultTargetHealthInterval = 60
+	// Default number of seconds between drift detection runs
+	defaultDriftInterval = 300
+	// Default number of seconds traffic takes to shift to a replacement target group
+	defaultTargetGroupShift = 300
+	// Default number of times targets change between healthy and unhealthy before a target group is
+	// reported as flapping
+	defaultTargetFlapThreshold = 4
+	// Default number of seconds target health changes are counted over for flap detection
+	defaultTargetFlapWindow = 900
+	// Default number of seconds between AWS heartbeats
+	defaultHeartbeatInterval = 30
+	// Default number of seconds ingress updates are coalesced over before reconciling
+	defaultReconcileWindow = 5
+	// Default number of days before expiry a certificate that won't be renewed is reported
+	defaultCertificateExpiryWarningDays = 30
+	// Default number of seconds between listings of the AlbConfigs
+	defaultAlbConfigInterval = 30
+	// Maximum number of windows a reconcile is delayed by when updates keep coming in
+	maxReconcileWindows = 10
+	// Default number of seconds instances being terminated are drained for
+	defaultLifecycleDrainTimeout = 300
+	// Default number of days the access logs of provisioned buckets are kept
+	defaultAccessLogRetentionDays = 90
+)
+
+// SHARD_BY values, naming the key ingresses are hashed into shards by.
+const (
+	shardByIngress   = "ingress"
+	shardByNamespace = "namespace"
+	shardByClass     = "class"
+)
+
+// GROUP_BY values, naming the ingresses sharing an ALB.
+const (
+	groupByIngress   = "ingress"
+	groupByNamespace = "namespace"
+	groupByGroup     = "group"
+)
+
+// ingressFinalizer is added to managed ingress resources, so they aren't removed from Kubernetes
+// before their AWS resources are deleted.
+const ingressFinalizer = "alb.ingress.kubernetes.io/resources"
+
+// namespaceRoleKey is the namespace annotation naming the IAM role the AWS resources of the
+// namespace's ingresses are managed as.
+const namespaceRoleKey = "alb.ingress.kubernetes.io/role-arn"
+
+// roleArnPattern matches the ARN of an IAM role.
+var roleArnPattern = regexp.MustCompile(`^arn:aws[a-z-]*:iam::[0-9]{12}:role/.+$`)
+
 // ALBController is our main controller
 type ALBController struct {
-	storeLister    ingress.StoreLister
-	ALBIngresses   ALBIngressesT
-	clusterName    *string
-	IngressClass   string
-	disableRoute53 bool
+	storeLister     ingress.StoreLister
+	client          kubernetes.Interface // nil when not running in a cluster
+	namespaces      cache.Store          // cached namespaces, nil when they aren't watched
+	recorder        record.EventRecorder
+	ALBIngresses    ALBIngressesT
+	clusterName     *string
+	controllerID    string
+	IngressClass    string
+	shardCount      uint32
+	shardIndex      uint32
+	shardBy         string
+	groupBy         string          // ingresses sharing an ALB, see ingressGroup
+	dnsProvider     alb.DNSProvider // publishes the hostnames of ingresses
+	disableRoute53  bool            // Route 53 isn't the dnsProvider
+	syncTLSSecrets  bool
+	tlsCertificates map[string]*tlsCertificate // certificates imported from TLS secrets, keyed by namespace/name of the secret
+	// requestedCertificates are the certificates requested from ACM, keyed by namespace and hash of their hosts
+	requestedCertificates map[string]*requestedCertificate
+	// certificateResolvers select the certificates of spec.tls blocks, in order
+	certificateResolvers []CertificateResolver
+	ruleQuota            int
+	sgRuleQuota          int64            // inbound rules a security group can have
+	sgRuleWarnings       map[string]int64 // rules of the security groups reported close to the quota, keyed by ingress ID and ALB
+	reconcileWindow      time.Duration
+	// targetWarmupTimeout is how long newly registered targets are waited on to turn healthy
+	targetWarmupTimeout time.Duration
+	targetFlapWindow    time.Duration      // how long target health changes are counted over for flap detection
+	heartbeat           *awsutil.Heartbeat // nil when heartbeats are disabled
+	// changeEventsQueueURL is the queue the CloudTrail events are received from, empty when change
+	// events aren't consumed
+	changeEventsQueueURL string
+	expiryWarning        time.Duration                // how long before expiry certificates are reported, negative when only expired ones are
+	certificateIssues    map[string]string            // last certificate issue an Event was emitted for, keyed by ingress ID and ARN
+	certificateExpiry    map[string]prometheus.Labels // labels of the exported certificate expiry gauges, keyed by ingress ID and ARN
+	reconcileErrors      map[string]string            // last reconcile error an Event was emitted for, keyed by ingress ID and ALB
+	costTagKeys          []string                     // labels of ingresses, or their namespace, their resources are tagged with
+	costPrices           costPrices
+	costEstimates        map[string]prometheus.Labels // labels of the exported cost estimate gauges, keyed by ingress ID and ALB
+	reconcileRequests    chan struct{}
+	// sharedSecurityGroup is used by the ALBs without security groups of their own, nil unless
+	// SHARED_SECURITY_GROUP is enabled
+	sharedSecurityGroup *alb.SharedSecurityGroup
+	// stateConfigMap is the namespace/name of the ConfigMap the state of the ALBs is saved to on
+	// shutdown, empty unless STATE_CONFIGMAP is set
+	stateConfigMap string
+	// stateGeneration is the generation of the state ConfigMap this controller started as, a
+	// snapshot is only saved while no other controller started since
+	stateGeneration int64
+	// albConfigNamespace is the namespace of the AlbConfigs ingresses may reference, empty unless
+	// ALB_CONFIG_NAMESPACE is set
+	albConfigNamespace string
+	albConfigs         map[string]*config.AlbConfigSpec // keyed by name, nil until they were listed
+	albConfigMutex     sync.Mutex
+	// lifecycleEventsQueueURL is the queue the lifecycle actions of instances being terminated are
+	// received from, empty unless LIFECYCLE_EVENTS_QUEUE_URL is set
+	lifecycleEventsQueueURL string
+	// lifecycleDrainTimeout is how long instances being terminated are drained for
+	lifecycleDrainTimeout time.Duration
+	// instanceEventsQueueURL is the queue the state changes of EC2 instances are received from,
+	// empty unless INSTANCE_EVENTS_QUEUE_URL is set
+	instanceEventsQueueURL string
+	// unavailableInstances are left out of the targets as they're going away, with when they may be
+	// registered again, or the zero time until they're running again. Guarded by instanceMutex
+	unavailableInstances map[string]time.Time
+	instanceMutex        sync.Mutex
+	// cloudWatchMetricsNamespace is the CloudWatch namespace the controller metrics are published
+	// under, empty unless CLOUDWATCH_METRICS_NAMESPACE is set
+	cloudWatchMetricsNamespace string
+	// publishedCounts are the values of the counters last published to CloudWatch, keyed by metric
+	publishedCounts map[string]float64
+	// podTargetHealth enables setting the target health conditions of pods, see syncPodConditions
+	podTargetHealth bool
+	// podConditions are the target health states last set on pods, keyed by namespace/name of the
+	// pod and target group ID
+	podConditions map[string]string
+	// shutdown is closed by Shutdown, no reconcile is started once it is
+	shutdown chan struct{}
+	// mutex serializes rebuilding the ALBIngresses in OnUpdate and reconciling them
+	mutex sync.Mutex
 }
 
 // NewALBController returns an ALBController
 func NewALBController(awsconfig *aws.Config, conf *config.Config) *ALBController {
+	client := newKubernetesClient()
 	ac := &ALBController{
-		clusterName:    aws.String(conf.ClusterName),
-		disableRoute53: conf.DisableRoute53,
+		client:                client,
+		recorder:              newEventRecorder(client),
+		clusterName:           aws.String(conf.ClusterName),
+		controllerID:          conf.ControllerID,
+		syncTLSSecrets:        conf.SyncTLSSecrets,
+		tlsCertificates:       make(map[string]*tlsCertificate),
+		requestedCertificates: make(map[string]*requestedCertificate),
+		ruleQuota:             conf.RuleQuota,
+		sgRuleQuota:           int64(conf.SecurityGroupRuleQuota),
+		sgRuleWarnings:        make(map[string]int64),
+		certificateIssues:     make(map[string]string),
+		certificateExpiry:     make(map[string]prometheus.Labels),
+		reconcileErrors:       make(map[string]string),
+		costEstimates:         make(map[string]prometheus.Labels),
+		stateConfigMap:        conf.StateConfigMap,
+		albConfigNamespace:    conf.AlbConfigNamespace,
+		shutdown:              make(chan struct{}),
+	}
+
+	// A default ID would be shared by every cluster of the same CLUSTER_NAME, which then take over
+	// each other's resources.
+	if ac.controllerID == "" {
+		glog.Exit("The controller ID must be set, unique to the cluster")
+	}
+	providerName := conf.DNSProvider
+	if providerName == "" && conf.DisableRoute53 {
+		providerName = alb.DNSProviderNone
+	}
+	dnsProvider, err := alb.LookupDNSProvider(providerName)
+	if err != nil {
+		glog.Exit(err)
+	}
+	ac.dnsProvider = dnsProvider
+	ac.disableRoute53 = ac.dnsProvider.Name() != alb.DNSProviderRoute53
+	if ac.certificateResolvers, err = ac.newCertificateResolvers(conf.CertificateResolvers); err != nil {
+		glog.Exit(err)
+	}
+	if ac.costTagKeys, err = config.ParseCostAllocationTagKeys(conf.CostAllocationTags); err != nil {
+		glog.Exit(err)
+	}
+	ac.costPrices = newCostPrices(conf.ALBHourlyPrice, conf.LCUHourlyPrice, conf.EstimatedLCUs)
+	if ac.sgRuleQuota <= 0 {
+		ac.sgRuleQuota = defaultSecurityGroupRuleQuota
+	}
+	if conf.ShardCount > 1 {
+		if conf.ShardIndex < 0 || conf.ShardIndex >= conf.ShardCount {
+			glog.Exitf("SHARD_INDEX must be between 0 and %d", conf.ShardCount-1)
+		}
+		ac.shardCount = uint32(conf.ShardCount)
+		ac.shardIndex = uint32(conf.ShardIndex)
+		switch conf.ShardBy {
+		case "", shardByIngress, shardByNamespace, shardByClass:
+			ac.shardBy = conf.ShardBy
+		default:
+			glog.Exitf("SHARD_BY must be %s, %s or %s", shardByIngress, shardByNamespace, shardByClass)
+		}
+	}
+	switch conf.GroupBy {
+	case "", groupByIngress, groupByNamespace, groupByGroup:
+		ac.groupBy = conf.GroupBy
+	default:
+		glog.Exitf("GROUP_BY must be %s, %s or %s", groupByIngress, groupByNamespace, groupByGroup)
+	}
+	if conf.SharedSecurityGroup {
+		nodeSecurityGroups, err := config.ParseNodeSecurityGroups(conf.NodeSecurityGroups)
+		if err != nil {
+			glog.Exit(err)
+		}
+		ac.sharedSecurityGroup = alb.NewSharedSecurityGroup(ac.sharedSecurityGroupName(),
+			nodeSecurityGroups, ownershipTags(conf.ClusterName, ac.controllerID))
+	}
+
+	if err := alb.SetTargetGroupNameTemplate(conf.TargetGroupNameTemplate); err != nil {
+		glog.Exit(err)
+	}
+
+	if conf.StateConfigMap != "" {
+		if _, _, err := parseStateConfigMap(conf.StateConfigMap); err != nil {
+			glog.Exit(err)
+		}
+	}
+
+	if client != nil {
+		ac.watchNamespaces()
+	}
+
+	// Ingresses referencing an AlbConfig fail validation until the AlbConfigs were listed.
+	if ac.albConfigNamespace != "" {
+		if client == nil {
+			log.Warnf("AlbConfigs can't be listed without a Kubernetes client, ingresses referencing one will fail validation", "controller")
+		} else {
+			ac.syncAlbConfigs()
+			go wait.Forever(ac.syncAlbConfigs, defaultAlbConfigInterval*time.Second)
+		}
 	}
 
+	config.SetHTTPSOnly(conf.HTTPSOnly)
+	config.SetAllowedAnnotations(conf.AllowedAnnotations)
+	if err := config.SetDefaultAttributes(conf.DefaultLoadBalancerAttributes, conf.DefaultTargetGroupAttributes); err != nil {
+		glog.Exit(err)
+	}
+
+	alb.SetRuleQuota(conf.RuleQuota)
+	alb.SetRuleSwapThreshold(conf.RuleSwapThreshold)
+
 	awsutil.AWSDebug = conf.AWSDebug
+	if awsutil.UserAgentSuffix, err = awsutil.ExpandUserAgentSuffix(conf.UserAgentSuffix, conf.ClusterName, ac.controllerID); err != nil {
+		glog.Exit(err)
+	}
+	resolver := &awsutil.EndpointResolver{
+		FIPS:        conf.UseFIPSEndpoints,
+		RegionalSTS: conf.STSRegionalEndpoints,
+		STSRegion:   conf.STSRegion,
+	}
+	if !resolver.IsDefault() {
+		awsconfig.EndpointResolver = resolver
+	}
 	awsutil.Session = awsutil.NewSession(awsconfig)
+	awsutil.Breaker = awsutil.NewCircuitBreaker(conf.CircuitBreakerThreshold,
+		time.Duration(conf.CircuitBreakerCooldownSeconds)*time.Second)
+	if awsutil.Breaker != nil {
+		awsutil.Breaker.Attach(awsutil.Session)
+	}
 	awsutil.ALBsvc = awsutil.NewELBV2(awsutil.Session)
+	awsutil.ALBsvc.SetTargetBatching(conf.TargetBatchSize, conf.TargetBatchRatePerSecond)
+	if conf.DiscoverByTags {
+		awsutil.ALBsvc.Tagging = awsutil.NewTagging(awsutil.Session)
+	}
 	awsutil.Ec2svc = awsutil.NewEC2(awsutil.Session)
 	awsutil.ACMsvc = awsutil.NewACM(awsutil.Session)
 	awsutil.IAMsvc = awsutil.NewIAM(awsutil.Session)
+	awsutil.WAFsvc = awsutil.NewWAFRegional(awsutil.Session)
+	awsutil.GlobalAcceleratorsvc = awsutil.NewGlobalAccelerator(awsutil.Session)
+	awsutil.CloudWatchsvc = awsutil.NewCloudWatch(awsutil.Session)
+
+	if err := config.SetMinSSLPolicy(conf.MinSSLPolicy); err != nil {
+		glog.Exit(err)
+	}
 
-	if !conf.DisableRoute53 {
+	if !ac.disableRoute53 {
 		awsutil.Route53svc = awsutil.NewRoute53(awsutil.Session)
 	}
 
+	heartbeatInterval := conf.HeartbeatIntervalSeconds
+	if heartbeatInterval == 0 {
+		heartbeatInterval = defaultHeartbeatInterval
+	}
+	if heartbeatInterval > 0 {
+		// Ec2svc is swapped while acting as the role of a namespace, the heartbeat checks the
+		// controller's own credentials.
+		ec2svc := awsutil.Ec2svc
+		ac.heartbeat = awsutil.NewHeartbeat(conf.HeartbeatFailureThreshold, ec2svc.DescribeAccountAttributes)
+		go wait.Forever(ac.heartbeat.Beat, time.Duration(heartbeatInterval)*time.Second)
+	}
+	if conf.ChangeEventsQueueURL != "" {
+		ac.changeEventsQueueURL = conf.ChangeEventsQueueURL
+		awsutil.SQSsvc = awsutil.NewSQS(awsutil.Session)
+	}
+	if conf.LifecycleEventsQueueURL != "" {
+		ac.lifecycleEventsQueueURL = conf.LifecycleEventsQueueURL
+		ac.lifecycleDrainTimeout = time.Duration(conf.LifecycleDrainTimeoutSeconds) * time.Second
+		if ac.lifecycleDrainTimeout <= 0 {
+			ac.lifecycleDrainTimeout = defaultLifecycleDrainTimeout * time.Second
+		}
+		awsutil.SQSsvc = awsutil.NewSQS(awsutil.Session)
+		awsutil.AutoScalingsvc = awsutil.NewAutoScaling(awsutil.Session)
+	}
+	if conf.InstanceEventsQueueURL != "" {
+		ac.instanceEventsQueueURL = conf.InstanceEventsQueueURL
+		awsutil.SQSsvc = awsutil.NewSQS(awsutil.Session)
+	}
+	if conf.ProvisionAccessLogBuckets {
+		retention := conf.AccessLogRetentionDays
+		if retention == 0 {
+			retention = defaultAccessLogRetentionDays
+		}
+		awsutil.S3svc = awsutil.NewS3(awsutil.Session)
+		alb.SetAccessLogBucketProvisioning(true, int64(retention))
+	}
+	if conf.CloudWatchMetricsNamespace != "" {
+		ac.cloudWatchMetricsNamespace = conf.CloudWatchMetricsNamespace
+		go wait.Forever(ac.publishMetrics, cloudWatchMetricsInterval*time.Second)
+	}
+	if !conf.DisablePermissionCheck {
+		go ac.checkPermissions()
+	}
+
+	interval := conf.TargetHealthIntervalSeconds
+	if interval == 0 {
+		interval = defaultTargetHealthInterval
+	}
+	if interval > 0 {
+		go wait.Forever(ac.syncTargetHealth, time.Duration(interval)*time.Second)
+	}
+	// Warming targets are only found healthy by the target health polls.
+	if conf.TargetWarmupTimeoutSeconds > 0 && interval > 0 {
+		ac.targetWarmupTimeout = time.Duration(conf.TargetWarmupTimeoutSeconds) * time.Second
+		alb.SetTargetWarmupTimeout(ac.targetWarmupTimeout)
+	}
+	flapThreshold := conf.TargetFlapThreshold
+	if flapThreshold == 0 {
+		flapThreshold = defaultTargetFlapThreshold
+	}
+	flapWindow := conf.TargetFlapWindowSeconds
+	if flapWindow <= 0 {
+		flapWindow = defaultTargetFlapWindow
+	}
+	if flapThreshold > 0 && interval > 0 {
+		ac.targetFlapWindow = time.Duration(flapWindow) * time.Second
+		alb.SetTargetFlapDetection(flapThreshold, ac.targetFlapWindow)
+	}
+	// Pod conditions are set from the target health polls.
+	ac.podTargetHealth = conf.PodTargetHealthConditions && interval > 0
+
+	driftInterval := conf.DriftIntervalSeconds
+	if driftInterval == 0 {
+		driftInterval = defaultDriftInterval
+	}
+	warningDays := conf.CertificateExpiryWarningDays
+	if warningDays == 0 {
+		warningDays = defaultCertificateExpiryWarningDays
+	}
+	ac.expiryWarning = time.Duration(warningDays) * 24 * time.Hour
+	if driftInterval > 0 {
+		go wait.Forever(ac.syncDrift, time.Duration(driftInterval)*time.Second)
+		go wait.Forever(ac.syncCertificates, time.Duration(driftInterval)*time.Second)
+		go wait.Forever(ac.syncCostEstimates, time.Duration(driftInterval)*time.Second)
+	}
+	if ac.changeEventsQueueURL != "" {
+		go wait.Forever(ac.receiveChangeEvents, time.Second)
+	}
+	if ac.lifecycleEventsQueueURL != "" {
+		go wait.Forever(ac.receiveLifecycleEvents, time.Second)
+	}
+	if ac.instanceEventsQueueURL != "" {
+		go wait.Forever(ac.receiveInstanceEvents, time.Second)
+	}
+
+	if !ac.disableRoute53 && interval > 0 {
+		go wait.Forever(ac.syncRecordStates, time.Duration(interval)*time.Second)
+	}
+
+	shift := conf.TargetGroupShiftSeconds
+	if shift == 0 {
+		shift = defaultTargetGroupShift
+	}
+	if shift > 0 {
+		alb.SetTargetGroupShiftPeriod(time.Duration(shift) * time.Second)
+		go wait.Forever(ac.syncTrafficShifts, time.Duration(shift)*time.Second/10)
+	}
+
+	window := conf.ReconcileWindowSeconds
+	if window == 0 {
+		window = defaultReconcileWindow
+	}
+	if window > 0 {
+		ac.reconcileWindow = time.Duration(window) * time.Second
+		ac.reconcileRequests = make(chan struct{}, 1)
+		go ac.coalesceReconciles()
+	}
+
 	return ingress.Controller(ac).(*ALBController)
 }
 
+// sharedSecurityGroupName returns the name of the shared security group of the controller. Each
+// shard has one of its own, as it only knows the ALBs of its ingresses and would revoke the rules
+// of the others.
+func (ac *ALBController) sharedSecurityGroupName() string {
+	name := fmt.Sprintf("%s-%s-alb-shared", *ac.clusterName, ac.controllerID)
+	if ac.shardCount > 1 {
+		name = fmt.Sprintf("%s-%d", name, ac.shardIndex)
+	}
+	return name
+}
+
+// isSharedSecurityGroupName returns whether name is the one of the shared security group of any
+// shard of the controller.
+func (ac *ALBController) isSharedSecurityGroupName(name string) bool {
+	prefix := fmt.Sprintf("%s-%s-alb-shared", *ac.clusterName, ac.controllerID)
+	return name == prefix || strings.HasPrefix(name, prefix+"-")
+}
+
+// newKubernetesClient returns a client of the in-cluster API server. If the controller isn't running
+// in a cluster, nil is returned.
+func newKubernetesClient() kubernetes.Interface {
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		log.Warnf("Unable to load in-cluster config, Kubernetes events will not be emitted and finalizers will not be managed. Error: %s", "controller", err.Error())
+		return nil
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		log.Warnf("Unable to create Kubernetes client, Kubernetes events will not be emitted and finalizers will not be managed. Error: %s", "controller", err.Error())
+		return nil
+	}
+	return client
+}
+
+// newEventRecorder returns a recorder emitting Kubernetes Events through client. If there is no
+// client, events are dropped.
+func newEventRecorder(client kubernetes.Interface) record.EventRecorder {
+	if client == nil {
+		return &record.FakeRecorder{}
+	}
+
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&corev1.EventSinkImpl{
+		Interface: client.Core().Events(""),
+	})
+	return eventBroadcaster.NewRecorder(def_api.Scheme, api.EventSource{
+		Component: "alb-ingress-controller",
+	})
+}
+
 // OnUpdate is a callback invoked from the sync queue when ingress resources, or resources ingress
 // resources touch, change. On each new event a new list of ALBIngresses are created and evaluated
 // against the existing ALBIngress list known to the ALBController. Eventually the state of this
 // list is synced resulting in new ingresses causing resource creation, modified ingresses having
 // resources modified (when appropriate) and ingresses missing from the new list deleted from AWS.
 func (ac *ALBController) OnUpdate(ingressConfiguration ingress.Configuration) ([]byte, error) {
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+
 	if ac.ALBIngresses == nil {
 		ac.assembleIngresses()
 	}
 
 	awsutil.OnUpdateCount.Add(float64(1))
+	ac.updateRuleQuota()
 
 	log.Debugf("OnUpdate event seen by ALB ingress controller.", "controller")
 
 	// Create new ALBIngress list for this invocation.
 	var ALBIngresses ALBIngressesT
+	// Ingresses sharing an ALB are merged once every ingress was seen.
+	groups := make(map[string]bool)
+	var groupKeys []string
 	// Find every ingress currently in Kubernetes.
 	for _, ingress := range ac.storeLister.Ingress.List() {
 		ingResource := ingress.(*extensions.Ingress)
@@ -72,6 +523,22 @@ func (ac *ALBController) OnUpdate(ingressConfiguration ingress.Configuration) ([
 		if !ac.validIngress(ingResource) {
 			continue
 		}
+		// An ingress being deleted is left out of the new list, so its AWS resources are deleted. Its
+		// finalizer is removed once they are.
+		if ingResource.DeletionTimestamp != nil {
+			continue
+		}
+		if !hasFinalizer(ingResource) {
+			ac.updateFinalizer(ingResource, true)
+		}
+		if group := ac.ingressGroup(ingResource); group != "" {
+			key := ingResource.Namespace + "/" + group
+			if !groups[key] {
+				groups[key] = true
+				groupKeys = append(groupKeys, key)
+			}
+			continue
+		}
 		// Produce a new ALBIngress instance for every ingress found. If ALBIngress returns nil, there
 		// was an issue with the ingress (e.g. bad annotations) and should not be added to the list.
 		ALBIngress, err := NewALBIngressFromIngress(ingResource, ac)
@@ -80,11 +547,49 @@ func (ac *ALBController) OnUpdate(ingressConfiguration ingress.Configuration) ([
 		}
 		if err != nil {
 			ALBIngress.tainted = true
+			ac.reportInvalidIngress(ingResource, err)
 		}
 		// Add the new ALBIngress instance to the new ALBIngress list.
 		ALBIngresses = append(ALBIngresses, ALBIngress)
 	}
 
+	// Each group gets a single ALBIngress, assembled from its members merged into one ingress. A
+	// group that can't be merged is left as it is, like an invalid ingress.
+	sort.Strings(groupKeys)
+	for _, key := range groupKeys {
+		// Namespaces can't contain a slash, groups can.
+		parts := strings.SplitN(key, "/", 2)
+		namespace, group := parts[0], parts[1]
+		members := ac.groupMembers(namespace, group)
+		var active []*extensions.Ingress
+		var names []string
+		for _, member := range members {
+			if member.DeletionTimestamp == nil {
+				active = append(active, member)
+				names = append(names, member.Name)
+			}
+		}
+		merged, err := mergeIngresses(namespace, group, active)
+		var ALBIngress *ALBIngress
+		if err == nil {
+			ALBIngress, err = NewALBIngressFromIngress(merged, ac)
+		} else if i := ac.ALBIngresses.find(NewALBIngress(namespace, merged.Name, *ac.clusterName)); i >= 0 {
+			ALBIngress = ac.ALBIngresses[i]
+		} else {
+			ALBIngress = NewALBIngress(namespace, merged.Name, *ac.clusterName)
+		}
+		// A group left as it is still serves its previous members, which keep their finalizers.
+		if err != nil {
+			ALBIngress.tainted = true
+			for _, member := range active {
+				ac.reportInvalidIngress(member, err)
+			}
+		} else {
+			ALBIngress.members = names
+		}
+		ALBIngresses = append(ALBIngresses, ALBIngress)
+	}
+
 	// Capture any ingresses missing from the new list that qualify for deletion.
 	deletable := ac.ingressToDelete(ALBIngresses)
 	// If deletable ingresses were found, add them to the list so they'll be deleted when Reconcile()
@@ -99,10 +604,27 @@ func (ac *ALBController) OnUpdate(ingressConfiguration ingress.Configuration) ([
 	return []byte(""), nil
 }
 
+// reportInvalidIngress emits a warning Event on an ingress resource that wasn't reconciled because
+// of err. Invalid annotations are cached, so this is only emitted once until they change or the
+// cache expires. Throttled lookups are retried on the next sync instead.
+func (ac *ALBController) reportInvalidIngress(ingResource *extensions.Ingress, err error) {
+	if awsutil.IsThrottle(err) {
+		ac.recorder.Eventf(ingResource, api.EventTypeWarning, "AWSThrottled",
+			"Ingress was not reconciled while AWS throttles the controller, it's retried on the next sync: %s", awsutil.DescribeError(err))
+	} else {
+		ac.recorder.Eventf(ingResource, api.EventTypeWarning, "ValidationFailed",
+			"Ingress was not reconciled, no AWS resources were changed: %s", awsutil.DescribeError(err))
+	}
+}
+
 // validIngress checks whether the ingress controller has an IngressClass set. If it does, it will
 // only return true if the ingress resource passed in has the same class specified via the
-// kubernetes.io/ingress.class annotation.
-func (ac ALBController) validIngress(i *extensions.Ingress) bool {
+// kubernetes.io/ingress.class annotation. Ingresses outside of the controller's shard are never
+// valid.
+func (ac *ALBController) validIngress(i *extensions.Ingress) bool {
+	if !ac.inShard(ac.shardKey(i)) {
+		return false
+	}
 	if ac.IngressClass == "" {
 		return true
 	}
@@ -112,19 +634,509 @@ func (ac ALBController) validIngress(i *extensions.Ingress) bool {
 	return false
 }
 
-// Reload executes the state synchronization for our ingresses
+// shardKey returns the key the ingress is assigned to a shard by: the namespace/name of the
+// ALBIngress of its group when it shares an ALB, so the ALB is managed by a single shard, the
+// shard-group annotation when it's set, so ingresses of a group share a shard, otherwise the
+// namespace, the ingress class or the namespace/name of the ingress, as set by SHARD_BY.
+func (ac *ALBController) shardKey(i *extensions.Ingress) string {
+	if group := ac.ingressGroup(i); group != "" && ac.shardBy != shardByNamespace {
+		return i.Namespace + "/" + groupIngressName(group)
+	}
+	if group := i.Annotations[config.ShardGroupKey]; group != "" {
+		return "group/" + group
+	}
+	switch ac.shardBy {
+	case shardByNamespace:
+		return i.Namespace
+	case shardByClass:
+		return "class/" + i.Annotations["kubernetes.io/ingress.class"]
+	}
+	return i.Namespace + "/" + i.Name
+}
+
+// inShard returns true when the shard key hashes to the controller's shard. Without sharding, every
+// ingress is in the shard.
+func (ac *ALBController) inShard(key string) bool {
+	if ac.shardCount < 2 {
+		return true
+	}
+	hasher := fnv.New32a()
+	hasher.Write([]byte(key))
+	return hasher.Sum32()%ac.shardCount == ac.shardIndex
+}
+
+// shardTags returns the ShardTag of the AWS resources of the controller's shard, none without
+// sharding.
+func (ac *ALBController) shardTags() util.Tags {
+	if ac.shardCount < 2 {
+		return nil
+	}
+	return util.Tags{{Key: aws.String(util.ShardTag), Value: aws.String(fmt.Sprintf("%d/%d", ac.shardIndex, ac.shardCount))}}
+}
+
+// ownerTags returns the tags identifying the cluster, controller and shard owning the AWS resources
+// of the controller.
+func (ac *ALBController) ownerTags() util.Tags {
+	return append(ownershipTags(*ac.clusterName, ac.controllerID), ac.shardTags()...)
+}
+
+// managesIngress returns true when the ingress namespace/name, whose ALB has the tags, is managed
+// by this controller. An ingress that no longer exists is managed by the controller of the shard
+// its ALB is tagged with, which deletes its leftover AWS resources. ALBs tagged when there were
+// another number of shards, or before they were tagged at all, are managed by the shard the
+// namespace/name of their ingress hashes to.
+func (ac *ALBController) managesIngress(namespace, name string, tags util.Tags) bool {
+	if ingResource, exists := ac.ingressResource(namespace, name); exists {
+		return ac.validIngress(ingResource)
+	}
+	if ac.shardCount < 2 {
+		return true
+	}
+	own := ac.shardTags()
+	if shard, ok := tags.Get(util.ShardTag); ok && util.SameShardCount(shard, *own[0].Value) {
+		return shard == *own[0].Value
+	}
+	if ac.shardBy == shardByNamespace {
+		return ac.inShard(namespace)
+	}
+	return ac.inShard(namespace + "/" + name)
+}
+
+// Reload executes the state synchronization for our ingresses. When a reconcile window is
+// configured, the synchronization is handed to coalesceReconciles instead, so a burst of updates
+// results in a single reconcile.
 func (ac *ALBController) Reload(data []byte) ([]byte, bool, error) {
 	awsutil.ReloadCount.Add(float64(1))
 
-	// Sync the state, resulting in creation, modify, delete, or no action, for every ALBIngress
-	// instance known to the ALBIngress controller.
-	for _, ALBIngress := range ac.ALBIngresses {
-		ALBIngress.Reconcile(ac.disableRoute53)
+	if ac.reconcileRequests == nil {
+		ac.reconcile()
+		return []byte(""), true, nil
 	}
 
+	select {
+	case ac.reconcileRequests <- struct{}{}:
+	default: // a reconcile is already pending
+	}
 	return []byte(""), true, nil
 }
 
+// coalesceReconciles waits for ingress updates to settle before reconciling. Every update seen
+// within the reconcile window pushes the reconcile back by another window, up to
+// maxReconcileWindows, so steady churn (e.g. a long rolling deploy) still gets reconciled.
+func (ac *ALBController) coalesceReconciles() {
+	for range ac.reconcileRequests {
+		updates := 1
+		timer := time.NewTimer(ac.reconcileWindow)
+		deadline := time.After(maxReconcileWindows * ac.reconcileWindow)
+	wait:
+		for {
+			select {
+			case <-ac.reconcileRequests:
+				updates++
+				timer.Reset(ac.reconcileWindow)
+			case <-timer.C:
+				break wait
+			case <-deadline:
+				timer.Stop()
+				break wait
+			}
+		}
+
+		log.Debugf("Reconciling after %d coalesced updates.", "controller", updates)
+		ac.reconcile()
+	}
+}
+
+// Shutdown stops the controller from reconciling and waits up to timeout for the reconcile in
+// flight, if any, to complete. An ingress being reconciled is reconciled to the end, its ALBs
+// rolling back the target groups, listeners and rules of a failed reconcile as usual, while the
+// ingresses it didn't get to are left for the next controller to reconcile. A timeout that isn't
+// positive waits indefinitely.
+func (ac *ALBController) Shutdown(timeout time.Duration) error {
+	close(ac.shutdown)
+
+	done := make(chan struct{})
+	go func() {
+		ac.mutex.Lock()
+		defer ac.mutex.Unlock()
+		// Upserts are normally flushed by the reconcile queueing them.
+		awsutil.FlushRoute53Batch()
+		if err := ac.saveSnapshot(); err != nil {
+			log.Errorf("Failed to save the state snapshot to the %s ConfigMap. Error: %s", "controller", ac.stateConfigMap, err.Error())
+		}
+		close(done)
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = time.After(timeout)
+	}
+	select {
+	case <-done:
+		return nil
+	case <-expired:
+		return fmt.Errorf("reconcile still in flight after %s", timeout)
+	}
+}
+
+// stopping reports whether Shutdown was called.
+func (ac *ALBController) stopping() bool {
+	select {
+	case <-ac.shutdown:
+		return true
+	default:
+		return false
+	}
+}
+
+// reconcile syncs the state, resulting in creation, modify, delete, or no action, for every
+// ALBIngress instance known to the ALBIngress controller.
+func (ac *ALBController) reconcile() {
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+
+	if ac.stopping() {
+		return
+	}
+
+	// While a circuit is open, changes to the ingresses' AWS resources can't be made. Surface it on
+	// every ingress so it's visible with kubectl describe, rather than only in the controller logs.
+	if open := awsutil.Breaker.OpenCircuits(); len(open) > 0 {
+		for _, ALBIngress := range ac.ALBIngresses {
+			ingResource, exists := ac.ingressResource(*ALBIngress.namespace, *ALBIngress.ingressName)
+			if !exists {
+				continue
+			}
+			ac.recorder.Eventf(ingResource, api.EventTypeWarning, "AWSCircuitOpen",
+				"Changes to %s are paused after repeated AWS API failures", strings.Join(open, ", "))
+		}
+	}
+
+	// An ingress that won't fit in the account limits is left alone, rather than failing with a
+	// LimitExceeded error part way through creating its resources.
+	exceeded := ac.checkQuotas()
+	// The shared security group must allow the traffic of new ALBs before they're created.
+	if ac.sharedSecurityGroup != nil {
+		var loadBalancers alb.LoadBalancers
+		for _, ALBIngress := range ac.ALBIngresses {
+			if ALBIngress.roleArn == "" {
+				loadBalancers = append(loadBalancers, ALBIngress.LoadBalancers...)
+			}
+		}
+		// The shared security group is in the controller's account, whichever role was assumed last.
+		func() {
+			defer awsutil.AssumeRole("")()
+			if err := ac.sharedSecurityGroup.Reconcile(loadBalancers); err != nil {
+				log.Errorf("Failed to reconcile the shared security group. Error: %s", "controller", err.Error())
+			}
+		}()
+	}
+	// Record upserts are written per hosted zone once every ingress was reconciled, rather than one
+	// by one.
+	awsutil.BeginRoute53Batch()
+	var reconciled []*ALBIngress
+	for _, ALBIngress := range ac.ALBIngresses {
+		if ac.stopping() {
+			log.Infof("Shutting down, leaving the remaining ingresses to be reconciled on restart.", "controller")
+			break
+		}
+		if violations, ok := exceeded[*ALBIngress.id]; ok {
+			log.Errorf("Skipping reconcile, AWS quotas would be exceeded: %s", *ALBIngress.id, strings.Join(violations, "; "))
+			ingResource, exists := ac.ingressResource(*ALBIngress.namespace, *ALBIngress.ingressName)
+			if exists {
+				ac.recorder.Eventf(ingResource, api.EventTypeWarning, "QuotaExceeded",
+					"Ingress was not reconciled, no AWS resources were changed: %s", strings.Join(violations, "; "))
+			}
+			continue
+		}
+		ALBIngress.Reconcile(ac.dnsProvider)
+		reconciled = append(reconciled, ALBIngress)
+	}
+	awsutil.FlushRoute53Batch()
+	// Upserts rejected by Route 53 fail their ALB once the batch is flushed.
+	for _, ALBIngress := range reconciled {
+		ac.reportReconcileErrors(ALBIngress)
+	}
+
+	ac.releaseDeletedIngresses()
+}
+
+// reportReconcileErrors emits a ReconcileFailed warning Event on the ingress resource for each ALB
+// of the ALBIngress that failed to reconcile, with the request ID of the failed AWS call to hand to
+// AWS support. Each error is reported once, until the ALB reconciles or fails with another error.
+func (ac *ALBController) reportReconcileErrors(ALBIngress *ALBIngress) {
+	ingResource, exists := ac.ingressResource(*ALBIngress.namespace, *ALBIngress.ingressName)
+	for _, lb := range ALBIngress.LoadBalancers {
+		key := *ALBIngress.id + " " + *lb.ID
+		if lb.LastError == nil {
+			delete(ac.reconcileErrors, key)
+			continue
+		}
+		// The request ID differs between attempts failing the same way.
+		issue := lb.LastError.Error()
+		if awsErr, ok := lb.LastError.(awserr.Error); ok {
+			issue = awsErr.Code() + ": " + awsErr.Message()
+		}
+		if !exists || ac.reconcileErrors[key] == issue {
+			continue
+		}
+		ac.reconcileErrors[key] = issue
+		ac.recorder.Eventf(ingResource, api.EventTypeWarning, "ReconcileFailed",
+			"Failed to reconcile ALB %s: %s", *lb.ID, awsutil.DescribeError(lb.LastError))
+	}
+}
+
+// releaseDeletedIngresses removes the finalizer from ingress resources being deleted whose AWS
+// resources are gone, letting Kubernetes complete their deletion. An ingress sharing the ALB of a
+// group is released once the group was reconciled without it, or its ALB is gone with the last
+// ingress of the group. Ingresses of other classes or shards are left to the controller cleaning up
+// after them.
+func (ac *ALBController) releaseDeletedIngresses() {
+	for _, item := range ac.storeLister.Ingress.List() {
+		ingResource := item.(*extensions.Ingress)
+		if ingResource.DeletionTimestamp == nil || !hasFinalizer(ingResource) || !ac.validIngress(ingResource) {
+			continue
+		}
+		name := ingResource.Name
+		if group := ac.ingressGroup(ingResource); group != "" {
+			name = groupIngressName(group)
+		}
+		i := ac.ALBIngresses.find(NewALBIngress(ingResource.Namespace, name, *ac.clusterName))
+		if i >= 0 && len(ac.ALBIngresses[i].LoadBalancers) > 0 && (name == ingResource.Name || ac.ALBIngresses[i].hasMember(ingResource.Name)) {
+			continue
+		}
+		ac.updateFinalizer(ingResource, false)
+	}
+}
+
+// updateFinalizer adds the ingressFinalizer to, or removes it from, an ingress resource. Failures
+// are logged and retried on the next sync.
+func (ac *ALBController) updateFinalizer(ingResource *extensions.Ingress, add bool) {
+	if ac.client == nil {
+		return
+	}
+	ingressID := fmt.Sprintf("%s-%s", ingResource.Namespace, ingResource.Name)
+
+	// The ingress is fetched rather than taken from the store, which must not be modified.
+	ingresses := ac.client.Extensions().Ingresses(ingResource.Namespace)
+	current, err := ingresses.Get(ingResource.Name, metav1.GetOptions{})
+	if err != nil {
+		log.Errorf("Failed to get ingress for finalizer update. Error: %s", ingressID, err.Error())
+		return
+	}
+
+	var finalizers []string
+	for _, f := range current.Finalizers {
+		if f != ingressFinalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	if add {
+		finalizers = append(finalizers, ingressFinalizer)
+	}
+	current.Finalizers = finalizers
+
+	if _, err := ingresses.Update(current); err != nil {
+		log.Errorf("Failed to update ingress finalizers. Error: %s", ingressID, err.Error())
+		return
+	}
+	if add {
+		log.Infof("Added finalizer %s.", ingressID, ingressFinalizer)
+	} else {
+		log.Infof("AWS resources deleted, removed finalizer %s.", ingressID, ingressFinalizer)
+	}
+}
+
+// hasFinalizer returns true when the ingress resource carries the ingressFinalizer.
+func hasFinalizer(ingResource *extensions.Ingress) bool {
+	for _, f := range ingResource.Finalizers {
+		if f == ingressFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// syncTargetHealth polls the health of every managed target group. A warning Event is emitted on
+// the ingress resource for each target that turned unhealthy since the previous poll, and for each
+// target group whose targets keep changing between healthy and unhealthy. With podTargetHealth, the
+// target health conditions of pods are set once every target group was polled.
+func (ac *ALBController) syncTargetHealth() {
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+	if ac.podTargetHealth {
+		defer ac.syncPodConditions()
+	}
+
+	for _, ALBIngress := range ac.ALBIngresses {
+		if ac.stopping() {
+			return
+		}
+		unhealthy, timedOut, flapping := ALBIngress.UpdateTargetHealth()
+		if len(unhealthy) == 0 && len(timedOut) == 0 && len(flapping) == 0 {
+			continue
+		}
+
+		ingResource, exists := ac.ingressResource(*ALBIngress.namespace, *ALBIngress.ingressName)
+		if !exists {
+			continue
+		}
+
+		for tgID, descriptions := range unhealthy {
+			for _, d := range descriptions {
+				ac.recorder.Eventf(ingResource, api.EventTypeWarning, "UnhealthyTarget",
+					"Target %s of target group %s is unhealthy: %s", *d.Target.Id, tgID, aws.StringValue(d.TargetHealth.Description))
+			}
+		}
+		for tgID, ids := range timedOut {
+			ac.recorder.Eventf(ingResource, api.EventTypeWarning, "TargetWarmupTimeout",
+				"Targets %s of target group %s weren't healthy within %s of being registered", strings.Join(ids, ", "), tgID, ac.targetWarmupTimeout)
+		}
+		for tgID, flaps := range flapping {
+			ac.recorder.Eventf(ingResource, api.EventTypeWarning, "TargetHealthFlapping",
+				"Targets of target group %s changed between healthy and unhealthy %d times within %s, check its health check path, timeout and thresholds",
+				tgID, flaps, ac.targetFlapWindow)
+		}
+	}
+}
+
+// syncRecordStates exports the number of Route 53 records owned, present in Route 53 and resolving
+// through DNS, across every ALBIngress. Queued upserts update the records they're made for without
+// holding the ALBIngress lock, so it's serialized with reconciles.
+func (ac *ALBController) syncRecordStates() {
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+
+	var owned, present, resolving int
+	for _, ALBIngress := range ac.ALBIngresses {
+		o, p, r := ALBIngress.RecordStates()
+		owned += o
+		present += p
+		resolving += r
+	}
+
+	awsutil.Route53Records.With(prometheus.Labels{"state": "owned"}).Set(float64(owned))
+	awsutil.Route53Records.With(prometheus.Labels{"state": "present"}).Set(float64(present))
+	awsutil.Route53Records.With(prometheus.Labels{"state": "resolving"}).Set(float64(resolving))
+}
+
+// syncDrift looks for changes made out of band to the AWS resources of every ALBIngress. Ingresses
+// whose resources drifted are reconciled right away, and a warning Event describing what drifted is
+// emitted on the ingress resource.
+func (ac *ALBController) syncDrift() {
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+
+	for _, ALBIngress := range ac.ALBIngresses {
+		if ac.stopping() {
+			return
+		}
+		ac.correctDrift(ALBIngress)
+	}
+}
+
+// correctDrift reconciles ALBIngress when its AWS resources were changed out of band, emitting a
+// warning Event describing what drifted on the ingress resource. It's called with the controller's
+// lock held.
+func (ac *ALBController) correctDrift(ALBIngress *ALBIngress) {
+	drifts := ALBIngress.DetectDrift()
+	if len(drifts) == 0 {
+		return
+	}
+
+	ALBIngress.Reconcile(ac.dnsProvider)
+
+	failed := make(map[string]error)
+	for _, lb := range ALBIngress.LoadBalancers {
+		failed[*lb.ID] = lb.LastError
+	}
+
+	ingResource, exists := ac.ingressResource(*ALBIngress.namespace, *ALBIngress.ingressName)
+	if !exists {
+		return
+	}
+	for lbID, d := range drifts {
+		awsutil.DriftEvents.Inc()
+		if err := failed[lbID]; err != nil {
+			ac.recorder.Eventf(ingResource, api.EventTypeWarning, "DriftDetected",
+				"Out of band changes to ALB %s could not be corrected: %s. Error: %s", lbID, strings.Join(d, "; "), awsutil.DescribeError(err))
+			continue
+		}
+		ac.recorder.Eventf(ingResource, api.EventTypeWarning, "DriftCorrected",
+			"Corrected out of band changes to ALB %s: %s", lbID, strings.Join(d, "; "))
+	}
+}
+
+// receiveChangeEvents receives the CloudTrail events of the CHANGE_EVENTS_QUEUE_URL queue, and
+// corrects the drift of the ALBIngresses whose AWS resources the calls they record changed. Out of
+// band changes are so corrected within seconds rather than at the next drift detection.
+func (ac *ALBController) receiveChangeEvents() {
+	if ac.stopping() {
+		return
+	}
+	messages, err := awsutil.SQSsvc.ReceiveMessages(ac.changeEventsQueueURL)
+	if err != nil {
+		log.Errorf("Failed to receive change events from %s. Error: %s", "controller", ac.changeEventsQueueURL, err.Error())
+		return
+	}
+	for _, message := range messages {
+		ac.handleChangeEvent(aws.StringValue(message.Body))
+		// Events that can't be handled would only be received again.
+		if err := awsutil.SQSsvc.DeleteMessage(ac.changeEventsQueueURL, message.ReceiptHandle); err != nil {
+			log.Errorf("Failed to delete a change event from %s. Error: %s", "controller", ac.changeEventsQueueURL, err.Error())
+		}
+	}
+}
+
+// handleChangeEvent corrects the drift of the ALBIngresses owning a resource the call recorded by
+// the change event body changed.
+func (ac *ALBController) handleChangeEvent(body string) {
+	event, err := awsutil.ParseChangeEvent(body)
+	if err != nil {
+		log.Warnf("Ignoring a change event that can't be read. Error: %s", "controller", err.Error())
+		return
+	}
+	if event == nil || len(event.Resources) == 0 {
+		return
+	}
+	// The controller's own calls are told apart by the suffix of their User-Agent.
+	if awsutil.UserAgentSuffix != "" && strings.Contains(event.UserAgent, awsutil.UserAgentSuffix) {
+		return
+	}
+
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+	for _, ALBIngress := range ac.ALBIngresses {
+		if ac.stopping() {
+			return
+		}
+		for _, id := range event.Resources {
+			if ALBIngress.OwnsResource(id) {
+				log.Infof("%s changed %s out of band, looking for drift.", *ALBIngress.id, event.EventName, id)
+				ac.correctDrift(ALBIngress)
+				break
+			}
+		}
+	}
+}
+
+// syncTrafficShifts reconciles the ALBIngresses shifting traffic to replacement target groups, so
+// the replacements get more of the requests at every step and the target groups they replace are
+// deleted once the shift completed.
+func (ac *ALBController) syncTrafficShifts() {
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+
+	for _, ALBIngress := range ac.ALBIngresses {
+		if ac.stopping() {
+			return
+		}
+		if ALBIngress.ShiftingTraffic() {
+			ALBIngress.Reconcile(ac.dnsProvider)
+		}
+	}
+}
+
 // OverrideFlags configures optional override flags for the ingress controller
 func (ac *ALBController) OverrideFlags(flags *pflag.FlagSet) {
 }
@@ -150,9 +1162,13 @@ func (ac *ALBController) Name() string {
 	return "AWS Application Load Balancer Controller"
 }
 
-// Check tests the ingress controller configuration
+// Check tests the ingress controller configuration. It fails once the controller can no longer talk
+// to AWS, see awsutil.Heartbeat, making the controller unready on the /healthz endpoint.
 func (ac *ALBController) Check(_ *http.Request) error {
-	return nil
+	if ac.heartbeat == nil {
+		return nil
+	}
+	return ac.heartbeat.Check()
 }
 
 // DefaultIngressClass returns thed default ingress class
@@ -170,8 +1186,41 @@ func (ac *ALBController) Info() *ingress.BackendInfo {
 	}
 }
 
-// GetServiceNodePort returns the nodeport for a given Kubernetes service
-func (ac *ALBController) GetServiceNodePort(serviceKey string, backendPort int32) (*int64, error) {
+// importExtraRecordSet returns the record of an extra hostname of an imported ALB, nil when the
+// hostname has no record pointing at the ALB.
+func importExtraRecordSet(ingressID, hostname string, loadBalancer *elbv2.LoadBalancer) *alb.ResourceRecordSet {
+	zone, err := awsutil.Route53svc.GetZoneID(&hostname)
+	if err != nil {
+		log.Infof("Failed to resolve %s zoneID. Returned error %s", ingressID, hostname, err.Error())
+		return nil
+	}
+	records, err := awsutil.Route53svc.DescribeResourceRecordSetsByName(zone.Id, &hostname)
+	if err != nil {
+		log.Errorf("Failed to find %s in AWS Route53", ingressID, hostname)
+		return nil
+	}
+	record := alb.FindResourceRecordSet(records, loadBalancer.DNSName, false)
+	if record == nil || !alb.RecordPointsAt(record, loadBalancer.DNSName) {
+		return nil
+	}
+
+	rs := &alb.ResourceRecordSet{
+		IngressID:                &ingressID,
+		ZoneID:                   zone.Id,
+		Resolveable:              true,
+		CurrentResourceRecordSet: record,
+	}
+	if record.HealthCheckId != nil {
+		if rs.CurrentHealthCheck, err = awsutil.Route53svc.GetHealthCheck(record.HealthCheckId); err != nil {
+			log.Errorf("Failed to get Route 53 health check %s of %s", ingressID, *record.HealthCheckId, hostname)
+		}
+	}
+	return rs
+}
+
+// GetServiceNodePort returns the nodeport for a given Kubernetes service. The backendPort may
+// reference the service port by number or by name.
+func (ac *ALBController) GetServiceNodePort(serviceKey string, backendPort intstr.IntOrString) (*int64, error) {
 	// Verify the service (namespace/service-name) exists in Kubernetes.
 	item, exists, _ := ac.storeLister.Service.GetByKey(serviceKey)
 	if !exists {
@@ -186,12 +1235,150 @@ func (ac *ALBController) GetServiceNodePort(serviceKey string, backendPort int32
 
 	// Find associated target port to ensure correct NodePort is assigned.
 	for _, p := range item.(*api.Service).Spec.Ports {
-		if p.Port == backendPort {
+		switch {
+		case backendPort.Type == intstr.Int && p.Port == backendPort.IntVal:
+			return aws.Int64(int64(p.NodePort)), nil
+		case backendPort.Type == intstr.String && p.Name == backendPort.StrVal:
 			return aws.Int64(int64(p.NodePort)), nil
 		}
 	}
 
-	return nil, fmt.Errorf("Unable to find a port defined in the %v service", serviceKey)
+	return nil, fmt.Errorf("Unable to find port %s defined in the %v service", backendPort.String(), serviceKey)
+}
+
+// resolveHealthcheckPort returns the port the target groups of a service are health checked on. A
+// named health check port refers to a port of the service. In instance mode it resolves to the
+// NodePort of that port, in ip mode to the port the endpoints receive it on.
+func (ac *ALBController) resolveHealthcheckPort(serviceKey string, annotations *config.Annotations) (*string, error) {
+	if !config.IsNamedPort(*annotations.HealthcheckPort) {
+		return annotations.HealthcheckPort, nil
+	}
+
+	var port *int64
+	var err error
+	switch *annotations.TargetType {
+	case awsutil.TargetTypeIP:
+		port, _, err = ac.GetServiceEndpoints(serviceKey, intstr.FromString(*annotations.HealthcheckPort))
+	default:
+		port, err = ac.GetServiceNodePort(serviceKey, intstr.FromString(*annotations.HealthcheckPort))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to resolve health check port %s. Error: %s", *annotations.HealthcheckPort, err.Error())
+	}
+	return aws.String(fmt.Sprintf("%d", *port)), nil
+}
+
+// GetServiceEndpoints returns the ready endpoint IPs backing a given Kubernetes service, along with
+// the port they receive traffic on. Unlike GetServiceNodePort, any service type (including headless
+// ClusterIP services) is accepted, as the ALB routes straight to the pods.
+func (ac *ALBController) GetServiceEndpoints(serviceKey string, backendPort intstr.IntOrString) (*int64, util.AWSStringSlice, error) {
+	// Verify the service (namespace/service-name) exists in Kubernetes.
+	item, exists, _ := ac.storeLister.Service.GetByKey(serviceKey)
+	if !exists {
+		return nil, nil, fmt.Errorf("Unable to find the %v service", serviceKey)
+	}
+	svc := item.(*api.Service)
+
+	// Find the service port the backend references.
+	var svcPort *api.ServicePort
+	for i, p := range svc.Spec.Ports {
+		if (backendPort.Type == intstr.Int && p.Port == backendPort.IntVal) ||
+			(backendPort.Type == intstr.String && p.Name == backendPort.StrVal) {
+			svcPort = &svc.Spec.Ports[i]
+			break
+		}
+	}
+	if svcPort == nil {
+		return nil, nil, fmt.Errorf("Unable to find port %s defined in the %v service", backendPort.String(), serviceKey)
+	}
+
+	var port *int64
+	var targets util.AWSStringSlice
+
+	// Endpoint ports carry the name of the service port they were created for.
+	endpoints, err := ac.storeLister.Endpoint.GetServiceEndpoints(svc)
+	if err == nil {
+		for _, subset := range endpoints.Subsets {
+			for _, p := range subset.Ports {
+				if p.Name != svcPort.Name {
+					continue
+				}
+				port = aws.Int64(int64(p.Port))
+				for _, addr := range subset.Addresses {
+					targets = append(targets, aws.String(addr.IP))
+				}
+			}
+		}
+	}
+
+	// Without endpoints, fall back to the numeric target port so the target group can still be
+	// created. Targets are registered once pods become ready.
+	if port == nil {
+		if svcPort.TargetPort.Type != intstr.Int {
+			return nil, nil, fmt.Errorf("Unable to resolve target port %s of the %v service without endpoints", svcPort.TargetPort.String(), serviceKey)
+		}
+		port = aws.Int64(int64(svcPort.TargetPort.IntVal))
+	}
+
+	sort.Sort(targets)
+	return port, targets, nil
+}
+
+// GetServiceStaticTargets returns the IP addresses listed in the static-targets annotation of a
+// given Kubernetes service.
+func (ac *ALBController) GetServiceStaticTargets(serviceKey string) (util.AWSStringSlice, error) {
+	item, exists, _ := ac.storeLister.Service.GetByKey(serviceKey)
+	if !exists {
+		return nil, fmt.Errorf("Unable to find the %v service", serviceKey)
+	}
+	return config.ParseStaticTargets(item.(*api.Service).Annotations)
+}
+
+// backendServiceKey returns the key (namespace/service-name) of the service an ingress backend
+// forwards to. It lives in the ingress's namespace unless a service-namespace annotation moves it
+// to another one, whose service must then allow the ingress's namespace with its
+// allowed-ingress-namespaces annotation.
+func (ac *ALBController) backendServiceKey(namespace string, annotations *config.Annotations, serviceName string) (string, error) {
+	serviceNamespace, ok := annotations.ServiceNamespaces[serviceName]
+	if !ok || serviceNamespace == namespace {
+		return fmt.Sprintf("%s/%s", namespace, serviceName), nil
+	}
+
+	serviceKey := fmt.Sprintf("%s/%s", serviceNamespace, serviceName)
+	item, exists, _ := ac.storeLister.Service.GetByKey(serviceKey)
+	if !exists {
+		return "", fmt.Errorf("Unable to find the %v service", serviceKey)
+	}
+	if !config.AllowsIngressNamespace(item.(*api.Service).Annotations, namespace) {
+		return "", fmt.Errorf("%v service doesn't allow ingresses of namespace %s to forward to it", serviceKey, namespace)
+	}
+	return serviceKey, nil
+}
+
+// hasFargateEndpoints returns true when any of the service's endpoints run on a Fargate node.
+func (ac *ALBController) hasFargateEndpoints(serviceKey string) bool {
+	item, exists, _ := ac.storeLister.Service.GetByKey(serviceKey)
+	if !exists {
+		return false
+	}
+
+	endpoints, err := ac.storeLister.Endpoint.GetServiceEndpoints(item.(*api.Service))
+	if err != nil {
+		return false
+	}
+
+	for _, subset := range endpoints.Subsets {
+		for _, addr := range subset.Addresses {
+			if addr.NodeName == nil {
+				continue
+			}
+			node, exists, _ := ac.storeLister.Node.GetByKey(*addr.NodeName)
+			if exists && isFargateNode(node.(*api.Node)) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // Returns a list of ingress objects that are no longer known to kubernetes and should
@@ -225,24 +1412,75 @@ func (ac *ALBController) StateHandler(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(ac.ALBIngresses)
 }
 
-// assembleIngresses builds a list of existing ingresses from resources in AWS
+// assembleIngresses builds a list of existing ingresses from resources in AWS, looking them up
+// under the controller's own credentials and under every IAM role namespaces are annotated with.
 func (ac *ALBController) assembleIngresses() {
 	log.Infof("Build up list of existing ingresses", "controller")
 	ac.ALBIngresses = nil
 
+	roles, err := ac.namespaceRoles()
+	if err != nil {
+		glog.Fatal(err)
+	}
+	snapshot := ac.loadSnapshot()
+	ac.importIngresses("", roles, snapshot)
+	imported := make(map[string]bool)
+	for _, role := range roles {
+		if !imported[role] {
+			imported[role] = true
+			ac.importIngresses(role, roles, snapshot)
+		}
+	}
+
+	log.Infof("Assembled %d ingresses from existing AWS resources", "controller", len(ac.ALBIngresses))
+}
+
+// importIngresses adds the ingresses whose resources exist under the IAM role roleArn to the
+// ALBIngresses. Resources of ingresses in namespaces managed as another role, according to roles,
+// are left out. The ALBs saved in snapshot, if any, are restored from it rather than described.
+func (ac *ALBController) importIngresses(roleArn string, roles map[string]string, snapshot *stateSnapshot) {
+	defer awsutil.AssumeRole(roleArn)()
+
 	loadBalancers, err := awsutil.ALBsvc.DescribeLoadBalancers(ac.clusterName)
 	if err != nil {
 		glog.Fatal(err)
 	}
 
+	// The tags of the ALBs are only described once an ALB isn't restored from the snapshot, for all
+	// of them at once.
+	var loadBalancerTags map[string]util.Tags
+
 	for _, loadBalancer := range loadBalancers {
 
 		var err error
 
-		log.Debugf("Fetching Tags for %s", "controller", *loadBalancer.LoadBalancerArn)
-		tags, err := awsutil.ALBsvc.DescribeTags(loadBalancer.LoadBalancerArn)
-		if err != nil {
-			glog.Fatal(err)
+		if lb, ok := snapshot.restore(loadBalancer, roleArn); ok {
+			namespace, _ := lb.CurrentTags.Get("Namespace")
+			ingressName, _ := lb.CurrentTags.Get("IngressName")
+			if roles[namespace] == roleArn && ac.managesIngress(namespace, ingressName, lb.CurrentTags) {
+				log.Debugf("Restored the LoadBalancer %s from the state snapshot", "controller", *loadBalancer.LoadBalancerName)
+				ac.addImportedLoadBalancer(namespace, ingressName, roleArn, lb)
+				continue
+			}
+		}
+
+		if loadBalancerTags == nil {
+			log.Debugf("Fetching Tags for %d LoadBalancers", "controller", len(loadBalancers))
+			var arns []*string
+			for _, loadBalancer := range loadBalancers {
+				arns = append(arns, loadBalancer.LoadBalancerArn)
+			}
+			if loadBalancerTags, err = awsutil.ALBsvc.DescribeTagsBatch(arns); err != nil {
+				glog.Fatal(err)
+			}
+		}
+		tags := loadBalancerTags[*loadBalancer.LoadBalancerArn]
+
+		// Resources owned by another cluster, controller or shard are left alone, even if their names
+		// match.
+		if err := tags.OwnershipConflict(ac.ownerTags()); err != nil {
+			log.Warnf("The LoadBalancer %s is %s, can't import", "controller", *loadBalancer.LoadBalancerName, err.Error())
+			continue
 		}
 
 		ingressName, ok := tags.Get("IngressName")
@@ -257,12 +1495,23 @@ func (ac *ALBController) assembleIngresses() {
 			continue
 		}
 
+		if roles[namespace] != roleArn {
+			log.Debugf("The LoadBalancer %s belongs to a namespace managed as another IAM role, skipping import", "controller", *loadBalancer.LoadBalancerName)
+			continue
+		}
+
 		hostname, ok := tags.Get("Hostname")
 		if !ok {
 			log.Infof("The LoadBalancer %s does not have a Hostname tag, can't import", "controller", *loadBalancer.LoadBalancerName)
 			continue
 		}
 
+		// The ALBs of ingresses of another class or shard belong to another controller deployment.
+		if !ac.managesIngress(namespace, ingressName, tags) {
+			log.Debugf("The LoadBalancer %s belongs to an ingress managed by another controller, skipping import", "controller", *loadBalancer.LoadBalancerName)
+			continue
+		}
+
 		ingressID := namespace + "-" + ingressName
 
 		var rs *alb.ResourceRecordSet
@@ -275,29 +1524,104 @@ func (ac *ALBController) assembleIngresses() {
 			}
 
 			log.Infof("Fetching resource recordset for %s/%s %s", "controller", namespace, ingressName, hostname)
-			resourceRecordSet, err := awsutil.Route53svc.DescribeResourceRecordSets(zone.Id,
-				&hostname)
-			if err != nil {
+			records, err := awsutil.Route53svc.DescribeResourceRecordSetsByName(zone.Id, &hostname)
+			resourceRecordSet := alb.FindResourceRecordSet(records, loadBalancer.DNSName, false)
+			if err != nil || resourceRecordSet == nil {
 				log.Errorf("Failed to find %s in AWS Route53", ingressID, hostname)
 			}
 
 			rs = &alb.ResourceRecordSet{
-				IngressID: &ingressID,
-				ZoneID:    zone.Id,
+				IngressID:                &ingressID,
+				ZoneID:                   zone.Id,
 				CurrentResourceRecordSet: resourceRecordSet,
 			}
+
+			// Primary failover records may have a health check of their own.
+			if resourceRecordSet != nil && resourceRecordSet.HealthCheckId != nil {
+				if rs.CurrentHealthCheck, err = awsutil.Route53svc.GetHealthCheck(resourceRecordSet.HealthCheckId); err != nil {
+					log.Errorf("Failed to get Route 53 health check %s of %s", ingressID, *resourceRecordSet.HealthCheckId, hostname)
+				}
+			}
 		} else {
 			log.Warnf("Route53 disabled", ingressID)
 			rs = nil
 		}
 
+		// The attributes of an ALB with a ConfigHash tag are those the hash was made from, they're
+		// only described when the ALB predates the tag.
+		var attributes []*elbv2.LoadBalancerAttribute
+		if _, ok := tags.Get(util.ConfigHashTag); !ok {
+			attributes, err = awsutil.ALBsvc.DescribeLoadBalancerAttributes(loadBalancer.LoadBalancerArn)
+			if err != nil {
+				glog.Fatal(err)
+			}
+		}
+
+		// Dualstack ALBs may have an AAAA record as well.
+		var ipv6 *alb.ResourceRecordSet
+		if rs != nil && aws.StringValue(loadBalancer.IpAddressType) == elbv2.IpAddressTypeDualstack {
+			if records, err := awsutil.Route53svc.DescribeResourceRecordSetsByName(rs.ZoneID, &hostname); err == nil {
+				if record := alb.FindResourceRecordSet(records, loadBalancer.DNSName, true); record != nil {
+					ipv6 = &alb.ResourceRecordSet{
+						IngressID:                &ingressID,
+						ZoneID:                   rs.ZoneID,
+						Resolveable:              rs.Resolveable,
+						CurrentResourceRecordSet: record,
+					}
+				}
+			}
+		}
+
+		// The extra hostnames of the ingress are tagged on the ALB.
+		extraValue, _ := tags.Get("ExtraHostnames")
+		extraHostnames := strings.Fields(extraValue)
+		var extra []*alb.ResourceRecordSet
+		if rs != nil {
+			for _, h := range extraHostnames {
+				if record := importExtraRecordSet(ingressID, h, loadBalancer); record != nil {
+					extra = append(extra, record)
+				}
+			}
+		}
+
 		lb := &alb.LoadBalancer{
 			ID:                  loadBalancer.LoadBalancerName,
 			IngressID:           &ingressID,
 			Hostname:            aws.String(hostname),
 			CurrentLoadBalancer: loadBalancer,
 			ResourceRecordSet:   rs,
+			IPv6RecordSet:       ipv6,
+			ExtraHostnames:      extraHostnames,
+			ExtraRecordSets:     extra,
 			CurrentTags:         tags,
+			CurrentAttributes:   attributes,
+		}
+		// The WebACLId tag only says which web ACL the controller associated, the association may
+		// have changed since.
+		if lb.CurrentWebACLID, err = awsutil.WAFsvc.GetWebACLForResource(loadBalancer.LoadBalancerArn); err != nil {
+			log.Warnf("Unable to describe the web ACL of the LoadBalancer %s, assuming the one it's tagged with. Error: %s",
+				"controller", *loadBalancer.LoadBalancerName, awsutil.DescribeError(err))
+			if webACLID, ok := tags.Get("WebACLId"); ok {
+				lb.CurrentWebACLID = aws.String(webACLID)
+			}
+		}
+		lb.Accelerator = alb.ImportAccelerator(lb)
+
+		// A security group named after the load balancer is one the controller manages for it.
+		if len(loadBalancer.SecurityGroups) > 0 {
+			sgs, err := awsutil.Ec2svc.DescribeSecurityGroups(ec2.DescribeSecurityGroupsInput{GroupIds: loadBalancer.SecurityGroups})
+			if err != nil {
+				glog.Fatal(err)
+			}
+			for _, sg := range sgs {
+				if *sg.GroupName == *loadBalancer.LoadBalancerName {
+					lb.SecurityGroup = &alb.SecurityGroup{
+						IngressID:            &ingressID,
+						Owner:                namespace + "/" + ingressName,
+						CurrentSecurityGroup: sg,
+					}
+				}
+			}
 		}
 
 		targetGroups, err := awsutil.ALBsvc.DescribeTargetGroups(loadBalancer.LoadBalancerArn)
@@ -305,10 +1629,21 @@ func (ac *ALBController) assembleIngresses() {
 			glog.Fatal(err)
 		}
 
+		var targetGroupArns []*string
 		for _, targetGroup := range targetGroups {
-			tags, err := awsutil.ALBsvc.DescribeTags(targetGroup.TargetGroupArn)
-			if err != nil {
-				glog.Fatal(err)
+			targetGroupArns = append(targetGroupArns, targetGroup.TargetGroupArn)
+		}
+		targetGroupTags, err := awsutil.ALBsvc.DescribeTagsBatch(targetGroupArns)
+		if err != nil {
+			glog.Fatal(err)
+		}
+
+		for _, targetGroup := range targetGroups {
+			tags := targetGroupTags[*targetGroup.TargetGroupArn]
+
+			if err := tags.OwnershipConflict(ac.ownerTags()); err != nil {
+				log.Warnf("The TargetGroup %s is %s, can't import", "controller", *targetGroup.TargetGroupName, err.Error())
+				continue
 			}
 
 			svcName, ok := tags.Get("ServiceName")
@@ -317,12 +1652,34 @@ func (ac *ALBController) assembleIngresses() {
 				continue
 			}
 
+			// Target groups created before the ServicePort tag was introduced won't have it. They're
+			// imported with an empty port and pick up the tag on their next modification.
+			svcPort, _ := tags.Get("ServicePort")
+
+			// Likewise, target groups without a TargetType tag were created in instance mode.
+			targetType, ok := tags.Get("TargetType")
+			if !ok {
+				targetType = awsutil.TargetTypeInstance
+			}
+
+			// Likewise for the attributes of target groups.
+			var attributes []*elbv2.TargetGroupAttribute
+			if _, ok := tags.Get(util.ConfigHashTag); !ok {
+				attributes, err = awsutil.ALBsvc.DescribeTargetGroupAttributes(targetGroup.TargetGroupArn)
+				if err != nil {
+					glog.Fatal(err)
+				}
+			}
+
 			tg := &alb.TargetGroup{
 				ID:                 targetGroup.TargetGroupName,
 				IngressID:          &ingressID,
 				SvcName:            svcName,
+				SvcPort:            intstr.Parse(svcPort),
+				TargetType:         aws.String(targetType),
 				CurrentTags:        tags,
 				CurrentTargetGroup: targetGroup,
+				CurrentAttributes:  attributes,
 			}
 			log.Infof("Fetching Targets for Target Group %s", "controller", *targetGroup.TargetGroupArn)
 
@@ -331,6 +1688,11 @@ func (ac *ALBController) assembleIngresses() {
 				glog.Fatal(err)
 			}
 			tg.CurrentTargets = targets
+			// Static targets are told apart by their availability zone, so they're deregistered
+			// as such once removed from the static-targets annotation.
+			if tg.CurrentStaticTargets, err = awsutil.ALBsvc.DescribeTargetGroupExternalTargets(targetGroup.TargetGroupArn); err != nil {
+				glog.Fatal(err)
+			}
 			lb.TargetGroups = append(lb.TargetGroups, tg)
 		}
 
@@ -350,19 +1712,31 @@ func (ac *ALBController) assembleIngresses() {
 				CurrentListener: listener,
 				IngressID:       &ingressID,
 			}
+			if aws.StringValue(listener.Protocol) == "HTTPS" {
+				if l.CurrentSNICertificates, err = awsutil.ALBsvc.DescribeListenerCertificates(listener.ListenerArn); err != nil {
+					glog.Fatal(err)
+				}
+			}
 
 			for _, rule := range rules {
 				var svcName string
+				var svcPort intstr.IntOrString
 				for _, tg := range lb.TargetGroups {
+					// Redirect rules don't forward to a target group.
+					if rule.Actions[0].TargetGroupArn == nil {
+						break
+					}
 					if *rule.Actions[0].TargetGroupArn == *tg.CurrentTargetGroup.TargetGroupArn {
 						svcName = tg.SvcName
+						svcPort = tg.SvcPort
 					}
 				}
 
-				log.Debugf("Assembling rule with svc name: %s", "controller", svcName)
+				log.Debugf("Assembling rule with svc name: %s | svc port: %s", "controller", svcName, svcPort.String())
 				l.Rules = append(l.Rules, &alb.Rule{
 					IngressID:   &ingressID,
 					SvcName:     svcName,
+					SvcPort:     svcPort,
 					CurrentRule: rule,
 				})
 			}
@@ -373,16 +1747,57 @@ func (ac *ALBController) assembleIngresses() {
 			lb.Listeners = append(lb.Listeners, l)
 		}
 
-		a := NewALBIngress(namespace, ingressName, *ac.clusterName)
-		a.LoadBalancers = []*alb.LoadBalancer{lb}
+		ac.addImportedLoadBalancer(namespace, ingressName, roleArn, lb)
+	}
+}
 
-		if i := ac.ALBIngresses.find(a); i >= 0 {
-			a = ac.ALBIngresses[i]
-			a.LoadBalancers = append(a.LoadBalancers, lb)
-		} else {
-			ac.ALBIngresses = append(ac.ALBIngresses, a)
-		}
+// addImportedLoadBalancer adds lb, imported under the IAM role roleArn, to the ALBIngress of the
+// ingress namespace/ingressName.
+func (ac *ALBController) addImportedLoadBalancer(namespace, ingressName, roleArn string, lb *alb.LoadBalancer) {
+	a := NewALBIngress(namespace, ingressName, *ac.clusterName)
+	a.LoadBalancers = []*alb.LoadBalancer{lb}
+	a.roleArn = roleArn
+
+	if i := ac.ALBIngresses.find(a); i >= 0 {
+		a = ac.ALBIngresses[i]
+		a.LoadBalancers = append(a.LoadBalancers, lb)
+	} else {
+		ac.ALBIngresses = append(ac.ALBIngresses, a)
 	}
+}
 
-	log.Infof("Assembled %d ingresses from existing AWS resources", "controller", len(ac.ALBIngresses))
+// namespaceRole returns the IAM role the AWS resources of the ingresses in namespace are managed
+// as, named by the namespaceRoleKey annotation of the namespace. It's empty when the namespace has
+// none, or when the controller isn't running in a cluster.
+func (ac *ALBController) namespaceRole(namespace string) (string, error) {
+	if ac.client == nil {
+		return "", nil
+	}
+	ns, err := ac.namespace(namespace)
+	if err != nil {
+		return "", err
+	}
+	role := ns.Annotations[namespaceRoleKey]
+	if role != "" && !roleArnPattern.MatchString(role) {
+		return "", fmt.Errorf("%s %s of namespace %s is not an IAM role ARN", namespaceRoleKey, role, namespace)
+	}
+	return role, nil
+}
+
+// namespaceRoles returns the IAM role of every namespace annotated with one, keyed by namespace.
+func (ac *ALBController) namespaceRoles() (map[string]string, error) {
+	roles := make(map[string]string)
+	if ac.client == nil {
+		return roles, nil
+	}
+	namespaces, err := ac.listNamespaces()
+	if err != nil {
+		return nil, err
+	}
+	for _, ns := range namespaces {
+		if role := ns.Annotations[namespaceRoleKey]; role != "" {
+			roles[ns.Name] = role
+		}
+	}
+	return roles, nil
 }
@@ -1,6 +1,14 @@
 package controller
 
-import "github.com/aws/aws-sdk-go/aws"
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/coreos/alb-ingress-controller/controller/config"
+	"github.com/coreos/alb-ingress-controller/controller/util"
+	extensions "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/tools/cache"
+)
 
 var a *ALBIngress
 
@@ -19,3 +27,64 @@ func setup() {
 	}
 
 }
+
+func TestManagesIngress(t *testing.T) {
+	shards := make([]*ALBController, 3)
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for i := range shards {
+		shards[i] = &ALBController{shardCount: 3, shardIndex: uint32(i), shardBy: shardByClass}
+		shards[i].storeLister.Ingress.Store = store
+	}
+	owners := func(namespace, name string, tags util.Tags) (owners []int) {
+		for i, ac := range shards {
+			if ac.managesIngress(namespace, name, tags) {
+				owners = append(owners, i)
+			}
+		}
+		return owners
+	}
+
+	// Ingresses of a class, or of a shard group, are managed by the same shard.
+	for _, name := range []string{"app", "api", "web"} {
+		ingress := &extensions.Ingress{}
+		ingress.Namespace, ingress.Name = "default", name
+		ingress.Annotations = map[string]string{"kubernetes.io/ingress.class": "internal"}
+		store.Add(ingress)
+		grouped := &extensions.Ingress{}
+		grouped.Namespace, grouped.Name = "team-"+name, name
+		grouped.Annotations = map[string]string{config.ShardGroupKey: "team"}
+		store.Add(grouped)
+	}
+	class, group := owners("default", "app", nil), owners("team-app", "app", nil)
+	if len(class) != 1 || len(group) != 1 {
+		t.Fatalf("managesIngress(): expected one shard per ingress, actual %v and %v", class, group)
+	}
+	for _, name := range []string{"api", "web"} {
+		if o := owners("default", name, nil); o[0] != class[0] {
+			t.Errorf("managesIngress(default/%s): expected shard %d of its class, actual %v", name, class[0], o)
+		}
+		if o := owners("team-"+name, name, nil); o[0] != group[0] {
+			t.Errorf("managesIngress(team-%s/%s): expected shard %d of its group, actual %v", name, name, group[0], o)
+		}
+	}
+
+	// The ALB of a deleted ingress is managed by the shard it's tagged with, unless it was tagged
+	// out of another number of shards.
+	tags := util.Tags{{Key: aws.String(util.ShardTag), Value: aws.String("2/3")}}
+	if o := owners("default", "deleted", tags); len(o) != 1 || o[0] != 2 {
+		t.Errorf("managesIngress(default/deleted): expected shard 2, actual %v", o)
+	}
+	tags = util.Tags{{Key: aws.String(util.ShardTag), Value: aws.String("7/8")}}
+	expected := owners("default", "deleted", nil)
+	if o := owners("default", "deleted", tags); len(o) != 1 || o[0] != expected[0] {
+		t.Errorf("managesIngress(default/deleted): expected shard %v, actual %v", expected, o)
+	}
+
+	// Shards don't take over each other's resources.
+	if err := tags.OwnershipConflict(shards[0].shardTags()); err != nil {
+		t.Errorf("OwnershipConflict(): expected no conflict with a stale shard, actual %v", err)
+	}
+	if err := shards[1].shardTags().OwnershipConflict(shards[0].shardTags()); err == nil {
+		t.Errorf("OwnershipConflict(): expected a conflict with another shard")
+	}
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/controller/util"
+	"github.com/prometheus/client_golang/prometheus"
+	extensions "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+const (
+	// Default prices, in USD, of an ALB-hour and an LCU-hour in us-east-1
+	defaultALBHourlyPrice = 0.0225
+	defaultLCUHourlyPrice = 0.008
+	// defaultEstimatedLCUs is the average number of LCUs an ALB is assumed to use. Actual usage
+	// depends on traffic, which the controller doesn't see.
+	defaultEstimatedLCUs = 1
+	// hoursPerMonth is the average number of hours in a month
+	hoursPerMonth = 730
+)
+
+// costPrices are the prices cost estimates are made from.
+type costPrices struct {
+	albHourly float64 // USD per ALB-hour
+	lcuHourly float64 // USD per LCU-hour
+	lcus      float64 // LCUs an ALB is assumed to use on average
+}
+
+// newCostPrices returns the prices for cost estimates, using the defaults for those that aren't
+// positive.
+func newCostPrices(albHourly, lcuHourly, lcus float64) costPrices {
+	p := costPrices{albHourly, lcuHourly, lcus}
+	if p.albHourly <= 0 {
+		p.albHourly = defaultALBHourlyPrice
+	}
+	if p.lcuHourly <= 0 {
+		p.lcuHourly = defaultLCUHourlyPrice
+	}
+	if p.lcus <= 0 {
+		p.lcus = defaultEstimatedLCUs
+	}
+	return p
+}
+
+// monthly returns the estimated monthly cost of an ALB.
+func (p costPrices) monthly() float64 {
+	return hoursPerMonth * (p.albHourly + p.lcus*p.lcuHourly)
+}
+
+// costAllocationTags returns the tags of the AWS resources of an ingress for cost allocation, the
+// value of each cost allocation label from the ingress or, when it hasn't the label, from its
+// namespace. Labels set on neither are left out.
+func (ac *ALBController) costAllocationTags(ingress *extensions.Ingress) (util.Tags, error) {
+	var tags util.Tags
+	var namespaceLabels map[string]string
+	for _, key := range ac.costTagKeys {
+		value, ok := ingress.Labels[key]
+		if !ok && ac.client != nil {
+			if namespaceLabels == nil {
+				ns, err := ac.namespace(ingress.Namespace)
+				if err != nil {
+					return nil, err
+				}
+				namespaceLabels = ns.Labels
+				if namespaceLabels == nil {
+					namespaceLabels = map[string]string{}
+				}
+			}
+			value, ok = namespaceLabels[key]
+		}
+		if ok {
+			tags = append(tags, &elbv2.Tag{Key: aws.String(key), Value: aws.String(value)})
+		}
+	}
+	return tags, nil
+}
+
+// syncCostEstimates exports the estimated monthly cost of the ALBs of every ALBIngress.
+func (ac *ALBController) syncCostEstimates() {
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+
+	estimates := make(map[string]prometheus.Labels)
+	for _, ALBIngress := range ac.ALBIngresses {
+		for _, lb := range ALBIngress.LoadBalancers {
+			if lb.CurrentLoadBalancer == nil {
+				continue
+			}
+			name := *lb.CurrentLoadBalancer.LoadBalancerName
+			labels := prometheus.Labels{"ingress": *ALBIngress.id, "load_balancer": name}
+			awsutil.CostEstimate.With(labels).Set(ac.costPrices.monthly())
+			estimates[fmt.Sprintf("%s %s", *ALBIngress.id, name)] = labels
+		}
+	}
+
+	// ALBs that went away stop being exported.
+	for key, labels := range ac.costEstimates {
+		if _, ok := estimates[key]; !ok {
+			awsutil.CostEstimate.Delete(labels)
+		}
+	}
+	ac.costEstimates = estimates
+}
@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/coreos/alb-ingress-controller/controller/config"
+	extensions "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+// groupPrefix starts the names of the ALBIngresses of groups of ingresses. Ingress names can't
+// contain a colon, so they're never mistaken for the ALBIngress of an ingress.
+const groupPrefix = "group:"
+
+// ingressGroup returns the group of ingresses of its namespace an ingress shares an ALB with, as
+// set by GROUP_BY: every ingress of the namespace, or the ones with the same group annotation. It's
+// empty for an ingress getting ALBs of its own.
+func (ac *ALBController) ingressGroup(i *extensions.Ingress) string {
+	switch ac.groupBy {
+	case groupByNamespace:
+		return i.Namespace
+	case groupByGroup:
+		return i.Annotations[config.GroupKey]
+	}
+	return ""
+}
+
+// groupIngressName returns the name of the ALBIngress of a group.
+func groupIngressName(group string) string {
+	return groupPrefix + group
+}
+
+// groupName returns the group the ALBIngress named ingressName is the ALBIngress of, false for the
+// ALBIngress of an ingress.
+func groupName(ingressName string) (string, bool) {
+	if !strings.HasPrefix(ingressName, groupPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(ingressName, groupPrefix), true
+}
+
+// groupMembers returns the ingresses of a group of namespace, in order of name.
+func (ac *ALBController) groupMembers(namespace, group string) []*extensions.Ingress {
+	byName := make(map[string]*extensions.Ingress)
+	var names []string
+	for _, item := range ac.storeLister.Ingress.List() {
+		i := item.(*extensions.Ingress)
+		if i.Namespace == namespace && ac.validIngress(i) && ac.ingressGroup(i) == group {
+			byName[i.Name] = i
+			names = append(names, i.Name)
+		}
+	}
+	sort.Strings(names)
+	var members []*extensions.Ingress
+	for _, name := range names {
+		members = append(members, byName[name])
+	}
+	return members
+}
+
+// ingressResource returns the ingress resource of the ALBIngress namespace/name, which events
+// about its ALBs are recorded on: the ingress the ALBIngress was assembled from or, for a group, its
+// first ingress. It's false once there is none.
+func (ac *ALBController) ingressResource(namespace, name string) (*extensions.Ingress, bool) {
+	if group, ok := groupName(name); ok {
+		members := ac.groupMembers(namespace, group)
+		if len(members) == 0 {
+			return nil, false
+		}
+		return members[0], true
+	}
+	item, exists, _ := ac.storeLister.Ingress.GetByKey(fmt.Sprintf("%s/%s", namespace, name))
+	if !exists {
+		return nil, false
+	}
+	return item.(*extensions.Ingress), true
+}
+
+// mergeIngresses returns the ingress a group of ingresses of namespace shares an ALB through,
+// with the rules and spec.tls blocks of its members, in order, and their annotations merged by
+// config.MergeAnnotations. Members can't serve the same path of a host with different backends.
+// The ingress is returned with an error as well, so the ALBIngress of the group is still known.
+func mergeIngresses(namespace, group string, members []*extensions.Ingress) (*extensions.Ingress, error) {
+	merged := &extensions.Ingress{}
+	merged.Namespace, merged.Name = namespace, groupIngressName(group)
+
+	var names, hosts []string
+	var annotations []map[string]string
+	servedBy := make(map[string]*extensions.Ingress)
+	for _, member := range members {
+		names = append(names, member.Name)
+		annotations = append(annotations, member.Annotations)
+		merged.Spec.TLS = append(merged.Spec.TLS, member.Spec.TLS...)
+		merged.Spec.Rules = append(merged.Spec.Rules, member.Spec.Rules...)
+		for _, rule := range member.Spec.Rules {
+			// Records can't be kept for wildcard hosts, see config.MergeAnnotations.
+			if rule.Host != "" && !strings.HasPrefix(rule.Host, "*.") {
+				hosts = append(hosts, rule.Host)
+			}
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				key := rule.Host + path.Path
+				other, ok := servedBy[key]
+				if !ok {
+					servedBy[key] = member
+					continue
+				}
+				if other != member && !sameBackend(other, rule.Host, path) {
+					return merged, fmt.Errorf("ingresses %s and %s share an ALB, but serve path %s of host %q with different backends",
+						other.Name, member.Name, path.Path, rule.Host)
+				}
+			}
+		}
+	}
+
+	var err error
+	merged.Annotations, err = config.MergeAnnotations(names, annotations, hosts)
+	return merged, err
+}
+
+// sameBackend reports whether the ingress serves the path of host with the backend of path.
+func sameBackend(ingress *extensions.Ingress, host string, path extensions.HTTPIngressPath) bool {
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Host != host || rule.HTTP == nil {
+			continue
+		}
+		for _, p := range rule.HTTP.Paths {
+			if p.Path == path.Path && p.Backend.ServiceName == path.Backend.ServiceName &&
+				p.Backend.ServicePort.String() == path.Backend.ServicePort.String() {
+				return true
+			}
+		}
+	}
+	return false
+}
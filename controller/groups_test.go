@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/coreos/alb-ingress-controller/controller/config"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	extensions "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// groupedIngress returns the ingress namespace/name of group, serving path of host from service.
+func groupedIngress(namespace, name, group, host, path, service string) *extensions.Ingress {
+	ingress := &extensions.Ingress{}
+	ingress.Namespace, ingress.Name = namespace, name
+	ingress.Annotations = map[string]string{config.GroupKey: group}
+	ingress.Spec.Rules = []extensions.IngressRule{{
+		Host: host,
+		IngressRuleValue: extensions.IngressRuleValue{HTTP: &extensions.HTTPIngressRuleValue{
+			Paths: []extensions.HTTPIngressPath{{
+				Path:    path,
+				Backend: extensions.IngressBackend{ServiceName: service, ServicePort: intstr.FromInt(80)},
+			}},
+		}},
+	}}
+	return ingress
+}
+
+func TestIngressGroups(t *testing.T) {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	store.Add(groupedIngress("default", "web", "public", "www.example.com", "/", "web"))
+	store.Add(groupedIngress("default", "api", "public", "api.example.com", "/", "api"))
+	store.Add(groupedIngress("default", "admin", "", "admin.example.com", "/", "admin"))
+	store.Add(groupedIngress("other", "web", "public", "www.example.org", "/", "web"))
+
+	ac := &ALBController{groupBy: groupByGroup}
+	ac.storeLister.Ingress.Store = store
+	var names []string
+	for _, member := range ac.groupMembers("default", "public") {
+		names = append(names, member.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"api", "web"}) {
+		t.Errorf("groupMembers(default, public): expected [api web], actual %v", names)
+	}
+
+	// Events about the ALB of a group are recorded on its first ingress, and the ALBIngress of an
+	// ingress still resolves to the ingress.
+	if i, ok := ac.ingressResource("default", groupIngressName("public")); !ok || i.Name != "api" {
+		t.Errorf("ingressResource(default, group:public): expected api, actual %v, %v", i, ok)
+	}
+	if i, ok := ac.ingressResource("default", "admin"); !ok || i.Name != "admin" {
+		t.Errorf("ingressResource(default, admin): expected admin, actual %v, %v", i, ok)
+	}
+	if _, ok := ac.ingressResource("default", groupIngressName("private")); ok {
+		t.Errorf("ingressResource(default, group:private): expected no ingress for a group without members")
+	}
+
+	// Grouped by namespace, every ingress of the namespace shares its ALB.
+	ac.groupBy = groupByNamespace
+	if members := ac.groupMembers("default", "default"); len(members) != 3 {
+		t.Errorf("groupMembers(default, default): expected the 3 ingresses of default, actual %d", len(members))
+	}
+	ac.groupBy = groupByIngress
+	if group := ac.ingressGroup(groupedIngress("default", "web", "public", "", "/", "web")); group != "" {
+		t.Errorf("ingressGroup(): expected no group with GROUP_BY=ingress, actual %q", group)
+	}
+}
+
+func TestMergeIngresses(t *testing.T) {
+	web := groupedIngress("default", "web", "public", "www.example.com", "/", "web")
+	web.Annotations["alb.ingress.kubernetes.io/scheme"] = "internet-facing"
+	web.Spec.TLS = []extensions.IngressTLS{{Hosts: []string{"www.example.com"}}}
+	api := groupedIngress("default", "api", "public", "*.example.com", "/api", "api")
+	api.Annotations["alb.ingress.kubernetes.io/scheme"] = "internet-facing"
+
+	merged, err := mergeIngresses("default", "public", []*extensions.Ingress{web, api})
+	if err != nil {
+		t.Fatalf("mergeIngresses() returned error %v", err)
+	}
+	if merged.Name != "group:public" || len(merged.Spec.Rules) != 2 || len(merged.Spec.TLS) != 1 {
+		t.Errorf("mergeIngresses(): expected group:public with the rules and TLS of both ingresses, actual %v", merged)
+	}
+	// The wildcard host gets no record.
+	if hostnames := merged.Annotations["alb.ingress.kubernetes.io/hostname"]; hostnames != "www.example.com" {
+		t.Errorf("mergeIngresses(): expected hostname www.example.com, actual %q", hostnames)
+	}
+
+	// Ingresses can't serve the same path of a host with different backends.
+	conflicting := groupedIngress("default", "www", "public", "www.example.com", "/", "www")
+	if _, err := mergeIngresses("default", "public", []*extensions.Ingress{web, conflicting}); err == nil ||
+		!strings.Contains(err.Error(), "web and www") {
+		t.Errorf("mergeIngresses(): expected an error naming web and www for path / of www.example.com, actual %v", err)
+	}
+	same := groupedIngress("default", "www", "public", "www.example.com", "/", "web")
+	if _, err := mergeIngresses("default", "public", []*extensions.Ingress{web, same}); err != nil {
+		t.Errorf("mergeIngresses(): expected the same backend to be accepted, actual error %v", err)
+	}
+}
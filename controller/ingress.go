@@ -2,11 +2,16 @@ package controller
 
 import (
 	"fmt"
+	"net"
+	"reflect"
 	"sort"
+	"strings"
 	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/coreos/alb-ingress-controller/awsutil"
 	"github.com/coreos/alb-ingress-controller/controller/alb"
 	"github.com/coreos/alb-ingress-controller/controller/config"
 	"github.com/coreos/alb-ingress-controller/controller/util"
@@ -16,6 +21,13 @@ import (
 	extensions "k8s.io/client-go/pkg/apis/extensions/v1beta1"
 )
 
+const (
+	// computeTypeLabel is set by EKS on nodes to describe the capacity backing them.
+	computeTypeLabel = "eks.amazonaws.com/compute-type"
+	// fargateComputeType is the computeTypeLabel value of Fargate nodes.
+	fargateComputeType = "fargate"
+)
+
 // ALBIngress contains all information above the cluster, ingress resource, and AWS resources
 // needed to assemble an ALB, TargetGroup, Listener, Rules, and Route53 Resource Records.
 type ALBIngress struct {
@@ -23,10 +35,22 @@ type ALBIngress struct {
 	namespace     *string
 	ingressName   *string
 	clusterName   *string
+	controllerID  *string
+	shardTags     util.Tags
 	lock          *sync.Mutex
 	annotations   *config.Annotations
 	LoadBalancers alb.LoadBalancers
 	tainted       bool // represents that parsing or validation this ingress resource failed
+	// ignoredAnnotations are the annotations left out because the controller doesn't allow them
+	ignoredAnnotations []string
+	// upgradedSSLPolicy is the ssl-policy annotation replaced by the minimum policy, if any
+	upgradedSSLPolicy string
+	// roleArn is the IAM role the AWS resources are managed as, empty for the controller's own
+	roleArn string
+	// costTags are the cost allocation tags of the AWS resources, see COST_ALLOCATION_TAGS
+	costTags util.Tags
+	// members are the names of the ingresses merged into the ALBIngress of a group, see GROUP_BY
+	members []string
 }
 
 // ALBIngressesT is a list of ALBIngress. It is held by the ALBController instance and evaluated
@@ -52,6 +76,15 @@ func NewALBIngress(namespace, name, clustername string) *ALBIngress {
 // If there is an issue and the ingress is invalid, nil is returned.
 func NewALBIngressFromIngress(ingress *extensions.Ingress, ac *ALBController) (*ALBIngress, error) {
 	var err error
+	// The ingresses of a group are merged into one sharing an ALB, see mergeIngresses. Events about
+	// it are recorded on its first ingress.
+	group, grouped := groupName(ingress.Name)
+	eventIngress := ingress
+	if grouped {
+		if member, ok := ac.ingressResource(ingress.Namespace, ingress.Name); ok {
+			eventIngress = member
+		}
+	}
 
 	// Create newIngress ALBIngress object holding the resource details and some cluster information.
 	newIngress := NewALBIngress(ingress.GetNamespace(), ingress.Name, *ac.clusterName)
@@ -67,9 +100,64 @@ func NewALBIngressFromIngress(ingress *extensions.Ingress, ac *ALBController) (*
 		// component will be generated later in this function.
 		newIngress.StripDesiredState()
 	}
+	newIngress.controllerID = aws.String(ac.controllerID)
+	newIngress.shardTags = ac.shardTags()
+
+	// The AWS resources of the ingress are managed as the IAM role of its namespace, if it has one.
+	// They can't move to another role, as it may well be in another account.
+	role, err := ac.namespaceRole(ingress.Namespace)
+	if err != nil {
+		log.Errorf("Error looking up the IAM role of namespace %s. Error: %s", "controller", ingress.Namespace, err.Error())
+		return newIngress, err
+	}
+	if role != newIngress.roleArn && newIngress.hasCurrentResources() {
+		err = fmt.Errorf("the %s annotation of namespace %s changed from %q to %q, the AWS resources of existing ingresses "+
+			"can't be moved to another role. Recreate the ingress to apply it", namespaceRoleKey, ingress.Namespace, newIngress.roleArn, role)
+		log.Errorf("Error looking up the IAM role of namespace %s. Error: %s", "controller", ingress.Namespace, err.Error())
+		return newIngress, err
+	}
+	newIngress.roleArn = role
+	defer awsutil.AssumeRole(role)()
+
+	if newIngress.costTags, err = ac.costAllocationTags(ingress); err != nil {
+		log.Errorf("Error looking up the cost allocation tags of namespace %s. Error: %s", "controller", ingress.Namespace, err.Error())
+		return newIngress, err
+	}
+
+	// Annotations the controller doesn't allow are dropped before anything reads them. They're
+	// reported whenever the set of ignored annotations changes.
+	allowed, ignored := config.FilterAnnotations(ingress.Annotations)
+	if len(ignored) > 0 && !reflect.DeepEqual(ignored, newIngress.ignoredAnnotations) {
+		log.Warnf("Ignoring annotations not allowed by the controller: %s", *newIngress.id, strings.Join(ignored, ", "))
+		ac.recorder.Eventf(eventIngress, api.EventTypeWarning, "AnnotationsIgnored",
+			"Annotations not allowed by the controller were ignored: %s", strings.Join(ignored, ", "))
+	}
+	newIngress.ignoredAnnotations = ignored
+	if len(ignored) > 0 {
+		filtered := *ingress
+		filtered.Annotations = allowed
+		ingress = &filtered
+	}
+
+	// The settings of the AlbConfig the ingress references, if any, replace its own annotations.
+	if ingress.Annotations[config.AlbConfigKey] != "" {
+		configured, err := ac.albConfigAnnotations(ingress)
+		if err != nil {
+			log.Errorf("Error applying the AlbConfig of ingress %v. Error: %s", "controller", newIngress.Name(), err.Error())
+			return newIngress, err
+		}
+		withConfig := *ingress
+		withConfig.Annotations = configured
+		ingress = &withConfig
+	}
 
 	// Load up the ingress with our current annotations.
-	newIngress.annotations, err = config.ParseAnnotations(ingress.Annotations)
+	annotations, err := ac.tlsAnnotations(ingress)
+	if err != nil {
+		log.Errorf("Error selecting the certificates of ingress %v. Error: %s", "controller", newIngress.Name(), err.Error())
+		return newIngress, err
+	}
+	newIngress.annotations, err = config.ParseAnnotations(annotations)
 	if err != nil {
 		log.Errorf("Error parsing annotations for ingress %v. Error: %s", "controller", newIngress.Name(), err.Error())
 		return newIngress, err
@@ -82,20 +170,73 @@ func NewALBIngressFromIngress(ingress *extensions.Ingress, ac *ALBController) (*
 		return newIngress, err
 	}
 
+	// An ssl-policy below the minimum policy is reported whenever it changes.
+	upgraded := aws.StringValue(newIngress.annotations.UpgradedSSLPolicy)
+	if upgraded != "" && upgraded != newIngress.upgradedSSLPolicy {
+		log.Warnf("SSL policy %s is below the minimum policy %s, using the minimum policy.", *newIngress.id,
+			upgraded, aws.StringValue(newIngress.annotations.SSLPolicy))
+		ac.recorder.Eventf(eventIngress, api.EventTypeWarning, "SSLPolicyUpgraded",
+			"SSL policy %s allows older TLS protocols than the minimum policy %s, which is used instead",
+			upgraded, aws.StringValue(newIngress.annotations.SSLPolicy))
+	}
+	newIngress.upgradedSSLPolicy = upgraded
+
+	// A name override can only name a single ALB, so it's limited to ingresses with a single rule,
+	// or groups.
+	if newIngress.annotations.LoadBalancerName != nil && len(ingress.Spec.Rules) > 1 && !grouped {
+		err = fmt.Errorf("load-balancer-name %s can't be used with %d ingress rules, as each rule gets its own ALB",
+			*newIngress.annotations.LoadBalancerName, len(ingress.Spec.Rules))
+		log.Errorf("Error parsing annotations for ingress %v. Error: %s", "controller", newIngress.Name(), err.Error())
+		return newIngress, err
+	}
+
+	// The extra hostnames point at the ALB of the ingress, so there must be only one.
+	if len(newIngress.annotations.Hostnames) > 0 && len(ingress.Spec.Rules) > 1 && !grouped {
+		err = fmt.Errorf("hostnames %s can't be used with %d ingress rules, as each rule gets its own ALB",
+			strings.Join(newIngress.annotations.Hostnames, ", "), len(ingress.Spec.Rules))
+		log.Errorf("Error parsing annotations for ingress %v. Error: %s", "controller", newIngress.Name(), err.Error())
+		return newIngress, err
+	}
+
 	// Create a new LoadBalancer instance for every item in ingress.Spec.Rules. This means that for
-	// each host specified (1 per ingress.Spec.Rule) a new load balancer is expected.
+	// each host specified (1 per ingress.Spec.Rule) a new load balancer is expected. The rules of a
+	// group all go to a single load balancer, without a hostname of its own, so it's kept when hosts
+	// come and go. The hosts of its rules are its extra hostnames instead, see mergeIngresses.
+	var assembled alb.LoadBalancers
+	groupForwards := 0
 	for _, rule := range ingress.Spec.Rules {
+		hostname := rule.Host
+		if grouped {
+			hostname = ""
+		}
 		// Start with a new LoadBalancer with a new DesiredState.
 		// TODO: RETURNING NIL SHOULD NOT BE AN OPTION HERE, otherwise memory access violations will
 		// occur.
-		lb := alb.NewLoadBalancer(*ac.clusterName, ingress.GetNamespace(), ingress.Name, rule.Host, newIngress.id, newIngress.annotations, newIngress.Tags())
+		lb := alb.NewLoadBalancer(*ac.clusterName, ingress.GetNamespace(), ingress.Name, hostname, newIngress.id, newIngress.annotations, newIngress.Tags())
+		// The shared security group is in the controller's own account, so ALBs managed as a
+		// namespace's role keep a security group of their own. So do ALBs with outbound rules, which
+		// would restrict the outbound traffic of every ALB sharing it.
+		if ac.sharedSecurityGroup != nil && newIngress.roleArn == "" && len(newIngress.annotations.OutboundRules) == 0 {
+			lb.ShareSecurityGroup()
+		}
 
 		// If this rule is for a previously defined loadbalancer, pull it out so we can work on it
 		if i := newIngress.LoadBalancers.Find(lb); i >= 0 {
 			// Save the Desired state to our old Loadbalancer.
 			newIngress.LoadBalancers[i].DesiredLoadBalancer = lb.DesiredLoadBalancer
 			newIngress.LoadBalancers[i].DesiredTags = lb.DesiredTags
+			newIngress.LoadBalancers[i].DesiredAttributes = lb.DesiredAttributes
+			newIngress.LoadBalancers[i].DesiredWebACLID = lb.DesiredWebACLID
 			newIngress.LoadBalancers[i].Hostname = lb.Hostname
+			newIngress.LoadBalancers[i].ExtraHostnames = lb.ExtraHostnames
+			newIngress.LoadBalancers[i].SharedPermissions = lb.SharedPermissions
+			// Save the Desired state to our old managed SecurityGroup, if there is one.
+			if sg := newIngress.LoadBalancers[i].SecurityGroup; sg != nil && lb.SecurityGroup != nil {
+				sg.DesiredSecurityGroup = lb.SecurityGroup.DesiredSecurityGroup
+				sg.DesiredTags = lb.SecurityGroup.DesiredTags
+			} else if lb.SecurityGroup != nil {
+				newIngress.LoadBalancers[i].SecurityGroup = lb.SecurityGroup
+			}
 			// Set lb to our old but updated LoadBalancer.
 			lb = newIngress.LoadBalancers[i]
 			// Remove the old LoadBalancer from the list.
@@ -105,41 +246,138 @@ func NewALBIngressFromIngress(ingress *extensions.Ingress, ac *ALBController) (*
 		// Create a new TargetGroup and Listener, associated with a LoadBalancer for every item in
 		// rule.HTTP.Paths. TargetGroups are constructed based on namespace, ingress name, and port.
 		// Listeners are constructed based on path and port.
-		for _, path := range rule.HTTP.Paths {
-			serviceKey := fmt.Sprintf("%s/%s", *newIngress.namespace, path.Backend.ServiceName)
-			port, err := ac.GetServiceNodePort(serviceKey, path.Backend.ServicePort.IntVal)
-			if err != nil {
-				glog.Infof("%s: %s", newIngress.Name(), err)
-				continue
+		// With host-header conditions, the rules only match requests for the hostnames of the ALB. The
+		// rules of a group only match requests for their host.
+		var hosts []string
+		switch {
+		case grouped && rule.Host != "":
+			hosts = []string{rule.Host}
+		case grouped:
+		case newIngress.annotations.HostHeaderConditions:
+			if *lb.Hostname != "" {
+				hosts = append(hosts, *lb.Hostname)
 			}
+			hosts = append(hosts, lb.ExtraHostnames...)
+			sort.Strings(hosts)
+		}
 
-			// Start with a new target group with a new Desired state.
-			targetGroup := alb.NewTargetGroup(newIngress.annotations, newIngress.Tags(), newIngress.clusterName, lb.ID, port, newIngress.id, path.Backend.ServiceName)
-			// If this rule/path matches an existing target group, pull it out so we can work on it.
-			if i := lb.TargetGroups.Find(targetGroup); i >= 0 {
-				// Save the Desired state to our old TargetGroup
-				lb.TargetGroups[i].DesiredTags = targetGroup.DesiredTags
-				lb.TargetGroups[i].DesiredTargetGroup = targetGroup.DesiredTargetGroup
-				// Set targetGroup to our old but updated TargetGroup.
-				targetGroup = lb.TargetGroups[i]
-				// Remove the old TG from our list.
-				lb.TargetGroups = append(lb.TargetGroups[:i], lb.TargetGroups[i+1:]...)
+		forwards := 0
+		for _, path := range rule.HTTP.Paths {
+			// The default action of the listeners of a group isn't for any host in particular, so / of
+			// a host is served by a rule of its own.
+			if grouped && rule.Host != "" && path.Path == "/" {
+				path.Path = "/*"
+			}
+			// Backends using an actions annotation redirect requests rather than forwarding them to a
+			// service, so they get no target group.
+			var redirect *config.RedirectConfig
+			if path.Backend.ServicePort.String() == config.UseAnnotation {
+				action, ok := newIngress.annotations.Actions[path.Backend.ServiceName]
+				if !ok {
+					err = fmt.Errorf("path %s uses the %s action, but the actions.%s annotation is missing",
+						path.Path, path.Backend.ServiceName, path.Backend.ServiceName)
+					log.Errorf("Error parsing annotations for ingress %v. Error: %s", "controller", newIngress.Name(), err.Error())
+					return newIngress, err
+				}
+				// The default path is served by the listener's default action, which must forward.
+				if path.Path == "/" {
+					err = fmt.Errorf("path / can't use the %s action, as / is served by the listener's default action. Use /* instead",
+						path.Backend.ServiceName)
+					log.Errorf("Error parsing annotations for ingress %v. Error: %s", "controller", newIngress.Name(), err.Error())
+					return newIngress, err
+				}
+				redirect = action.RedirectConfig
 			}
 
-			// Add desired targets set to the targetGroup.
-			targetGroup.DesiredTargets = GetNodes(ac)
-			lb.TargetGroups = append(lb.TargetGroups, targetGroup)
+			if redirect == nil {
+				forwards++
+				var serviceKey string
+				serviceKey, err = ac.backendServiceKey(*newIngress.namespace, newIngress.annotations, path.Backend.ServiceName)
+				if err != nil {
+					log.Errorf("Error resolving the service of path %s. Error: %s", newIngress.Name(), path.Path, err.Error())
+					continue
+				}
+
+				// In instance mode traffic reaches the service through its NodePort on every node. In ip
+				// mode it is sent directly to the service's endpoints.
+				var port *int64
+				var targets, staticTargets util.AWSStringSlice
+				switch *newIngress.annotations.TargetType {
+				case awsutil.TargetTypeIP:
+					port, targets, err = ac.GetServiceEndpoints(serviceKey, path.Backend.ServicePort)
+					if err == nil {
+						staticTargets, err = ac.GetServiceStaticTargets(serviceKey)
+						targets = append(targets, staticTargets...)
+						sort.Sort(targets)
+					}
+				default:
+					port, err = ac.GetServiceNodePort(serviceKey, path.Backend.ServicePort)
+					targets = GetNodes(ac)
+					if ac.hasFargateEndpoints(serviceKey) {
+						log.Warnf("Service %s has pods running on Fargate, which have no node port and can only be reached with target-type %s.",
+							newIngress.Name(), serviceKey, awsutil.TargetTypeIP)
+					}
+					if staticTargets, _ := ac.GetServiceStaticTargets(serviceKey); len(staticTargets) > 0 {
+						log.Warnf("Service %s has static targets, which are only registered with target-type %s.",
+							newIngress.Name(), serviceKey, awsutil.TargetTypeIP)
+					}
+				}
+				if err != nil {
+					glog.Infof("%s: %s", newIngress.Name(), err)
+					continue
+				}
+
+				// Start with a new target group with a new Desired state.
+				// Each (service, port) pair referenced by the ingress results in its own target group.
+				// Services can override the ingress's target group settings, see config.Annotations.ForBackend.
+				backendAnnotations := newIngress.annotations.ForBackend(path.Backend.ServiceName)
+				if *newIngress.annotations.TargetType == awsutil.TargetTypeIP {
+					targets = filterTargets(targets, *backendAnnotations.TargetIPAddressType)
+					if len(staticTargets) > 0 && *backendAnnotations.TargetIPAddressType == awsutil.IPAddressTypeIPv6 {
+						log.Warnf("Service %s has static targets, which are only registered in %s target groups.",
+							newIngress.Name(), serviceKey, awsutil.IPAddressTypeIPv4)
+					}
+					staticTargets = filterTargets(staticTargets, *backendAnnotations.TargetIPAddressType)
+				}
+				var healthcheckPort *string
+				healthcheckPort, err = ac.resolveHealthcheckPort(serviceKey, backendAnnotations)
+				if err != nil {
+					glog.Infof("%s: %s", newIngress.Name(), err)
+					continue
+				}
+
+				targetGroup := alb.NewTargetGroup(backendAnnotations, newIngress.Tags(), newIngress.clusterName, lb.ID, port, newIngress.id, path.Backend.ServiceName, path.Backend.ServicePort)
+				targetGroup.DesiredTargetGroup.HealthCheckPort = healthcheckPort
+				// If this rule/path matches an existing target group, pull it out so we can work on it.
+				if i := lb.TargetGroups.Find(targetGroup); i >= 0 {
+					// Save the Desired state to our old TargetGroup
+					lb.TargetGroups[i].SvcPort = targetGroup.SvcPort
+					lb.TargetGroups[i].ProtocolVersion = targetGroup.ProtocolVersion
+					lb.TargetGroups[i].IPAddressType = targetGroup.IPAddressType
+					lb.TargetGroups[i].DesiredTags = targetGroup.DesiredTags
+					lb.TargetGroups[i].DesiredTargetGroup = targetGroup.DesiredTargetGroup
+					lb.TargetGroups[i].DesiredAttributes = targetGroup.DesiredAttributes
+					lb.TargetGroups[i].DesiredAlarms = targetGroup.DesiredAlarms
+					// Set targetGroup to our old but updated TargetGroup.
+					targetGroup = lb.TargetGroups[i]
+					// Remove the old TG from our list.
+					lb.TargetGroups = append(lb.TargetGroups[:i], lb.TargetGroups[i+1:]...)
+				}
+
+				// Add desired targets set to the targetGroup.
+				targetGroup.DesiredTargets = targets
+				targetGroup.DesiredStaticTargets = staticTargets
+				lb.TargetGroups = append(lb.TargetGroups, targetGroup)
+			}
 
 			// Start with a new listener
 			listenerList := alb.NewListener(newIngress.annotations, newIngress.id)
 			for _, listener := range listenerList {
-				// If this listener matches an existing listener, pull it out so we can work on it.
-				// TODO: We should refine the lookup. Find is really not adequate as this could be a first
-				// statrt where no Listeners have CurrentListeners attached. In other words, find should be
-				// rewritten.
+				// If this listener is on the port of an existing listener, pull it out so we can work on it.
 				if i := lb.Listeners.Find(listener.DesiredListener); i >= 0 {
 					// Save the Desired state to our old Listener.
 					lb.Listeners[i].DesiredListener = listener.DesiredListener
+					lb.Listeners[i].DesiredSNICertificates = listener.DesiredSNICertificates
 					// Set listener to our old but updated Listener.
 					listener = lb.Listeners[i]
 					// Remove the old Listener from our list.
@@ -147,59 +385,456 @@ func NewALBIngressFromIngress(ingress *extensions.Ingress, ac *ALBController) (*
 				}
 				lb.Listeners = append(lb.Listeners, listener)
 
-				// Start with a new rule
-				rule := alb.NewRule(path, newIngress.id)
-				// If this rule matches an existing rule, pull it out so we can work on it
-				if i := listener.Rules.Find(rule.DesiredRule); i >= 0 {
-					// Save the Desired state to our old Rule
-					listener.Rules[i].DesiredRule = rule.DesiredRule
-					// Set rule to our old but updated Rule
-					rule = listener.Rules[i]
-					// Remove the old Rule from our list.
-					listener.Rules = append(listener.Rules[:i], listener.Rules[i+1:]...)
+				// Start with new rules, more than one when the hosts don't fit in a single rule
+				for _, rule := range alb.NewRules(path, newIngress.id, redirect, hosts) {
+					// If this rule matches an existing rule, pull it out so we can work on it
+					if i := listener.Rules.Find(rule.DesiredRule); i >= 0 {
+						// Save the Desired state to our old Rule
+						listener.Rules[i].DesiredRule = rule.DesiredRule
+						listener.Rules[i].DesiredRedirect = rule.DesiredRedirect
+						listener.Rules[i].SvcName = rule.SvcName
+						listener.Rules[i].SvcPort = rule.SvcPort
+						// Set rule to our old but updated Rule
+						rule = listener.Rules[i]
+						// Remove the old Rule from our list.
+						listener.Rules = append(listener.Rules[:i], listener.Rules[i+1:]...)
+					}
+					listener.Rules = append(listener.Rules, rule)
 				}
-				listener.Rules = append(listener.Rules, rule)
 			}
 
-			if !ac.disableRoute53 {
-				// Create a new ResourceRecordSet for the hostname.
-				resourceRecordSet := alb.NewResourceRecordSet(lb.Hostname, lb.IngressID)
+			// Ingresses opting out of DNS management leave records they were given before in place,
+			// they're forgotten rather than deleted.
+			lb.UnmanagedDNS = !newIngress.annotations.ManageDNS
+			if lb.UnmanagedDNS {
+				lb.ResourceRecordSet, lb.IPv6RecordSet, lb.ExtraRecordSets = nil, nil, nil
+			}
 
-				// If the load balancer has a CurrentResourceRecordSet, set
-				// this value inside our new resourceRecordSet.
-				if lb.ResourceRecordSet != nil {
-					resourceRecordSet.CurrentResourceRecordSet = lb.ResourceRecordSet.CurrentResourceRecordSet
+			if !ac.disableRoute53 && !lb.UnmanagedDNS {
+				// The ALB of a group has no hostname of its own, only extra hostnames.
+				if !grouped {
+					// Create a new ResourceRecordSet for the hostname.
+					resourceRecordSet := alb.NewResourceRecordSet(lb.Hostname, newIngress.annotations, lb.IngressID)
+
+					// If the load balancer has a CurrentResourceRecordSet, set
+					// this value inside our new resourceRecordSet.
+					if lb.ResourceRecordSet != nil {
+						resourceRecordSet.CurrentResourceRecordSet = lb.ResourceRecordSet.CurrentResourceRecordSet
+						resourceRecordSet.CurrentHealthCheck = lb.ResourceRecordSet.CurrentHealthCheck
+					}
+
+					// Assign the resourceRecordSet to the load balancer
+					lb.ResourceRecordSet = resourceRecordSet
+
+					// Dualstack ALBs get an AAAA alias record too. A CNAME already resolves for IPv6 clients,
+					// and the standby ALB is ipv4 only, so it couldn't take over an AAAA record. A previous
+					// AAAA record no longer desired was stripped above, so it's deleted.
+					if *newIngress.annotations.IPAddressType == elbv2.IpAddressTypeDualstack &&
+						*newIngress.annotations.Route53RecordType == route53.RRTypeA && newIngress.annotations.Standby == nil {
+						ipv6 := alb.NewIPv6ResourceRecordSet(lb.Hostname, newIngress.annotations, lb.IngressID)
+						if lb.IPv6RecordSet != nil {
+							ipv6.CurrentResourceRecordSet = lb.IPv6RecordSet.CurrentResourceRecordSet
+							ipv6.CurrentHealthCheck = lb.IPv6RecordSet.CurrentHealthCheck
+						}
+						lb.IPv6RecordSet = ipv6
+					}
 				}
 
-				// Assign the resourceRecordSet to the load balancer
-				lb.ResourceRecordSet = resourceRecordSet
+				// Each extra hostname gets a record like the one of the hostname. The records of
+				// hostnames no longer listed are deleted.
+				lb.ExtraRecordSets = alb.NewExtraRecordSets(lb, newIngress.annotations)
 			}
 
 		}
 
+		// A standby ALB mirrors the LoadBalancer in another region. It can't move between regions, as
+		// the existing one has to be deleted first.
+		if newIngress.annotations.Standby != nil {
+			standby := alb.NewStandby(lb, newIngress.annotations)
+			switch {
+			case lb.Standby == nil:
+				lb.Standby = standby
+			case lb.Standby.Region != standby.Region:
+				err = fmt.Errorf("standby-region can't change from %s to %s while the standby ALB %s exists. Remove the standby "+
+					"annotations to delete it first", lb.Standby.Region, standby.Region, *lb.ID)
+				log.Errorf("Error parsing annotations for ingress %v. Error: %s", "controller", newIngress.Name(), err.Error())
+				return newIngress, err
+			default:
+				lb.Standby.SetDesiredState(standby)
+			}
+		}
+
+		// So can't the Global Accelerator endpoint group move between the accelerator of the ALB and
+		// an existing listener.
+		if newIngress.annotations.GlobalAccelerator != nil {
+			accelerator := alb.NewAccelerator(lb, newIngress.annotations)
+			switch {
+			case lb.Accelerator == nil:
+				lb.Accelerator = accelerator
+			case !lb.Accelerator.SameEndpointGroup(accelerator):
+				err = fmt.Errorf("the Global Accelerator endpoint group of ALB %s can't change while the ALB is an endpoint. Remove the "+
+					"global-accelerator annotations to remove it first", *lb.ID)
+				log.Errorf("Error parsing annotations for ingress %v. Error: %s", "controller", newIngress.Name(), err.Error())
+				return newIngress, err
+			default:
+				lb.Accelerator.SetDesiredState(accelerator)
+			}
+		}
+
 		// Add the newly constructed LoadBalancer to the new ALBIngress's Loadbalancer list.
 		newIngress.LoadBalancers = append(newIngress.LoadBalancers, lb)
+		if assembled.Find(lb) < 0 {
+			assembled = append(assembled, lb)
+		}
+
+		// Listeners forward to a target group by default, so a host can't only redirect.
+		// The ALB of a group only needs one for all of its hosts.
+		groupForwards += forwards
+		if forwards == 0 && len(rule.HTTP.Paths) > 0 && !grouped {
+			err = fmt.Errorf("host %s needs at least one path forwarding to a service, as listeners forward to a target group by default", rule.Host)
+			log.Errorf("Error parsing annotations for ingress %v. Error: %s", "controller", newIngress.Name(), err.Error())
+			return newIngress, err
+		}
+	}
+	if grouped && groupForwards == 0 && len(assembled) > 0 {
+		err = fmt.Errorf("the ingresses of group %s need at least one path forwarding to a service, as listeners forward to a target group by default", group)
+		log.Errorf("Error parsing annotations for ingress %v. Error: %s", "controller", newIngress.Name(), err.Error())
+		return newIngress, err
+	}
+
+	// Load balancers are only complete once every rule of the ingress was added, as several rules
+	// may go to the same one.
+	for _, lb := range assembled {
+		// Target groups that have to be recreated, e.g. because their protocol changed, hand their
+		// traffic over to the replacement gradually.
+		lb.TargetGroups.RetireReplaced()
+
+		// An ALB that can't hold all of the rules would be left half configured, so the ingress isn't
+		// reconciled at all.
+		if err := lb.CheckQuotas(); err != nil {
+			log.Errorf("Ingress exceeds ALB quotas. Error: %s", *newIngress.id, err.Error())
+			return newIngress, err
+		}
 	}
 
 	return newIngress, nil
 }
 
-// Reconcile begins the state sync for all AWS resource satisfying this ALBIngress instance.
-func (a *ALBIngress) Reconcile(disableRoute53 bool) {
+// Reconcile begins the state sync for all AWS resource satisfying this ALBIngress instance. Its
+// hostnames are published by dns.
+func (a *ALBIngress) Reconcile(dns alb.DNSProvider) {
 	a.lock.Lock()
 	defer a.lock.Unlock()
 	// If the ingress resource failed to assemble, don't attempt reconcile
 	if a.tainted {
 		return
 	}
+
+	// Standby ALBs are reconciled in their region first, so they're deleted before the LoadBalancers
+	// holding them are. A LoadBalancer whose standby failed to reconcile isn't deleted, or the
+	// standby would be left behind. Neither is one before it was removed from its Global Accelerator
+	// endpoint group, and its accelerator deleted.
+	loadBalancers, held := alb.LoadBalancers{}, alb.LoadBalancers{}
+	for _, lb := range a.LoadBalancers {
+		if lb.Standby != nil {
+			restore := awsutil.AssumeRoleIn(a.roleArn, lb.Standby.Region)
+			err := lb.Standby.Reconcile()
+			restore()
+			switch {
+			case err != nil && lb.DesiredLoadBalancer == nil:
+				log.Errorf("Failed to reconcile standby ALB %s in %s, keeping the ALB. Error: %s", *a.id, *lb.ID, lb.Standby.Region, err.Error())
+				lb.LastError = err
+				awsutil.ReconcileErrors.Inc()
+				held = append(held, lb)
+				continue
+			case err != nil:
+				log.Errorf("Failed to reconcile standby ALB %s in %s. Error: %s", *a.id, *lb.ID, lb.Standby.Region, err.Error())
+			case lb.Standby.Deleted:
+				lb.Standby = nil
+			}
+		}
+		if lb.Accelerator != nil && lb.DesiredLoadBalancer == nil {
+			restore := awsutil.AssumeRole(a.roleArn)
+			err := lb.Accelerator.Reconcile(lb)
+			restore()
+			if err != nil {
+				log.Errorf("Failed to remove ELBV2 (ALB) %s from its Global Accelerator endpoint group, keeping the ALB. Error: %s", *a.id,
+					*lb.ID, awsutil.DescribeError(err))
+				lb.LastError = err
+				awsutil.ReconcileErrors.Inc()
+			}
+			if !lb.Accelerator.Deleted {
+				held = append(held, lb)
+				continue
+			}
+			lb.Accelerator = nil
+		}
+		loadBalancers = append(loadBalancers, lb)
+	}
+
+	defer awsutil.AssumeRole(a.roleArn)()
 	errLBs := alb.LoadBalancers{}
+	for _, lb := range loadBalancers {
+		lb.IngressLock = a.lock
+	}
 
-	a.LoadBalancers, errLBs = a.LoadBalancers.Reconcile(disableRoute53)
+	a.LoadBalancers, errLBs = loadBalancers.Reconcile(dns)
+	a.LoadBalancers = append(a.LoadBalancers, held...)
 	for _, errLB := range errLBs {
-		log.Errorf("Failed to reconcile state on this ingress resource. Error: %s", *errLB.IngressID, errLB.LastError)
+		awsutil.ReconcileErrors.Inc()
+		log.Errorf("Failed to reconcile state on this ingress resource. Error: %s", *errLB.IngressID, awsutil.DescribeError(errLB.LastError))
+	}
+
+	// ALBs are added to their Global Accelerator endpoint group once they exist.
+	for _, lb := range a.LoadBalancers {
+		if lb.Accelerator == nil || lb.DesiredLoadBalancer == nil {
+			continue
+		}
+		if err := lb.Accelerator.Reconcile(lb); err != nil {
+			lb.LastError = err
+			awsutil.ReconcileErrors.Inc()
+			continue
+		}
+		if lb.Accelerator.Deleted {
+			lb.Accelerator = nil
+		}
+	}
+
+	// The failover records of the standby ALBs are written once the records of their LoadBalancers
+	// were converted to primary failover records. Route 53 is global, so this doesn't need the
+	// services of the standby region.
+	for _, lb := range a.LoadBalancers {
+		if lb.Standby == nil || lb.DesiredLoadBalancer == nil {
+			continue
+		}
+		if err := lb.Standby.ReconcileResourceRecordSet(lb.ResourceRecordSet); err != nil {
+			log.Errorf("Failed to write the failover record of standby ALB %s. Error: %s", *a.id, *lb.ID, err.Error())
+		}
 	}
 }
 
+// UpdateTargetHealth polls the target health of every target group belonging to this ALBIngress.
+// The targets that turned unhealthy, the IDs of the newly registered targets that weren't healthy
+// within the warmup timeout, and the number of health changes of the flapping target groups, are
+// returned keyed by target group ID.
+func (a *ALBIngress) UpdateTargetHealth() (map[string][]*elbv2.TargetHealthDescription, map[string][]string, map[string]int) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	defer awsutil.AssumeRole(a.roleArn)()
+
+	unhealthy := make(map[string][]*elbv2.TargetHealthDescription)
+	timedOut := make(map[string][]string)
+	flapping := make(map[string]int)
+	for _, lb := range a.LoadBalancers {
+		for _, tg := range lb.TargetGroups {
+			descriptions, err := tg.UpdateTargetHealth()
+			if err != nil {
+				log.Errorf("Failed to describe target health of TargetGroup %s. Error: %s", *a.id, *tg.ID, err.Error())
+				continue
+			}
+			if len(descriptions) > 0 {
+				unhealthy[*tg.ID] = descriptions
+			}
+			if ids := tg.UpdateWarmupStatus(); len(ids) > 0 {
+				timedOut[*tg.ID] = ids
+			}
+			if flaps := tg.UpdateFlapping(); flaps > 0 {
+				flapping[*tg.ID] = flaps
+			}
+		}
+	}
+	return unhealthy, timedOut, flapping
+}
+
+// IPTargetHealth returns the health state last polled of the targets of every ip mode target group
+// belonging to this ALBIngress, keyed by target group ID and IP address.
+func (a *ALBIngress) IPTargetHealth() map[string]map[string]string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	health := make(map[string]map[string]string)
+	for _, lb := range a.LoadBalancers {
+		for _, tg := range lb.TargetGroups {
+			if aws.StringValue(tg.TargetType) != awsutil.TargetTypeIP || len(tg.TargetHealth) == 0 {
+				continue
+			}
+			states := make(map[string]string)
+			for id, state := range tg.TargetHealth {
+				states[id] = state
+			}
+			health[*tg.ID] = states
+		}
+	}
+	return health
+}
+
+// AddTarget registers the instance id with the target groups of this ALBIngress right away, rather
+// than at its next reconcile.
+func (a *ALBIngress) AddTarget(id string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	defer awsutil.AssumeRole(a.roleArn)()
+
+	for _, lb := range a.LoadBalancers {
+		for _, tg := range lb.TargetGroups {
+			if _, err := tg.AddTarget(aws.String(id)); err != nil {
+				log.Errorf("Failed to register instance %s with TargetGroup %s. Error: %s", *a.id, id, *tg.ID, err.Error())
+			}
+		}
+	}
+}
+
+// RemoveTarget deregisters the instance id from the target groups of this ALBIngress right away,
+// rather than at its next reconcile. The target groups it was deregistered from are returned.
+func (a *ALBIngress) RemoveTarget(id string) []targetDrain {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	defer awsutil.AssumeRole(a.roleArn)()
+
+	var drains []targetDrain
+	for _, lb := range a.LoadBalancers {
+		for _, tg := range lb.TargetGroups {
+			removed, err := tg.RemoveTarget(aws.String(id))
+			if err != nil {
+				log.Errorf("Failed to deregister instance %s from TargetGroup %s. Error: %s", *a.id, id, *tg.ID, err.Error())
+				continue
+			}
+			if removed {
+				drains = append(drains, targetDrain{roleArn: a.roleArn, targetGroupArn: tg.CurrentTargetGroup.TargetGroupArn})
+			}
+		}
+	}
+	return drains
+}
+
+// RecordStates checks the Route 53 records belonging to this ALBIngress. It returns the number of
+// records the ALBIngress owns, and how many of them are present in Route 53 and resolving.
+func (a *ALBIngress) RecordStates() (owned, present, resolving int) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	defer awsutil.AssumeRole(a.roleArn)()
+
+	for _, lb := range a.LoadBalancers {
+		for _, record := range lb.RecordSets() {
+			if record.CurrentResourceRecordSet == nil {
+				continue
+			}
+			owned++
+			p, r := record.CheckState()
+			if p {
+				present++
+			}
+			if r {
+				resolving++
+			}
+		}
+	}
+	return owned, present, resolving
+}
+
+// DetectDrift refreshes the current state of every LoadBalancer belonging to this ALBIngress from
+// AWS. The out of band changes found are returned, keyed by LoadBalancer ID.
+func (a *ALBIngress) DetectDrift() map[string][]string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	defer awsutil.AssumeRole(a.roleArn)()
+
+	drifts := make(map[string][]string)
+	if a.tainted {
+		return drifts
+	}
+	for _, lb := range a.LoadBalancers {
+		d, err := lb.DetectDrift()
+		if err != nil {
+			log.Errorf("Failed to detect drift of ELBV2 (ALB) %s. Error: %s", *a.id, *lb.ID, err.Error())
+		}
+		if len(d) > 0 {
+			drifts[*lb.ID] = d
+		}
+	}
+	return drifts
+}
+
+// hasCurrentResources reports whether any of the AWS resources of this ALBIngress exist.
+func (a *ALBIngress) hasCurrentResources() bool {
+	for _, lb := range a.LoadBalancers {
+		if lb.CurrentLoadBalancer != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// CertificateArns returns the ARNs of the certificates the listeners of this ALBIngress use.
+func (a *ALBIngress) CertificateArns() []string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	seen := make(map[string]bool)
+	var arns []string
+	for _, lb := range a.LoadBalancers {
+		for _, l := range lb.Listeners {
+			if l.DesiredListener == nil {
+				continue
+			}
+			for _, certificate := range append(l.DesiredListener.Certificates, l.DesiredSNICertificates...) {
+				arn := aws.StringValue(certificate.CertificateArn)
+				if arn == "" || seen[arn] {
+					continue
+				}
+				seen[arn] = true
+				arns = append(arns, arn)
+			}
+		}
+	}
+	sort.Strings(arns)
+	return arns
+}
+
+// OwnsResource reports whether id is the ARN of an ALB, listener, rule or target group of this
+// ALBIngress, or the ID of a security group it manages.
+func (a *ALBIngress) OwnsResource(id string) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for _, lb := range a.LoadBalancers {
+		if lb.CurrentLoadBalancer != nil {
+			lbArn := *lb.CurrentLoadBalancer.LoadBalancerArn
+			if id == lbArn {
+				return true
+			}
+			// Listener and rule ARNs extend the ARN of their ALB, e.g.
+			// arn:aws:elasticloadbalancing:us-east-1:123456789012:listener/app/name/50dc6c495c0c9188/f2f7dc8efc522ab2
+			for _, resource := range []string{":listener/", ":listener-rule/"} {
+				if strings.HasPrefix(id, strings.Replace(lbArn, ":loadbalancer/", resource, 1)+"/") {
+					return true
+				}
+			}
+		}
+		for _, tg := range lb.TargetGroups {
+			if tg.CurrentTargetGroup != nil && id == *tg.CurrentTargetGroup.TargetGroupArn {
+				return true
+			}
+		}
+		if sg := lb.SecurityGroup; sg != nil && sg.CurrentSecurityGroup != nil && id == aws.StringValue(sg.CurrentSecurityGroup.GroupId) {
+			return true
+		}
+	}
+	return false
+}
+
+// ShiftingTraffic reports whether traffic shifts from a target group of the ALBIngress that is
+// being replaced.
+func (a *ALBIngress) ShiftingTraffic() bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for _, lb := range a.LoadBalancers {
+		if lb.TargetGroups.ShiftingTraffic() {
+			return true
+		}
+	}
+	return false
+}
+
 // Name returns the name of the ingress
 func (a *ALBIngress) Name() string {
 	return fmt.Sprintf("%s-%s", *a.namespace, *a.ingressName)
@@ -217,10 +852,32 @@ func (a *ALBIngress) StripDesiredState() {
 	}
 }
 
+// hasMember reports whether the ingress name was merged into the ALBIngress of a group when it was
+// last assembled.
+func (a *ALBIngress) hasMember(name string) bool {
+	for _, member := range a.members {
+		if member == name {
+			return true
+		}
+	}
+	return false
+}
+
 // Tags returns an elbv2.Tag slice of standard tags for the ingress AWS resources
 func (a *ALBIngress) Tags() []*elbv2.Tag {
 	tags := a.annotations.Tags
 
+	// The tags annotation takes precedence over cost allocation tags.
+next:
+	for _, t := range a.costTags {
+		for _, annotated := range a.annotations.Tags {
+			if *annotated.Key == *t.Key {
+				continue next
+			}
+		}
+		tags = append(tags, t)
+	}
+
 	tags = append(tags, &elbv2.Tag{
 		Key:   aws.String("Namespace"),
 		Value: a.namespace,
@@ -231,9 +888,20 @@ func (a *ALBIngress) Tags() []*elbv2.Tag {
 		Value: a.ingressName,
 	})
 
+	tags = append(tags, ownershipTags(*a.clusterName, *a.controllerID)...)
+	tags = append(tags, a.shardTags...)
+
 	return tags
 }
 
+// ownershipTags returns the tags identifying the cluster and controller owning an AWS resource.
+func ownershipTags(clusterName, controllerID string) util.Tags {
+	return util.Tags{
+		{Key: aws.String(util.ClusterNameTag), Value: aws.String(clusterName)},
+		{Key: aws.String(util.ControllerIDTag), Value: aws.String(controllerID)},
+	}
+}
+
 func (a ALBIngressesT) find(b *ALBIngress) int {
 	for p, v := range a {
 		if *v.id == *b.id {
@@ -243,13 +911,37 @@ func (a ALBIngressesT) find(b *ALBIngress) int {
 	return -1
 }
 
-// GetNodes returns a list of the cluster node external ids
+// GetNodes returns a list of the cluster node external ids. Fargate nodes are skipped as they are
+// not EC2 instances and can't be registered as instance targets.
 func GetNodes(ac *ALBController) util.AWSStringSlice {
 	var result util.AWSStringSlice
 	nodes := ac.storeLister.Node.List()
 	for _, node := range nodes {
+		if isFargateNode(node.(*api.Node)) || ac.instanceUnavailable(node.(*api.Node).Spec.ExternalID) {
+			continue
+		}
 		result = append(result, aws.String(node.(*api.Node).Spec.ExternalID))
 	}
 	sort.Sort(result)
 	return result
 }
+
+// isFargateNode returns true when the node is a virtual node backing an EKS Fargate pod.
+func isFargateNode(node *api.Node) bool {
+	return node.Labels[computeTypeLabel] == fargateComputeType
+}
+
+// filterTargets returns the IP targets of the family ipAddressType, in the canonical form AWS
+// reports them in, so they compare equal to the registered targets. Pods of dualstack clusters have
+// an address of each family, and a target group only accepts one of them.
+func filterTargets(targets util.AWSStringSlice, ipAddressType string) util.AWSStringSlice {
+	var out util.AWSStringSlice
+	for _, target := range targets {
+		ip := net.ParseIP(*target)
+		if ip == nil || (ip.To4() == nil) != (ipAddressType == awsutil.IPAddressTypeIPv6) {
+			continue
+		}
+		out = append(out, aws.String(ip.String()))
+	}
+	return out
+}
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/coreos/alb-ingress-controller/awsutil/fake"
+	"github.com/coreos/alb-ingress-controller/controller/alb"
+	"github.com/coreos/alb-ingress-controller/controller/config"
+	"github.com/coreos/alb-ingress-controller/controller/util"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	extensions "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+func TestReconcile(t *testing.T) {
+	clients := fake.New()
+	defer clients.Install()()
+	clients.EC2.AddSubnet("subnet-1", "vpc-1", "us-east-1a", nil)
+	clients.EC2.AddSubnet("subnet-2", "vpc-1", "us-east-1b", nil)
+	zoneID := clients.Route53.AddHostedZone("example.com")
+
+	annotations, err := config.ParseAnnotations(map[string]string{
+		"alb.ingress.kubernetes.io/scheme":  "internet-facing",
+		"alb.ingress.kubernetes.io/subnets": "subnet-1,subnet-2",
+	})
+	if err != nil {
+		t.Fatalf("ParseAnnotations() returned error %v", err)
+	}
+	a := NewALBIngress("default", "app", "cluster")
+	a.controllerID = aws.String("alb")
+	a.annotations = annotations
+	lb := alb.NewLoadBalancer("cluster", "default", "app", "app.example.com", a.id, annotations, a.Tags())
+	if lb.SecurityGroup == nil {
+		t.Fatalf("NewLoadBalancer(): expected a managed security group")
+	}
+	tg := alb.NewTargetGroup(annotations, a.Tags(), a.clusterName, lb.ID, aws.Int64(30080), a.id, "app", intstr.FromInt(80))
+	tg.DesiredTargets = util.AWSStringSlice{aws.String("i-1"), aws.String("i-2")}
+	lb.TargetGroups = alb.TargetGroups{tg}
+	path := extensions.HTTPIngressPath{
+		Path:    "/api",
+		Backend: extensions.IngressBackend{ServiceName: "app", ServicePort: intstr.FromInt(80)},
+	}
+	for _, listener := range alb.NewListener(annotations, a.id) {
+		listener.Rules = alb.Rules{alb.NewRule(path, a.id, nil, nil)}
+		lb.Listeners = append(lb.Listeners, listener)
+	}
+	lb.ResourceRecordSet = alb.NewResourceRecordSet(lb.Hostname, annotations, lb.IngressID)
+	a.LoadBalancers = alb.LoadBalancers{lb}
+
+	dns, err := alb.LookupDNSProvider(alb.DNSProviderRoute53)
+	if err != nil {
+		t.Fatalf("LookupDNSProvider() returned error %v", err)
+	}
+	a.Reconcile(dns)
+	if lb.LastError != nil {
+		t.Fatalf("Reconcile(): ALB failed with %v", lb.LastError)
+	}
+
+	loadBalancers, err := clients.ELBV2.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{})
+	if err != nil || len(loadBalancers.LoadBalancers) != 1 || *loadBalancers.LoadBalancers[0].LoadBalancerName != *lb.ID {
+		t.Fatalf("Reconcile(): expected ALB %s to be created, actual %v, %v", *lb.ID, loadBalancers, err)
+	}
+	arn := loadBalancers.LoadBalancers[0].LoadBalancerArn
+	sgs := loadBalancers.LoadBalancers[0].SecurityGroups
+	if len(sgs) != 1 {
+		t.Fatalf("Reconcile(): expected the ALB to use its managed security group, actual %v", aws.StringValueSlice(sgs))
+	}
+	groups, err := clients.EC2.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{GroupIds: sgs})
+	if err != nil || len(groups.SecurityGroups) != 1 || len(groups.SecurityGroups[0].IpPermissions) != 1 ||
+		*groups.SecurityGroups[0].IpPermissions[0].FromPort != 80 {
+		t.Errorf("Reconcile(): expected the security group to allow port 80, actual %v, %v", groups, err)
+	}
+
+	targetGroups, err := clients.ELBV2.DescribeTargetGroups(&elbv2.DescribeTargetGroupsInput{LoadBalancerArn: arn})
+	if err != nil || len(targetGroups.TargetGroups) != 1 || *targetGroups.TargetGroups[0].Port != 30080 {
+		t.Fatalf("Reconcile(): expected a target group on port 30080, actual %v, %v", targetGroups, err)
+	}
+	health, err := clients.ELBV2.DescribeTargetHealth(&elbv2.DescribeTargetHealthInput{
+		TargetGroupArn: targetGroups.TargetGroups[0].TargetGroupArn,
+	})
+	if err != nil || len(health.TargetHealthDescriptions) != 2 {
+		t.Errorf("Reconcile(): expected i-1 and i-2 to be registered, actual %v, %v", health, err)
+	}
+
+	listeners, err := clients.ELBV2.DescribeListeners(&elbv2.DescribeListenersInput{LoadBalancerArn: arn})
+	if err != nil || len(listeners.Listeners) != 1 || *listeners.Listeners[0].Port != 80 {
+		t.Fatalf("Reconcile(): expected a listener on port 80, actual %v, %v", listeners, err)
+	}
+	rules, err := clients.ELBV2.DescribeRules(&elbv2.DescribeRulesInput{ListenerArn: listeners.Listeners[0].ListenerArn})
+	if err != nil {
+		t.Fatalf("DescribeRules() returned error %v", err)
+	}
+	found := false
+	for _, rule := range rules.Rules {
+		for _, condition := range rule.Conditions {
+			if *condition.Field == "path-pattern" && *condition.Values[0] == "/api" {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Reconcile(): expected a rule for /api, actual %v", rules)
+	}
+
+	records := clients.Route53.Records(zoneID)
+	if len(records) != 1 || *records[0].Name != "app.example.com." || *records[0].Type != route53.RRTypeA ||
+		records[0].AliasTarget == nil || *records[0].AliasTarget.DNSName != *loadBalancers.LoadBalancers[0].DNSName+"." {
+		t.Errorf("Reconcile(): expected an alias record of app.example.com pointing at the ALB, actual %v", records)
+	}
+
+	// Once the ingress is deleted, its resources are.
+	a.StripDesiredState()
+	a.Reconcile(dns)
+	if loadBalancers, _ := clients.ELBV2.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{}); len(loadBalancers.LoadBalancers) != 0 {
+		t.Errorf("Reconcile(): expected the ALB to be deleted, actual %v", loadBalancers.LoadBalancers)
+	}
+	if targetGroups, _ := clients.ELBV2.DescribeTargetGroups(&elbv2.DescribeTargetGroupsInput{}); len(targetGroups.TargetGroups) != 0 {
+		t.Errorf("Reconcile(): expected the target group to be deleted, actual %v", targetGroups.TargetGroups)
+	}
+	if groups, err := clients.EC2.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{GroupIds: sgs}); err == nil {
+		t.Errorf("Reconcile(): expected the security group to be deleted, actual %v", groups.SecurityGroups)
+	}
+	if records := clients.Route53.Records(zoneID); len(records) != 0 {
+		t.Errorf("Reconcile(): expected the record to be deleted, actual %v", records)
+	}
+	if len(a.LoadBalancers) != 0 {
+		t.Errorf("Reconcile(): expected no LoadBalancers to be left, actual %d", len(a.LoadBalancers))
+	}
+}
@@ -0,0 +1,266 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/log"
+	api "k8s.io/client-go/pkg/api/v1"
+)
+
+// instancePollInterval is how often the target groups an instance was deregistered from are polled
+// while it drains, and the nodes of the cluster while an instance joins it.
+const instancePollInterval = 5 * time.Second
+
+// instanceJoinTimeout is how long instances that started running are waited on to join the cluster.
+const instanceJoinTimeout = 10 * time.Minute
+
+// unavailableGrace is how long instances stay unavailable once drained, until their nodes are
+// removed from the cluster.
+const unavailableGrace = time.Hour
+
+// targetDrain is a target group an instance was deregistered from, with the IAM role of the
+// ALBIngress it belongs to.
+type targetDrain struct {
+	roleArn        string
+	targetGroupArn *string
+}
+
+// registered reports whether the instance id is still registered with, or draining from, the target
+// group.
+func (d targetDrain) registered(id string) (bool, error) {
+	defer awsutil.AssumeRole(d.roleArn)()
+	descriptions, err := awsutil.ALBsvc.DescribeTargetGroupHealth(d.targetGroupArn)
+	if err != nil {
+		return false, err
+	}
+	for _, description := range descriptions {
+		if description.Target != nil && aws.StringValue(description.Target.Id) == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// instanceUnavailable reports whether the instance id is left out of the targets.
+func (ac *ALBController) instanceUnavailable(id string) bool {
+	ac.instanceMutex.Lock()
+	defer ac.instanceMutex.Unlock()
+	until, ok := ac.unavailableInstances[id]
+	if ok && !unavailable(until) {
+		delete(ac.unavailableInstances, id)
+		return false
+	}
+	return ok
+}
+
+// unavailable reports whether an instance left out of the targets until the time until still is.
+func unavailable(until time.Time) bool {
+	return until.IsZero() || time.Now().Before(until)
+}
+
+// markUnavailable leaves the instance id out of the targets until the time until, or until it's
+// running again when until is the zero time. It returns false when the instance was unavailable
+// already.
+func (ac *ALBController) markUnavailable(id string, until time.Time) bool {
+	ac.instanceMutex.Lock()
+	defer ac.instanceMutex.Unlock()
+	if previous, ok := ac.unavailableInstances[id]; ok && unavailable(previous) {
+		return false
+	}
+	if ac.unavailableInstances == nil {
+		ac.unavailableInstances = make(map[string]time.Time)
+	}
+	ac.unavailableInstances[id] = until
+	return true
+}
+
+// receiveLifecycleEvents receives the lifecycle actions of the LIFECYCLE_EVENTS_QUEUE_URL queue,
+// and drains the instances Auto Scaling is about to terminate from every target group before
+// completing their action.
+func (ac *ALBController) receiveLifecycleEvents() {
+	if ac.stopping() {
+		return
+	}
+	messages, err := awsutil.SQSsvc.ReceiveMessages(ac.lifecycleEventsQueueURL)
+	if err != nil {
+		log.Errorf("Failed to receive lifecycle events from %s. Error: %s", "controller", ac.lifecycleEventsQueueURL, err.Error())
+		return
+	}
+	for _, message := range messages {
+		ac.handleLifecycleEvent(aws.StringValue(message.Body))
+		// A redelivered action would only drain the instance again. Should the controller restart
+		// mid-drain the hook times out instead, taking its default result.
+		if err := awsutil.SQSsvc.DeleteMessage(ac.lifecycleEventsQueueURL, message.ReceiptHandle); err != nil {
+			log.Errorf("Failed to delete a lifecycle event from %s. Error: %s", "controller", ac.lifecycleEventsQueueURL, err.Error())
+		}
+	}
+}
+
+// handleLifecycleEvent starts draining the instance of the lifecycle action the lifecycle event
+// body notifies of, unless it's draining already.
+func (ac *ALBController) handleLifecycleEvent(body string) {
+	action, err := awsutil.ParseTerminationAction(body)
+	if err != nil {
+		log.Warnf("Ignoring a lifecycle event that can't be read. Error: %s", "controller", err.Error())
+		return
+	}
+	if action == nil {
+		return
+	}
+	if !ac.markUnavailable(action.InstanceID, time.Now().Add(ac.lifecycleDrainTimeout+unavailableGrace)) {
+		return
+	}
+	go ac.drainInstance(action)
+}
+
+// drainInstance deregisters the instance of the lifecycle action from every target group, waits for
+// it to drain and completes the action, so Auto Scaling only terminates the instance once the ALBs
+// stopped sending it requests. Instances that are still draining once the drain timeout passed are
+// terminated anyway.
+func (ac *ALBController) drainInstance(action *awsutil.LifecycleAction) {
+	log.Infof("Draining instance %s before lifecycle hook %s of %s completes.", "controller",
+		action.InstanceID, action.HookName, action.GroupName)
+	drains := ac.removeTarget(action.InstanceID)
+
+	deadline := time.Now().Add(ac.lifecycleDrainTimeout)
+	for {
+		drains = draining(drains, action.InstanceID)
+		if len(drains) == 0 || !time.Now().Before(deadline) {
+			break
+		}
+		// The hook times out to its default result instead.
+		if ac.stopping() {
+			return
+		}
+		time.Sleep(instancePollInterval)
+	}
+	if len(drains) > 0 {
+		log.Warnf("Instance %s is still draining from %d target groups after %s, completing lifecycle hook %s anyway.", "controller",
+			action.InstanceID, len(drains), ac.lifecycleDrainTimeout, action.HookName)
+	}
+
+	if err := awsutil.AutoScalingsvc.CompleteLifecycleAction(action, awsutil.LifecycleActionResultContinue); err != nil {
+		log.Errorf("Failed to complete lifecycle hook %s of instance %s. Error: %s", "controller",
+			action.HookName, action.InstanceID, err.Error())
+		return
+	}
+	log.Infof("Completed lifecycle hook %s of instance %s.", "controller", action.HookName, action.InstanceID)
+}
+
+// removeTarget deregisters the instance id from the target groups of every ALBIngress, returning the
+// ones it was deregistered from.
+func (ac *ALBController) removeTarget(id string) []targetDrain {
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+
+	var drains []targetDrain
+	for _, ALBIngress := range ac.ALBIngresses {
+		drains = append(drains, ALBIngress.RemoveTarget(id)...)
+	}
+	return drains
+}
+
+// draining returns the drains the instance id is still registered with, or draining from. Target
+// groups that can't be described are kept.
+func draining(drains []targetDrain, id string) []targetDrain {
+	var pending []targetDrain
+	for _, d := range drains {
+		registered, err := d.registered(id)
+		if err != nil {
+			log.Errorf("Failed to describe target health of %s. Error: %s", "controller", *d.targetGroupArn, err.Error())
+		}
+		if err != nil || registered {
+			pending = append(pending, d)
+		}
+	}
+	return pending
+}
+
+// receiveInstanceEvents receives the EC2 instance state changes of the INSTANCE_EVENTS_QUEUE_URL
+// queue, and updates the targets of the instance mode target groups with them. Nodes stopping or
+// being terminated are so deregistered within seconds, and nodes joining the cluster registered,
+// rather than once the next sync notices them.
+func (ac *ALBController) receiveInstanceEvents() {
+	if ac.stopping() {
+		return
+	}
+	messages, err := awsutil.SQSsvc.ReceiveMessages(ac.instanceEventsQueueURL)
+	if err != nil {
+		log.Errorf("Failed to receive instance events from %s. Error: %s", "controller", ac.instanceEventsQueueURL, err.Error())
+		return
+	}
+	for _, message := range messages {
+		ac.handleInstanceEvent(aws.StringValue(message.Body))
+		if err := awsutil.SQSsvc.DeleteMessage(ac.instanceEventsQueueURL, message.ReceiptHandle); err != nil {
+			log.Errorf("Failed to delete an instance event from %s. Error: %s", "controller", ac.instanceEventsQueueURL, err.Error())
+		}
+	}
+}
+
+// handleInstanceEvent updates the targets with the state change the instance event body notifies
+// of. Instances shutting down or stopping are deregistered, those that started running registered
+// once they joined the cluster.
+func (ac *ALBController) handleInstanceEvent(body string) {
+	change, err := awsutil.ParseInstanceStateChange(body)
+	if err != nil {
+		log.Warnf("Ignoring an instance event that can't be read. Error: %s", "controller", err.Error())
+		return
+	}
+	if change == nil {
+		return
+	}
+
+	switch change.State {
+	case awsutil.InstanceStateShuttingDown, awsutil.InstanceStateTerminated:
+		ac.markUnavailable(change.InstanceID, time.Now().Add(unavailableGrace))
+		ac.removeTarget(change.InstanceID)
+	case awsutil.InstanceStateStopping, awsutil.InstanceStateStopped:
+		ac.markUnavailable(change.InstanceID, time.Time{})
+		ac.removeTarget(change.InstanceID)
+	case awsutil.InstanceStateRunning:
+		ac.markAvailable(change.InstanceID)
+		go ac.registerInstance(change.InstanceID)
+	}
+}
+
+// markAvailable stops leaving the instance id out of the targets, once it's running again.
+func (ac *ALBController) markAvailable(id string) {
+	ac.instanceMutex.Lock()
+	defer ac.instanceMutex.Unlock()
+	delete(ac.unavailableInstances, id)
+}
+
+// registerInstance registers the instance id, which started running, with the target groups of
+// every ALBIngress once it joined the cluster. Instances that don't join it within
+// instanceJoinTimeout aren't nodes and are left alone.
+func (ac *ALBController) registerInstance(id string) {
+	deadline := time.Now().Add(instanceJoinTimeout)
+	for !ac.isNode(id) {
+		if ac.stopping() || !time.Now().Before(deadline) {
+			return
+		}
+		time.Sleep(instancePollInterval)
+	}
+	// The instance may have stopped again in the meantime.
+	if ac.instanceUnavailable(id) {
+		return
+	}
+
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+	for _, ALBIngress := range ac.ALBIngresses {
+		ALBIngress.AddTarget(id)
+	}
+}
+
+// isNode reports whether the instance id is a node of the cluster, other than a Fargate one.
+func (ac *ALBController) isNode(id string) bool {
+	for _, node := range ac.storeLister.Node.List() {
+		if node.(*api.Node).Spec.ExternalID == id && !isFargateNode(node.(*api.Node)) {
+			return true
+		}
+	}
+	return false
+}
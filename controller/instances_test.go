@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/awsutil/fake"
+	"github.com/coreos/alb-ingress-controller/controller/alb"
+	"github.com/coreos/alb-ingress-controller/controller/util"
+	api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// newInstanceTargets returns an ALBController whose one ALBIngress has an instance mode target
+// group, created in the fake ELBV2 with the instances ids registered.
+func newInstanceTargets(t *testing.T, clients *fake.Clients, ids ...string) (*ALBController, *alb.TargetGroup) {
+	out, err := clients.ELBV2.CreateTargetGroup(&elbv2.CreateTargetGroupInput{
+		Name: aws.String("app"), Port: aws.Int64(30080), Protocol: aws.String("HTTP"), VpcId: aws.String("vpc-1"),
+	})
+	if err != nil {
+		t.Fatalf("CreateTargetGroup() returned error %v", err)
+	}
+	targets := util.AWSStringSlice(aws.StringSlice(ids))
+	tg := &alb.TargetGroup{
+		ID:                 aws.String("app"),
+		IngressID:          aws.String("default-app"),
+		TargetType:         aws.String(awsutil.TargetTypeInstance),
+		CurrentTargetGroup: out.TargetGroups[0],
+		CurrentTargets:     targets,
+		DesiredTargets:     targets,
+	}
+	in := elbv2.RegisterTargetsInput{TargetGroupArn: out.TargetGroups[0].TargetGroupArn}
+	for _, id := range targets {
+		in.Targets = append(in.Targets, &elbv2.TargetDescription{Id: id})
+	}
+	if err := awsutil.ALBsvc.RegisterTargets(in); err != nil {
+		t.Fatalf("RegisterTargets() returned error %v", err)
+	}
+	a := NewALBIngress("default", "app", "cluster")
+	a.LoadBalancers = alb.LoadBalancers{{ID: aws.String("app"), TargetGroups: alb.TargetGroups{tg}}}
+	return &ALBController{ALBIngresses: ALBIngressesT{a}}, tg
+}
+
+// registeredTargets returns the targets registered with tg in the fake ELBV2.
+func registeredTargets(t *testing.T, tg *alb.TargetGroup) []string {
+	targets, err := awsutil.ALBsvc.DescribeTargetGroupTargets(tg.CurrentTargetGroup.TargetGroupArn)
+	if err != nil {
+		t.Fatalf("DescribeTargetGroupTargets() returned error %v", err)
+	}
+	return aws.StringValueSlice(targets)
+}
+
+func TestDrainInstance(t *testing.T) {
+	clients := fake.New()
+	defer clients.Install()()
+	ac, tg := newInstanceTargets(t, clients, "i-1", "i-2")
+	ac.lifecycleDrainTimeout = time.Minute
+	ac.lifecycleEventsQueueURL = "lifecycle"
+
+	body := `{"LifecycleHookName": "drain", "AutoScalingGroupName": "nodes", "EC2InstanceId": "i-1",
+  "LifecycleActionToken": "token-1", "LifecycleTransition": "autoscaling:EC2_INSTANCE_TERMINATING"}`
+	action, err := awsutil.ParseTerminationAction(body)
+	if err != nil {
+		t.Fatalf("ParseTerminationAction() returned error %v", err)
+	}
+	if !ac.markUnavailable(action.InstanceID, time.Now().Add(time.Hour)) {
+		t.Fatalf("markUnavailable(i-1): expected i-1 to be available before")
+	}
+	ac.drainInstance(action)
+
+	if targets := registeredTargets(t, tg); len(targets) != 1 || targets[0] != "i-2" {
+		t.Errorf("drainInstance(): expected i-2 to be left registered, actual %v", targets)
+	}
+	if len(tg.DesiredTargets) != 1 || *tg.DesiredTargets[0] != "i-2" {
+		t.Errorf("drainInstance(): expected i-2 to be left desired, actual %v", aws.StringValueSlice(tg.DesiredTargets))
+	}
+	if result := clients.AutoScaling.Completed("i-1"); result != awsutil.LifecycleActionResultContinue {
+		t.Errorf("drainInstance(): expected the lifecycle action to be completed with CONTINUE, actual %q", result)
+	}
+	if !ac.instanceUnavailable("i-1") || ac.instanceUnavailable("i-2") {
+		t.Errorf("drainInstance(): expected only i-1 to be unavailable")
+	}
+
+	// Actions redelivered while the instance drains are deleted without draining it again.
+	clients.SQS.SendMessage("lifecycle", body)
+	ac.receiveLifecycleEvents()
+	if messages := clients.SQS.Messages("lifecycle"); len(messages) != 0 {
+		t.Errorf("receiveLifecycleEvents(): expected the event to be deleted, actual %v", messages)
+	}
+}
+
+func TestInstanceEvents(t *testing.T) {
+	clients := fake.New()
+	defer clients.Install()()
+	ac, tg := newInstanceTargets(t, clients, "i-1", "i-2")
+	ac.instanceEventsQueueURL = "instances"
+	ac.storeLister.Node.Store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, id := range []string{"i-1", "i-2", "i-3"} {
+		node := &api.Node{Spec: api.NodeSpec{ExternalID: id}}
+		node.Name = id
+		ac.storeLister.Node.Add(node)
+	}
+
+	clients.SQS.SendMessage("instances", `{"detail-type": "EC2 Instance State-change Notification",
+  "detail": {"instance-id": "i-1", "state": "stopping"}}`)
+	ac.receiveInstanceEvents()
+	if targets := registeredTargets(t, tg); len(targets) != 1 || targets[0] != "i-2" {
+		t.Errorf("receiveInstanceEvents(): expected the stopping i-1 to be deregistered, actual %v", targets)
+	}
+	if nodes := aws.StringValueSlice(GetNodes(ac)); len(nodes) != 2 || nodes[0] != "i-2" || nodes[1] != "i-3" {
+		t.Errorf("GetNodes(): expected the stopping i-1 to be left out, actual %v", nodes)
+	}
+	if messages := clients.SQS.Messages("instances"); len(messages) != 0 {
+		t.Errorf("receiveInstanceEvents(): expected the event to be deleted, actual %v", messages)
+	}
+
+	// Instances running again, or joining the cluster, are registered.
+	ac.markAvailable("i-1")
+	ac.registerInstance("i-1")
+	ac.registerInstance("i-3")
+	if targets := registeredTargets(t, tg); len(targets) != 3 {
+		t.Errorf("registerInstance(): expected i-1, i-2 and i-3 to be registered, actual %v", targets)
+	}
+	if len(tg.DesiredTargets) != 3 || len(tg.CurrentTargets) != 3 {
+		t.Errorf("registerInstance(): expected i-1, i-2 and i-3 to be the targets, actual %v, %v",
+			aws.StringValueSlice(tg.CurrentTargets), aws.StringValueSlice(tg.DesiredTargets))
+	}
+}
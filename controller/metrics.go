@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// cloudWatchMetricsInterval is how often, in seconds, the controller metrics are published to
+// CloudWatch.
+const cloudWatchMetricsInterval = 60
+
+// cloudWatchMetric is a controller metric published to CloudWatch.
+type cloudWatchMetric struct {
+	name   string // the name of the CloudWatch metric
+	metric prometheus.Metric
+	// counters are published as their increase since the previous publication, so they can be
+	// summed over any period
+	counter bool
+}
+
+// cloudWatchMetrics are the controller metrics published to CloudWatch when
+// CLOUDWATCH_METRICS_NAMESPACE is set.
+var cloudWatchMetrics = []cloudWatchMetric{
+	{"ManagedIngresses", awsutil.ManagedIngresses, false},
+	{"ReconcileErrors", awsutil.ReconcileErrors, true},
+	{"DriftEvents", awsutil.DriftEvents, true},
+}
+
+// publishMetrics publishes the cloudWatchMetrics under the cloudWatchMetricsNamespace, with the
+// cluster name as dimension. The increase of counters that failed to be published is published
+// along with the next one.
+func (ac *ALBController) publishMetrics() {
+	if ac.publishedCounts == nil {
+		ac.publishedCounts = make(map[string]float64)
+	}
+	dimensions := []*awsutil.MetricDimension{{Name: aws.String("ClusterName"), Value: ac.clusterName}}
+	var data []*awsutil.MetricDatum
+	counts := make(map[string]float64)
+	for _, m := range cloudWatchMetrics {
+		var metric dto.Metric
+		if err := m.metric.Write(&metric); err != nil {
+			log.Errorf("Failed to read metric %s. Error: %s", "controller", m.name, err.Error())
+			continue
+		}
+		value := metric.GetGauge().GetValue()
+		if m.counter {
+			counts[m.name] = metric.GetCounter().GetValue()
+			value = counts[m.name] - ac.publishedCounts[m.name]
+		}
+		data = append(data, &awsutil.MetricDatum{
+			MetricName: aws.String(m.name),
+			Dimensions: dimensions,
+			Unit:       aws.String("Count"),
+			Value:      aws.Float64(value),
+		})
+	}
+
+	if err := awsutil.CloudWatchsvc.PutMetricData(ac.cloudWatchMetricsNamespace, data); err != nil {
+		log.Errorf("Failed to publish the controller metrics to CloudWatch namespace %s. Error: %s", "controller",
+			ac.cloudWatchMetricsNamespace, err.Error())
+		return
+	}
+	for name, count := range counts {
+		ac.publishedCounts[name] = count
+	}
+}
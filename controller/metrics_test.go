@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/awsutil/fake"
+)
+
+func TestPublishMetrics(t *testing.T) {
+	clients := fake.New()
+	defer clients.Install()()
+
+	ac := &ALBController{clusterName: aws.String("prod"), cloudWatchMetricsNamespace: "ALBIngressController"}
+	awsutil.ManagedIngresses.Set(3)
+	ac.publishMetrics()
+	awsutil.ReconcileErrors.Inc()
+	awsutil.ReconcileErrors.Inc()
+	ac.publishMetrics()
+
+	data := clients.CloudWatch.Metrics("ALBIngressController")
+	if len(data) != 2*len(cloudWatchMetrics) {
+		t.Fatalf("publishMetrics(): expected %d values, actual %v", 2*len(cloudWatchMetrics), data)
+	}
+	// Counters are published as their increase since the previous publication.
+	expected := map[string]float64{"ManagedIngresses": 3, "ReconcileErrors": 2, "DriftEvents": 0}
+	for _, datum := range data[len(cloudWatchMetrics):] {
+		if *datum.Value != expected[*datum.MetricName] {
+			t.Errorf("publishMetrics(): expected %s %v, actual %v", *datum.MetricName, expected[*datum.MetricName], *datum.Value)
+		}
+		if len(datum.Dimensions) != 1 || *datum.Dimensions[0].Value != "prod" {
+			t.Errorf("publishMetrics(): expected the ClusterName dimension, actual %v", datum.Dimensions)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/coreos/alb-ingress-controller/log"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+	api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// namespaceResyncPeriod is how often the cached namespaces are relisted.
+const namespaceResyncPeriod = 10 * time.Minute
+
+// watchNamespaces caches the namespaces of the cluster, so the IAM roles and cost allocation
+// labels of namespaces are read without a request to the API server on every ingress sync. It
+// returns once the namespaces were listed.
+func (ac *ALBController) watchNamespaces() {
+	lw := cache.NewListWatchFromClient(ac.client.Core().RESTClient(), "namespaces", metav1.NamespaceAll, fields.Everything())
+	store, informer := cache.NewInformer(lw, &api.Namespace{}, namespaceResyncPeriod, cache.ResourceEventHandlerFuncs{})
+	go informer.Run(wait.NeverStop)
+	if !cache.WaitForCacheSync(wait.NeverStop, informer.HasSynced) {
+		log.Errorf("Failed to list the namespaces, they're looked up on every ingress sync.", "controller")
+		return
+	}
+	ac.namespaces = store
+}
+
+// namespace returns the namespace named name. It's read from the API server when it isn't cached
+// yet, e.g. right after it was created, so the role of a new namespace is never missed.
+func (ac *ALBController) namespace(name string) (*api.Namespace, error) {
+	if ac.namespaces != nil {
+		if item, exists, _ := ac.namespaces.GetByKey(name); exists {
+			return item.(*api.Namespace), nil
+		}
+	}
+	return ac.client.Core().Namespaces().Get(name, metav1.GetOptions{})
+}
+
+// listNamespaces returns every namespace, from the cache when the namespaces are watched.
+func (ac *ALBController) listNamespaces() ([]*api.Namespace, error) {
+	var namespaces []*api.Namespace
+	if ac.namespaces != nil {
+		for _, item := range ac.namespaces.List() {
+			namespaces = append(namespaces, item.(*api.Namespace))
+		}
+		return namespaces, nil
+	}
+	list, err := ac.client.Core().Namespaces().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range list.Items {
+		namespaces = append(namespaces, &list.Items[i])
+	}
+	return namespaces, nil
+}
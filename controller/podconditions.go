@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api "k8s.io/client-go/pkg/api/v1"
+)
+
+// podConditionPrefix prefixes the type of the pod conditions reporting the target health of pods,
+// followed by the ID of the target group.
+const podConditionPrefix = "target-health.alb.ingress.kubernetes.io/"
+
+// podRef names a pod an endpoint address belongs to.
+type podRef struct {
+	namespace string
+	name      string
+}
+
+// syncPodConditions sets a condition on each pod registered with an ip mode target group, reporting
+// its health in the target group as last polled. Only the conditions whose state changed since they
+// were last set are written. It's called with the controller's lock held.
+func (ac *ALBController) syncPodConditions() {
+	if ac.client == nil {
+		return
+	}
+	pods := ac.podsByIP()
+	written := make(map[string]string)
+	for _, ALBIngress := range ac.ALBIngresses {
+		for tgID, health := range ALBIngress.IPTargetHealth() {
+			for ip, state := range health {
+				pod, ok := pods[ip]
+				if !ok {
+					continue
+				}
+				key := fmt.Sprintf("%s/%s/%s", pod.namespace, pod.name, tgID)
+				if ac.podConditions[key] != state {
+					if err := ac.setPodCondition(pod, tgID, ip, state); err != nil {
+						log.Errorf("Failed to set the target health condition of pod %s/%s. Error: %s", *ALBIngress.id,
+							pod.namespace, pod.name, err.Error())
+						continue
+					}
+				}
+				written[key] = state
+			}
+		}
+	}
+	ac.podConditions = written
+}
+
+// podsByIP returns the pods backing the endpoints of every service, keyed by IP address.
+func (ac *ALBController) podsByIP() map[string]podRef {
+	pods := make(map[string]podRef)
+	for _, item := range ac.storeLister.Endpoint.List() {
+		endpoints := item.(*api.Endpoints)
+		for _, subset := range endpoints.Subsets {
+			for _, addresses := range [][]api.EndpointAddress{subset.Addresses, subset.NotReadyAddresses} {
+				for _, addr := range addresses {
+					if addr.TargetRef == nil || addr.TargetRef.Kind != "Pod" {
+						continue
+					}
+					pods[addr.IP] = podRef{namespace: addr.TargetRef.Namespace, name: addr.TargetRef.Name}
+				}
+			}
+		}
+	}
+	return pods
+}
+
+// setPodCondition sets the condition of pod reporting its health state in the target group tgID,
+// which it's registered with as ip.
+func (ac *ALBController) setPodCondition(pod podRef, tgID, ip, state string) error {
+	pods := ac.client.Core().Pods(pod.namespace)
+	current, err := pods.Get(pod.name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	condition := api.PodCondition{
+		Type:               api.PodConditionType(podConditionPrefix + tgID),
+		Status:             api.ConditionFalse,
+		LastProbeTime:      metav1.Now(),
+		LastTransitionTime: metav1.Now(),
+		Reason:             "Target" + strings.Title(state),
+		Message:            fmt.Sprintf("Target %s of target group %s is %s", ip, tgID, state),
+	}
+	if state == elbv2.TargetHealthStateEnumHealthy {
+		condition.Status = api.ConditionTrue
+	}
+
+	found := false
+	for i, c := range current.Status.Conditions {
+		if c.Type != condition.Type {
+			continue
+		}
+		if c.Status == condition.Status {
+			condition.LastTransitionTime = c.LastTransitionTime
+		}
+		current.Status.Conditions[i] = condition
+		found = true
+	}
+	if !found {
+		current.Status.Conditions = append(current.Status.Conditions, condition)
+	}
+	_, err = pods.UpdateStatus(current)
+	return err
+}
@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPodsByIP(t *testing.T) {
+	ac := &ALBController{}
+	ac.storeLister.Endpoint.Store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	endpoints := &api.Endpoints{Subsets: []api.EndpointSubset{{
+		Addresses: []api.EndpointAddress{
+			{IP: "10.0.0.1", TargetRef: &api.ObjectReference{Kind: "Pod", Namespace: "default", Name: "app-1"}},
+			// Addresses of other objects, or none, aren't pods.
+			{IP: "10.0.0.2", TargetRef: &api.ObjectReference{Kind: "Node", Name: "node-1"}},
+			{IP: "10.0.0.3"},
+		},
+		NotReadyAddresses: []api.EndpointAddress{
+			{IP: "10.0.0.4", TargetRef: &api.ObjectReference{Kind: "Pod", Namespace: "default", Name: "app-2"}},
+		},
+	}}}
+	endpoints.Namespace, endpoints.Name = "default", "app"
+	ac.storeLister.Endpoint.Add(endpoints)
+
+	expected := map[string]podRef{
+		"10.0.0.1": {namespace: "default", name: "app-1"},
+		"10.0.0.4": {namespace: "default", name: "app-2"},
+	}
+	if pods := ac.podsByIP(); !reflect.DeepEqual(pods, expected) {
+		t.Errorf("podsByIP(): expected %v, actual %v", expected, pods)
+	}
+}
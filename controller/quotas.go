@@ -0,0 +1,240 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/controller/alb"
+	"github.com/coreos/alb-ingress-controller/log"
+	"github.com/prometheus/client_golang/prometheus"
+	api "k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	// Name the inbound rules per security group quota is exported under
+	securityGroupRulesQuota = "inbound-rules-per-security-group"
+	// Default number of inbound rules a security group can have. Unlike the ELBV2 limits, it can't
+	// be looked up through the EC2 API.
+	defaultSecurityGroupRuleQuota = 60
+	// Share of the inbound rules quota from which a managed security group is reported as close to
+	// the limit
+	securityGroupRuleWarningRatio = 0.8
+)
+
+// updateRuleQuota applies the account's rules per ALB limit to the rule checks made when ingresses
+// are built, unless ALB_RULE_QUOTA overrides it.
+func (ac *ALBController) updateRuleQuota() {
+	if ac.ruleQuota != 0 {
+		return
+	}
+	defer awsutil.AssumeRole("")()
+	limits, err := awsutil.ALBsvc.DescribeAccountLimits()
+	if err != nil {
+		log.Warnf("Unable to describe ELBV2 account limits. Error: %s", "controller", err.Error())
+		return
+	}
+	alb.SetRuleQuota(int(limits[awsutil.LimitRulesPerLoadBalancer]))
+}
+
+// checkQuotas compares the resources every ALBIngress is about to create against the ELBV2 account
+// limits. The quotas each ALBIngress would exceed are returned, keyed by ALBIngress ID. Creations
+// are counted towards the region wide limits in the order of the ALBIngresses, so the ingresses that
+// still fit are reconciled.
+func (ac *ALBController) checkQuotas() map[string][]string {
+	defer awsutil.AssumeRole("")()
+	exceeded := make(map[string][]string)
+
+	limits, err := awsutil.ALBsvc.DescribeAccountLimits()
+	if err != nil {
+		log.Warnf("Unable to describe ELBV2 account limits, quotas aren't checked. Error: %s", "controller", err.Error())
+		return exceeded
+	}
+	for name, limit := range limits {
+		awsutil.AWSQuotaLimit.With(prometheus.Labels{"quota": name}).Set(float64(limit))
+	}
+	awsutil.AWSQuotaLimit.With(prometheus.Labels{"quota": securityGroupRulesQuota}).Set(float64(ac.sgRuleQuota))
+
+	// The region wide usage is only looked up when something is about to be created.
+	var newLoadBalancers, newTargetGroups int64
+	for _, ALBIngress := range ac.ALBIngresses {
+		// The limits are the ones of the controller's own account, which the resources managed as a
+		// namespace's IAM role may not count towards.
+		if ALBIngress.tainted || ALBIngress.roleArn != "" {
+			continue
+		}
+		for _, lb := range ALBIngress.LoadBalancers {
+			if lb.DesiredLoadBalancer != nil && lb.CurrentLoadBalancer == nil {
+				newLoadBalancers++
+			}
+			for _, tg := range lb.TargetGroups {
+				if tg.DesiredTargetGroup != nil && tg.CurrentTargetGroup == nil {
+					newTargetGroups++
+				}
+			}
+		}
+	}
+	var loadBalancers, targetGroups int64
+	if newLoadBalancers > 0 {
+		if loadBalancers, err = awsutil.ALBsvc.CountLoadBalancers(); err != nil {
+			log.Warnf("Unable to count ALBs, the ALB quota isn't checked. Error: %s", "controller", err.Error())
+			newLoadBalancers = 0
+		}
+	}
+	if newTargetGroups > 0 {
+		tgs, err := awsutil.ALBsvc.DescribeTargetGroups(nil)
+		if err != nil {
+			log.Warnf("Unable to count target groups, the target group quota isn't checked. Error: %s", "controller", err.Error())
+			newTargetGroups = 0
+		}
+		targetGroups = int64(len(tgs))
+	}
+
+	var maxListeners, maxCertificates, maxTargetGroups, maxTargets, maxSecurityGroupRules int64
+	for _, ALBIngress := range ac.ALBIngresses {
+		if ALBIngress.tainted || ALBIngress.roleArn != "" {
+			continue
+		}
+		var violations []string
+		var ingressLoadBalancers, ingressTargetGroups int64
+		for _, lb := range ALBIngress.LoadBalancers {
+			if lb.DesiredLoadBalancer == nil {
+				continue
+			}
+			if lb.CurrentLoadBalancer == nil && newLoadBalancers > 0 {
+				ingressLoadBalancers++
+			}
+
+			// The default certificates of the listeners don't count towards the certificates quota.
+			var listeners, certificates, lbTargetGroups, targets int64
+			for _, l := range lb.Listeners {
+				if l.DesiredListener != nil {
+					listeners++
+					certificates += int64(len(l.DesiredSNICertificates))
+				}
+			}
+			// Retiring target groups are still attached to the ALB, so they count as well.
+			for _, tg := range lb.TargetGroups {
+				if tg.DesiredTargetGroup == nil {
+					continue
+				}
+				if tg.CurrentTargetGroup == nil && newTargetGroups > 0 {
+					ingressTargetGroups++
+				}
+				lbTargetGroups++
+				targets += int64(len(tg.DesiredTargets))
+			}
+			if limit, ok := limits[awsutil.LimitListenersPerLoadBalancer]; ok && listeners > limit {
+				violations = append(violations, fmt.Sprintf("ALB %s needs %d listeners, the limit is %d", *lb.ID, listeners, limit))
+			}
+			if limit, ok := limits[awsutil.LimitCertificatesPerLoadBalancer]; ok && certificates > limit {
+				violations = append(violations, fmt.Sprintf("ALB %s needs %d certificates besides the default ones, the limit is %d",
+					*lb.ID, certificates, limit))
+			}
+			if limit, ok := limits[awsutil.LimitTargetGroupsPerLoadBalancer]; ok && lbTargetGroups > limit {
+				violations = append(violations, fmt.Sprintf("ALB %s needs %d target groups, the limit is %d", *lb.ID, lbTargetGroups, limit))
+			}
+			if limit, ok := limits[awsutil.LimitTargetsPerLoadBalancer]; ok && targets > limit {
+				violations = append(violations, fmt.Sprintf("ALB %s needs %d targets, the limit is %d", *lb.ID, targets, limit))
+			}
+			if listeners > maxListeners {
+				maxListeners = listeners
+			}
+			if certificates > maxCertificates {
+				maxCertificates = certificates
+			}
+			if lbTargetGroups > maxTargetGroups {
+				maxTargetGroups = lbTargetGroups
+			}
+			if targets > maxTargets {
+				maxTargets = targets
+			}
+
+			if sg := lb.SecurityGroup; sg != nil && sg.DesiredSecurityGroup != nil {
+				rules := sg.PeakRules()
+				if rules > ac.sgRuleQuota {
+					violations = append(violations, fmt.Sprintf("security group of ALB %s needs %d inbound rules, the limit is %d",
+						*lb.ID, rules, ac.sgRuleQuota))
+				}
+				ac.warnSecurityGroupRules(ALBIngress, lb, rules)
+				if rules > maxSecurityGroupRules {
+					maxSecurityGroupRules = rules
+				}
+			}
+		}
+
+		if limit, ok := limits[awsutil.LimitLoadBalancers]; ok && ingressLoadBalancers > 0 {
+			if loadBalancers+ingressLoadBalancers > limit {
+				violations = append(violations, fmt.Sprintf("%d ALBs would be created, %d of the region's limit of %d are in use",
+					ingressLoadBalancers, loadBalancers, limit))
+			} else {
+				loadBalancers += ingressLoadBalancers
+			}
+		}
+		if limit, ok := limits[awsutil.LimitTargetGroups]; ok && ingressTargetGroups > 0 {
+			if targetGroups+ingressTargetGroups > limit {
+				violations = append(violations, fmt.Sprintf("%d target groups would be created, %d of the region's limit of %d are in use",
+					ingressTargetGroups, targetGroups, limit))
+			} else {
+				targetGroups += ingressTargetGroups
+			}
+		}
+
+		if len(violations) > 0 {
+			exceeded[*ALBIngress.id] = violations
+		}
+	}
+
+	// The shared security group can't be split between ingresses, so it's only reported.
+	if sg := ac.sharedSecurityGroup; sg != nil && sg.DesiredSecurityGroup != nil {
+		rules := sg.PeakRules()
+		if float64(rules) >= securityGroupRuleWarningRatio*float64(ac.sgRuleQuota) {
+			log.Warnf("The shared security group needs %d of the %d inbound rules a security group can have.", "controller",
+				rules, ac.sgRuleQuota)
+		}
+		if rules > maxSecurityGroupRules {
+			maxSecurityGroupRules = rules
+		}
+	}
+
+	if newLoadBalancers > 0 {
+		awsutil.AWSQuotaUsage.With(prometheus.Labels{"quota": awsutil.LimitLoadBalancers}).Set(float64(loadBalancers))
+	}
+	if newTargetGroups > 0 {
+		awsutil.AWSQuotaUsage.With(prometheus.Labels{"quota": awsutil.LimitTargetGroups}).Set(float64(targetGroups))
+	}
+	awsutil.AWSQuotaUsage.With(prometheus.Labels{"quota": awsutil.LimitListenersPerLoadBalancer}).Set(float64(maxListeners))
+	awsutil.AWSQuotaUsage.With(prometheus.Labels{"quota": awsutil.LimitCertificatesPerLoadBalancer}).Set(float64(maxCertificates))
+	awsutil.AWSQuotaUsage.With(prometheus.Labels{"quota": awsutil.LimitTargetGroupsPerLoadBalancer}).Set(float64(maxTargetGroups))
+	awsutil.AWSQuotaUsage.With(prometheus.Labels{"quota": awsutil.LimitTargetsPerLoadBalancer}).Set(float64(maxTargets))
+	awsutil.AWSQuotaUsage.With(prometheus.Labels{"quota": securityGroupRulesQuota}).Set(float64(maxSecurityGroupRules))
+	return exceeded
+}
+
+// warnSecurityGroupRules records a SecurityGroupRulesNearLimit warning event on the ingress when the
+// managed security group of lb needs rules close to the quota, rather than leaving the ingress to
+// fail with RulesPerSecurityGroupLimitExceeded once it grows further. The event is recorded again
+// whenever the number of rules changes.
+func (ac *ALBController) warnSecurityGroupRules(ALBIngress *ALBIngress, lb *alb.LoadBalancer, rules int64) {
+	key := *ALBIngress.id + " " + *lb.ID
+	if float64(rules) < securityGroupRuleWarningRatio*float64(ac.sgRuleQuota) {
+		delete(ac.sgRuleWarnings, key)
+		return
+	}
+	if ac.sgRuleWarnings[key] == rules {
+		return
+	}
+	ac.sgRuleWarnings[key] = rules
+
+	hint := "enable SHARED_SECURITY_GROUP to let the ALBs share their rules"
+	if ac.sharedSecurityGroup != nil {
+		hint = "reduce the inbound-cidrs and inbound-prefix-lists of the ingress"
+	}
+	log.Warnf("The security group of ALB %s needs %d of the %d inbound rules a security group can have.", *ALBIngress.id,
+		*lb.ID, rules, ac.sgRuleQuota)
+	ingResource, exists := ac.ingressResource(*ALBIngress.namespace, *ALBIngress.ingressName)
+	if !exists {
+		return
+	}
+	ac.recorder.Eventf(ingResource, api.EventTypeWarning, "SecurityGroupRulesNearLimit",
+		"The security group of ALB %s needs %d of the %d inbound rules a security group can have, %s", *lb.ID, rules, ac.sgRuleQuota, hint)
+}
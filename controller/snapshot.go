@@ -0,0 +1,340 @@
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/coreos/alb-ingress-controller/controller/alb"
+	"github.com/coreos/alb-ingress-controller/log"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	api "k8s.io/client-go/pkg/api/v1"
+)
+
+// stateSnapshotVersion is bumped whenever the contents of a state snapshot change, so snapshots
+// saved by another version of the controller aren't restored.
+const stateSnapshotVersion = 2
+
+// stateSnapshotKey is the key of the ConfigMap data holding the state snapshot.
+const stateSnapshotKey = "state"
+
+// stateGenerationKey is the key of the ConfigMap data holding the generation of the controller that
+// started last. Each controller increments it on startup.
+const stateGenerationKey = "generation"
+
+// stateSnapshot is the current state of the ALBs of the controller, saved to the STATE_CONFIGMAP
+// on shutdown so the next controller restores it instead of describing every ALB again.
+type stateSnapshot struct {
+	Version int
+	// Generation is the generation of the controller that saved the snapshot
+	Generation int64
+	// ConfigHash is the hash of the settings the ALBs were imported with, see snapshotConfigHash
+	ConfigHash string
+	// LoadBalancers holds the current state of each ALB, keyed by ARN, along with the IAM role of
+	// its namespace
+	LoadBalancers map[string]*snapshotLoadBalancer
+}
+
+type snapshotLoadBalancer struct {
+	RoleArn      string
+	LoadBalancer *alb.LoadBalancer
+}
+
+// parseStateConfigMap returns the namespace and name of the ConfigMap named namespace/name by the
+// STATE_CONFIGMAP setting.
+func parseStateConfigMap(s string) (string, string, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("STATE_CONFIGMAP %s must be of the form namespace/name", s)
+	}
+	return parts[0], parts[1], nil
+}
+
+// snapshotConfigHash returns the hash of the settings deciding which ALBs the controller imports.
+// A snapshot saved with other settings isn't restored.
+func (ac *ALBController) snapshotConfigHash() string {
+	hasher := fnv.New32a()
+	fmt.Fprintf(hasher, "%d %s %s %s %d %d %s", stateSnapshotVersion, *ac.clusterName, ac.controllerID, ac.IngressClass,
+		ac.shardCount, ac.shardIndex, ac.shardBy)
+	if ac.groupBy != "" && ac.groupBy != groupByIngress {
+		fmt.Fprintf(hasher, " group-by-%s", ac.groupBy)
+	}
+	if ac.disableRoute53 {
+		hasher.Write([]byte(" no-route53"))
+	}
+	return fmt.Sprintf("%x", hasher.Sum32())
+}
+
+// saveSnapshot saves the current state of the ALBs of every ALBIngress to the state ConfigMap. It's
+// called with the controller's lock held once no reconcile is running any more.
+func (ac *ALBController) saveSnapshot() error {
+	if ac.stateConfigMap == "" || ac.client == nil {
+		return nil
+	}
+
+	snapshot := &stateSnapshot{
+		Version:       stateSnapshotVersion,
+		Generation:    ac.stateGeneration,
+		ConfigHash:    ac.snapshotConfigHash(),
+		LoadBalancers: make(map[string]*snapshotLoadBalancer),
+	}
+	for _, ALBIngress := range ac.ALBIngresses {
+		for _, lb := range ALBIngress.LoadBalancers {
+			if lb.CurrentLoadBalancer == nil {
+				continue
+			}
+			snapshot.LoadBalancers[*lb.CurrentLoadBalancer.LoadBalancerArn] = &snapshotLoadBalancer{
+				RoleArn:      ALBIngress.roleArn,
+				LoadBalancer: currentState(lb),
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	// During a rolling update, the controller replacing this one started before it shut down, and
+	// may already have changed the ALBs.
+	err := ac.updateStateConfigMap(func(data map[string]string) error {
+		if generation := data[stateGenerationKey]; generation != strconv.FormatInt(ac.stateGeneration, 10) {
+			return fmt.Errorf("the snapshot is stale, a controller of generation %s started since this one of generation %d",
+				generation, ac.stateGeneration)
+		}
+		data[stateSnapshotKey] = base64.StdEncoding.EncodeToString(buf.Bytes())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Infof("Saved the state of %d ALBs to the %s ConfigMap", "controller", len(snapshot.LoadBalancers), ac.stateConfigMap)
+	return nil
+}
+
+// loadSnapshot returns the state snapshot saved to the state ConfigMap, nil when there's none or it
+// doesn't apply to the controller's settings. The generation of the ConfigMap is incremented and
+// becomes the controller's, and the snapshot is removed, as the ALBs change once the controller
+// reconciles them: a controller that crashes doesn't leave a stale snapshot behind. A snapshot saved
+// by a controller other than the one that started last, e.g. one that shut down after its
+// replacement started during a rolling update, is stale and isn't restored either.
+func (ac *ALBController) loadSnapshot() *stateSnapshot {
+	if ac.stateConfigMap == "" || ac.client == nil {
+		return nil
+	}
+	var data string
+	var previous int64
+	err := ac.updateStateConfigMap(func(configMapData map[string]string) error {
+		data = configMapData[stateSnapshotKey]
+		previous = 0
+		if generation := configMapData[stateGenerationKey]; generation != "" {
+			var err error
+			if previous, err = strconv.ParseInt(generation, 10, 64); err != nil {
+				return fmt.Errorf("invalid generation %s: %s", generation, err.Error())
+			}
+		}
+		configMapData[stateGenerationKey] = strconv.FormatInt(previous+1, 10)
+		configMapData[stateSnapshotKey] = ""
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Failed to update the %s ConfigMap, describing every ALB. Error: %s", "controller", ac.stateConfigMap, err.Error())
+		return nil
+	}
+	ac.stateGeneration = previous + 1
+	if data == "" {
+		return nil
+	}
+
+	snapshot, err := decodeSnapshot(data)
+	if err != nil {
+		log.Warnf("The state snapshot of the %s ConfigMap can't be read, describing every ALB. Error: %s", "controller", ac.stateConfigMap, err.Error())
+		return nil
+	}
+	if snapshot.Version != stateSnapshotVersion || snapshot.ConfigHash != ac.snapshotConfigHash() {
+		log.Infof("The state snapshot of the %s ConfigMap was saved with other settings, describing every ALB", "controller", ac.stateConfigMap)
+		return nil
+	}
+	if snapshot.Generation != previous {
+		log.Infof("The state snapshot of the %s ConfigMap was saved by the controller of generation %d rather than %d, describing every ALB",
+			"controller", ac.stateConfigMap, snapshot.Generation, previous)
+		return nil
+	}
+	return snapshot
+}
+
+// decodeSnapshot decodes a state snapshot saved by saveSnapshot.
+func decodeSnapshot(data string) (*stateSnapshot, error) {
+	compressed, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, err
+	}
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &stateSnapshot{}
+	if err := json.Unmarshal(b, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// updateStateConfigMap updates the data of the state ConfigMap with update, creating the ConfigMap
+// when it doesn't exist. The update fails with a conflict when the ConfigMap was changed in the
+// meantime, e.g. by another controller.
+func (ac *ALBController) updateStateConfigMap(update func(data map[string]string) error) error {
+	namespace, name, _ := parseStateConfigMap(ac.stateConfigMap)
+	configMaps := ac.client.Core().ConfigMaps(namespace)
+	cm, err := configMaps.Get(name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		data := make(map[string]string)
+		if err := update(data); err != nil {
+			return err
+		}
+		_, err = configMaps.Create(&api.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Data:       data,
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	if err := update(cm.Data); err != nil {
+		return err
+	}
+	_, err = configMaps.Update(cm)
+	return err
+}
+
+// restore returns the LoadBalancer saved for loadBalancer under the IAM role roleArn, as if it was
+// imported from AWS. ok is false when the snapshot holds no such ALB, or one that was since
+// replaced.
+func (s *stateSnapshot) restore(loadBalancer *elbv2.LoadBalancer, roleArn string) (lb *alb.LoadBalancer, ok bool) {
+	if s == nil {
+		return nil, false
+	}
+	saved, ok := s.LoadBalancers[*loadBalancer.LoadBalancerArn]
+	if !ok || saved.RoleArn != roleArn || saved.LoadBalancer == nil || saved.LoadBalancer.CurrentLoadBalancer == nil ||
+		!aws.TimeValue(saved.LoadBalancer.CurrentLoadBalancer.CreatedTime).Equal(aws.TimeValue(loadBalancer.CreatedTime)) {
+		return nil, false
+	}
+	lb = saved.LoadBalancer
+	// The ALB as just described is more recent, e.g. its state or subnets.
+	lb.CurrentLoadBalancer = loadBalancer
+	return lb, true
+}
+
+// currentState returns a LoadBalancer holding the current state of lb, as importIngresses assembles
+// it from AWS.
+func currentState(lb *alb.LoadBalancer) *alb.LoadBalancer {
+	s := &alb.LoadBalancer{
+		ID:                  lb.ID,
+		IngressID:           lb.IngressID,
+		Hostname:            lb.Hostname,
+		CurrentLoadBalancer: lb.CurrentLoadBalancer,
+		ResourceRecordSet:   currentRecordSet(lb.ResourceRecordSet),
+		ExtraHostnames:      lb.ExtraHostnames,
+		CurrentTags:         lb.CurrentTags,
+		CurrentAttributes:   lb.CurrentAttributes,
+		CurrentWebACLID:     lb.CurrentWebACLID,
+		LastRulePriority:    lb.LastRulePriority,
+	}
+	// As when importing, the A record is kept along with its hosted zone before it's published, the
+	// AAAA and extra records only once they exist.
+	if r := lb.IPv6RecordSet; r != nil && r.CurrentResourceRecordSet != nil {
+		s.IPv6RecordSet = currentRecordSet(r)
+	}
+	for _, r := range lb.ExtraRecordSets {
+		if r.CurrentResourceRecordSet != nil {
+			s.ExtraRecordSets = append(s.ExtraRecordSets, currentRecordSet(r))
+		}
+	}
+	if lb.Accelerator != nil {
+		s.Accelerator = lb.Accelerator.CurrentState()
+	}
+	if sg := lb.SecurityGroup; sg != nil && sg.CurrentSecurityGroup != nil {
+		s.SecurityGroup = &alb.SecurityGroup{
+			IngressID:            sg.IngressID,
+			Owner:                sg.Owner,
+			CurrentSecurityGroup: sg.CurrentSecurityGroup,
+		}
+	}
+	for _, tg := range lb.TargetGroups {
+		if tg.CurrentTargetGroup == nil {
+			continue
+		}
+		s.TargetGroups = append(s.TargetGroups, &alb.TargetGroup{
+			ID:                   tg.ID,
+			IngressID:            tg.IngressID,
+			SvcName:              tg.SvcName,
+			SvcPort:              tg.SvcPort,
+			TargetType:           tg.TargetType,
+			CurrentTags:          tg.CurrentTags,
+			CurrentTargets:       tg.CurrentTargets,
+			CurrentStaticTargets: tg.CurrentStaticTargets,
+			CurrentTargetGroup:   tg.CurrentTargetGroup,
+			CurrentAttributes:    tg.CurrentAttributes,
+		})
+	}
+	for _, l := range lb.Listeners {
+		if l.CurrentListener == nil {
+			continue
+		}
+		listener := &alb.Listener{
+			IngressID:              l.IngressID,
+			CurrentListener:        l.CurrentListener,
+			CurrentSNICertificates: l.CurrentSNICertificates,
+			CurrentWeights:         l.CurrentWeights,
+		}
+		for _, r := range l.Rules {
+			if r.CurrentRule == nil {
+				continue
+			}
+			listener.Rules = append(listener.Rules, &alb.Rule{
+				IngressID:       r.IngressID,
+				SvcName:         r.SvcName,
+				SvcPort:         r.SvcPort,
+				CurrentRule:     r.CurrentRule,
+				CurrentRedirect: r.CurrentRedirect,
+				CurrentWeights:  r.CurrentWeights,
+			})
+		}
+		s.Listeners = append(s.Listeners, listener)
+	}
+	return s
+}
+
+// currentRecordSet returns a ResourceRecordSet holding the current state of r.
+func currentRecordSet(r *alb.ResourceRecordSet) *alb.ResourceRecordSet {
+	if r == nil {
+		return nil
+	}
+	return &alb.ResourceRecordSet{
+		IngressID:                r.IngressID,
+		ZoneID:                   r.ZoneID,
+		Resolveable:              r.Resolveable,
+		CurrentResourceRecordSet: r.CurrentResourceRecordSet,
+		CurrentHealthCheck:       r.CurrentHealthCheck,
+	}
+}
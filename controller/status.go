@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// ingressStatus is the state of an ALBIngress reported by StatusHandler.
+type ingressStatus struct {
+	Ingress       string                `json:"ingress"`
+	LoadBalancers []*loadBalancerStatus `json:"loadBalancers"`
+}
+
+// loadBalancerStatus is the state of an ALB and the resources belonging to it. State holds both the
+// desired state built from the ingress and the current state last observed in AWS, encoded when the
+// status was taken, as the alb.LoadBalancer keeps changing.
+type loadBalancerStatus struct {
+	ID        string          `json:"id"`
+	Hostname  string          `json:"hostname"`
+	LastError string          `json:"lastError,omitempty"`
+	Pending   []string        `json:"pending"`
+	State     json.RawMessage `json:"state"`
+}
+
+// ingressSummary is the summary of an ALBIngress reported by IngressesHandler.
+type ingressSummary struct {
+	Ingress       string                 `json:"ingress"`
+	RoleArn       string                 `json:"roleArn,omitempty"`
+	LoadBalancers []*loadBalancerSummary `json:"loadBalancers"`
+}
+
+// loadBalancerSummary is the summary of an ALB. Its ARN and DNS name are empty until it's created.
+type loadBalancerSummary struct {
+	ID            string                `json:"id"`
+	Arn           string                `json:"arn,omitempty"`
+	DNSName       string                `json:"dnsName,omitempty"`
+	Hostname      string                `json:"hostname"`
+	TargetGroups  []*targetGroupSummary `json:"targetGroups"`
+	LastReconcile *reconcileSummary     `json:"lastReconcile,omitempty"`
+}
+
+// targetGroupSummary is the summary of a target group, with the number of targets in each health
+// state as last polled.
+type targetGroupSummary struct {
+	ID           string         `json:"id"`
+	Arn          string         `json:"arn,omitempty"`
+	Service      string         `json:"service"`
+	Targets      int            `json:"targets"`
+	TargetHealth map[string]int `json:"targetHealth"`
+}
+
+// reconcileSummary is the result of the last reconcile of an ALB. A reconcile that registered
+// targets only succeeds once they're healthy or the warmup timeout passed, it's warming up meanwhile.
+type reconcileSummary struct {
+	Time      time.Time `json:"time"`
+	Succeeded bool      `json:"succeeded"`
+	WarmingUp bool      `json:"warmingUp,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// IngressesHandler lists the ingresses the controller manages with their ALBs, target groups,
+// target health and last reconcile result, for dashboards and support tooling. Unlike
+// StatusHandler, it describes the resources in AWS rather than the controller's model of them.
+func (ac *ALBController) IngressesHandler(w http.ResponseWriter, r *http.Request) {
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+
+	summaries := []*ingressSummary{}
+	for _, ALBIngress := range ac.ALBIngresses {
+		summaries = append(summaries, ALBIngress.summary())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(summaries)
+}
+
+// summary returns the ingressSummary of the ALBIngress. The target health is polled without the
+// controller's lock, so the ALBIngress lock is held while reading it.
+func (a *ALBIngress) summary() *ingressSummary {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	s := &ingressSummary{
+		Ingress:       fmt.Sprintf("%s/%s", *a.namespace, *a.ingressName),
+		RoleArn:       a.roleArn,
+		LoadBalancers: []*loadBalancerSummary{},
+	}
+	for _, lb := range a.LoadBalancers {
+		l := &loadBalancerSummary{
+			ID:           *lb.ID,
+			Hostname:     aws.StringValue(lb.Hostname),
+			TargetGroups: []*targetGroupSummary{},
+		}
+		if lb.CurrentLoadBalancer != nil {
+			l.Arn = aws.StringValue(lb.CurrentLoadBalancer.LoadBalancerArn)
+			l.DNSName = aws.StringValue(lb.CurrentLoadBalancer.DNSName)
+		}
+		if !lb.LastReconciled.IsZero() {
+			l.LastReconcile = &reconcileSummary{Time: lb.LastReconciled}
+			if lb.LastError != nil {
+				l.LastReconcile.Error = lb.LastError.Error()
+			} else {
+				l.LastReconcile.WarmingUp = lb.TargetGroups.WarmingUp()
+				l.LastReconcile.Succeeded = !l.LastReconcile.WarmingUp
+			}
+		}
+		for _, tg := range lb.TargetGroups {
+			t := &targetGroupSummary{
+				ID:           *tg.ID,
+				Service:      fmt.Sprintf("%s:%s", tg.SvcName, tg.SvcPort.String()),
+				Targets:      len(tg.CurrentTargets),
+				TargetHealth: make(map[string]int),
+			}
+			if tg.CurrentTargetGroup != nil {
+				t.Arn = aws.StringValue(tg.CurrentTargetGroup.TargetGroupArn)
+			}
+			for _, state := range tg.TargetHealth {
+				t.TargetHealth[state]++
+			}
+			l.TargetGroups = append(l.TargetGroups, t)
+		}
+		s.LoadBalancers = append(s.LoadBalancers, l)
+	}
+	return s
+}
+
+// StatusHandler reports, for every ingress, its desired and current state along with the changes
+// the next reconcile would make, for troubleshooting. An ingress query parameter, e.g.
+// ?ingress=default/echoserver, limits the report to that ingress. The states are copied under the
+// controller's lock, and written once it's released, so a slow client doesn't hold up reconciles.
+func (ac *ALBController) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	filter := r.URL.Query().Get("ingress")
+	statuses, err := ac.statuses(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if filter != "" && len(statuses) == 0 {
+		http.Error(w, fmt.Sprintf("ingress %s is not managed by the controller", filter), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(statuses)
+}
+
+// statuses returns the ingressStatus of every ALBIngress, or only of the one named filter unless
+// it's empty.
+func (ac *ALBController) statuses(filter string) ([]*ingressStatus, error) {
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+
+	statuses := []*ingressStatus{}
+	for _, ALBIngress := range ac.ALBIngresses {
+		name := fmt.Sprintf("%s/%s", *ALBIngress.namespace, *ALBIngress.ingressName)
+		if filter != "" && filter != name {
+			continue
+		}
+		status, err := ALBIngress.status(name)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// status returns the ingressStatus of the ALBIngress. Deferred Route 53 changes update the
+// LoadBalancers without the controller's lock, so the ALBIngress lock is held while copying them.
+func (a *ALBIngress) status(name string) (*ingressStatus, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	status := &ingressStatus{Ingress: name, LoadBalancers: []*loadBalancerStatus{}}
+	for _, lb := range a.LoadBalancers {
+		state, err := json.Marshal(lb)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode the state of %s: %s", *lb.ID, err.Error())
+		}
+		s := &loadBalancerStatus{
+			ID:       *lb.ID,
+			Hostname: *lb.Hostname,
+			Pending:  lb.PendingChanges(),
+			State:    state,
+		}
+		if lb.LastError != nil {
+			s.LastError = lb.LastError.Error()
+		}
+		status.LoadBalancers = append(status.LoadBalancers, s)
+	}
+	return status, nil
+}
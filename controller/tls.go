@@ -0,0 +1,372 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/controller/config"
+	"github.com/coreos/alb-ingress-controller/controller/util"
+	"github.com/coreos/alb-ingress-controller/log"
+	api "k8s.io/client-go/pkg/api/v1"
+	extensions "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// secretTag is the tag carrying the namespace/name of the secret an ACM certificate was imported from.
+	secretTag = "Secret"
+	// secretHashTag is the tag carrying the hash of the secret contents an ACM certificate was imported from.
+	secretHashTag = "SecretHash"
+	// requestTag is the tag carrying the hash of the hosts an ACM certificate was requested for.
+	requestTag = "RequestedHosts"
+	// validationRecordTTL is the TTL of the records validating requested certificates.
+	validationRecordTTL = 300
+)
+
+// tlsCertificate is an ACM certificate imported from a TLS secret.
+type tlsCertificate struct {
+	arn  *string
+	hash string // hash of the secret contents last imported
+}
+
+// tlsAnnotations returns the annotations of an ingress, with the certificate-arn annotation set to
+// the certificates selected by its spec.tls blocks, following the Ingress semantics: the
+// certificate of the first block becomes the default certificate of the HTTPS listeners, the
+// others are served through SNI. The certificates of each block are selected by the chain of
+// certificate resolvers, by default from the certificate-arn annotation when present, from the
+// block's TLS secret when TLS secrets are synced. Certificates of the annotation no block picked
+// are served through SNI as well. Ingresses without spec.tls, or whose listen-ports list no HTTPS
+// port, have their annotations returned as they are.
+func (ac *ALBController) tlsAnnotations(ingress *extensions.Ingress) (map[string]string, error) {
+	if len(ingress.Spec.TLS) == 0 || config.ListensHTTPOnly(ingress.Annotations) {
+		return ingress.Annotations, nil
+	}
+
+	var arns []string
+	seen := make(map[string]bool)
+	add := func(arn string) {
+		if !seen[arn] {
+			seen[arn] = true
+			arns = append(arns, arn)
+		}
+	}
+
+	for _, tls := range ingress.Spec.TLS {
+		resolved, err := ac.resolveCertificates(ingress, tls)
+		if err != nil {
+			return nil, err
+		}
+		for _, arn := range resolved {
+			add(arn)
+		}
+	}
+	for _, arn := range config.CertificateArns(ingress.Annotations) {
+		add(arn)
+	}
+
+	if len(arns) == 0 {
+		return ingress.Annotations, nil
+	}
+	return config.WithCertificateArns(ingress.Annotations, arns), nil
+}
+
+// candidateCertificates returns the details of the ACM certificates among arns, or of every issued
+// ACM certificate when arns is empty. IAM server certificates can't be described through ACM, so
+// they're left out.
+func candidateCertificates(arns []string) ([]*acm.CertificateDetail, error) {
+	if len(arns) == 0 {
+		certificates, err := awsutil.ACMsvc.DescribeIssuedCertificates()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to discover ACM certificates: %s", err.Error())
+		}
+		return certificates, nil
+	}
+
+	certificates := []*acm.CertificateDetail{}
+	for _, arn := range arns {
+		if strings.Contains(arn, ":iam::") {
+			continue
+		}
+		certificate, err := awsutil.ACMsvc.LookupCertificate(aws.String(arn))
+		if err != nil {
+			return nil, fmt.Errorf("Unable to describe certificate %s: %s", arn, err.Error())
+		}
+		certificates = append(certificates, certificate)
+	}
+	return certificates, nil
+}
+
+// matchCertificate returns the certificate that covers host, or nil when none does. Certificates
+// naming host exactly are preferred over wildcard certificates, and among those, the one expiring
+// last.
+func matchCertificate(certificates []*acm.CertificateDetail, host string) *acm.CertificateDetail {
+	var match *acm.CertificateDetail
+	var matchExact bool
+	for _, certificate := range certificates {
+		covers, exact := false, false
+		for _, domain := range append([]*string{certificate.DomainName}, certificate.SubjectAlternativeNames...) {
+			switch d := strings.ToLower(aws.StringValue(domain)); {
+			case d == strings.ToLower(host):
+				covers, exact = true, true
+			case strings.HasPrefix(d, "*.") && wildcardCovers(d, host):
+				covers = true
+			}
+		}
+		switch {
+		case !covers:
+		case match == nil, exact && !matchExact:
+			match, matchExact = certificate, exact
+		case exact == matchExact && expiresAfter(certificate, match):
+			match = certificate
+		}
+	}
+	return match
+}
+
+// wildcardCovers reports whether a wildcard domain, e.g. *.example.com, covers host. A wildcard
+// covers a single label, so it matches www.example.com but neither example.com nor a.b.example.com.
+func wildcardCovers(wildcard, host string) bool {
+	host = strings.ToLower(host)
+	i := strings.Index(host, ".")
+	return i > 0 && host[i:] == wildcard[1:]
+}
+
+// expiresAfter reports whether certificate a expires after certificate b.
+func expiresAfter(a, b *acm.CertificateDetail) bool {
+	if a.NotAfter == nil || b.NotAfter == nil {
+		return a.NotAfter != nil
+	}
+	return a.NotAfter.After(*b.NotAfter)
+}
+
+// syncTLSSecret imports the certificate of a TLS secret into ACM and returns its ARN. When the
+// secret changes, e.g. because cert-manager renewed the certificate, the certificate is reimported
+// in place, so it keeps its ARN and listeners don't need to be modified. Imported certificates are
+// tagged with the secret they came from, so they're found again after a restart.
+func (ac *ALBController) syncTLSSecret(namespace, name string) (*string, error) {
+	key := fmt.Sprintf("%s/%s", namespace, name)
+	item, exists, _ := ac.storeLister.Secret.GetByKey(key)
+	if !exists {
+		return nil, fmt.Errorf("Unable to find the %v secret", key)
+	}
+	secret := item.(*api.Secret)
+	certificate, privateKey := secret.Data[api.TLSCertKey], secret.Data[api.TLSPrivateKeyKey]
+	if len(certificate) == 0 || len(privateKey) == 0 {
+		return nil, fmt.Errorf("%v secret must contain a %s and a %s", key, api.TLSCertKey, api.TLSPrivateKeyKey)
+	}
+
+	hasher := sha256.New()
+	hasher.Write(certificate)
+	hasher.Write(privateKey)
+	hash := hex.EncodeToString(hasher.Sum(nil))
+
+	imported, ok := ac.tlsCertificates[key]
+	if ok && imported.hash == hash {
+		return imported.arn, nil
+	}
+
+	tags := map[string]string{
+		util.ClusterNameTag:  *ac.clusterName,
+		util.ControllerIDTag: ac.controllerID,
+		secretTag:            key,
+	}
+	if !ok {
+		arn, current, err := awsutil.ACMsvc.FindCertificate(tags)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to look up the certificate imported from the %v secret: %s", key, err.Error())
+		}
+		if arn != nil {
+			imported = &tlsCertificate{arn: arn, hash: current[secretHashTag]}
+			ac.tlsCertificates[key] = imported
+			if imported.hash == hash {
+				return arn, nil
+			}
+		}
+	}
+
+	in := acm.ImportCertificateInput{PrivateKey: privateKey}
+	in.Certificate, in.CertificateChain = splitCertificateChain(certificate)
+	if in.Certificate == nil {
+		return nil, fmt.Errorf("%s of the %v secret must be a PEM encoded certificate", api.TLSCertKey, key)
+	}
+	if imported != nil {
+		in.CertificateArn = imported.arn
+	}
+	arn, err := awsutil.ACMsvc.ImportCertificate(in)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to import the certificate of the %v secret into ACM: %s", key, err.Error())
+	}
+	// The hash is only recorded once the certificate is tagged, so a failure to tag it is retried.
+	ac.tlsCertificates[key] = &tlsCertificate{arn: arn}
+
+	tags[secretHashTag] = hash
+	if err := awsutil.ACMsvc.AddTagsToCertificate(arn, tags); err != nil {
+		return nil, fmt.Errorf("Unable to tag the certificate imported from the %v secret: %s", key, err.Error())
+	}
+	ac.tlsCertificates[key].hash = hash
+	log.Infof("Imported the certificate of the %s secret into ACM. ARN: %s", "controller", key, *arn)
+	return arn, nil
+}
+
+// requestedCertificate is an ACM certificate requested for the hosts of a spec.tls block.
+type requestedCertificate struct {
+	arn       *string
+	issued    bool
+	validated map[string]bool // names of the validation records created in Route 53
+}
+
+// requestCertificate returns the ARN of the DNS validated ACM certificate requested for hosts,
+// requesting it when there is none. Until ACM issues it, the records validating it are created in
+// Route 53 and an error is returned, so the ingress is synced again. Requested certificates are
+// tagged with a hash of their hosts, so they're found again after a restart, and ACM renews them
+// in place as long as the records exist.
+func (ac *ALBController) requestCertificate(namespace string, hosts []string) (*string, error) {
+	seen := make(map[string]bool)
+	var names []string
+	for _, host := range hosts {
+		host = strings.ToLower(host)
+		if !seen[host] {
+			seen[host] = true
+			names = append(names, host)
+		}
+	}
+	sort.Strings(names)
+	sum := sha256.Sum256([]byte(strings.Join(names, ",")))
+	// The hash doubles as the idempotency token of the request, which can't exceed 32 characters.
+	hash := hex.EncodeToString(sum[:16])
+	description := strings.Join(names, ", ")
+
+	// Certificates are looked up under the IAM role of the namespace, so they're cached by namespace.
+	key := fmt.Sprintf("%s/%s", namespace, hash)
+	requested, ok := ac.requestedCertificates[key]
+	if !ok {
+		tags := map[string]string{
+			util.ClusterNameTag:  *ac.clusterName,
+			util.ControllerIDTag: ac.controllerID,
+			requestTag:           hash,
+		}
+		arn, _, err := awsutil.ACMsvc.FindCertificate(tags)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to look up the certificate requested for %s: %s", description, err.Error())
+		}
+		if arn == nil {
+			arn, err = awsutil.ACMsvc.Requests.RequestCertificate(names[0], names[1:], hash, tags)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to request a certificate for %s from ACM: %s", description, err.Error())
+			}
+			log.Infof("Requested a certificate for %s from ACM. ARN: %s", "controller", description, *arn)
+		}
+		requested = &requestedCertificate{arn: arn, validated: make(map[string]bool)}
+		ac.requestedCertificates[key] = requested
+	}
+	if requested.issued {
+		return requested.arn, nil
+	}
+
+	validation, err := awsutil.ACMsvc.Requests.DescribeCertificateValidation(requested.arn)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to describe the certificate %s requested for %s: %s", *requested.arn, description, err.Error())
+	}
+	switch validation.Status {
+	case acm.CertificateStatusIssued:
+		requested.issued = true
+		return requested.arn, nil
+	case acm.CertificateStatusPendingValidation:
+	default:
+		return nil, fmt.Errorf("Certificate %s requested for %s is %s. Delete it from ACM to request another one", *requested.arn, description, validation.Status)
+	}
+
+	// ACM generates the records shortly after the request, the missing ones are created on a later sync.
+	for _, record := range validation.Records {
+		if requested.validated[*record.Name] {
+			continue
+		}
+		if err := createValidationRecord(record); err != nil {
+			return nil, fmt.Errorf("Unable to create the %s record validating the certificate %s: %s", *record.Name, *requested.arn, err.Error())
+		}
+		requested.validated[*record.Name] = true
+	}
+	return nil, fmt.Errorf("Certificate %s requested for %s is pending DNS validation", *requested.arn, description)
+}
+
+// createValidationRecord creates or updates record in the Route 53 hosted zone of its name.
+func createValidationRecord(record *awsutil.ResourceRecord) error {
+	zone, err := awsutil.Route53svc.GetZoneID(record.Name)
+	if err != nil {
+		return err
+	}
+	in := route53.ChangeResourceRecordSetsInput{
+		ChangeBatch: &route53.ChangeBatch{
+			Changes: []*route53.Change{
+				{
+					Action: aws.String(route53.ChangeActionUpsert),
+					ResourceRecordSet: &route53.ResourceRecordSet{
+						Name:            record.Name,
+						Type:            record.Type,
+						TTL:             aws.Int64(validationRecordTTL),
+						ResourceRecords: []*route53.ResourceRecord{{Value: record.Value}},
+					},
+				},
+			},
+			Comment: aws.String("Managed by Kubernetes"),
+		},
+		HostedZoneId: zone.Id,
+	}
+	return awsutil.Route53svc.Modify(in)
+}
+
+// resyncTLSSecrets reimports the certificates of TLS secrets that changed since they were last
+// imported. Secret updates don't trigger a sync of the ingresses using them, so rotated
+// certificates would otherwise only be picked up on the next ingress update.
+func (ac *ALBController) resyncTLSSecrets() {
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+
+	for key := range ac.tlsCertificates {
+		namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+		// The certificate is imported under the IAM role of the namespace, next to the listeners
+		// using it.
+		role, err := ac.namespaceRole(namespace)
+		if err != nil {
+			log.Errorf("Failed to sync TLS secret %s. Error: %s", "controller", key, err.Error())
+			continue
+		}
+		restore := awsutil.AssumeRole(role)
+		_, err = ac.syncTLSSecret(namespace, name)
+		restore()
+		if err != nil {
+			log.Errorf("Failed to sync TLS secret %s. Error: %s", "controller", key, err.Error())
+		}
+	}
+}
+
+// splitCertificateChain splits the PEM encoded contents of a tls.crt into the certificate and the
+// chain of intermediate certificates following it, as ACM expects them apart. The chain is nil
+// when there are no intermediate certificates.
+func splitCertificateChain(data []byte) ([]byte, []byte) {
+	var certificate, chain []byte
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if certificate == nil {
+			certificate = pem.EncodeToMemory(block)
+			continue
+		}
+		chain = append(chain, pem.EncodeToMemory(block)...)
+	}
+	return certificate, chain
+}
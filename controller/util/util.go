@@ -3,7 +3,9 @@ package util
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awsutil"
@@ -11,6 +13,22 @@ import (
 	"github.com/aws/aws-sdk-go/service/elbv2"
 )
 
+const (
+	// ClusterNameTag is the tag carrying the name of the cluster owning an AWS resource.
+	ClusterNameTag = "ClusterName"
+	// ControllerIDTag is the tag carrying the ID of the controller owning an AWS resource.
+	ControllerIDTag = "ControllerID"
+	// ShardTag is the tag carrying the shard of the controller owning an AWS resource, as
+	// <index>/<count>.
+	ShardTag = "Shard"
+	// ConfigHashTag is the tag carrying the hash of the attributes the controller set on an ALB or
+	// target group.
+	ConfigHashTag = "ConfigHash"
+	// AlarmsHashTag is the tag carrying the hash of the CloudWatch alarms the controller put for a
+	// target group.
+	AlarmsHashTag = "AlarmsHash"
+)
+
 type AWSStringSlice []*string
 type Tags []*elbv2.Tag
 type EC2Tags []*ec2.Tag
@@ -39,6 +57,36 @@ func (a AWSStringSlice) Hash() *string {
 	return aws.String(output)
 }
 
+// Difference returns the strings found in a but not in b.
+func (a AWSStringSlice) Difference(b AWSStringSlice) AWSStringSlice {
+	var out AWSStringSlice
+	exists := make(map[string]bool)
+	for _, str := range b {
+		exists[*str] = true
+	}
+	for _, str := range a {
+		if !exists[*str] {
+			out = append(out, str)
+		}
+	}
+	return out
+}
+
+// Intersect returns the strings found in both a and b.
+func (a AWSStringSlice) Intersect(b AWSStringSlice) AWSStringSlice {
+	var out AWSStringSlice
+	exists := make(map[string]bool)
+	for _, str := range b {
+		exists[*str] = true
+	}
+	for _, str := range a {
+		if exists[*str] {
+			out = append(out, str)
+		}
+	}
+	return out
+}
+
 func (t Tags) Hash() *string {
 	sort.Sort(t)
 	hasher := md5.New()
@@ -56,6 +104,31 @@ func (t *Tags) Get(s string) (string, bool) {
 	return "", false
 }
 
+// OwnershipConflict returns an error when the ownership tags of t, the tags of an existing AWS
+// resource, name another cluster, controller or shard than the ownership tags of owner. Resources
+// without ownership tags predate them and aren't in conflict, and neither are resources of a shard
+// out of another number of shards, which their ingress moved away from.
+func (t Tags) OwnershipConflict(owner Tags) error {
+	for _, key := range []string{ClusterNameTag, ControllerIDTag, ShardTag} {
+		want, ok := owner.Get(key)
+		if !ok {
+			continue
+		}
+		got, ok := t.Get(key)
+		if !ok || got == want || (key == ShardTag && !SameShardCount(got, want)) {
+			continue
+		}
+		return fmt.Errorf("owned by %s %s, not %s", key, got, want)
+	}
+	return nil
+}
+
+// SameShardCount returns true when the ShardTag values a and b name shards out of the same number
+// of shards.
+func SameShardCount(a, b string) bool {
+	return a[strings.Index(a, "/")+1:] == b[strings.Index(b, "/")+1:]
+}
+
 func (t EC2Tags) Get(s string) (string, bool) {
 	for _, tag := range t {
 		if *tag.Key == s {
@@ -65,6 +138,15 @@ func (t EC2Tags) Get(s string) (string, bool) {
 	return "", false
 }
 
+// AsEC2Tags returns the tags as EC2 tags, for use on EC2 resources such as security groups.
+func (t Tags) AsEC2Tags() EC2Tags {
+	var out EC2Tags
+	for _, tag := range t {
+		out = append(out, &ec2.Tag{Key: tag.Key, Value: tag.Value})
+	}
+	return out
+}
+
 func SortedMap(m map[string]string) Tags {
 	var t Tags
 	for k, v := range m {
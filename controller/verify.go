@@ -0,0 +1,416 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/controller/config"
+	"github.com/coreos/alb-ingress-controller/log"
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Share of an ELBV2 account limit from which Verify warns it's close to being reached
+const quotaHeadroomWarningRatio = 0.8
+
+// acmActions are the IAM actions the controller calls through ACMsvc, as granted by
+// examples/iam-policy.json, like the other actions below.
+var acmActions = []string{
+	"acm:AddTagsToCertificate",
+	"acm:DescribeCertificate",
+	"acm:ImportCertificate",
+	"acm:ListCertificates",
+	"acm:ListTagsForCertificate",
+	"acm:RequestCertificate",
+}
+
+// ec2Actions are the IAM actions the controller calls through Ec2svc.
+var ec2Actions = []string{
+	"ec2:AuthorizeSecurityGroupEgress",
+	"ec2:AuthorizeSecurityGroupIngress",
+	"ec2:CreateSecurityGroup",
+	"ec2:CreateTags",
+	"ec2:DeleteSecurityGroup",
+	"ec2:DescribeAccountAttributes",
+	"ec2:DescribePrefixLists",
+	"ec2:DescribeSecurityGroups",
+	"ec2:DescribeSubnets",
+	"ec2:RevokeSecurityGroupEgress",
+	"ec2:RevokeSecurityGroupIngress",
+}
+
+// elbv2Actions are the IAM actions the controller calls through ALBsvc.
+var elbv2Actions = []string{
+	"elasticloadbalancing:AddListenerCertificates",
+	"elasticloadbalancing:AddTags",
+	"elasticloadbalancing:CreateListener",
+	"elasticloadbalancing:CreateLoadBalancer",
+	"elasticloadbalancing:CreateRule",
+	"elasticloadbalancing:CreateTargetGroup",
+	"elasticloadbalancing:DeleteListener",
+	"elasticloadbalancing:DeleteLoadBalancer",
+	"elasticloadbalancing:DeleteRule",
+	"elasticloadbalancing:DeleteTargetGroup",
+	"elasticloadbalancing:DeregisterTargets",
+	"elasticloadbalancing:DescribeAccountLimits",
+	"elasticloadbalancing:DescribeListenerCertificates",
+	"elasticloadbalancing:DescribeListeners",
+	"elasticloadbalancing:DescribeLoadBalancerAttributes",
+	"elasticloadbalancing:DescribeLoadBalancers",
+	"elasticloadbalancing:DescribeRules",
+	"elasticloadbalancing:DescribeSSLPolicies",
+	"elasticloadbalancing:DescribeTags",
+	"elasticloadbalancing:DescribeTargetGroupAttributes",
+	"elasticloadbalancing:DescribeTargetGroups",
+	"elasticloadbalancing:DescribeTargetHealth",
+	"elasticloadbalancing:ModifyListener",
+	"elasticloadbalancing:ModifyLoadBalancerAttributes",
+	"elasticloadbalancing:ModifyRule",
+	"elasticloadbalancing:ModifyTargetGroup",
+	"elasticloadbalancing:ModifyTargetGroupAttributes",
+	"elasticloadbalancing:RegisterTargets",
+	"elasticloadbalancing:RemoveListenerCertificates",
+	"elasticloadbalancing:RemoveTags",
+	"elasticloadbalancing:SetIpAddressType",
+	"elasticloadbalancing:SetRulePriorities",
+	"elasticloadbalancing:SetSecurityGroups",
+	"elasticloadbalancing:SetSubnets",
+}
+
+// route53Actions are the IAM actions the controller calls unless DISABLE_ROUTE53 is set.
+var route53Actions = []string{
+	"route53:ChangeResourceRecordSets",
+	"route53:ChangeTagsForResource",
+	"route53:CreateHealthCheck",
+	"route53:DeleteHealthCheck",
+	"route53:GetChange",
+	"route53:GetHealthCheck",
+	"route53:ListHostedZonesByName",
+	"route53:ListResourceRecordSets",
+	"route53:UpdateHealthCheck",
+}
+
+// wafActions are the IAM actions the controller calls for the waf-acl-id annotation.
+var wafActions = []string{
+	"waf-regional:AssociateWebACL",
+	"waf-regional:DisassociateWebACL",
+	"waf-regional:GetWebACLForResource",
+}
+
+// globalAcceleratorActions are the IAM actions the controller calls for the global-accelerator
+// annotations.
+var globalAcceleratorActions = []string{
+	"globalaccelerator:CreateAccelerator",
+	"globalaccelerator:CreateEndpointGroup",
+	"globalaccelerator:CreateListener",
+	"globalaccelerator:DeleteAccelerator",
+	"globalaccelerator:DeleteEndpointGroup",
+	"globalaccelerator:DeleteListener",
+	"globalaccelerator:DescribeAccelerator",
+	"globalaccelerator:ListAccelerators",
+	"globalaccelerator:ListEndpointGroups",
+	"globalaccelerator:ListListeners",
+	"globalaccelerator:ListTagsForResource",
+	"globalaccelerator:TagResource",
+	"globalaccelerator:UpdateAccelerator",
+	"globalaccelerator:UpdateEndpointGroup",
+	"globalaccelerator:UpdateListener",
+}
+
+// alarmActions are the IAM actions the controller calls for the alarm annotations of target groups.
+var alarmActions = []string{
+	"cloudwatch:DeleteAlarms",
+	"cloudwatch:DescribeAlarms",
+	"cloudwatch:PutMetricAlarm",
+}
+
+// s3Actions are the IAM actions the controller calls when PROVISION_ACCESS_LOG_BUCKETS is set.
+var s3Actions = []string{
+	"s3:CreateBucket",
+	"s3:ListBucket",
+	"s3:PutBucketPolicy",
+	"s3:PutLifecycleConfiguration",
+}
+
+// iamResolverActions are the IAM actions the iam certificate resolver calls.
+var iamResolverActions = []string{
+	"iam:GetServerCertificate",
+	"iam:ListServerCertificates",
+}
+
+// configuredActions returns the IAM actions the controller calls with the AWS clients it created,
+// and the resources of those it's only allowed on some of: sts:AssumeRole on the roles of roles, a
+// map of namespaces to the IAM roles their ingresses are managed as.
+func (ac *ALBController) configuredActions(roles map[string]string) ([]string, map[string][]string) {
+	var actions []string
+	if awsutil.ACMsvc != nil {
+		actions = append(actions, acmActions...)
+	}
+	if awsutil.Ec2svc != nil {
+		actions = append(actions, ec2Actions...)
+	}
+	if awsutil.ALBsvc != nil {
+		actions = append(actions, elbv2Actions...)
+		if awsutil.ALBsvc.Tagging != nil {
+			actions = append(actions, "tag:GetResources")
+		}
+	}
+	if awsutil.Route53svc != nil {
+		actions = append(actions, route53Actions...)
+	}
+	if awsutil.WAFsvc != nil {
+		actions = append(actions, wafActions...)
+	}
+	if awsutil.GlobalAcceleratorsvc != nil {
+		actions = append(actions, globalAcceleratorActions...)
+	}
+	if awsutil.CloudWatchsvc != nil {
+		actions = append(actions, alarmActions...)
+		if ac.cloudWatchMetricsNamespace != "" {
+			actions = append(actions, "cloudwatch:PutMetricData")
+		}
+	}
+	if awsutil.SQSsvc != nil {
+		actions = append(actions, "sqs:ReceiveMessage", "sqs:DeleteMessage")
+	}
+	if awsutil.AutoScalingsvc != nil {
+		actions = append(actions, "autoscaling:CompleteLifecycleAction")
+	}
+	if awsutil.S3svc != nil {
+		actions = append(actions, s3Actions...)
+	}
+	for _, r := range ac.certificateResolvers {
+		if r.Name() == CertificateResolverIAM {
+			actions = append(actions, iamResolverActions...)
+		}
+	}
+
+	resources := make(map[string][]string)
+	assumed := make(map[string]bool)
+	for _, role := range roles {
+		if !assumed[role] {
+			assumed[role] = true
+			resources["sts:AssumeRole"] = append(resources["sts:AssumeRole"], role)
+		}
+	}
+	if len(assumed) > 0 {
+		sort.Strings(resources["sts:AssumeRole"])
+		actions = append(actions, "sts:AssumeRole")
+	}
+	return actions, resources
+}
+
+// checkPermissions simulates the IAM policies of the controller's principal against the actions it
+// calls with its configuration, on startup. Denied actions are logged and set to 1 in the
+// albingress_aws_missing_permissions gauge, rather than surfacing later as AccessDenied errors of
+// individual reconciles.
+func (ac *ALBController) checkPermissions() {
+	roles, err := ac.namespaceRoles()
+	if err != nil {
+		log.Warnf("Unable to list the namespaces, the permission to assume their IAM roles isn't checked. Error: %s", "controller", err.Error())
+	}
+	actions, resources := ac.configuredActions(roles)
+	arn, err := awsutil.CallerArn(awsutil.Session)
+	if err != nil {
+		log.Errorf("Unable to look up the IAM principal of the controller, its permissions aren't checked. Error: %s", "controller", err.Error())
+		return
+	}
+	denied, err := awsutil.IAMsvc.DeniedActions(arn, actions, resources)
+	if err != nil {
+		log.Warnf("Unable to simulate the IAM policies of %s, its permissions aren't checked. Allow it iam:SimulatePrincipalPolicy to check them. Error: %s",
+			"controller", arn, err.Error())
+		return
+	}
+	for _, action := range denied {
+		awsutil.AWSMissingPermissions.With(prometheus.Labels{"action": action}).Set(1)
+	}
+	if len(denied) > 0 {
+		log.Errorf("%s isn't allowed %s, calls to them will fail with AccessDenied. Grant them in its IAM policy, see examples/iam-policy.json",
+			"controller", arn, strings.Join(denied, ", "))
+		return
+	}
+	log.Infof("%s is allowed the %d actions the controller calls", "controller", arn, len(actions))
+}
+
+// preflight collects the results of the checks made by Verify.
+type preflight struct {
+	results []string
+	failed  bool
+}
+
+func (p *preflight) ok(check, format string, args ...interface{}) {
+	p.results = append(p.results, fmt.Sprintf("OK   %s: %s", check, fmt.Sprintf(format, args...)))
+}
+
+func (p *preflight) warn(check, format string, args ...interface{}) {
+	p.results = append(p.results, fmt.Sprintf("WARN %s: %s", check, fmt.Sprintf(format, args...)))
+}
+
+func (p *preflight) fail(check, format string, args ...interface{}) {
+	p.failed = true
+	p.results = append(p.results, fmt.Sprintf("FAIL %s: %s", check, fmt.Sprintf(format, args...)))
+}
+
+// Verify checks that the controller can manage the ingresses of the cluster, before it's installed
+// or upgraded: that its IAM principal is allowed the actions it calls, that the namespace IAM roles
+// can be assumed, that the ELBV2 account limits leave room for new ALBs, and that the subnets and
+// security groups of every ingress resolve and its hosts have a hosted zone. A line describing each
+// result is returned, along with false when any check failed.
+func (ac *ALBController) Verify() ([]string, bool) {
+	p := &preflight{}
+	ac.verifyPermissions(p)
+	ac.verifyQuotas(p)
+	ac.verifyIngresses(p)
+	return p.results, !p.failed
+}
+
+// verifyPermissions simulates the IAM policies of the controller's principal against the actions
+// it calls, and assumes every role namespaces are annotated with.
+func (ac *ALBController) verifyPermissions(p *preflight) {
+	roles, err := ac.namespaceRoles()
+	if err != nil {
+		p.fail("roles", "Unable to list the namespaces: %s", err.Error())
+		return
+	}
+	actions, resources := ac.configuredActions(roles)
+
+	arn, err := awsutil.CallerArn(awsutil.Session)
+	if err != nil {
+		p.fail("credentials", "Unable to look up the IAM principal of the controller: %s. Check the AWS credentials and region it's given", err.Error())
+		return
+	}
+	denied, err := awsutil.IAMsvc.DeniedActions(arn, actions, resources)
+	switch {
+	case err != nil:
+		p.warn("iam", "Unable to simulate the IAM policies of %s: %s. Allow it iam:SimulatePrincipalPolicy to check its permissions", arn, err.Error())
+	case len(denied) > 0:
+		p.fail("iam", "%s isn't allowed %s. Grant them in its IAM policy, see examples/iam-policy.json", arn, strings.Join(denied, ", "))
+	default:
+		p.ok("iam", "%s is allowed the %d actions the controller calls", arn, len(actions))
+	}
+
+	checked := make(map[string]bool)
+	for namespace, role := range roles {
+		if checked[role] {
+			continue
+		}
+		checked[role] = true
+		restore := awsutil.AssumeRole(role)
+		_, err := awsutil.ALBsvc.DescribeAccountLimits()
+		restore()
+		if err != nil {
+			p.fail("roles", "Unable to act as %s, the IAM role of namespace %s: %s. Its trust policy must allow %s to assume it",
+				role, namespace, err.Error(), arn)
+			continue
+		}
+		p.ok("roles", "%s, the IAM role of namespace %s, can be assumed", role, namespace)
+	}
+}
+
+// verifyQuotas compares the ALBs and target groups in the region against the ELBV2 account limits.
+func (ac *ALBController) verifyQuotas(p *preflight) {
+	limits, err := awsutil.ALBsvc.DescribeAccountLimits()
+	if err != nil {
+		p.fail("quotas", "Unable to describe the ELBV2 account limits: %s", err.Error())
+		return
+	}
+	loadBalancers, err := awsutil.ALBsvc.CountLoadBalancers()
+	if err != nil {
+		p.fail("quotas", "Unable to count the ALBs: %s", err.Error())
+		return
+	}
+	targetGroups, err := awsutil.ALBsvc.DescribeTargetGroups(nil)
+	if err != nil {
+		p.fail("quotas", "Unable to count the target groups: %s", err.Error())
+		return
+	}
+
+	for _, quota := range []struct {
+		name string
+		used int64
+	}{
+		{awsutil.LimitLoadBalancers, loadBalancers},
+		{awsutil.LimitTargetGroups, int64(len(targetGroups))},
+	} {
+		limit, ok := limits[quota.name]
+		if !ok {
+			continue
+		}
+		switch {
+		case quota.used >= limit:
+			p.fail("quotas", "%d of %d %s are used, ingresses needing new ones can't be reconciled. Request a limit increase from AWS support",
+				quota.used, limit, quota.name)
+		case float64(quota.used) >= quotaHeadroomWarningRatio*float64(limit):
+			p.warn("quotas", "%d of %d %s are used. Request a limit increase from AWS support before adding ingresses", quota.used, limit, quota.name)
+		default:
+			p.ok("quotas", "%d of %d %s are used", quota.used, limit, quota.name)
+		}
+	}
+}
+
+// verifyIngresses resolves the subnets and security groups of every ingress the controller would
+// manage, and the hosted zones of their hosts. Ingresses without the required annotations aren't
+// meant for the controller and are left out.
+func (ac *ALBController) verifyIngresses(p *preflight) {
+	if ac.client == nil {
+		p.warn("ingresses", "Unable to list the ingress resources without access to the Kubernetes API, their subnets, security groups and hosted zones aren't checked")
+		return
+	}
+	ingresses, err := ac.client.Extensions().Ingresses(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		p.fail("ingresses", "Unable to list the ingress resources: %s", err.Error())
+		return
+	}
+
+	for i := range ingresses.Items {
+		ingress := &ingresses.Items[i]
+		if !ac.validIngress(ingress) || !config.HasRequiredAnnotations(ingress.Annotations) {
+			continue
+		}
+		name := fmt.Sprintf("%s/%s", ingress.Namespace, ingress.Name)
+		role, err := ac.namespaceRole(ingress.Namespace)
+		if err != nil {
+			p.fail("ingresses", "%s: %s", name, err.Error())
+			continue
+		}
+
+		restore := awsutil.AssumeRole(role)
+		// Certificates of TLS secrets are only imported by the controller, and requested ones only
+		// requested by it, so the annotations of ingresses using them can't be resolved without
+		// making changes.
+		secrets := (ac.syncTLSSecrets || ac.requestsCertificates()) && len(ingress.Spec.TLS) > 0
+		if !secrets {
+			filtered := *ingress
+			filtered.Annotations, _ = config.FilterAnnotations(ingress.Annotations)
+			var annotations map[string]string
+			if annotations, err = ac.tlsAnnotations(&filtered); err == nil {
+				_, err = config.ParseAnnotations(annotations)
+			}
+		}
+		var zoneErrs []string
+		if !ac.disableRoute53 && config.ManagesDNS(ingress.Annotations) {
+			for _, rule := range ingress.Spec.Rules {
+				if rule.Host == "" {
+					continue
+				}
+				if _, err := awsutil.Route53svc.GetZoneID(&rule.Host); err != nil {
+					zoneErrs = append(zoneErrs, err.Error())
+				}
+			}
+		}
+		restore()
+
+		switch {
+		case err != nil:
+			p.fail("ingresses", "%s: %s", name, err.Error())
+		case len(zoneErrs) > 0:
+			p.fail("ingresses", "%s: %s. Create a hosted zone for the host, or set DISABLE_ROUTE53", name, strings.Join(zoneErrs, "; "))
+		case secrets:
+			p.warn("ingresses", "%s: hosted zones resolve, the annotations of ingresses with spec.tls aren't checked while SYNC_TLS_SECRETS is set or the request resolver is enabled", name)
+		default:
+			p.ok("ingresses", "%s: subnets, security groups and hosted zones resolve", name)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/alb-ingress-controller/awsutil"
+	"github.com/coreos/alb-ingress-controller/awsutil/fake"
+)
+
+func TestConfiguredActions(t *testing.T) {
+	clients := fake.New()
+	defer clients.Install()()
+	awsutil.Route53svc = nil
+
+	ac := &ALBController{}
+	roles := map[string]string{
+		"team-a": "arn:aws:iam::123456789012:role/team-a",
+		"team-b": "arn:aws:iam::123456789012:role/team-a",
+	}
+	actions, resources := ac.configuredActions(roles)
+	has := make(map[string]bool)
+	for _, action := range actions {
+		has[action] = true
+	}
+	for _, action := range []string{"elasticloadbalancing:CreateRule", "waf-regional:GetWebACLForResource", "cloudwatch:PutMetricAlarm", "sts:AssumeRole"} {
+		if !has[action] {
+			t.Errorf("configuredActions(): expected %s, actual %v", action, actions)
+		}
+	}
+	// Route 53 isn't called without a client.
+	if has["route53:ChangeResourceRecordSets"] {
+		t.Errorf("configuredActions(): expected no route53 actions, actual %v", actions)
+	}
+	expected := map[string][]string{"sts:AssumeRole": {"arn:aws:iam::123456789012:role/team-a"}}
+	if !reflect.DeepEqual(resources, expected) {
+		t.Errorf("configuredActions(): expected resources %v, actual %v", expected, resources)
+	}
+
+	if actions, _ := ac.configuredActions(nil); len(actions) == 0 || actions[len(actions)-1] == "sts:AssumeRole" {
+		t.Errorf("configuredActions(): expected no sts:AssumeRole without namespace roles, actual %v", actions)
+	}
+}
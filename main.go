@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/golang/glog"
@@ -17,6 +20,10 @@ import (
 	ingresscontroller "k8s.io/ingress/core/pkg/ingress/controller"
 )
 
+// defaultShutdownTimeout is the default number of seconds the reconcile in flight is waited on
+// when shutting down, less than the 30 second termination grace period of pods.
+const defaultShutdownTimeout = 25
+
 func main() {
 	flag.Set("logtostderr", "true")
 	flag.CommandLine.Parse([]string{})
@@ -26,6 +33,11 @@ func main() {
 		glog.Exit("A CLUSTER_NAME environment variable must be defined")
 	}
 
+	controllerID := os.Getenv("CONTROLLER_ID")
+	if controllerID == "" {
+		glog.Exit("A CONTROLLER_ID environment variable must be defined, unique to the cluster. Controllers upgraded from a version defaulting it keep their resources with alb-ingress-controller")
+	}
+
 	logLevel := os.Getenv("LOG_LEVEL")
 	log.SetLogLevel(logLevel)
 
@@ -33,16 +45,154 @@ func main() {
 
 	disableRoute53, _ := strconv.ParseBool(os.Getenv("DISABLE_ROUTE53"))
 
+	syncTLSSecrets, _ := strconv.ParseBool(os.Getenv("SYNC_TLS_SECRETS"))
+
+	httpsOnly, _ := strconv.ParseBool(os.Getenv("HTTPS_ONLY"))
+
+	targetBatchSize, _ := strconv.Atoi(os.Getenv("TARGET_BATCH_SIZE"))
+
+	targetBatchRate, _ := strconv.ParseFloat(os.Getenv("TARGET_BATCH_RATE"), 32)
+
+	targetHealthInterval, _ := strconv.Atoi(os.Getenv("TARGET_HEALTH_INTERVAL"))
+
+	podTargetHealthConditions, _ := strconv.ParseBool(os.Getenv("POD_TARGET_HEALTH_CONDITIONS"))
+
+	targetWarmupTimeout, _ := strconv.Atoi(os.Getenv("TARGET_WARMUP_TIMEOUT"))
+
+	targetFlapThreshold, _ := strconv.Atoi(os.Getenv("TARGET_FLAP_THRESHOLD"))
+
+	targetFlapWindow, _ := strconv.Atoi(os.Getenv("TARGET_FLAP_WINDOW"))
+
+	circuitBreakerThreshold, _ := strconv.Atoi(os.Getenv("AWS_CIRCUIT_BREAKER_THRESHOLD"))
+
+	circuitBreakerCooldown, _ := strconv.Atoi(os.Getenv("AWS_CIRCUIT_BREAKER_COOLDOWN"))
+
+	heartbeatInterval, _ := strconv.Atoi(os.Getenv("AWS_HEARTBEAT_INTERVAL"))
+
+	heartbeatFailureThreshold, _ := strconv.Atoi(os.Getenv("AWS_HEARTBEAT_FAILURE_THRESHOLD"))
+
+	disablePermissionCheck, _ := strconv.ParseBool(os.Getenv("DISABLE_PERMISSION_CHECK"))
+
+	useFIPSEndpoints, _ := strconv.ParseBool(os.Getenv("USE_FIPS_ENDPOINTS"))
+
+	discoverByTags, _ := strconv.ParseBool(os.Getenv("DISCOVER_BY_TAGS"))
+
+	// The setting takes the values the AWS SDKs understand, which the vendored one predates.
+	stsRegionalEndpoints := os.Getenv("AWS_STS_REGIONAL_ENDPOINTS") == "regional"
+
+	reconcileWindow, _ := strconv.Atoi(os.Getenv("RECONCILE_WINDOW"))
+
+	driftInterval, _ := strconv.Atoi(os.Getenv("DRIFT_INTERVAL"))
+
+	lifecycleDrainTimeout, _ := strconv.Atoi(os.Getenv("LIFECYCLE_DRAIN_TIMEOUT"))
+
+	targetGroupShift, _ := strconv.Atoi(os.Getenv("TARGET_GROUP_SHIFT_PERIOD"))
+
+	certificateExpiryWarning, _ := strconv.Atoi(os.Getenv("CERTIFICATE_EXPIRY_WARNING_DAYS"))
+
+	shardCount, _ := strconv.Atoi(os.Getenv("SHARD_COUNT"))
+
+	shardIndex, _ := strconv.Atoi(os.Getenv("SHARD_INDEX"))
+
+	shardBy := os.Getenv("SHARD_BY")
+
+	groupBy := os.Getenv("GROUP_BY")
+
+	ruleQuota, _ := strconv.Atoi(os.Getenv("ALB_RULE_QUOTA"))
+
+	ruleSwapThreshold, _ := strconv.Atoi(os.Getenv("RULE_SWAP_THRESHOLD"))
+
+	securityGroupRuleQuota, _ := strconv.Atoi(os.Getenv("SECURITY_GROUP_RULE_QUOTA"))
+
+	sharedSecurityGroup, _ := strconv.ParseBool(os.Getenv("SHARED_SECURITY_GROUP"))
+
+	albHourlyPrice, _ := strconv.ParseFloat(os.Getenv("COST_ALB_HOURLY_PRICE"), 64)
+
+	lcuHourlyPrice, _ := strconv.ParseFloat(os.Getenv("COST_LCU_HOURLY_PRICE"), 64)
+
+	estimatedLCUs, _ := strconv.ParseFloat(os.Getenv("COST_ESTIMATED_LCUS"), 64)
+
+	provisionAccessLogBuckets, _ := strconv.ParseBool(os.Getenv("PROVISION_ACCESS_LOG_BUCKETS"))
+
+	accessLogRetention, _ := strconv.Atoi(os.Getenv("ACCESS_LOG_RETENTION_DAYS"))
+
+	shutdownTimeout, _ := strconv.Atoi(os.Getenv("SHUTDOWN_TIMEOUT"))
+	if shutdownTimeout == 0 {
+		shutdownTimeout = defaultShutdownTimeout
+	}
+
 	conf := &config.Config{
-		ClusterName:    clusterName,
-		AWSDebug:       awsDebug,
-		DisableRoute53: disableRoute53,
+		ClusterName:                   clusterName,
+		ControllerID:                  controllerID,
+		AWSDebug:                      awsDebug,
+		UserAgentSuffix:               os.Getenv("AWS_USER_AGENT_SUFFIX"),
+		UseFIPSEndpoints:              useFIPSEndpoints,
+		STSRegionalEndpoints:          stsRegionalEndpoints,
+		STSRegion:                     os.Getenv("AWS_STS_REGION"),
+		DiscoverByTags:                discoverByTags,
+		DisableRoute53:                disableRoute53,
+		DNSProvider:                   os.Getenv("DNS_PROVIDER"),
+		SyncTLSSecrets:                syncTLSSecrets,
+		CertificateResolvers:          os.Getenv("CERTIFICATE_RESOLVERS"),
+		HTTPSOnly:                     httpsOnly,
+		MinSSLPolicy:                  os.Getenv("MIN_SSL_POLICY"),
+		AllowedAnnotations:            os.Getenv("ALLOWED_ANNOTATIONS"),
+		TargetBatchSize:               targetBatchSize,
+		TargetBatchRatePerSecond:      float32(targetBatchRate),
+		TargetHealthIntervalSeconds:   targetHealthInterval,
+		PodTargetHealthConditions:     podTargetHealthConditions,
+		TargetWarmupTimeoutSeconds:    targetWarmupTimeout,
+		TargetFlapThreshold:           targetFlapThreshold,
+		TargetFlapWindowSeconds:       targetFlapWindow,
+		TargetGroupNameTemplate:       os.Getenv("TARGET_GROUP_NAME_TEMPLATE"),
+		TargetGroupShiftSeconds:       targetGroupShift,
+		DefaultLoadBalancerAttributes: os.Getenv("DEFAULT_LOAD_BALANCER_ATTRIBUTES"),
+		DefaultTargetGroupAttributes:  os.Getenv("DEFAULT_TARGET_GROUP_ATTRIBUTES"),
+		CircuitBreakerThreshold:       circuitBreakerThreshold,
+		CircuitBreakerCooldownSeconds: circuitBreakerCooldown,
+		HeartbeatIntervalSeconds:      heartbeatInterval,
+		HeartbeatFailureThreshold:     heartbeatFailureThreshold,
+		DisablePermissionCheck:        disablePermissionCheck,
+		ReconcileWindowSeconds:        reconcileWindow,
+		DriftIntervalSeconds:          driftInterval,
+		ChangeEventsQueueURL:          os.Getenv("CHANGE_EVENTS_QUEUE_URL"),
+		LifecycleEventsQueueURL:       os.Getenv("LIFECYCLE_EVENTS_QUEUE_URL"),
+		LifecycleDrainTimeoutSeconds:  lifecycleDrainTimeout,
+		InstanceEventsQueueURL:        os.Getenv("INSTANCE_EVENTS_QUEUE_URL"),
+		CloudWatchMetricsNamespace:    os.Getenv("CLOUDWATCH_METRICS_NAMESPACE"),
+		ProvisionAccessLogBuckets:     provisionAccessLogBuckets,
+		AccessLogRetentionDays:        accessLogRetention,
+		CertificateExpiryWarningDays:  certificateExpiryWarning,
+		ShardCount:                    shardCount,
+		ShardIndex:                    shardIndex,
+		ShardBy:                       shardBy,
+		GroupBy:                       groupBy,
+		RuleQuota:                     ruleQuota,
+		RuleSwapThreshold:             ruleSwapThreshold,
+		SecurityGroupRuleQuota:        securityGroupRuleQuota,
+		SharedSecurityGroup:           sharedSecurityGroup,
+		NodeSecurityGroups:            os.Getenv("NODE_SECURITY_GROUPS"),
+		CostAllocationTags:            os.Getenv("COST_ALLOCATION_TAGS"),
+		ALBHourlyPrice:                albHourlyPrice,
+		LCUHourlyPrice:                lcuHourlyPrice,
+		EstimatedLCUs:                 estimatedLCUs,
+		StateConfigMap:                os.Getenv("STATE_CONFIGMAP"),
+		AlbConfigNamespace:            os.Getenv("ALB_CONFIG_NAMESPACE"),
 	}
 
 	if len(clusterName) > 11 {
 		glog.Exit("CLUSTER_NAME must be 11 characters or less")
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "cleanup-orphans" {
+		cleanupOrphans(conf, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		verify(conf, os.Args[2:])
+		return
+	}
+
 	port := "8080"
 	http.Handle("/metrics", promhttp.Handler())
 	go http.ListenAndServe(fmt.Sprintf(":%s", port), nil)
@@ -56,10 +206,87 @@ func main() {
 	}
 
 	http.HandleFunc("/state", ac.StateHandler)
+	http.HandleFunc("/status", ac.StatusHandler)
+	http.HandleFunc("/ingresses", ac.IngressesHandler)
 
-	defer func() {
-		glog.Infof("Shutting down ingress controller...")
-		ic.Stop()
-	}()
+	go handleSigterm(ac, ic, time.Duration(shutdownTimeout)*time.Second)
 	ic.Start()
+	// Start returns once the ingress controller was stopped, the process exits once handleSigterm
+	// shut the ALB controller down.
+	select {}
+}
+
+// handleSigterm shuts the controller down on SIGTERM or SIGINT: no ingress update is processed any
+// more, the reconcile in flight is completed within timeout and the process exits.
+func handleSigterm(ac *controller.ALBController, ic *ingresscontroller.GenericController, timeout time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-signals
+	glog.Infof("Received %s, shutting down ingress controller...", sig)
+
+	ic.Stop()
+	code := 0
+	if err := ac.Shutdown(timeout); err != nil {
+		glog.Errorf("Unclean shutdown: %s", err.Error())
+		code = 1
+	}
+	glog.Infof("Ingress controller shut down")
+	glog.Flush()
+	os.Exit(code)
+}
+
+// cleanupOrphans runs the cleanup-orphans command, deleting the AWS resources of the controller that
+// no ingress resource exists for, and prints them.
+func cleanupOrphans(conf *config.Config, args []string) {
+	flags := flag.NewFlagSet("cleanup-orphans", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "list the orphaned resources without deleting them")
+	all := flags.Bool("all", false, "treat every resource of the controller as orphaned, e.g. once the cluster was deleted")
+	flags.Parse(args)
+
+	ac := newCommandController(conf)
+
+	changes, err := ac.CleanupOrphans(*dryRun, *all)
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	if err != nil {
+		glog.Exit(err)
+	}
+}
+
+// verify runs the verify command, printing the results of the preflight checks of the controller.
+// It exits with a non-zero status when any of them failed.
+func verify(conf *config.Config, args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	ingressClass := flags.String("ingress-class", "", "only check the ingresses of this class, like the controller's --ingress-class")
+	flags.Parse(args)
+
+	ac := newCommandController(conf)
+	ac.IngressClass = *ingressClass
+
+	results, ok := ac.Verify()
+	for _, result := range results {
+		fmt.Println(result)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// newCommandController returns an ALBController for a command making a single pass, which doesn't
+// start any of the periodic syncs of the controller, nor restore the state snapshot the controller
+// saved.
+func newCommandController(conf *config.Config) *controller.ALBController {
+	conf.StateConfigMap = ""
+	conf.TargetHealthIntervalSeconds = -1
+	conf.DriftIntervalSeconds = -1
+	conf.TargetGroupShiftSeconds = -1
+	conf.ReconcileWindowSeconds = -1
+	conf.HeartbeatIntervalSeconds = -1
+	conf.DisablePermissionCheck = true
+	conf.ChangeEventsQueueURL = ""
+	conf.LifecycleEventsQueueURL = ""
+	conf.InstanceEventsQueueURL = ""
+	conf.CloudWatchMetricsNamespace = ""
+	return controller.NewALBController(&aws.Config{MaxRetries: aws.Int(5)}, conf)
 }